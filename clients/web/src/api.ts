@@ -1,6 +1,11 @@
 export async function jsonFetch<T>(input: RequestInfo, init?: RequestInit): Promise<T> {
   const response = await fetch(input, init);
   const payload = await response.json();
+  if (response.status === 401 && typeof payload?.details?.login === 'string') {
+    // The SSO session expired; sign in again and come back here.
+    const here = window.location.pathname + window.location.search;
+    window.location.assign(`${payload.details.login}?redirect=${encodeURIComponent(here)}`);
+  }
   if (!response.ok) {
     throw new Error(payload?.error ?? `HTTP ${response.status}`);
   }
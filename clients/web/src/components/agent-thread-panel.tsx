@@ -4,6 +4,9 @@ import { AgentEvent, ThreadState } from '../types';
 import { isConversationEvent, isTransientProgressEvent, roleForEvent } from '../thread-utils';
 import { jsonFetch, rpcPost, isThreadNotFoundError } from '../api';
 
+// SSE event names the server sends thread events under (see sseEventName).
+const THREAD_EVENT_NAMES = ['interaction', 'draft', 'darkhold', 'delta', 'item', 'turn', 'thread'];
+
 function nowIso(): string {
   return new Date().toISOString();
 }
@@ -188,7 +191,7 @@ export function AgentThreadPanel({ thread, onError, onTurnCompleted, onInteracti
 
     threadEventsSourceRef.current?.close();
     const source = new EventSource(`/api/thread/events/stream?threadId=${encodeURIComponent(threadId)}`);
-    source.onmessage = (event) => {
+    const onEvent = (event: MessageEvent<string>) => {
       let parsed: { method?: string; params?: unknown };
       try {
         parsed = JSON.parse(event.data) as { method?: string; params?: unknown };
@@ -200,6 +203,11 @@ export function AgentThreadPanel({ thread, onError, onTurnCompleted, onInteracti
       }
       handleNotificationRef.current(parsed.method, parsed.params);
     };
+    // Events arrive named by kind; anything unnamed still comes as "message".
+    source.onmessage = onEvent;
+    for (const name of THREAD_EVENT_NAMES) {
+      source.addEventListener(name, onEvent);
+    }
     source.onerror = () => {
       // Browser will reconnect automatically and resume from Last-Event-ID.
     };
@@ -2,6 +2,7 @@ export type FolderEntry = {
   name: string;
   path: string;
   kind: 'directory' | 'file';
+  image?: boolean;
 };
 
 export type FolderListing = {
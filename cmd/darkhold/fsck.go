@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"darkhold-go/internal/events"
+	"darkhold-go/internal/netfs"
+)
+
+// runFsck implements `darkhold fsck`: check (and by default repair) the JSONL
+// event logs under a data dir. It fails when issues are left unrepaired.
+func runFsck(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	dataDir := flags.String("data-dir", "", "darkhold data directory to check")
+	dryRun := flags.Bool("dry-run", false, "report issues without repairing them")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *dataDir == "" {
+		return errors.New("fsck: --data-dir is required")
+	}
+
+	store := events.NewStore(filepath.Join(*dataDir, "events"))
+	store.Layout = events.ReadLayout(store.RootDir)
+	store.Network = netfs.Detect(store.RootDir).Network
+	if !*dryRun {
+		// Give bare legacy lines their IDs first, so dropping a bad line
+		// cannot renumber them.
+		if _, err := store.MigrateIDs(); err != nil {
+			return err
+		}
+	}
+	report, err := store.Fsck(!*dryRun)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		for _, issue := range report.Issues {
+			where := issue.Log
+			if issue.Line > 0 {
+				where = fmt.Sprintf("%s:%d", issue.Log, issue.Line)
+			}
+			status := "unrepaired"
+			if issue.Repaired {
+				status = "repaired"
+			}
+			fmt.Fprintf(stdout, "%-10s %-14s %s\n", status, issue.Kind, strings.TrimSpace(where+" "+issue.Detail))
+		}
+		fmt.Fprintf(stdout, "%d logs, %d records: %d repaired, %d unrepaired\n", report.Logs, report.Records, report.Repaired, report.Unrepaired)
+	}
+	if report.Unrepaired > 0 {
+		return fmt.Errorf("fsck: %d issues left unrepaired", report.Unrepaired)
+	}
+	return nil
+}
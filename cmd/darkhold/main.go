@@ -2,22 +2,59 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"darkhold-go/internal/bench"
 	"darkhold-go/internal/config"
+	"darkhold-go/internal/conformance"
 	"darkhold-go/internal/events"
 	browserfs "darkhold-go/internal/fs"
+	"darkhold-go/internal/grpcapi"
+	"darkhold-go/internal/mcp"
+	"darkhold-go/internal/pgstore"
 	"darkhold-go/internal/server"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		if err := bench.Main(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "conformance" {
+		if err := conformance.Main(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "fsck" {
+		if err := runFsck(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "mcp" {
+		if err := mcp.Main(os.Args[2:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	cfg, err := config.Parse(os.Args[1:])
 	if err != nil {
 		log.Fatal(err)
@@ -27,40 +64,114 @@ func main() {
 		log.Fatal(err)
 	}
 
-	eventsTmpRoot, err := os.MkdirTemp("", "darkhold-events-")
-	if err != nil {
+	ephemeralDataDir := cfg.DataDir == ""
+	if ephemeralDataDir {
+		cfg.DataDir, err = os.MkdirTemp("", "darkhold-")
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	eventsRoot := filepath.Join(cfg.DataDir, "events")
+	if err := os.MkdirAll(eventsRoot, 0o755); err != nil {
 		log.Fatal(err)
 	}
-	store := events.NewStore(eventsTmpRoot)
+	var store events.Storage
+	closeStore := func() {}
+	if cfg.EventStore == "postgres" {
+		pg, err := pgstore.Open(context.Background(), cfg.PostgresURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		closeStore = pg.Close
+		store = pg
+	} else {
+		store, err = events.Open(cfg.EventStore, eventsRoot)
+		if err != nil {
+			log.Fatal(err)
+		}
+		switch st := store.(type) {
+		case *events.Store:
+			st.Chain = cfg.EventChain
+			moved, err := st.Relayout(cfg.EventStoreLayout)
+			if err != nil {
+				log.Fatalf("arrange event store as %s: %v", cfg.EventStoreLayout, err)
+			}
+			if moved > 0 {
+				log.Printf("[events] moved %d thread logs to the %s layout", moved, cfg.EventStoreLayout)
+			}
+		case *events.Memory:
+			st.Chain = cfg.EventChain
+		}
+	}
 	srv := server.New(cfg, store)
 
-	httpServer := &http.Server{
-		Addr:    fmt.Sprintf("%s:%d", cfg.Bind, cfg.Port),
-		Handler: srv.Handler(),
+	handler := srv.Handler()
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	httpServer := newHTTPServer(cfg, handler)
+	httpServer.TLSConfig = tlsConfig
+	scheme := "http"
+	if cfg.TLSCertFile != "" {
+		scheme = "https"
 	}
 
 	allowListNote := ""
 	if len(cfg.AllowCIDRs) > 0 {
 		allowListNote = fmt.Sprintf(" (allowed CIDRs: %s, plus localhost)", strings.Join(cfg.AllowCIDRs, ", "))
 	}
-	fmt.Printf("darkhold-go listening on http://%s:%d%s (base path: %s, app-server transport: stdio per session)\n",
+	if len(cfg.RouteCIDRs) > 0 {
+		allowListNote += fmt.Sprintf(" (%d route CIDR rules)", len(cfg.RouteCIDRs))
+	}
+	fmt.Printf("darkhold-go listening on %s://%s:%d%s (base path: %s, data dir: %s, agent: %s, app-server transport: stdio per session)\n",
+		scheme,
 		cfg.Bind,
 		cfg.Port,
 		allowListNote,
 		browserfs.GetHomeRoot(),
+		cfg.DataDir,
+		cfg.AgentCmd,
 	)
 
 	errCh := make(chan error, 1)
 	go func() {
-		errCh <- httpServer.ListenAndServe()
+		serve := httpServer.ListenAndServe
+		if cfg.TLSCertFile != "" {
+			serve = func() error { return httpServer.ListenAndServeTLS("", "") }
+		}
+		errCh <- srv.ServeSupervised("http", serve)
 	}()
 
+	var grpcServer *grpc.Server
+	if cfg.GRPCPort != 0 {
+		grpcServer, err = newGRPCServer(cfg, handler, tlsConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+		listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", cfg.Bind, cfg.GRPCPort))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("darkhold-go gRPC API listening on %s:%d\n", cfg.Bind, cfg.GRPCPort)
+		go func() {
+			errCh <- grpcServer.Serve(listener)
+		}()
+	}
+
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 
+	exitCode := 0
 	select {
 	case sig := <-sigCh:
 		fmt.Printf("received %s, shutting down...\n", sig)
+	case req := <-srv.ShutdownRequests():
+		action := "shutdown"
+		if req.Restart {
+			action, exitCode = "restart", server.RestartExitCode
+		}
+		fmt.Printf("%s requested by %s over the admin API, shutting down...\n", action, req.Identity)
 	case err := <-errCh:
 		if err != nil && err != http.ErrServerClosed {
 			log.Fatal(err)
@@ -70,6 +181,97 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	_ = httpServer.Shutdown(ctx)
+	if grpcServer != nil {
+		stopGRPCServer(ctx, grpcServer)
+	}
 	_ = srv.Shutdown(ctx)
-	_ = store.Cleanup()
+	closeStore()
+	if ephemeralDataDir {
+		_ = os.RemoveAll(cfg.DataDir)
+	}
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
+
+// newHTTPServer builds the listener for the API, SSE streams and the web
+// client. There is deliberately no ReadTimeout or WriteTimeout: both would
+// cut off long-lived event streams and WebSockets. Slow clients are bounded
+// by ReadHeaderTimeout instead, and idle keep-alive connections by
+// IdleTimeout.
+func newHTTPServer(cfg config.Config, handler http.Handler) *http.Server {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	// HTTP/2 is only offered over TLS, where browsers use it to multiplex
+	// event streams past their six-connections-per-host HTTP/1.1 limit.
+	protocols.SetHTTP2(cfg.TLSCertFile != "")
+	return &http.Server{
+		Addr:              fmt.Sprintf("%s:%d", cfg.Bind, cfg.Port),
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		Protocols:         protocols,
+		HTTP2: &http.HTTP2Config{
+			MaxConcurrentStreams: cfg.HTTP2MaxStreams,
+		},
+	}
+}
+
+// newTLSConfig loads the HTTPS certificate and, with --tls-client-ca, the CAs
+// client certificates are verified against. It returns nil without TLS.
+func newTLSConfig(cfg config.Config) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if len(cfg.TLSClientCAFiles) == 0 {
+		return tlsConfig, nil
+	}
+	pool := x509.NewCertPool()
+	for _, path := range cfg.TLSClientCAFiles {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates in %s", path)
+		}
+	}
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	if cfg.TLSRequireClientCert {
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// newGRPCServer builds the --grpc-port listener's server. It uses the HTTPS
+// TLS settings, client certificates included, when they are configured, and
+// plaintext HTTP/2 otherwise.
+func newGRPCServer(cfg config.Config, handler http.Handler, tlsConfig *tls.Config) (*grpc.Server, error) {
+	var opts []grpc.ServerOption
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig.Clone())))
+	}
+	opts = append(opts, grpc.MaxConcurrentStreams(uint32(cfg.HTTP2MaxStreams)))
+	return grpcapi.NewServer(handler, opts...), nil
+}
+
+// stopGRPCServer lets in-flight calls finish until ctx ends, then closes
+// what is left, such as open event streams.
+func stopGRPCServer(ctx context.Context, gs *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		gs.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		gs.Stop()
+	}
 }
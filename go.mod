@@ -1,8 +1,31 @@
 module darkhold-go
 
-go 1.25
+go 1.25.0
 
 require (
 	github.com/oklog/ulid/v2 v2.1.1
 	github.com/tmaxmax/go-sse v0.11.0
 )
+
+require github.com/yuin/goldmark v1.8.6
+
+require (
+	github.com/coder/websocket v1.8.15
+	github.com/fsnotify/fsnotify v1.9.0
+	github.com/jackc/pgx/v5 v5.11.0
+	go.etcd.io/bbolt v1.4.3
+	golang.org/x/image v0.44.0
+	golang.org/x/sys v0.47.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20260706201446-f0a921348800
+	google.golang.org/grpc v1.84.0
+	google.golang.org/protobuf v1.36.12
+)
+
+require (
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
+	github.com/jackc/puddle/v2 v2.2.2 // indirect
+	golang.org/x/net v0.57.0 // indirect
+	golang.org/x/sync v0.22.0 // indirect
+	golang.org/x/text v0.40.0 // indirect
+)
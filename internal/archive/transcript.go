@@ -0,0 +1,50 @@
+package archive
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Transcript renders the user and agent messages found in item/completed
+// events as markdown, in log order.
+func Transcript(title string, payloads []string) string {
+	var b strings.Builder
+	if title == "" {
+		title = "Thread transcript"
+	}
+	b.WriteString("# " + title + "\n")
+	for _, payload := range payloads {
+		var event struct {
+			Method string `json:"method"`
+			Params struct {
+				Item map[string]any `json:"item"`
+			} `json:"params"`
+		}
+		if err := json.Unmarshal([]byte(payload), &event); err != nil || event.Method != "item/completed" {
+			continue
+		}
+		item := event.Params.Item
+		var speaker, text string
+		switch item["type"] {
+		case "userMessage":
+			speaker = "User"
+			content, _ := item["content"].([]any)
+			parts := make([]string, 0, len(content))
+			for _, raw := range content {
+				part, _ := raw.(map[string]any)
+				if t, ok := part["text"].(string); ok && part["type"] == "text" {
+					parts = append(parts, t)
+				}
+			}
+			text = strings.Join(parts, "\n\n")
+		case "agentMessage":
+			speaker = "Agent"
+			text, _ = item["text"].(string)
+		}
+		if speaker == "" || strings.TrimSpace(text) == "" {
+			continue
+		}
+		b.WriteString("\n## " + speaker + "\n\n" + strings.TrimSpace(text) + "\n")
+	}
+	return b.String()
+}
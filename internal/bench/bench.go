@@ -0,0 +1,411 @@
+// Package bench drives a darkhold server backed by the built-in fake agent
+// with concurrent threads, turns and SSE clients, and reports latency
+// percentiles for RPCs and event delivery.
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"darkhold-go/internal/config"
+	"darkhold-go/internal/events"
+	"darkhold-go/internal/server"
+)
+
+// Options sizes a benchmark run.
+type Options struct {
+	Threads        int
+	TurnsPerThread int
+	// SSEClients is the number of event stream subscribers per thread.
+	SSEClients int
+	// FakeLatency and ApprovalRate configure the fake agent.
+	FakeLatency  time.Duration
+	ApprovalRate float64
+	// TurnTimeout bounds how long one turn may take before it counts as an
+	// error.
+	TurnTimeout time.Duration
+}
+
+// DefaultOptions match a small team's worth of load.
+func DefaultOptions() Options {
+	return Options{Threads: 10, TurnsPerThread: 5, SSEClients: 2, FakeLatency: 10 * time.Millisecond, ApprovalRate: 0.5, TurnTimeout: 30 * time.Second}
+}
+
+// Summary holds percentiles for one measured series.
+type Summary struct {
+	Name  string        `json:"name"`
+	Count int           `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// Report is the outcome of a run.
+type Report struct {
+	Options        Options       `json:"options"`
+	Elapsed        time.Duration `json:"elapsed"`
+	TurnsCompleted int           `json:"turnsCompleted"`
+	EventsReceived int64         `json:"eventsReceived"`
+	Errors         []string      `json:"errors"`
+	Series         []Summary     `json:"series"`
+}
+
+// Series names reported by Run.
+const (
+	SeriesThreadStart   = "rpc thread/start"
+	SeriesTurnStart     = "rpc turn/start"
+	SeriesRespond       = "rpc interaction/respond"
+	SeriesFirstEvent    = "event first (turn/start -> first SSE event)"
+	SeriesTurnCompleted = "event turn/completed (turn/start -> SSE)"
+)
+
+type recorder struct {
+	mu      sync.Mutex
+	samples map[string][]time.Duration
+	errors  []string
+	events  int64
+	turns   int
+}
+
+func (r *recorder) add(series string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.samples[series] = append(r.samples[series], d)
+}
+
+func (r *recorder) fail(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+// Percentile returns the p-th percentile (0-100) of sorted samples using the
+// nearest-rank method.
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	rank = min(max(rank, 0), len(sorted)-1)
+	return sorted[rank]
+}
+
+func summarize(name string, samples []time.Duration) Summary {
+	sorted := append([]time.Duration(nil), samples...)
+	slices.Sort(sorted)
+	out := Summary{Name: name, Count: len(sorted)}
+	if len(sorted) > 0 {
+		out.P50 = Percentile(sorted, 50)
+		out.P90 = Percentile(sorted, 90)
+		out.P99 = Percentile(sorted, 99)
+		out.Max = sorted[len(sorted)-1]
+	}
+	return out
+}
+
+// Run starts an in-process server on a loopback port, executes the workload
+// and shuts everything down again.
+func Run(ctx context.Context, opts Options) (Report, error) {
+	if opts.Threads < 1 || opts.TurnsPerThread < 1 || opts.SSEClients < 1 {
+		return Report{}, errors.New("threads, turns and sse-clients must be at least 1")
+	}
+	if opts.TurnTimeout <= 0 {
+		opts.TurnTimeout = DefaultOptions().TurnTimeout
+	}
+	dataDir, err := os.MkdirTemp("", "darkhold-bench-")
+	if err != nil {
+		return Report{}, err
+	}
+	defer os.RemoveAll(dataDir)
+	eventsRoot := filepath.Join(dataDir, "events")
+	if err := os.MkdirAll(eventsRoot, 0o755); err != nil {
+		return Report{}, err
+	}
+
+	cfg := config.Config{
+		Bind:             "127.0.0.1",
+		DataDir:          dataDir,
+		AgentCmd:         config.FakeAgentCmd,
+		FakeLatency:      opts.FakeLatency,
+		FakeApprovalRate: opts.ApprovalRate,
+	}
+	srv := server.New(cfg, events.NewStore(eventsRoot))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return Report{}, err
+	}
+	httpServer := &http.Server{Handler: srv.Handler()}
+	go func() { _ = httpServer.Serve(listener) }()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	h := &harness{
+		baseURL: "http://" + listener.Addr().String(),
+		client:  &http.Client{},
+		opts:    opts,
+		rec:     &recorder{samples: map[string][]time.Duration{}},
+		cwd:     dataDir,
+	}
+	started := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Threads; i++ {
+		wg.Go(func() {
+			h.runThread(ctx)
+		})
+	}
+	wg.Wait()
+
+	report := Report{
+		Options:        opts,
+		Elapsed:        time.Since(started),
+		TurnsCompleted: h.rec.turns,
+		EventsReceived: h.rec.events,
+		Errors:         h.rec.errors,
+	}
+	for _, name := range []string{SeriesThreadStart, SeriesTurnStart, SeriesRespond, SeriesFirstEvent, SeriesTurnCompleted} {
+		report.Series = append(report.Series, summarize(name, h.rec.samples[name]))
+	}
+	return report, nil
+}
+
+type harness struct {
+	baseURL string
+	client  *http.Client
+	opts    Options
+	rec     *recorder
+	cwd     string
+}
+
+type sseEvent struct {
+	at   time.Time
+	data map[string]any
+}
+
+func (h *harness) post(ctx context.Context, path string, body any) (map[string]any, error) {
+	encoded, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.baseURL+path, bytes.NewReader(encoded))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var payload map[string]any
+	_ = json.NewDecoder(resp.Body).Decode(&payload)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: HTTP %d: %v", path, resp.StatusCode, payload["error"])
+	}
+	return payload, nil
+}
+
+func (h *harness) rpc(ctx context.Context, series, method string, params map[string]any) (map[string]any, error) {
+	begin := time.Now()
+	result, err := h.post(ctx, "/api/rpc", map[string]any{"method": method, "params": params})
+	if err == nil {
+		h.rec.add(series, time.Since(begin))
+	}
+	return result, err
+}
+
+// subscribe opens a thread event stream and forwards each event until ctx
+// ends.
+func (h *harness) subscribe(ctx context.Context, threadID string, out chan<- sseEvent) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.baseURL+"/api/thread/events/stream?threadId="+threadID, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return fmt.Errorf("event stream: HTTP %d", resp.StatusCode)
+	}
+	go func() {
+		defer resp.Body.Close()
+		readSSE(resp.Body, func(data string) {
+			var payload map[string]any
+			if json.Unmarshal([]byte(data), &payload) != nil {
+				return
+			}
+			select {
+			case out <- sseEvent{at: time.Now(), data: payload}:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return nil
+}
+
+func readSSE(body io.Reader, onData func(string)) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 1<<20), 1<<20)
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				onData(strings.Join(data, "\n"))
+				data = data[:0]
+			}
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+}
+
+func (h *harness) runThread(parent context.Context) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	started, err := h.rpc(ctx, SeriesThreadStart, "thread/start", map[string]any{"cwd": h.cwd})
+	if err != nil {
+		h.rec.fail("thread/start: %v", err)
+		return
+	}
+	threadObj, _ := started["thread"].(map[string]any)
+	threadID, _ := threadObj["id"].(string)
+	if threadID == "" {
+		h.rec.fail("thread/start returned no thread id")
+		return
+	}
+
+	// Every client gets its own channel; client 0 also answers approvals.
+	streams := make([]chan sseEvent, h.opts.SSEClients)
+	for i := range streams {
+		streams[i] = make(chan sseEvent, 1024)
+		if err := h.subscribe(ctx, threadID, streams[i]); err != nil {
+			h.rec.fail("subscribe %s: %v", threadID, err)
+			return
+		}
+	}
+
+	for turn := 0; turn < h.opts.TurnsPerThread; turn++ {
+		if ctx.Err() != nil {
+			return
+		}
+		if !h.runTurn(ctx, threadID, turn, streams) {
+			return
+		}
+	}
+}
+
+func (h *harness) runTurn(ctx context.Context, threadID string, turn int, streams []chan sseEvent) bool {
+	begin := time.Now()
+	input := []any{map[string]any{"type": "text", "text": fmt.Sprintf("bench turn %d", turn)}}
+	if _, err := h.rpc(ctx, SeriesTurnStart, "turn/start", map[string]any{"threadId": threadID, "input": input}); err != nil {
+		h.rec.fail("turn/start %s: %v", threadID, err)
+		return false
+	}
+
+	deadline := time.NewTimer(h.opts.TurnTimeout)
+	defer deadline.Stop()
+	var wg sync.WaitGroup
+	ok := true
+	var okMu sync.Mutex
+	for i, stream := range streams {
+		wg.Go(func() {
+			first := true
+			for {
+				select {
+				case event := <-stream:
+					h.rec.mu.Lock()
+					h.rec.events++
+					h.rec.mu.Unlock()
+					if event.at.Before(begin) {
+						continue
+					}
+					if first {
+						first = false
+						h.rec.add(SeriesFirstEvent, event.at.Sub(begin))
+					}
+					switch event.data["method"] {
+					case "darkhold/interaction/request":
+						if i == 0 {
+							h.respond(ctx, event.data)
+						}
+					case "turn/completed":
+						h.rec.add(SeriesTurnCompleted, event.at.Sub(begin))
+						return
+					}
+				case <-deadline.C:
+					h.rec.fail("turn %d on %s timed out", turn, threadID)
+					okMu.Lock()
+					ok = false
+					okMu.Unlock()
+					return
+				case <-ctx.Done():
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+	if ok {
+		h.rec.mu.Lock()
+		h.rec.turns++
+		h.rec.mu.Unlock()
+	}
+	return ok
+}
+
+func (h *harness) respond(ctx context.Context, payload map[string]any) {
+	params, _ := payload["params"].(map[string]any)
+	begin := time.Now()
+	_, err := h.post(ctx, "/api/thread/interaction/respond", map[string]any{
+		"threadId":  params["threadId"],
+		"requestId": params["requestId"],
+		"result":    map[string]any{"decision": "accept"},
+	})
+	if err != nil {
+		h.rec.fail("respond: %v", err)
+		return
+	}
+	h.rec.add(SeriesRespond, time.Since(begin))
+}
+
+// Format renders a report as a plain-text table.
+func Format(report Report) string {
+	var b strings.Builder
+	o := report.Options
+	fmt.Fprintf(&b, "threads=%d turns/thread=%d sse-clients/thread=%d fake-latency=%s approval-rate=%.2f\n",
+		o.Threads, o.TurnsPerThread, o.SSEClients, o.FakeLatency, o.ApprovalRate)
+	fmt.Fprintf(&b, "elapsed=%s turns completed=%d events received=%d errors=%d\n\n",
+		report.Elapsed.Round(time.Millisecond), report.TurnsCompleted, report.EventsReceived, len(report.Errors))
+	fmt.Fprintf(&b, "%-46s %7s %10s %10s %10s %10s\n", "series", "count", "p50", "p90", "p99", "max")
+	for _, s := range report.Series {
+		fmt.Fprintf(&b, "%-46s %7d %10s %10s %10s %10s\n", s.Name, s.Count,
+			s.P50.Round(time.Microsecond), s.P90.Round(time.Microsecond), s.P99.Round(time.Microsecond), s.Max.Round(time.Microsecond))
+	}
+	for i, msg := range report.Errors {
+		if i == 10 {
+			fmt.Fprintf(&b, "... %d more errors\n", len(report.Errors)-10)
+			break
+		}
+		fmt.Fprintf(&b, "error: %s\n", msg)
+	}
+	return b.String()
+}
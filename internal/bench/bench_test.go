@@ -0,0 +1,42 @@
+package bench
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	samples := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	if got := Percentile(samples, 50); got != 5 {
+		t.Fatalf("p50 = %d", got)
+	}
+	if got := Percentile(samples, 99); got != 10 {
+		t.Fatalf("p99 = %d", got)
+	}
+	if got := Percentile(nil, 50); got != 0 {
+		t.Fatalf("empty p50 = %d", got)
+	}
+}
+
+func TestRunSmallWorkload(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("loopback sockets are not available in this environment")
+	}
+	_ = listener.Close()
+
+	report, err := Run(context.Background(), Options{Threads: 2, TurnsPerThread: 2, SSEClients: 2, ApprovalRate: 1, TurnTimeout: 10 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Errors) != 0 || report.TurnsCompleted != 4 {
+		t.Fatalf("unexpected report:\n%s", Format(report))
+	}
+	for _, series := range report.Series {
+		if series.Count == 0 {
+			t.Fatalf("series %q has no samples:\n%s", series.Name, Format(report))
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+)
+
+// Main implements `darkhold bench`.
+func Main(args []string, stdout io.Writer) error {
+	opts := DefaultOptions()
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	flags.IntVar(&opts.Threads, "threads", opts.Threads, "concurrent threads")
+	flags.IntVar(&opts.TurnsPerThread, "turns", opts.TurnsPerThread, "sequential turns per thread")
+	flags.IntVar(&opts.SSEClients, "sse-clients", opts.SSEClients, "event stream subscribers per thread")
+	flags.DurationVar(&opts.FakeLatency, "fake-latency", opts.FakeLatency, "fake agent delay per streamed step")
+	flags.Float64Var(&opts.ApprovalRate, "approval-rate", opts.ApprovalRate, "probability (0-1) that a turn asks for approval")
+	flags.DurationVar(&opts.TurnTimeout, "turn-timeout", opts.TurnTimeout, "maximum time per turn")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report, err := Run(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	_, err = fmt.Fprint(stdout, Format(report))
+	return err
+}
@@ -1,79 +1,1269 @@
 package config
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+
+	"darkhold-go/internal/i18n"
+)
+
+const (
+	DefaultAgentCmd = "codex app-server"
+	// FakeAgentCmd selects the built-in fake agent in internal/fakeagent.
+	FakeAgentCmd = "internal:fake"
 )
 
 type Config struct {
 	Bind       string
 	Port       int
 	AllowCIDRs []string
-	BasePath   string
+	// RouteCIDRs replace AllowCIDRs for some requests. Keys are URL path
+	// prefixes ("/api/rpc", "/api/fs/"), where the longest match wins, or
+	// "scope:<name>" for the API routes that need that scope. Localhost and
+	// Tailscale stay allowed everywhere.
+	RouteCIDRs map[string][]string
+	// TLSCertFile and TLSKeyFile, when both set, serve HTTPS; HTTP/2 is
+	// negotiated over TLS. Plain HTTP stays HTTP/1.1.
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSClientCAFiles, with HTTPS, ask clients for a certificate signed by
+	// one of these CAs. TLSRequireClientCert refuses connections without
+	// one; otherwise clients may still use API keys or OIDC. A verified
+	// certificate whose subject alternative name (DNS name, email or URI)
+	// is a ClientCertScopes key, or matches a "*.domain" key, is signed in
+	// as cert:<name> with that key's scopes.
+	TLSClientCAFiles     []string
+	TLSRequireClientCert bool
+	ClientCertScopes     map[string][]string
+	// ReadHeaderTimeout bounds how long a client may take to send request
+	// headers. IdleTimeout closes keep-alive connections with no request in
+	// flight; open SSE streams are never idle. Zero disables either.
+	ReadHeaderTimeout time.Duration
+	IdleTimeout       time.Duration
+	// HTTP2MaxStreams caps concurrent streams (and handler goroutines) per
+	// HTTP/2 connection, so one browser tab with many event streams cannot
+	// pin an unbounded number of goroutines.
+	HTTP2MaxStreams int
+	// GRPCPort, when non-zero, also serves the gRPC API on Bind at this
+	// port, with the same TLS certificate as HTTPS.
+	GRPCPort int
+	BasePath string
+	// DataDir holds darkhold's persistent state (event logs, thread
+	// metadata). When empty, a temporary directory is used per process.
+	DataDir string
+	// EventStore selects the event log backend: "jsonl" (files under
+	// <DataDir>/events, the default), "memory", or "postgres" (shared by
+	// several replicas, which also share the thread index through it).
+	EventStore string
+	// PostgresURL is the connection string for the postgres event store;
+	// DATABASE_URL is used when it is not set.
+	PostgresURL string
+	// EventChain has the jsonl and memory stores hash-chain appended
+	// records, so a log can later be shown to be unmodified.
+	EventChain bool
+	// EventStoreLayout arranges the jsonl store's files: "flat" (the
+	// default) or "sharded" into prefix subdirectories. A store in the
+	// other layout is moved over at startup.
+	EventStoreLayout string
+
+	// PolicyURL, when set, receives every upstream interaction request before
+	// it is shown to humans. See internal/server/policy.go for the contract.
+	PolicyURL     string
+	PolicyTimeout time.Duration
+	// DenyCommands are matched against the command text of exec approvals;
+	// a match is declined before policy or humans see the request.
+	DenyCommands []*regexp.Regexp
+	// RejectOutsideRoot declines exec approvals whose cwd or referenced
+	// paths fall outside the browser root instead of only flagging them.
+	RejectOutsideRoot bool
+
+	// TerminalEnabled serves /api/terminal/ws, an interactive shell in a
+	// directory under the browser root. TerminalShell overrides $SHELL.
+	TerminalEnabled bool
+	TerminalShell   string
+
+	// ExecAllow are anchored patterns a /api/exec command line must match
+	// in full; /api/exec is disabled while it is empty. ExecTimeout caps how
+	// long one command may run.
+	ExecAllow   []*regexp.Regexp
+	ExecTimeout time.Duration
+
+	// AgentCmd is the app-server command line, split on whitespace, or
+	// FakeAgentCmd. The Fake* knobs only apply to the fake agent.
+	AgentCmd         string
+	FakeLatency      time.Duration
+	FakeCrashRate    float64
+	FakeApprovalRate float64
+
+	// SessionPingInterval is how often each agent session is sent a health
+	// ping; one that misses two pings in a row, each SessionPingTimeout
+	// long, is killed and replaced. Zero disables pings.
+	SessionPingInterval time.Duration
+	SessionPingTimeout  time.Duration
+	// SessionKeepaliveInterval sends SessionKeepaliveMethod to sessions the
+	// idle reaper is keeping (a turn is running or an RPC is outstanding)
+	// once they have been quiet that long, for agent builds that exit
+	// without traffic. Zero disables keepalives.
+	SessionKeepaliveInterval time.Duration
+	SessionKeepaliveMethod   string
+	// MaxSessionRPCs caps the RPCs outstanding on one agent session; calls
+	// beyond it are refused at once instead of queueing behind a slow or
+	// hung agent. Zero disables the cap.
+	MaxSessionRPCs int
+	// AgentSampleInterval is how often the CPU time and resident memory of
+	// each agent's process tree is sampled (Linux and Windows). An agent
+	// above MaxAgentRSSBytes, or using more than MaxAgentCPUPercent of a
+	// core for MaxAgentCPUFor, is killed and failed over. Zeros disable
+	// sampling and the limits.
+	AgentSampleInterval time.Duration
+	MaxAgentRSSBytes    int64
+	MaxAgentCPUPercent  float64
+	MaxAgentCPUFor      time.Duration
+
+	// MinFreeDiskBytes and MinFreeInodes are the free space and inodes the
+	// filesystems holding the browser root and the event store must keep;
+	// turn/start is refused below either. Zero disables a check.
+	MinFreeDiskBytes int64
+	MinFreeInodes    int
+
+	// AlertTurnDuration raises a darkhold/alert event (and logs it) when a
+	// turn runs longer than this; AlertApprovalPending does the same for an
+	// approval request left unanswered that long. Zero disables an alert.
+	AlertTurnDuration    time.Duration
+	AlertApprovalPending time.Duration
+
+	// MaxTurnDuration interrupts a turn that has run this long, and
+	// MaxTurnCPU one whose agent has used this much CPU time since it
+	// started (sampled every AgentSampleInterval). Zero disables a limit.
+	MaxTurnDuration time.Duration
+	MaxTurnCPU      time.Duration
+
+	// RecordSessions writes every JSON line exchanged with each agent
+	// process, secrets redacted, to <data-dir>/transcripts for debugging
+	// protocol mismatches. A transcript stops growing at
+	// RecordSessionMaxBytes.
+	RecordSessions        bool
+	RecordSessionMaxBytes int64
+
+	// AgentClientName, AgentClientTitle and AgentClientVersion override the
+	// clientInfo sent in the agent's initialize call, and AgentCapabilities
+	// the capabilities (experimentalApi is on unless set false here).
+	// AgentInitParams are further initialize params, merged over the rest.
+	AgentClientName    string
+	AgentClientTitle   string
+	AgentClientVersion string
+	AgentCapabilities  map[string]any
+	AgentInitParams    map[string]any
+
+	// AutoRespond maps agent→client request methods to the JSON result
+	// darkhold answers them with instead of publishing them for a human.
+	AutoRespond map[string]any
+
+	// MaxEventStoreBytes caps the total size of thread event logs. When it is
+	// exceeded the least recently written logs (archived threads first) are
+	// evicted. Zero disables the cap.
+	MaxEventStoreBytes int64
+
+	// AutoArchiveAfter, when non-zero, archives threads with no new events
+	// for that long and compacts their logs. AutoArchiveAgent also sends
+	// thread/archive to the agent for each of them.
+	AutoArchiveAfter time.Duration
+	AutoArchiveAgent bool
+
+	// TurnSnapshots, when not "off", snapshots a thread's cwd before every
+	// turn/start so the turn can be rolled back: "git" keeps the snapshot as
+	// a git tree, "copy" copies the directory into the data dir, and "auto"
+	// uses git inside a work tree and a copy elsewhere. Copies larger than
+	// TurnSnapshotMaxBytes are skipped; TurnSnapshotKeep snapshots are kept
+	// per thread.
+	TurnSnapshots        string
+	TurnSnapshotMaxBytes int64
+	TurnSnapshotKeep     int
+
+	// SystemPreamble is injected at the top of every turn/start input, ahead
+	// of any workspace preamble. Set with --system-preamble as text or
+	// @path.
+	SystemPreamble string
+
+	// TurnHooks rewrite or annotate every turn/start before it is sent, in
+	// order: each is the name of a compiled-in hook (TurnHookNames) or
+	// "cmd:" and the command line of an external one. TurnHookTimeout
+	// bounds each run. TurnSnippetsDir holds the <name>.md files the
+	// snippets hook expands.
+	TurnHooks       []string
+	TurnHookTimeout time.Duration
+	TurnSnippetsDir string
+	// PostTurnHooks run, in order, after every turn that completes: each
+	// is "cmd:" and a command line run in the thread's cwd, or an http(s)
+	// URL the turn summary is POSTed to. PostTurnHookTimeout bounds each
+	// run.
+	PostTurnHooks       []string
+	PostTurnHookTimeout time.Duration
+
+	// MaxTurnInputChars caps the total text length of one turn/start input.
+	// Zero disables the cap.
+	MaxTurnInputChars int
+	// TurnWarnTokens and TurnMaxTokens act on a turn's estimated prompt
+	// size (its input plus pinned notes and preamble): over the first the
+	// turn/start result carries a warning, over the second it is refused.
+	// Zero disables either.
+	TurnWarnTokens int
+	TurnMaxTokens  int
+
+	// MaxSSEPerIP and MaxSSETotal cap open thread event streams per client
+	// IP and server-wide. Zero disables a cap.
+	MaxSSEPerIP int
+	MaxSSETotal int
+
+	// RPCCacheTTL is how long thread/read and thread/list results are reused.
+	// Zero disables the cache.
+	RPCCacheTTL time.Duration
+
+	// Supervised means a service manager restarts darkhold when it exits,
+	// which /api/admin/restart relies on. It defaults to on under systemd.
+	Supervised bool
+
+	// ImportCodexHistory imports threads the agent already knows about (for
+	// example sessions started from the Codex CLI) into the thread index and
+	// event store on first startup with a data dir.
+	ImportCodexHistory bool
+
+	// APIKeys, when non-empty, are required as bearer tokens on every API
+	// route except /api/health. Usage and budgets are tracked per key name.
+	APIKeys []APIKey
+	// GlobalBudget caps daily usage across all clients; KeyBudgets caps it
+	// per key name. Zero limits are unlimited.
+	GlobalBudget Budget
+	KeyBudgets   map[string]Budget
+
+	// OIDCIssuer, when set, adds single sign-on through the issuer's
+	// authorization code flow next to any API keys. Signed-in users are
+	// identified as oidc:<email>, or their subject without an email, and get
+	// OIDCDefaultScopes plus the scopes OIDCGroupScopes maps their
+	// OIDCGroupsClaim values to. OIDCRedirectURL defaults to the callback
+	// under PublicURL, or under the request's own host.
+	OIDCIssuer        string
+	OIDCClientID      string
+	OIDCClientSecret  string
+	OIDCRedirectURL   string
+	OIDCRequestScopes []string
+	OIDCGroupsClaim   string
+	OIDCGroupScopes   map[string][]string
+	OIDCDefaultScopes []string
+	OIDCSessionTTL    time.Duration
+
+	// ContentSecurityPolicy replaces the web UI's default policy; "off" sends
+	// none. FrameAncestors lists who may frame the UI (the frame-ancestors
+	// sources of the default policy, mirrored in X-Frame-Options where it
+	// can be). EmbedFrameAncestors does the same for the /embed/ status
+	// widgets, which are meant to be framed by dashboards.
+	ContentSecurityPolicy string
+	FrameAncestors        string
+	EmbedFrameAncestors   string
+
+	// Locale is the language of API error messages and webhook notification
+	// text when a request's Accept-Language (or a webhook's locale) names
+	// none darkhold has a catalog for.
+	Locale string
+
+	// PublicURL is the externally reachable base URL of this server, used to
+	// build absolute links sent to other systems (quick interaction links in
+	// webhooks). QuickLinkTTL is how long those links stay valid.
+	// ShareLinkTTL is the default and longest lifetime of a thread share
+	// link.
+	PublicURL    string
+	QuickLinkTTL time.Duration
+	ShareLinkTTL time.Duration
+
+	// S3Bucket, when set, enables archiving thread logs and transcripts to
+	// S3-compatible storage under S3Prefix. Credentials fall back to
+	// AWS_ACCESS_KEY_ID / AWS_SECRET_ACCESS_KEY. ArchiveInterval, when
+	// non-zero, uploads every changed thread on that schedule; archived
+	// threads are always uploaded when archived.
+	S3Endpoint      string
+	S3Region        string
+	S3Bucket        string
+	S3AccessKey     string
+	S3SecretKey     string
+	S3Prefix        string
+	ArchiveInterval time.Duration
+
+	// MQTTURL, when set, mirrors every thread event to the broker as a QoS 0
+	// message on <MQTTTopicPrefix>/<threadId>/<method>.
+	MQTTURL         string
+	MQTTUsername    string
+	MQTTPassword    string
+	MQTTTopicPrefix string
+
+	// RedisURL, when set, shares SSE events between darkhold instances over
+	// Redis pub/sub on RedisChannel.
+	RedisURL     string
+	RedisChannel string
+}
+
+type APIKey struct {
+	Name  string
+	Token string
+}
+
+// AccessScopes are what OIDC users and client certificates can be granted:
+// read for GET requests, write for everything else, approve for answering
+// interaction requests and admin for /api/admin. API key holders have all of
+// them.
+var AccessScopes = []string{"read", "write", "approve", "admin"}
+
+// TurnHookNames are the compiled-in hooks --turn-hook accepts besides
+// "cmd:<command line>".
+var TurnHookNames = []string{"snippets", "git-context"}
+
+// Budget is a daily allowance, reset at UTC midnight.
+type Budget struct {
+	TurnsPerDay  int64
+	TokensPerDay int64
+}
+
+func (b Budget) IsZero() bool {
+	return b.TurnsPerDay == 0 && b.TokensPerDay == 0
+}
+
+// splitFlag reads the flag at args[i], accepting both "--name value" and
+// "--name=value". It returns the number of extra args consumed.
+func splitFlag(args []string, i int) (name, value string, consumed int, ok bool) {
+	arg := args[i]
+	if !strings.HasPrefix(arg, "--") {
+		return "", "", 0, false
+	}
+	if before, after, ok := strings.Cut(arg, "="); ok {
+		return before, after, 0, true
+	}
+	if i+1 < len(args) && args[i+1] != "" && !strings.HasPrefix(args[i+1], "--") {
+		return arg, args[i+1], 1, true
+	}
+	return "", "", 0, false
 }
 
 func Parse(args []string) (Config, error) {
 	cfg := Config{
-		Bind:       "127.0.0.1",
-		Port:       3275,
-		AllowCIDRs: []string{},
+		Bind:              "127.0.0.1",
+		Port:              3275,
+		AllowCIDRs:        []string{},
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		HTTP2MaxStreams:   250,
+		PolicyTimeout:     10 * time.Second,
+		ExecTimeout:       2 * time.Minute,
+		TurnHookTimeout:   10 * time.Second,
+		RPCCacheTTL:       2 * time.Second,
+		MaxTurnInputChars: 100000,
+		TurnSnapshots:     "off",
+		QuickLinkTTL:      15 * time.Minute,
+		ShareLinkTTL:      24 * time.Hour,
+		AgentCmd:          DefaultAgentCmd,
+		EventStore:        "jsonl",
+		EventStoreLayout:  "flat",
+		KeyBudgets:        map[string]Budget{},
+
+		MQTTTopicPrefix: "darkhold",
+		RedisChannel:    "darkhold:events",
+		S3Region:        "us-east-1",
+		S3Prefix:        "darkhold/",
+
+		TurnSnapshotMaxBytes: 256 << 20,
+		TurnSnapshotKeep:     20,
+
+		PostTurnHookTimeout: 10 * time.Minute,
+
+		SessionKeepaliveMethod: "darkhold/keepalive",
+
+		SessionPingInterval: 30 * time.Second,
+		SessionPingTimeout:  10 * time.Second,
+		MaxSessionRPCs:      64,
+		AgentSampleInterval: 15 * time.Second,
+		MaxAgentCPUFor:      5 * time.Minute,
+
+		MinFreeDiskBytes: 512 << 20,
+		MinFreeInodes:    10000,
+
+		RecordSessionMaxBytes: 64 << 20,
+
+		OIDCRequestScopes: []string{"openid", "email", "profile"},
+		OIDCGroupsClaim:   "groups",
+		OIDCDefaultScopes: []string{"read", "write", "approve"},
+		OIDCSessionTTL:    12 * time.Hour,
+
+		FrameAncestors:      "'none'",
+		EmbedFrameAncestors: "*",
+		Locale:              i18n.DefaultLocale,
+
+		Supervised: os.Getenv("INVOCATION_ID") != "",
 	}
 
 	for i := 0; i < len(args); i++ {
-		arg := args[i]
-		next := ""
-		if i+1 < len(args) {
-			next = args[i+1]
-		}
-
-		switch {
-		case arg == "--bind" && next != "":
-			cfg.Bind = next
-			i++
-		case strings.HasPrefix(arg, "--bind="):
-			cfg.Bind = strings.TrimPrefix(arg, "--bind=")
-		case arg == "--port" && next != "":
-			v, err := strconv.Atoi(next)
+		// Boolean flags may be given bare; "--name=false" still parses below.
+		if args[i] == "--import-codex-history" {
+			cfg.ImportCodexHistory = true
+			continue
+		}
+		if args[i] == "--reject-outside-root" {
+			cfg.RejectOutsideRoot = true
+			continue
+		}
+		if args[i] == "--auto-archive-agent" {
+			cfg.AutoArchiveAgent = true
+			continue
+		}
+		if args[i] == "--event-chain" {
+			cfg.EventChain = true
+			continue
+		}
+		if args[i] == "--record-sessions" {
+			cfg.RecordSessions = true
+			continue
+		}
+		if args[i] == "--enable-terminal" {
+			cfg.TerminalEnabled = true
+			continue
+		}
+		if args[i] == "--supervised" {
+			cfg.Supervised = true
+			continue
+		}
+		if args[i] == "--tls-require-client-cert" {
+			cfg.TLSRequireClientCert = true
+			continue
+		}
+		name, value, consumed, ok := splitFlag(args, i)
+		if !ok {
+			continue
+		}
+		i += consumed
+
+		var err error
+		switch name {
+		case "--bind":
+			cfg.Bind = value
+		case "--port":
+			cfg.Port, err = strconv.Atoi(value)
 			if err != nil {
 				return Config{}, errors.New("port must be an integer")
 			}
-			cfg.Port = v
-			i++
-		case strings.HasPrefix(arg, "--port="):
-			v, err := strconv.Atoi(strings.TrimPrefix(arg, "--port="))
+		case "--allow-cidr":
+			cfg.AllowCIDRs = append(cfg.AllowCIDRs, value)
+		case "--route-cidr":
+			route, cidrs, found := strings.Cut(value, "=")
+			if !found || strings.TrimSpace(route) == "" {
+				return Config{}, errors.New("route-cidr must look like /path=cidr,cidr or scope:name=cidr,cidr")
+			}
+			if cfg.RouteCIDRs == nil {
+				cfg.RouteCIDRs = map[string][]string{}
+			}
+			route = strings.TrimSpace(route)
+			cfg.RouteCIDRs[route] = append(cfg.RouteCIDRs[route], strings.FieldsFunc(cidrs, func(r rune) bool { return r == ',' || r == ' ' })...)
+		case "--tls-cert":
+			cfg.TLSCertFile = value
+		case "--tls-key":
+			cfg.TLSKeyFile = value
+		case "--tls-client-ca":
+			cfg.TLSClientCAFiles = append(cfg.TLSClientCAFiles, value)
+		case "--tls-require-client-cert":
+			cfg.TLSRequireClientCert, err = strconv.ParseBool(value)
 			if err != nil {
-				return Config{}, errors.New("port must be an integer")
+				err = fmt.Errorf("invalid --tls-require-client-cert: %s", value)
+			}
+		case "--client-cert":
+			san, scopes, found := strings.Cut(value, "=")
+			if !found || strings.TrimSpace(san) == "" {
+				return Config{}, errors.New("client-cert must look like name=scope,scope")
+			}
+			if cfg.ClientCertScopes == nil {
+				cfg.ClientCertScopes = map[string][]string{}
+			}
+			cfg.ClientCertScopes[strings.TrimSpace(san)] = splitScopes(scopes)
+		case "--read-header-timeout":
+			cfg.ReadHeaderTimeout, err = parseDuration(name, value)
+		case "--idle-timeout":
+			cfg.IdleTimeout, err = parseDuration(name, value)
+		case "--http2-max-streams":
+			cfg.HTTP2MaxStreams, err = strconv.Atoi(value)
+			if err != nil || cfg.HTTP2MaxStreams < 1 {
+				err = errors.New("http2-max-streams must be a positive integer")
+			}
+		case "--grpc-port":
+			cfg.GRPCPort, err = strconv.Atoi(value)
+			if err != nil {
+				err = errors.New("grpc-port must be an integer")
+			}
+		case "--base-path":
+			cfg.BasePath = value
+		case "--data-dir":
+			cfg.DataDir = value
+		case "--event-store":
+			cfg.EventStore = strings.ToLower(strings.TrimSpace(value))
+		case "--postgres-url":
+			cfg.PostgresURL = value
+		case "--event-store-layout":
+			cfg.EventStoreLayout = strings.ToLower(strings.TrimSpace(value))
+		case "--event-chain":
+			cfg.EventChain, err = strconv.ParseBool(value)
+			if err != nil {
+				err = fmt.Errorf("invalid --event-chain: %s", value)
+			}
+		case "--policy-url":
+			cfg.PolicyURL = value
+		case "--policy-timeout":
+			cfg.PolicyTimeout, err = parseDuration(name, value)
+		case "--deny-command":
+			var pattern *regexp.Regexp
+			pattern, err = parseDenyCommand(value)
+			cfg.DenyCommands = append(cfg.DenyCommands, pattern)
+		case "--deny-command-file":
+			var patterns []*regexp.Regexp
+			patterns, err = readDenyCommandFile(value)
+			cfg.DenyCommands = append(cfg.DenyCommands, patterns...)
+		case "--reject-outside-root":
+			cfg.RejectOutsideRoot, err = strconv.ParseBool(value)
+			if err != nil {
+				err = fmt.Errorf("invalid --reject-outside-root: %s", value)
+			}
+		case "--enable-terminal":
+			cfg.TerminalEnabled, err = strconv.ParseBool(value)
+			if err != nil {
+				err = fmt.Errorf("invalid --enable-terminal: %s", value)
+			}
+		case "--supervised":
+			cfg.Supervised, err = strconv.ParseBool(value)
+			if err != nil {
+				err = fmt.Errorf("invalid --supervised: %s", value)
+			}
+		case "--terminal-shell":
+			cfg.TerminalShell = value
+		case "--exec-allow":
+			var pattern *regexp.Regexp
+			pattern, err = parseExecAllow(value)
+			cfg.ExecAllow = append(cfg.ExecAllow, pattern)
+		case "--exec-timeout":
+			cfg.ExecTimeout, err = parseDuration(name, value)
+			if err == nil && cfg.ExecTimeout == 0 {
+				err = errors.New("exec-timeout must be positive")
+			}
+		case "--agent-cmd":
+			cfg.AgentCmd = value
+		case "--session-ping-interval":
+			cfg.SessionPingInterval, err = parseDuration(name, value)
+		case "--session-ping-timeout":
+			cfg.SessionPingTimeout, err = parseDuration(name, value)
+		case "--session-keepalive-interval":
+			cfg.SessionKeepaliveInterval, err = parseDuration(name, value)
+		case "--session-keepalive-method":
+			cfg.SessionKeepaliveMethod = strings.TrimSpace(value)
+			if cfg.SessionKeepaliveMethod == "" {
+				err = errors.New("session-keepalive-method must not be empty")
+			}
+		case "--max-session-rpcs":
+			cfg.MaxSessionRPCs, err = parseLimit(name, value)
+		case "--agent-sample-interval":
+			cfg.AgentSampleInterval, err = parseDuration(name, value)
+		case "--max-agent-rss":
+			cfg.MaxAgentRSSBytes, err = parseSize(name, value)
+		case "--max-agent-cpu":
+			cfg.MaxAgentCPUPercent, err = strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+			if err != nil || cfg.MaxAgentCPUPercent < 0 {
+				err = errors.New("max-agent-cpu must be a non-negative percentage of one core (for example 200)")
+			}
+		case "--max-agent-cpu-for":
+			cfg.MaxAgentCPUFor, err = parseDuration(name, value)
+		case "--min-free-disk":
+			cfg.MinFreeDiskBytes, err = parseSize(name, value)
+		case "--min-free-inodes":
+			cfg.MinFreeInodes, err = parseLimit(name, value)
+		case "--alert-turn-duration":
+			cfg.AlertTurnDuration, err = parseDuration(name, value)
+		case "--alert-approval-pending":
+			cfg.AlertApprovalPending, err = parseDuration(name, value)
+		case "--max-turn-duration":
+			cfg.MaxTurnDuration, err = parseDuration(name, value)
+		case "--max-turn-cpu":
+			cfg.MaxTurnCPU, err = parseDuration(name, value)
+		case "--record-sessions":
+			cfg.RecordSessions, err = strconv.ParseBool(value)
+			if err != nil {
+				err = fmt.Errorf("invalid --record-sessions: %s", value)
+			}
+		case "--record-session-max-size":
+			cfg.RecordSessionMaxBytes, err = parseSize(name, value)
+		case "--agent-client-name":
+			cfg.AgentClientName = value
+		case "--agent-client-title":
+			cfg.AgentClientTitle = value
+		case "--agent-client-version":
+			cfg.AgentClientVersion = value
+		case "--agent-capability":
+			var capName string
+			var capValue any
+			capName, capValue, err = parseAgentCapability(value)
+			if err == nil {
+				if cfg.AgentCapabilities == nil {
+					cfg.AgentCapabilities = map[string]any{}
+				}
+				cfg.AgentCapabilities[capName] = capValue
+			}
+		case "--agent-init-params":
+			cfg.AgentInitParams, err = parseAgentInitParams(value)
+		case "--auto-respond":
+			var method string
+			var result any
+			method, result, err = parseAutoRespond(value)
+			if err == nil {
+				if cfg.AutoRespond == nil {
+					cfg.AutoRespond = map[string]any{}
+				}
+				cfg.AutoRespond[method] = result
+			}
+		case "--fake-latency":
+			cfg.FakeLatency, err = parseDuration(name, value)
+		case "--fake-crash-rate":
+			cfg.FakeCrashRate, err = parseRate(name, value)
+		case "--fake-approval-rate":
+			cfg.FakeApprovalRate, err = parseRate(name, value)
+		case "--max-event-store-size":
+			cfg.MaxEventStoreBytes, err = parseSize(name, value)
+		case "--auto-archive-days":
+			var days int
+			days, err = parseLimit(name, value)
+			cfg.AutoArchiveAfter = time.Duration(days) * 24 * time.Hour
+		case "--auto-archive-agent":
+			cfg.AutoArchiveAgent, err = strconv.ParseBool(value)
+			if err != nil {
+				err = fmt.Errorf("invalid --auto-archive-agent: %s", value)
+			}
+		case "--import-codex-history":
+			cfg.ImportCodexHistory, err = strconv.ParseBool(value)
+			if err != nil {
+				err = fmt.Errorf("invalid --import-codex-history: %s", value)
+			}
+		case "--turn-snapshots":
+			cfg.TurnSnapshots = strings.ToLower(strings.TrimSpace(value))
+		case "--turn-snapshot-max-size":
+			cfg.TurnSnapshotMaxBytes, err = parseSize(name, value)
+		case "--turn-snapshot-keep":
+			cfg.TurnSnapshotKeep, err = parseLimit(name, value)
+		case "--system-preamble":
+			cfg.SystemPreamble, err = parseSystemPreamble(value)
+		case "--turn-hook":
+			var hook string
+			hook, err = parseTurnHook(value)
+			cfg.TurnHooks = append(cfg.TurnHooks, hook)
+		case "--turn-hook-timeout":
+			cfg.TurnHookTimeout, err = parseDuration(name, value)
+			if err == nil && cfg.TurnHookTimeout == 0 {
+				err = errors.New("turn-hook-timeout must be positive")
+			}
+		case "--turn-snippets-dir":
+			cfg.TurnSnippetsDir = value
+		case "--post-turn-hook":
+			var hook string
+			hook, err = parsePostTurnHook(value)
+			cfg.PostTurnHooks = append(cfg.PostTurnHooks, hook)
+		case "--post-turn-hook-timeout":
+			cfg.PostTurnHookTimeout, err = parseDuration(name, value)
+			if err == nil && cfg.PostTurnHookTimeout == 0 {
+				err = errors.New("post-turn-hook-timeout must be positive")
+			}
+		case "--max-turn-input-chars":
+			cfg.MaxTurnInputChars, err = parseLimit(name, value)
+		case "--turn-warn-tokens":
+			cfg.TurnWarnTokens, err = parseLimit(name, value)
+		case "--turn-max-tokens":
+			cfg.TurnMaxTokens, err = parseLimit(name, value)
+		case "--max-sse-per-ip":
+			cfg.MaxSSEPerIP, err = parseLimit(name, value)
+		case "--max-sse-total":
+			cfg.MaxSSETotal, err = parseLimit(name, value)
+		case "--rpc-cache-ttl":
+			cfg.RPCCacheTTL, err = parseDuration(name, value)
+		case "--api-key":
+			var key APIKey
+			key, err = parseAPIKey(value)
+			cfg.APIKeys = append(cfg.APIKeys, key)
+		case "--api-key-file":
+			var keys []APIKey
+			keys, err = readAPIKeyFile(value)
+			cfg.APIKeys = append(cfg.APIKeys, keys...)
+		case "--budget":
+			cfg.GlobalBudget, err = parseBudget(value)
+		case "--key-budget":
+			keyName, spec, found := strings.Cut(value, ":")
+			if !found || strings.TrimSpace(keyName) == "" {
+				return Config{}, fmt.Errorf("key-budget must look like name:turns=N,tokens=N")
+			}
+			cfg.KeyBudgets[strings.TrimSpace(keyName)], err = parseBudget(spec)
+		case "--oidc-issuer":
+			cfg.OIDCIssuer = strings.TrimSpace(value)
+		case "--oidc-client-id":
+			cfg.OIDCClientID = strings.TrimSpace(value)
+		case "--oidc-client-secret":
+			cfg.OIDCClientSecret = value
+		case "--oidc-redirect-url":
+			cfg.OIDCRedirectURL = strings.TrimSpace(value)
+		case "--oidc-request-scopes":
+			cfg.OIDCRequestScopes = splitScopes(value)
+		case "--oidc-groups-claim":
+			cfg.OIDCGroupsClaim = strings.TrimSpace(value)
+		case "--oidc-group":
+			group, scopes, found := strings.Cut(value, "=")
+			if !found || strings.TrimSpace(group) == "" {
+				return Config{}, errors.New("oidc-group must look like group=scope,scope")
 			}
-			cfg.Port = v
-		case arg == "--allow-cidr" && next != "":
-			cfg.AllowCIDRs = append(cfg.AllowCIDRs, next)
-			i++
-		case strings.HasPrefix(arg, "--allow-cidr="):
-			cfg.AllowCIDRs = append(cfg.AllowCIDRs, strings.TrimPrefix(arg, "--allow-cidr="))
-		case arg == "--base-path" && next != "":
-			cfg.BasePath = next
-			i++
-		case strings.HasPrefix(arg, "--base-path="):
-			cfg.BasePath = strings.TrimPrefix(arg, "--base-path=")
+			if cfg.OIDCGroupScopes == nil {
+				cfg.OIDCGroupScopes = map[string][]string{}
+			}
+			cfg.OIDCGroupScopes[strings.TrimSpace(group)] = splitScopes(scopes)
+		case "--oidc-default-scopes":
+			cfg.OIDCDefaultScopes = splitScopes(value)
+		case "--oidc-session-ttl":
+			cfg.OIDCSessionTTL, err = parseDuration(name, value)
+		case "--csp":
+			cfg.ContentSecurityPolicy = strings.TrimSpace(value)
+		case "--frame-ancestors":
+			cfg.FrameAncestors = strings.Join(strings.Fields(value), " ")
+		case "--embed-frame-ancestors":
+			cfg.EmbedFrameAncestors = strings.Join(strings.Fields(value), " ")
+		case "--locale":
+			locale, ok := i18n.Supported(value)
+			if !ok {
+				err = fmt.Errorf("invalid --locale %q: use one of %s", value, strings.Join(i18n.Locales(), ", "))
+			}
+			cfg.Locale = locale
+		case "--public-url":
+			cfg.PublicURL = strings.TrimRight(value, "/")
+		case "--quick-link-ttl":
+			cfg.QuickLinkTTL, err = parseDuration(name, value)
+		case "--share-link-ttl":
+			cfg.ShareLinkTTL, err = parseDuration(name, value)
+		case "--s3-endpoint":
+			cfg.S3Endpoint = value
+		case "--s3-region":
+			cfg.S3Region = value
+		case "--s3-bucket":
+			cfg.S3Bucket = value
+		case "--s3-access-key":
+			cfg.S3AccessKey = value
+		case "--s3-secret-key":
+			cfg.S3SecretKey = value
+		case "--s3-prefix":
+			cfg.S3Prefix = value
+		case "--archive-interval":
+			cfg.ArchiveInterval, err = parseDuration(name, value)
+		case "--mqtt-url":
+			cfg.MQTTURL = value
+		case "--mqtt-username":
+			cfg.MQTTUsername = value
+		case "--mqtt-password":
+			cfg.MQTTPassword = value
+		case "--mqtt-topic-prefix":
+			cfg.MQTTTopicPrefix = strings.Trim(value, "/")
+		case "--redis-url":
+			cfg.RedisURL = value
+		case "--redis-channel":
+			cfg.RedisChannel = strings.TrimSpace(value)
+		}
+		if err != nil {
+			return Config{}, err
 		}
 	}
 
 	if cfg.Port < 1 || cfg.Port > 65535 {
 		return Config{}, errors.New("port must be between 1 and 65535")
 	}
+	if cfg.GRPCPort < 0 || cfg.GRPCPort > 65535 {
+		return Config{}, errors.New("grpc-port must be between 0 (off) and 65535")
+	}
+	if cfg.GRPCPort == cfg.Port {
+		return Config{}, errors.New("grpc-port must differ from port")
+	}
 
 	for _, cidr := range cfg.AllowCIDRs {
 		if _, _, err := net.ParseCIDR(cidr); err != nil {
 			return Config{}, fmt.Errorf("invalid CIDR: %s", cidr)
 		}
 	}
+	for route, cidrs := range cfg.RouteCIDRs {
+		if scope, ok := strings.CutPrefix(route, "scope:"); ok {
+			if !slices.Contains(AccessScopes, scope) {
+				return Config{}, fmt.Errorf("unknown scope %q for route-cidr: use %s", scope, strings.Join(AccessScopes, ", "))
+			}
+		} else if !strings.HasPrefix(route, "/") {
+			return Config{}, fmt.Errorf("route-cidr route must be a path starting with / or scope:name: %s", route)
+		}
+		if len(cidrs) == 0 {
+			return Config{}, fmt.Errorf("route-cidr %s needs at least one CIDR", route)
+		}
+		for _, cidr := range cidrs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return Config{}, fmt.Errorf("invalid CIDR for route-cidr %s: %s", route, cidr)
+			}
+		}
+	}
+
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return Config{}, errors.New("tls-cert and tls-key must be set together")
+	}
+	if len(cfg.TLSClientCAFiles) > 0 && cfg.TLSCertFile == "" {
+		return Config{}, errors.New("tls-client-ca requires --tls-cert and --tls-key")
+	}
+	if (cfg.TLSRequireClientCert || len(cfg.ClientCertScopes) > 0) && len(cfg.TLSClientCAFiles) == 0 {
+		return Config{}, errors.New("tls-require-client-cert and client-cert require --tls-client-ca")
+	}
+	for san, scopes := range cfg.ClientCertScopes {
+		for _, scope := range scopes {
+			if !slices.Contains(AccessScopes, scope) {
+				return Config{}, fmt.Errorf("unknown scope %q for client-cert %s: use %s", scope, san, strings.Join(AccessScopes, ", "))
+			}
+		}
+	}
+
+	if slices.Contains(cfg.TurnHooks, "snippets") && cfg.TurnSnippetsDir == "" {
+		return Config{}, errors.New("turn-hook snippets requires --turn-snippets-dir")
+	}
+
+	if cfg.PolicyURL != "" {
+		u, err := url.Parse(cfg.PolicyURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return Config{}, fmt.Errorf("invalid policy URL: %s", cfg.PolicyURL)
+		}
+	}
+
+	switch cfg.EventStore {
+	case "jsonl", "memory":
+		if cfg.PostgresURL != "" {
+			return Config{}, errors.New("postgres-url requires --event-store postgres")
+		}
+	case "postgres":
+		if cfg.PostgresURL == "" {
+			cfg.PostgresURL = os.Getenv("DATABASE_URL")
+		}
+		if cfg.PostgresURL == "" {
+			return Config{}, errors.New("event-store postgres requires --postgres-url (or DATABASE_URL)")
+		}
+		if cfg.EventChain {
+			return Config{}, errors.New("event-chain requires --event-store jsonl or memory")
+		}
+	default:
+		return Config{}, fmt.Errorf("invalid event store %q: use jsonl, memory or postgres", cfg.EventStore)
+	}
+	switch cfg.EventStoreLayout {
+	case "flat":
+	case "sharded":
+		if cfg.EventStore != "jsonl" {
+			return Config{}, errors.New("event-store-layout sharded requires --event-store jsonl")
+		}
+	default:
+		return Config{}, fmt.Errorf("invalid event store layout %q: use flat or sharded", cfg.EventStoreLayout)
+	}
+
+	if strings.ContainsAny(cfg.FrameAncestors, ";,") || cfg.FrameAncestors == "" {
+		return Config{}, errors.New("frame-ancestors must be a space-separated list of sources such as 'self' or https://example.com")
+	}
+	if strings.ContainsAny(cfg.EmbedFrameAncestors, ";,") || cfg.EmbedFrameAncestors == "" {
+		return Config{}, errors.New("embed-frame-ancestors must be a space-separated list of sources such as * or https://grafana.example.com")
+	}
+	if strings.ContainsAny(cfg.ContentSecurityPolicy, "\r\n") {
+		return Config{}, errors.New("csp must be a single line")
+	}
+
+	if cfg.PublicURL != "" {
+		u, err := url.Parse(cfg.PublicURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return Config{}, fmt.Errorf("invalid public URL: %s", cfg.PublicURL)
+		}
+	}
+	switch cfg.TurnSnapshots {
+	case "off", "auto", "git", "copy":
+	default:
+		return Config{}, fmt.Errorf("invalid turn-snapshots %q: use off, auto, git or copy", cfg.TurnSnapshots)
+	}
+	if cfg.TurnSnapshots != "off" && cfg.TurnSnapshotKeep < 1 {
+		return Config{}, errors.New("turn-snapshot-keep must be at least 1")
+	}
+
+	if cfg.SessionPingInterval > 0 && cfg.SessionPingTimeout <= 0 {
+		return Config{}, errors.New("session-ping-timeout must be positive while pings are enabled")
+	}
+
+	if (cfg.MaxAgentRSSBytes > 0 || cfg.MaxAgentCPUPercent > 0) && cfg.AgentSampleInterval <= 0 {
+		return Config{}, errors.New("max-agent-rss and max-agent-cpu require a positive --agent-sample-interval")
+	}
+	if cfg.MaxAgentCPUPercent > 0 && cfg.MaxAgentCPUFor < cfg.AgentSampleInterval {
+		return Config{}, errors.New("max-agent-cpu-for must be at least --agent-sample-interval")
+	}
+	if cfg.MaxTurnCPU > 0 && cfg.AgentSampleInterval <= 0 {
+		return Config{}, errors.New("max-turn-cpu requires a positive --agent-sample-interval")
+	}
+
+	if cfg.QuickLinkTTL <= 0 {
+		return Config{}, errors.New("quick-link-ttl must be positive")
+	}
+	if cfg.TurnWarnTokens > 0 && cfg.TurnMaxTokens > 0 && cfg.TurnWarnTokens >= cfg.TurnMaxTokens {
+		return Config{}, errors.New("turn-warn-tokens must be below --turn-max-tokens")
+	}
+	if cfg.ShareLinkTTL <= 0 {
+		return Config{}, errors.New("share-link-ttl must be positive")
+	}
+
+	if cfg.MQTTURL != "" {
+		u, err := url.Parse(cfg.MQTTURL)
+		if err != nil || u.Host == "" {
+			return Config{}, fmt.Errorf("invalid MQTT broker URL: %s", cfg.MQTTURL)
+		}
+		switch u.Scheme {
+		case "tcp", "mqtt", "ssl", "tls", "mqtts":
+		default:
+			return Config{}, fmt.Errorf("invalid MQTT broker URL: %s", cfg.MQTTURL)
+		}
+	}
+	if cfg.S3Bucket != "" {
+		if cfg.S3AccessKey == "" {
+			cfg.S3AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		}
+		if cfg.S3SecretKey == "" {
+			cfg.S3SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		}
+		if cfg.S3Endpoint == "" {
+			cfg.S3Endpoint = "https://s3." + cfg.S3Region + ".amazonaws.com"
+		}
+		u, err := url.Parse(cfg.S3Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return Config{}, fmt.Errorf("invalid S3 endpoint: %s", cfg.S3Endpoint)
+		}
+		if cfg.S3AccessKey == "" || cfg.S3SecretKey == "" {
+			return Config{}, errors.New("s3-bucket requires --s3-access-key and --s3-secret-key (or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY)")
+		}
+		if cfg.S3Prefix != "" && !strings.HasSuffix(cfg.S3Prefix, "/") {
+			cfg.S3Prefix += "/"
+		}
+	} else if cfg.ArchiveInterval > 0 {
+		return Config{}, errors.New("archive-interval requires --s3-bucket")
+	}
+	if strings.ContainsAny(cfg.MQTTTopicPrefix, "+#") {
+		return Config{}, errors.New("mqtt-topic-prefix must not contain MQTT wildcards")
+	}
+	if cfg.RedisURL != "" {
+		u, err := url.Parse(cfg.RedisURL)
+		if err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+			return Config{}, fmt.Errorf("invalid Redis URL: %s", cfg.RedisURL)
+		}
+	}
+	if cfg.RedisChannel == "" {
+		return Config{}, errors.New("redis-channel must not be empty")
+	}
+
+	if cfg.OIDCIssuer != "" {
+		u, err := url.Parse(cfg.OIDCIssuer)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return Config{}, fmt.Errorf("invalid OIDC issuer: %s", cfg.OIDCIssuer)
+		}
+		if cfg.OIDCClientID == "" {
+			return Config{}, errors.New("oidc-issuer requires --oidc-client-id")
+		}
+		if cfg.OIDCClientSecret == "" {
+			cfg.OIDCClientSecret = os.Getenv("DARKHOLD_OIDC_CLIENT_SECRET")
+		}
+		if cfg.OIDCRedirectURL != "" {
+			u, err := url.Parse(cfg.OIDCRedirectURL)
+			if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+				return Config{}, fmt.Errorf("invalid OIDC redirect URL: %s", cfg.OIDCRedirectURL)
+			}
+		}
+		if cfg.OIDCSessionTTL <= 0 {
+			return Config{}, errors.New("oidc-session-ttl must be positive")
+		}
+		scopes := slices.Clone(cfg.OIDCDefaultScopes)
+		for _, groupScopes := range cfg.OIDCGroupScopes {
+			scopes = append(scopes, groupScopes...)
+		}
+		for _, scope := range scopes {
+			if !slices.Contains(AccessScopes, scope) {
+				return Config{}, fmt.Errorf("unknown scope %q: use %s", scope, strings.Join(AccessScopes, ", "))
+			}
+		}
+	} else if cfg.OIDCClientID != "" || len(cfg.OIDCGroupScopes) > 0 {
+		return Config{}, errors.New("oidc-client-id and oidc-group require --oidc-issuer")
+	}
+
+	seenKeys := map[string]bool{}
+	for _, key := range cfg.APIKeys {
+		if seenKeys[key.Name] {
+			return Config{}, fmt.Errorf("duplicate API key name: %s", key.Name)
+		}
+		seenKeys[key.Name] = true
+	}
+	for keyName := range cfg.KeyBudgets {
+		if !seenKeys[keyName] {
+			return Config{}, fmt.Errorf("key-budget refers to unknown API key: %s", keyName)
+		}
+	}
 
 	return cfg, nil
 }
 
+func parseAPIKey(value string) (APIKey, error) {
+	name, token, found := strings.Cut(strings.TrimSpace(value), ":")
+	name = strings.TrimSpace(name)
+	token = strings.TrimSpace(token)
+	if !found || name == "" || len(token) < 16 {
+		return APIKey{}, errors.New("api-key must look like name:token with a token of at least 16 characters")
+	}
+	return APIKey{Name: name, Token: token}, nil
+}
+
+// readAPIKeyFile reads one name:token pair per line, skipping blank lines and
+// # comments, so tokens need not appear on the command line.
+func readAPIKeyFile(path string) ([]APIKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("api-key-file: %w", err)
+	}
+	var keys []APIKey
+	for line := range strings.SplitSeq(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := parseAPIKey(line)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// parseLimit reads a non-negative count where zero means unlimited.
+func parseLimit(name, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s: %s", name, value)
+	}
+	return n, nil
+}
+
+func parseDenyCommand(value string) (*regexp.Regexp, error) {
+	pattern, err := regexp.Compile(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --deny-command %q: %v", value, err)
+	}
+	return pattern, nil
+}
+
+// parseExecAllow compiles an --exec-allow pattern anchored at both ends, so
+// "npm test" does not also allow "npm test; curl ...".
+func parseExecAllow(value string) (*regexp.Regexp, error) {
+	pattern, err := regexp.Compile(`^(?:` + value + `)$`)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --exec-allow %q: %v", value, err)
+	}
+	return pattern, nil
+}
+
+// parseAgentCapability reads an --agent-capability "name=value" pair. The
+// value is decoded as JSON when it parses (true, 3, {"a":1}) and kept as a
+// string otherwise; a bare name means true.
+func parseAgentCapability(value string) (string, any, error) {
+	name, raw, hasValue := strings.Cut(value, "=")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, fmt.Errorf("invalid --agent-capability %q: expected name=value", value)
+	}
+	if !hasValue {
+		return name, true, nil
+	}
+	var decoded any
+	if err := json.Unmarshal([]byte(raw), &decoded); err != nil {
+		return name, raw, nil
+	}
+	return name, decoded, nil
+}
+
+// parseAgentInitParams reads --agent-init-params: a JSON object, or
+// @path to a file holding one.
+func parseAgentInitParams(value string) (map[string]any, error) {
+	data := []byte(value)
+	if path, ok := strings.CutPrefix(value, "@"); ok {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("agent-init-params: %w", err)
+		}
+	}
+	var params map[string]any
+	if err := json.Unmarshal(data, &params); err != nil || params == nil {
+		return nil, errors.New("agent-init-params must be a JSON object")
+	}
+	return params, nil
+}
+
+// parseSystemPreamble reads --system-preamble: the text itself, or @path to
+// a file holding it.
+func parseSystemPreamble(value string) (string, error) {
+	if path, ok := strings.CutPrefix(value, "@"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("system-preamble: %w", err)
+		}
+		value = string(data)
+	}
+	return strings.TrimSpace(value), nil
+}
+
+// parseTurnHook reads a --turn-hook: a TurnHookNames entry or
+// "cmd:<command line>".
+func parseTurnHook(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if command, ok := strings.CutPrefix(value, "cmd:"); ok {
+		if strings.TrimSpace(command) == "" {
+			return "", errors.New("turn-hook cmd: needs a command line")
+		}
+		return "cmd:" + strings.TrimSpace(command), nil
+	}
+	if !slices.Contains(TurnHookNames, value) {
+		return "", fmt.Errorf("unknown turn-hook %q: use %s or cmd:<command>", value, strings.Join(TurnHookNames, ", "))
+	}
+	return value, nil
+}
+
+// parsePostTurnHook reads a --post-turn-hook: "cmd:<command line>" or an
+// http(s) URL.
+func parsePostTurnHook(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if command, ok := strings.CutPrefix(value, "cmd:"); ok {
+		if strings.TrimSpace(command) == "" {
+			return "", errors.New("post-turn-hook cmd: needs a command line")
+		}
+		return "cmd:" + strings.TrimSpace(command), nil
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid post-turn-hook %q: use cmd:<command> or an http(s) URL", value)
+	}
+	return value, nil
+}
+
+// parseAutoRespond reads an --auto-respond "method=result" pair, where
+// result is the JSON the request is answered with.
+func parseAutoRespond(value string) (string, any, error) {
+	method, raw, _ := strings.Cut(value, "=")
+	method = strings.TrimSpace(method)
+	var result any
+	if method == "" || json.Unmarshal([]byte(raw), &result) != nil {
+		return "", nil, fmt.Errorf("invalid --auto-respond %q: expected method=<json result>", value)
+	}
+	return method, result, nil
+}
+
+// readDenyCommandFile reads one deny-list regex per line, skipping blank
+// lines and # comments.
+func readDenyCommandFile(path string) ([]*regexp.Regexp, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("deny-command-file: %w", err)
+	}
+	var patterns []*regexp.Regexp
+	for line := range strings.SplitSeq(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pattern, err := parseDenyCommand(line)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// splitScopes reads a comma- or space-separated scope list; "none" is an
+// empty one.
+func splitScopes(value string) []string {
+	scopes := []string{}
+	for _, scope := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if scope != "none" && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// parseBudget reads "turns=N,tokens=N"; either part may be omitted.
+func parseBudget(value string) (Budget, error) {
+	var budget Budget
+	for part := range strings.SplitSeq(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		field, raw, _ := strings.Cut(part, "=")
+		n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+		if err != nil || n < 0 {
+			return Budget{}, fmt.Errorf("invalid budget %q: limits must be non-negative integers", value)
+		}
+		switch strings.TrimSpace(field) {
+		case "turns":
+			budget.TurnsPerDay = n
+		case "tokens":
+			budget.TokensPerDay = n
+		default:
+			return Budget{}, fmt.Errorf("invalid budget %q: expected turns=N and/or tokens=N", value)
+		}
+	}
+	return budget, nil
+}
+
+// parseSize reads a byte count with an optional binary unit suffix: 1048576,
+// 512KB, 500MB, 2GB or 1TB (KiB, MiB, GiB and TiB are accepted too).
+func parseSize(name, value string) (int64, error) {
+	raw := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"TIB", 1 << 40}, {"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10}, {"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if trimmed, ok := strings.CutSuffix(raw, unit.suffix); ok {
+			raw, multiplier = strings.TrimSpace(trimmed), unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || n < 0 || n > (1<<62)/multiplier {
+		return 0, fmt.Errorf("%s must be a non-negative size (for example 500MB or 2GB)", strings.TrimPrefix(name, "--"))
+	}
+	return n * multiplier, nil
+}
+
+func parseRate(name, value string) (float64, error) {
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("%s must be a number between 0 and 1", strings.TrimPrefix(name, "--"))
+	}
+	return rate, nil
+}
+
+func parseDuration(name, value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative duration (for example 30s or 5m)", strings.TrimPrefix(name, "--"))
+	}
+	return d, nil
+}
+
 func IsAllowedClient(ip net.IP, allowCIDRs []string) bool {
 	if ip == nil {
 		return true
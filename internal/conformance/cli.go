@@ -0,0 +1,65 @@
+package conformance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+)
+
+// Main implements `darkhold conformance`. It exits with an error when any
+// spec fails.
+func Main(args []string, stdout io.Writer) error {
+	var opts Options
+	flags := flag.NewFlagSet("conformance", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	flags.StringVar(&opts.AgentCmd, "agent-cmd", "", "agent to test, as for --agent-cmd (default: the built-in fake agent)")
+	flags.DurationVar(&opts.Timeout, "timeout", 0, "maximum time per step without its own timeout (default 30s)")
+	specDir := flags.String("spec", "", "directory of JSON specs to run instead of the built-in ones")
+	only := flags.String("run", "", "comma-separated spec names to run")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var fsys fs.FS = Specs
+	dir := "spec"
+	if *specDir != "" {
+		fsys, dir = os.DirFS(*specDir), "."
+	}
+	specs, err := LoadSpecs(fsys, dir)
+	if err != nil {
+		return err
+	}
+	if *only != "" {
+		names := strings.Split(*only, ",")
+		specs = slices.DeleteFunc(specs, func(spec Spec) bool { return !slices.Contains(names, spec.Name) })
+	}
+	if len(specs) == 0 {
+		return errors.New("no specs to run")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report := Run(ctx, opts, specs)
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else if _, err := fmt.Fprint(stdout, Format(report)); err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d specs failed", report.Failed, len(report.Results))
+	}
+	return nil
+}
@@ -0,0 +1,529 @@
+// Package conformance runs the app-server protocol conformance suite: a set
+// of scripted scenarios (initialize, threads, turns, approvals, streamed
+// notifications, interrupts) played through a darkhold server against an
+// agent. The built-in fake agent must pass it, and so must any agent version
+// or alternative backend darkhold claims to support.
+//
+// Scenarios live as JSON specs in the spec directory and are embedded, so
+// `darkhold conformance` can run them against a real agent binary.
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"darkhold-go/internal/config"
+	"darkhold-go/internal/events"
+	"darkhold-go/internal/server"
+)
+
+// Specs holds the built-in scenarios, one JSON file per spec.
+//
+//go:embed spec/*.json
+var Specs embed.FS
+
+// Spec is one scenario: steps run in order against a fresh server.
+type Spec struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Fake configures the built-in fake agent for this spec, so it exercises
+	// paths a real agent reaches on its own (asking for approval, taking
+	// long enough to interrupt). It is ignored for other agents.
+	Fake  *FakeOptions `json:"fake,omitempty"`
+	Steps []Step       `json:"steps"`
+}
+
+// FakeOptions are the fake agent knobs a spec may set.
+type FakeOptions struct {
+	ApprovalRate float64 `json:"approvalRate,omitempty"`
+	// Latency delays each streamed step, as a Go duration.
+	Latency string `json:"latency,omitempty"`
+}
+
+// Step is one action. Exactly one of RPC, Await and Respond is set.
+//
+// Strings of the form ${name} in Params, Respond, Match and Expect are
+// replaced by variables: cwd (a directory the agent may use) and whatever
+// earlier steps saved. Expect and Match map dotted paths into the RPC result or the event
+// ("thread.id", "params.turn.status", "data.0.id") to either a literal that
+// must be equal, or one of the matchers $string, $number, $boolean, $object,
+// $array, $present and $nonEmpty.
+type Step struct {
+	// RPC calls a method through POST /api/rpc.
+	RPC    string `json:"rpc,omitempty"`
+	Params any    `json:"params,omitempty"`
+	// ExpectError means the call must fail (an upstream error or a darkhold
+	// error status); Expect then applies to the error body.
+	ExpectError bool `json:"expectError,omitempty"`
+
+	// Await waits for the next thread event with this method after the last
+	// one awaited, among those whose fields satisfy Match. Thread names the
+	// thread, default ${threadId}.
+	Await  string         `json:"await,omitempty"`
+	Match  map[string]any `json:"match,omitempty"`
+	Thread string         `json:"thread,omitempty"`
+
+	// Respond answers ${requestId} on ${threadId} with this result through
+	// POST /api/thread/interaction/respond.
+	Respond any `json:"respond,omitempty"`
+
+	Expect map[string]any `json:"expect,omitempty"`
+	// Save stores values from the result or event as variables.
+	Save map[string]string `json:"save,omitempty"`
+	// Timeout bounds the step, as a Go duration (default Options.Timeout).
+	Timeout string `json:"timeout,omitempty"`
+}
+
+func (s Step) label() string {
+	switch {
+	case s.RPC != "":
+		return "rpc " + s.RPC
+	case s.Await != "":
+		return "await " + s.Await
+	default:
+		return "respond"
+	}
+}
+
+// LoadSpecs reads every *.json spec in dir of fsys, ordered by file name.
+func LoadSpecs(fsys fs.FS, dir string) ([]Spec, error) {
+	names, err := fs.Glob(fsys, filepath.ToSlash(filepath.Join(dir, "*.json")))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	specs := make([]Spec, 0, len(names))
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		var spec Spec
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&spec); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if spec.Name == "" {
+			spec.Name = strings.TrimSuffix(filepath.Base(name), ".json")
+		}
+		for i, step := range spec.Steps {
+			set := 0
+			for _, present := range []bool{step.RPC != "", step.Await != "", step.Respond != nil} {
+				if present {
+					set++
+				}
+			}
+			if set != 1 {
+				return nil, fmt.Errorf("%s: step %d must set exactly one of rpc, await and respond", name, i+1)
+			}
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// Options configure a run.
+type Options struct {
+	// AgentCmd is the agent to test, as for --agent-cmd; empty means the
+	// built-in fake agent.
+	AgentCmd string
+	// Timeout bounds each step that sets none (default 30s).
+	Timeout time.Duration
+}
+
+// Result is the outcome of one spec.
+type Result struct {
+	Name     string        `json:"name"`
+	Passed   bool          `json:"passed"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Report is the outcome of a run.
+type Report struct {
+	AgentCmd string   `json:"agentCmd"`
+	Results  []Result `json:"results"`
+	Passed   int      `json:"passed"`
+	Failed   int      `json:"failed"`
+}
+
+// Run plays every spec against its own in-process darkhold server backed by
+// the agent, and reports which passed.
+func Run(ctx context.Context, opts Options, specs []Spec) Report {
+	if opts.AgentCmd == "" {
+		opts.AgentCmd = config.FakeAgentCmd
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	report := Report{AgentCmd: opts.AgentCmd, Results: []Result{}}
+	for _, spec := range specs {
+		started := time.Now()
+		err := runSpec(ctx, opts, spec)
+		result := Result{Name: spec.Name, Passed: err == nil, Duration: time.Since(started)}
+		if err != nil {
+			result.Error = err.Error()
+			report.Failed++
+		} else {
+			report.Passed++
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
+
+func runSpec(ctx context.Context, opts Options, spec Spec) error {
+	dataDir, err := os.MkdirTemp("", "darkhold-conformance-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dataDir)
+	cwd := filepath.Join(dataDir, "workspace")
+	eventsRoot := filepath.Join(dataDir, "events")
+	for _, dir := range []string{cwd, eventsRoot} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	cfg := config.Config{Bind: "127.0.0.1", DataDir: dataDir, AgentCmd: opts.AgentCmd}
+	if opts.AgentCmd == config.FakeAgentCmd && spec.Fake != nil {
+		cfg.FakeApprovalRate = spec.Fake.ApprovalRate
+		if spec.Fake.Latency != "" {
+			if cfg.FakeLatency, err = time.ParseDuration(spec.Fake.Latency); err != nil {
+				return fmt.Errorf("fake latency: %w", err)
+			}
+		}
+	}
+	srv := server.New(cfg, events.NewStore(eventsRoot))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	httpServer := &http.Server{Handler: srv.Handler()}
+	go func() { _ = httpServer.Serve(listener) }()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	r := &runner{
+		baseURL: "http://" + listener.Addr().String(),
+		client:  &http.Client{},
+		vars:    map[string]any{"cwd": cwd},
+		cursors: map[string]int{},
+	}
+	for i, step := range spec.Steps {
+		timeout := opts.Timeout
+		if step.Timeout != "" {
+			if timeout, err = time.ParseDuration(step.Timeout); err != nil {
+				return fmt.Errorf("step %d (%s): timeout: %w", i+1, step.label(), err)
+			}
+		}
+		stepCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := r.run(stepCtx, step)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("step %d (%s): %w", i+1, step.label(), err)
+		}
+	}
+	return nil
+}
+
+type runner struct {
+	baseURL string
+	client  *http.Client
+	vars    map[string]any
+	// cursors is how many of each thread's events earlier awaits consumed.
+	cursors map[string]int
+}
+
+func (r *runner) run(ctx context.Context, step Step) error {
+	var subject any
+	switch {
+	case step.RPC != "":
+		status, body, err := r.post(ctx, "/api/rpc", map[string]any{"method": step.RPC, "params": r.expand(step.Params)})
+		if err != nil {
+			return err
+		}
+		failed := status != http.StatusOK
+		if failed != step.ExpectError {
+			if failed {
+				return fmt.Errorf("HTTP %d: %s", status, body)
+			}
+			return fmt.Errorf("expected an error, got %s", body)
+		}
+		if err := json.Unmarshal(body, &subject); err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+	case step.Await != "":
+		thread := step.Thread
+		if thread == "" {
+			thread = "${threadId}"
+		}
+		threadID, _ := r.expand(thread).(string)
+		if threadID == "" {
+			return errors.New("no thread to await events on")
+		}
+		event, err := r.await(ctx, threadID, step.Await, r.expand(step.Match))
+		if err != nil {
+			return err
+		}
+		subject = event
+	default:
+		status, body, err := r.post(ctx, "/api/thread/interaction/respond", map[string]any{
+			"threadId":  fmt.Sprint(r.vars["threadId"]),
+			"requestId": fmt.Sprint(r.vars["requestId"]),
+			"result":    r.expand(step.Respond),
+		})
+		if err != nil {
+			return err
+		}
+		if status != http.StatusOK {
+			return fmt.Errorf("HTTP %d: %s", status, body)
+		}
+		_ = json.Unmarshal(body, &subject)
+	}
+	expect, _ := r.expand(step.Expect).(map[string]any)
+	if err := check(subject, expect); err != nil {
+		return err
+	}
+	for name, path := range step.Save {
+		value, ok := lookup(subject, path)
+		if !ok {
+			return fmt.Errorf("cannot save %s: %s is missing", name, path)
+		}
+		r.vars[name] = value
+	}
+	return nil
+}
+
+func (r *runner) post(ctx context.Context, path string, body any) (int, []byte, error) {
+	encoded, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.baseURL+path, bytes.NewReader(encoded))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, bytes.TrimSpace(buf.Bytes()), nil
+}
+
+// await polls the thread's stored events until one after the cursor has the
+// method and satisfies match, and moves the cursor past it.
+func (r *runner) await(ctx context.Context, threadID, method string, match any) (map[string]any, error) {
+	filter, _ := match.(map[string]any)
+	var seen []string
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.baseURL+"/api/thread/events?threadId="+threadID, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := r.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("timed out; saw %s", describeSeen(seen))
+			}
+			return nil, err
+		}
+		var listing struct {
+			Events []string `json:"events"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&listing)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decode events: %w", err)
+		}
+		seen = seen[:0]
+		for i := r.cursors[threadID]; i < len(listing.Events); i++ {
+			var event map[string]any
+			if json.Unmarshal([]byte(listing.Events[i]), &event) != nil {
+				continue
+			}
+			name, _ := event["method"].(string)
+			seen = append(seen, name)
+			if name == method && check(event, filter) == nil {
+				r.cursors[threadID] = i + 1
+				return event, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out; saw %s", describeSeen(seen))
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func describeSeen(methods []string) string {
+	if len(methods) == 0 {
+		return "no new events"
+	}
+	return strings.Join(methods, ", ")
+}
+
+// expand replaces ${name} references in strings throughout value. A string
+// that is only a reference takes the variable's value and type.
+func (r *runner) expand(value any) any {
+	switch v := value.(type) {
+	case string:
+		if name, ok := strings.CutPrefix(v, "${"); ok && strings.HasSuffix(name, "}") && !strings.Contains(name, "${") {
+			if resolved, ok := r.vars[strings.TrimSuffix(name, "}")]; ok {
+				return resolved
+			}
+		}
+		for name, resolved := range r.vars {
+			v = strings.ReplaceAll(v, "${"+name+"}", fmt.Sprint(resolved))
+		}
+		return v
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			out[key] = r.expand(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = r.expand(item)
+		}
+		return out
+	}
+	return value
+}
+
+// check reports the first expectation subject does not meet.
+func check(subject any, expect map[string]any) error {
+	paths := make([]string, 0, len(expect))
+	for path := range expect {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		want := expect[path]
+		got, ok := lookup(subject, path)
+		if matcher, isMatcher := want.(string); isMatcher && strings.HasPrefix(matcher, "$") {
+			if err := matchKind(matcher, got, ok); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			continue
+		}
+		if !ok {
+			return fmt.Errorf("%s is missing, want %s", path, encode(want))
+		}
+		if encode(got) != encode(want) {
+			return fmt.Errorf("%s = %s, want %s", path, encode(got), encode(want))
+		}
+	}
+	return nil
+}
+
+func matchKind(matcher string, got any, present bool) error {
+	if !present {
+		return fmt.Errorf("missing, want %s", matcher)
+	}
+	ok := false
+	switch matcher {
+	case "$present":
+		ok = true
+	case "$string":
+		_, ok = got.(string)
+	case "$number":
+		_, ok = got.(float64)
+	case "$boolean":
+		_, ok = got.(bool)
+	case "$object":
+		_, ok = got.(map[string]any)
+	case "$array":
+		_, ok = got.([]any)
+	case "$nonEmpty":
+		switch v := got.(type) {
+		case string:
+			ok = v != ""
+		case []any:
+			ok = len(v) > 0
+		case map[string]any:
+			ok = len(v) > 0
+		}
+	default:
+		return fmt.Errorf("unknown matcher %s", matcher)
+	}
+	if !ok {
+		return fmt.Errorf("got %s, want %s", encode(got), matcher)
+	}
+	return nil
+}
+
+// lookup follows a dotted path through objects and arrays.
+func lookup(value any, path string) (any, bool) {
+	if path == "" {
+		return value, true
+	}
+	for key := range strings.SplitSeq(path, ".") {
+		switch v := value.(type) {
+		case map[string]any:
+			next, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func encode(value any) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// Format renders a report as plain text.
+func Format(report Report) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "agent: %s\n", report.AgentCmd)
+	for _, result := range report.Results {
+		status := "PASS"
+		if !result.Passed {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&b, "%s  %-20s %8s", status, result.Name, result.Duration.Round(time.Millisecond))
+		if result.Error != "" {
+			fmt.Fprintf(&b, "  %s", result.Error)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "%d passed, %d failed\n", report.Passed, report.Failed)
+	return b.String()
+}
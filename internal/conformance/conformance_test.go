@@ -0,0 +1,71 @@
+package conformance
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestConformance runs the built-in specs against the fake agent, or against
+// DARKHOLD_CONFORMANCE_AGENT_CMD when it is set.
+func TestConformance(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("loopback sockets are not available in this environment")
+	}
+	_ = listener.Close()
+
+	specs, err := LoadSpecs(Specs, "spec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{AgentCmd: os.Getenv("DARKHOLD_CONFORMANCE_AGENT_CMD"), Timeout: 10 * time.Second}
+	for _, spec := range specs {
+		t.Run(spec.Name, func(t *testing.T) {
+			report := Run(context.Background(), opts, []Spec{spec})
+			if result := report.Results[0]; !result.Passed {
+				t.Fatal(result.Error)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	subject := map[string]any{
+		"thread": map[string]any{"id": "t-1", "turns": []any{map[string]any{"id": "u-1"}}},
+		"count":  float64(2),
+		"empty":  "",
+	}
+	passing := map[string]any{
+		"thread.id":         "t-1",
+		"thread.turns.0.id": "u-1",
+		"thread.turns":      "$nonEmpty",
+		"count":             float64(2),
+		"empty":             "$string",
+	}
+	if err := check(subject, passing); err != nil {
+		t.Fatalf("expected expectations to hold: %v", err)
+	}
+	for path, want := range map[string]any{
+		"thread.id":         "t-2",
+		"thread.turns.1.id": "$present",
+		"empty":             "$nonEmpty",
+		"count":             "$string",
+		"missing":           "$bogus",
+	} {
+		if err := check(subject, map[string]any{path: want}); err == nil {
+			t.Fatalf("expected %s %v to fail", path, want)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	r := &runner{vars: map[string]any{"threadId": "t-1", "count": float64(3)}}
+	got := r.expand(map[string]any{"id": "${threadId}", "n": "${count}", "label": "thread ${threadId}", "list": []any{"${threadId}"}})
+	params := got.(map[string]any)
+	if params["id"] != "t-1" || params["n"] != float64(3) || params["label"] != "thread t-1" || params["list"].([]any)[0] != "t-1" {
+		t.Fatalf("unexpected expansion: %v", params)
+	}
+}
@@ -0,0 +1,54 @@
+package events
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestChainDetectsEditedRecords(t *testing.T) {
+	store := NewStore(t.TempDir())
+	if _, err := store.Append("t1", `{"method":"turn/started"}`); err != nil {
+		t.Fatal(err)
+	}
+	store.Chain = true
+	for _, payload := range []string{`{"method":"item/completed"}`, `{"method":"turn/completed"}`, `{"method":"thread/name/updated"}`} {
+		if _, err := store.Append("t1", payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err := store.ReadRange("t1", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := VerifyChain(records)
+	if !report.Valid || report.Records != 4 || report.Chained != 3 || report.FirstChained != records[1].ID || report.Head != RecordHash(records[3]) {
+		t.Fatalf("unexpected report for an intact log: %+v", report)
+	}
+	if compaction, err := Compact(store, "t1"); err != nil || !compaction.Chained {
+		t.Fatalf("expected a chained log to be left alone, got %+v %v", compaction, err)
+	}
+
+	data, err := store.ExportLog("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := bytes.Replace(data, []byte(`item/completed`), []byte(`item/started`), 1)
+	report, err = VerifyChainLog(bytes.NewReader(tampered))
+	if err != nil || report.Valid || len(report.Breaks) != 1 || report.Breaks[0].Index != 2 {
+		t.Fatalf("expected the record after the edit to break, got %+v %v", report, err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	removed := strings.Join(append(lines[:2:2], lines[3:]...), "")
+	if report, _ := VerifyChainLog(strings.NewReader(removed)); report.Valid {
+		t.Fatalf("expected a removed record to break the chain, got %+v", report)
+	}
+
+	memory := NewMemory()
+	memory.Chain = true
+	first, _ := memory.Append("t1", `{"method":"turn/started"}`)
+	second, _ := memory.Append("t1", `{"method":"turn/completed"}`)
+	if first.Prev != ChainGenesis || second.Prev != RecordHash(first) {
+		t.Fatalf("unexpected memory chain %q %q", first.Prev, second.Prev)
+	}
+}
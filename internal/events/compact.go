@@ -0,0 +1,116 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// Compaction is the outcome of Compact.
+type Compaction struct {
+	Records      int   `json:"records"`
+	Dropped      int   `json:"dropped"`
+	BytesBefore  int64 `json:"bytesBefore"`
+	BytesAfter   int64 `json:"bytesAfter"`
+	BytesDropped int64 `json:"bytesDropped"`
+	// Chained is set when the log was left alone because it is hash-chained.
+	Chained bool `json:"chained,omitempty"`
+}
+
+// Compact rewrites a thread's log without the streaming deltas
+// (item/agentMessage/delta, item/commandExecution/outputDelta, ...) of items
+// that later completed, since their item/completed event carries the final
+// content. Every other record keeps its ID and stamps, so stored cursors stay
+// valid. Chained logs are left as they are, since dropping records would
+// break the chain. The log is read and replaced under RewriteLog, so events
+// appended meanwhile wait and land after the compacted records.
+func Compact(store Storage, threadID string) (Compaction, error) {
+	var result Compaction
+	err := store.RewriteLog(threadID, func(data []byte) ([]byte, error) {
+		records, err := decodeRecords(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		result = Compaction{Records: len(records), BytesBefore: int64(len(data)), BytesAfter: int64(len(data))}
+		for _, record := range records {
+			if record.Prev != "" {
+				result.Chained = true
+				return nil, nil
+			}
+		}
+		kept := compactRecords(records)
+		result.Dropped = len(records) - len(kept)
+		if result.Dropped == 0 {
+			return nil, nil
+		}
+		compacted, err := encodeRecords(kept)
+		if err != nil {
+			return nil, err
+		}
+		result.Records = len(kept)
+		result.BytesAfter = int64(len(compacted))
+		result.BytesDropped = result.BytesBefore - result.BytesAfter
+		return compacted, nil
+	})
+	if err != nil {
+		return Compaction{}, err
+	}
+	return result, nil
+}
+
+// compactRecords drops the deltas of items with an item/completed record.
+func compactRecords(records []Record) []Record {
+	completed := map[string]bool{}
+	for _, record := range records {
+		var event struct {
+			Method string `json:"method"`
+			Params struct {
+				Item struct {
+					ID string `json:"id"`
+				} `json:"item"`
+			} `json:"params"`
+		}
+		if json.Unmarshal([]byte(record.Payload), &event) == nil && event.Method == "item/completed" && event.Params.Item.ID != "" {
+			completed[event.Params.Item.ID] = true
+		}
+	}
+	kept := records[:0:0]
+	for _, record := range records {
+		if itemID, ok := deltaItemID(record.Payload); ok && completed[itemID] {
+			continue
+		}
+		kept = append(kept, record)
+	}
+	return kept
+}
+
+// deltaItemID returns the item a streaming delta notification belongs to.
+func deltaItemID(payload string) (string, bool) {
+	var event struct {
+		Method string `json:"method"`
+		Params struct {
+			ItemID string `json:"itemId"`
+		} `json:"params"`
+	}
+	if json.Unmarshal([]byte(payload), &event) != nil || event.Params.ItemID == "" {
+		return "", false
+	}
+	last := event.Method[strings.LastIndexByte(event.Method, '/')+1:]
+	if last != "delta" && !strings.HasSuffix(last, "Delta") {
+		return "", false
+	}
+	return event.Params.ItemID, true
+}
+
+// encodeRecords writes records as JSONL, the format ExportLog returns.
+func encodeRecords(records []Record) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(append(line, '\n'))
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,289 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Issue kinds reported by Fsck.
+const (
+	// IssueTruncatedLine is a final line cut short by a crash mid-append.
+	IssueTruncatedLine = "truncatedLine"
+	// IssueCorruptLine is a line that is neither a record nor a legacy
+	// payload. When a later append was written onto a truncated line, the
+	// complete record at its end is salvaged.
+	IssueCorruptLine = "corruptLine"
+	// IssueDuplicateID is a record whose ID appeared earlier in the log.
+	// Exact copies are dropped; records with a different payload get a new
+	// ID, as for IssueOutOfOrder.
+	IssueDuplicateID = "duplicateId"
+	// IssueOutOfOrder is a record whose ID is not after the previous one, so
+	// resuming after an earlier ID can skip it. It gets a new ID after the
+	// previous record, keeping the old one as its LegacyID (see sortIDs).
+	IssueOutOfOrder = "outOfOrder"
+	// IssueOrphanedLock is a lock directory left by a crashed process.
+	IssueOrphanedLock = "orphanedLock"
+	// IssueSequence is a missing, corrupt or stale sequence file that would
+	// hand out sequence numbers already used in a log.
+	IssueSequence = "sequence"
+)
+
+// rejectedSuffix names the file next to a log that keeps the lines Fsck
+// removed from it.
+const rejectedSuffix = ".rejected"
+
+// FsckIssue is one problem found by Fsck. Log is the log key and Line the
+// 1-based line number, when the issue belongs to a log.
+type FsckIssue struct {
+	Kind     string `json:"kind"`
+	Log      string `json:"log,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Detail   string `json:"detail"`
+	Repaired bool   `json:"repaired"`
+}
+
+// FsckReport is the outcome of Fsck.
+type FsckReport struct {
+	Logs       int         `json:"logs"`
+	Records    int         `json:"records"`
+	Issues     []FsckIssue `json:"issues"`
+	Repaired   int         `json:"repaired"`
+	Unrepaired int         `json:"unrepaired"`
+}
+
+func (r *FsckReport) add(issue FsckIssue) {
+	r.Issues = append(r.Issues, issue)
+	if issue.Repaired {
+		r.Repaired++
+	} else {
+		r.Unrepaired++
+	}
+}
+
+// Fsck checks every log in the store for truncated or corrupt lines and
+// duplicate or out-of-order IDs, and the store for orphaned lock directories
+// and a sequence file behind the logs. With repair, bad lines are moved to
+// <log>.rejected, exact duplicates dropped, IDs out of log order replaced,
+// orphaned locks removed and the sequence file advanced; otherwise nothing is
+// changed and every issue is reported unrepaired. Each log is checked under its lock, so Fsck is safe to
+// run against a store in use.
+func (s *Store) Fsck(repair bool) (FsckReport, error) {
+	report := FsckReport{Issues: []FsckIssue{}}
+	if err := s.fsckLocks(&report, repair); err != nil {
+		return report, err
+	}
+	logs, err := s.Logs()
+	if err != nil {
+		return report, err
+	}
+	var maxSeq int64
+	for _, log := range logs {
+		seq, err := s.fsckLog(&report, log.Key, repair)
+		if err != nil {
+			return report, fmt.Errorf("check %s: %w", log.Key, err)
+		}
+		maxSeq = max(maxSeq, seq)
+		report.Logs++
+	}
+	if err := s.fsckSequence(&report, maxSeq, repair); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// fsckLocks reports lock directories older than lockStaleDuration. Locks are
+// held only for the length of one write, so these belong to no live process.
+// Windows lock files are released by the OS and never reported.
+func (s *Store) fsckLocks(report *FsckReport, repair bool) error {
+	entries, err := os.ReadDir(s.RootDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasSuffix(entry.Name(), ".lock") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || lockAge(filepath.Join(s.RootDir, entry.Name()), info.ModTime(), s.Network) <= lockStaleDuration {
+			continue
+		}
+		issue := FsckIssue{Kind: IssueOrphanedLock, Detail: entry.Name() + " held since " + info.ModTime().UTC().Format(time.RFC3339)}
+		if repair {
+			if err := os.RemoveAll(filepath.Join(s.RootDir, entry.Name())); err != nil {
+				return err
+			}
+			issue.Repaired = true
+		}
+		report.add(issue)
+	}
+	return nil
+}
+
+// fsckLog checks one log and returns the highest sequence number in it.
+// Lines are kept byte for byte, so legacy lines stay in their old format.
+func (s *Store) fsckLog(report *FsckReport, key string, repair bool) (int64, error) {
+	var maxSeq int64
+	err := s.withThreadFileLock(key, func() error {
+		path := s.filePath(key)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		lines := strings.Split(string(data), "\n")
+		// A log ending in a newline splits into a final empty string; any
+		// other final element was never terminated.
+		unterminated := lines[len(lines)-1] != ""
+		var kept, rejected []string
+		var issues []FsckIssue
+		changed := false
+		seen := map[string]string{}
+		lastID := ""
+		for i, raw := range lines {
+			line := strings.TrimSpace(raw)
+			if line == "" {
+				continue
+			}
+			number := i + 1
+			record, ok := decodeLine(line)
+			if !ok && !json.Valid([]byte(line)) {
+				changed = true
+				rejected = append(rejected, raw)
+				if at := strings.LastIndex(line, `{"id":`); at > 0 {
+					if salvaged, found := decodeLine(line[at:]); found {
+						issues = append(issues, FsckIssue{Kind: IssueCorruptLine, Log: key, Line: number, Detail: fmt.Sprintf("dropped %d bytes of a partial record before record %s", at, salvaged.ID)})
+						raw, record, ok = line[at:], salvaged, true
+					}
+				}
+				if !ok {
+					kind, detail := IssueCorruptLine, "not a record or a legacy payload"
+					if i == len(lines)-1 && unterminated {
+						kind, detail = IssueTruncatedLine, fmt.Sprintf("%d bytes of an unfinished append", len(raw))
+					}
+					issues = append(issues, FsckIssue{Kind: kind, Log: key, Line: number, Detail: detail})
+					continue
+				}
+			}
+			if ok {
+				if payload, dup := seen[record.ID]; dup {
+					if payload == record.Payload {
+						issues = append(issues, FsckIssue{Kind: IssueDuplicateID, Log: key, Line: number, Detail: "repeated record " + record.ID})
+						changed = true
+						rejected = append(rejected, raw)
+						continue
+					}
+					issues = append(issues, FsckIssue{Kind: IssueDuplicateID, Log: key, Line: number, Detail: "record " + record.ID + " reused with a different payload"})
+					changed = true
+				} else if record.ID <= lastID {
+					issues = append(issues, FsckIssue{Kind: IssueOutOfOrder, Log: key, Line: number, Detail: fmt.Sprintf("record %s follows %s", record.ID, lastID)})
+					changed = true
+				}
+				seen[record.ID] = record.Payload
+				lastID = max(lastID, record.ID)
+				maxSeq = max(maxSeq, record.Seq)
+			}
+			kept = append(kept, strings.TrimSpace(raw))
+		}
+		report.Records += len(kept)
+		if !changed || !repair {
+			for _, issue := range issues {
+				report.add(issue)
+			}
+			return nil
+		}
+
+		if len(rejected) > 0 {
+			f, err := os.OpenFile(path+rejectedSuffix, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+			if err != nil {
+				return err
+			}
+			_, err = f.WriteString(strings.Join(rejected, "\n") + "\n")
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := sortLines(kept); err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		for _, line := range kept {
+			buf.WriteString(line + "\n")
+		}
+		tmp := path + ".fsck"
+		if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return err
+		}
+		for _, issue := range issues {
+			issue.Repaired = true
+			report.add(issue)
+		}
+		return nil
+	})
+	return maxSeq, err
+}
+
+// fsckSequence checks that the sequence file is at or past every logged
+// sequence number.
+func (s *Store) fsckSequence(report *FsckReport, maxSeq int64, repair bool) error {
+	if maxSeq == 0 {
+		return nil
+	}
+	err := s.withThreadFileLock(".sequence", func() error {
+		path := filepath.Join(s.RootDir, "sequence")
+		detail := ""
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			detail = "missing"
+		case err != nil:
+			return err
+		default:
+			high, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+			if err != nil {
+				detail = "corrupt"
+			} else if high < maxSeq {
+				detail = fmt.Sprintf("at %d", high)
+			}
+		}
+		if detail == "" {
+			return nil
+		}
+		issue := FsckIssue{Kind: IssueSequence, Detail: fmt.Sprintf("sequence file %s but logs reach %d", detail, maxSeq)}
+		if repair {
+			tmp := path + ".tmp"
+			if err := os.WriteFile(tmp, []byte(strconv.FormatInt(maxSeq, 10)+"\n"), 0o644); err != nil {
+				return err
+			}
+			if err := os.Rename(tmp, path); err != nil {
+				return err
+			}
+			issue.Repaired = true
+		}
+		report.add(issue)
+		return nil
+	})
+	if err != nil || !repair {
+		return err
+	}
+	// Taken after the file lock is released: stamp holds seqMu while it
+	// waits for the file lock.
+	s.seqMu.Lock()
+	s.clock.seq = max(s.clock.seq, maxSeq)
+	s.seqMu.Unlock()
+	return nil
+}
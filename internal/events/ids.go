@@ -0,0 +1,215 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// Event IDs are ULIDs, so string order is time order, and resume cursors
+// compare IDs as strings (see recordRange). The stores generate them with
+// nextID, which keeps every log's IDs strictly increasing in file order.
+
+// idFormatFile marks a JSONL store whose logs have all been through
+// MigrateIDs, so the scan runs once per store.
+const idFormatFile = "id-format"
+
+// legacyID is the ID decodeRecords gives the nth bare payload line.
+func legacyID(n int) string {
+	return fmt.Sprintf("LEGACY-%020d", n)
+}
+
+// nextID returns a new ULID that sorts after last, even when both fall in
+// the same millisecond or the clock has gone backwards since last was made.
+func nextID(last string) string {
+	return idAt(last, uint64(time.Now().UnixMilli()))
+}
+
+// idAt returns a ULID for ms that sorts after last. When last is a ULID from
+// the same or a later millisecond, it is last plus one.
+func idAt(last string, ms uint64) string {
+	id, err := ulid.New(ms, ulid.DefaultEntropy())
+	prev, parseErr := ulid.ParseStrict(last)
+	if parseErr == nil && (err != nil || id.Compare(prev) <= 0) {
+		return incrementID(prev).String()
+	}
+	if err != nil {
+		return ulid.Make().String()
+	}
+	return id.String()
+}
+
+func incrementID(id ulid.ULID) ulid.ULID {
+	for i := len(id) - 1; i >= 0; i-- {
+		id[i]++
+		if id[i] != 0 {
+			break
+		}
+	}
+	return id
+}
+
+// sortIDs gives every record an ID after the one before it, so ID order and
+// log order agree. Records that are in order keep their IDs; the others
+// (bare legacy payloads, plain or non-ULID IDs, duplicates, IDs that went
+// backwards) get a new ULID for their timestamp, and their old ID is kept as
+// LegacyID so cursors holding it still resolve. It returns how many records
+// changed.
+func sortIDs(records []Record) int {
+	changed := 0
+	last := ""
+	for i := range records {
+		record := &records[i]
+		if _, err := ulid.ParseStrict(record.ID); err == nil && record.ID > last {
+			last = record.ID
+			continue
+		}
+		ms := uint64(max(record.Time, 0))
+		if prev, err := ulid.ParseStrict(last); err == nil {
+			ms = max(ms, prev.Time())
+		}
+		record.LegacyID = record.ID
+		record.ID = idAt(last, ms)
+		last = record.ID
+		changed++
+	}
+	return changed
+}
+
+// sortLines applies sortIDs to the lines of a JSONL log in place. Lines whose
+// record gets a new ID are rewritten as JSON records; every other line,
+// including ones that are not records at all, is left as it is. Bare payload
+// lines are numbered as decodeRecords numbers them, so their LEGACY IDs carry
+// over.
+func sortLines(lines []string) (int, error) {
+	var records []Record
+	var at []int
+	legacy := 0
+	for i, raw := range lines {
+		line := strings.TrimSpace(raw)
+		if line == "" {
+			continue
+		}
+		record, ok := decodeLine(line)
+		if !ok {
+			legacy++
+			if !json.Valid([]byte(line)) {
+				continue
+			}
+			record = Record{ID: legacyID(legacy), Payload: line}
+		}
+		records = append(records, record)
+		at = append(at, i)
+	}
+	before := make([]string, len(records))
+	for i, record := range records {
+		before[i] = record.ID
+	}
+	changed := sortIDs(records)
+	for i, record := range records {
+		if record.ID == before[i] {
+			continue
+		}
+		line, err := json.Marshal(record)
+		if err != nil {
+			return 0, err
+		}
+		lines[at[i]] = string(line)
+	}
+	return changed, nil
+}
+
+// MigrateIDs rewrites every log whose IDs are not ULIDs in file order, such
+// as logs with bare payload lines from early versions; see sortIDs. It runs
+// once per store, recording completion in <root>/id-format, and returns how
+// many logs it rewrote.
+func (s *Store) MigrateIDs() (int, error) {
+	marker := filepath.Join(s.RootDir, idFormatFile)
+	if _, err := os.Stat(marker); err == nil {
+		return 0, nil
+	}
+	logs, err := s.Logs()
+	if err != nil {
+		return 0, err
+	}
+	migrated := 0
+	for _, log := range logs {
+		err := s.withThreadFileLock(log.Key, func() error {
+			path := s.filePath(log.Key)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			lines := strings.Split(string(data), "\n")
+			changed, err := sortLines(lines)
+			if err != nil || changed == 0 {
+				return err
+			}
+			tmp := path + ".migrate"
+			if err := os.WriteFile(tmp, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+				return err
+			}
+			migrated++
+			return os.Rename(tmp, path)
+		})
+		if err != nil {
+			return migrated, fmt.Errorf("migrate %s: %w", log.Key, err)
+		}
+	}
+	if err := os.MkdirAll(s.RootDir, 0o755); err != nil {
+		return migrated, err
+	}
+	return migrated, os.WriteFile(marker, []byte("ulid\n"), 0o644)
+}
+
+// tailChunk is how much of a log lastRecord reads per step.
+const tailChunk = 4096
+
+// lastRecord returns the last record in f (nil when it has none), reading
+// backwards from the end past any lines that hold no record, and whether the
+// file ends in a newline.
+func lastRecord(f *os.File) (*Record, bool, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, false, err
+	}
+	var tail []byte
+	terminated := true
+	for offset := info.Size(); offset > 0; {
+		n := min(int64(tailChunk), offset)
+		offset -= n
+		chunk := make([]byte, n)
+		if _, err := f.ReadAt(chunk, offset); err != nil {
+			return nil, false, err
+		}
+		if tail == nil {
+			terminated = chunk[n-1] == '\n'
+		}
+		tail = append(chunk, tail...)
+		// Only the part after the first newline is known to be whole lines,
+		// unless the start of the file has been reached.
+		whole := tail
+		if offset > 0 {
+			i := bytes.IndexByte(tail, '\n')
+			if i < 0 {
+				continue
+			}
+			whole = tail[i+1:]
+		}
+		lines := strings.Split(string(whole), "\n")
+		for _, line := range slices.Backward(lines) {
+			if record, ok := decodeLine(strings.TrimSpace(line)); ok {
+				return &record, terminated, nil
+			}
+		}
+		tail = tail[:len(tail)-len(whole)]
+	}
+	return nil, terminated, nil
+}
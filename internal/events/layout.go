@@ -0,0 +1,159 @@
+package events
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Store layouts. A flat store keeps every log in RootDir; a sharded one puts
+// each log in one of 256 subdirectories named by the first two hex digits of
+// the SHA-256 of its key. The hash spreads the logs evenly: Codex thread IDs
+// are UUIDv7s, whose leading characters are a timestamp, so a plain ID
+// prefix would put every recent thread in the same directory. Lock files
+// stay in RootDir in both layouts; they exist only while a write runs.
+const (
+	LayoutFlat    = "flat"
+	LayoutSharded = "sharded"
+)
+
+// layoutFile records the layout a store's logs are in. A store without one
+// is flat.
+const layoutFile = "layout"
+
+// ReadLayout returns the layout the store under rootDir was last arranged in
+// by Relayout.
+func ReadLayout(rootDir string) string {
+	data, err := os.ReadFile(filepath.Join(rootDir, layoutFile))
+	if err != nil || strings.TrimSpace(string(data)) != LayoutSharded {
+		return LayoutFlat
+	}
+	return LayoutSharded
+}
+
+// shardDir is the subdirectory a sharded store keeps key's log in.
+func shardDir(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:1])
+}
+
+// isShardDir reports whether name is a directory shardDir can return.
+func isShardDir(name string) bool {
+	if len(name) != 2 {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil && strings.ToLower(name) == name
+}
+
+// logFile is a log found on disk, wherever the layout put it.
+type logFile struct {
+	key  string
+	path string
+	info os.FileInfo
+}
+
+// logFiles lists the logs in RootDir and in its shard directories, so logs
+// are found in either layout and partway through a Relayout.
+func (s *Store) logFiles() ([]logFile, error) {
+	entries, err := os.ReadDir(s.RootDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var files []logFile
+	add := func(dir string, entries []os.DirEntry) {
+		for _, entry := range entries {
+			key, ok := strings.CutSuffix(entry.Name(), ".jsonl")
+			if !ok || entry.IsDir() {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			files = append(files, logFile{key: key, path: filepath.Join(dir, entry.Name()), info: info})
+		}
+	}
+	add(s.RootDir, entries)
+	for _, entry := range entries {
+		if !entry.IsDir() || !isShardDir(entry.Name()) {
+			continue
+		}
+		dir := filepath.Join(s.RootDir, entry.Name())
+		shard, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		add(dir, shard)
+	}
+	return files, nil
+}
+
+// Relayout moves every log (and its Fsck .rejected file) to where layout
+// keeps it and records the layout, after which the store uses it. Each log
+// is moved under its lock, and a Relayout interrupted by a crash finishes
+// on the next run, but every process sharing the store must use the same
+// layout: switch it while they are stopped. It returns how many logs moved.
+func (s *Store) Relayout(layout string) (int, error) {
+	if layout == "" {
+		layout = LayoutFlat
+	}
+	if layout != LayoutFlat && layout != LayoutSharded {
+		return 0, fmt.Errorf("unknown event store layout %q", layout)
+	}
+	s.Layout = layout
+	if ReadLayout(s.RootDir) == layout {
+		return 0, nil
+	}
+	files, err := s.logFiles()
+	if err != nil {
+		return 0, err
+	}
+	moved := 0
+	for _, file := range files {
+		target := s.filePath(file.key)
+		if file.path == target {
+			continue
+		}
+		err := s.withThreadFileLock(file.key, func() error {
+			if _, err := os.Stat(target); err == nil {
+				return fmt.Errorf("both %s and %s exist", file.path, target)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			if err := os.Rename(file.path, target); err != nil {
+				return err
+			}
+			if err := os.Rename(file.path+rejectedSuffix, target+rejectedSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			moved++
+			return nil
+		})
+		if err != nil {
+			return moved, fmt.Errorf("move %s: %w", file.key, err)
+		}
+	}
+	if layout == LayoutFlat {
+		// Remove the emptied shard directories; Remove leaves any that
+		// still hold something.
+		entries, _ := os.ReadDir(s.RootDir)
+		for _, entry := range entries {
+			if entry.IsDir() && isShardDir(entry.Name()) {
+				_ = os.Remove(filepath.Join(s.RootDir, entry.Name()))
+			}
+		}
+	}
+	if err := os.MkdirAll(s.RootDir, 0o755); err != nil {
+		return moved, err
+	}
+	return moved, os.WriteFile(filepath.Join(s.RootDir, layoutFile), []byte(layout+"\n"), 0o644)
+}
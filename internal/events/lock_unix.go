@@ -0,0 +1,41 @@
+//go:build !windows
+
+package events
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// acquireFileLock takes the lock at path by creating it as a directory,
+// which is atomic on every local and network filesystem, unlike flock over
+// NFS. Locks older than lockStaleDuration (see lockAge) were left by crashed
+// processes and are broken.
+func acquireFileLock(path string, network bool) (func(), error) {
+	deadline := time.Now().Add(lockTimeout)
+	var probed time.Time
+	for {
+		err := os.Mkdir(path, 0o755)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		// Reading the server clock costs a write, so a network lock's age
+		// is checked once a second.
+		if info, statErr := os.Stat(path); statErr == nil && (!network || time.Since(probed) >= time.Second) {
+			probed = time.Now()
+			if lockAge(path, info.ModTime(), network) > lockStaleDuration {
+				_ = os.RemoveAll(path)
+				continue
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil, errLockTimeout
+		}
+		time.Sleep(lockPollInterval)
+	}
+	return func() { _ = os.RemoveAll(path) }, nil
+}
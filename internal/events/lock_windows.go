@@ -0,0 +1,56 @@
+package events
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+	errorLockViolation      = syscall.Errno(33)
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+// acquireFileLock takes an exclusive LockFileEx lock on path. Directory
+// locks are unreliable on Windows, where a lock directory another process
+// still has open cannot be removed; file locks are also released by the OS
+// when their holder crashes, so there is nothing stale to break. SMB shares
+// enforce the lock on the file server, so network paths need nothing else.
+func acquireFileLock(path string, _ bool) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	handle := f.Fd()
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		var overlapped syscall.Overlapped
+		r, _, callErr := procLockFileEx.Call(handle, lockfileExclusiveLock|lockfileFailImmediately, 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+		if r != 0 {
+			break
+		}
+		if !errors.Is(callErr, errorLockViolation) {
+			f.Close()
+			return nil, callErr
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, errLockTimeout
+		}
+		time.Sleep(lockPollInterval)
+	}
+	return func() {
+		var overlapped syscall.Overlapped
+		_, _, _ = procUnlockFileEx.Call(handle, 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+		f.Close()
+	}, nil
+}
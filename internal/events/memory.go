@@ -0,0 +1,160 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Memory is a Storage that keeps every log in process memory. It suits tests
+// and throwaway servers; nothing survives a restart.
+type Memory struct {
+	// Chain makes Append store the hash of the previous record in each new
+	// one, as Store.Chain does.
+	Chain bool
+
+	mu      sync.Mutex
+	clock   clock
+	threads map[string]*memoryLog
+}
+
+type memoryLog struct {
+	records []Record
+	size    int64
+	modTime time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{threads: map[string]*memoryLog{}}
+}
+
+func (m *Memory) Key(threadID string) string {
+	return threadIDSanitizer.ReplaceAllString(threadID, "_")
+}
+
+func (m *Memory) Append(threadID, payload string) (Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := m.Key(threadID)
+	log := m.threads[key]
+	if log == nil {
+		log = &memoryLog{}
+		m.threads[key] = log
+	}
+	var last *Record
+	lastID := ""
+	if n := len(log.records); n > 0 {
+		last = &log.records[n-1]
+		lastID = last.ID
+	}
+	seq, ts := m.clock.next()
+	record := Record{ID: nextID(lastID), Seq: seq, Time: ts, Payload: payload}
+	if m.Chain {
+		record.Prev = chainPrev(last)
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return Record{}, err
+	}
+	log.records = append(log.records, record)
+	log.size += int64(len(line)) + 1
+	log.modTime = time.Now()
+	return record, nil
+}
+
+func (m *Memory) ReadRange(threadID, afterID string, limit int) ([]Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	log := m.threads[m.Key(threadID)]
+	if log == nil {
+		return []Record{}, nil
+	}
+	return recordRange(log.records, afterID, limit), nil
+}
+
+// Rehydrate backfills finished turns from a thread/read result that the log
+// is missing; see events.Rehydrate.
+func (m *Memory) Rehydrate(threadID string, readResult map[string]any) error {
+	return Rehydrate(m, threadID, readResult)
+}
+
+func (m *Memory) Delete(threadID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.threads, m.Key(threadID))
+	return nil
+}
+
+func (m *Memory) Logs() ([]ThreadLog, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	logs := make([]ThreadLog, 0, len(m.threads))
+	for key, log := range m.threads {
+		logs = append(logs, ThreadLog{Key: key, Size: log.size, ModTime: log.modTime})
+	}
+	return logs, nil
+}
+
+func (m *Memory) ExportLog(threadID string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	log := m.threads[m.Key(threadID)]
+	if log == nil {
+		return nil, nil
+	}
+	return encodeRecords(log.records)
+}
+
+func (m *Memory) ImportLog(threadID string, data []byte, overwrite bool) error {
+	records, err := decodeRecords(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if sortIDs(records) > 0 {
+		if data, err = encodeRecords(records); err != nil {
+			return err
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := m.Key(threadID)
+	if _, exists := m.threads[key]; exists && !overwrite {
+		return ErrLogExists
+	}
+	for _, record := range records {
+		m.clock.seq = max(m.clock.seq, record.Seq)
+	}
+	m.threads[key] = &memoryLog{records: records, size: int64(len(data)), modTime: time.Now()}
+	return nil
+}
+
+func (m *Memory) RewriteLog(threadID string, rewrite func(data []byte) ([]byte, error)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	log := m.threads[m.Key(threadID)]
+	if log == nil {
+		return nil
+	}
+	data, err := encodeRecords(log.records)
+	if err != nil {
+		return err
+	}
+	rewritten, err := rewrite(data)
+	if err != nil || rewritten == nil {
+		return err
+	}
+	records, err := decodeRecords(bytes.NewReader(rewritten))
+	if err != nil {
+		return err
+	}
+	log.records, log.size, log.modTime = records, int64(len(rewritten)), time.Now()
+	return nil
+}
+
+func (m *Memory) Cleanup() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.threads = map[string]*memoryLog{}
+	return nil
+}
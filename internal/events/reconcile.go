@@ -0,0 +1,146 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Provenance sources for synthesized events.
+const (
+	SourceThreadRead    = "thread/read"
+	SourceHistoryImport = "history-import"
+)
+
+// Provenance is attached under the "darkhold" key of events darkhold built
+// from a thread/read result rather than received from the agent live.
+// TurnIndex is the turn's position in that result, since backfilled turns are
+// appended after whatever the log already held.
+type Provenance struct {
+	Synthesized bool   `json:"synthesized"`
+	Source      string `json:"source"`
+	TurnIndex   int    `json:"turnIndex"`
+}
+
+// TurnEvents replays thread/read turns as the notifications a live session
+// would have produced: turn/started, one item/completed per item and
+// turn/completed, each marked with its provenance. Turns without an ID get
+// "<source>-turn-N".
+func TurnEvents(threadID string, turns []any, source string) []string {
+	var payloads []string
+	for i, raw := range turns {
+		payloads = append(payloads, turnEvents(threadID, i, raw, source)...)
+	}
+	return payloads
+}
+
+func turnEvents(threadID string, index int, raw any, source string) []string {
+	provenance := Provenance{Synthesized: true, Source: source, TurnIndex: index}
+	marshal := func(method string, params map[string]any) string {
+		data, _ := json.Marshal(map[string]any{"method": method, "params": params, "darkhold": provenance})
+		return string(data)
+	}
+	turn, _ := raw.(map[string]any)
+	turnID, _ := turn["id"].(string)
+	if turnID == "" {
+		turnID = fmt.Sprintf("%s-turn-%d", source, index+1)
+	}
+	payloads := []string{marshal("turn/started", map[string]any{
+		"threadId": threadID,
+		"turnId":   turnID,
+		"turn":     map[string]any{"id": turnID, "status": "inProgress"},
+	})}
+	items, _ := turn["items"].([]any)
+	for _, item := range items {
+		payloads = append(payloads, marshal("item/completed", map[string]any{"threadId": threadID, "turnId": turnID, "item": item}))
+	}
+	status, _ := turn["status"].(string)
+	if status == "" {
+		status = "completed"
+	}
+	return append(payloads, marshal("turn/completed", map[string]any{
+		"threadId": threadID,
+		"turnId":   turnID,
+		"turn":     map[string]any{"id": turnID, "status": status, "error": turn["error"]},
+	}))
+}
+
+// MissingTurnEvents compares a thread/read result with a thread's log and
+// returns synthesized events for finished turns the log lacks. A turn with an
+// ID is missing when no logged turn/started or turn/completed carries that ID;
+// turns without IDs are matched by position against the turns the log has
+// started. In-progress turns are left to the live stream.
+func MissingTurnEvents(threadID string, records []Record, readResult map[string]any) []string {
+	thread, _ := readResult["thread"].(map[string]any)
+	turns, _ := thread["turns"].([]any)
+	if len(turns) == 0 {
+		return nil
+	}
+	logged := map[string]bool{}
+	started := 0
+	for _, record := range records {
+		var event struct {
+			Method string `json:"method"`
+			Params struct {
+				TurnID string `json:"turnId"`
+				Turn   struct {
+					ID string `json:"id"`
+				} `json:"turn"`
+			} `json:"params"`
+		}
+		if json.Unmarshal([]byte(record.Payload), &event) != nil {
+			continue
+		}
+		if event.Method != "turn/started" && event.Method != "turn/completed" {
+			continue
+		}
+		if event.Method == "turn/started" {
+			started++
+		}
+		for _, id := range []string{event.Params.TurnID, event.Params.Turn.ID} {
+			if id != "" {
+				logged[id] = true
+			}
+		}
+	}
+
+	var payloads []string
+	for i, raw := range turns {
+		turn, _ := raw.(map[string]any)
+		if status, _ := turn["status"].(string); status == "inProgress" {
+			continue
+		}
+		if id, _ := turn["id"].(string); id != "" {
+			if logged[id] {
+				continue
+			}
+		} else if i < started {
+			continue
+		}
+		payloads = append(payloads, turnEvents(threadID, i, raw, SourceThreadRead)...)
+	}
+	return payloads
+}
+
+// rehydrateMu serializes Rehydrate so concurrent thread/read calls do not
+// backfill the same turn twice. Rehydration only follows thread/read and
+// thread/resume, so one lock for every thread is enough.
+var rehydrateMu sync.Mutex
+
+// Rehydrate appends the events MissingTurnEvents reports for a thread's log
+// in st. Raw events already in the log are never rewritten. Backends use it
+// to implement Storage.Rehydrate.
+func Rehydrate(st Storage, threadID string, readResult map[string]any) error {
+	rehydrateMu.Lock()
+	defer rehydrateMu.Unlock()
+	records, err := st.ReadRange(threadID, "", 0)
+	if err != nil {
+		return err
+	}
+	for _, payload := range MissingTurnEvents(threadID, records, readResult) {
+		if _, err := st.Append(threadID, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
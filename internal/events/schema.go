@@ -0,0 +1,138 @@
+package events
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// SchemaVersion is the version of the event envelope darkhold stores and
+// streams. Version 1 is the agent's JSON-RPC notification passed through as
+// received ({method, params}), plus darkhold's own darkhold/* events. Shims
+// keep that shape stable when the agent protocol changes; a change darkhold
+// cannot express as a shim bumps the version.
+const SchemaVersion = 1
+
+// Shim rewrites one upstream method into the stable schema: Rename replaces
+// the method name and Moves relocates fields, given as dot paths rooted at
+// the message ("params.item.id"). Moves whose source is missing are skipped,
+// so a shim also accepts messages already in the stable shape.
+type Shim struct {
+	Name   string
+	Method string
+	Rename string
+	Moves  []FieldMove
+}
+
+type FieldMove struct {
+	From string
+	To   string
+}
+
+// DefaultShims are applied to every agent message. The agent protocol has
+// not diverged from schema version 1 yet, so there are none.
+var DefaultShims []Shim
+
+// Translator applies shims to upstream messages by method.
+type Translator struct {
+	byMethod map[string][]Shim
+	names    []string
+}
+
+func NewTranslator(shims []Shim) *Translator {
+	t := &Translator{byMethod: map[string][]Shim{}, names: []string{}}
+	for _, shim := range shims {
+		t.byMethod[shim.Method] = append(t.byMethod[shim.Method], shim)
+		t.names = append(t.names, shim.Name)
+	}
+	return t
+}
+
+// Shims returns the names of the configured shims.
+func (t *Translator) Shims() []string {
+	return append([]string(nil), t.names...)
+}
+
+// Normalize rewrites msg in place with the shims registered for its method
+// and returns the names of those that changed it. A changed message gets
+// "darkhold": {"schema", "upstreamMethod", "shims"} so consumers can tell it
+// was translated; callers must re-encode it.
+func (t *Translator) Normalize(msg map[string]any) []string {
+	method, _ := msg["method"].(string)
+	shims := t.byMethod[method]
+	if len(shims) == 0 {
+		return nil
+	}
+	var applied []string
+	for _, shim := range shims {
+		changed := false
+		for _, move := range shim.Moves {
+			if value, ok := cutPath(msg, move.From); ok {
+				setPath(msg, move.To, value)
+				changed = true
+			}
+		}
+		if shim.Rename != "" && shim.Rename != method {
+			msg["method"] = shim.Rename
+			changed = true
+		}
+		if changed {
+			applied = append(applied, shim.Name)
+		}
+	}
+	if len(applied) > 0 {
+		marker, _ := msg["darkhold"].(map[string]any)
+		if marker == nil {
+			marker = map[string]any{}
+		}
+		marker["schema"] = SchemaVersion
+		marker["upstreamMethod"] = method
+		marker["shims"] = applied
+		msg["darkhold"] = marker
+	}
+	return applied
+}
+
+// NormalizeLine is Normalize for an encoded message. It returns line
+// unchanged when no shim applies.
+func (t *Translator) NormalizeLine(line string, msg map[string]any) string {
+	if len(t.Normalize(msg)) == 0 {
+		return line
+	}
+	encoded, err := json.Marshal(msg)
+	if err != nil {
+		return line
+	}
+	return string(encoded)
+}
+
+func cutPath(msg map[string]any, path string) (any, bool) {
+	parts := strings.Split(path, ".")
+	node := msg
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := node[part].(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		node = next
+	}
+	last := parts[len(parts)-1]
+	value, ok := node[last]
+	if ok {
+		delete(node, last)
+	}
+	return value, ok
+}
+
+func setPath(msg map[string]any, path string, value any) {
+	parts := strings.Split(path, ".")
+	node := msg
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := node[part].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			node[part] = next
+		}
+		node = next
+	}
+	node[parts[len(parts)-1]] = value
+}
@@ -0,0 +1,44 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTranslatorRenamesMethodsAndMovesFields(t *testing.T) {
+	translator := NewTranslator([]Shim{{
+		Name:   "agent-message-chunk",
+		Method: "item/agentMessage/chunk",
+		Rename: "item/agentMessage/delta",
+		Moves:  []FieldMove{{From: "params.text", To: "params.delta"}, {From: "params.item.id", To: "params.itemId"}},
+	}})
+
+	line := `{"method":"item/agentMessage/chunk","params":{"text":"hi","item":{"id":"m1"}}}`
+	var msg map[string]any
+	_ = json.Unmarshal([]byte(line), &msg)
+	normalized := translator.NormalizeLine(line, msg)
+
+	var got struct {
+		Method string         `json:"method"`
+		Params map[string]any `json:"params"`
+		Marker map[string]any `json:"darkhold"`
+	}
+	if err := json.Unmarshal([]byte(normalized), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Method != "item/agentMessage/delta" || got.Params["delta"] != "hi" || got.Params["itemId"] != "m1" || got.Params["text"] != nil {
+		t.Fatalf("unexpected normalized message %s", normalized)
+	}
+	if got.Marker["upstreamMethod"] != "item/agentMessage/chunk" || got.Marker["schema"] != float64(SchemaVersion) {
+		t.Fatalf("unexpected darkhold marker %v", got.Marker)
+	}
+
+	passthrough := `{"method":"turn/started","params":{"turnId":"t1"}}`
+	_ = json.Unmarshal([]byte(passthrough), &msg)
+	if out := translator.NormalizeLine(passthrough, msg); out != passthrough {
+		t.Fatalf("expected unshimmed messages to pass through, got %s", out)
+	}
+	if names := translator.Shims(); len(names) != 1 || names[0] != "agent-message-chunk" {
+		t.Fatalf("unexpected shim names %v", names)
+	}
+}
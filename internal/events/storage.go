@@ -0,0 +1,157 @@
+package events
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"darkhold-go/internal/netfs"
+)
+
+// Storage is an append-only event log per thread. Store (JSONL files) is the
+// default; Memory keeps everything in process; internal/pgstore shares logs
+// between replicas through Postgres. Implementations assign each
+// appended record a ULID, a store-wide increasing Seq and a non-decreasing
+// Time, and must be safe for concurrent use.
+type Storage interface {
+	Append(threadID, payload string) (Record, error)
+	// ReadRange returns records with IDs after afterID (from the start when
+	// empty), at most limit when limit is positive.
+	ReadRange(threadID, afterID string, limit int) ([]Record, error)
+	// Rehydrate reconciles a thread's log with a thread/read result by
+	// appending synthesized events for finished turns the log is missing.
+	Rehydrate(threadID string, readResult map[string]any) error
+	// Delete removes a thread's log; deleting a missing log is not an error.
+	Delete(threadID string) error
+
+	// Key returns the name a thread's log is listed under in Logs.
+	Key(threadID string) string
+	Logs() ([]ThreadLog, error)
+	// ExportLog returns a thread's log as JSONL records (nil when it has
+	// none); ImportLog replaces it with such a log, returning ErrLogExists
+	// when one is present and overwrite is false.
+	ExportLog(threadID string) ([]byte, error)
+	ImportLog(threadID string, data []byte, overwrite bool) error
+	// RewriteLog replaces a thread's exported log with what rewrite makes of
+	// it, holding off appends in between so none is lost. A nil result, or
+	// a thread without a log, leaves the log as it is.
+	RewriteLog(threadID string, rewrite func(data []byte) ([]byte, error)) error
+	// Cleanup removes everything the store holds. Stores shared with other
+	// processes may make it a no-op.
+	Cleanup() error
+}
+
+var (
+	_ Storage = (*Store)(nil)
+	_ Storage = (*Memory)(nil)
+)
+
+// Backend names accepted by Open.
+const (
+	BackendJSONL  = "jsonl"
+	BackendMemory = "memory"
+)
+
+// Open returns the named backend. rootDir is only used (and created) by the
+// JSONL backend, which is also the default for an empty name; opening it
+// picks up the layout last set by Relayout, checks for a network filesystem
+// and runs MigrateIDs.
+func Open(backend, rootDir string) (Storage, error) {
+	switch backend {
+	case "", BackendJSONL:
+		if err := os.MkdirAll(rootDir, 0o755); err != nil {
+			return nil, err
+		}
+		store := NewStore(rootDir)
+		store.Layout = ReadLayout(rootDir)
+		store.Network = netfs.Detect(rootDir).Network
+		if _, err := store.MigrateIDs(); err != nil {
+			return nil, fmt.Errorf("migrate event IDs: %w", err)
+		}
+		return store, nil
+	case BackendMemory:
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown event store backend %q", backend)
+	}
+}
+
+// RangeScanner is implemented by stores that can stream a log without
+// holding it in memory; Store does.
+type RangeScanner interface {
+	ScanRange(threadID, afterID string, fn func(Record) error) error
+}
+
+// scanPageSize is how many records ScanRange reads at a time from stores
+// that are not RangeScanners.
+const scanPageSize = 500
+
+// ScanRange calls fn with each of a thread's records after afterID, in
+// order, stopping at the first error fn returns. Stores that cannot stream
+// are read in pages through ReadRange.
+func ScanRange(store Storage, threadID, afterID string, fn func(Record) error) error {
+	if scanner, ok := store.(RangeScanner); ok {
+		return scanner.ScanRange(threadID, afterID, fn)
+	}
+	for {
+		records, err := store.ReadRange(threadID, afterID, scanPageSize)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		if len(records) < scanPageSize {
+			return nil
+		}
+		afterID = records[len(records)-1].ID
+	}
+}
+
+// Payloads returns the payloads of records, in order.
+func Payloads(records []Record) []string {
+	payloads := make([]string, 0, len(records))
+	for _, record := range records {
+		payloads = append(payloads, record.Payload)
+	}
+	return payloads
+}
+
+// recordRange returns records after afterID, compared as strings since IDs
+// are ULIDs in log order. A cursor naming a record's LegacyID resumes after
+// that record.
+func recordRange(records []Record, afterID string, limit int) []Record {
+	for _, record := range records {
+		if afterID != "" && record.LegacyID == afterID {
+			afterID = record.ID
+			break
+		}
+	}
+	out := records[:0:0]
+	for _, record := range records {
+		if afterID != "" && record.ID <= afterID {
+			continue
+		}
+		out = append(out, record)
+		if limit > 0 && len(out) == limit {
+			break
+		}
+	}
+	return out
+}
+
+// clock stamps records with a sequence number and a timestamp that never goes
+// backwards, even if the wall clock does.
+type clock struct {
+	seq      int64
+	lastTime int64
+}
+
+func (c *clock) next() (int64, int64) {
+	c.seq++
+	now := max(time.Now().UnixMilli(), c.lastTime)
+	c.lastTime = now
+	return c.seq, now
+}
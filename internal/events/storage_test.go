@@ -0,0 +1,119 @@
+package events
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestScanRangeMatchesReadRange(t *testing.T) {
+	for _, backend := range []string{BackendJSONL, BackendMemory} {
+		t.Run(backend, func(t *testing.T) {
+			store, err := Open(backend, filepath.Join(t.TempDir(), "events"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Cleanup()
+			// More than a page, so Memory is read through several.
+			for range 2*scanPageSize + 7 {
+				if _, err := store.Append("t", `{"method":"x"}`); err != nil {
+					t.Fatal(err)
+				}
+			}
+			all, _ := store.ReadRange("t", "", 0)
+			for _, cursor := range []string{"", all[0].ID, all[scanPageSize].ID, all[len(all)-1].ID} {
+				want, _ := store.ReadRange("t", cursor, 0)
+				var got []Record
+				if err := ScanRange(store, "t", cursor, func(record Record) error {
+					got = append(got, record)
+					return nil
+				}); err != nil {
+					t.Fatal(err)
+				}
+				if len(got) != len(want) || (len(got) > 0 && (got[0].ID != want[0].ID || got[len(got)-1].ID != want[len(want)-1].ID)) {
+					t.Fatalf("after %q: scanned %d records, read %d", cursor, len(got), len(want))
+				}
+			}
+
+			stop := errors.New("stop")
+			seen := 0
+			if err := ScanRange(store, "t", "", func(Record) error {
+				seen++
+				if seen == 3 {
+					return stop
+				}
+				return nil
+			}); !errors.Is(err, stop) || seen != 3 {
+				t.Fatalf("expected the scan to stop at the callback's error, got %v after %d", err, seen)
+			}
+			if err := ScanRange(store, "missing", "", func(Record) error { return stop }); err != nil {
+				t.Fatalf("expected a missing log to scan as empty, got %v", err)
+			}
+		})
+	}
+}
+
+func TestStorageBackends(t *testing.T) {
+	for _, backend := range []string{BackendJSONL, BackendMemory} {
+		t.Run(backend, func(t *testing.T) {
+			store, err := Open(backend, filepath.Join(t.TempDir(), "events"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Cleanup()
+
+			var appended []Record
+			for _, payload := range []string{`{"method":"one"}`, `{"method":"two"}`, `{"method":"three"}`} {
+				record, err := store.Append("thread/a", payload)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(appended) > 0 && (record.Seq <= appended[len(appended)-1].Seq || record.ID <= appended[len(appended)-1].ID) {
+					t.Fatalf("stamps must increase: %+v after %+v", record, appended[len(appended)-1])
+				}
+				appended = append(appended, record)
+			}
+
+			all, err := store.ReadRange("thread/a", "", 0)
+			if err != nil || len(all) != 3 || all[2].Payload != `{"method":"three"}` {
+				t.Fatalf("unexpected records %+v %v", all, err)
+			}
+			tail, _ := store.ReadRange("thread/a", appended[0].ID, 1)
+			if len(tail) != 1 || tail[0].ID != appended[1].ID {
+				t.Fatalf("unexpected range %+v", tail)
+			}
+
+			logs, _ := store.Logs()
+			if len(logs) != 1 || logs[0].Key != store.Key("thread/a") || logs[0].Size == 0 {
+				t.Fatalf("unexpected logs %+v", logs)
+			}
+
+			exported, err := store.ExportLog("thread/a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.ImportLog("thread/a", exported, false); !errors.Is(err, ErrLogExists) {
+				t.Fatalf("expected ErrLogExists, got %v", err)
+			}
+			if err := store.ImportLog("thread-b", exported, false); err != nil {
+				t.Fatal(err)
+			}
+			if copied, _ := store.ReadRange("thread-b", "", 0); len(copied) != 3 || copied[0].ID != appended[0].ID {
+				t.Fatalf("unexpected imported records %+v", copied)
+			}
+			if next, _ := store.Append("thread-b", `{"method":"four"}`); next.Seq <= appended[2].Seq {
+				t.Fatalf("sequence went backwards after import: %d", next.Seq)
+			}
+
+			if err := store.Delete("thread/a"); err != nil {
+				t.Fatal(err)
+			}
+			if records, _ := store.ReadRange("thread/a", "", 0); len(records) != 0 {
+				t.Fatalf("expected deleted log to be empty, got %+v", records)
+			}
+		})
+	}
+	if _, err := Open("sqlite", ""); err == nil {
+		t.Fatal("expected unknown backend to be rejected")
+	}
+}
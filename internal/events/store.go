@@ -2,13 +2,17 @@ package events
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/oklog/ulid/v2"
@@ -16,13 +20,39 @@ import (
 
 var threadIDSanitizer = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
 
+// Store is the default Storage: one JSONL file per thread under RootDir,
+// guarded by per-thread lock files so several processes can share it.
 type Store struct {
 	RootDir string
+	// Layout is LayoutFlat (also when empty) or LayoutSharded; see
+	// Relayout to change it for a store that has logs.
+	Layout string
+	// Network is set for a RootDir on a network filesystem (see Open).
+	// Lock ages are then measured by the file server's clock and appends
+	// are written at an explicit offset and synced before the lock is
+	// released, since O_APPEND is emulated per client on NFS.
+	Network bool
+	// Chain makes Append store the hash of the previous record in each new
+	// one (see VerifyChain).
+	Chain bool
+
+	seqMu sync.Mutex
+	clock clock
 }
 
+// Record is one stored event. Seq is a store-wide sequence assigned at append
+// time and Time the server clock in Unix milliseconds. Lines written before
+// sequences existed report Seq 0 and the time encoded in their ULID.
+// LegacyID is the ID a record had before MigrateIDs gave it a sortable one;
+// it is still accepted as a resume cursor. Prev is set in chained logs: the
+// RecordHash of the record before it.
 type Record struct {
-	ID      string `json:"id"`
-	Payload string `json:"payload"`
+	ID       string `json:"id"`
+	Seq      int64  `json:"seq,omitempty"`
+	Time     int64  `json:"ts,omitempty"`
+	Payload  string `json:"payload"`
+	LegacyID string `json:"legacyId,omitempty"`
+	Prev     string `json:"prev,omitempty"`
 }
 
 func NewStore(rootDir string) *Store {
@@ -31,6 +61,9 @@ func NewStore(rootDir string) *Store {
 
 func (s *Store) filePath(threadID string) string {
 	safe := threadIDSanitizer.ReplaceAllString(threadID, "_")
+	if s.Layout == LayoutSharded {
+		return filepath.Join(s.RootDir, shardDir(safe), safe+".jsonl")
+	}
 	return filepath.Join(s.RootDir, safe+".jsonl")
 }
 
@@ -45,57 +78,148 @@ const (
 	lockPollInterval  = 8 * time.Millisecond
 )
 
+// errLockTimeout is returned when a lock stays held past lockTimeout.
+var errLockTimeout = errors.New("timed out acquiring lock")
+
+// clockProbeFile is written to read the file server's clock; see lockAge.
+const clockProbeFile = ".clock"
+
+// lockAge is how long ago the lock at path, last modified at modTime, was
+// taken. On a network filesystem the modification time comes from the file
+// server's clock, which may be well off from ours, so it is compared with
+// the time of a write to clockProbeFile beside the lock instead. A lock
+// whose age cannot be measured counts as fresh.
+func lockAge(path string, modTime time.Time, network bool) time.Duration {
+	if !network {
+		return time.Since(modTime)
+	}
+	probe := filepath.Join(filepath.Dir(path), clockProbeFile)
+	if err := os.WriteFile(probe, []byte("\n"), 0o644); err != nil {
+		return 0
+	}
+	info, err := os.Stat(probe)
+	if err != nil {
+		return 0
+	}
+	return info.ModTime().Sub(modTime)
+}
+
 func (s *Store) withThreadFileLock(threadID string, fn func() error) error {
-	lock := s.lockPath(threadID)
-	deadline := time.Now().Add(lockTimeout)
-	for {
-		err := os.Mkdir(lock, 0o755)
-		if err == nil {
-			break
+	unlock, err := acquireFileLock(s.lockPath(threadID), s.Network)
+	if err != nil {
+		return fmt.Errorf("%w for thread %s", err, threadID)
+	}
+	defer unlock()
+	return fn()
+}
+
+// Append stores payload and returns the record with its event ID, sequence
+// and timestamp. Stamps are assigned while the thread file is locked, so file
+// order always matches sequence and ID order: the ID is generated after the
+// last one in the file, whichever process wrote it. A final line left
+// unfinished by a crash is terminated first, so the new record stays
+// readable; Fsck removes the fragment. With Chain set, the record also holds
+// the hash of the last one.
+func (s *Store) Append(threadID, payload string) (Record, error) {
+	var record Record
+	err := s.withThreadFileLock(threadID, func() error {
+		path := s.filePath(threadID)
+		flags := os.O_CREATE | os.O_APPEND | os.O_RDWR
+		if s.Network {
+			flags &^= os.O_APPEND
+		}
+		f, err := os.OpenFile(path, flags, 0o644)
+		if errors.Is(err, os.ErrNotExist) && s.Layout == LayoutSharded {
+			// The thread's shard directory does not exist yet.
+			if err = os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
+				f, err = os.OpenFile(path, flags, 0o644)
+			}
 		}
-		if !errors.Is(err, os.ErrExist) {
+		if err != nil {
 			return err
 		}
-		// Break stale locks left by crashed processes.
-		if info, statErr := os.Stat(lock); statErr == nil {
-			if time.Since(info.ModTime()) > lockStaleDuration {
-				_ = os.RemoveAll(lock)
-				continue
-			}
+		defer f.Close()
+		last, terminated, err := lastRecord(f)
+		if err != nil {
+			return err
+		}
+		seq, ts, err := s.stamp()
+		if err != nil {
+			return err
+		}
+		lastID := ""
+		if last != nil {
+			lastID = last.ID
+		}
+		record = Record{ID: nextID(lastID), Seq: seq, Time: ts, Payload: payload}
+		if s.Chain {
+			record.Prev = chainPrev(last)
+		}
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if !terminated {
+			line = append([]byte{'\n'}, line...)
+		}
+		if !s.Network {
+			_, err = f.Write(append(line, '\n'))
+			return err
+		}
+		// The size is fresh: NFS revalidates attributes on open, and the
+		// lock keeps other writers out until the data is on the server.
+		info, err := f.Stat()
+		if err != nil {
+			return err
 		}
-		if time.Now().After(deadline) {
-			return fmt.Errorf("timed out acquiring lock for thread %s", threadID)
+		if _, err := f.WriteAt(append(line, '\n'), info.Size()); err != nil {
+			return err
 		}
-		time.Sleep(lockPollInterval)
+		return f.Sync()
+	})
+	if err != nil {
+		return Record{}, err
 	}
-	defer func() {
-		_ = os.RemoveAll(lock)
-	}()
-	return fn()
+	return record, nil
 }
 
-func (s *Store) Append(threadID, payload string) (string, error) {
-	eventID, err := nextULID()
-	if err != nil {
-		return "", err
-	}
-	line := eventID + ":" + payload
-	err = s.withThreadFileLock(threadID, func() error {
-		f, err := os.OpenFile(s.filePath(threadID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
-		if err != nil {
+// stamp returns the next global sequence number and timestamp. The number
+// is taken from <root>/sequence, shared by every process using the same
+// store, under its lock on every call, so appends from several processes are
+// numbered in the order they happen.
+func (s *Store) stamp() (int64, int64, error) {
+	s.seqMu.Lock()
+	defer s.seqMu.Unlock()
+	var seq, ts int64
+	err := s.withThreadFileLock(".sequence", func() error {
+		path := filepath.Join(s.RootDir, "sequence")
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			last, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+			if err != nil {
+				return fmt.Errorf("corrupt sequence file %s: %w", path, err)
+			}
+			s.clock.seq = max(s.clock.seq, last)
+		case !errors.Is(err, os.ErrNotExist):
 			return err
 		}
-		defer f.Close()
-		_, err = f.WriteString(line + "\n")
-		return err
+		seq, ts = s.clock.next()
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, []byte(strconv.FormatInt(seq, 10)+"\n"), 0o644); err != nil {
+			return err
+		}
+		return os.Rename(tmp, path)
 	})
 	if err != nil {
-		return "", err
+		return 0, 0, err
 	}
-	return eventID, nil
+	return seq, ts, nil
 }
 
-func (s *Store) ReadRecords(threadID string) ([]Record, error) {
+// ReadRange returns a thread's records with IDs after afterID (all records
+// when empty), at most limit of them when limit is positive.
+func (s *Store) ReadRange(threadID, afterID string, limit int) ([]Record, error) {
 	f, err := os.Open(s.filePath(threadID))
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -104,9 +228,73 @@ func (s *Store) ReadRecords(threadID string) ([]Record, error) {
 		return nil, err
 	}
 	defer f.Close()
+	records, err := decodeRecords(f)
+	if err != nil {
+		return nil, err
+	}
+	return recordRange(records, afterID, limit), nil
+}
 
+// ScanRange calls fn with each of a thread's records after afterID, as
+// ReadRange would return them, reading the log one line at a time. A cursor
+// that is not a ULID may be a record's LegacyID, which takes a first pass
+// over the log to resolve.
+func (s *Store) ScanRange(threadID, afterID string, fn func(Record) error) error {
+	open := func() (*os.File, error) {
+		f, err := os.Open(s.filePath(threadID))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return f, err
+	}
+	if _, err := ulid.ParseStrict(afterID); afterID != "" && err != nil {
+		f, err := open()
+		if f == nil {
+			return err
+		}
+		errFound := errors.New("found")
+		err = scanRecords(f, func(record Record) error {
+			if record.LegacyID == afterID {
+				afterID = record.ID
+				return errFound
+			}
+			return nil
+		})
+		f.Close()
+		if err != nil && !errors.Is(err, errFound) {
+			return err
+		}
+	}
+	f, err := open()
+	if f == nil {
+		return err
+	}
+	defer f.Close()
+	return scanRecords(f, func(record Record) error {
+		if afterID != "" && record.ID <= afterID {
+			return nil
+		}
+		return fn(record)
+	})
+}
+
+// decodeRecords parses a JSONL log, including lines written by older
+// versions ("<ulid>:<payload>" and bare payloads).
+func decodeRecords(r io.Reader) ([]Record, error) {
 	records := make([]Record, 0, 128)
-	scanner := bufio.NewScanner(f)
+	if err := scanRecords(r, func(record Record) error {
+		records = append(records, record)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// scanRecords is decodeRecords one record at a time; it stops at the first
+// error fn returns.
+func scanRecords(r io.Reader, fn func(Record) error) error {
+	scanner := bufio.NewScanner(r)
 	legacyIndex := 0
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
@@ -114,52 +302,167 @@ func (s *Store) ReadRecords(threadID string) ([]Record, error) {
 			continue
 		}
 
-		if len(line) > 27 && line[26] == ':' {
-			records = append(records, Record{
-				ID:      line[:26],
-				Payload: line[27:],
-			})
-			continue
+		record, ok := decodeLine(line)
+		if !ok {
+			legacyIndex++
+			record = Record{
+				ID:      legacyID(legacyIndex),
+				Payload: line,
+			}
 		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
 
-		var record Record
-		if err := json.Unmarshal([]byte(line), &record); err == nil && strings.TrimSpace(record.ID) != "" && record.Payload != "" {
-			records = append(records, record)
-			continue
+// decodeLine parses a JSON record or an "<ulid>:<payload>" line. Anything
+// else is reported as not a record; older versions wrote bare payloads.
+func decodeLine(line string) (Record, bool) {
+	if len(line) > 27 && line[26] == ':' {
+		record := Record{ID: line[:26], Payload: line[27:]}
+		if id, err := ulid.ParseStrict(record.ID); err == nil {
+			record.Time = int64(id.Time())
 		}
-		legacyIndex++
-		records = append(records, Record{
-			ID:      fmt.Sprintf("LEGACY-%020d", legacyIndex),
-			Payload: line,
-		})
+		return record, true
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	var record Record
+	if err := json.Unmarshal([]byte(line), &record); err == nil && strings.TrimSpace(record.ID) != "" && record.Payload != "" {
+		return record, true
 	}
-	return records, nil
+	return Record{}, false
 }
 
+// Read returns every payload in a thread's log.
 func (s *Store) Read(threadID string) ([]string, error) {
-	records, err := s.ReadRecords(threadID)
+	records, err := s.ReadRange(threadID, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	return Payloads(records), nil
+}
+
+// Rehydrate backfills finished turns from a thread/read result that the log
+// is missing; see events.Rehydrate.
+func (s *Store) Rehydrate(threadID string, readResult map[string]any) error {
+	return Rehydrate(s, threadID, readResult)
+}
+
+// ThreadLog describes one thread's event log on disk. Key is the sanitized
+// file name stem, which equals the thread ID for IDs made of [a-zA-Z0-9._-].
+type ThreadLog struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Key returns the log key a thread's events are stored under.
+func (s *Store) Key(threadID string) string {
+	return threadIDSanitizer.ReplaceAllString(threadID, "_")
+}
+
+// Logs lists every thread log in the store.
+func (s *Store) Logs() ([]ThreadLog, error) {
+	files, err := s.logFiles()
 	if err != nil {
 		return nil, err
 	}
-	lines := make([]string, 0, len(records))
+	logs := make([]ThreadLog, 0, len(files))
+	for _, file := range files {
+		logs = append(logs, ThreadLog{Key: file.key, Size: file.info.Size(), ModTime: file.info.ModTime()})
+	}
+	return logs, nil
+}
+
+// ErrLogExists is returned by ImportLog when the thread already has a log and
+// overwrite was not requested.
+var ErrLogExists = errors.New("thread already has an event log")
+
+// ExportLog returns the raw contents of a thread's log, or nil when it has
+// none.
+func (s *Store) ExportLog(threadID string) ([]byte, error) {
+	var data []byte
+	err := s.withThreadFileLock(threadID, func() error {
+		var err error
+		data, err = os.ReadFile(s.filePath(threadID))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	})
+	return data, err
+}
+
+// ImportLog writes a log previously returned by ExportLog. Records keep their
+// original IDs and stamps, unless they are out of order (see sortIDs); later
+// appends are sequenced after them.
+func (s *Store) ImportLog(threadID string, data []byte, overwrite bool) error {
+	records, err := decodeRecords(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if sortIDs(records) > 0 {
+		if data, err = encodeRecords(records); err != nil {
+			return err
+		}
+	}
+	s.seqMu.Lock()
 	for _, record := range records {
-		lines = append(lines, record.Payload)
+		s.clock.seq = max(s.clock.seq, record.Seq)
 	}
-	return lines, nil
+	s.seqMu.Unlock()
+	return s.withThreadFileLock(threadID, func() error {
+		path := s.filePath(threadID)
+		if _, err := os.Stat(path); err == nil && !overwrite {
+			return ErrLogExists
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		tmp := path + ".import"
+		if err := os.WriteFile(tmp, data, 0o644); err != nil {
+			return err
+		}
+		return os.Rename(tmp, path)
+	})
 }
 
-func (s *Store) RehydrateFromThreadRead(threadID string, readResult map[string]any) error {
-	return nil
+// RewriteLog holds the thread file lock from reading the log to renaming
+// its replacement into place, so appends wait for the rewrite.
+func (s *Store) RewriteLog(threadID string, rewrite func(data []byte) ([]byte, error)) error {
+	return s.withThreadFileLock(threadID, func() error {
+		path := s.filePath(threadID)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		rewritten, err := rewrite(data)
+		if err != nil || rewritten == nil {
+			return err
+		}
+		tmp := path + ".rewrite"
+		if err := os.WriteFile(tmp, rewritten, 0o644); err != nil {
+			return err
+		}
+		return os.Rename(tmp, path)
+	})
 }
 
-func (s *Store) Cleanup() error {
-	return os.RemoveAll(s.RootDir)
+// Delete removes a thread's event log. Deleting a missing log is not an error.
+func (s *Store) Delete(threadID string) error {
+	return s.withThreadFileLock(threadID, func() error {
+		err := os.Remove(s.filePath(threadID))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	})
 }
 
-func nextULID() (string, error) {
-	id := ulid.Make()
-	return id.String(), nil
+func (s *Store) Cleanup() error {
+	return os.RemoveAll(s.RootDir)
 }
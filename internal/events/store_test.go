@@ -2,10 +2,17 @@ package events
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
 )
 
 func TestAppendAndRead(t *testing.T) {
@@ -57,6 +64,96 @@ func TestConcurrentAppend(t *testing.T) {
 	}
 }
 
+func TestNetworkStoreAppends(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "events")
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	store := NewStore(root)
+	store.Network = true
+	if runtime.GOOS != "windows" {
+		// A lock left by a crash is broken by the server clock's measure.
+		lock := store.lockPath("thread-n")
+		if err := os.Mkdir(lock, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		old := time.Now().Add(-time.Hour)
+		if err := os.Chtimes(lock, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(v int) {
+			defer wg.Done()
+			if _, err := store.Append("thread-n", `{"method":"event","seq":`+jsonNumber(v)+`}`); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	records, err := store.ReadRange("thread-n", "", 0)
+	if err != nil || len(records) != 20 {
+		t.Fatalf("expected 20 records, got %d %v", len(records), err)
+	}
+	for i := 1; i < len(records); i++ {
+		if records[i].ID <= records[i-1].ID || records[i].Seq <= records[i-1].Seq {
+			t.Fatalf("records out of order at %d: %+v", i, records)
+		}
+	}
+	if runtime.GOOS != "windows" {
+		if _, err := os.Stat(filepath.Join(root, clockProbeFile)); err != nil {
+			t.Fatalf("expected the stale lock to be measured by the clock probe: %v", err)
+		}
+	}
+}
+
+func TestAppendStampsGlobalSequence(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "events")
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// A line in the pre-sequence format keeps its ULID time and reports seq 0.
+	legacy := "01HZX3Q0W7V6K9J6X1Q2T3Y4Z5:" + `{"method":"old"}` + "\n"
+	if err := os.WriteFile(filepath.Join(root, "thread-a.jsonl"), []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore(root)
+	first, err := store.Append("thread-a", `{"method":"one"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.Append("thread-b", `{"method":"two"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Seq < 1 || second.Seq != first.Seq+1 || second.Time < first.Time || first.Time == 0 {
+		t.Fatalf("unexpected stamps: %+v %+v", first, second)
+	}
+
+	// A new store on the same root continues after the previous one.
+	reopened := NewStore(root)
+	third, err := reopened.Append("thread-a", `{"method":"three"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.Seq <= second.Seq {
+		t.Fatalf("expected sequence to keep increasing across stores, got %d after %d", third.Seq, second.Seq)
+	}
+
+	records, err := reopened.ReadRange("thread-a", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0].Seq != 0 || records[0].Time == 0 || records[1] != first || records[2].Payload != `{"method":"three"}` {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+}
+
 func TestRehydrateFromThreadRead(t *testing.T) {
 	root := filepath.Join(t.TempDir(), "events")
 	if err := os.MkdirAll(root, 0o755); err != nil {
@@ -85,7 +182,7 @@ func TestRehydrateFromThreadRead(t *testing.T) {
 			},
 		},
 	}
-	if err := store.RehydrateFromThreadRead("thread-3", readResult); err != nil {
+	if err := store.Rehydrate("thread-3", readResult); err != nil {
 		t.Fatal(err)
 	}
 
@@ -93,12 +190,141 @@ func TestRehydrateFromThreadRead(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(events) != 1 {
-		t.Fatalf("rehydrate should not transform events; got %d event(s)", len(events))
+	// stale, then turn/started + 3 items + turn/completed, then turn/started +
+	// turn/completed for the failed turn.
+	if len(events) != 8 {
+		t.Fatalf("expected both turns to be backfilled after the raw event; got %d event(s)", len(events))
 	}
-	if !contains(events[0], "stale") {
+	if events[0] != `{"method":"stale"}` {
 		t.Fatal("original raw event should remain unchanged")
 	}
+	if !contains(events[1], `"synthesized":true`) || !contains(events[1], `"source":"thread/read"`) || !contains(events[7], `"turnIndex":1`) {
+		t.Fatalf("backfilled events should carry provenance: %s / %s", events[1], events[7])
+	}
+
+	if err := store.Rehydrate("thread-3", readResult); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := store.Read("thread-3"); len(again) != len(events) {
+		t.Fatalf("rehydrating twice should not duplicate turns; got %d event(s)", len(again))
+	}
+}
+
+func TestRehydrateKeepsLiveTurns(t *testing.T) {
+	store := NewMemory()
+	_, _ = store.Append("t", `{"method":"turn/started","params":{"threadId":"t","turnId":"live","turn":{"id":"live","status":"inProgress"}}}`)
+	_, _ = store.Append("t", `{"method":"item/agentMessage/delta","params":{"threadId":"t","turnId":"live","delta":"raw"}}`)
+	_, _ = store.Append("t", `{"method":"turn/completed","params":{"threadId":"t","turnId":"live","turn":{"id":"live","status":"completed"}}}`)
+	readResult := map[string]any{"thread": map[string]any{"turns": []any{
+		map[string]any{"id": "old", "status": "completed", "items": []any{}},
+		map[string]any{"id": "live", "status": "completed", "items": []any{map[string]any{"type": "agentMessage", "text": "summary"}}},
+		map[string]any{"id": "running", "status": "inProgress", "items": []any{}},
+	}}}
+	if err := store.Rehydrate("t", readResult); err != nil {
+		t.Fatal(err)
+	}
+	records, _ := store.ReadRange("t", "", 0)
+	events := Payloads(records)
+	if len(events) != 5 || !contains(events[1], `"delta":"raw"`) {
+		t.Fatalf("expected raw live turn plus backfilled old turn only; got %v", events)
+	}
+	if !contains(events[3], `"turnId":"old"`) || contains(strings.Join(events, "\n"), "running") {
+		t.Fatalf("unexpected backfill: %v", events)
+	}
+}
+
+func TestLogsAndDelete(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "events")
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	store := NewStore(root)
+	if _, err := store.Append("thread/5", `{"method":"turn/started"}`); err != nil {
+		t.Fatal(err)
+	}
+	logs, err := store.Logs()
+	if err != nil || len(logs) != 1 || logs[0].Key != store.Key("thread/5") || logs[0].Size == 0 {
+		t.Fatalf("unexpected logs: %+v %v", logs, err)
+	}
+	if err := store.Delete("thread/5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("thread/5"); err != nil {
+		t.Fatalf("deleting a missing log should succeed: %v", err)
+	}
+	if logs, _ := store.Logs(); len(logs) != 0 {
+		t.Fatalf("expected no logs after delete, got %+v", logs)
+	}
+}
+
+func TestRelayoutMovesLogsBetweenLayouts(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "events")
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	store := NewStore(root)
+	threads := []string{"thread-1", "thread-2", "thread/3"}
+	for _, threadID := range threads {
+		if _, err := store.Append(threadID, `{"method":"turn/started"}`); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(store.filePath("thread-1")+rejectedSuffix, []byte("junk\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	moved, err := store.Relayout(LayoutSharded)
+	if err != nil || moved != len(threads) {
+		t.Fatalf("expected %d logs moved, got %d %v", len(threads), moved, err)
+	}
+	if ReadLayout(root) != LayoutSharded {
+		t.Fatal("expected the sharded layout to be recorded")
+	}
+	sharded := filepath.Join(root, shardDir("thread-1"), "thread-1.jsonl")
+	if _, err := os.Stat(sharded); err != nil {
+		t.Fatalf("expected the log in its shard directory: %v", err)
+	}
+	if _, err := os.Stat(sharded + rejectedSuffix); err != nil {
+		t.Fatalf("expected the rejected lines to move with the log: %v", err)
+	}
+
+	// A store opened later picks up the layout, and new threads go to
+	// their shard directories.
+	reopened, err := Open(BackendJSONL, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.Append("thread-4", `{"method":"turn/started"}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, shardDir("thread-4"), "thread-4.jsonl")); err != nil {
+		t.Fatalf("expected a new log in its shard directory: %v", err)
+	}
+	records, err := reopened.ReadRange("thread/3", "", 0)
+	if err != nil || len(records) != 1 {
+		t.Fatalf("expected the moved log to stay readable, got %d %v", len(records), err)
+	}
+	logs, err := reopened.Logs()
+	if err != nil || len(logs) != 4 {
+		t.Fatalf("expected 4 logs, got %+v %v", logs, err)
+	}
+	if moved, err := reopened.(*Store).Relayout(LayoutSharded); err != nil || moved != 0 {
+		t.Fatalf("expected nothing to move, got %d %v", moved, err)
+	}
+
+	moved, err = reopened.(*Store).Relayout(LayoutFlat)
+	if err != nil || moved != 4 {
+		t.Fatalf("expected 4 logs moved back, got %d %v", moved, err)
+	}
+	entries, _ := os.ReadDir(root)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			t.Fatalf("expected no shard directories left, found %s", entry.Name())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "thread-1.jsonl"+rejectedSuffix)); err != nil {
+		t.Fatalf("expected the rejected lines back beside the log: %v", err)
+	}
 }
 
 func TestCleanup(t *testing.T) {
@@ -146,3 +372,326 @@ func stringContains(s, sub string) bool {
 	}
 	return false
 }
+
+func TestExportAndImportLog(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "events")
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	store := NewStore(root)
+	first, err := store.Append("thread-1", `{"method":"turn/started"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := store.ExportLog("thread-1")
+	if err != nil || len(data) == 0 {
+		t.Fatalf("unexpected export: %q %v", data, err)
+	}
+	if err := store.ImportLog("thread-1", data, false); !errors.Is(err, ErrLogExists) {
+		t.Fatalf("expected ErrLogExists, got %v", err)
+	}
+	if err := store.Delete("thread-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ImportLog("thread-1", data, false); err != nil {
+		t.Fatal(err)
+	}
+	records, err := store.ReadRange("thread-1", "", 0)
+	if err != nil || len(records) != 1 || records[0].ID != first.ID {
+		t.Fatalf("expected imported record to keep its ID, got %+v %v", records, err)
+	}
+}
+
+func TestCompactDropsDeltasOfCompletedItems(t *testing.T) {
+	store := NewMemory()
+	for _, payload := range []string{
+		`{"method":"turn/started","params":{"turnId":"t1"}}`,
+		`{"method":"item/agentMessage/delta","params":{"itemId":"done","delta":"hel"}}`,
+		`{"method":"item/commandExecution/outputDelta","params":{"itemId":"done","delta":"lo"}}`,
+		`{"method":"item/agentMessage/delta","params":{"itemId":"open","delta":"still streaming"}}`,
+		`{"method":"item/completed","params":{"item":{"id":"done","type":"agentMessage","text":"hello"}}}`,
+	} {
+		if _, err := store.Append("thread-1", payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before, _ := store.ReadRange("thread-1", "", 0)
+
+	result, err := Compact(store, "thread-1")
+	if err != nil || result.Dropped != 2 || result.Records != 3 || result.BytesDropped <= 0 {
+		t.Fatalf("unexpected compaction %+v %v", result, err)
+	}
+	after, _ := store.ReadRange("thread-1", "", 0)
+	if len(after) != 3 || after[0].ID != before[0].ID || after[1].ID != before[3].ID || after[2].ID != before[4].ID {
+		t.Fatalf("unexpected compacted log %+v", after)
+	}
+
+	if again, err := Compact(store, "thread-1"); err != nil || again.Dropped != 0 {
+		t.Fatalf("expected a second compaction to be a no-op, got %+v %v", again, err)
+	}
+	if missing, err := Compact(store, "no-such-thread"); err != nil || missing.Records != 0 {
+		t.Fatalf("unexpected compaction of a missing log %+v %v", missing, err)
+	}
+}
+
+// appendWhileRewriting appends an event from another goroutine while a
+// rewrite of the same log is under way.
+type appendWhileRewriting struct {
+	Storage
+	appended chan error
+}
+
+func (a *appendWhileRewriting) RewriteLog(threadID string, rewrite func(data []byte) ([]byte, error)) error {
+	return a.Storage.RewriteLog(threadID, func(data []byte) ([]byte, error) {
+		go func() {
+			_, err := a.Storage.Append(threadID, `{"method":"thread/archived","params":{}}`)
+			a.appended <- err
+		}()
+		time.Sleep(50 * time.Millisecond)
+		return rewrite(data)
+	})
+}
+
+func TestCompactKeepsEventsAppendedWhileItRuns(t *testing.T) {
+	for _, backend := range []string{BackendJSONL, BackendMemory} {
+		t.Run(backend, func(t *testing.T) {
+			inner, err := Open(backend, filepath.Join(t.TempDir(), "events"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer inner.Cleanup()
+			for _, payload := range []string{
+				`{"method":"item/agentMessage/delta","params":{"itemId":"done","delta":"hi"}}`,
+				`{"method":"item/completed","params":{"item":{"id":"done","type":"agentMessage","text":"hi"}}}`,
+			} {
+				if _, err := inner.Append("thread-1", payload); err != nil {
+					t.Fatal(err)
+				}
+			}
+			store := &appendWhileRewriting{Storage: inner, appended: make(chan error, 1)}
+
+			if result, err := Compact(store, "thread-1"); err != nil || result.Dropped != 1 {
+				t.Fatalf("unexpected compaction %+v %v", result, err)
+			}
+			if err := <-store.appended; err != nil {
+				t.Fatal(err)
+			}
+			records, _ := inner.ReadRange("thread-1", "", 0)
+			if len(records) != 2 || !strings.Contains(records[1].Payload, "thread/archived") {
+				t.Fatalf("expected the event appended during compaction to follow the compacted log, got %+v", records)
+			}
+		})
+	}
+}
+
+func TestFsckRepairsLogs(t *testing.T) {
+	root := t.TempDir()
+	store := NewStore(root)
+	var lines []string
+	for _, payload := range []string{`{"method":"turn/started"}`, `{"method":"item/completed"}`, `{"method":"turn/completed"}`} {
+		record, err := store.Append("thread-a", payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		line, _ := json.Marshal(record)
+		lines = append(lines, string(line))
+	}
+	// A duplicated first record, an append written onto a half-line, and an
+	// unfinished final append.
+	damaged := lines[0] + "\n" + lines[0] + "\n" + lines[1][:20] + lines[1] + "\n" + lines[2] + "\n" + lines[2][:15]
+	path := filepath.Join(root, "thread-a.jsonl")
+	if err := os.WriteFile(path, []byte(damaged), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lock := filepath.Join(root, "thread-b.lock")
+	if err := os.Mkdir(lock, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(lock, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "sequence"), []byte("1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := func(report FsckReport) []string {
+		var out []string
+		for _, issue := range report.Issues {
+			out = append(out, issue.Kind)
+		}
+		sort.Strings(out)
+		return out
+	}
+	want := []string{IssueCorruptLine, IssueDuplicateID, IssueOrphanedLock, IssueSequence, IssueTruncatedLine}
+	check, err := store.Fsck(false)
+	if err != nil || strings.Join(kinds(check), ",") != strings.Join(want, ",") || check.Unrepaired != 5 || check.Repaired != 0 {
+		t.Fatalf("unexpected check %+v %v", check, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != damaged {
+		t.Fatal("expected a check without repair to leave the log alone")
+	}
+
+	repaired, err := store.Fsck(true)
+	if err != nil || repaired.Repaired != 5 || repaired.Unrepaired != 0 || repaired.Records != 3 {
+		t.Fatalf("unexpected repair %+v %v", repaired, err)
+	}
+	records, err := store.ReadRange("thread-a", "", 0)
+	if err != nil || len(records) != 3 || records[1].Payload != `{"method":"item/completed"}` {
+		t.Fatalf("unexpected repaired log %+v %v", records, err)
+	}
+	if rejected, _ := os.ReadFile(path + rejectedSuffix); strings.Count(string(rejected), "\n") != 3 {
+		t.Fatalf("expected the dropped lines to be kept aside, got %q", rejected)
+	}
+	if _, err := os.Stat(lock); !os.IsNotExist(err) {
+		t.Fatalf("expected the orphaned lock to be removed: %v", err)
+	}
+	if again, err := store.Fsck(false); err != nil || len(again.Issues) != 0 {
+		t.Fatalf("expected a clean store after repair, got %+v %v", again, err)
+	}
+	if next, err := NewStore(root).Append("thread-a", `{"method":"next"}`); err != nil || next.Seq <= records[2].Seq {
+		t.Fatalf("expected appends to be sequenced after the repaired log, got %+v %v", next, err)
+	}
+}
+
+func TestMigrateIDsKeepsOldCursorsResumable(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "events")
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// Bare payloads, plain integer IDs of different widths and an in-order
+	// ULID line, as older versions and imports left them.
+	log := strings.Join([]string{
+		`{"method":"bare-1"}`,
+		`{"id":"9","payload":"{\"method\":\"nine\"}"}`,
+		`{"id":"10","payload":"{\"method\":\"ten\"}"}`,
+		"7ZZZZZZZZZ0000000000000000:" + `{"method":"far-future"}`,
+		`{"method":"bare-2"}`,
+	}, "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(root, "thread-a.jsonl"), []byte(log), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := NewStore(root).ReadRange("thread-a", "", 0)
+
+	storage, err := Open(BackendJSONL, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := storage.(*Store)
+	records, err := store.ReadRange("thread-a", "", 0)
+	if err != nil || len(records) != 5 {
+		t.Fatalf("unexpected migrated log %+v %v", records, err)
+	}
+	for i, record := range records {
+		if _, err := ulid.ParseStrict(record.ID); err != nil || (i > 0 && record.ID <= records[i-1].ID) {
+			t.Fatalf("expected increasing ULIDs, got %+v", records)
+		}
+		if record.Payload != before[i].Payload || (record.ID != before[i].ID && record.LegacyID != before[i].ID) {
+			t.Fatalf("record %d lost its payload or old ID: %+v, was %+v", i, record, before[i])
+		}
+	}
+	if records[3].ID != "7ZZZZZZZZZ0000000000000000" || records[3].LegacyID != "" {
+		t.Fatalf("expected an in-order ULID to be kept, got %+v", records[3])
+	}
+
+	// Cursors from before the migration resume after the record they named,
+	// even where string order would have skipped ("10" < "9") or replayed.
+	for i, cursor := range []string{before[0].ID, "9", "10", before[3].ID} {
+		tail, err := store.ReadRange("thread-a", cursor, 0)
+		if err != nil || len(tail) != 4-i || tail[0].Payload != before[i+1].Payload {
+			t.Fatalf("unexpected resume after %q: %+v %v", cursor, tail, err)
+		}
+		var scanned []Record
+		if err := store.ScanRange("thread-a", cursor, func(record Record) error {
+			scanned = append(scanned, record)
+			return nil
+		}); err != nil || len(scanned) != len(tail) || scanned[0].ID != tail[0].ID {
+			t.Fatalf("unexpected scan after %q: %+v %v", cursor, scanned, err)
+		}
+	}
+	next, err := store.Append("thread-a", `{"method":"new"}`)
+	if err != nil || next.ID <= records[4].ID {
+		t.Fatalf("expected appends to sort after the migrated log, got %+v %v", next, err)
+	}
+	if tail, _ := store.ReadRange("thread-a", records[4].LegacyID, 0); len(tail) != 1 || tail[0].ID != next.ID {
+		t.Fatalf("unexpected resume after the last legacy record: %+v", tail)
+	}
+
+	// The migration runs once per store.
+	if err := os.WriteFile(filepath.Join(root, "thread-b.jsonl"), []byte(`{"method":"bare"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if migrated, err := store.MigrateIDs(); err != nil || migrated != 0 {
+		t.Fatalf("expected a migrated store to be skipped, got %d %v", migrated, err)
+	}
+}
+
+func TestAppendKeepsSequenceOrderedAcrossStores(t *testing.T) {
+	root := t.TempDir()
+	stores := []*Store{NewStore(root), NewStore(root)}
+	var wg sync.WaitGroup
+	for _, store := range stores {
+		wg.Go(func() {
+			for range 25 {
+				if _, err := store.Append("thread-a", `{"method":"tick"}`); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+	records, err := stores[0].ReadRange("thread-a", "", 0)
+	if err != nil || len(records) != 50 {
+		t.Fatalf("expected 50 records, got %d %v", len(records), err)
+	}
+	for i := 1; i < len(records); i++ {
+		if records[i].Seq != records[i-1].Seq+1 {
+			t.Fatalf("sequence out of file order at %d: %d after %d", i, records[i].Seq, records[i-1].Seq)
+		}
+	}
+}
+
+func TestAppendKeepsIDsOrderedAcrossStores(t *testing.T) {
+	root := t.TempDir()
+	first, second := NewStore(root), NewStore(root)
+	// A clock-skewed writer left an ID far ahead of now, and crashed
+	// mid-append.
+	ahead := "7ZZZZZZZZZ000000000000000A"
+	if err := os.WriteFile(filepath.Join(root, "thread-a.jsonl"), []byte(`{"id":"`+ahead+`","payload":"{}"}`+"\n"+`{"id":"01J`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.Fsck(true); err != nil {
+		t.Fatal(err)
+	}
+	var last string
+	for i := range 20 {
+		store := first
+		if i%2 == 1 {
+			store = second
+		}
+		record, err := store.Append("thread-a", `{"method":"tick"}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 && record.ID <= ahead {
+			t.Fatalf("expected %s after %s", record.ID, ahead)
+		}
+		if record.ID <= last {
+			t.Fatalf("IDs went backwards: %s after %s", record.ID, last)
+		}
+		last = record.ID
+	}
+
+	// An append after an unfinished line still lands on its own line.
+	f, _ := os.OpenFile(filepath.Join(root, "thread-a.jsonl"), os.O_APPEND|os.O_WRONLY, 0o644)
+	_, _ = f.WriteString(`{"id":"01J`)
+	f.Close()
+	record, err := first.Append("thread-a", `{"method":"after-crash"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records, _ := first.ReadRange("thread-a", last, 0); len(records) != 2 || records[1].ID != record.ID {
+		t.Fatalf("expected the record after the fragment to be readable, got %+v", records)
+	}
+}
@@ -0,0 +1,573 @@
+// Package fakeagent is an in-process stand-in for `codex app-server`. It
+// speaks the same newline-delimited JSON-RPC over stdio-like pipes so the
+// server, the web client and load tests can run without the real agent.
+package fakeagent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Options are the chaos knobs of a fake agent.
+type Options struct {
+	// Latency delays each streamed step of a turn.
+	Latency time.Duration
+	// CrashRate is the probability (0-1) that a turn makes the process exit
+	// with status 1 before it completes.
+	CrashRate float64
+	// ApprovalRate is the probability (0-1) that a turn asks for a command
+	// approval before replying.
+	ApprovalRate float64
+	// Seed makes the random choices reproducible when non-zero.
+	Seed uint64
+	// HangFile, when set, makes the agent leave health pings unanswered for
+	// as long as the file exists, like a wedged app-server.
+	HangFile string
+}
+
+const (
+	// ExitCodeInterrupted mirrors a process stopped with SIGINT.
+	ExitCodeInterrupted = 130
+
+	// HangFileEnv names the environment variable the server reads
+	// Options.HangFile from.
+	HangFileEnv = "FAKE_AGENT_HANG"
+
+	// failTransientMarker in a prompt makes the first such turn fail with a
+	// dropped response stream, which darkhold retries.
+	failTransientMarker = "FAIL_TRANSIENT"
+
+	// legacyApprovalMarker in a prompt makes the turn ask for its approval
+	// with the legacy execCommandApproval method.
+	legacyApprovalMarker = "LEGACY_APPROVAL"
+)
+
+var nextPid atomic.Int64
+
+// Process is a running fake agent. Write requests to Stdin and read
+// responses and notifications from Stdout; Stderr carries log lines.
+type Process struct {
+	Stdin  io.WriteCloser
+	Stdout io.Reader
+	Stderr io.Reader
+
+	opts   Options
+	pid    int
+	stdinR *io.PipeReader
+	outW   *io.PipeWriter
+	errW   *io.PipeWriter
+
+	mu          sync.Mutex
+	rng         *rand.Rand
+	initialized bool
+	threads     map[string]*thread
+	threadOrder []string
+	approvals   map[int64]*pendingApproval
+	mcpServers  map[string]any
+	nextID      int64
+	turnCounter int
+	failedOnce  bool
+	exited      bool
+	exitCode    int
+	done        chan struct{}
+
+	writeMu sync.Mutex
+	logs    chan string
+}
+
+type thread struct {
+	ID        string `json:"id"`
+	Cwd       string `json:"cwd"`
+	Preview   string `json:"preview"`
+	CreatedAt int64  `json:"createdAt"`
+	UpdatedAt int64  `json:"updatedAt"`
+	Turns     []any  `json:"-"`
+}
+
+type pendingApproval struct {
+	threadID string
+	turnID   string
+	prompt   string
+}
+
+// Start launches a fake agent.
+func Start(opts Options) *Process {
+	stdinR, stdinW := io.Pipe()
+	outR, outW := io.Pipe()
+	errR, errW := io.Pipe()
+	seed := opts.Seed
+	if seed == 0 {
+		seed = uint64(time.Now().UnixNano())
+	}
+	p := &Process{
+		Stdin:      stdinW,
+		Stdout:     outR,
+		Stderr:     errR,
+		opts:       opts,
+		pid:        -int(nextPid.Add(1)),
+		stdinR:     stdinR,
+		outW:       outW,
+		errW:       errW,
+		rng:        rand.New(rand.NewPCG(seed, seed>>1)),
+		threads:    map[string]*thread{},
+		approvals:  map[int64]*pendingApproval{},
+		mcpServers: map[string]any{},
+		nextID:     9000,
+		done:       make(chan struct{}),
+		logs:       make(chan string, 256),
+	}
+	go p.writeLogs()
+	go p.serve()
+	return p
+}
+
+// Pid returns a negative pseudo process ID, unique within this process.
+func (p *Process) Pid() int { return p.pid }
+
+// Interrupt stops the agent as if it received SIGINT.
+func (p *Process) Interrupt() error {
+	p.exit(ExitCodeInterrupted)
+	return nil
+}
+
+// Kill stops the agent immediately.
+func (p *Process) Kill() error {
+	p.exit(-1)
+	return nil
+}
+
+// Wait blocks until the agent exits. It is safe to call concurrently.
+func (p *Process) Wait() error {
+	<-p.done
+	if code := p.ExitCode(); code != 0 {
+		return fmt.Errorf("fake agent exited with status %d", code)
+	}
+	return nil
+}
+
+// ExitCode returns the exit status once the agent has exited, otherwise -1.
+func (p *Process) ExitCode() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.exited {
+		return -1
+	}
+	return p.exitCode
+}
+
+func (p *Process) exit(code int) {
+	p.mu.Lock()
+	if p.exited {
+		p.mu.Unlock()
+		return
+	}
+	p.exited = true
+	p.exitCode = code
+	p.mu.Unlock()
+	_ = p.stdinR.Close()
+	_ = p.outW.Close()
+	_ = p.errW.Close()
+	close(p.done)
+}
+
+func (p *Process) isExited() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.exited
+}
+
+func (p *Process) serve() {
+	p.logf("fake agent started (latency=%s crashRate=%.2f approvalRate=%.2f)", p.opts.Latency, p.opts.CrashRate, p.opts.ApprovalRate)
+	scanner := bufio.NewScanner(p.stdinR)
+	scanner.Buffer(make([]byte, 1<<20), 1<<20)
+	for scanner.Scan() {
+		var msg map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			p.logf("malformed JSON: %v", err)
+			continue
+		}
+		p.handle(msg)
+	}
+	// Closing stdin ends the agent cleanly, like the real app-server.
+	p.exit(0)
+}
+
+func (p *Process) send(msg map[string]any) {
+	if p.isExited() {
+		return
+	}
+	encoded, _ := json.Marshal(msg)
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	_, _ = p.outW.Write(append(encoded, '\n'))
+}
+
+func (p *Process) notify(method string, params map[string]any) {
+	p.send(map[string]any{"method": method, "params": params})
+}
+
+// logf writes a line to Stderr. Logging is best-effort: lines are dropped
+// rather than block the agent on an unread pipe.
+func (p *Process) logf(format string, args ...any) {
+	select {
+	case p.logs <- fmt.Sprintf(format, args...) + "\n":
+	default:
+	}
+}
+
+func (p *Process) writeLogs() {
+	for {
+		select {
+		case line := <-p.logs:
+			if _, err := io.WriteString(p.errW, line); err != nil {
+				return
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+func (p *Process) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rng.Float64() < rate
+}
+
+func (p *Process) sleep() {
+	if p.opts.Latency > 0 {
+		time.Sleep(p.opts.Latency)
+	}
+}
+
+func (p *Process) handle(msg map[string]any) {
+	method, _ := msg["method"].(string)
+	idValue, hasID := msg["id"].(float64)
+	id := int64(idValue)
+	if method == "" {
+		if hasID {
+			p.handleApprovalResponse(id, msg)
+		}
+		return
+	}
+	params, _ := msg["params"].(map[string]any)
+	reply := func(result any) {
+		if hasID {
+			p.send(map[string]any{"id": id, "result": result})
+		}
+	}
+	fail := func(message string) {
+		if hasID {
+			p.send(map[string]any{"id": id, "error": map[string]any{"code": -32600, "message": message}})
+		}
+	}
+
+	switch method {
+	case "initialize":
+		p.mu.Lock()
+		already := p.initialized
+		p.initialized = true
+		p.mu.Unlock()
+		if already {
+			fail("Already initialized")
+			return
+		}
+		reply(map[string]any{"userAgent": "darkhold-fake-agent"})
+	case "thread/start":
+		cwd, _ := params["cwd"].(string)
+		reply(map[string]any{"thread": p.newThread(cwd)})
+	case "thread/list":
+		reply(map[string]any{"data": p.listThreads(), "nextCursor": nil})
+	case "thread/read", "thread/resume":
+		threadID, _ := params["threadId"].(string)
+		if method == "thread/resume" && threadID != "" {
+			p.loadThread(threadID)
+		}
+		view, ok := p.threadView(threadID, true)
+		if !ok {
+			fail("thread not found: " + threadID)
+			return
+		}
+		reply(map[string]any{"thread": view})
+	case "turn/start":
+		threadID, _ := params["threadId"].(string)
+		if threadID == "" {
+			fail("threadId is required")
+			return
+		}
+		// Accept threads this process has not seen, so a turn can start
+		// straight after the session that held the thread was reaped.
+		p.loadThread(threadID)
+		p.mu.Lock()
+		p.turnCounter++
+		turnID := fmt.Sprintf("fake-turn-%d", p.turnCounter)
+		p.mu.Unlock()
+		reply(map[string]any{"turn": map[string]any{"id": turnID, "status": "inProgress", "items": []any{}}})
+		go p.runTurn(threadID, turnID, inputText(params))
+	case "turn/interrupt":
+		threadID, _ := params["threadId"].(string)
+		turnID, _ := params["turnId"].(string)
+		reply(map[string]any{})
+		p.notify("turn/completed", map[string]any{"threadId": threadID, "turnId": turnID, "turn": map[string]any{"id": turnID, "status": "interrupted", "error": nil}})
+	case "config/read":
+		p.mu.Lock()
+		servers := make(map[string]any, len(p.mcpServers))
+		maps.Copy(servers, p.mcpServers)
+		p.mu.Unlock()
+		reply(map[string]any{"config": map[string]any{"mcp_servers": servers}, "origins": map[string]any{}})
+	case "config/value/write":
+		keyPath, _ := params["keyPath"].(string)
+		name, ok := strings.CutPrefix(keyPath, "mcp_servers.")
+		if !ok || name == "" || strings.Contains(name, ".") {
+			fail("unsupported keyPath: " + keyPath)
+			return
+		}
+		p.mu.Lock()
+		if value := params["value"]; value == nil {
+			delete(p.mcpServers, name)
+		} else {
+			p.mcpServers[name] = value
+		}
+		p.mu.Unlock()
+		reply(map[string]any{"status": "ok"})
+	case "mcpServerStatus/list":
+		reply(map[string]any{"data": p.mcpServerStatuses(), "nextCursor": nil})
+	case "darkhold/ping":
+		if p.hung() {
+			return
+		}
+		reply(map[string]any{})
+	default:
+		reply(map[string]any{})
+	}
+}
+
+func (p *Process) hung() bool {
+	if p.opts.HangFile == "" {
+		return false
+	}
+	_, err := os.Stat(p.opts.HangFile)
+	return err == nil
+}
+
+// mcpServerStatuses reports every configured, enabled MCP server as running
+// with one echo tool.
+func (p *Process) mcpServerStatuses() []any {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	statuses := []any{}
+	for name, raw := range p.mcpServers {
+		if server, _ := raw.(map[string]any); server["enabled"] == false {
+			continue
+		}
+		statuses = append(statuses, map[string]any{
+			"name":              name,
+			"tools":             map[string]any{"echo": map[string]any{"name": "echo", "inputSchema": map[string]any{"type": "object"}}},
+			"resources":         []any{},
+			"resourceTemplates": []any{},
+			"authStatus":        "unsupported",
+		})
+	}
+	return statuses
+}
+
+func (p *Process) newThread(cwd string) map[string]any {
+	if cwd == "" {
+		cwd = "/tmp"
+	}
+	now := time.Now().Unix()
+	p.mu.Lock()
+	t := &thread{ID: fmt.Sprintf("fake-thread-%d-%d", -p.pid, len(p.threadOrder)+1), Cwd: cwd, CreatedAt: now, UpdatedAt: now}
+	p.threads[t.ID] = t
+	p.threadOrder = append(p.threadOrder, t.ID)
+	p.mu.Unlock()
+	view, _ := p.threadView(t.ID, true)
+	return view
+}
+
+// loadThread adds an empty thread under an ID this process has not seen, as
+// an app-server resuming a thread recorded by an earlier process would.
+func (p *Process) loadThread(threadID string) {
+	now := time.Now().Unix()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.threads[threadID]; ok {
+		return
+	}
+	p.threads[threadID] = &thread{ID: threadID, Cwd: "/tmp", CreatedAt: now, UpdatedAt: now}
+	p.threadOrder = append(p.threadOrder, threadID)
+}
+
+func (p *Process) listThreads() []any {
+	p.mu.Lock()
+	ids := append([]string(nil), p.threadOrder...)
+	p.mu.Unlock()
+	out := make([]any, 0, len(ids))
+	for _, id := range slices.Backward(ids) {
+		view, _ := p.threadView(id, false)
+		out = append(out, view)
+	}
+	return out
+}
+
+func (p *Process) threadView(threadID string, withTurns bool) (map[string]any, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t, ok := p.threads[threadID]
+	if !ok {
+		return nil, false
+	}
+	view := map[string]any{"id": t.ID, "cwd": t.Cwd, "preview": t.Preview, "createdAt": t.CreatedAt, "updatedAt": t.UpdatedAt}
+	if withTurns {
+		view["turns"] = append([]any{}, t.Turns...)
+	}
+	return view, true
+}
+
+func (p *Process) runTurn(threadID, turnID, prompt string) {
+	p.notify("turn/started", map[string]any{"threadId": threadID, "turn": map[string]any{"id": turnID, "status": "inProgress", "items": []any{}}})
+	p.sleep()
+	if p.failTransient(prompt) {
+		p.notify("turn/completed", map[string]any{"threadId": threadID, "turnId": turnID, "turn": map[string]any{
+			"id":     turnID,
+			"status": "failed",
+			"error":  map[string]any{"message": "stream disconnected before completion", "codexErrorInfo": "responseStreamDisconnected"},
+		}})
+		return
+	}
+	if p.roll(p.opts.CrashRate) {
+		p.logf("injected crash during %s", turnID)
+		p.exit(1)
+		return
+	}
+	if p.roll(p.opts.ApprovalRate) {
+		p.mu.Lock()
+		p.nextID++
+		requestID := p.nextID
+		p.approvals[requestID] = &pendingApproval{threadID: threadID, turnID: turnID, prompt: prompt}
+		p.mu.Unlock()
+		if strings.Contains(prompt, legacyApprovalMarker) {
+			p.send(map[string]any{
+				"id":     requestID,
+				"method": "execCommandApproval",
+				"params": map[string]any{
+					"threadId": threadID,
+					"callId":   turnID + "-command",
+					"command":  []any{"echo", "from-fake-agent"},
+					"cwd":      "/tmp",
+					"reason":   "fake agent approval",
+				},
+			})
+			return
+		}
+		p.send(map[string]any{
+			"id":     requestID,
+			"method": "item/commandExecution/requestApproval",
+			"params": map[string]any{
+				"threadId": threadID,
+				"turnId":   turnID,
+				"itemId":   turnID + "-command",
+				"command":  "echo from-fake-agent",
+				"reason":   "fake agent approval",
+			},
+		})
+		return
+	}
+	p.finishTurn(threadID, turnID, prompt, "")
+}
+
+// failTransient reports whether a turn on prompt should fail transiently:
+// only the first one carrying failTransientMarker does, so the retry succeeds.
+func (p *Process) failTransient(prompt string) bool {
+	if !strings.Contains(prompt, failTransientMarker) {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failedOnce {
+		return false
+	}
+	p.failedOnce = true
+	return true
+}
+
+func (p *Process) handleApprovalResponse(id int64, msg map[string]any) {
+	p.mu.Lock()
+	approval := p.approvals[id]
+	delete(p.approvals, id)
+	p.mu.Unlock()
+	if approval == nil {
+		return
+	}
+	result, _ := msg["result"].(map[string]any)
+	decision, _ := result["decision"].(string)
+	go p.finishTurn(approval.threadID, approval.turnID, approval.prompt, decision)
+}
+
+func (p *Process) finishTurn(threadID, turnID, prompt, decision string) {
+	reply := "fake reply: " + prompt
+	if decision == "decline" || decision == "cancel" || decision == "denied" || decision == "abort" {
+		reply = "fake reply: command was declined"
+	}
+	itemID := turnID + "-message"
+	p.notify("item/started", map[string]any{"threadId": threadID, "turnId": turnID, "item": map[string]any{"type": "agentMessage", "id": itemID, "text": ""}})
+	for _, word := range strings.SplitAfter(reply, " ") {
+		p.sleep()
+		p.notify("item/agentMessage/delta", map[string]any{"threadId": threadID, "turnId": turnID, "itemId": itemID, "delta": word})
+	}
+	p.notify("item/completed", map[string]any{"threadId": threadID, "turnId": turnID, "item": map[string]any{"type": "agentMessage", "id": itemID, "text": reply}})
+
+	tokens := int64(len(prompt)/4 + len(reply)/4 + 1)
+	p.notify("thread/tokenUsage/updated", map[string]any{
+		"threadId": threadID,
+		"turnId":   turnID,
+		"tokenUsage": map[string]any{
+			"last":  map[string]any{"totalTokens": tokens, "inputTokens": len(prompt)/4 + 1, "outputTokens": len(reply) / 4},
+			"total": map[string]any{"totalTokens": tokens},
+		},
+	})
+
+	p.mu.Lock()
+	if t := p.threads[threadID]; t != nil {
+		if t.Preview == "" {
+			t.Preview = prompt
+		}
+		t.UpdatedAt = time.Now().Unix()
+		t.Turns = append(t.Turns, map[string]any{
+			"id":     turnID,
+			"status": "completed",
+			"error":  nil,
+			"items": []any{
+				map[string]any{"type": "userMessage", "id": turnID + "-input", "content": []any{map[string]any{"type": "text", "text": prompt}}},
+				map[string]any{"type": "agentMessage", "id": itemID, "text": reply},
+			},
+		})
+	}
+	p.mu.Unlock()
+	p.notify("turn/completed", map[string]any{"threadId": threadID, "turnId": turnID, "turn": map[string]any{"id": turnID, "status": "completed", "error": nil}})
+}
+
+func inputText(params map[string]any) string {
+	input, _ := params["input"].([]any)
+	parts := make([]string, 0, len(input))
+	for _, raw := range input {
+		item, _ := raw.(map[string]any)
+		if text, ok := item["text"].(string); ok && item["type"] == "text" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,113 @@
+package fakeagent
+
+import (
+	"bufio"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type client struct {
+	t       *testing.T
+	p       *Process
+	scanner *bufio.Scanner
+	nextID  int
+}
+
+func newClient(t *testing.T, opts Options) *client {
+	p := Start(opts)
+	t.Cleanup(func() { _ = p.Kill() })
+	go func() {
+		// Drain stderr so log lines never back up.
+		buf := make([]byte, 4096)
+		for {
+			if _, err := p.Stderr.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+	return &client{t: t, p: p, scanner: bufio.NewScanner(p.Stdout)}
+}
+
+func (c *client) write(msg map[string]any) {
+	encoded, _ := json.Marshal(msg)
+	if _, err := c.p.Stdin.Write(append(encoded, '\n')); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) call(method string, params map[string]any) map[string]any {
+	c.nextID++
+	c.write(map[string]any{"id": c.nextID, "method": method, "params": params})
+	return c.waitFor(func(msg map[string]any) bool { return msg["id"] == float64(c.nextID) && msg["method"] == nil })
+}
+
+func (c *client) waitFor(match func(map[string]any) bool) map[string]any {
+	c.t.Helper()
+	for c.scanner.Scan() {
+		var msg map[string]any
+		if err := json.Unmarshal(c.scanner.Bytes(), &msg); err != nil {
+			c.t.Fatal(err)
+		}
+		if match(msg) {
+			return msg
+		}
+	}
+	c.t.Fatal("agent output ended before the expected message")
+	return nil
+}
+
+func TestFakeAgentRunsTurnWithApproval(t *testing.T) {
+	c := newClient(t, Options{ApprovalRate: 1, Seed: 1})
+	if resp := c.call("initialize", map[string]any{}); resp["error"] != nil {
+		t.Fatalf("initialize failed: %v", resp)
+	}
+	started := c.call("thread/start", map[string]any{"cwd": "/work"})
+	threadID := started["result"].(map[string]any)["thread"].(map[string]any)["id"].(string)
+
+	c.call("turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "hello"}}})
+	approval := c.waitFor(func(msg map[string]any) bool { return msg["method"] == "item/commandExecution/requestApproval" })
+	c.write(map[string]any{"id": approval["id"], "result": map[string]any{"decision": "accept"}})
+	c.waitFor(func(msg map[string]any) bool { return msg["method"] == "turn/completed" })
+
+	read := c.call("thread/read", map[string]any{"threadId": threadID})
+	turns := read["result"].(map[string]any)["thread"].(map[string]any)["turns"].([]any)
+	if len(turns) != 1 {
+		t.Fatalf("expected the completed turn in thread/read, got %v", read)
+	}
+	if resp := c.call("thread/read", map[string]any{"threadId": "missing"}); resp["error"] == nil {
+		t.Fatalf("expected an error for an unknown thread, got %v", resp)
+	}
+}
+
+func TestFakeAgentCrashInjection(t *testing.T) {
+	c := newClient(t, Options{CrashRate: 1})
+	c.call("initialize", map[string]any{})
+	started := c.call("thread/start", map[string]any{})
+	threadID := started["result"].(map[string]any)["thread"].(map[string]any)["id"].(string)
+	c.call("turn/start", map[string]any{"threadId": threadID, "input": []any{}})
+	c.waitFor(func(msg map[string]any) bool { return msg["method"] == "turn/started" })
+
+	done := make(chan error, 1)
+	go func() { done <- c.p.Wait() }()
+	select {
+	case err := <-done:
+		if err == nil || c.p.ExitCode() != 1 {
+			t.Fatalf("expected exit status 1, got %v (%d)", err, c.p.ExitCode())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("fake agent did not crash")
+	}
+}
+
+func TestFakeAgentStartsTurnsOnUnknownThreadsWithLegacyApprovals(t *testing.T) {
+	c := newClient(t, Options{ApprovalRate: 1})
+	c.call("initialize", map[string]any{})
+	c.call("turn/start", map[string]any{"threadId": "from-an-earlier-process", "input": []any{map[string]any{"type": "text", "text": "go " + legacyApprovalMarker}}})
+	approval := c.waitFor(func(msg map[string]any) bool { return msg["method"] == "execCommandApproval" })
+	c.write(map[string]any{"id": approval["id"], "result": map[string]any{"decision": "denied"}})
+	completed := c.waitFor(func(msg map[string]any) bool { return msg["method"] == "item/completed" })
+	if text := completed["params"].(map[string]any)["item"].(map[string]any)["text"]; text != "fake reply: command was declined" {
+		t.Fatalf("expected the legacy denial to decline the command, got %v", text)
+	}
+}
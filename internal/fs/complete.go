@@ -0,0 +1,97 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Completion is a directory that completes a typed path.
+type Completion struct {
+	Name string `json:"name"`
+	// Path is the resolved directory.
+	Path string `json:"path"`
+	// Value is the typed prefix completed with Name and a trailing
+	// separator, in the form it was typed (a leading "~" is kept).
+	Value string `json:"value"`
+}
+
+// CompleteDirectory lists the directories inside the browser root whose name
+// completes the last element of prefix, ordered by name. A leading "~" and
+// relative prefixes are taken from the browser root. Names match
+// case-insensitively, and hidden directories are only offered once the
+// typed name starts with a dot. A parent that does not exist has no
+// completions; one outside the root is an error.
+func CompleteDirectory(prefix string) ([]Completion, error) {
+	rootMu.RLock()
+	root := configuredRoot
+	rootMu.RUnlock()
+
+	typed := prefix
+	expanded := prefix
+	switch {
+	case prefix == "" || prefix == "~":
+		typed = "~" + string(filepath.Separator)
+		expanded = root + string(filepath.Separator)
+	case strings.HasPrefix(prefix, "~/") || strings.HasPrefix(prefix, "~"+string(filepath.Separator)):
+		expanded = root + prefix[1:]
+	case !filepath.IsAbs(prefix):
+		expanded = filepath.Join(root, prefix)
+		if strings.HasSuffix(prefix, "/") || strings.HasSuffix(prefix, string(filepath.Separator)) {
+			expanded += string(filepath.Separator)
+		}
+	}
+
+	dir, partial := expanded, ""
+	if !strings.HasSuffix(expanded, "/") && !strings.HasSuffix(expanded, string(filepath.Separator)) {
+		dir, partial = filepath.Dir(expanded), filepath.Base(expanded)
+	}
+	typedDir := typed[:len(typed)-len(partial)]
+	separator := string(filepath.Separator)
+	if strings.Contains(typed, "/") {
+		separator = "/"
+	}
+
+	current, _, err := resolveWithinRoot(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Completion{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	dirEntries, err := os.ReadDir(current)
+	if err != nil {
+		return nil, err
+	}
+
+	lowerPartial := strings.ToLower(partial)
+	completions := []Completion{}
+	for _, entry := range dirEntries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(partial, ".") {
+			continue
+		}
+		if !strings.HasPrefix(strings.ToLower(name), lowerPartial) {
+			continue
+		}
+		path := filepath.Join(current, name)
+		if entry.Type()&os.ModeSymlink != 0 {
+			// Links are offered when they lead to a directory in the root.
+			real, err := filepath.EvalSymlinks(path)
+			if err != nil || !IsWithinRoot(real) {
+				continue
+			}
+			path = real
+		}
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			continue
+		}
+		completions = append(completions, Completion{Name: name, Path: path, Value: typedDir + name + separator})
+	}
+	sort.Slice(completions, func(i, j int) bool {
+		return strings.ToLower(completions[i].Name) < strings.ToLower(completions[j].Name)
+	})
+	return completions, nil
+}
@@ -0,0 +1,51 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompleteDirectory(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	for _, dir := range []string{"work", "Workshop", "writing", ".worktrees", "work/org"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "words.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "wormhole")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SetBrowserRoot(root); err != nil {
+		t.Fatal(err)
+	}
+
+	completions, err := CompleteDirectory("~/wo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(completions) != 2 || completions[0].Value != "~/work/" || completions[1].Value != "~/Workshop/" {
+		t.Fatalf("expected work and Workshop only, got %+v", completions)
+	}
+
+	completions, err = CompleteDirectory("~/.wo")
+	if err != nil || len(completions) != 1 || completions[0].Name != ".worktrees" {
+		t.Fatalf("expected the hidden directory once typed, got %+v %v", completions, err)
+	}
+
+	completions, err = CompleteDirectory("work/")
+	if err != nil || len(completions) != 1 || completions[0].Value != "work/org/" {
+		t.Fatalf("expected a relative prefix to list its children, got %+v %v", completions, err)
+	}
+
+	if completions, err := CompleteDirectory("~/missing/x"); err != nil || len(completions) != 0 {
+		t.Fatalf("expected no completions under a missing directory, got %+v %v", completions, err)
+	}
+	if _, err := CompleteDirectory(filepath.Join(outside, "a")); err == nil {
+		t.Fatal("expected a prefix outside the root to fail")
+	}
+}
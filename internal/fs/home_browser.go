@@ -14,6 +14,8 @@ type FolderEntry struct {
 	Name string `json:"name"`
 	Path string `json:"path"`
 	Kind string `json:"kind"`
+	// Image marks files /api/fs/thumbnail can preview.
+	Image bool `json:"image,omitempty"`
 }
 
 type FolderListing struct {
@@ -32,7 +34,7 @@ var (
 func init() {
 	home, err := os.UserHomeDir()
 	if err != nil || home == "" {
-		home = "/"
+		home = filesystemRoot()
 	}
 	resolved := filepath.Clean(home)
 	real := resolved
@@ -84,12 +86,80 @@ func resolveWithinRoot(target string) (string, string, error) {
 	if err != nil {
 		return "", "", err
 	}
-	if real != rootReal && !strings.HasPrefix(real, rootReal+string(filepath.Separator)) {
+	if !PathWithin(real, rootReal) {
 		return "", "", errors.New("path must be inside the configured base path")
 	}
 	return real, rootReal, nil
 }
 
+// ResolvePath normalizes a user-supplied path, resolving symlinks, and
+// rejects anything outside the configured base path.
+func ResolvePath(inputPath string) (string, error) {
+	real, _, err := resolveWithinRoot(inputPath)
+	return real, err
+}
+
+// NormalizePath puts a path in the form thread and workspace cwds are
+// compared in: a leading "~" stands for the browser root, the path is
+// cleaned, and symlinks are resolved when it exists. Unlike ResolvePath it
+// neither requires the path to exist nor to be inside the root.
+func NormalizePath(path string) string {
+	if strings.TrimSpace(path) == "" {
+		return ""
+	}
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		rootMu.RLock()
+		root := configuredRoot
+		rootMu.RUnlock()
+		path = root + path[1:]
+	}
+	cleaned := filepath.Clean(path)
+	if real, err := filepath.EvalSymlinks(cleaned); err == nil {
+		return real
+	}
+	return cleaned
+}
+
+// IsWithinRoot reports whether an absolute path, which need not exist, falls
+// inside the configured base path. Symlinks in the part of the path that
+// exists are resolved first, so a link out of the root counts as outside.
+func IsWithinRoot(path string) bool {
+	rootMu.RLock()
+	rootReal := configuredReal
+	rootMu.RUnlock()
+
+	if !filepath.IsAbs(path) {
+		return false
+	}
+	existing := filepath.Clean(path)
+	rest := ""
+	for {
+		if real, err := filepath.EvalSymlinks(existing); err == nil {
+			return PathWithin(filepath.Join(real, rest), rootReal)
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return false
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+}
+
+// PathWithin reports whether the cleaned path is root or below it. Paths are
+// compared as the filesystem would: case-insensitively on Windows. A root
+// that already ends in a separator ("/", `C:\`) is not given another one.
+func PathWithin(path, root string) bool {
+	path, root = foldPath(path), foldPath(root)
+	if path == root {
+		return true
+	}
+	if !strings.HasSuffix(root, string(filepath.Separator)) {
+		root += string(filepath.Separator)
+	}
+	return strings.HasPrefix(path, root)
+}
+
 func ListFolder(inputPath string) (FolderListing, error) {
 	current, rootReal, err := resolveWithinRoot(inputPath)
 	if err != nil {
@@ -111,9 +181,10 @@ func ListFolder(inputPath string) (FolderListing, error) {
 			kind = "directory"
 		}
 		entries = append(entries, FolderEntry{
-			Name: entry.Name(),
-			Path: filepath.Join(current, entry.Name()),
-			Kind: kind,
+			Name:  entry.Name(),
+			Path:  filepath.Join(current, entry.Name()),
+			Kind:  kind,
+			Image: kind == "file" && IsImage(entry.Name()),
 		})
 	}
 
@@ -125,7 +196,7 @@ func ListFolder(inputPath string) (FolderListing, error) {
 	})
 
 	var parent *string
-	if current != rootReal {
+	if foldPath(current) != foldPath(rootReal) {
 		p := filepath.Dir(current)
 		parent = &p
 	}
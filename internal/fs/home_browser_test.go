@@ -41,3 +41,73 @@ func TestListFolderAndSafety(t *testing.T) {
 		t.Fatal("expected outside-root listing to fail")
 	}
 }
+
+func TestIsWithinRoot(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SetBrowserRoot(root); err != nil {
+		t.Fatal(err)
+	}
+	real, _ := filepath.EvalSymlinks(root)
+	for path, want := range map[string]bool{
+		real:                                  true,
+		filepath.Join(real, "new", "file.go"): true,
+		filepath.Join(real, "..", "sibling"):  false,
+		filepath.Join(real, "escape", "x"):    false,
+		outside:                               false,
+		"relative/path":                       false,
+	} {
+		if got := IsWithinRoot(path); got != want {
+			t.Errorf("IsWithinRoot(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "app"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "app"), filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SetBrowserRoot(root); err != nil {
+		t.Fatal(err)
+	}
+	real, _ := filepath.EvalSymlinks(filepath.Join(root, "app"))
+	for path, want := range map[string]string{
+		"~/app":                                 real,
+		"~/app/":                                real,
+		filepath.Join(root, "link") + "/":       real,
+		filepath.Join(root, "app", "..", "app"): real,
+		"/nowhere/x/../y/":                      filepath.Clean("/nowhere/y"),
+		"  ":                                    "",
+	} {
+		if got := NormalizePath(path); got != want {
+			t.Errorf("NormalizePath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestPathWithin(t *testing.T) {
+	sep := string(filepath.Separator)
+	root := filepath.Join(sep, "srv", "code")
+	for _, tc := range []struct {
+		path, root string
+		want       bool
+	}{
+		{root, root, true},
+		{filepath.Join(root, "app"), root, true},
+		{root + "-other", root, false},
+		{filepath.Join(sep, "srv"), root, false},
+		{filepath.Join(sep, "tmp"), sep, true},
+		{sep, sep, true},
+	} {
+		if got := PathWithin(tc.path, tc.root); got != tc.want {
+			t.Errorf("PathWithin(%q, %q) = %v, want %v", tc.path, tc.root, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package fs
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// languagesByExtension maps lower-cased extensions to the language names
+// common highlighters (highlight.js, Prism, Shiki) accept.
+var languagesByExtension = map[string]string{
+	".bash": "shell", ".sh": "shell", ".zsh": "shell", ".fish": "shell", ".ps1": "powershell",
+	".c": "c", ".h": "c", ".cc": "cpp", ".cpp": "cpp", ".cxx": "cpp", ".hpp": "cpp", ".hh": "cpp",
+	".cs": "csharp", ".css": "css", ".scss": "scss", ".sass": "sass", ".less": "less",
+	".dart": "dart", ".diff": "diff", ".patch": "diff",
+	".ex": "elixir", ".exs": "elixir", ".erl": "erlang", ".go": "go", ".graphql": "graphql", ".gql": "graphql",
+	".hs": "haskell", ".hcl": "hcl", ".tf": "hcl", ".html": "html", ".htm": "html",
+	".ini": "ini", ".cfg": "ini", ".java": "java", ".js": "javascript", ".mjs": "javascript", ".cjs": "javascript",
+	".json": "json", ".jsonc": "json", ".jsx": "jsx", ".kt": "kotlin", ".kts": "kotlin", ".lua": "lua",
+	".md": "markdown", ".markdown": "markdown", ".m": "objectivec", ".mm": "objectivec",
+	".php": "php", ".pl": "perl", ".proto": "protobuf", ".py": "python", ".pyi": "python",
+	".r": "r", ".rb": "ruby", ".rs": "rust", ".scala": "scala", ".sql": "sql", ".svelte": "svelte",
+	".swift": "swift", ".toml": "toml", ".ts": "typescript", ".mts": "typescript", ".cts": "typescript",
+	".tsx": "tsx", ".vue": "vue", ".xml": "xml", ".svg": "xml", ".yaml": "yaml", ".yml": "yaml", ".zig": "zig",
+}
+
+// languagesByName covers files recognized by their whole name.
+var languagesByName = map[string]string{
+	"dockerfile": "dockerfile", "containerfile": "dockerfile", "makefile": "makefile", "gnumakefile": "makefile",
+	"go.mod": "go", "go.sum": "text", "gemfile": "ruby", "rakefile": "ruby", "cmakelists.txt": "cmake",
+	".bashrc": "shell", ".zshrc": "shell", ".profile": "shell", ".gitignore": "ignore", ".dockerignore": "ignore",
+}
+
+// languagesByInterpreter maps a shebang's interpreter to a language.
+var languagesByInterpreter = map[string]string{
+	"sh": "shell", "bash": "shell", "zsh": "shell", "python": "python", "python3": "python",
+	"node": "javascript", "deno": "typescript", "ruby": "ruby", "perl": "perl", "php": "php",
+}
+
+// LanguageForName names the language of a file for syntax highlighting from
+// its name or extension alone. It returns "" when the language is not known.
+func LanguageForName(path string) string {
+	base := strings.ToLower(filepath.Base(path))
+	if language, ok := languagesByName[base]; ok {
+		return language
+	}
+	if strings.HasPrefix(base, "dockerfile.") {
+		return "dockerfile"
+	}
+	return languagesByExtension[filepath.Ext(base)]
+}
+
+// DetectLanguage is LanguageForName falling back, for files without an
+// extension, to the shebang line when the file can be read.
+func DetectLanguage(path string) string {
+	if language := LanguageForName(path); language != "" || filepath.Ext(path) != "" {
+		return language
+	}
+	return shebangLanguage(path)
+}
+
+func shebangLanguage(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	line, err := bufio.NewReaderSize(file, 256).ReadString('\n')
+	if err != nil && line == "" {
+		return ""
+	}
+	rest, ok := strings.CutPrefix(line, "#!")
+	if !ok {
+		return ""
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" && len(fields) > 1 {
+		interpreter = fields[1]
+	}
+	return languagesByInterpreter[interpreter]
+}
@@ -0,0 +1,29 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "deploy")
+	if err := os.WriteFile(script, []byte("#!/usr/bin/env python3\nprint('hi')\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		"internal/server/server.go": "go",
+		"clients/web/src/main.TSX":  "tsx",
+		"Dockerfile":                "dockerfile",
+		"Dockerfile.dev":            "dockerfile",
+		"infra/main.tf":             "hcl",
+		"notes.unknown":             "",
+		filepath.Join(dir, "none"):  "",
+		script:                      "python",
+	} {
+		if got := DetectLanguage(path); got != want {
+			t.Errorf("DetectLanguage(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package fs
+
+// filesystemRoot is the browser root used when there is no home directory.
+func filesystemRoot() string {
+	return "/"
+}
+
+// foldPath returns the form of path used for comparisons.
+func foldPath(path string) string {
+	return path
+}
@@ -0,0 +1,23 @@
+package fs
+
+import (
+	"os"
+	"strings"
+)
+
+// filesystemRoot is the browser root used when there is no home directory:
+// the root of the system drive.
+func filesystemRoot() string {
+	drive := os.Getenv("SystemDrive")
+	if drive == "" {
+		drive = "C:"
+	}
+	return drive + `\`
+}
+
+// foldPath returns the form of path used for comparisons. NTFS and FAT are
+// case-insensitive, and EvalSymlinks does not always normalize drive letter
+// case.
+func foldPath(path string) string {
+	return strings.ToLower(path)
+}
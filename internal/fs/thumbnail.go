@@ -0,0 +1,112 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// MaxThumbnailPixels bounds the source images Thumbnail decodes, so a small
+// file declaring huge dimensions cannot exhaust memory.
+const MaxThumbnailPixels = 50_000_000
+
+var (
+	// ErrNotImage is returned for files Thumbnail cannot decode.
+	ErrNotImage = errors.New("not a supported image")
+	// ErrImageTooLarge is returned for images over MaxThumbnailPixels.
+	ErrImageTooLarge = errors.New("image is too large to preview")
+)
+
+// imageDecoders maps lower-cased extensions to their decoder.
+var imageDecoders = map[string]func(*bytes.Reader) (image.Image, error){
+	".png":  func(r *bytes.Reader) (image.Image, error) { return png.Decode(r) },
+	".jpg":  func(r *bytes.Reader) (image.Image, error) { return jpeg.Decode(r) },
+	".jpeg": func(r *bytes.Reader) (image.Image, error) { return jpeg.Decode(r) },
+	".gif":  func(r *bytes.Reader) (image.Image, error) { return gif.Decode(r) },
+	".webp": func(r *bytes.Reader) (image.Image, error) { return webp.Decode(r) },
+	".bmp":  func(r *bytes.Reader) (image.Image, error) { return bmp.Decode(r) },
+}
+
+var imageConfigDecoders = map[string]func(*bytes.Reader) (image.Config, error){
+	".png":  func(r *bytes.Reader) (image.Config, error) { return png.DecodeConfig(r) },
+	".jpg":  func(r *bytes.Reader) (image.Config, error) { return jpeg.DecodeConfig(r) },
+	".jpeg": func(r *bytes.Reader) (image.Config, error) { return jpeg.DecodeConfig(r) },
+	".gif":  func(r *bytes.Reader) (image.Config, error) { return gif.DecodeConfig(r) },
+	".webp": func(r *bytes.Reader) (image.Config, error) { return webp.DecodeConfig(r) },
+	".bmp":  func(r *bytes.Reader) (image.Config, error) { return bmp.DecodeConfig(r) },
+}
+
+// IsImage reports whether Thumbnail handles the file, by extension.
+func IsImage(path string) bool {
+	_, ok := imageDecoders[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// Thumbnail decodes the image at path and scales it to fit within width x
+// height, keeping its aspect ratio and never enlarging it. JPEG sources are
+// encoded as JPEG; everything else as PNG, which keeps transparency. It
+// returns the encoded image and its content type.
+func Thumbnail(path string, width, height int) ([]byte, string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	decode, ok := imageDecoders[ext]
+	if !ok {
+		return nil, "", ErrNotImage
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	cfg, err := imageConfigDecoders[ext](bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrNotImage, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, "", ErrNotImage
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MaxThumbnailPixels {
+		return nil, "", ErrImageTooLarge
+	}
+	src, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrNotImage, err)
+	}
+
+	bounds := src.Bounds()
+	w, h := fitWithin(bounds.Dx(), bounds.Dy(), width, height)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+
+	var out bytes.Buffer
+	if ext == ".jpg" || ext == ".jpeg" {
+		if err := jpeg.Encode(&out, dst, &jpeg.Options{Quality: 80}); err != nil {
+			return nil, "", err
+		}
+		return out.Bytes(), "image/jpeg", nil
+	}
+	if err := png.Encode(&out, dst); err != nil {
+		return nil, "", err
+	}
+	return out.Bytes(), "image/png", nil
+}
+
+// fitWithin scales w x h down to fit within maxW x maxH, at least 1x1.
+func fitWithin(w, h, maxW, maxH int) (int, int) {
+	if w <= maxW && h <= maxH {
+		return w, h
+	}
+	if w*maxH > h*maxW {
+		return maxW, max(1, h*maxW/w)
+	}
+	return max(1, w*maxH/h), maxH
+}
@@ -0,0 +1,73 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writePNG(t *testing.T, path string, w, h int) {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for x := range w {
+		img.Set(x, 0, color.NRGBA{R: 255, A: 255})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestThumbnailFitsWithinBounds(t *testing.T) {
+	dir := t.TempDir()
+	wide := filepath.Join(dir, "wide.PNG")
+	writePNG(t, wide, 800, 200)
+	data, contentType, err := Thumbnail(wide, 100, 100)
+	if err != nil || contentType != "image/png" {
+		t.Fatalf("got %q, %v", contentType, err)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width != 100 || cfg.Height != 25 {
+		t.Fatalf("expected a 100x25 thumbnail, got %dx%d (%v)", cfg.Width, cfg.Height, err)
+	}
+
+	small := filepath.Join(dir, "small.png")
+	writePNG(t, small, 10, 20)
+	data, _, _ = Thumbnail(small, 100, 100)
+	if cfg, _ := png.DecodeConfig(bytes.NewReader(data)); cfg.Width != 10 || cfg.Height != 20 {
+		t.Fatalf("expected small images to keep their size, got %dx%d", cfg.Width, cfg.Height)
+	}
+
+	fake := filepath.Join(dir, "notes.png")
+	if err := os.WriteFile(fake, []byte("not a png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Thumbnail(fake, 100, 100); !errors.Is(err, ErrNotImage) {
+		t.Fatalf("expected ErrNotImage, got %v", err)
+	}
+	if IsImage(filepath.Join(dir, "notes.txt")) || !IsImage("shot.webp") {
+		t.Fatal("unexpected IsImage result")
+	}
+}
+
+func TestFitWithin(t *testing.T) {
+	cases := []struct{ w, h, maxW, maxH, wantW, wantH int }{
+		{1000, 500, 256, 256, 256, 128},
+		{500, 1000, 256, 256, 128, 256},
+		{5000, 1, 256, 256, 256, 1},
+		{100, 100, 256, 256, 100, 100},
+	}
+	for _, tc := range cases {
+		if w, h := fitWithin(tc.w, tc.h, tc.maxW, tc.maxH); w != tc.wantW || h != tc.wantH {
+			t.Errorf("fitWithin(%d, %d, %d, %d) = %dx%d, want %dx%d", tc.w, tc.h, tc.maxW, tc.maxH, w, h, tc.wantW, tc.wantH)
+		}
+	}
+}
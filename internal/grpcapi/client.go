@@ -0,0 +1,199 @@
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	pb "darkhold-go/internal/grpcapi/darkholdv1"
+)
+
+// errorDomain is the ErrorInfo domain of statuses built from API errors.
+const errorDomain = "darkhold"
+
+// apiClient calls the HTTP API handler in-process on behalf of a gRPC
+// caller: the authorization metadata becomes the Authorization header, the
+// peer address the remote address and the TLS state (with any client
+// certificate) the request's, so the call is authenticated and allow-listed
+// like an HTTP request from the same client.
+type apiClient struct {
+	handler http.Handler
+}
+
+// apiError is an error envelope returned by the HTTP API.
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+	Details json.RawMessage
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("darkhold API error %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// GRPCStatus maps the envelope onto a status whose ErrorInfo detail keeps
+// the darkhold code, so clients can branch on it as they would over HTTP.
+func (e *apiError) GRPCStatus() *status.Status {
+	info := &errdetails.ErrorInfo{
+		Reason:   e.Code,
+		Domain:   errorDomain,
+		Metadata: map[string]string{"httpStatus": strconv.Itoa(e.Status)},
+	}
+	if len(e.Details) > 0 {
+		info.Metadata["details"] = string(e.Details)
+	}
+	st := status.New(grpcCode(e.Status), e.Message)
+	if detailed, err := st.WithDetails(info); err == nil {
+		return detailed
+	}
+	return st
+}
+
+func (e *apiError) proto() *pb.Error {
+	return &pb.Error{Code: e.Code, Message: e.Message, HttpStatus: int32(e.Status)}
+}
+
+// grpcCode picks the status code closest to an HTTP status.
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound, http.StatusGone:
+		return codes.NotFound
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusConflict, http.StatusLocked:
+		return codes.Aborted
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests, http.StatusPaymentRequired, http.StatusInsufficientStorage:
+		return codes.ResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	if httpStatus >= http.StatusInternalServerError {
+		return codes.Internal
+	}
+	return codes.Unknown
+}
+
+// invalidRequest is an INVALID_REQUEST error for arguments rejected before
+// they reach the API.
+func invalidRequest(message string) *apiError {
+	return &apiError{Status: http.StatusBadRequest, Code: "INVALID_REQUEST", Message: message}
+}
+
+// call serves method path with body (if any) encoded as JSON and returns the
+// response body, or an *apiError for an error status.
+func (c *apiClient) call(ctx context.Context, method, path string, query url.Values, body any) ([]byte, error) {
+	target := path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	var encoded []byte
+	if body != nil {
+		var err error
+		if encoded, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, "http://darkhold"+target, bytes.NewReader(encoded))
+	if err != nil {
+		return nil, err
+	}
+	req.RequestURI = target
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if auth := md.Get("authorization"); len(auth) > 0 {
+			req.Header.Set("Authorization", auth[0])
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		req.RemoteAddr = p.Addr.String()
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			req.TLS = &info.State
+		}
+	}
+	w := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+	c.handler.ServeHTTP(w, req)
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	if w.status >= http.StatusBadRequest {
+		var envelope struct {
+			Error   string          `json:"error"`
+			Code    string          `json:"code"`
+			Details json.RawMessage `json:"details"`
+		}
+		if err := json.Unmarshal(w.body.Bytes(), &envelope); err != nil || envelope.Code == "" {
+			return nil, &apiError{Status: w.status, Code: "INTERNAL", Message: fmt.Sprintf("%s %s: HTTP %d", method, path, w.status)}
+		}
+		return nil, &apiError{Status: w.status, Code: envelope.Code, Message: envelope.Error, Details: envelope.Details}
+	}
+	return w.body.Bytes(), nil
+}
+
+// callJSON is call with the response decoded into out.
+func (c *apiClient) callJSON(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	raw, err := c.call(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("%s %s: unreadable response: %w", method, path, err)
+	}
+	return nil
+}
+
+// rpc sends an app-server request through POST /api/rpc and returns the raw
+// result.
+func (c *apiClient) rpc(ctx context.Context, method string, params map[string]any) (json.RawMessage, error) {
+	request := map[string]any{"method": method}
+	if params != nil {
+		request["params"] = params
+	}
+	return c.call(ctx, http.MethodPost, "/api/rpc", nil, request)
+}
+
+type bufferedResponse struct {
+	header      http.Header
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+}
+
+func (w *bufferedResponse) Header() http.Header { return w.header }
+
+func (w *bufferedResponse) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = status, true
+	}
+}
+
+func (w *bufferedResponse) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+// Flush is a no-op: the response is only read once the handler returns.
+func (w *bufferedResponse) Flush() {}
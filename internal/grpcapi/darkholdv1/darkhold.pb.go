@@ -0,0 +1,1795 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: darkhold/v1/darkhold.proto
+
+// darkhold.v1 is the gRPC API served on --grpc-port. Every call goes through
+// the same handler as its HTTP counterpart, so API keys, allowed CIDRs, audit
+// records and error codes are shared with the HTTP API.
+//
+// App-server payloads (RPC params and results, interaction results, thread
+// events) are carried as JSON text: darkhold passes the agent's protocol
+// through rather than modelling each method.
+//
+// Regenerate the Go code with `go generate ./internal/grpcapi`.
+
+package darkholdv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type CallRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Method string                 `protobuf:"bytes,1,opt,name=method,proto3" json:"method,omitempty"`
+	// params_json is the request params object; empty sends none.
+	ParamsJson    string `protobuf:"bytes,2,opt,name=params_json,json=paramsJson,proto3" json:"params_json,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CallRequest) Reset() {
+	*x = CallRequest{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CallRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CallRequest) ProtoMessage() {}
+
+func (x *CallRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CallRequest.ProtoReflect.Descriptor instead.
+func (*CallRequest) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CallRequest) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *CallRequest) GetParamsJson() string {
+	if x != nil {
+		return x.ParamsJson
+	}
+	return ""
+}
+
+type CallResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ResultJson    string                 `protobuf:"bytes,1,opt,name=result_json,json=resultJson,proto3" json:"result_json,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CallResponse) Reset() {
+	*x = CallResponse{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CallResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CallResponse) ProtoMessage() {}
+
+func (x *CallResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CallResponse.ProtoReflect.Descriptor instead.
+func (*CallResponse) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CallResponse) GetResultJson() string {
+	if x != nil {
+		return x.ResultJson
+	}
+	return ""
+}
+
+type ListThreadsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// sort is threadId (default), updatedAt, createdAt, title or cwd.
+	Sort string `protobuf:"bytes,1,opt,name=sort,proto3" json:"sort,omitempty"`
+	// order is asc or desc; empty uses the sort's default.
+	Order     string `protobuf:"bytes,2,opt,name=order,proto3" json:"order,omitempty"`
+	Limit     int32  `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	Cursor    string `protobuf:"bytes,4,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	CwdPrefix string `protobuf:"bytes,5,opt,name=cwd_prefix,json=cwdPrefix,proto3" json:"cwd_prefix,omitempty"`
+	// tags keeps threads that carry all of them.
+	Tags          []string `protobuf:"bytes,6,rep,name=tags,proto3" json:"tags,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListThreadsRequest) Reset() {
+	*x = ListThreadsRequest{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListThreadsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListThreadsRequest) ProtoMessage() {}
+
+func (x *ListThreadsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListThreadsRequest.ProtoReflect.Descriptor instead.
+func (*ListThreadsRequest) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListThreadsRequest) GetSort() string {
+	if x != nil {
+		return x.Sort
+	}
+	return ""
+}
+
+func (x *ListThreadsRequest) GetOrder() string {
+	if x != nil {
+		return x.Order
+	}
+	return ""
+}
+
+func (x *ListThreadsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListThreadsRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *ListThreadsRequest) GetCwdPrefix() string {
+	if x != nil {
+		return x.CwdPrefix
+	}
+	return ""
+}
+
+func (x *ListThreadsRequest) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+type Thread struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	ThreadId string                 `protobuf:"bytes,1,opt,name=thread_id,json=threadId,proto3" json:"thread_id,omitempty"`
+	Title    string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	Cwd      string                 `protobuf:"bytes,3,opt,name=cwd,proto3" json:"cwd,omitempty"`
+	Tags     []string               `protobuf:"bytes,4,rep,name=tags,proto3" json:"tags,omitempty"`
+	// Times are Unix milliseconds; zero when unknown.
+	CreatedAt           int64 `protobuf:"varint,5,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt           int64 `protobuf:"varint,6,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	LastActivityAt      int64 `protobuf:"varint,7,opt,name=last_activity_at,json=lastActivityAt,proto3" json:"last_activity_at,omitempty"`
+	ArchivedAt          int64 `protobuf:"varint,8,opt,name=archived_at,json=archivedAt,proto3" json:"archived_at,omitempty"`
+	PendingInteractions int32 `protobuf:"varint,9,opt,name=pending_interactions,json=pendingInteractions,proto3" json:"pending_interactions,omitempty"`
+	ActiveTurn          bool  `protobuf:"varint,10,opt,name=active_turn,json=activeTurn,proto3" json:"active_turn,omitempty"`
+	Unread              int32 `protobuf:"varint,11,opt,name=unread,proto3" json:"unread,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *Thread) Reset() {
+	*x = Thread{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Thread) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Thread) ProtoMessage() {}
+
+func (x *Thread) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Thread.ProtoReflect.Descriptor instead.
+func (*Thread) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Thread) GetThreadId() string {
+	if x != nil {
+		return x.ThreadId
+	}
+	return ""
+}
+
+func (x *Thread) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Thread) GetCwd() string {
+	if x != nil {
+		return x.Cwd
+	}
+	return ""
+}
+
+func (x *Thread) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Thread) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+func (x *Thread) GetUpdatedAt() int64 {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return 0
+}
+
+func (x *Thread) GetLastActivityAt() int64 {
+	if x != nil {
+		return x.LastActivityAt
+	}
+	return 0
+}
+
+func (x *Thread) GetArchivedAt() int64 {
+	if x != nil {
+		return x.ArchivedAt
+	}
+	return 0
+}
+
+func (x *Thread) GetPendingInteractions() int32 {
+	if x != nil {
+		return x.PendingInteractions
+	}
+	return 0
+}
+
+func (x *Thread) GetActiveTurn() bool {
+	if x != nil {
+		return x.ActiveTurn
+	}
+	return false
+}
+
+func (x *Thread) GetUnread() int32 {
+	if x != nil {
+		return x.Unread
+	}
+	return 0
+}
+
+type ListThreadsResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Threads []*Thread              `protobuf:"bytes,1,rep,name=threads,proto3" json:"threads,omitempty"`
+	// next_cursor is set when more pages follow.
+	NextCursor    string `protobuf:"bytes,2,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListThreadsResponse) Reset() {
+	*x = ListThreadsResponse{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListThreadsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListThreadsResponse) ProtoMessage() {}
+
+func (x *ListThreadsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListThreadsResponse.ProtoReflect.Descriptor instead.
+func (*ListThreadsResponse) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListThreadsResponse) GetThreads() []*Thread {
+	if x != nil {
+		return x.Threads
+	}
+	return nil
+}
+
+func (x *ListThreadsResponse) GetNextCursor() string {
+	if x != nil {
+		return x.NextCursor
+	}
+	return ""
+}
+
+type StartThreadRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Cwd   string                 `protobuf:"bytes,1,opt,name=cwd,proto3" json:"cwd,omitempty"`
+	// params_json holds further thread/start params; cwd wins over a cwd here.
+	ParamsJson    string `protobuf:"bytes,2,opt,name=params_json,json=paramsJson,proto3" json:"params_json,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartThreadRequest) Reset() {
+	*x = StartThreadRequest{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartThreadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartThreadRequest) ProtoMessage() {}
+
+func (x *StartThreadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartThreadRequest.ProtoReflect.Descriptor instead.
+func (*StartThreadRequest) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *StartThreadRequest) GetCwd() string {
+	if x != nil {
+		return x.Cwd
+	}
+	return ""
+}
+
+func (x *StartThreadRequest) GetParamsJson() string {
+	if x != nil {
+		return x.ParamsJson
+	}
+	return ""
+}
+
+type StartThreadResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ThreadId      string                 `protobuf:"bytes,1,opt,name=thread_id,json=threadId,proto3" json:"thread_id,omitempty"`
+	ResultJson    string                 `protobuf:"bytes,2,opt,name=result_json,json=resultJson,proto3" json:"result_json,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartThreadResponse) Reset() {
+	*x = StartThreadResponse{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartThreadResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartThreadResponse) ProtoMessage() {}
+
+func (x *StartThreadResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartThreadResponse.ProtoReflect.Descriptor instead.
+func (*StartThreadResponse) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *StartThreadResponse) GetThreadId() string {
+	if x != nil {
+		return x.ThreadId
+	}
+	return ""
+}
+
+func (x *StartThreadResponse) GetResultJson() string {
+	if x != nil {
+		return x.ResultJson
+	}
+	return ""
+}
+
+type ResumeThreadRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ThreadId      string                 `protobuf:"bytes,1,opt,name=thread_id,json=threadId,proto3" json:"thread_id,omitempty"`
+	ParamsJson    string                 `protobuf:"bytes,2,opt,name=params_json,json=paramsJson,proto3" json:"params_json,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResumeThreadRequest) Reset() {
+	*x = ResumeThreadRequest{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumeThreadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumeThreadRequest) ProtoMessage() {}
+
+func (x *ResumeThreadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumeThreadRequest.ProtoReflect.Descriptor instead.
+func (*ResumeThreadRequest) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ResumeThreadRequest) GetThreadId() string {
+	if x != nil {
+		return x.ThreadId
+	}
+	return ""
+}
+
+func (x *ResumeThreadRequest) GetParamsJson() string {
+	if x != nil {
+		return x.ParamsJson
+	}
+	return ""
+}
+
+type ResumeThreadResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ResultJson    string                 `protobuf:"bytes,1,opt,name=result_json,json=resultJson,proto3" json:"result_json,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResumeThreadResponse) Reset() {
+	*x = ResumeThreadResponse{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumeThreadResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumeThreadResponse) ProtoMessage() {}
+
+func (x *ResumeThreadResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumeThreadResponse.ProtoReflect.Descriptor instead.
+func (*ResumeThreadResponse) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ResumeThreadResponse) GetResultJson() string {
+	if x != nil {
+		return x.ResultJson
+	}
+	return ""
+}
+
+type StartTurnRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	ThreadId string                 `protobuf:"bytes,1,opt,name=thread_id,json=threadId,proto3" json:"thread_id,omitempty"`
+	Text     string                 `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	// params_json holds further turn/start params, such as an input list to
+	// send instead of text.
+	ParamsJson    string `protobuf:"bytes,3,opt,name=params_json,json=paramsJson,proto3" json:"params_json,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartTurnRequest) Reset() {
+	*x = StartTurnRequest{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartTurnRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartTurnRequest) ProtoMessage() {}
+
+func (x *StartTurnRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartTurnRequest.ProtoReflect.Descriptor instead.
+func (*StartTurnRequest) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *StartTurnRequest) GetThreadId() string {
+	if x != nil {
+		return x.ThreadId
+	}
+	return ""
+}
+
+func (x *StartTurnRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *StartTurnRequest) GetParamsJson() string {
+	if x != nil {
+		return x.ParamsJson
+	}
+	return ""
+}
+
+type StartTurnResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// turn_id is empty when the agent only reports it on turn/started.
+	TurnId        string `protobuf:"bytes,1,opt,name=turn_id,json=turnId,proto3" json:"turn_id,omitempty"`
+	ResultJson    string `protobuf:"bytes,2,opt,name=result_json,json=resultJson,proto3" json:"result_json,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartTurnResponse) Reset() {
+	*x = StartTurnResponse{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartTurnResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartTurnResponse) ProtoMessage() {}
+
+func (x *StartTurnResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartTurnResponse.ProtoReflect.Descriptor instead.
+func (*StartTurnResponse) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *StartTurnResponse) GetTurnId() string {
+	if x != nil {
+		return x.TurnId
+	}
+	return ""
+}
+
+func (x *StartTurnResponse) GetResultJson() string {
+	if x != nil {
+		return x.ResultJson
+	}
+	return ""
+}
+
+type InterruptTurnRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ThreadId      string                 `protobuf:"bytes,1,opt,name=thread_id,json=threadId,proto3" json:"thread_id,omitempty"`
+	TurnId        string                 `protobuf:"bytes,2,opt,name=turn_id,json=turnId,proto3" json:"turn_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InterruptTurnRequest) Reset() {
+	*x = InterruptTurnRequest{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InterruptTurnRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InterruptTurnRequest) ProtoMessage() {}
+
+func (x *InterruptTurnRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InterruptTurnRequest.ProtoReflect.Descriptor instead.
+func (*InterruptTurnRequest) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *InterruptTurnRequest) GetThreadId() string {
+	if x != nil {
+		return x.ThreadId
+	}
+	return ""
+}
+
+func (x *InterruptTurnRequest) GetTurnId() string {
+	if x != nil {
+		return x.TurnId
+	}
+	return ""
+}
+
+type InterruptTurnResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ResultJson    string                 `protobuf:"bytes,1,opt,name=result_json,json=resultJson,proto3" json:"result_json,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InterruptTurnResponse) Reset() {
+	*x = InterruptTurnResponse{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InterruptTurnResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InterruptTurnResponse) ProtoMessage() {}
+
+func (x *InterruptTurnResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InterruptTurnResponse.ProtoReflect.Descriptor instead.
+func (*InterruptTurnResponse) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *InterruptTurnResponse) GetResultJson() string {
+	if x != nil {
+		return x.ResultJson
+	}
+	return ""
+}
+
+type SubscribeRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	ThreadId string                 `protobuf:"bytes,1,opt,name=thread_id,json=threadId,proto3" json:"thread_id,omitempty"`
+	// after_id resumes after a stored event id; empty starts from the
+	// beginning of the log.
+	AfterId string `protobuf:"bytes,2,opt,name=after_id,json=afterId,proto3" json:"after_id,omitempty"`
+	// render_html adds renderedHtml to agent messages, like ?render=html.
+	RenderHtml    bool `protobuf:"varint,3,opt,name=render_html,json=renderHtml,proto3" json:"render_html,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeRequest) Reset() {
+	*x = SubscribeRequest{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeRequest) ProtoMessage() {}
+
+func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *SubscribeRequest) GetThreadId() string {
+	if x != nil {
+		return x.ThreadId
+	}
+	return ""
+}
+
+func (x *SubscribeRequest) GetAfterId() string {
+	if x != nil {
+		return x.AfterId
+	}
+	return ""
+}
+
+func (x *SubscribeRequest) GetRenderHtml() bool {
+	if x != nil {
+		return x.RenderHtml
+	}
+	return false
+}
+
+type Event struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	ThreadId string                 `protobuf:"bytes,1,opt,name=thread_id,json=threadId,proto3" json:"thread_id,omitempty"`
+	Id       string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Seq      int64                  `protobuf:"varint,3,opt,name=seq,proto3" json:"seq,omitempty"`
+	// time is when darkhold stored the event, in Unix milliseconds.
+	Time   int64  `protobuf:"varint,4,opt,name=time,proto3" json:"time,omitempty"`
+	Method string `protobuf:"bytes,5,opt,name=method,proto3" json:"method,omitempty"`
+	// payload_json is the stored {method, params} envelope.
+	PayloadJson   string `protobuf:"bytes,6,opt,name=payload_json,json=payloadJson,proto3" json:"payload_json,omitempty"`
+	SchemaVersion int32  `protobuf:"varint,7,opt,name=schema_version,json=schemaVersion,proto3" json:"schema_version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *Event) GetThreadId() string {
+	if x != nil {
+		return x.ThreadId
+	}
+	return ""
+}
+
+func (x *Event) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Event) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Event) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *Event) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *Event) GetPayloadJson() string {
+	if x != nil {
+		return x.PayloadJson
+	}
+	return ""
+}
+
+func (x *Event) GetSchemaVersion() int32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+type SubscribeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Event         *Event                 `protobuf:"bytes,1,opt,name=event,proto3" json:"event,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeResponse) Reset() {
+	*x = SubscribeResponse{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeResponse) ProtoMessage() {}
+
+func (x *SubscribeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeResponse.ProtoReflect.Descriptor instead.
+func (*SubscribeResponse) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *SubscribeResponse) GetEvent() *Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+type RespondRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	ThreadId  string                 `protobuf:"bytes,1,opt,name=thread_id,json=threadId,proto3" json:"thread_id,omitempty"`
+	RequestId string                 `protobuf:"bytes,2,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	// Set exactly one of result_json and error_json.
+	ResultJson    string `protobuf:"bytes,3,opt,name=result_json,json=resultJson,proto3" json:"result_json,omitempty"`
+	ErrorJson     string `protobuf:"bytes,4,opt,name=error_json,json=errorJson,proto3" json:"error_json,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RespondRequest) Reset() {
+	*x = RespondRequest{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RespondRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RespondRequest) ProtoMessage() {}
+
+func (x *RespondRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RespondRequest.ProtoReflect.Descriptor instead.
+func (*RespondRequest) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *RespondRequest) GetThreadId() string {
+	if x != nil {
+		return x.ThreadId
+	}
+	return ""
+}
+
+func (x *RespondRequest) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *RespondRequest) GetResultJson() string {
+	if x != nil {
+		return x.ResultJson
+	}
+	return ""
+}
+
+func (x *RespondRequest) GetErrorJson() string {
+	if x != nil {
+		return x.ErrorJson
+	}
+	return ""
+}
+
+type RespondResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// kind is the interaction kind, such as commandApproval; empty when the
+	// response was forwarded to another replica.
+	Kind          string `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Forwarded     bool   `protobuf:"varint,2,opt,name=forwarded,proto3" json:"forwarded,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RespondResponse) Reset() {
+	*x = RespondResponse{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RespondResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RespondResponse) ProtoMessage() {}
+
+func (x *RespondResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RespondResponse.ProtoReflect.Descriptor instead.
+func (*RespondResponse) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *RespondResponse) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *RespondResponse) GetForwarded() bool {
+	if x != nil {
+		return x.Forwarded
+	}
+	return false
+}
+
+type Unsubscribe struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// subscription_id is the id of the ConnectRequest that subscribed.
+	SubscriptionId string `protobuf:"bytes,1,opt,name=subscription_id,json=subscriptionId,proto3" json:"subscription_id,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Unsubscribe) Reset() {
+	*x = Unsubscribe{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Unsubscribe) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Unsubscribe) ProtoMessage() {}
+
+func (x *Unsubscribe) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Unsubscribe.ProtoReflect.Descriptor instead.
+func (*Unsubscribe) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *Unsubscribe) GetSubscriptionId() string {
+	if x != nil {
+		return x.SubscriptionId
+	}
+	return ""
+}
+
+type ConnectRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// id is echoed on the replies to this message. It must be unique among
+	// the stream's open subscriptions.
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Types that are valid to be assigned to Kind:
+	//
+	//	*ConnectRequest_Subscribe
+	//	*ConnectRequest_Unsubscribe
+	//	*ConnectRequest_Call
+	//	*ConnectRequest_Respond
+	Kind          isConnectRequest_Kind `protobuf_oneof:"kind"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConnectRequest) Reset() {
+	*x = ConnectRequest{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConnectRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConnectRequest) ProtoMessage() {}
+
+func (x *ConnectRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConnectRequest.ProtoReflect.Descriptor instead.
+func (*ConnectRequest) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *ConnectRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ConnectRequest) GetKind() isConnectRequest_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return nil
+}
+
+func (x *ConnectRequest) GetSubscribe() *SubscribeRequest {
+	if x != nil {
+		if x, ok := x.Kind.(*ConnectRequest_Subscribe); ok {
+			return x.Subscribe
+		}
+	}
+	return nil
+}
+
+func (x *ConnectRequest) GetUnsubscribe() *Unsubscribe {
+	if x != nil {
+		if x, ok := x.Kind.(*ConnectRequest_Unsubscribe); ok {
+			return x.Unsubscribe
+		}
+	}
+	return nil
+}
+
+func (x *ConnectRequest) GetCall() *CallRequest {
+	if x != nil {
+		if x, ok := x.Kind.(*ConnectRequest_Call); ok {
+			return x.Call
+		}
+	}
+	return nil
+}
+
+func (x *ConnectRequest) GetRespond() *RespondRequest {
+	if x != nil {
+		if x, ok := x.Kind.(*ConnectRequest_Respond); ok {
+			return x.Respond
+		}
+	}
+	return nil
+}
+
+type isConnectRequest_Kind interface {
+	isConnectRequest_Kind()
+}
+
+type ConnectRequest_Subscribe struct {
+	Subscribe *SubscribeRequest `protobuf:"bytes,2,opt,name=subscribe,proto3,oneof"`
+}
+
+type ConnectRequest_Unsubscribe struct {
+	Unsubscribe *Unsubscribe `protobuf:"bytes,3,opt,name=unsubscribe,proto3,oneof"`
+}
+
+type ConnectRequest_Call struct {
+	Call *CallRequest `protobuf:"bytes,4,opt,name=call,proto3,oneof"`
+}
+
+type ConnectRequest_Respond struct {
+	Respond *RespondRequest `protobuf:"bytes,5,opt,name=respond,proto3,oneof"`
+}
+
+func (*ConnectRequest_Subscribe) isConnectRequest_Kind() {}
+
+func (*ConnectRequest_Unsubscribe) isConnectRequest_Kind() {}
+
+func (*ConnectRequest_Call) isConnectRequest_Kind() {}
+
+func (*ConnectRequest_Respond) isConnectRequest_Kind() {}
+
+type ConnectResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Types that are valid to be assigned to Kind:
+	//
+	//	*ConnectResponse_Event
+	//	*ConnectResponse_Result
+	//	*ConnectResponse_Responded
+	//	*ConnectResponse_Error
+	//	*ConnectResponse_Subscribed
+	Kind          isConnectResponse_Kind `protobuf_oneof:"kind"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConnectResponse) Reset() {
+	*x = ConnectResponse{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConnectResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConnectResponse) ProtoMessage() {}
+
+func (x *ConnectResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConnectResponse.ProtoReflect.Descriptor instead.
+func (*ConnectResponse) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *ConnectResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ConnectResponse) GetKind() isConnectResponse_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetEvent() *Event {
+	if x != nil {
+		if x, ok := x.Kind.(*ConnectResponse_Event); ok {
+			return x.Event
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetResult() *CallResponse {
+	if x != nil {
+		if x, ok := x.Kind.(*ConnectResponse_Result); ok {
+			return x.Result
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetResponded() *RespondResponse {
+	if x != nil {
+		if x, ok := x.Kind.(*ConnectResponse_Responded); ok {
+			return x.Responded
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetError() *Error {
+	if x != nil {
+		if x, ok := x.Kind.(*ConnectResponse_Error); ok {
+			return x.Error
+		}
+	}
+	return nil
+}
+
+func (x *ConnectResponse) GetSubscribed() *Subscribed {
+	if x != nil {
+		if x, ok := x.Kind.(*ConnectResponse_Subscribed); ok {
+			return x.Subscribed
+		}
+	}
+	return nil
+}
+
+type isConnectResponse_Kind interface {
+	isConnectResponse_Kind()
+}
+
+type ConnectResponse_Event struct {
+	Event *Event `protobuf:"bytes,2,opt,name=event,proto3,oneof"`
+}
+
+type ConnectResponse_Result struct {
+	Result *CallResponse `protobuf:"bytes,3,opt,name=result,proto3,oneof"`
+}
+
+type ConnectResponse_Responded struct {
+	Responded *RespondResponse `protobuf:"bytes,4,opt,name=responded,proto3,oneof"`
+}
+
+type ConnectResponse_Error struct {
+	Error *Error `protobuf:"bytes,5,opt,name=error,proto3,oneof"`
+}
+
+type ConnectResponse_Subscribed struct {
+	// subscribed acknowledges a subscribe or unsubscribe.
+	Subscribed *Subscribed `protobuf:"bytes,6,opt,name=subscribed,proto3,oneof"`
+}
+
+func (*ConnectResponse_Event) isConnectResponse_Kind() {}
+
+func (*ConnectResponse_Result) isConnectResponse_Kind() {}
+
+func (*ConnectResponse_Responded) isConnectResponse_Kind() {}
+
+func (*ConnectResponse_Error) isConnectResponse_Kind() {}
+
+func (*ConnectResponse_Subscribed) isConnectResponse_Kind() {}
+
+type Subscribed struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Active        bool                   `protobuf:"varint,1,opt,name=active,proto3" json:"active,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Subscribed) Reset() {
+	*x = Subscribed{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Subscribed) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Subscribed) ProtoMessage() {}
+
+func (x *Subscribed) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Subscribed.ProtoReflect.Descriptor instead.
+func (*Subscribed) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *Subscribed) GetActive() bool {
+	if x != nil {
+		return x.Active
+	}
+	return false
+}
+
+// Error is the HTTP API's error envelope. Unary calls return it as the
+// ErrorInfo detail of their status instead: reason is the code and metadata
+// holds httpStatus.
+type Error struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	HttpStatus    int32                  `protobuf:"varint,3,opt,name=http_status,json=httpStatus,proto3" json:"http_status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Error) Reset() {
+	*x = Error{}
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Error) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Error) ProtoMessage() {}
+
+func (x *Error) ProtoReflect() protoreflect.Message {
+	mi := &file_darkhold_v1_darkhold_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Error.ProtoReflect.Descriptor instead.
+func (*Error) Descriptor() ([]byte, []int) {
+	return file_darkhold_v1_darkhold_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *Error) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Error) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Error) GetHttpStatus() int32 {
+	if x != nil {
+		return x.HttpStatus
+	}
+	return 0
+}
+
+var File_darkhold_v1_darkhold_proto protoreflect.FileDescriptor
+
+const file_darkhold_v1_darkhold_proto_rawDesc = "" +
+	"\n" +
+	"\x1adarkhold/v1/darkhold.proto\x12\vdarkhold.v1\"F\n" +
+	"\vCallRequest\x12\x16\n" +
+	"\x06method\x18\x01 \x01(\tR\x06method\x12\x1f\n" +
+	"\vparams_json\x18\x02 \x01(\tR\n" +
+	"paramsJson\"/\n" +
+	"\fCallResponse\x12\x1f\n" +
+	"\vresult_json\x18\x01 \x01(\tR\n" +
+	"resultJson\"\x9f\x01\n" +
+	"\x12ListThreadsRequest\x12\x12\n" +
+	"\x04sort\x18\x01 \x01(\tR\x04sort\x12\x14\n" +
+	"\x05order\x18\x02 \x01(\tR\x05order\x12\x14\n" +
+	"\x05limit\x18\x03 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06cursor\x18\x04 \x01(\tR\x06cursor\x12\x1d\n" +
+	"\n" +
+	"cwd_prefix\x18\x05 \x01(\tR\tcwdPrefix\x12\x12\n" +
+	"\x04tags\x18\x06 \x03(\tR\x04tags\"\xd6\x02\n" +
+	"\x06Thread\x12\x1b\n" +
+	"\tthread_id\x18\x01 \x01(\tR\bthreadId\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12\x10\n" +
+	"\x03cwd\x18\x03 \x01(\tR\x03cwd\x12\x12\n" +
+	"\x04tags\x18\x04 \x03(\tR\x04tags\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\x05 \x01(\x03R\tcreatedAt\x12\x1d\n" +
+	"\n" +
+	"updated_at\x18\x06 \x01(\x03R\tupdatedAt\x12(\n" +
+	"\x10last_activity_at\x18\a \x01(\x03R\x0elastActivityAt\x12\x1f\n" +
+	"\varchived_at\x18\b \x01(\x03R\n" +
+	"archivedAt\x121\n" +
+	"\x14pending_interactions\x18\t \x01(\x05R\x13pendingInteractions\x12\x1f\n" +
+	"\vactive_turn\x18\n" +
+	" \x01(\bR\n" +
+	"activeTurn\x12\x16\n" +
+	"\x06unread\x18\v \x01(\x05R\x06unread\"e\n" +
+	"\x13ListThreadsResponse\x12-\n" +
+	"\athreads\x18\x01 \x03(\v2\x13.darkhold.v1.ThreadR\athreads\x12\x1f\n" +
+	"\vnext_cursor\x18\x02 \x01(\tR\n" +
+	"nextCursor\"G\n" +
+	"\x12StartThreadRequest\x12\x10\n" +
+	"\x03cwd\x18\x01 \x01(\tR\x03cwd\x12\x1f\n" +
+	"\vparams_json\x18\x02 \x01(\tR\n" +
+	"paramsJson\"S\n" +
+	"\x13StartThreadResponse\x12\x1b\n" +
+	"\tthread_id\x18\x01 \x01(\tR\bthreadId\x12\x1f\n" +
+	"\vresult_json\x18\x02 \x01(\tR\n" +
+	"resultJson\"S\n" +
+	"\x13ResumeThreadRequest\x12\x1b\n" +
+	"\tthread_id\x18\x01 \x01(\tR\bthreadId\x12\x1f\n" +
+	"\vparams_json\x18\x02 \x01(\tR\n" +
+	"paramsJson\"7\n" +
+	"\x14ResumeThreadResponse\x12\x1f\n" +
+	"\vresult_json\x18\x01 \x01(\tR\n" +
+	"resultJson\"d\n" +
+	"\x10StartTurnRequest\x12\x1b\n" +
+	"\tthread_id\x18\x01 \x01(\tR\bthreadId\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\x12\x1f\n" +
+	"\vparams_json\x18\x03 \x01(\tR\n" +
+	"paramsJson\"M\n" +
+	"\x11StartTurnResponse\x12\x17\n" +
+	"\aturn_id\x18\x01 \x01(\tR\x06turnId\x12\x1f\n" +
+	"\vresult_json\x18\x02 \x01(\tR\n" +
+	"resultJson\"L\n" +
+	"\x14InterruptTurnRequest\x12\x1b\n" +
+	"\tthread_id\x18\x01 \x01(\tR\bthreadId\x12\x17\n" +
+	"\aturn_id\x18\x02 \x01(\tR\x06turnId\"8\n" +
+	"\x15InterruptTurnResponse\x12\x1f\n" +
+	"\vresult_json\x18\x01 \x01(\tR\n" +
+	"resultJson\"k\n" +
+	"\x10SubscribeRequest\x12\x1b\n" +
+	"\tthread_id\x18\x01 \x01(\tR\bthreadId\x12\x19\n" +
+	"\bafter_id\x18\x02 \x01(\tR\aafterId\x12\x1f\n" +
+	"\vrender_html\x18\x03 \x01(\bR\n" +
+	"renderHtml\"\xbc\x01\n" +
+	"\x05Event\x12\x1b\n" +
+	"\tthread_id\x18\x01 \x01(\tR\bthreadId\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\x10\n" +
+	"\x03seq\x18\x03 \x01(\x03R\x03seq\x12\x12\n" +
+	"\x04time\x18\x04 \x01(\x03R\x04time\x12\x16\n" +
+	"\x06method\x18\x05 \x01(\tR\x06method\x12!\n" +
+	"\fpayload_json\x18\x06 \x01(\tR\vpayloadJson\x12%\n" +
+	"\x0eschema_version\x18\a \x01(\x05R\rschemaVersion\"=\n" +
+	"\x11SubscribeResponse\x12(\n" +
+	"\x05event\x18\x01 \x01(\v2\x12.darkhold.v1.EventR\x05event\"\x8c\x01\n" +
+	"\x0eRespondRequest\x12\x1b\n" +
+	"\tthread_id\x18\x01 \x01(\tR\bthreadId\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x02 \x01(\tR\trequestId\x12\x1f\n" +
+	"\vresult_json\x18\x03 \x01(\tR\n" +
+	"resultJson\x12\x1d\n" +
+	"\n" +
+	"error_json\x18\x04 \x01(\tR\terrorJson\"C\n" +
+	"\x0fRespondResponse\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x12\x1c\n" +
+	"\tforwarded\x18\x02 \x01(\bR\tforwarded\"6\n" +
+	"\vUnsubscribe\x12'\n" +
+	"\x0fsubscription_id\x18\x01 \x01(\tR\x0esubscriptionId\"\x8e\x02\n" +
+	"\x0eConnectRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12=\n" +
+	"\tsubscribe\x18\x02 \x01(\v2\x1d.darkhold.v1.SubscribeRequestH\x00R\tsubscribe\x12<\n" +
+	"\vunsubscribe\x18\x03 \x01(\v2\x18.darkhold.v1.UnsubscribeH\x00R\vunsubscribe\x12.\n" +
+	"\x04call\x18\x04 \x01(\v2\x18.darkhold.v1.CallRequestH\x00R\x04call\x127\n" +
+	"\arespond\x18\x05 \x01(\v2\x1b.darkhold.v1.RespondRequestH\x00R\arespondB\x06\n" +
+	"\x04kind\"\xaf\x02\n" +
+	"\x0fConnectResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12*\n" +
+	"\x05event\x18\x02 \x01(\v2\x12.darkhold.v1.EventH\x00R\x05event\x123\n" +
+	"\x06result\x18\x03 \x01(\v2\x19.darkhold.v1.CallResponseH\x00R\x06result\x12<\n" +
+	"\tresponded\x18\x04 \x01(\v2\x1c.darkhold.v1.RespondResponseH\x00R\tresponded\x12*\n" +
+	"\x05error\x18\x05 \x01(\v2\x12.darkhold.v1.ErrorH\x00R\x05error\x129\n" +
+	"\n" +
+	"subscribed\x18\x06 \x01(\v2\x17.darkhold.v1.SubscribedH\x00R\n" +
+	"subscribedB\x06\n" +
+	"\x04kind\"$\n" +
+	"\n" +
+	"Subscribed\x12\x16\n" +
+	"\x06active\x18\x01 \x01(\bR\x06active\"V\n" +
+	"\x05Error\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x1f\n" +
+	"\vhttp_status\x18\x03 \x01(\x05R\n" +
+	"httpStatus2\xc5\x02\n" +
+	"\rThreadService\x12P\n" +
+	"\vListThreads\x12\x1f.darkhold.v1.ListThreadsRequest\x1a .darkhold.v1.ListThreadsResponse\x12P\n" +
+	"\vStartThread\x12\x1f.darkhold.v1.StartThreadRequest\x1a .darkhold.v1.StartThreadResponse\x12S\n" +
+	"\fResumeThread\x12 .darkhold.v1.ResumeThreadRequest\x1a!.darkhold.v1.ResumeThreadResponse\x12;\n" +
+	"\x04Call\x12\x18.darkhold.v1.CallRequest\x1a\x19.darkhold.v1.CallResponse2\xb1\x01\n" +
+	"\vTurnService\x12J\n" +
+	"\tStartTurn\x12\x1d.darkhold.v1.StartTurnRequest\x1a\x1e.darkhold.v1.StartTurnResponse\x12V\n" +
+	"\rInterruptTurn\x12!.darkhold.v1.InterruptTurnRequest\x1a\".darkhold.v1.InterruptTurnResponse2\xa6\x01\n" +
+	"\fEventService\x12L\n" +
+	"\tSubscribe\x12\x1d.darkhold.v1.SubscribeRequest\x1a\x1e.darkhold.v1.SubscribeResponse0\x01\x12H\n" +
+	"\aConnect\x12\x1b.darkhold.v1.ConnectRequest\x1a\x1c.darkhold.v1.ConnectResponse(\x010\x012Z\n" +
+	"\x12InteractionService\x12D\n" +
+	"\aRespond\x12\x1b.darkhold.v1.RespondRequest\x1a\x1c.darkhold.v1.RespondResponseB4Z2darkhold-go/internal/grpcapi/darkholdv1;darkholdv1b\x06proto3"
+
+var (
+	file_darkhold_v1_darkhold_proto_rawDescOnce sync.Once
+	file_darkhold_v1_darkhold_proto_rawDescData []byte
+)
+
+func file_darkhold_v1_darkhold_proto_rawDescGZIP() []byte {
+	file_darkhold_v1_darkhold_proto_rawDescOnce.Do(func() {
+		file_darkhold_v1_darkhold_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_darkhold_v1_darkhold_proto_rawDesc), len(file_darkhold_v1_darkhold_proto_rawDesc)))
+	})
+	return file_darkhold_v1_darkhold_proto_rawDescData
+}
+
+var file_darkhold_v1_darkhold_proto_msgTypes = make([]protoimpl.MessageInfo, 23)
+var file_darkhold_v1_darkhold_proto_goTypes = []any{
+	(*CallRequest)(nil),           // 0: darkhold.v1.CallRequest
+	(*CallResponse)(nil),          // 1: darkhold.v1.CallResponse
+	(*ListThreadsRequest)(nil),    // 2: darkhold.v1.ListThreadsRequest
+	(*Thread)(nil),                // 3: darkhold.v1.Thread
+	(*ListThreadsResponse)(nil),   // 4: darkhold.v1.ListThreadsResponse
+	(*StartThreadRequest)(nil),    // 5: darkhold.v1.StartThreadRequest
+	(*StartThreadResponse)(nil),   // 6: darkhold.v1.StartThreadResponse
+	(*ResumeThreadRequest)(nil),   // 7: darkhold.v1.ResumeThreadRequest
+	(*ResumeThreadResponse)(nil),  // 8: darkhold.v1.ResumeThreadResponse
+	(*StartTurnRequest)(nil),      // 9: darkhold.v1.StartTurnRequest
+	(*StartTurnResponse)(nil),     // 10: darkhold.v1.StartTurnResponse
+	(*InterruptTurnRequest)(nil),  // 11: darkhold.v1.InterruptTurnRequest
+	(*InterruptTurnResponse)(nil), // 12: darkhold.v1.InterruptTurnResponse
+	(*SubscribeRequest)(nil),      // 13: darkhold.v1.SubscribeRequest
+	(*Event)(nil),                 // 14: darkhold.v1.Event
+	(*SubscribeResponse)(nil),     // 15: darkhold.v1.SubscribeResponse
+	(*RespondRequest)(nil),        // 16: darkhold.v1.RespondRequest
+	(*RespondResponse)(nil),       // 17: darkhold.v1.RespondResponse
+	(*Unsubscribe)(nil),           // 18: darkhold.v1.Unsubscribe
+	(*ConnectRequest)(nil),        // 19: darkhold.v1.ConnectRequest
+	(*ConnectResponse)(nil),       // 20: darkhold.v1.ConnectResponse
+	(*Subscribed)(nil),            // 21: darkhold.v1.Subscribed
+	(*Error)(nil),                 // 22: darkhold.v1.Error
+}
+var file_darkhold_v1_darkhold_proto_depIdxs = []int32{
+	3,  // 0: darkhold.v1.ListThreadsResponse.threads:type_name -> darkhold.v1.Thread
+	14, // 1: darkhold.v1.SubscribeResponse.event:type_name -> darkhold.v1.Event
+	13, // 2: darkhold.v1.ConnectRequest.subscribe:type_name -> darkhold.v1.SubscribeRequest
+	18, // 3: darkhold.v1.ConnectRequest.unsubscribe:type_name -> darkhold.v1.Unsubscribe
+	0,  // 4: darkhold.v1.ConnectRequest.call:type_name -> darkhold.v1.CallRequest
+	16, // 5: darkhold.v1.ConnectRequest.respond:type_name -> darkhold.v1.RespondRequest
+	14, // 6: darkhold.v1.ConnectResponse.event:type_name -> darkhold.v1.Event
+	1,  // 7: darkhold.v1.ConnectResponse.result:type_name -> darkhold.v1.CallResponse
+	17, // 8: darkhold.v1.ConnectResponse.responded:type_name -> darkhold.v1.RespondResponse
+	22, // 9: darkhold.v1.ConnectResponse.error:type_name -> darkhold.v1.Error
+	21, // 10: darkhold.v1.ConnectResponse.subscribed:type_name -> darkhold.v1.Subscribed
+	2,  // 11: darkhold.v1.ThreadService.ListThreads:input_type -> darkhold.v1.ListThreadsRequest
+	5,  // 12: darkhold.v1.ThreadService.StartThread:input_type -> darkhold.v1.StartThreadRequest
+	7,  // 13: darkhold.v1.ThreadService.ResumeThread:input_type -> darkhold.v1.ResumeThreadRequest
+	0,  // 14: darkhold.v1.ThreadService.Call:input_type -> darkhold.v1.CallRequest
+	9,  // 15: darkhold.v1.TurnService.StartTurn:input_type -> darkhold.v1.StartTurnRequest
+	11, // 16: darkhold.v1.TurnService.InterruptTurn:input_type -> darkhold.v1.InterruptTurnRequest
+	13, // 17: darkhold.v1.EventService.Subscribe:input_type -> darkhold.v1.SubscribeRequest
+	19, // 18: darkhold.v1.EventService.Connect:input_type -> darkhold.v1.ConnectRequest
+	16, // 19: darkhold.v1.InteractionService.Respond:input_type -> darkhold.v1.RespondRequest
+	4,  // 20: darkhold.v1.ThreadService.ListThreads:output_type -> darkhold.v1.ListThreadsResponse
+	6,  // 21: darkhold.v1.ThreadService.StartThread:output_type -> darkhold.v1.StartThreadResponse
+	8,  // 22: darkhold.v1.ThreadService.ResumeThread:output_type -> darkhold.v1.ResumeThreadResponse
+	1,  // 23: darkhold.v1.ThreadService.Call:output_type -> darkhold.v1.CallResponse
+	10, // 24: darkhold.v1.TurnService.StartTurn:output_type -> darkhold.v1.StartTurnResponse
+	12, // 25: darkhold.v1.TurnService.InterruptTurn:output_type -> darkhold.v1.InterruptTurnResponse
+	15, // 26: darkhold.v1.EventService.Subscribe:output_type -> darkhold.v1.SubscribeResponse
+	20, // 27: darkhold.v1.EventService.Connect:output_type -> darkhold.v1.ConnectResponse
+	17, // 28: darkhold.v1.InteractionService.Respond:output_type -> darkhold.v1.RespondResponse
+	20, // [20:29] is the sub-list for method output_type
+	11, // [11:20] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
+}
+
+func init() { file_darkhold_v1_darkhold_proto_init() }
+func file_darkhold_v1_darkhold_proto_init() {
+	if File_darkhold_v1_darkhold_proto != nil {
+		return
+	}
+	file_darkhold_v1_darkhold_proto_msgTypes[19].OneofWrappers = []any{
+		(*ConnectRequest_Subscribe)(nil),
+		(*ConnectRequest_Unsubscribe)(nil),
+		(*ConnectRequest_Call)(nil),
+		(*ConnectRequest_Respond)(nil),
+	}
+	file_darkhold_v1_darkhold_proto_msgTypes[20].OneofWrappers = []any{
+		(*ConnectResponse_Event)(nil),
+		(*ConnectResponse_Result)(nil),
+		(*ConnectResponse_Responded)(nil),
+		(*ConnectResponse_Error)(nil),
+		(*ConnectResponse_Subscribed)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_darkhold_v1_darkhold_proto_rawDesc), len(file_darkhold_v1_darkhold_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   23,
+			NumExtensions: 0,
+			NumServices:   4,
+		},
+		GoTypes:           file_darkhold_v1_darkhold_proto_goTypes,
+		DependencyIndexes: file_darkhold_v1_darkhold_proto_depIdxs,
+		MessageInfos:      file_darkhold_v1_darkhold_proto_msgTypes,
+	}.Build()
+	File_darkhold_v1_darkhold_proto = out.File
+	file_darkhold_v1_darkhold_proto_goTypes = nil
+	file_darkhold_v1_darkhold_proto_depIdxs = nil
+}
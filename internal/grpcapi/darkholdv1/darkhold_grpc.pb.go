@@ -0,0 +1,650 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: darkhold/v1/darkhold.proto
+
+// darkhold.v1 is the gRPC API served on --grpc-port. Every call goes through
+// the same handler as its HTTP counterpart, so API keys, allowed CIDRs, audit
+// records and error codes are shared with the HTTP API.
+//
+// App-server payloads (RPC params and results, interaction results, thread
+// events) are carried as JSON text: darkhold passes the agent's protocol
+// through rather than modelling each method.
+//
+// Regenerate the Go code with `go generate ./internal/grpcapi`.
+
+package darkholdv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ThreadService_ListThreads_FullMethodName  = "/darkhold.v1.ThreadService/ListThreads"
+	ThreadService_StartThread_FullMethodName  = "/darkhold.v1.ThreadService/StartThread"
+	ThreadService_ResumeThread_FullMethodName = "/darkhold.v1.ThreadService/ResumeThread"
+	ThreadService_Call_FullMethodName         = "/darkhold.v1.ThreadService/Call"
+)
+
+// ThreadServiceClient is the client API for ThreadService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ThreadServiceClient interface {
+	// ListThreads is GET /api/threads.
+	ListThreads(ctx context.Context, in *ListThreadsRequest, opts ...grpc.CallOption) (*ListThreadsResponse, error)
+	// StartThread sends thread/start.
+	StartThread(ctx context.Context, in *StartThreadRequest, opts ...grpc.CallOption) (*StartThreadResponse, error)
+	// ResumeThread sends thread/resume.
+	ResumeThread(ctx context.Context, in *ResumeThreadRequest, opts ...grpc.CallOption) (*ResumeThreadResponse, error)
+	// Call sends any app-server request, like POST /api/rpc.
+	Call(ctx context.Context, in *CallRequest, opts ...grpc.CallOption) (*CallResponse, error)
+}
+
+type threadServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewThreadServiceClient(cc grpc.ClientConnInterface) ThreadServiceClient {
+	return &threadServiceClient{cc}
+}
+
+func (c *threadServiceClient) ListThreads(ctx context.Context, in *ListThreadsRequest, opts ...grpc.CallOption) (*ListThreadsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListThreadsResponse)
+	err := c.cc.Invoke(ctx, ThreadService_ListThreads_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *threadServiceClient) StartThread(ctx context.Context, in *StartThreadRequest, opts ...grpc.CallOption) (*StartThreadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StartThreadResponse)
+	err := c.cc.Invoke(ctx, ThreadService_StartThread_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *threadServiceClient) ResumeThread(ctx context.Context, in *ResumeThreadRequest, opts ...grpc.CallOption) (*ResumeThreadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResumeThreadResponse)
+	err := c.cc.Invoke(ctx, ThreadService_ResumeThread_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *threadServiceClient) Call(ctx context.Context, in *CallRequest, opts ...grpc.CallOption) (*CallResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CallResponse)
+	err := c.cc.Invoke(ctx, ThreadService_Call_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ThreadServiceServer is the server API for ThreadService service.
+// All implementations must embed UnimplementedThreadServiceServer
+// for forward compatibility.
+type ThreadServiceServer interface {
+	// ListThreads is GET /api/threads.
+	ListThreads(context.Context, *ListThreadsRequest) (*ListThreadsResponse, error)
+	// StartThread sends thread/start.
+	StartThread(context.Context, *StartThreadRequest) (*StartThreadResponse, error)
+	// ResumeThread sends thread/resume.
+	ResumeThread(context.Context, *ResumeThreadRequest) (*ResumeThreadResponse, error)
+	// Call sends any app-server request, like POST /api/rpc.
+	Call(context.Context, *CallRequest) (*CallResponse, error)
+	mustEmbedUnimplementedThreadServiceServer()
+}
+
+// UnimplementedThreadServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedThreadServiceServer struct{}
+
+func (UnimplementedThreadServiceServer) ListThreads(context.Context, *ListThreadsRequest) (*ListThreadsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListThreads not implemented")
+}
+func (UnimplementedThreadServiceServer) StartThread(context.Context, *StartThreadRequest) (*StartThreadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartThread not implemented")
+}
+func (UnimplementedThreadServiceServer) ResumeThread(context.Context, *ResumeThreadRequest) (*ResumeThreadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResumeThread not implemented")
+}
+func (UnimplementedThreadServiceServer) Call(context.Context, *CallRequest) (*CallResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Call not implemented")
+}
+func (UnimplementedThreadServiceServer) mustEmbedUnimplementedThreadServiceServer() {}
+func (UnimplementedThreadServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeThreadServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ThreadServiceServer will
+// result in compilation errors.
+type UnsafeThreadServiceServer interface {
+	mustEmbedUnimplementedThreadServiceServer()
+}
+
+func RegisterThreadServiceServer(s grpc.ServiceRegistrar, srv ThreadServiceServer) {
+	// If the following call pancis, it indicates UnimplementedThreadServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ThreadService_ServiceDesc, srv)
+}
+
+func _ThreadService_ListThreads_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListThreadsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ThreadServiceServer).ListThreads(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ThreadService_ListThreads_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ThreadServiceServer).ListThreads(ctx, req.(*ListThreadsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ThreadService_StartThread_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartThreadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ThreadServiceServer).StartThread(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ThreadService_StartThread_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ThreadServiceServer).StartThread(ctx, req.(*StartThreadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ThreadService_ResumeThread_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResumeThreadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ThreadServiceServer).ResumeThread(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ThreadService_ResumeThread_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ThreadServiceServer).ResumeThread(ctx, req.(*ResumeThreadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ThreadService_Call_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CallRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ThreadServiceServer).Call(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ThreadService_Call_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ThreadServiceServer).Call(ctx, req.(*CallRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ThreadService_ServiceDesc is the grpc.ServiceDesc for ThreadService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ThreadService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "darkhold.v1.ThreadService",
+	HandlerType: (*ThreadServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListThreads",
+			Handler:    _ThreadService_ListThreads_Handler,
+		},
+		{
+			MethodName: "StartThread",
+			Handler:    _ThreadService_StartThread_Handler,
+		},
+		{
+			MethodName: "ResumeThread",
+			Handler:    _ThreadService_ResumeThread_Handler,
+		},
+		{
+			MethodName: "Call",
+			Handler:    _ThreadService_Call_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "darkhold/v1/darkhold.proto",
+}
+
+const (
+	TurnService_StartTurn_FullMethodName     = "/darkhold.v1.TurnService/StartTurn"
+	TurnService_InterruptTurn_FullMethodName = "/darkhold.v1.TurnService/InterruptTurn"
+)
+
+// TurnServiceClient is the client API for TurnService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type TurnServiceClient interface {
+	// StartTurn sends turn/start with a text input.
+	StartTurn(ctx context.Context, in *StartTurnRequest, opts ...grpc.CallOption) (*StartTurnResponse, error)
+	// InterruptTurn sends turn/interrupt.
+	InterruptTurn(ctx context.Context, in *InterruptTurnRequest, opts ...grpc.CallOption) (*InterruptTurnResponse, error)
+}
+
+type turnServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTurnServiceClient(cc grpc.ClientConnInterface) TurnServiceClient {
+	return &turnServiceClient{cc}
+}
+
+func (c *turnServiceClient) StartTurn(ctx context.Context, in *StartTurnRequest, opts ...grpc.CallOption) (*StartTurnResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StartTurnResponse)
+	err := c.cc.Invoke(ctx, TurnService_StartTurn_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *turnServiceClient) InterruptTurn(ctx context.Context, in *InterruptTurnRequest, opts ...grpc.CallOption) (*InterruptTurnResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InterruptTurnResponse)
+	err := c.cc.Invoke(ctx, TurnService_InterruptTurn_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TurnServiceServer is the server API for TurnService service.
+// All implementations must embed UnimplementedTurnServiceServer
+// for forward compatibility.
+type TurnServiceServer interface {
+	// StartTurn sends turn/start with a text input.
+	StartTurn(context.Context, *StartTurnRequest) (*StartTurnResponse, error)
+	// InterruptTurn sends turn/interrupt.
+	InterruptTurn(context.Context, *InterruptTurnRequest) (*InterruptTurnResponse, error)
+	mustEmbedUnimplementedTurnServiceServer()
+}
+
+// UnimplementedTurnServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTurnServiceServer struct{}
+
+func (UnimplementedTurnServiceServer) StartTurn(context.Context, *StartTurnRequest) (*StartTurnResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartTurn not implemented")
+}
+func (UnimplementedTurnServiceServer) InterruptTurn(context.Context, *InterruptTurnRequest) (*InterruptTurnResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method InterruptTurn not implemented")
+}
+func (UnimplementedTurnServiceServer) mustEmbedUnimplementedTurnServiceServer() {}
+func (UnimplementedTurnServiceServer) testEmbeddedByValue()                     {}
+
+// UnsafeTurnServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TurnServiceServer will
+// result in compilation errors.
+type UnsafeTurnServiceServer interface {
+	mustEmbedUnimplementedTurnServiceServer()
+}
+
+func RegisterTurnServiceServer(s grpc.ServiceRegistrar, srv TurnServiceServer) {
+	// If the following call pancis, it indicates UnimplementedTurnServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&TurnService_ServiceDesc, srv)
+}
+
+func _TurnService_StartTurn_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartTurnRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TurnServiceServer).StartTurn(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TurnService_StartTurn_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TurnServiceServer).StartTurn(ctx, req.(*StartTurnRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TurnService_InterruptTurn_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InterruptTurnRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TurnServiceServer).InterruptTurn(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TurnService_InterruptTurn_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TurnServiceServer).InterruptTurn(ctx, req.(*InterruptTurnRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// TurnService_ServiceDesc is the grpc.ServiceDesc for TurnService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TurnService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "darkhold.v1.TurnService",
+	HandlerType: (*TurnServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "StartTurn",
+			Handler:    _TurnService_StartTurn_Handler,
+		},
+		{
+			MethodName: "InterruptTurn",
+			Handler:    _TurnService_InterruptTurn_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "darkhold/v1/darkhold.proto",
+}
+
+const (
+	EventService_Subscribe_FullMethodName = "/darkhold.v1.EventService/Subscribe"
+	EventService_Connect_FullMethodName   = "/darkhold.v1.EventService/Connect"
+)
+
+// EventServiceClient is the client API for EventService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type EventServiceClient interface {
+	// Subscribe streams a thread's stored events after after_id, then new ones
+	// as they are written, until the client cancels.
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SubscribeResponse], error)
+	// Connect carries subscriptions, app-server calls and interaction
+	// responses for any number of threads over one stream. Each reply echoes
+	// the id of the client message it answers; events carry their
+	// subscription's id.
+	Connect(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ConnectRequest, ConnectResponse], error)
+}
+
+type eventServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEventServiceClient(cc grpc.ClientConnInterface) EventServiceClient {
+	return &eventServiceClient{cc}
+}
+
+func (c *eventServiceClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SubscribeResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &EventService_ServiceDesc.Streams[0], EventService_Subscribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeRequest, SubscribeResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EventService_SubscribeClient = grpc.ServerStreamingClient[SubscribeResponse]
+
+func (c *eventServiceClient) Connect(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ConnectRequest, ConnectResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &EventService_ServiceDesc.Streams[1], EventService_Connect_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ConnectRequest, ConnectResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EventService_ConnectClient = grpc.BidiStreamingClient[ConnectRequest, ConnectResponse]
+
+// EventServiceServer is the server API for EventService service.
+// All implementations must embed UnimplementedEventServiceServer
+// for forward compatibility.
+type EventServiceServer interface {
+	// Subscribe streams a thread's stored events after after_id, then new ones
+	// as they are written, until the client cancels.
+	Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[SubscribeResponse]) error
+	// Connect carries subscriptions, app-server calls and interaction
+	// responses for any number of threads over one stream. Each reply echoes
+	// the id of the client message it answers; events carry their
+	// subscription's id.
+	Connect(grpc.BidiStreamingServer[ConnectRequest, ConnectResponse]) error
+	mustEmbedUnimplementedEventServiceServer()
+}
+
+// UnimplementedEventServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedEventServiceServer struct{}
+
+func (UnimplementedEventServiceServer) Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[SubscribeResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedEventServiceServer) Connect(grpc.BidiStreamingServer[ConnectRequest, ConnectResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Connect not implemented")
+}
+func (UnimplementedEventServiceServer) mustEmbedUnimplementedEventServiceServer() {}
+func (UnimplementedEventServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeEventServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EventServiceServer will
+// result in compilation errors.
+type UnsafeEventServiceServer interface {
+	mustEmbedUnimplementedEventServiceServer()
+}
+
+func RegisterEventServiceServer(s grpc.ServiceRegistrar, srv EventServiceServer) {
+	// If the following call pancis, it indicates UnimplementedEventServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&EventService_ServiceDesc, srv)
+}
+
+func _EventService_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EventServiceServer).Subscribe(m, &grpc.GenericServerStream[SubscribeRequest, SubscribeResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EventService_SubscribeServer = grpc.ServerStreamingServer[SubscribeResponse]
+
+func _EventService_Connect_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(EventServiceServer).Connect(&grpc.GenericServerStream[ConnectRequest, ConnectResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type EventService_ConnectServer = grpc.BidiStreamingServer[ConnectRequest, ConnectResponse]
+
+// EventService_ServiceDesc is the grpc.ServiceDesc for EventService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var EventService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "darkhold.v1.EventService",
+	HandlerType: (*EventServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _EventService_Subscribe_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Connect",
+			Handler:       _EventService_Connect_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "darkhold/v1/darkhold.proto",
+}
+
+const (
+	InteractionService_Respond_FullMethodName = "/darkhold.v1.InteractionService/Respond"
+)
+
+// InteractionServiceClient is the client API for InteractionService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type InteractionServiceClient interface {
+	// Respond is POST /api/thread/interaction/respond.
+	Respond(ctx context.Context, in *RespondRequest, opts ...grpc.CallOption) (*RespondResponse, error)
+}
+
+type interactionServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewInteractionServiceClient(cc grpc.ClientConnInterface) InteractionServiceClient {
+	return &interactionServiceClient{cc}
+}
+
+func (c *interactionServiceClient) Respond(ctx context.Context, in *RespondRequest, opts ...grpc.CallOption) (*RespondResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RespondResponse)
+	err := c.cc.Invoke(ctx, InteractionService_Respond_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// InteractionServiceServer is the server API for InteractionService service.
+// All implementations must embed UnimplementedInteractionServiceServer
+// for forward compatibility.
+type InteractionServiceServer interface {
+	// Respond is POST /api/thread/interaction/respond.
+	Respond(context.Context, *RespondRequest) (*RespondResponse, error)
+	mustEmbedUnimplementedInteractionServiceServer()
+}
+
+// UnimplementedInteractionServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedInteractionServiceServer struct{}
+
+func (UnimplementedInteractionServiceServer) Respond(context.Context, *RespondRequest) (*RespondResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Respond not implemented")
+}
+func (UnimplementedInteractionServiceServer) mustEmbedUnimplementedInteractionServiceServer() {}
+func (UnimplementedInteractionServiceServer) testEmbeddedByValue()                            {}
+
+// UnsafeInteractionServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to InteractionServiceServer will
+// result in compilation errors.
+type UnsafeInteractionServiceServer interface {
+	mustEmbedUnimplementedInteractionServiceServer()
+}
+
+func RegisterInteractionServiceServer(s grpc.ServiceRegistrar, srv InteractionServiceServer) {
+	// If the following call pancis, it indicates UnimplementedInteractionServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&InteractionService_ServiceDesc, srv)
+}
+
+func _InteractionService_Respond_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RespondRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InteractionServiceServer).Respond(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: InteractionService_Respond_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InteractionServiceServer).Respond(ctx, req.(*RespondRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// InteractionService_ServiceDesc is the grpc.ServiceDesc for InteractionService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var InteractionService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "darkhold.v1.InteractionService",
+	HandlerType: (*InteractionServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Respond",
+			Handler:    _InteractionService_Respond_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "darkhold/v1/darkhold.proto",
+}
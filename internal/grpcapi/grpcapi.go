@@ -0,0 +1,413 @@
+// Package grpcapi serves darkhold's gRPC API, defined in
+// proto/darkhold/v1/darkhold.proto. Like the MCP bridge it holds no state of
+// its own: every call is served in-process by the HTTP API handler, so gRPC
+// clients share the HTTP API's authentication, allow-lists, audit trail and
+// error codes.
+package grpcapi
+
+//go:generate buf generate ../../proto --template ../../buf.gen.yaml -o ../..
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	pb "darkhold-go/internal/grpcapi/darkholdv1"
+)
+
+// NewServer returns a gRPC server exposing the thread, turn, event and
+// interaction services, served by api.
+func NewServer(api http.Handler, opts ...grpc.ServerOption) *grpc.Server {
+	gs := grpc.NewServer(opts...)
+	Register(gs, api)
+	return gs
+}
+
+// Register adds the darkhold services to gs.
+func Register(gs grpc.ServiceRegistrar, api http.Handler) {
+	client := &apiClient{handler: api}
+	pb.RegisterThreadServiceServer(gs, &threadService{api: client})
+	pb.RegisterTurnServiceServer(gs, &turnService{api: client})
+	pb.RegisterEventServiceServer(gs, &eventService{api: client})
+	pb.RegisterInteractionServiceServer(gs, &interactionService{api: client})
+}
+
+// decodeParams parses an optional params_json object.
+func decodeParams(raw string) (map[string]any, error) {
+	params := map[string]any{}
+	if raw == "" {
+		return params, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &params); err != nil || params == nil {
+		return nil, &apiError{Status: http.StatusBadRequest, Code: "INVALID_JSON", Message: "params_json must be a JSON object."}
+	}
+	return params, nil
+}
+
+type threadService struct {
+	pb.UnimplementedThreadServiceServer
+	api *apiClient
+}
+
+func (s *threadService) ListThreads(ctx context.Context, req *pb.ListThreadsRequest) (*pb.ListThreadsResponse, error) {
+	query := url.Values{}
+	for key, value := range map[string]string{"sort": req.GetSort(), "order": req.GetOrder(), "cursor": req.GetCursor(), "cwdPrefix": req.GetCwdPrefix()} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	if req.GetLimit() != 0 {
+		query.Set("limit", strconv.Itoa(int(req.GetLimit())))
+	}
+	for _, tag := range req.GetTags() {
+		query.Add("tag", tag)
+	}
+	var page struct {
+		Threads []struct {
+			ThreadID            string   `json:"threadId"`
+			Title               string   `json:"title"`
+			Cwd                 string   `json:"cwd"`
+			Tags                []string `json:"tags"`
+			CreatedAt           int64    `json:"createdAt"`
+			UpdatedAt           int64    `json:"updatedAt"`
+			LastActivityAt      int64    `json:"lastActivityAt"`
+			ArchivedAt          int64    `json:"archivedAt"`
+			PendingInteractions int32    `json:"pendingInteractions"`
+			ActiveTurn          bool     `json:"activeTurn"`
+			Unread              int32    `json:"unread"`
+		} `json:"threads"`
+		NextCursor string `json:"nextCursor"`
+	}
+	if err := s.api.callJSON(ctx, http.MethodGet, "/api/threads", query, nil, &page); err != nil {
+		return nil, err
+	}
+	response := &pb.ListThreadsResponse{NextCursor: page.NextCursor, Threads: make([]*pb.Thread, 0, len(page.Threads))}
+	for _, entry := range page.Threads {
+		response.Threads = append(response.Threads, &pb.Thread{
+			ThreadId:            entry.ThreadID,
+			Title:               entry.Title,
+			Cwd:                 entry.Cwd,
+			Tags:                entry.Tags,
+			CreatedAt:           entry.CreatedAt,
+			UpdatedAt:           entry.UpdatedAt,
+			LastActivityAt:      entry.LastActivityAt,
+			ArchivedAt:          entry.ArchivedAt,
+			PendingInteractions: entry.PendingInteractions,
+			ActiveTurn:          entry.ActiveTurn,
+			Unread:              entry.Unread,
+		})
+	}
+	return response, nil
+}
+
+func (s *threadService) StartThread(ctx context.Context, req *pb.StartThreadRequest) (*pb.StartThreadResponse, error) {
+	params, err := decodeParams(req.GetParamsJson())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetCwd() != "" {
+		params["cwd"] = req.GetCwd()
+	}
+	result, err := s.api.rpc(ctx, "thread/start", params)
+	if err != nil {
+		return nil, err
+	}
+	var started struct {
+		Thread struct {
+			ID string `json:"id"`
+		} `json:"thread"`
+	}
+	_ = json.Unmarshal(result, &started)
+	return &pb.StartThreadResponse{ThreadId: started.Thread.ID, ResultJson: string(result)}, nil
+}
+
+func (s *threadService) ResumeThread(ctx context.Context, req *pb.ResumeThreadRequest) (*pb.ResumeThreadResponse, error) {
+	if req.GetThreadId() == "" {
+		return nil, invalidRequest("thread_id is required.")
+	}
+	params, err := decodeParams(req.GetParamsJson())
+	if err != nil {
+		return nil, err
+	}
+	params["threadId"] = req.GetThreadId()
+	result, err := s.api.rpc(ctx, "thread/resume", params)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ResumeThreadResponse{ResultJson: string(result)}, nil
+}
+
+func (s *threadService) Call(ctx context.Context, req *pb.CallRequest) (*pb.CallResponse, error) {
+	return call(ctx, s.api, req)
+}
+
+func call(ctx context.Context, api *apiClient, req *pb.CallRequest) (*pb.CallResponse, error) {
+	if req.GetMethod() == "" {
+		return nil, invalidRequest("method is required.")
+	}
+	var params map[string]any
+	if req.GetParamsJson() != "" {
+		var err error
+		if params, err = decodeParams(req.GetParamsJson()); err != nil {
+			return nil, err
+		}
+	}
+	result, err := api.rpc(ctx, req.GetMethod(), params)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CallResponse{ResultJson: string(result)}, nil
+}
+
+type turnService struct {
+	pb.UnimplementedTurnServiceServer
+	api *apiClient
+}
+
+func (s *turnService) StartTurn(ctx context.Context, req *pb.StartTurnRequest) (*pb.StartTurnResponse, error) {
+	if req.GetThreadId() == "" {
+		return nil, invalidRequest("thread_id is required.")
+	}
+	params, err := decodeParams(req.GetParamsJson())
+	if err != nil {
+		return nil, err
+	}
+	params["threadId"] = req.GetThreadId()
+	if req.GetText() != "" {
+		params["input"] = []any{map[string]any{"type": "text", "text": req.GetText()}}
+	}
+	result, err := s.api.rpc(ctx, "turn/start", params)
+	if err != nil {
+		return nil, err
+	}
+	var started struct {
+		Turn struct {
+			ID string `json:"id"`
+		} `json:"turn"`
+	}
+	_ = json.Unmarshal(result, &started)
+	return &pb.StartTurnResponse{TurnId: started.Turn.ID, ResultJson: string(result)}, nil
+}
+
+func (s *turnService) InterruptTurn(ctx context.Context, req *pb.InterruptTurnRequest) (*pb.InterruptTurnResponse, error) {
+	if req.GetThreadId() == "" || req.GetTurnId() == "" {
+		return nil, invalidRequest("thread_id and turn_id are required.")
+	}
+	result, err := s.api.rpc(ctx, "turn/interrupt", map[string]any{"threadId": req.GetThreadId(), "turnId": req.GetTurnId()})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.InterruptTurnResponse{ResultJson: string(result)}, nil
+}
+
+type interactionService struct {
+	pb.UnimplementedInteractionServiceServer
+	api *apiClient
+}
+
+func (s *interactionService) Respond(ctx context.Context, req *pb.RespondRequest) (*pb.RespondResponse, error) {
+	return respond(ctx, s.api, req)
+}
+
+func respond(ctx context.Context, api *apiClient, req *pb.RespondRequest) (*pb.RespondResponse, error) {
+	body := map[string]any{"threadId": req.GetThreadId(), "requestId": req.GetRequestId()}
+	switch {
+	case req.GetResultJson() != "" && req.GetErrorJson() != "":
+		return nil, invalidRequest("set either result_json or error_json, not both.")
+	case req.GetResultJson() != "":
+		body["result"] = json.RawMessage(req.GetResultJson())
+	case req.GetErrorJson() != "":
+		body["error"] = json.RawMessage(req.GetErrorJson())
+	default:
+		return nil, invalidRequest("result_json or error_json is required.")
+	}
+	for _, field := range []string{req.GetResultJson(), req.GetErrorJson()} {
+		if field != "" && !json.Valid([]byte(field)) {
+			return nil, &apiError{Status: http.StatusBadRequest, Code: "INVALID_JSON", Message: "result_json and error_json must be JSON."}
+		}
+	}
+	var answer struct {
+		Kind      string `json:"kind"`
+		Forwarded bool   `json:"forwarded"`
+	}
+	if err := api.callJSON(ctx, http.MethodPost, "/api/thread/interaction/respond", nil, body, &answer); err != nil {
+		return nil, err
+	}
+	return &pb.RespondResponse{Kind: answer.Kind, Forwarded: answer.Forwarded}, nil
+}
+
+type eventService struct {
+	pb.UnimplementedEventServiceServer
+	api *apiClient
+}
+
+func (s *eventService) Subscribe(req *pb.SubscribeRequest, stream grpc.ServerStreamingServer[pb.SubscribeResponse]) error {
+	return follow(stream.Context(), s.api, req, func(event *pb.Event) error {
+		return stream.Send(&pb.SubscribeResponse{Event: event})
+	})
+}
+
+// follow sends a thread's events after req.AfterId to send, then waits for
+// more with GET /api/thread/events/poll, until ctx ends or send fails.
+// Resuming from the last id each time means no event is skipped or
+// repeated between polls.
+func follow(ctx context.Context, api *apiClient, req *pb.SubscribeRequest, send func(*pb.Event) error) error {
+	if req.GetThreadId() == "" {
+		return invalidRequest("thread_id is required.")
+	}
+	afterID := req.GetAfterId()
+	for {
+		query := url.Values{"threadId": {req.GetThreadId()}}
+		if afterID != "" {
+			query.Set("afterId", afterID)
+		}
+		if req.GetRenderHtml() {
+			query.Set("render", "html")
+		}
+		var page struct {
+			SchemaVersion int32    `json:"schemaVersion"`
+			Events        []string `json:"events"`
+			Records       []struct {
+				ID   string `json:"id"`
+				Seq  int64  `json:"seq"`
+				Time int64  `json:"ts"`
+			} `json:"records"`
+			LastEventID string `json:"lastEventId"`
+		}
+		if err := api.callJSON(ctx, http.MethodGet, "/api/thread/events/poll", query, nil, &page); err != nil {
+			return err
+		}
+		for i, payload := range page.Events {
+			event := &pb.Event{ThreadId: req.GetThreadId(), PayloadJson: payload, SchemaVersion: page.SchemaVersion}
+			if i < len(page.Records) {
+				event.Id, event.Seq, event.Time = page.Records[i].ID, page.Records[i].Seq, page.Records[i].Time
+			}
+			var envelope struct {
+				Method string `json:"method"`
+			}
+			if json.Unmarshal([]byte(payload), &envelope) == nil {
+				event.Method = envelope.Method
+			}
+			if err := send(event); err != nil {
+				return err
+			}
+		}
+		if page.LastEventID != "" {
+			afterID = page.LastEventID
+		}
+	}
+}
+
+// Connect serves one multiplexed stream. Calls and responses run
+// concurrently, so a slow turn/start does not hold up events; replies go out
+// in completion order, matched by id. When the client half-closes, pending
+// calls are answered and the stream ends along with its subscriptions.
+func (s *eventService) Connect(stream grpc.BidiStreamingServer[pb.ConnectRequest, pb.ConnectResponse]) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	var sendMu sync.Mutex
+	send := func(message *pb.ConnectResponse) error {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		return stream.Send(message)
+	}
+	sendError := func(id string, err error) {
+		message := &pb.ConnectResponse{Id: id}
+		var apiErr *apiError
+		if errors.As(err, &apiErr) {
+			message.Kind = &pb.ConnectResponse_Error{Error: apiErr.proto()}
+		} else {
+			message.Kind = &pb.ConnectResponse_Error{Error: &pb.Error{Code: "INTERNAL", Message: status.Convert(err).Message(), HttpStatus: http.StatusInternalServerError}}
+		}
+		_ = send(message)
+	}
+
+	var subsMu sync.Mutex
+	subs := map[string]*context.CancelFunc{}
+	var calls, followers sync.WaitGroup
+	defer func() {
+		cancel()
+		calls.Wait()
+		followers.Wait()
+	}()
+
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			calls.Wait()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		id := req.GetId()
+		switch kind := req.GetKind().(type) {
+		case *pb.ConnectRequest_Subscribe:
+			subsMu.Lock()
+			_, taken := subs[id]
+			if id == "" || taken {
+				subsMu.Unlock()
+				sendError(id, invalidRequest("subscribe needs an id that no open subscription uses."))
+				continue
+			}
+			subCtx, stop := context.WithCancel(ctx)
+			subs[id] = &stop
+			subsMu.Unlock()
+			if err := send(&pb.ConnectResponse{Id: id, Kind: &pb.ConnectResponse_Subscribed{Subscribed: &pb.Subscribed{Active: true}}}); err != nil {
+				return err
+			}
+			followers.Go(func() {
+				err := follow(subCtx, s.api, kind.Subscribe, func(event *pb.Event) error {
+					return send(&pb.ConnectResponse{Id: id, Kind: &pb.ConnectResponse_Event{Event: event}})
+				})
+				subsMu.Lock()
+				if subs[id] == &stop {
+					delete(subs, id)
+				}
+				subsMu.Unlock()
+				stop()
+				if subCtx.Err() == nil {
+					sendError(id, err)
+				}
+			})
+		case *pb.ConnectRequest_Unsubscribe:
+			subsMu.Lock()
+			if stop, ok := subs[kind.Unsubscribe.GetSubscriptionId()]; ok {
+				(*stop)()
+				delete(subs, kind.Unsubscribe.GetSubscriptionId())
+			}
+			subsMu.Unlock()
+			if err := send(&pb.ConnectResponse{Id: id, Kind: &pb.ConnectResponse_Subscribed{Subscribed: &pb.Subscribed{Active: false}}}); err != nil {
+				return err
+			}
+		case *pb.ConnectRequest_Call:
+			calls.Go(func() {
+				result, err := call(ctx, s.api, kind.Call)
+				if err != nil {
+					sendError(id, err)
+					return
+				}
+				_ = send(&pb.ConnectResponse{Id: id, Kind: &pb.ConnectResponse_Result{Result: result}})
+			})
+		case *pb.ConnectRequest_Respond:
+			calls.Go(func() {
+				answer, err := respond(ctx, s.api, kind.Respond)
+				if err != nil {
+					sendError(id, err)
+					return
+				}
+				_ = send(&pb.ConnectResponse{Id: id, Kind: &pb.ConnectResponse_Responded{Responded: answer}})
+			})
+		default:
+			sendError(id, invalidRequest("one of subscribe, unsubscribe, call or respond is required."))
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "darkhold-go/internal/grpcapi/darkholdv1"
+)
+
+// fakeAPI answers the HTTP routes the gRPC services call. Events appended
+// with emit are served by the poll route.
+type fakeAPI struct {
+	mu     sync.Mutex
+	events []string
+	added  chan struct{}
+	calls  []map[string]any
+}
+
+func (f *fakeAPI) emit(method string) {
+	f.mu.Lock()
+	payload, _ := json.Marshal(map[string]any{"method": method, "params": map[string]any{"threadId": "t1"}})
+	f.events = append(f.events, string(payload))
+	f.mu.Unlock()
+	f.added <- struct{}{}
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":"a valid API key is required.","code":"UNAUTHORIZED"}`))
+		return
+	}
+	switch r.URL.Path {
+	case "/api/threads":
+		_, _ = w.Write([]byte(`{"threads":[{"threadId":"t1","cwd":"/work","tags":["` + r.URL.Query().Get("tag") + `"],"pendingInteractions":2,"activeTurn":true}],"nextCursor":"c2"}`))
+	case "/api/rpc":
+		var request map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		f.mu.Lock()
+		f.calls = append(f.calls, request)
+		f.mu.Unlock()
+		switch request["method"] {
+		case "thread/start":
+			_, _ = w.Write([]byte(`{"thread":{"id":"t1"}}`))
+		case "turn/start":
+			_, _ = w.Write([]byte(`{"turn":{"id":"turn-1","status":"inProgress"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"thread not found: t9","code":"THREAD_NOT_FOUND","details":{"rpcError":{"code":-32600}}}`))
+		}
+	case "/api/thread/interaction/respond":
+		var request map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		if request["requestId"] == "done" {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error":"interaction request not found or already resolved.","code":"INTERACTION_RESOLVED"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"kind":"commandApproval"}`))
+	case "/api/thread/events/poll":
+		after := -1
+		if id := r.URL.Query().Get("afterId"); id != "" {
+			after, _ = strconv.Atoi(id)
+		}
+		for {
+			f.mu.Lock()
+			fresh := f.events[after+1:]
+			f.mu.Unlock()
+			if len(fresh) > 0 {
+				records := make([]map[string]any, len(fresh))
+				for i := range fresh {
+					records[i] = map[string]any{"id": strconv.Itoa(after + 1 + i), "seq": after + 2 + i, "ts": 1000}
+				}
+				body, _ := json.Marshal(map[string]any{"schemaVersion": 1, "events": fresh, "records": records, "lastEventId": strconv.Itoa(after + len(fresh))})
+				_, _ = w.Write(body)
+				return
+			}
+			select {
+			case <-f.added:
+			case <-time.After(20 * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func dial(t *testing.T, api http.Handler) *grpc.ClientConn {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	gs := NewServer(api)
+	go func() { _ = gs.Serve(listener) }()
+	t.Cleanup(gs.Stop)
+	conn, err := grpc.NewClient("passthrough:///darkhold",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func authed(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+}
+
+func TestUnaryCallsGoThroughTheAPI(t *testing.T) {
+	api := &fakeAPI{added: make(chan struct{}, 16)}
+	conn := dial(t, api)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := pb.NewThreadServiceClient(conn).ListThreads(ctx, &pb.ListThreadsRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected calls without a key to be refused, got %v", err)
+	}
+	ctx = authed(ctx)
+	threads, err := pb.NewThreadServiceClient(conn).ListThreads(ctx, &pb.ListThreadsRequest{Tags: []string{"ci"}})
+	if err != nil || len(threads.Threads) != 1 || threads.Threads[0].Tags[0] != "ci" || threads.Threads[0].PendingInteractions != 2 || !threads.Threads[0].ActiveTurn || threads.NextCursor != "c2" {
+		t.Fatalf("unexpected thread list: %v, %v", threads, err)
+	}
+	started, err := pb.NewThreadServiceClient(conn).StartThread(ctx, &pb.StartThreadRequest{Cwd: "/work"})
+	if err != nil || started.ThreadId != "t1" {
+		t.Fatalf("unexpected thread/start answer: %v, %v", started, err)
+	}
+	turn, err := pb.NewTurnServiceClient(conn).StartTurn(ctx, &pb.StartTurnRequest{ThreadId: "t1", Text: "hello", ParamsJson: `{"model":"m"}`})
+	if err != nil || turn.TurnId != "turn-1" {
+		t.Fatalf("unexpected turn/start answer: %v, %v", turn, err)
+	}
+	params := api.calls[1]["params"].(map[string]any)
+	if params["model"] != "m" || params["input"].([]any)[0].(map[string]any)["text"] != "hello" {
+		t.Fatalf("expected text and extra params to be sent: %v", params)
+	}
+
+	_, err = pb.NewThreadServiceClient(conn).Call(ctx, &pb.CallRequest{Method: "thread/read", ParamsJson: `{"threadId":"t9"}`})
+	st := status.Convert(err)
+	if st.Code() != codes.NotFound || len(st.Details()) != 1 {
+		t.Fatalf("expected a NotFound status with details, got %v", err)
+	}
+	if info := st.Details()[0].(*errdetails.ErrorInfo); info.Reason != "THREAD_NOT_FOUND" || info.Metadata["httpStatus"] != "404" || info.Metadata["details"] == "" {
+		t.Fatalf("unexpected error info: %v", info)
+	}
+	if _, err := pb.NewThreadServiceClient(conn).Call(ctx, &pb.CallRequest{Method: "x", ParamsJson: "[1]"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected params that are not an object to be rejected, got %v", err)
+	}
+
+	answer, err := pb.NewInteractionServiceClient(conn).Respond(ctx, &pb.RespondRequest{ThreadId: "t1", RequestId: "7", ResultJson: `{"decision":"accept"}`})
+	if err != nil || answer.Kind != "commandApproval" {
+		t.Fatalf("unexpected respond answer: %v, %v", answer, err)
+	}
+	if _, err := pb.NewInteractionServiceClient(conn).Respond(ctx, &pb.RespondRequest{ThreadId: "t1", RequestId: "done", ResultJson: `{}`}); status.Code(err) != codes.Aborted {
+		t.Fatalf("expected a resolved interaction to be Aborted, got %v", err)
+	}
+}
+
+func TestSubscribeFollowsTheLogAcrossPolls(t *testing.T) {
+	api := &fakeAPI{added: make(chan struct{}, 16)}
+	api.emit("thread/started")
+	conn := dial(t, api)
+	ctx, cancel := context.WithTimeout(authed(context.Background()), 5*time.Second)
+	defer cancel()
+
+	stream, err := pb.NewEventServiceClient(conn).Subscribe(ctx, &pb.SubscribeRequest{ThreadId: "t1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"thread/started", "turn/started", "turn/completed"}
+	for i, method := range want {
+		if i > 0 {
+			api.emit(method)
+		}
+		got, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Event.Method != method || got.Event.Id != strconv.Itoa(i) || got.Event.SchemaVersion != 1 {
+			t.Fatalf("event %d: unexpected %v", i, got.Event)
+		}
+	}
+}
+
+func TestConnectMultiplexesSubscriptionsAndCalls(t *testing.T) {
+	api := &fakeAPI{added: make(chan struct{}, 16)}
+	conn := dial(t, api)
+	ctx, cancel := context.WithTimeout(authed(context.Background()), 5*time.Second)
+	defer cancel()
+
+	stream, err := pb.NewEventServiceClient(conn).Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func(message *pb.ConnectRequest) {
+		if err := stream.Send(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	send(&pb.ConnectRequest{Id: "sub", Kind: &pb.ConnectRequest_Subscribe{Subscribe: &pb.SubscribeRequest{ThreadId: "t1"}}})
+	send(&pb.ConnectRequest{Id: "sub", Kind: &pb.ConnectRequest_Subscribe{Subscribe: &pb.SubscribeRequest{ThreadId: "t1"}}})
+	send(&pb.ConnectRequest{Id: "call", Kind: &pb.ConnectRequest_Call{Call: &pb.CallRequest{Method: "turn/start", ParamsJson: `{"threadId":"t1"}`}}})
+	send(&pb.ConnectRequest{Id: "answer", Kind: &pb.ConnectRequest_Respond{Respond: &pb.RespondRequest{ThreadId: "t1", RequestId: "done", ResultJson: `{}`}}})
+	api.emit("turn/started")
+
+	got := map[string][]*pb.ConnectResponse{}
+	for len(got["sub"]) < 3 || len(got["call"]) < 1 || len(got["answer"]) < 1 {
+		message, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("after %v: %v", got, err)
+		}
+		got[message.Id] = append(got[message.Id], message)
+	}
+	if !got["sub"][0].GetSubscribed().GetActive() {
+		t.Fatalf("expected the subscription to be acknowledged first: %v", got["sub"])
+	}
+	duplicate, event := got["sub"][1].GetError(), got["sub"][2].GetEvent()
+	if event == nil {
+		duplicate, event = got["sub"][2].GetError(), got["sub"][1].GetEvent()
+	}
+	if duplicate.GetCode() != "INVALID_REQUEST" || event.GetMethod() != "turn/started" {
+		t.Fatalf("expected a refused duplicate and the event: %v", got["sub"])
+	}
+	if result := got["call"][0].GetResult(); result == nil || result.ResultJson != `{"turn":{"id":"turn-1","status":"inProgress"}}` {
+		t.Fatalf("unexpected call reply: %v", got["call"])
+	}
+	if failure := got["answer"][0].GetError(); failure.GetCode() != "INTERACTION_RESOLVED" || failure.GetHttpStatus() != http.StatusConflict {
+		t.Fatalf("unexpected respond reply: %v", got["answer"])
+	}
+
+	send(&pb.ConnectRequest{Id: "stop", Kind: &pb.ConnectRequest_Unsubscribe{Unsubscribe: &pb.Unsubscribe{SubscriptionId: "sub"}}})
+	if message, err := stream.Recv(); err != nil || message.Id != "stop" || message.GetSubscribed().GetActive() {
+		t.Fatalf("expected the unsubscribe to be acknowledged: %v, %v", message, err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if !errors.Is(err, io.EOF) {
+				t.Fatalf("expected the stream to end cleanly, got %v", err)
+			}
+			break
+		}
+	}
+}
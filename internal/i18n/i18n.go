@@ -0,0 +1,145 @@
+// Package i18n holds darkhold's message catalogs and picks a locale for a
+// request from its Accept-Language header.
+//
+// A catalog is a flat JSON object per locale under locales/. Keys such as
+// "error.THREAD_NOT_FOUND" or "notify.turn.completed" name a message and are
+// defined in en.json, which every other catalog must cover. The other
+// catalogs may also translate server strings verbatim, keyed by their
+// English text, so a specific message ("threadId is required.") reads better
+// than the generic one for its error code.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLocale is the locale server strings are written in.
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var catalogFiles embed.FS
+
+var catalogs = mustLoadCatalogs()
+
+func mustLoadCatalogs() map[string]map[string]string {
+	entries, err := catalogFiles.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	out := make(map[string]map[string]string, len(entries))
+	for _, entry := range entries {
+		data, err := catalogFiles.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var catalog map[string]string
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			panic(fmt.Sprintf("i18n: %s: %v", entry.Name(), err))
+		}
+		out[strings.TrimSuffix(entry.Name(), ".json")] = catalog
+	}
+	return out
+}
+
+// Locales returns the locales with a catalog, sorted.
+func Locales() []string {
+	locales := make([]string, 0, len(catalogs))
+	for locale := range catalogs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Supported returns the catalog locale for a language tag: the tag itself
+// ("de") or its primary language ("de-CH" -> "de"), case-insensitively.
+func Supported(tag string) (string, bool) {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if _, ok := catalogs[tag]; ok {
+		return tag, true
+	}
+	base, _, _ := strings.Cut(tag, "-")
+	if _, ok := catalogs[base]; ok {
+		return base, true
+	}
+	return "", false
+}
+
+// Negotiate picks the supported locale the Accept-Language header value
+// prefers most, or fallback when it names none ("*" also means fallback).
+func Negotiate(acceptLanguage, fallback string) string {
+	type choice struct {
+		locale string
+		q      float64
+	}
+	var choices []choice
+	for part := range strings.SplitSeq(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		locale, ok := Supported(tag)
+		if strings.TrimSpace(tag) == "*" {
+			locale, ok = fallback, true
+		}
+		if ok {
+			choices = append(choices, choice{locale: locale, q: q})
+		}
+	}
+	if len(choices) == 0 {
+		return fallback
+	}
+	// MaxFunc keeps the first of equal choices, as the header lists them in
+	// order of preference.
+	best := slices.MaxFunc(choices, func(a, b choice) int {
+		switch {
+		case a.q < b.q:
+			return -1
+		case a.q > b.q:
+			return 1
+		}
+		return 0
+	})
+	return best.locale
+}
+
+// Lookup returns the message for key in locale, falling back to the English
+// catalog, with each {name} placeholder replaced by vars[name].
+func Lookup(locale, key string, vars map[string]string) (string, bool) {
+	message, ok := catalogs[locale][key]
+	if !ok {
+		message, ok = catalogs[DefaultLocale][key]
+	}
+	if !ok {
+		return "", false
+	}
+	for name, value := range vars {
+		message = strings.ReplaceAll(message, "{"+name+"}", value)
+	}
+	return message, true
+}
+
+// Translate returns locale's verbatim translation of an English server
+// string, if its catalog has one.
+func Translate(locale, message string) (string, bool) {
+	if locale == DefaultLocale {
+		return "", false
+	}
+	text, ok := catalogs[locale][message]
+	return text, ok
+}
@@ -0,0 +1,61 @@
+package i18n
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCatalogsCoverEnglishKeys(t *testing.T) {
+	placeholder := regexp.MustCompile(`\{[a-zA-Z]+\}`)
+	for locale, catalog := range catalogs {
+		for key, english := range catalogs[DefaultLocale] {
+			message, ok := catalog[key]
+			if !ok {
+				t.Errorf("%s is missing %q", locale, key)
+				continue
+			}
+			if want, got := placeholder.FindAllString(english, -1), placeholder.FindAllString(message, -1); !slices.Equal(want, got) {
+				t.Errorf("%s %q has placeholders %v, want %v", locale, key, got, want)
+			}
+		}
+		for key := range catalog {
+			if _, ok := catalogs[DefaultLocale][key]; !ok && (strings.HasPrefix(key, "error.") || strings.HasPrefix(key, "notify.")) {
+				t.Errorf("%s defines %q, which en.json does not", locale, key)
+			}
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                            "en",
+		"de-CH, de;q=0.9, en;q=0.8":   "de",
+		"ja, fr;q=0.5":                "fr",
+		"en;q=0.4, es;q=0.9":          "es",
+		"es, fr":                      "es",
+		"fr;q=0, *":                   "en",
+		"pt-BR":                       "en",
+		"FR-ca;q=0.7, garbage;q=nope": "fr",
+	} {
+		if got := Negotiate(header, DefaultLocale); got != want {
+			t.Errorf("Negotiate(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestLookupAndTranslate(t *testing.T) {
+	if text, ok := Lookup("de", "notify.approval.command", map[string]string{"command": "make test"}); !ok || text != "Freigabe erforderlich, um auszuführen: make test" {
+		t.Fatalf("unexpected lookup %q %v", text, ok)
+	}
+	if text, ok := Lookup("xx", "notify.turn.completed", nil); !ok || text != "Turn completed." {
+		t.Fatalf("expected unknown locales to fall back to English, got %q %v", text, ok)
+	}
+	if text, ok := Translate("es", "threadId is required."); !ok || text != "Falta threadId." {
+		t.Fatalf("unexpected translation %q %v", text, ok)
+	}
+	if _, ok := Translate("en", "threadId is required."); ok {
+		t.Fatal("English strings should not be translated")
+	}
+}
@@ -0,0 +1,270 @@
+// Package indexdb keeps darkhold's thread index and usage counters in one
+// embedded bbolt database under the data dir. Every change is its own small
+// transaction, so a crash mid-write loses at most that change instead of
+// leaving a half-rewritten JSON document.
+package indexdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"darkhold-go/internal/netfs"
+	"darkhold-go/internal/threads"
+	"darkhold-go/internal/usage"
+)
+
+const (
+	// FileName is the database file inside the data dir.
+	FileName = "index.db"
+	// Legacy documents imported when the database is first created.
+	threadsFile = "threads.json"
+	usageFile   = "usage.json"
+	// migratedSuffix is appended to legacy documents once imported.
+	migratedSuffix = ".migrated"
+	// openTimeout bounds the wait for another process's lock on the file.
+	openTimeout = time.Second
+)
+
+var (
+	bucketThreads    = []byte("threads")
+	bucketWorkspaces = []byte("workspaces")
+	// bucketUsage holds one nested bucket named after the day it counts.
+	bucketUsage = []byte("usage")
+)
+
+// DB is an open index database.
+type DB struct {
+	db *bolt.DB
+	// releaseOwner removes the owner directory taken on network
+	// filesystems (see claimOwner).
+	releaseOwner func()
+}
+
+// Open opens (creating if needed) the database in dir. A new database
+// imports threads.json and usage.json from dir, which are then renamed with
+// a .migrated suffix. Outside Windows, whose SMB locks are enforced by the
+// file server, a dir on a network filesystem is also claimed with an owner
+// directory, since flock cannot be relied on there.
+func Open(dir string) (*DB, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	releaseOwner := func() {}
+	if runtime.GOOS != "windows" && netfs.Detect(dir).Network {
+		release, err := claimOwner(dir)
+		if err != nil {
+			return nil, err
+		}
+		releaseOwner = release
+	}
+	path := filepath.Join(dir, FileName)
+	_, statErr := os.Stat(path)
+	fresh := errors.Is(statErr, os.ErrNotExist)
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		releaseOwner()
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	d := &DB{db: db, releaseOwner: releaseOwner}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketThreads, bucketWorkspaces, bucketUsage} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		_ = d.Close()
+		return nil, err
+	}
+	if fresh {
+		if err := d.importLegacy(dir); err != nil {
+			_ = d.Close()
+			_ = os.Remove(path)
+			return nil, fmt.Errorf("import legacy index: %w", err)
+		}
+	}
+	return d, nil
+}
+
+// Close releases the database file.
+func (d *DB) Close() error {
+	err := d.db.Close()
+	d.releaseOwner()
+	return err
+}
+
+// importLegacy copies the JSON documents into the database in one
+// transaction, then sets them aside.
+func (d *DB) importLegacy(dir string) error {
+	var imported []string
+	legacyIndex := filepath.Join(dir, threadsFile)
+	legacyUsage := filepath.Join(dir, usageFile)
+	ix, err := threads.Open(legacyIndex)
+	if err != nil {
+		return err
+	}
+	tracker, err := usage.Open(legacyUsage)
+	if err != nil {
+		return err
+	}
+	err = d.db.Update(func(tx *bolt.Tx) error {
+		for _, meta := range ix.List() {
+			if err := putJSON(tx.Bucket(bucketThreads), meta.ThreadID, meta); err != nil {
+				return err
+			}
+		}
+		for _, ws := range ix.ListWorkspaces() {
+			if err := putJSON(tx.Bucket(bucketWorkspaces), ws.ID, ws); err != nil {
+				return err
+			}
+		}
+		day, counts := tracker.Snapshot()
+		for key, c := range counts {
+			if err := saveCounts(tx, day, key, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, path := range []string{legacyIndex, legacyUsage} {
+		if _, err := os.Stat(path); err == nil {
+			imported = append(imported, path)
+		}
+	}
+	for _, path := range imported {
+		if err := os.Rename(path, path+migratedSuffix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func putJSON(bucket *bolt.Bucket, key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(key), data)
+}
+
+// Threads returns the thread index store.
+func (d *DB) Threads() threads.Store {
+	return threadStore{d.db}
+}
+
+// Usage returns the usage counter store.
+func (d *DB) Usage() usage.Store {
+	return usageStore{d.db}
+}
+
+type threadStore struct {
+	db *bolt.DB
+}
+
+func (s threadStore) Load() ([]*threads.Metadata, []*threads.Workspace, error) {
+	var metas []*threads.Metadata
+	var workspaces []*threads.Workspace
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(bucketThreads).ForEach(func(key, value []byte) error {
+			var meta threads.Metadata
+			if err := json.Unmarshal(value, &meta); err != nil {
+				return fmt.Errorf("thread %s: %w", key, err)
+			}
+			metas = append(metas, &meta)
+			return nil
+		}); err != nil {
+			return err
+		}
+		return tx.Bucket(bucketWorkspaces).ForEach(func(key, value []byte) error {
+			var ws threads.Workspace
+			if err := json.Unmarshal(value, &ws); err != nil {
+				return fmt.Errorf("workspace %s: %w", key, err)
+			}
+			workspaces = append(workspaces, &ws)
+			return nil
+		})
+	})
+	return metas, workspaces, err
+}
+
+func (s threadStore) PutThread(meta *threads.Metadata) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(bucketThreads), meta.ThreadID, meta)
+	})
+}
+
+func (s threadStore) PutWorkspace(ws *threads.Workspace) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(bucketWorkspaces), ws.ID, ws)
+	})
+}
+
+func (s threadStore) DeleteWorkspace(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketWorkspaces).Delete([]byte(id))
+	})
+}
+
+type usageStore struct {
+	db *bolt.DB
+}
+
+func (s usageStore) LoadDay(day string) (map[string]usage.Counts, error) {
+	out := map[string]usage.Counts{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketUsage).Bucket([]byte(day))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(key, value []byte) error {
+			var counts usage.Counts
+			if err := json.Unmarshal(value, &counts); err != nil {
+				return fmt.Errorf("usage %s/%s: %w", day, key, err)
+			}
+			out[string(key)] = counts
+			return nil
+		})
+	})
+	return out, err
+}
+
+func (s usageStore) SaveCounts(day, key string, counts usage.Counts) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return saveCounts(tx, day, key, counts)
+	})
+}
+
+// saveCounts stores key's counts for day, dropping any other day's bucket.
+func saveCounts(tx *bolt.Tx, day, key string, counts usage.Counts) error {
+	root := tx.Bucket(bucketUsage)
+	var stale [][]byte
+	if err := root.ForEachBucket(func(name []byte) error {
+		if string(name) != day {
+			stale = append(stale, append([]byte(nil), name...))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, name := range stale {
+		if err := root.DeleteBucket(name); err != nil {
+			return err
+		}
+	}
+	bucket, err := root.CreateBucketIfNotExists([]byte(day))
+	if err != nil {
+		return err
+	}
+	return putJSON(bucket, key, counts)
+}
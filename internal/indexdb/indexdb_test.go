@@ -0,0 +1,161 @@
+package indexdb
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/threads"
+	"darkhold-go/internal/usage"
+)
+
+func TestIndexPersistsRecordsAcrossOpen(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ix, err := threads.OpenStore(db.Threads())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"t1", "t2"} {
+		if _, err := ix.Update(id, func(m *threads.Metadata) error {
+			m.Title, m.Tags = "title "+id, []string{"ci"}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := ix.CreateWorkspace(threads.Workspace{Name: "api", Cwd: "/work/api"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ix.CreateWorkspace(threads.Workspace{Name: "web", Cwd: "/work/web"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ix.DeleteWorkspace("web"); err != nil {
+		t.Fatal(err)
+	}
+	tracker, err := usage.OpenStore(db.Usage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = tracker.AddTurn("alice")
+	_ = tracker.AddTokens("alice", 120)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	reopened, err := threads.OpenStore(db.Threads())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta, ok := reopened.Get("t2"); !ok || meta.Title != "title t2" || !meta.HasTag("ci") || len(reopened.List()) != 2 {
+		t.Fatalf("unexpected threads after reopening: %+v", reopened.List())
+	}
+	if list := reopened.ListWorkspaces(); len(list) != 1 || list[0].ID != "api" {
+		t.Fatalf("unexpected workspaces after reopening: %+v", list)
+	}
+	tracker, err = usage.OpenStore(db.Usage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts := tracker.Get("alice"); counts.Turns != 1 || counts.Tokens != 120 {
+		t.Fatalf("unexpected usage after reopening: %+v", counts)
+	}
+
+	// A later day replaces the stored one.
+	if err := db.Usage().SaveCounts("2999-01-01", "bob", usage.Counts{Turns: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if today, _ := db.Usage().LoadDay(usage.Day(time.Now())); len(today) != 0 {
+		t.Fatalf("expected the previous day's counts to be dropped, got %v", today)
+	}
+}
+
+func TestOpenImportsLegacyDocuments(t *testing.T) {
+	dir := t.TempDir()
+	legacy, err := threads.Open(filepath.Join(dir, threadsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := legacy.Update("old-thread", func(m *threads.Metadata) error {
+		_, err := m.AddNote("use pnpm", true)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	legacyUsage, err := usage.Open(filepath.Join(dir, usageFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = legacyUsage.AddTurn("anonymous")
+
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ix, err := threads.OpenStore(db.Threads())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta, ok := ix.Get("old-thread"); !ok || len(meta.PinnedNotes()) != 1 {
+		t.Fatalf("expected the legacy thread to be imported: %+v", meta)
+	}
+	tracker, err := usage.OpenStore(db.Usage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracker.Get("anonymous").Turns != 1 {
+		t.Fatal("expected legacy usage to be imported")
+	}
+	for _, name := range []string{threadsFile, usageFile} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be set aside, got %v", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, name+migratedSuffix)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestClaimOwnerRefusesOtherHosts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ownerDir)
+	host, _ := os.Hostname()
+	if err := os.Mkdir(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "owner"), []byte("other-host 42\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := claimOwner(dir); err == nil || !strings.Contains(err.Error(), "other-host 42") {
+		t.Fatalf("expected a claim held by another host to be refused, got %v", err)
+	}
+
+	// A process of this host that is gone leaves a claim to take over.
+	if err := os.WriteFile(filepath.Join(path, "owner"), []byte(host+" 999999999\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	release, err := claimOwner(dir)
+	if err != nil {
+		t.Fatalf("expected a dead owner's claim to be taken over: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(path, "owner"))
+	if want := host + " " + strconv.Itoa(os.Getpid()); strings.TrimSpace(string(data)) != want {
+		t.Fatalf("expected owner %q, got %q", want, data)
+	}
+	release()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected the claim to be released, got %v", err)
+	}
+}
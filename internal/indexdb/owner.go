@@ -0,0 +1,62 @@
+package indexdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ownerDir marks the database as open when it lives on a network
+// filesystem. bbolt guards the file with flock, which NFS may keep local to
+// each host, so two hosts could otherwise write it at once. Creating a
+// directory is atomic on the file server.
+const ownerDir = FileName + ".owner"
+
+// claimOwner takes ownerDir in dir, recording this host and process in it,
+// and returns the function that releases it. A directory left by a process
+// of this host that is gone is taken over; one held by another host is
+// not, as nothing here can tell whether that process still runs.
+func claimOwner(dir string) (func(), error) {
+	path := filepath.Join(dir, ownerDir)
+	host, _ := os.Hostname()
+	for attempt := 0; ; attempt++ {
+		err := os.Mkdir(path, 0o755)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrExist) || attempt > 0 {
+			return nil, err
+		}
+		data, _ := os.ReadFile(filepath.Join(path, "owner"))
+		owner := strings.TrimSpace(string(data))
+		ownerHost, pidText, _ := strings.Cut(owner, " ")
+		pid, _ := strconv.Atoi(pidText)
+		if ownerHost != host || (pid != os.Getpid() && processAlive(pid)) {
+			if owner == "" {
+				owner = "another process"
+			}
+			return nil, fmt.Errorf("%s on a network filesystem is in use by %s; remove %s if that process is gone", FileName, owner, path)
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return nil, err
+		}
+	}
+	owner := host + " " + strconv.Itoa(os.Getpid()) + "\n"
+	if err := os.WriteFile(filepath.Join(path, "owner"), []byte(owner), 0o644); err != nil {
+		_ = os.RemoveAll(path)
+		return nil, err
+	}
+	return func() { _ = os.RemoveAll(path) }, nil
+}
+
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	return err == nil && process.Signal(syscall.Signal(0)) == nil
+}
@@ -0,0 +1,26 @@
+package mcp
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"os/signal"
+)
+
+// Main implements `darkhold mcp`: an MCP server on stdin/stdout backed by a
+// running darkhold server. Usage is printed to stderr, since stdout carries
+// the protocol.
+func Main(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("mcp", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	baseURL := flags.String("url", "http://127.0.0.1:3275", "darkhold server URL")
+	apiKey := flags.String("api-key", os.Getenv("DARKHOLD_API_KEY"), "API key for the darkhold server (default $DARKHOLD_API_KEY)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return NewServer(NewClient(*baseURL, *apiKey)).ServeStdio(ctx, stdin, stdout)
+}
@@ -0,0 +1,143 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client calls darkhold's HTTP API on behalf of MCP tools.
+type Client struct {
+	BaseURL string
+	// Header is sent with every call, for example the caller's
+	// Authorization header.
+	Header http.Header
+	HTTP   *http.Client
+}
+
+// NewClient calls the darkhold server at baseURL, authenticating with apiKey
+// when it is set.
+func NewClient(baseURL, apiKey string) *Client {
+	header := http.Header{}
+	if apiKey != "" {
+		header.Set("Authorization", "Bearer "+apiKey)
+	}
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), Header: header, HTTP: &http.Client{}}
+}
+
+// NewHandlerClient calls handler in-process, as if from the client that made
+// r: its Authorization header and remote address are passed along, so the
+// calls are authenticated and allow-listed like the MCP request itself.
+func NewHandlerClient(handler http.Handler, r *http.Request) *Client {
+	header := http.Header{}
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		header.Set("Authorization", auth)
+	}
+	transport := &handlerTransport{handler: handler, remoteAddr: r.RemoteAddr}
+	return &Client{BaseURL: "http://darkhold", Header: header, HTTP: &http.Client{Transport: transport}}
+}
+
+// APIError is a darkhold error envelope.
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("darkhold API error %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// call sends body (if any) to path and decodes the JSON response.
+func (c *Client) call(ctx context.Context, method, path string, query url.Values, body any) (map[string]any, error) {
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(req.Header, c.Header)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var payload map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("%s %s: HTTP %d with an unreadable body", method, path, resp.StatusCode)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &APIError{Status: resp.StatusCode}
+		apiErr.Code, _ = payload["code"].(string)
+		apiErr.Message, _ = payload["error"].(string)
+		return nil, apiErr
+	}
+	return payload, nil
+}
+
+func (c *Client) rpc(ctx context.Context, method string, params map[string]any) (map[string]any, error) {
+	return c.call(ctx, http.MethodPost, "/api/rpc", nil, map[string]any{"method": method, "params": params})
+}
+
+// handlerTransport serves requests with an http.Handler instead of the
+// network.
+type handlerTransport struct {
+	handler    http.Handler
+	remoteAddr string
+}
+
+func (t *handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.RemoteAddr = t.remoteAddr
+	req.RequestURI = req.URL.RequestURI()
+	w := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+	t.handler.ServeHTTP(w, req)
+	return &http.Response{
+		Status:     http.StatusText(w.status),
+		StatusCode: w.status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     w.header,
+		Body:       io.NopCloser(&w.body),
+		Request:    req,
+	}, nil
+}
+
+type bufferedResponse struct {
+	header      http.Header
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+}
+
+func (w *bufferedResponse) Header() http.Header { return w.header }
+
+func (w *bufferedResponse) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = status, true
+	}
+}
+
+func (w *bufferedResponse) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
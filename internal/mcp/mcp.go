@@ -0,0 +1,171 @@
+// Package mcp exposes darkhold as a Model Context Protocol server, so other
+// agents and IDEs can list threads, read transcripts, start turns and answer
+// interactions as MCP tools. Tools call darkhold's HTTP API: over the network
+// for `darkhold mcp` (stdio transport), or in-process for POST /api/mcp.
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"slices"
+	"sync"
+)
+
+// ProtocolVersions are the MCP revisions this server speaks, newest first.
+var ProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+const serverVersion = "0.1.0"
+
+// maxMessageBytes bounds one stdio message.
+const maxMessageBytes = 10 << 20
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+type request struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Server answers MCP messages. It keeps no per-client state, so one Server
+// can serve any number of clients.
+type Server struct {
+	api *Client
+}
+
+func NewServer(api *Client) *Server {
+	return &Server{api: api}
+}
+
+// Handle answers one JSON-RPC message. It returns nil for notifications and
+// for responses, which need no answer.
+func (s *Server) Handle(ctx context.Context, message []byte) []byte {
+	var req request
+	if err := json.Unmarshal(message, &req); err != nil {
+		return encode(response{ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: "invalid JSON"}})
+	}
+	switch {
+	case req.Method == "" && len(req.ID) == 0:
+		return encode(response{ID: json.RawMessage("null"), Error: &rpcError{Code: codeInvalidRequest, Message: "missing method"}})
+	case req.Method == "" || len(req.ID) == 0:
+		// A response to us or a notification.
+		return nil
+	}
+	result, rerr := s.dispatch(ctx, req)
+	return encode(response{ID: req.ID, Result: result, Error: rerr})
+}
+
+func (s *Server) dispatch(ctx context.Context, req request) (any, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		_ = json.Unmarshal(req.Params, &params)
+		version := ProtocolVersions[0]
+		if slices.Contains(ProtocolVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{"listChanged": false}},
+			"serverInfo":      map[string]any{"name": "darkhold", "title": "Darkhold", "version": serverVersion},
+			"instructions":    "Darkhold manages Codex app-server threads. List threads, read a transcript, start turns, and answer pending approval or input requests.",
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		list := make([]map[string]any, 0, len(tools))
+		for _, t := range tools {
+			list = append(list, map[string]any{"name": t.name, "description": t.description, "inputSchema": t.inputSchema})
+		}
+		return map[string]any{"tools": list}, nil
+	case "tools/call":
+		var params struct {
+			Name      string         `json:"name"`
+			Arguments map[string]any `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: "invalid tools/call params"}
+		}
+		t, ok := toolByName(params.Name)
+		if !ok {
+			return nil, &rpcError{Code: codeInvalidParams, Message: "unknown tool: " + params.Name}
+		}
+		if params.Arguments == nil {
+			params.Arguments = map[string]any{}
+		}
+		return toolResult(t.call(ctx, s.api, params.Arguments)), nil
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+// toolResult wraps a tool's output as a CallToolResult. Failures become
+// isError results so the calling model can see and react to them.
+func toolResult(output any, err error) map[string]any {
+	if err != nil {
+		return map[string]any{"content": []any{map[string]any{"type": "text", "text": err.Error()}}, "isError": true}
+	}
+	text, _ := json.MarshalIndent(output, "", "  ")
+	result := map[string]any{"content": []any{map[string]any{"type": "text", "text": string(text)}}, "isError": false}
+	if _, ok := output.(map[string]any); ok {
+		result["structuredContent"] = output
+	}
+	return result
+}
+
+func encode(resp response) []byte {
+	resp.JSONRPC = "2.0"
+	encoded, _ := json.Marshal(resp)
+	return encoded
+}
+
+// ServeStdio reads newline-delimited messages from in and writes answers to
+// out until in ends or ctx is done. Messages are handled concurrently, since
+// a tool call may wait on the agent.
+func (s *Server) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64<<10), maxMessageBytes)
+	var writeMu sync.Mutex
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line := slices.Clone(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		wg.Go(func() {
+			answer := s.Handle(ctx, line)
+			if answer == nil {
+				return
+			}
+			writeMu.Lock()
+			defer writeMu.Unlock()
+			_, _ = out.Write(append(answer, '\n'))
+		})
+	}
+	return scanner.Err()
+}
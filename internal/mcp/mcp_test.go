@@ -0,0 +1,74 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeStdioAnswersRequestsOverTheAPI(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"a valid API key is required.","code":"UNAUTHORIZED"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/api/threads":
+			_, _ = w.Write([]byte(`{"threads":[{"threadId":"t1","tags":["` + r.URL.Query().Get("tag") + `"]}]}`))
+		case "/api/thread/interaction/respond":
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error":"interaction request not found or already resolved.","code":"INTERACTION_RESOLVED"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	in := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"1999-01-01"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"list_threads","arguments":{"tag":"ci"}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"respond_interaction","arguments":{"threadId":"t1","requestId":"9","result":{"decision":"accept"}}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"start_turn","arguments":{"threadId":"t1"}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"resources/list"}`,
+		`not json`,
+	}, "\n") + "\n"
+	var out bytes.Buffer
+	if err := NewServer(NewClient(api.URL, "secret")).ServeStdio(context.Background(), strings.NewReader(in), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	answers := map[string]map[string]any{}
+	for line := range strings.SplitSeq(strings.TrimSpace(out.String()), "\n") {
+		var answer map[string]any
+		if err := json.Unmarshal([]byte(line), &answer); err != nil {
+			t.Fatalf("invalid answer %q: %v", line, err)
+		}
+		id, _ := json.Marshal(answer["id"])
+		answers[string(id)] = answer
+	}
+	if len(answers) != 6 {
+		t.Fatalf("expected six answers (none for the notification), got %v", answers)
+	}
+	if version := answers["1"]["result"].(map[string]any)["protocolVersion"]; version != ProtocolVersions[0] {
+		t.Fatalf("expected an unknown version to get the latest, got %v", version)
+	}
+	listed := answers["2"]["result"].(map[string]any)
+	if listed["isError"] != false || !strings.Contains(listed["content"].([]any)[0].(map[string]any)["text"].(string), `"ci"`) {
+		t.Fatalf("unexpected list_threads result %v", listed)
+	}
+	for id, want := range map[string]string{"3": "INTERACTION_RESOLVED", "4": "text is required"} {
+		result := answers[id]["result"].(map[string]any)
+		if result["isError"] != true || !strings.Contains(result["content"].([]any)[0].(map[string]any)["text"].(string), want) {
+			t.Fatalf("expected tool error %q for %s, got %v", want, id, result)
+		}
+	}
+	if answers["5"]["error"].(map[string]any)["code"] != float64(codeMethodNotFound) || answers["null"]["error"].(map[string]any)["code"] != float64(codeParseError) {
+		t.Fatalf("unexpected protocol errors %v %v", answers["5"], answers["null"])
+	}
+}
@@ -0,0 +1,322 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// maxTranscriptText bounds each message kept in a read_transcript turn.
+const maxTranscriptText = 8000
+
+// defaultTranscriptTurns is how many trailing turns read_transcript returns
+// unless asked otherwise.
+const defaultTranscriptTurns = 20
+
+type tool struct {
+	name        string
+	description string
+	inputSchema map[string]any
+	call        func(ctx context.Context, api *Client, args map[string]any) (any, error)
+}
+
+func objectSchema(required []string, properties map[string]any) map[string]any {
+	schema := map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func property(kind, description string) map[string]any {
+	return map[string]any{"type": kind, "description": description}
+}
+
+var tools = []tool{
+	{
+		name:        "list_threads",
+		description: "List darkhold threads with their title, cwd, tags, last activity and whether they wait on an approval or have a turn running. Results are paged; pass nextCursor back as cursor.",
+		inputSchema: objectSchema(nil, map[string]any{
+			"sort":            map[string]any{"type": "string", "enum": []string{"threadId", "updatedAt", "createdAt", "title", "cwd"}},
+			"order":           map[string]any{"type": "string", "enum": []string{"asc", "desc"}},
+			"cwdPrefix":       property("string", "Only threads whose cwd is at or beneath this directory."),
+			"tag":             property("string", "Only threads with this tag."),
+			"pendingApproval": property("boolean", "Only threads that do (true) or do not (false) wait on an interaction."),
+			"activeTurn":      property("boolean", "Only threads that do (true) or do not (false) have a running turn."),
+			"limit":           property("integer", "Page size, 1-500."),
+			"cursor":          property("string", "nextCursor from a previous call."),
+		}),
+		call: listThreads,
+	},
+	{
+		name:        "read_transcript",
+		description: "Read a thread's recent turns (user prompt, agent reply, commands and changed files) and the interaction requests it is waiting on.",
+		inputSchema: objectSchema([]string{"threadId"}, map[string]any{
+			"threadId":  property("string", "Thread to read."),
+			"lastTurns": property("integer", fmt.Sprintf("How many trailing turns to return. Default %d.", defaultTranscriptTurns)),
+		}),
+		call: readTranscript,
+	},
+	{
+		name:        "start_thread",
+		description: "Start a new thread in a directory under darkhold's base path.",
+		inputSchema: objectSchema([]string{"cwd"}, map[string]any{
+			"cwd": property("string", "Working directory for the thread."),
+		}),
+		call: startThread,
+	},
+	{
+		name:        "start_turn",
+		description: "Send a prompt to a thread. The call returns once the turn has started; use read_transcript to follow it.",
+		inputSchema: objectSchema([]string{"threadId", "text"}, map[string]any{
+			"threadId": property("string", "Thread to prompt."),
+			"text":     property("string", "Prompt text."),
+		}),
+		call: startTurn,
+	},
+	{
+		name:        "respond_interaction",
+		description: "Answer a pending interaction request listed by read_transcript. Approvals take {\"decision\": \"accept\" | \"decline\"}; other kinds take the result their method expects. Send error instead of result to reject the request.",
+		inputSchema: objectSchema([]string{"threadId", "requestId"}, map[string]any{
+			"threadId":  property("string", "Thread the request belongs to."),
+			"requestId": property("string", "requestId of the interaction request."),
+			"result":    map[string]any{"description": "Result sent to the agent."},
+			"error":     map[string]any{"type": "object", "description": "JSON-RPC error sent instead of a result: {message, code?}."},
+		}),
+		call: respondInteraction,
+	},
+}
+
+func toolByName(name string) (tool, bool) {
+	for _, t := range tools {
+		if t.name == name {
+			return t, true
+		}
+	}
+	return tool{}, false
+}
+
+func requiredString(args map[string]any, name string) (string, error) {
+	value, _ := args[name].(string)
+	if value = strings.TrimSpace(value); value == "" {
+		return "", fmt.Errorf("%s is required", name)
+	}
+	return value, nil
+}
+
+func listThreads(ctx context.Context, api *Client, args map[string]any) (any, error) {
+	query := url.Values{}
+	for _, name := range []string{"sort", "order", "cwdPrefix", "tag", "cursor"} {
+		if value, ok := args[name].(string); ok && value != "" {
+			query.Set(name, value)
+		}
+	}
+	for _, name := range []string{"pendingApproval", "activeTurn"} {
+		if value, ok := args[name].(bool); ok {
+			query.Set(name, strconv.FormatBool(value))
+		}
+	}
+	if limit, ok := args["limit"].(float64); ok {
+		query.Set("limit", strconv.Itoa(int(limit)))
+	}
+	return api.call(ctx, http.MethodGet, "/api/threads", query, nil)
+}
+
+type transcriptCommand struct {
+	Command  string `json:"command"`
+	ExitCode *int   `json:"exitCode,omitempty"`
+}
+
+type transcriptTurn struct {
+	Index     int                 `json:"index"`
+	TurnID    string              `json:"turnId,omitempty"`
+	Status    string              `json:"status,omitempty"`
+	User      string              `json:"user"`
+	Agent     string              `json:"agent"`
+	Commands  []transcriptCommand `json:"commands"`
+	FilePaths []string            `json:"filePaths"`
+}
+
+type pendingInteraction struct {
+	RequestID string         `json:"requestId"`
+	Method    string         `json:"method"`
+	Kind      string         `json:"kind,omitempty"`
+	Params    map[string]any `json:"params"`
+}
+
+func readTranscript(ctx context.Context, api *Client, args map[string]any) (any, error) {
+	threadID, err := requiredString(args, "threadId")
+	if err != nil {
+		return nil, err
+	}
+	lastTurns := defaultTranscriptTurns
+	if n, ok := args["lastTurns"].(float64); ok && n >= 1 {
+		lastTurns = int(n)
+	}
+	read, err := api.rpc(ctx, "thread/read", map[string]any{"threadId": threadID, "includeTurns": true})
+	if err != nil {
+		return nil, err
+	}
+	threadObj, _ := read["thread"].(map[string]any)
+	rawTurns, _ := threadObj["turns"].([]any)
+	turns := summarizeTurns(rawTurns)
+	if len(turns) > lastTurns {
+		turns = turns[len(turns)-lastTurns:]
+	}
+	pending, err := pendingInteractions(ctx, api, threadID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"threadId":            threadID,
+		"turnCount":           len(rawTurns),
+		"turns":               turns,
+		"pendingInteractions": pending,
+	}, nil
+}
+
+func summarizeTurns(rawTurns []any) []transcriptTurn {
+	turns := make([]transcriptTurn, 0, len(rawTurns))
+	for index, raw := range rawTurns {
+		turnObj, _ := raw.(map[string]any)
+		turn := transcriptTurn{Index: index, Commands: []transcriptCommand{}, FilePaths: []string{}}
+		turn.TurnID, _ = turnObj["id"].(string)
+		turn.Status, _ = turnObj["status"].(string)
+		items, _ := turnObj["items"].([]any)
+		var user, agent []string
+		for _, rawItem := range items {
+			item, _ := rawItem.(map[string]any)
+			switch item["type"] {
+			case "userMessage":
+				content, _ := item["content"].([]any)
+				for _, rawEntry := range content {
+					entry, _ := rawEntry.(map[string]any)
+					if text, ok := entry["text"].(string); ok && entry["type"] == "text" {
+						user = append(user, text)
+					}
+				}
+			case "agentMessage":
+				if text, ok := item["text"].(string); ok && text != "" {
+					agent = append(agent, text)
+				}
+			case "commandExecution":
+				command := transcriptCommand{}
+				command.Command, _ = item["command"].(string)
+				if code, ok := item["exitCode"].(float64); ok {
+					exitCode := int(code)
+					command.ExitCode = &exitCode
+				}
+				turn.Commands = append(turn.Commands, command)
+			case "fileChange":
+				changes, _ := item["changes"].([]any)
+				for _, rawChange := range changes {
+					change, _ := rawChange.(map[string]any)
+					if path, ok := change["path"].(string); ok {
+						turn.FilePaths = append(turn.FilePaths, path)
+					}
+				}
+			}
+		}
+		turn.User = truncateRunes(strings.Join(user, "\n"), maxTranscriptText)
+		turn.Agent = truncateRunes(strings.Join(agent, "\n\n"), maxTranscriptText)
+		turns = append(turns, turn)
+	}
+	return turns
+}
+
+// pendingInteractions replays the thread log for interaction requests that
+// have not been resolved yet.
+func pendingInteractions(ctx context.Context, api *Client, threadID string) ([]pendingInteraction, error) {
+	payload, err := api.call(ctx, http.MethodGet, "/api/thread/events", url.Values{"threadId": {threadID}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	rawEvents, _ := payload["events"].([]any)
+	open := map[string]pendingInteraction{}
+	var order []string
+	for _, raw := range rawEvents {
+		line, _ := raw.(string)
+		var event struct {
+			Method string             `json:"method"`
+			Params pendingInteraction `json:"params"`
+		}
+		if json.Unmarshal([]byte(line), &event) != nil || event.Params.RequestID == "" {
+			continue
+		}
+		switch event.Method {
+		case "darkhold/interaction/request":
+			if _, seen := open[event.Params.RequestID]; !seen {
+				order = append(order, event.Params.RequestID)
+			}
+			open[event.Params.RequestID] = event.Params
+		case "darkhold/interaction/resolved":
+			delete(open, event.Params.RequestID)
+		}
+	}
+	pending := []pendingInteraction{}
+	for _, requestID := range order {
+		if interaction, ok := open[requestID]; ok {
+			pending = append(pending, interaction)
+			delete(open, requestID)
+		}
+	}
+	return pending, nil
+}
+
+func startThread(ctx context.Context, api *Client, args map[string]any) (any, error) {
+	cwd, err := requiredString(args, "cwd")
+	if err != nil {
+		return nil, err
+	}
+	return api.rpc(ctx, "thread/start", map[string]any{"cwd": cwd})
+}
+
+func startTurn(ctx context.Context, api *Client, args map[string]any) (any, error) {
+	threadID, err := requiredString(args, "threadId")
+	if err != nil {
+		return nil, err
+	}
+	text, err := requiredString(args, "text")
+	if err != nil {
+		return nil, err
+	}
+	return api.rpc(ctx, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": text}}})
+}
+
+func respondInteraction(ctx context.Context, api *Client, args map[string]any) (any, error) {
+	threadID, err := requiredString(args, "threadId")
+	if err != nil {
+		return nil, err
+	}
+	requestID, err := requiredString(args, "requestId")
+	if err != nil {
+		return nil, err
+	}
+	body := map[string]any{"threadId": threadID, "requestId": requestID}
+	result, hasResult := args["result"]
+	rpcErr, hasError := args["error"]
+	switch {
+	case hasResult && hasError:
+		return nil, errors.New("send either result or error, not both")
+	case hasError:
+		body["error"] = rpcErr
+	case hasResult:
+		body["result"] = result
+	default:
+		return nil, errors.New("result or error is required")
+	}
+	return api.call(ctx, http.MethodPost, "/api/thread/interaction/respond", nil, body)
+}
+
+func truncateRunes(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "…"
+}
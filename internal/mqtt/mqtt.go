@@ -0,0 +1,328 @@
+// Package mqtt is a minimal MQTT 3.1.1 publisher: QoS 0 PUBLISH over a
+// single reconnecting connection. It implements only what darkhold needs to
+// mirror thread events onto a broker.
+package mqtt
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	packetConnect    = 0x10
+	packetConnack    = 0x20
+	packetPublish    = 0x30
+	packetPingreq    = 0xC0
+	packetDisconnect = 0xE0
+
+	queueSize  = 256
+	maxBackoff = 30 * time.Second
+)
+
+// Options configures a Publisher. BrokerURL uses tcp://, mqtt://, ssl://,
+// tls:// or mqtts://; credentials may also be given as URL userinfo.
+type Options struct {
+	BrokerURL string
+	Username  string
+	Password  string
+	ClientID  string
+	KeepAlive time.Duration
+}
+
+type message struct {
+	topic   string
+	payload []byte
+}
+
+// Publisher queues messages and delivers them from a background goroutine,
+// connecting lazily and reconnecting with backoff. Messages that arrive while
+// the queue is full are dropped; QoS 0 gives no delivery guarantee anyway.
+type Publisher struct {
+	opts   Options
+	addr   string
+	useTLS bool
+
+	queue chan message
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+
+	mu      sync.Mutex
+	dropped int64
+}
+
+// ParseBrokerURL validates a broker URL and returns the dial address and
+// whether TLS is required.
+func ParseBrokerURL(raw string) (addr string, useTLS bool, err error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "", false, fmt.Errorf("invalid MQTT broker URL: %s", raw)
+	}
+	port := "1883"
+	switch u.Scheme {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		useTLS = true
+		port = "8883"
+	default:
+		return "", false, fmt.Errorf("invalid MQTT broker URL: %s", raw)
+	}
+	host := u.Hostname()
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	return net.JoinHostPort(host, port), useTLS, nil
+}
+
+// New validates opts and starts the publisher's delivery loop.
+func New(opts Options) (*Publisher, error) {
+	addr, useTLS, err := ParseBrokerURL(opts.BrokerURL)
+	if err != nil {
+		return nil, err
+	}
+	if u, _ := url.Parse(opts.BrokerURL); u.User != nil {
+		if opts.Username == "" {
+			opts.Username = u.User.Username()
+		}
+		if password, ok := u.User.Password(); ok && opts.Password == "" {
+			opts.Password = password
+		}
+	}
+	if opts.ClientID == "" {
+		opts.ClientID = fmt.Sprintf("darkhold-%d", time.Now().UnixNano())
+	}
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = 60 * time.Second
+	}
+	p := &Publisher{
+		opts:   opts,
+		addr:   addr,
+		useTLS: useTLS,
+		queue:  make(chan message, queueSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go p.run()
+	return p, nil
+}
+
+// Publish queues a QoS 0 message. It never blocks.
+func (p *Publisher) Publish(topic string, payload []byte) {
+	select {
+	case <-p.stop:
+		return
+	default:
+	}
+	select {
+	case p.queue <- message{topic: topic, payload: payload}:
+	default:
+		p.mu.Lock()
+		p.dropped++
+		p.mu.Unlock()
+	}
+}
+
+// Dropped reports how many messages were discarded because the queue was
+// full.
+func (p *Publisher) Dropped() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.dropped
+}
+
+// Close sends DISCONNECT if connected and stops the delivery loop. Queued
+// messages that have not been written are discarded.
+func (p *Publisher) Close() {
+	p.once.Do(func() { close(p.stop) })
+	<-p.done
+}
+
+func (p *Publisher) run() {
+	defer close(p.done)
+	backoff := time.Second
+	for {
+		conn, reader, err := p.connect()
+		if err != nil {
+			log.Printf("[mqtt] connect to %s failed: %v", p.addr, err)
+			select {
+			case <-p.stop:
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxBackoff)
+			continue
+		}
+		backoff = time.Second
+		if stopped := p.serve(conn, reader); stopped {
+			return
+		}
+	}
+}
+
+func (p *Publisher) connect() (net.Conn, *bufio.Reader, error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	var err error
+	if p.useTLS {
+		host, _, _ := net.SplitHostPort(p.addr)
+		conn, err = tls.DialWithDialer(dialer, "tcp", p.addr, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", p.addr)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := conn.Write(connectPacket(p.opts)); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	reader := bufio.NewReader(conn)
+	header, body, err := readPacket(reader)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if header&0xF0 != packetConnack || len(body) != 2 {
+		conn.Close()
+		return nil, nil, errors.New("unexpected reply to CONNECT")
+	}
+	if body[1] != 0 {
+		conn.Close()
+		return nil, nil, fmt.Errorf("broker refused connection (return code %d)", body[1])
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, reader, nil
+}
+
+// serve writes queued messages until the connection fails or the publisher
+// stops. It reports whether the publisher was stopped.
+func (p *Publisher) serve(conn net.Conn, reader *bufio.Reader) bool {
+	defer conn.Close()
+	readErr := make(chan error, 1)
+	go func() {
+		// Drain PINGRESP and anything else the broker sends so the socket
+		// never backs up; an error here means the connection is gone.
+		for {
+			if _, _, err := readPacket(reader); err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+	ping := time.NewTicker(p.opts.KeepAlive / 2)
+	defer ping.Stop()
+	for {
+		var packet []byte
+		select {
+		case <-p.stop:
+			_, _ = conn.Write([]byte{packetDisconnect, 0})
+			return true
+		case err := <-readErr:
+			log.Printf("[mqtt] connection to %s lost: %v", p.addr, err)
+			return false
+		case <-ping.C:
+			packet = []byte{packetPingreq, 0}
+		case msg := <-p.queue:
+			packet = publishPacket(msg)
+		}
+		_ = conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if _, err := conn.Write(packet); err != nil {
+			log.Printf("[mqtt] write to %s failed: %v", p.addr, err)
+			return false
+		}
+	}
+}
+
+func connectPacket(opts Options) []byte {
+	var body []byte
+	body = appendString(body, "MQTT")
+	body = append(body, 4) // protocol level 3.1.1
+	flags := byte(0x02)    // clean session
+	if opts.Username != "" {
+		flags |= 0x80
+		if opts.Password != "" {
+			flags |= 0x40
+		}
+	}
+	keepAlive := uint16(opts.KeepAlive / time.Second)
+	body = append(body, flags, byte(keepAlive>>8), byte(keepAlive))
+	body = appendString(body, opts.ClientID)
+	if opts.Username != "" {
+		body = appendString(body, opts.Username)
+		if opts.Password != "" {
+			body = appendString(body, opts.Password)
+		}
+	}
+	return appendPacket(packetConnect, body)
+}
+
+func publishPacket(msg message) []byte {
+	body := appendString(nil, msg.topic)
+	body = append(body, msg.payload...)
+	return appendPacket(packetPublish, body)
+}
+
+func appendPacket(header byte, body []byte) []byte {
+	out := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(out, body...)
+}
+
+func appendString(out []byte, s string) []byte {
+	out = append(out, byte(len(s)>>8), byte(len(s)))
+	return append(out, s...)
+}
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(b&0x7F) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+// TopicSegment makes s safe to use as one topic level by replacing the MQTT
+// separator and wildcard characters.
+func TopicSegment(s string) string {
+	return strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(s)
+}
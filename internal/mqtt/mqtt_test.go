@@ -0,0 +1,134 @@
+package mqtt
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+type received struct {
+	header byte
+	body   []byte
+}
+
+// fakeBroker accepts one connection, acknowledges CONNECT and forwards every
+// packet it reads.
+func fakeBroker(t *testing.T, returnCode byte) (string, <-chan received) {
+	t.Helper()
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("loopback sockets are not available in this environment")
+	}
+	t.Cleanup(func() { listener.Close() })
+	packets := make(chan received, 16)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			header, body, err := readPacket(reader)
+			if err != nil {
+				close(packets)
+				return
+			}
+			packets <- received{header: header, body: body}
+			if header&0xF0 == packetConnect {
+				_, _ = conn.Write([]byte{packetConnack, 2, 0, returnCode})
+			}
+		}
+	}()
+	return listener.Addr().String(), packets
+}
+
+func next(t *testing.T, packets <-chan received) received {
+	t.Helper()
+	select {
+	case p, ok := <-packets:
+		if !ok {
+			t.Fatal("broker connection closed")
+		}
+		return p
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for packet")
+	}
+	return received{}
+}
+
+func readString(body []byte) (string, []byte) {
+	n := int(body[0])<<8 | int(body[1])
+	return string(body[2 : 2+n]), body[2+n:]
+}
+
+func TestPublisherConnectsAndPublishes(t *testing.T) {
+	addr, packets := fakeBroker(t, 0)
+	p, err := New(Options{BrokerURL: "mqtt://lights:secret@" + addr, ClientID: "darkhold-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Publish("darkhold/t1/turn/completed", []byte(`{"method":"turn/completed"}`))
+
+	connect := next(t, packets)
+	if connect.header != packetConnect {
+		t.Fatalf("expected CONNECT, got %#x", connect.header)
+	}
+	protocol, rest := readString(connect.body)
+	if protocol != "MQTT" || rest[0] != 4 || rest[1] != 0xC2 {
+		t.Fatalf("unexpected CONNECT header: %q %v", protocol, rest[:4])
+	}
+	clientID, rest := readString(rest[4:])
+	username, rest := readString(rest)
+	password, _ := readString(rest)
+	if clientID != "darkhold-test" || username != "lights" || password != "secret" {
+		t.Fatalf("unexpected CONNECT payload: %q %q %q", clientID, username, password)
+	}
+
+	publish := next(t, packets)
+	topic, payload := readString(publish.body)
+	if publish.header != packetPublish || topic != "darkhold/t1/turn/completed" || string(payload) != `{"method":"turn/completed"}` {
+		t.Fatalf("unexpected PUBLISH: %#x %q %q", publish.header, topic, payload)
+	}
+
+	p.Close()
+	if disconnect := next(t, packets); disconnect.header != packetDisconnect {
+		t.Fatalf("expected DISCONNECT, got %#x", disconnect.header)
+	}
+}
+
+func TestRemainingLengthEncoding(t *testing.T) {
+	body := make([]byte, 321)
+	packet := appendPacket(packetPublish, body)
+	if packet[1] != 0xC1 || packet[2] != 0x02 || len(packet) != 3+len(body) {
+		t.Fatalf("unexpected encoding: %v", packet[:3])
+	}
+}
+
+func TestParseBrokerURL(t *testing.T) {
+	cases := []struct {
+		raw    string
+		addr   string
+		useTLS bool
+	}{
+		{"tcp://broker.local", "broker.local:1883", false},
+		{"mqtts://broker.local", "broker.local:8883", true},
+		{"mqtt://10.0.0.5:1884", "10.0.0.5:1884", false},
+	}
+	for _, tc := range cases {
+		addr, useTLS, err := ParseBrokerURL(tc.raw)
+		if err != nil || addr != tc.addr || useTLS != tc.useTLS {
+			t.Errorf("ParseBrokerURL(%q) = %q, %v, %v", tc.raw, addr, useTLS, err)
+		}
+	}
+	if _, _, err := ParseBrokerURL("http://broker.local"); err == nil {
+		t.Fatal("expected http scheme to be rejected")
+	}
+}
+
+func TestTopicSegment(t *testing.T) {
+	if got := TopicSegment("a/b+c#"); got != "a_b_c_" {
+		t.Fatalf("unexpected segment %q", got)
+	}
+}
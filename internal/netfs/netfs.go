@@ -0,0 +1,38 @@
+// Package netfs tells whether a path lives on a network filesystem (NFS,
+// SMB and the like), where darkhold cannot rely on flock, a clock shared
+// with the file server, or inotify seeing changes made by other hosts.
+package netfs
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Info describes the filesystem holding a path. Type is its name where
+// known ("nfs", "cifs", "smbfs", "apfs"); Linux and Windows only name
+// network filesystems.
+type Info struct {
+	Type    string `json:"type,omitempty"`
+	Network bool   `json:"network"`
+}
+
+// Detect returns the filesystem holding path, or its nearest existing
+// parent when path has not been created yet. Filesystems that cannot be
+// identified are reported as local.
+func Detect(path string) Info {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			break
+		}
+		path = parent
+	}
+	info, err := detect(path)
+	if err != nil {
+		return Info{}
+	}
+	return info
+}
@@ -0,0 +1,22 @@
+package netfs
+
+import "syscall"
+
+var darwinNetworkTypes = map[string]bool{
+	"nfs": true, "smbfs": true, "afpfs": true, "webdav": true, "cifs": true, "macfuse": true, "osxfuse": true,
+}
+
+func detect(path string) (Info, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return Info{}, err
+	}
+	name := make([]byte, 0, len(stat.Fstypename))
+	for _, c := range stat.Fstypename {
+		if c == 0 {
+			break
+		}
+		name = append(name, byte(c))
+	}
+	return Info{Type: string(name), Network: darwinNetworkTypes[string(name)]}, nil
+}
@@ -0,0 +1,30 @@
+package netfs
+
+import "syscall"
+
+// linuxTypes names the statfs magic numbers of network filesystems, plus
+// FUSE, which sshfs and most userspace network mounts use. Magic numbers
+// are compared as uint32, as 32-bit platforms report them signed.
+var linuxTypes = map[uint32]string{
+	0x6969:     "nfs",
+	0x517b:     "smb",
+	0xff534d42: "cifs",
+	0xfe534d42: "smb2",
+	0x5346414f: "afs",
+	0x00c36400: "ceph",
+	0x01021997: "9p",
+	0x65735546: "fuse",
+	0x47504653: "gpfs",
+	0x0bd00bd0: "lustre",
+}
+
+func detect(path string) (Info, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return Info{}, err
+	}
+	if name, ok := linuxTypes[uint32(stat.Type)]; ok {
+		return Info{Type: name, Network: true}, nil
+	}
+	return Info{}, nil
+}
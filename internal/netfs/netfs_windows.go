@@ -0,0 +1,28 @@
+package netfs
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// detect reports UNC paths and mapped network drives as network
+// filesystems.
+func detect(path string) (Info, error) {
+	if strings.HasPrefix(path, `\\`) {
+		return Info{Type: "smb", Network: true}, nil
+	}
+	volume := filepath.VolumeName(path)
+	if volume == "" {
+		return Info{}, nil
+	}
+	root, err := windows.UTF16PtrFromString(volume + `\`)
+	if err != nil {
+		return Info{}, err
+	}
+	if windows.GetDriveType(root) == windows.DRIVE_REMOTE {
+		return Info{Type: "smb", Network: true}, nil
+	}
+	return Info{}, nil
+}
@@ -0,0 +1,171 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"maps"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeIssuer is a minimal OpenID provider: discovery, JWKS and a token
+// endpoint that answers any code with the ID token in idToken.
+type fakeIssuer struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	idToken  func(nonce string) string
+	lastForm url.Values
+	lastUser string
+	nonce    string
+	jwksHits int
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                 f.server.URL,
+			"authorization_endpoint": f.server.URL + "/authorize",
+			"token_endpoint":         f.server.URL + "/token",
+			"jwks_uri":               f.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		f.jwksHits++
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []any{map[string]any{
+			"kty": "RSA", "kid": "k1", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		f.lastForm = r.PostForm
+		f.lastUser, _, _ = r.BasicAuth()
+		_ = json.NewEncoder(w).Encode(map[string]any{"id_token": f.idToken(f.nonce), "token_type": "Bearer"})
+	})
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	f.idToken = func(nonce string) string {
+		return f.sign(t, "k1", map[string]any{"iss": f.server.URL, "aud": "darkhold", "sub": "u1", "email": "a@example.com", "nonce": nonce, "exp": time.Now().Add(time.Hour).Unix()})
+	}
+	return f
+}
+
+func (f *fakeIssuer) sign(t *testing.T, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]any{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	body, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	f := newFakeIssuer(t)
+	p := &Provider{Issuer: f.server.URL, ClientID: "darkhold", ClientSecret: "s3cret", Scopes: []string{"email", "openid"}}
+	login := NewLogin()
+	f.nonce = login.Nonce
+
+	authURL, err := p.AuthCodeURL(context.Background(), login, "http://localhost/cb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(authURL)
+	query := u.Query()
+	challenge := sha256.Sum256([]byte(login.Verifier))
+	if u.Path != "/authorize" || query.Get("state") != login.State || query.Get("scope") != "openid email" ||
+		query.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(challenge[:]) || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected authorization URL %s", authURL)
+	}
+
+	claims, err := p.Exchange(context.Background(), login, "code-1", "http://localhost/cb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims["email"] != "a@example.com" || f.lastForm.Get("code_verifier") != login.Verifier || f.lastUser != "darkhold" || f.lastForm.Get("client_secret") != "" {
+		t.Fatalf("unexpected exchange: %v %v %q", claims, f.lastForm, f.lastUser)
+	}
+
+	// A token minted for another login is refused.
+	if _, err := p.Exchange(context.Background(), NewLogin(), "code-2", "http://localhost/cb"); !errors.Is(err, ErrNonce) {
+		t.Fatalf("expected a nonce mismatch, got %v", err)
+	}
+}
+
+func TestVerifyRejectsBadTokens(t *testing.T) {
+	f := newFakeIssuer(t)
+	p := &Provider{Issuer: f.server.URL, ClientID: "darkhold"}
+	claims := func(overrides map[string]any) map[string]any {
+		out := map[string]any{"iss": f.server.URL, "aud": "darkhold", "sub": "u1", "nonce": "n", "exp": time.Now().Add(time.Hour).Unix()}
+		maps.Copy(out, overrides)
+		return out
+	}
+	if _, err := p.Verify(context.Background(), f.sign(t, "k1", claims(nil)), "n"); err != nil {
+		t.Fatalf("expected a valid token, got %v", err)
+	}
+
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	forged := &fakeIssuer{key: other}
+	unsigned := strings.Join(strings.Split(f.sign(t, "k1", claims(nil)), ".")[:2], ".") + "."
+	for name, token := range map[string]string{
+		"issuer":   f.sign(t, "k1", claims(map[string]any{"iss": "https://evil.example"})),
+		"audience": f.sign(t, "k1", claims(map[string]any{"aud": []any{"other"}})),
+		"expired":  f.sign(t, "k1", claims(map[string]any{"exp": time.Now().Add(-time.Hour).Unix()})),
+		"key":      forged.sign(t, "k1", claims(nil)),
+		"kid":      f.sign(t, "k2", claims(nil)),
+		"unsigned": unsigned,
+	} {
+		if _, err := p.Verify(context.Background(), token, "n"); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+	// Unknown key ids refetch the key set at most once a minute.
+	if f.jwksHits != 1 {
+		t.Fatalf("expected the key set to be fetched once, got %d", f.jwksHits)
+	}
+}
+
+func TestECKeys(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	jwk := jsonWebKey{KeyType: "EC", Curve: "P-256",
+		X: base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		Y: base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32)))}
+	public, err := jwk.publicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := []byte("header.claims")
+	digest := sha256.Sum256(signed)
+	r, s, _ := ecdsa.Sign(rand.Reader, key, digest[:])
+	signature := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	if err := verifySignature("ES256", public, signed, signature); err != nil {
+		t.Fatalf("expected the EC signature to verify, got %v", err)
+	}
+	if err := verifySignature("RS256", public, signed, signature); err == nil {
+		t.Fatal("expected an RSA algorithm on an EC key to be refused")
+	}
+}
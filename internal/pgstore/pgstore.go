@@ -0,0 +1,467 @@
+// Package pgstore keeps darkhold's event logs and thread index in Postgres so
+// several replicas can serve the same threads. Appends are announced with
+// LISTEN/NOTIFY, letting each replica push other replicas' events to its own
+// SSE clients.
+package pgstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/oklog/ulid/v2"
+
+	"darkhold-go/internal/events"
+	"darkhold-go/internal/threads"
+)
+
+const (
+	eventsChannel    = "darkhold_events"
+	documentsChannel = "darkhold_documents"
+	messagesChannel  = "darkhold_messages"
+
+	threadIndexDocument = "threads"
+
+	queryTimeout = 10 * time.Second
+)
+
+const schema = `
+CREATE SEQUENCE IF NOT EXISTS darkhold_event_seq;
+CREATE TABLE IF NOT EXISTS darkhold_events (
+	thread_id text NOT NULL,
+	id text NOT NULL,
+	seq bigint NOT NULL,
+	ts bigint NOT NULL,
+	payload text NOT NULL,
+	PRIMARY KEY (thread_id, id)
+);
+CREATE INDEX IF NOT EXISTS darkhold_events_thread_seq ON darkhold_events (thread_id, seq);
+CREATE TABLE IF NOT EXISTS darkhold_documents (
+	name text PRIMARY KEY,
+	body text NOT NULL,
+	updated_at timestamptz NOT NULL DEFAULT now()
+);
+`
+
+// Store is an events.Storage backed by Postgres.
+type Store struct {
+	pool     *pgxpool.Pool
+	url      string
+	instance string
+
+	clockMu  sync.Mutex
+	lastTime int64
+}
+
+var _ events.Storage = (*Store)(nil)
+
+// notification is the NOTIFY payload for all channels. Origin is the replica
+// that sent it, so replicas can ignore their own.
+type notification struct {
+	Origin   string          `json:"origin"`
+	ThreadID string          `json:"threadId,omitempty"`
+	ID       string          `json:"id,omitempty"`
+	Name     string          `json:"name,omitempty"`
+	Channel  string          `json:"channel,omitempty"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+}
+
+// Open connects to the database at url and creates the schema if needed.
+func Open(ctx context.Context, url string) (*Store, error) {
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := pool.Exec(ctx, schema); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("create schema: %w", err)
+	}
+	id := make([]byte, 6)
+	_, _ = rand.Read(id)
+	return &Store{pool: pool, url: url, instance: hex.EncodeToString(id)}, nil
+}
+
+// Close releases the connection pool.
+func (s *Store) Close() {
+	s.pool.Close()
+}
+
+// InstanceID identifies this replica in notifications.
+func (s *Store) InstanceID() string {
+	return s.instance
+}
+
+func (s *Store) now() int64 {
+	s.clockMu.Lock()
+	defer s.clockMu.Unlock()
+	now := max(time.Now().UnixMilli(), s.lastTime)
+	s.lastTime = now
+	return now
+}
+
+// execer is satisfied by both the pool and a transaction.
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func (s *Store) notify(ctx context.Context, q execer, channel string, n notification) error {
+	n.Origin = s.instance
+	payload, _ := json.Marshal(n)
+	_, err := q.Exec(ctx, "SELECT pg_notify($1, $2)", channel, string(payload))
+	return err
+}
+
+// Key returns threadID unchanged; Postgres needs no file-safe name.
+func (s *Store) Key(threadID string) string {
+	return threadID
+}
+
+// Append inserts a record and announces it on darkhold_events in the same
+// transaction, so listeners only hear about committed rows. Seq comes from a
+// database sequence shared by all replicas. A transaction-scoped advisory
+// lock per thread orders appends to one thread, so its seq values commit in
+// order and a reader never sees seq N+1 before N.
+func (s *Store) Append(threadID, payload string) (events.Record, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	record := events.Record{ID: ulid.Make().String(), Time: s.now(), Payload: payload}
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('darkhold_events'), hashtext($1))`, threadID); err != nil {
+			return err
+		}
+		err := tx.QueryRow(ctx,
+			`INSERT INTO darkhold_events (thread_id, id, seq, ts, payload) VALUES ($1, $2, nextval('darkhold_event_seq'), $3, $4) RETURNING seq`,
+			threadID, record.ID, record.Time, payload,
+		).Scan(&record.Seq)
+		if err != nil {
+			return err
+		}
+		return s.notify(ctx, tx, eventsChannel, notification{ThreadID: threadID, ID: record.ID})
+	})
+	if err != nil {
+		return events.Record{}, err
+	}
+	return record, nil
+}
+
+// ReadRange resumes after afterID by its seq, not its ID: replicas mint IDs
+// from their own clocks, so ID order can disagree with seq order. An afterID
+// the thread does not have falls back to comparing IDs.
+func (s *Store) ReadRange(threadID, afterID string, limit int) ([]events.Record, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	var limitArg any
+	if limit > 0 {
+		limitArg = limit
+	}
+	rows, err := s.pool.Query(ctx,
+		`WITH after_record AS (SELECT seq FROM darkhold_events WHERE thread_id = $1 AND id = $2)
+		SELECT id, seq, ts, payload FROM darkhold_events
+		WHERE thread_id = $1 AND (seq > (SELECT seq FROM after_record) OR (NOT EXISTS (SELECT 1 FROM after_record) AND id > $2))
+		ORDER BY seq LIMIT $3`,
+		threadID, afterID, limitArg,
+	)
+	if err != nil {
+		return nil, err
+	}
+	records, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (events.Record, error) {
+		var r events.Record
+		err := row.Scan(&r.ID, &r.Seq, &r.Time, &r.Payload)
+		return r, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if records == nil {
+		records = []events.Record{}
+	}
+	return records, nil
+}
+
+func (s *Store) record(ctx context.Context, threadID, id string) (events.Record, error) {
+	var r events.Record
+	err := s.pool.QueryRow(ctx,
+		`SELECT id, seq, ts, payload FROM darkhold_events WHERE thread_id = $1 AND id = $2`, threadID, id,
+	).Scan(&r.ID, &r.Seq, &r.Time, &r.Payload)
+	return r, err
+}
+
+func (s *Store) Rehydrate(threadID string, readResult map[string]any) error {
+	return events.Rehydrate(s, threadID, readResult)
+}
+
+func (s *Store) Delete(threadID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	_, err := s.pool.Exec(ctx, `DELETE FROM darkhold_events WHERE thread_id = $1`, threadID)
+	return err
+}
+
+// Logs reports payload bytes per thread and the time of its last event.
+func (s *Store) Logs() ([]events.ThreadLog, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	rows, err := s.pool.Query(ctx,
+		`SELECT thread_id, sum(octet_length(payload))::bigint, max(ts) FROM darkhold_events GROUP BY thread_id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (events.ThreadLog, error) {
+		var l events.ThreadLog
+		var ts int64
+		err := row.Scan(&l.Key, &l.Size, &ts)
+		l.ModTime = time.UnixMilli(ts)
+		return l, err
+	})
+	if logs == nil {
+		logs = []events.ThreadLog{}
+	}
+	return logs, err
+}
+
+func (s *Store) ExportLog(threadID string) ([]byte, error) {
+	records, err := s.ReadRange(threadID, "", 0)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for _, record := range records {
+		line, _ := json.Marshal(record)
+		buf.Write(append(line, '\n'))
+	}
+	return buf.Bytes(), nil
+}
+
+// ImportLog replaces a thread's rows with an exported JSONL log and moves the
+// shared sequence past the imported records.
+func (s *Store) ImportLog(threadID string, data []byte, overwrite bool) error {
+	records, err := parseLog(data)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM darkhold_events WHERE thread_id = $1)`, threadID).Scan(&exists); err != nil {
+			return err
+		}
+		if exists && !overwrite {
+			return events.ErrLogExists
+		}
+		return replaceRows(ctx, tx, threadID, records)
+	})
+}
+
+// RewriteLog reads and replaces a thread's rows in one transaction holding
+// the thread's advisory lock, which Append takes too.
+func (s *Store) RewriteLog(threadID string, rewrite func(data []byte) ([]byte, error)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('darkhold_events'), hashtext($1))`, threadID); err != nil {
+			return err
+		}
+		rows, err := tx.Query(ctx, `SELECT id, seq, ts, payload FROM darkhold_events WHERE thread_id = $1 ORDER BY seq`, threadID)
+		if err != nil {
+			return err
+		}
+		records, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (events.Record, error) {
+			var r events.Record
+			err := row.Scan(&r.ID, &r.Seq, &r.Time, &r.Payload)
+			return r, err
+		})
+		if err != nil || len(records) == 0 {
+			return err
+		}
+		var buf bytes.Buffer
+		for _, record := range records {
+			line, _ := json.Marshal(record)
+			buf.Write(append(line, '\n'))
+		}
+		rewritten, err := rewrite(buf.Bytes())
+		if err != nil || rewritten == nil {
+			return err
+		}
+		if records, err = parseLog(rewritten); err != nil {
+			return err
+		}
+		return replaceRows(ctx, tx, threadID, records)
+	})
+}
+
+func parseLog(data []byte) ([]events.Record, error) {
+	var records []events.Record
+	for line := range bytes.SplitSeq(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var record events.Record
+		if err := json.Unmarshal(line, &record); err != nil || record.ID == "" {
+			return nil, fmt.Errorf("invalid event log line: %q", line)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// replaceRows swaps a thread's rows for records and moves the shared
+// sequence past them.
+func replaceRows(ctx context.Context, tx pgx.Tx, threadID string, records []events.Record) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM darkhold_events WHERE thread_id = $1`, threadID); err != nil {
+		return err
+	}
+	var maxSeq int64
+	for _, r := range records {
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO darkhold_events (thread_id, id, seq, ts, payload) VALUES ($1, $2, $3, $4, $5)`,
+			threadID, r.ID, r.Seq, r.Time, r.Payload,
+		); err != nil {
+			return err
+		}
+		maxSeq = max(maxSeq, r.Seq)
+	}
+	if maxSeq > 0 {
+		if _, err := tx.Exec(ctx, `SELECT setval('darkhold_event_seq', GREATEST($1, (SELECT last_value FROM darkhold_event_seq)))`, maxSeq); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cleanup is a no-op: the database is shared with other replicas and is never
+// wiped by one of them.
+func (s *Store) Cleanup() error {
+	return nil
+}
+
+// ThreadIndexBackend stores the thread index as a document row; saves are
+// announced so other replicas reload.
+func (s *Store) ThreadIndexBackend() threads.Backend {
+	return documentBackend{store: s, name: threadIndexDocument}
+}
+
+type documentBackend struct {
+	store *Store
+	name  string
+}
+
+func (b documentBackend) Load() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	var body string
+	err := b.store.pool.QueryRow(ctx, `SELECT body FROM darkhold_documents WHERE name = $1`, b.name).Scan(&body)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(body), nil
+}
+
+func (b documentBackend) Save(data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	return pgx.BeginFunc(ctx, b.store.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO darkhold_documents (name, body, updated_at) VALUES ($1, $2, now())
+			 ON CONFLICT (name) DO UPDATE SET body = excluded.body, updated_at = now()`,
+			b.name, string(data),
+		); err != nil {
+			return err
+		}
+		return b.store.notify(ctx, tx, documentsChannel, notification{Name: b.name})
+	})
+}
+
+// Notify sends payload to every other replica's Listen message handler on
+// channel. Payloads must stay under Postgres' 8000 byte NOTIFY limit.
+func (s *Store) Notify(ctx context.Context, channel string, payload []byte) error {
+	return s.notify(ctx, s.pool, messagesChannel, notification{Channel: channel, Payload: payload})
+}
+
+// Listen delivers what other replicas publish until ctx ends, reconnecting
+// with backoff when the connection drops. onEvent gets each record another
+// replica appended, onDocument the name of each document it saved (the thread
+// index is "threads"), and onMessage each payload it sent with Notify.
+func (s *Store) Listen(ctx context.Context, onEvent func(threadID string, record events.Record), onDocument func(name string), onMessage func(channel string, payload []byte)) {
+	h := handlers{onEvent, onDocument, onMessage}
+	backoff := time.Second
+	for ctx.Err() == nil {
+		err := s.listenOnce(ctx, h)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("[postgres] listener disconnected, retrying in %s: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+type handlers struct {
+	Event    func(threadID string, record events.Record)
+	Document func(name string)
+	Message  func(channel string, payload []byte)
+}
+
+func (s *Store) listenOnce(ctx context.Context, h handlers) error {
+	conn, err := pgx.Connect(ctx, s.url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	for _, channel := range []string{eventsChannel, documentsChannel, messagesChannel} {
+		if _, err := conn.Exec(ctx, "LISTEN "+channel); err != nil {
+			return err
+		}
+	}
+	for {
+		raw, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var n notification
+		if err := json.Unmarshal([]byte(raw.Payload), &n); err != nil || n.Origin == s.instance {
+			continue
+		}
+		switch raw.Channel {
+		case eventsChannel:
+			if h.Event == nil {
+				continue
+			}
+			fetchCtx, cancel := context.WithTimeout(ctx, queryTimeout)
+			record, err := s.record(fetchCtx, n.ThreadID, n.ID)
+			cancel()
+			if err != nil {
+				log.Printf("[postgres] failed to load event %s for thread %s: %v", n.ID, n.ThreadID, err)
+				continue
+			}
+			h.Event(n.ThreadID, record)
+		case documentsChannel:
+			if h.Document != nil {
+				h.Document(n.Name)
+			}
+		case messagesChannel:
+			if h.Message != nil {
+				h.Message(n.Channel, n.Payload)
+			}
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package pgstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	"darkhold-go/internal/events"
+	"darkhold-go/internal/threads"
+)
+
+// openTestStore connects to DARKHOLD_TEST_POSTGRES_URL, skipping when it is
+// not set.
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	url := os.Getenv("DARKHOLD_TEST_POSTGRES_URL")
+	if url == "" {
+		t.Skip("DARKHOLD_TEST_POSTGRES_URL is not set")
+	}
+	store, err := Open(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(store.Close)
+	return store
+}
+
+func TestStoreAppendReadAndImport(t *testing.T) {
+	store := openTestStore(t)
+	threadID := "pgstore-test-" + ulid.Make().String()
+	t.Cleanup(func() { _ = store.Delete(threadID) })
+
+	first, err := store.Append(threadID, `{"method":"a"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := store.Append(threadID, `{"method":"b"}`)
+	if second.Seq <= first.Seq {
+		t.Fatalf("expected increasing seq, got %d then %d", first.Seq, second.Seq)
+	}
+	records, err := store.ReadRange(threadID, first.ID, 0)
+	if err != nil || len(records) != 1 || records[0].Payload != `{"method":"b"}` {
+		t.Fatalf("unexpected range %v, %v", records, err)
+	}
+
+	exported, err := store.ExportLog(threadID)
+	if err != nil || strings.Count(string(exported), "\n") != 2 {
+		t.Fatalf("unexpected export %q, %v", exported, err)
+	}
+	if err := store.ImportLog(threadID, exported, false); !errors.Is(err, events.ErrLogExists) {
+		t.Fatalf("expected ErrLogExists, got %v", err)
+	}
+	if err := store.ImportLog(threadID, exported, true); err != nil {
+		t.Fatal(err)
+	}
+	third, _ := store.Append(threadID, `{"method":"c"}`)
+	if third.Seq <= second.Seq {
+		t.Fatalf("expected seq past imported records, got %d", third.Seq)
+	}
+}
+
+func TestStoresAppendingToOneThreadResumeBySeq(t *testing.T) {
+	a := openTestStore(t)
+	b := openTestStore(t)
+	threadID := "pgstore-test-" + ulid.Make().String()
+	t.Cleanup(func() { _ = a.Delete(threadID) })
+
+	var wg sync.WaitGroup
+	for _, store := range []*Store{a, b} {
+		wg.Go(func() {
+			for range 20 {
+				if _, err := store.Append(threadID, `{"method":"tick"}`); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	records, err := b.ReadRange(threadID, "", 0)
+	if err != nil || len(records) != 40 {
+		t.Fatalf("expected 40 records, got %d, %v", len(records), err)
+	}
+	for i, record := range records {
+		if i > 0 && record.Seq <= records[i-1].Seq {
+			t.Fatalf("records out of seq order at %d: %d after %d", i, record.Seq, records[i-1].Seq)
+		}
+		rest, err := a.ReadRange(threadID, record.ID, 0)
+		if err != nil || len(rest) != len(records)-i-1 {
+			t.Fatalf("resuming after record %d returned %d records, %v", i, len(rest), err)
+		}
+	}
+}
+
+func TestRewriteLogHoldsOffAppendsFromOtherReplicas(t *testing.T) {
+	a := openTestStore(t)
+	b := openTestStore(t)
+	threadID := "pgstore-test-" + ulid.Make().String()
+	t.Cleanup(func() { _ = a.Delete(threadID) })
+	for _, payload := range []string{
+		`{"method":"item/agentMessage/delta","params":{"itemId":"done","delta":"hi"}}`,
+		`{"method":"item/completed","params":{"item":{"id":"done"}}}`,
+	} {
+		if _, err := a.Append(threadID, payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	appended := make(chan error, 1)
+	err := a.RewriteLog(threadID, func(data []byte) ([]byte, error) {
+		go func() {
+			_, err := b.Append(threadID, `{"method":"thread/archived"}`)
+			appended <- err
+		}()
+		time.Sleep(100 * time.Millisecond)
+		lines := strings.SplitAfter(string(data), "\n")
+		return []byte(strings.Join(lines[1:], "")), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-appended; err != nil {
+		t.Fatal(err)
+	}
+	records, err := a.ReadRange(threadID, "", 0)
+	if err != nil || len(records) != 2 || records[1].Payload != `{"method":"thread/archived"}` {
+		t.Fatalf("expected the other replica's append to follow the rewrite, got %+v %v", records, err)
+	}
+}
+
+func TestStoreNotifiesOtherReplicas(t *testing.T) {
+	a := openTestStore(t)
+	b := openTestStore(t)
+	threadID := "pgstore-test-" + ulid.Make().String()
+	t.Cleanup(func() { _ = a.Delete(threadID) })
+
+	gotEvent := make(chan events.Record, 4)
+	gotDocument := make(chan string, 4)
+	gotMessage := make(chan string, 4)
+	ctx := t.Context()
+	go b.Listen(ctx, func(id string, record events.Record) {
+		if id == threadID {
+			gotEvent <- record
+		}
+	}, func(name string) {
+		gotDocument <- name
+	}, func(channel string, payload []byte) {
+		gotMessage <- channel + ":" + string(payload)
+	})
+	// LISTEN is asynchronous; keep publishing until b hears it.
+	deadline := time.After(5 * time.Second)
+	var record events.Record
+	for record.ID == "" {
+		sent, err := a.Append(threadID, `{"method":"ping"}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case record = <-gotEvent:
+			if record.Payload != sent.Payload {
+				t.Fatalf("unexpected relayed record %+v", record)
+			}
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("no event notification received")
+		}
+	}
+
+	ix, err := threads.OpenBackend(a.ThreadIndexBackend())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ix.Update(threadID, func(m *threads.Metadata) error { m.Title = "shared"; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case name := <-gotDocument:
+		if name != threadIndexDocument {
+			t.Fatalf("unexpected document %q", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no document notification received")
+	}
+
+	if err := a.Notify(context.Background(), "interactions", []byte(`{"ok":true}`)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case message := <-gotMessage:
+		if message != `interactions:{"ok":true}` {
+			t.Fatalf("unexpected message %q", message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+}
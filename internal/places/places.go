@@ -0,0 +1,170 @@
+// Package places remembers directories worth jumping to in the folder
+// picker: the ones recently used as thread cwds and the ones a user starred.
+package places
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MaxRecent is how many recent directories are kept; the least recently
+// used are dropped first.
+const MaxRecent = 50
+
+// Recent is a directory used as a thread cwd.
+type Recent struct {
+	Path string `json:"path"`
+	// LastUsedAt is when a thread last started or resumed there, in Unix
+	// milliseconds.
+	LastUsedAt int64 `json:"lastUsedAt"`
+	Uses       int   `json:"uses"`
+}
+
+// Favorite is a directory a user starred.
+type Favorite struct {
+	Path string `json:"path"`
+	Name string `json:"name"`
+	// AddedAt is when it was starred, in Unix milliseconds.
+	AddedAt int64 `json:"addedAt"`
+}
+
+type document struct {
+	Recent    []Recent   `json:"recent"`
+	Favorites []Favorite `json:"favorites"`
+}
+
+// Store holds recent and favorite directories and, when a path is
+// configured, persists them.
+type Store struct {
+	path string
+	now  func() time.Time
+
+	mu  sync.Mutex
+	doc document
+}
+
+// Open loads places stored at path. An empty path keeps them in memory; a
+// missing file starts empty.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, now: time.Now}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.doc); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Touch records that dir was just used as a thread cwd.
+func (s *Store) Touch(dir string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := Recent{Path: dir}
+	for i, recent := range s.doc.Recent {
+		if recent.Path == dir {
+			entry = recent
+			s.doc.Recent = append(s.doc.Recent[:i], s.doc.Recent[i+1:]...)
+			break
+		}
+	}
+	entry.LastUsedAt = s.now().UnixMilli()
+	entry.Uses++
+	s.doc.Recent = append([]Recent{entry}, s.doc.Recent...)
+	if len(s.doc.Recent) > MaxRecent {
+		s.doc.Recent = s.doc.Recent[:MaxRecent]
+	}
+	return s.saveLocked()
+}
+
+// Recent returns recent directories, most recently used first.
+func (s *Store) Recent() []Recent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Recent{}, s.doc.Recent...)
+}
+
+// Forget drops dir from the recent directories and reports whether it was
+// there.
+func (s *Store) Forget(dir string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, recent := range s.doc.Recent {
+		if recent.Path == dir {
+			s.doc.Recent = append(s.doc.Recent[:i], s.doc.Recent[i+1:]...)
+			return true, s.saveLocked()
+		}
+	}
+	return false, nil
+}
+
+// Favorites returns starred directories ordered by name.
+func (s *Store) Favorites() []Favorite {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	favorites := append([]Favorite{}, s.doc.Favorites...)
+	sort.SliceStable(favorites, func(i, j int) bool { return favorites[i].Name < favorites[j].Name })
+	return favorites
+}
+
+// AddFavorite stars dir under name, which defaults to the directory's base
+// name. Starring a directory again renames it and keeps when it was added.
+func (s *Store) AddFavorite(dir, name string) (Favorite, error) {
+	if name == "" {
+		name = filepath.Base(dir)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, favorite := range s.doc.Favorites {
+		if favorite.Path == dir {
+			s.doc.Favorites[i].Name = name
+			return s.doc.Favorites[i], s.saveLocked()
+		}
+	}
+	favorite := Favorite{Path: dir, Name: name, AddedAt: s.now().UnixMilli()}
+	s.doc.Favorites = append(s.doc.Favorites, favorite)
+	return favorite, s.saveLocked()
+}
+
+// RemoveFavorite unstars dir and reports whether it was starred.
+func (s *Store) RemoveFavorite(dir string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, favorite := range s.doc.Favorites {
+		if favorite.Path == dir {
+			s.doc.Favorites = append(s.doc.Favorites[:i], s.doc.Favorites[i+1:]...)
+			return true, s.saveLocked()
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,57 @@
+package places
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoreOrdersRecentAndPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fs-places.json")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.UnixMilli(1000)
+	store.now = func() time.Time { clock = clock.Add(time.Second); return clock }
+	for _, dir := range []string{"/w/a", "/w/b", "/w/a"} {
+		if err := store.Touch(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.AddFavorite("/w/service", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.AddFavorite("/w/api", "API"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recent := reopened.Recent()
+	if len(recent) != 2 || recent[0].Path != "/w/a" || recent[0].Uses != 2 || recent[1].Path != "/w/b" {
+		t.Fatalf("unexpected recent directories: %+v", recent)
+	}
+	favorites := reopened.Favorites()
+	if len(favorites) != 2 || favorites[0].Name != "API" || favorites[1].Name != "service" {
+		t.Fatalf("unexpected favorites: %+v", favorites)
+	}
+	if removed, err := reopened.RemoveFavorite("/w/api"); err != nil || !removed {
+		t.Fatalf("expected the favorite to be removed, got %v %v", removed, err)
+	}
+	if removed, _ := reopened.RemoveFavorite("/w/api"); removed {
+		t.Fatal("expected a second removal to find nothing")
+	}
+}
+
+func TestTouchKeepsMaxRecent(t *testing.T) {
+	store, _ := Open("")
+	for i := range MaxRecent + 5 {
+		_ = store.Touch(filepath.Join("/w", string(rune('a'+i%26)), string(rune('a'+i/26))))
+	}
+	if got := len(store.Recent()); got != MaxRecent {
+		t.Fatalf("expected %d recent directories, got %d", MaxRecent, got)
+	}
+}
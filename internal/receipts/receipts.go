@@ -0,0 +1,99 @@
+// Package receipts remembers, per reader and thread, the last event the
+// reader has seen, so unread activity can be counted.
+package receipts
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Receipt is how far a reader has read a thread.
+type Receipt struct {
+	// EventID is the last event read. Event IDs are ULIDs, so later events
+	// compare greater.
+	EventID string `json:"eventId"`
+	// ReadAt is when the receipt last moved, in Unix milliseconds.
+	ReadAt int64 `json:"readAt"`
+}
+
+// Store holds receipts and, when a path is configured, persists them.
+type Store struct {
+	path string
+	now  func() time.Time
+
+	mu      sync.Mutex
+	readers map[string]map[string]Receipt
+}
+
+// Open loads receipts stored at path. An empty path keeps receipts in memory;
+// a missing file starts empty.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, now: time.Now, readers: map[string]map[string]Receipt{}}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.readers); err != nil {
+		return nil, err
+	}
+	if s.readers == nil {
+		s.readers = map[string]map[string]Receipt{}
+	}
+	return s, nil
+}
+
+// Get returns reader's receipt for threadID.
+func (s *Store) Get(reader, threadID string) (Receipt, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	receipt, ok := s.readers[reader][threadID]
+	return receipt, ok
+}
+
+// Mark records that reader has read threadID up to eventID. Receipts only
+// move forward: an eventID before the current one is ignored. It returns the
+// resulting receipt.
+func (s *Store) Mark(reader, threadID, eventID string) (Receipt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.readers[reader][threadID]
+	if ok && eventID <= current.EventID {
+		return current, nil
+	}
+	threads := s.readers[reader]
+	if threads == nil {
+		threads = map[string]Receipt{}
+		s.readers[reader] = threads
+	}
+	receipt := Receipt{EventID: eventID, ReadAt: s.now().UnixMilli()}
+	threads[threadID] = receipt
+	return receipt, s.saveLocked()
+}
+
+func (s *Store) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.readers, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,31 @@
+package receipts
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestStorePersistsAndOnlyMovesForward(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "read-receipts.json")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Mark("phone", "thread-a", "01J00000000000000000000002"); err != nil {
+		t.Fatal(err)
+	}
+	if receipt, _ := store.Mark("phone", "thread-a", "01J00000000000000000000001"); receipt.EventID != "01J00000000000000000000002" {
+		t.Fatalf("expected an older event to be ignored, got %+v", receipt)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt, ok := reopened.Get("phone", "thread-a"); !ok || receipt.EventID != "01J00000000000000000000002" || receipt.ReadAt == 0 {
+		t.Fatalf("unexpected persisted receipt: %+v %v", receipt, ok)
+	}
+	if _, ok := reopened.Get("desktop", "thread-a"); ok {
+		t.Fatal("expected receipts to be kept per reader")
+	}
+}
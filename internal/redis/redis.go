@@ -0,0 +1,347 @@
+// Package redis is a minimal Redis pub/sub client: PUBLISH over one
+// reconnecting connection and SUBSCRIBE to a single channel over another. It
+// implements only what darkhold needs to fan SSE events out between
+// instances.
+package redis
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	queueSize  = 256
+	maxBackoff = 30 * time.Second
+)
+
+// Options configures a PubSub. URL uses redis:// or rediss:// (TLS);
+// credentials may be given as URL userinfo. The database number is ignored
+// because pub/sub channels are server-wide.
+type Options struct {
+	URL     string
+	Channel string
+}
+
+// PubSub publishes to and subscribes to one channel. Published messages are
+// queued and written from a background goroutine; messages that arrive while
+// the queue is full or the server is unreachable are dropped, matching
+// Redis' own at-most-once pub/sub delivery.
+type PubSub struct {
+	opts     Options
+	addr     string
+	useTLS   bool
+	username string
+	password string
+	handler  func(payload []byte)
+
+	queue chan []byte
+	stop  chan struct{}
+	wg    sync.WaitGroup
+	once  sync.Once
+
+	mu      sync.Mutex
+	dropped int64
+	conns   map[net.Conn]struct{}
+}
+
+// ParseURL validates a Redis URL and returns the dial address, whether TLS
+// is required and any credentials.
+func ParseURL(raw string) (addr string, useTLS bool, username, password string, err error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "", false, "", "", fmt.Errorf("invalid Redis URL: %s", raw)
+	}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		useTLS = true
+	default:
+		return "", false, "", "", fmt.Errorf("invalid Redis URL: %s", raw)
+	}
+	port := "6379"
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	if u.User != nil {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+	}
+	return net.JoinHostPort(u.Hostname(), port), useTLS, username, password, nil
+}
+
+// New validates opts and starts the publish and subscribe loops. handler is
+// called from the subscriber goroutine for every message on the channel,
+// including ones this PubSub published.
+func New(opts Options, handler func(payload []byte)) (*PubSub, error) {
+	addr, useTLS, username, password, err := ParseURL(opts.URL)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Channel == "" {
+		return nil, errors.New("redis channel is required")
+	}
+	p := &PubSub{
+		opts:     opts,
+		addr:     addr,
+		useTLS:   useTLS,
+		username: username,
+		password: password,
+		handler:  handler,
+		queue:    make(chan []byte, queueSize),
+		stop:     make(chan struct{}),
+		conns:    map[net.Conn]struct{}{},
+	}
+	p.wg.Add(2)
+	go p.loop("publish", p.servePublish)
+	go p.loop("subscribe", p.serveSubscribe)
+	return p, nil
+}
+
+// Publish queues payload for the channel. It never blocks.
+func (p *PubSub) Publish(payload []byte) {
+	select {
+	case <-p.stop:
+		return
+	default:
+	}
+	select {
+	case p.queue <- payload:
+	default:
+		p.mu.Lock()
+		p.dropped++
+		p.mu.Unlock()
+	}
+}
+
+// Dropped reports how many messages were discarded because the queue was
+// full.
+func (p *PubSub) Dropped() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.dropped
+}
+
+// Close stops both loops and closes their connections. Queued messages that
+// have not been written are discarded.
+func (p *PubSub) Close() {
+	p.once.Do(func() {
+		close(p.stop)
+		p.mu.Lock()
+		for conn := range p.conns {
+			conn.Close()
+		}
+		p.mu.Unlock()
+	})
+	p.wg.Wait()
+}
+
+func (p *PubSub) stopped() bool {
+	select {
+	case <-p.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// loop keeps serve running on a fresh connection, backing off between failed
+// attempts, until Close.
+func (p *PubSub) loop(role string, serve func(net.Conn, *bufio.Reader) error) {
+	defer p.wg.Done()
+	backoff := time.Second
+	for !p.stopped() {
+		conn, reader, err := p.connect()
+		if err == nil {
+			backoff = time.Second
+			err = serve(conn, reader)
+			p.mu.Lock()
+			delete(p.conns, conn)
+			p.mu.Unlock()
+			conn.Close()
+		}
+		if p.stopped() {
+			return
+		}
+		log.Printf("[redis] %s connection to %s failed: %v", role, p.addr, err)
+		select {
+		case <-p.stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func (p *PubSub) connect() (net.Conn, *bufio.Reader, error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	var err error
+	if p.useTLS {
+		host, _, _ := net.SplitHostPort(p.addr)
+		conn, err = tls.DialWithDialer(dialer, "tcp", p.addr, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", p.addr)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	p.mu.Lock()
+	if p.stopped() {
+		p.mu.Unlock()
+		conn.Close()
+		return nil, nil, errors.New("closed")
+	}
+	p.conns[conn] = struct{}{}
+	p.mu.Unlock()
+	reader := bufio.NewReader(conn)
+	if p.password != "" {
+		args := []string{"AUTH", p.password}
+		if p.username != "" {
+			args = []string{"AUTH", p.username, p.password}
+		}
+		_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+		if _, err := conn.Write(command(args...)); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		if _, err := readReply(reader); err != nil {
+			conn.Close()
+			return nil, nil, fmt.Errorf("AUTH: %w", err)
+		}
+		_ = conn.SetDeadline(time.Time{})
+	}
+	return conn, reader, nil
+}
+
+// servePublish writes queued messages until the connection fails or the
+// PubSub closes.
+func (p *PubSub) servePublish(conn net.Conn, reader *bufio.Reader) error {
+	readErr := make(chan error, 1)
+	go func() {
+		// Drain PUBLISH replies so the socket never backs up; an error reply
+		// is logged, a read error means the connection is gone.
+		for {
+			if _, err := readReply(reader); err != nil {
+				var replyErr replyError
+				if errors.As(err, &replyErr) {
+					log.Printf("[redis] PUBLISH failed: %v", err)
+					continue
+				}
+				readErr <- err
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case <-p.stop:
+			return nil
+		case err := <-readErr:
+			return err
+		case payload := <-p.queue:
+			_ = conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if _, err := conn.Write(command("PUBLISH", p.opts.Channel, string(payload))); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// serveSubscribe subscribes to the channel and hands every message to the
+// handler until the connection fails.
+func (p *PubSub) serveSubscribe(conn net.Conn, reader *bufio.Reader) error {
+	if _, err := conn.Write(command("SUBSCRIBE", p.opts.Channel)); err != nil {
+		return err
+	}
+	for {
+		reply, err := readReply(reader)
+		if err != nil {
+			return err
+		}
+		push, _ := reply.([]any)
+		if len(push) != 3 {
+			continue
+		}
+		if kind, _ := push[0].(string); kind != "message" {
+			continue
+		}
+		if payload, ok := push[2].(string); ok && p.handler != nil {
+			p.handler([]byte(payload))
+		}
+	}
+}
+
+// command encodes args as a RESP array of bulk strings.
+func command(args ...string) []byte {
+	out := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		out = append(out, "$"+strconv.Itoa(len(arg))+"\r\n"...)
+		out = append(out, arg...)
+		out = append(out, "\r\n"...)
+	}
+	return out
+}
+
+// replyError is an error reply ("-ERR ...") from the server.
+type replyError string
+
+func (e replyError) Error() string { return string(e) }
+
+// readReply decodes one RESP2 reply: simple and bulk strings as string,
+// integers as int64, arrays as []any and nil bulk strings as nil.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("malformed reply")
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, replyError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, errors.New("malformed bulk length")
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, errors.New("malformed array length")
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unexpected reply type %q", kind)
+}
@@ -0,0 +1,146 @@
+package redis
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer speaks just enough RESP for PubSub: AUTH, SUBSCRIBE and PUBLISH
+// to every subscriber of the channel.
+type fakeServer struct {
+	addr     string
+	password string
+
+	mu          sync.Mutex
+	subscribers map[net.Conn]string
+}
+
+func startFakeServer(t *testing.T, password string) *fakeServer {
+	t.Helper()
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("loopback sockets are not available in this environment")
+	}
+	t.Cleanup(func() { listener.Close() })
+	s := &fakeServer{addr: listener.Addr().String(), password: password, subscribers: map[net.Conn]string{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authed := s.password == ""
+	for {
+		reply, err := readReply(reader)
+		if err != nil {
+			s.mu.Lock()
+			delete(s.subscribers, conn)
+			s.mu.Unlock()
+			return
+		}
+		raw, _ := reply.([]any)
+		args := make([]string, len(raw))
+		for i, v := range raw {
+			args[i], _ = v.(string)
+		}
+		switch {
+		case args[0] == "AUTH":
+			if args[len(args)-1] != s.password {
+				_, _ = conn.Write([]byte("-WRONGPASS invalid password\r\n"))
+				continue
+			}
+			authed = true
+			_, _ = conn.Write([]byte("+OK\r\n"))
+		case !authed:
+			_, _ = conn.Write([]byte("-NOAUTH Authentication required.\r\n"))
+		case args[0] == "SUBSCRIBE":
+			s.mu.Lock()
+			s.subscribers[conn] = args[1]
+			s.mu.Unlock()
+			_, _ = conn.Write([]byte("*3\r\n$9\r\nsubscribe\r\n" + bulk(args[1]) + ":1\r\n"))
+		case args[0] == "PUBLISH":
+			s.mu.Lock()
+			n := 0
+			for sub, channel := range s.subscribers {
+				if channel == args[1] {
+					_, _ = sub.Write([]byte("*3\r\n$7\r\nmessage\r\n" + bulk(channel) + bulk(args[2])))
+					n++
+				}
+			}
+			s.mu.Unlock()
+			_, _ = conn.Write([]byte(":" + strconv.Itoa(n) + "\r\n"))
+		}
+	}
+}
+
+func (s *fakeServer) subscriberCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subscribers)
+}
+
+func bulk(s string) string {
+	return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+}
+
+func TestPubSubDeliversAcrossClients(t *testing.T) {
+	server := startFakeServer(t, "secret")
+	received := make(chan string, 4)
+	url := "redis://:secret@" + server.addr
+	a, err := New(Options{URL: url, Channel: "darkhold:events"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := New(Options{URL: url, Channel: "darkhold:events"}, func(payload []byte) { received <- string(payload) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for server.subscriberCount() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("clients did not subscribe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	a.Publish([]byte("hello\r\nworld"))
+	select {
+	case got := <-received:
+		if got != "hello\r\nworld" {
+			t.Fatalf("unexpected payload %q", got)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("message not delivered")
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	addr, useTLS, username, password, err := ParseURL("rediss://user:pw@cache.internal/2")
+	if err != nil || addr != "cache.internal:6379" || !useTLS || username != "user" || password != "pw" {
+		t.Fatalf("unexpected parse: %s %v %s %s %v", addr, useTLS, username, password, err)
+	}
+	if _, _, _, _, err := ParseURL("http://cache.internal"); err == nil {
+		t.Fatal("expected non-redis scheme to be rejected")
+	}
+}
+
+func TestCommandEncoding(t *testing.T) {
+	if got := string(command("PUBLISH", "ch", "hi")); got != "*3\r\n$7\r\nPUBLISH\r\n$2\r\nch\r\n$2\r\nhi\r\n" {
+		t.Fatalf("unexpected encoding %q", got)
+	}
+}
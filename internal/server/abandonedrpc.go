@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+const (
+	// Reasons a caller stopped waiting for an RPC.
+	abandonedReasonCanceled = "canceled"
+	abandonedReasonTimeout  = "timeout"
+
+	// abandonedRPCTTL is how long a response is waited for after its caller
+	// gave up; later ones are dropped as before.
+	abandonedRPCTTL = 10 * time.Minute
+	// abandonedResultMaxBytes caps the result copied into the event; larger
+	// ones, such as whole thread/read results, are left out.
+	abandonedResultMaxBytes = 64 << 10
+)
+
+// abandonedRPC is a call whose caller stopped waiting, by disconnecting or
+// by reaching the RPC timeout, while the agent still owes the response.
+type abandonedRPC struct {
+	method   string
+	threadID string
+	reason   string
+	sent     time.Time
+	at       time.Time
+}
+
+// abandonRPC stops waiting for requestID and remembers it, so its response
+// is reported by handleAbandonedResponse instead of dropped. Pings are
+// forgotten: their timeouts are sessionhealth.go's business.
+func (s *Server) abandonRPC(sess *session, requestID int64, method string, params any, reason string, sent time.Time) {
+	now := time.Now()
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	delete(sess.pending, requestID)
+	if method == sessionPingMethod {
+		return
+	}
+	if sess.abandoned == nil {
+		sess.abandoned = map[int64]abandonedRPC{}
+	}
+	for id, call := range sess.abandoned {
+		if now.Sub(call.at) > abandonedRPCTTL {
+			delete(sess.abandoned, id)
+		}
+	}
+	paramsMap, _ := params.(map[string]any)
+	threadID, _ := paramsMap["threadId"].(string)
+	sess.abandoned[requestID] = abandonedRPC{method: method, threadID: threadID, reason: reason, sent: sent, at: now}
+	sess.abandonedRPCs++
+}
+
+// handleAbandonedResponse publishes darkhold/rpc/abandoned to the thread for
+// a response that arrived after its caller gave up. A turn it started is
+// interrupted: nobody is waiting for it, and a client that retries would
+// run it twice.
+func (s *Server) handleAbandonedResponse(sess *session, call abandonedRPC, response map[string]any) {
+	result, _ := response["result"].(map[string]any)
+	threadID := call.threadID
+	if threadID == "" {
+		thread, _ := result["thread"].(map[string]any)
+		threadID, _ = thread["id"].(string)
+	}
+	event := map[string]any{
+		"method":      call.method,
+		"reason":      call.reason,
+		"abandonedAt": call.at.UnixMilli(),
+		"latencyMs":   time.Since(call.sent).Milliseconds(),
+	}
+	if threadID != "" {
+		event["threadId"] = threadID
+	}
+	if errObj, ok := response["error"].(map[string]any); ok {
+		event["error"] = errObj
+	} else if encoded, _ := json.Marshal(response["result"]); len(encoded) <= abandonedResultMaxBytes {
+		event["result"] = response["result"]
+	} else {
+		event["resultOmitted"] = true
+	}
+	turnID := ""
+	if call.method == "turn/start" {
+		turn, _ := result["turn"].(map[string]any)
+		turnID, _ = turn["id"].(string)
+	}
+	if turnID != "" && threadID != "" {
+		event["turnId"] = turnID
+		event["interrupted"] = true
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), s.rpcTimeout)
+			defer cancel()
+			if _, err := s.callSessionRPC(ctx, sess, "turn/interrupt", map[string]any{"threadId": threadID, "turnId": turnID}); err != nil {
+				log.Printf("[session=%d] failed to interrupt abandoned turn %s: %v", sess.id, turnID, err)
+			}
+		}()
+	}
+	log.Printf("[session=%d] %s answered %s after its caller gave up (%s)", sess.id, call.method, time.Since(call.sent).Round(time.Millisecond), call.reason)
+	if threadID == "" {
+		return
+	}
+	encoded, _ := json.Marshal(map[string]any{"method": "darkhold/rpc/abandoned", "params": event})
+	s.publishThreadEvent(threadID, string(encoded))
+}
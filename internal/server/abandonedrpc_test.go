@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestAbandonedTurnStartIsInterruptedAndReported(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.FakeApprovalRate = 0
+		cfg.FakeLatency = 200 * time.Millisecond
+	})
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	s.app.sessionsMu.RLock()
+	sess := s.app.sessions[s.app.threadToSession[threadID]]
+	s.app.sessionsMu.RUnlock()
+	if sess == nil {
+		t.Fatal("expected the thread to be bound to a session")
+	}
+
+	// A caller already gone gets nothing sent.
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.app.callSessionRPC(canceled, sess, "turn/start", map[string]any{"threadId": threadID}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a canceled call to fail fast, got %v", err)
+	}
+
+	// Give up on a turn/start as a disconnecting caller would, then let the
+	// agent answer it.
+	params := map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "hello"}}}
+	requestID := atomic.AddInt64(&sess.nextRequestID, 1_000_000)
+	s.app.abandonRPC(sess, requestID, "turn/start", params, abandonedReasonCanceled, time.Now())
+	line, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": requestID, "method": "turn/start", "params": params})
+	if err := s.app.writeSessionLine(sess, string(line)); err != nil {
+		t.Fatal(err)
+	}
+
+	var abandoned, completed map[string]any
+	waitForCondition(t, 5*time.Second, 20*time.Millisecond, func() bool {
+		records, _ := s.app.eventStore.ReadRange(threadID, "", 0)
+		for _, record := range records {
+			var event struct {
+				Method string         `json:"method"`
+				Params map[string]any `json:"params"`
+			}
+			if json.Unmarshal([]byte(record.Payload), &event) != nil {
+				continue
+			}
+			switch event.Method {
+			case "darkhold/rpc/abandoned":
+				abandoned = event.Params
+			case "turn/completed":
+				if turn, _ := event.Params["turn"].(map[string]any); turn["status"] == "interrupted" {
+					completed = event.Params
+				}
+			}
+		}
+		return abandoned != nil && completed != nil
+	})
+	if abandoned["method"] != "turn/start" || abandoned["reason"] != "canceled" || abandoned["interrupted"] != true || abandoned["turnId"] == nil {
+		t.Fatalf("unexpected abandoned event: %v", abandoned)
+	}
+	if completed["turnId"] != abandoned["turnId"] {
+		t.Fatalf("expected the abandoned turn to be interrupted, got %v", completed)
+	}
+	sess.mu.Lock()
+	left, count := len(sess.abandoned), sess.abandonedRPCs
+	sess.mu.Unlock()
+	if left != 0 || count != 1 {
+		t.Fatalf("expected the abandoned call to be settled, %d left of %d", left, count)
+	}
+}
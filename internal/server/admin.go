@@ -0,0 +1,233 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	sse "github.com/tmaxmax/go-sse"
+)
+
+const (
+	errCodeSessionNotFound = "SESSION_NOT_FOUND"
+
+	// stderrRingSize is how many stderr lines are kept per session.
+	stderrRingSize = 2000
+	// exitedSessionHistory is how many exited sessions stay inspectable so
+	// startup failures can be debugged after the process is gone.
+	exitedSessionHistory = 16
+)
+
+type sessionInfo struct {
+	ID             int      `json:"id"`
+	PID            int      `json:"pid,omitempty"`
+	Alive          bool     `json:"alive"`
+	State          string   `json:"state"`
+	StopRequested  bool     `json:"stopRequested,omitempty"`
+	Unhealthy      bool     `json:"unhealthy,omitempty"`
+	LastPingAt     int64    `json:"lastPingAt,omitempty"`
+	Keepalives     int64    `json:"keepalives,omitempty"`
+	KeepaliveAt    int64    `json:"lastKeepaliveAt,omitempty"`
+	StartedAt      int64    `json:"startedAt"`
+	LastActivityAt int64    `json:"lastActivityAt"`
+	ExitedAt       int64    `json:"exitedAt,omitempty"`
+	ExitCode       *int     `json:"exitCode,omitempty"`
+	ThreadIDs      []string `json:"threadIds"`
+	ActiveTurns    int      `json:"activeTurns"`
+	PendingRPCs    int      `json:"pendingRpcs"`
+	PeakPending    int      `json:"peakPendingRpcs"`
+	RejectedRPCs   int64    `json:"rejectedRpcs"`
+	AbandonedRPCs  int64    `json:"abandonedRpcs"`
+	RPCLatencyMs   float64  `json:"rpcLatencyMs"`
+	Routed         int64    `json:"routed"`
+	StderrLines    int64    `json:"stderrLines"`
+	// Usage is the latest CPU and memory sample of the agent's process
+	// tree; KilledFor is set when --max-agent-rss (rss) or --max-agent-cpu
+	// (cpu) killed it.
+	Usage     *agentUsage `json:"usage,omitempty"`
+	KilledFor string      `json:"killedFor,omitempty"`
+	// Transcript names the session's recording under --record-sessions.
+	Transcript string `json:"transcript,omitempty"`
+}
+
+func (sess *session) info() sessionInfo {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	info := sessionInfo{
+		ID:             sess.id,
+		Alive:          !sess.closed,
+		State:          sess.state,
+		StopRequested:  sess.stopRequested,
+		Unhealthy:      sess.unhealthy,
+		StartedAt:      sess.startedAt.UnixMilli(),
+		LastActivityAt: sess.lastActivityAt.UnixMilli(),
+		ThreadIDs:      make([]string, 0, len(sess.knownThreadIDs)),
+		ActiveTurns:    len(sess.activeTurnIDs),
+		PendingRPCs:    len(sess.pending),
+		PeakPending:    sess.peakPending,
+		RejectedRPCs:   sess.rejectedRPCs,
+		AbandonedRPCs:  sess.abandonedRPCs,
+		RPCLatencyMs:   float64(sess.rpcLatency.Microseconds()) / 1000,
+		Routed:         sess.routed,
+		Usage:          sess.usage,
+		KilledFor:      sess.killedFor,
+	}
+	if sess.recorder != nil {
+		info.Transcript = sess.recorder.name
+	}
+	if sess.proc != nil {
+		info.PID = sess.proc.Pid()
+	}
+	if !sess.lastPingAt.IsZero() {
+		info.LastPingAt = sess.lastPingAt.UnixMilli()
+	}
+	if !sess.lastKeepaliveAt.IsZero() {
+		info.Keepalives, info.KeepaliveAt = sess.keepalives, sess.lastKeepaliveAt.UnixMilli()
+	}
+	if !sess.exitedAt.IsZero() {
+		code := sess.exitCode
+		info.Alive = false
+		info.ExitedAt = sess.exitedAt.UnixMilli()
+		info.ExitCode = &code
+	}
+	for threadID := range sess.knownThreadIDs {
+		info.ThreadIDs = append(info.ThreadIDs, threadID)
+	}
+	sort.Strings(info.ThreadIDs)
+	if sess.stderr != nil {
+		sess.stderr.mu.Lock()
+		info.StderrLines = sess.stderr.seq
+		sess.stderr.mu.Unlock()
+	}
+	return info
+}
+
+// lookupSession finds a live or recently exited session by ID.
+func (s *Server) lookupSession(id int) *session {
+	s.sessionsMu.RLock()
+	defer s.sessionsMu.RUnlock()
+	if sess, ok := s.sessions[id]; ok {
+		return sess
+	}
+	for _, sess := range s.exitedSessions {
+		if sess.id == id {
+			return sess
+		}
+	}
+	return nil
+}
+
+func (s *Server) handleAdminSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	s.sessionsMu.RLock()
+	all := make([]*session, 0, len(s.sessions)+len(s.exitedSessions))
+	for _, sess := range s.sessions {
+		all = append(all, sess)
+	}
+	all = append(all, s.exitedSessions...)
+	s.sessionsMu.RUnlock()
+
+	infos := make([]sessionInfo, 0, len(all))
+	for _, sess := range all {
+		infos = append(infos, sess.info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	writeJSON(w, http.StatusOK, map[string]any{"sessions": infos, "routing": s.recentRouting(), "maxSessionRpcs": s.cfg.MaxSessionRPCs})
+}
+
+func (s *Server) sessionFromPath(w http.ResponseWriter, r *http.Request) *session {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "session id must be an integer.")
+		return nil
+	}
+	sess := s.lookupSession(id)
+	if sess == nil {
+		writeError(w, http.StatusNotFound, errCodeSessionNotFound, "session not found.")
+		return nil
+	}
+	return sess
+}
+
+func (s *Server) handleAdminSessionStderr(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	sess := s.sessionFromPath(w, r)
+	if sess == nil {
+		return
+	}
+	after, _ := strconv.ParseInt(r.URL.Query().Get("after"), 10, 64)
+	writeJSON(w, http.StatusOK, map[string]any{"sessionId": sess.id, "lines": sess.stderr.snapshot(after)})
+}
+
+// handleAdminSessionStderrStream tails a session's stderr over SSE.
+func (s *Server) handleAdminSessionStderrStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	sess := s.sessionFromPath(w, r)
+	if sess == nil {
+		return
+	}
+	streamLineRing(w, r, sess.stderr, func(line logLine) string {
+		encoded, _ := json.Marshal(line)
+		return string(encoded)
+	})
+}
+
+// streamLineRing sends a ring's backlog and live lines over SSE. Event IDs are
+// line sequence numbers, so Last-Event-ID (or ?after=) resumes without gaps
+// while the lines are still buffered.
+func streamLineRing(w http.ResponseWriter, r *http.Request, ring *lineRing, encode func(logLine) string) {
+	lastRaw := strings.TrimSpace(r.Header.Get("Last-Event-ID"))
+	if lastRaw == "" {
+		lastRaw = r.URL.Query().Get("after")
+	}
+	lastSeq, _ := strconv.ParseInt(lastRaw, 10, 64)
+
+	backlog, live, cancel := ring.subscribe(lastSeq)
+	defer cancel()
+
+	stream, err := sse.Upgrade(w, r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+		return
+	}
+	ready := &sse.Message{}
+	ready.AppendComment("ready")
+	if err := stream.Send(ready); err != nil {
+		return
+	}
+	send := func(line logLine) error {
+		if line.Seq <= lastSeq {
+			return nil
+		}
+		lastSeq = line.Seq
+		return sendSSEMessage(stream, strconv.FormatInt(line.Seq, 10), encode(line))
+	}
+	for _, line := range backlog {
+		if err := send(line); err != nil {
+			return
+		}
+	}
+	_ = stream.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case line := <-live:
+			if err := send(line); err != nil {
+				return
+			}
+			_ = stream.Flush()
+		}
+	}
+}
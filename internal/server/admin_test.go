@@ -0,0 +1,69 @@
+package server
+
+import (
+	"bufio"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminSessionStderrCapturesAgentOutput(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	_ = postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+
+	_, listed := doJSON(t, http.MethodGet, s.http.URL+"/api/admin/sessions", nil)
+	sessions, _ := listed["sessions"].([]any)
+	if len(sessions) != 1 {
+		t.Fatalf("expected one session, got %v", listed)
+	}
+	info := sessions[0].(map[string]any)
+	if info["alive"] != true || len(info["threadIds"].([]any)) != 1 {
+		t.Fatalf("unexpected session info: %v", info)
+	}
+	sessionURL := s.http.URL + "/api/admin/sessions/" + strconv.Itoa(int(info["id"].(float64)))
+
+	var lines []any
+	waitForCondition(t, 5*time.Second, 20*time.Millisecond, func() bool {
+		_, payload := doJSON(t, http.MethodGet, sessionURL+"/stderr", nil)
+		lines, _ = payload["lines"].([]any)
+		return len(lines) > 0
+	})
+	first := lines[0].(map[string]any)
+	if !strings.HasPrefix(first["text"].(string), "fake agent started") || first["seq"] != float64(1) || first["time"].(float64) <= 0 {
+		t.Fatalf("unexpected stderr line: %v", first)
+	}
+
+	_, after := doJSON(t, http.MethodGet, sessionURL+"/stderr?after=1", nil)
+	if rest, _ := after["lines"].([]any); len(rest) != 0 {
+		t.Fatalf("expected no lines after seq 1, got %v", rest)
+	}
+
+	resp, err := http.Get(sessionURL + "/stderr/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for stderr backlog on the stream")
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(line, "data:") && strings.Contains(line, "fake agent started") {
+			break
+		}
+	}
+
+	missing, _ := doJSON(t, http.MethodGet, s.http.URL+"/api/admin/sessions/999/stderr", nil)
+	if missing.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown session, got %d", missing.StatusCode)
+	}
+}
@@ -0,0 +1,259 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// orphanTermGrace is how long an orphaned agent gets to exit after
+	// SIGTERM before it is killed.
+	orphanTermGrace = 5 * time.Second
+
+	orphanTerminated = "terminated"
+	orphanKilled     = "killed"
+	orphanExited     = "exited"
+	orphanPIDReused  = "pid-reused"
+	orphanUnverified = "unverified"
+	orphanOwnerAlive = "owner-alive"
+	orphanFailed     = "failed"
+)
+
+var (
+	// errProcessGone is returned by processStartTime for processes that no
+	// longer exist or have exited and await reaping.
+	errProcessGone = errors.New("process is gone")
+	// errProcessStartUnknown is returned where the platform does not expose
+	// process start times.
+	errProcessStartUnknown = errors.New("process start time is not available on this platform")
+)
+
+// agentRecord is one spawned app-server child. StartTime is the OS start
+// time of the process, so a recycled PID is never mistaken for it.
+type agentRecord struct {
+	PID       int    `json:"pid"`
+	StartTime string `json:"startTime,omitempty"`
+	SessionID int    `json:"sessionId"`
+	Command   string `json:"command"`
+	StartedAt int64  `json:"startedAt"`
+}
+
+// agentRegistryFile is <data-dir>/agents.json. The owner fields identify the
+// darkhold process that spawned the agents.
+type agentRegistryFile struct {
+	OwnerPID       int           `json:"ownerPid"`
+	OwnerStartTime string        `json:"ownerStartTime,omitempty"`
+	Agents         []agentRecord `json:"agents"`
+}
+
+// orphanReport is what startup recovery did with one agent left behind by a
+// previous darkhold process.
+type orphanReport struct {
+	agentRecord
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+// agentPIDs records live app-server children in <data-dir>/agents.json so a
+// darkhold that crashed can clean up after itself on the next start. The
+// agents speak JSON-RPC over stdio pipes that died with their parent, so an
+// orphan cannot be adopted: recovery terminates it.
+type agentPIDs struct {
+	path string
+
+	mu          sync.Mutex
+	file        agentRegistryFile
+	live        map[int]agentRecord
+	previous    agentRegistryFile
+	recovering  bool
+	recoveredAt time.Time
+	orphans     []orphanReport
+}
+
+// openAgentPIDs loads the previous process's registry and claims the file
+// for this one. It returns nil without a data dir.
+func openAgentPIDs(dataDir string) *agentPIDs {
+	if dataDir == "" {
+		return nil
+	}
+	a := &agentPIDs{path: filepath.Join(dataDir, "agents.json"), live: map[int]agentRecord{}}
+	if data, err := os.ReadFile(a.path); err == nil {
+		if err := json.Unmarshal(data, &a.previous); err != nil {
+			log.Printf("[orphans] ignoring unreadable %s: %v", a.path, err)
+		}
+	}
+	a.recovering = len(a.previous.Agents) > 0
+	a.file.OwnerPID = os.Getpid()
+	a.file.OwnerStartTime, _ = processStartTime(a.file.OwnerPID)
+	a.mu.Lock()
+	a.saveLocked()
+	a.mu.Unlock()
+	return a
+}
+
+// record adds a spawned agent. Pseudo-PIDs of the in-process fake agent are
+// not recorded.
+func (a *agentPIDs) record(sessionID, pid int, command string) {
+	if a == nil || pid <= 0 {
+		return
+	}
+	startTime, _ := processStartTime(pid)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.live[pid] = agentRecord{PID: pid, StartTime: startTime, SessionID: sessionID, Command: command, StartedAt: time.Now().UnixMilli()}
+	a.saveLocked()
+}
+
+// forget drops an agent once it has exited.
+func (a *agentPIDs) forget(pid int) {
+	if a == nil || pid <= 0 {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.live[pid]; !ok {
+		return
+	}
+	delete(a.live, pid)
+	a.saveLocked()
+}
+
+func (a *agentPIDs) saveLocked() {
+	a.file.Agents = make([]agentRecord, 0, len(a.live))
+	for _, record := range a.live {
+		a.file.Agents = append(a.file.Agents, record)
+	}
+	sort.Slice(a.file.Agents, func(i, j int) bool { return a.file.Agents[i].SessionID < a.file.Agents[j].SessionID })
+	data, _ := json.MarshalIndent(a.file, "", "  ")
+	tmp := a.path + ".tmp"
+	err := os.WriteFile(tmp, data, 0o644)
+	if err == nil {
+		err = os.Rename(tmp, a.path)
+	}
+	if err != nil {
+		log.Printf("[orphans] failed to write %s: %v", a.path, err)
+	}
+}
+
+// recoverOrphans terminates the agents the previous darkhold process left behind.
+// Agents are left alone when their PID now belongs to another process, when
+// that cannot be checked, or when the previous owner is still running (two
+// servers sharing a data dir).
+func (a *agentPIDs) recoverOrphans() {
+	if a == nil || !a.recovering {
+		return
+	}
+	previous := a.previous
+	ownerAlive := previous.OwnerPID > 0 && previous.OwnerPID != os.Getpid() && sameProcess(previous.OwnerPID, previous.OwnerStartTime)
+
+	var wg sync.WaitGroup
+	reports := make([]orphanReport, len(previous.Agents))
+	for i, record := range previous.Agents {
+		reports[i] = orphanReport{agentRecord: record}
+		if ownerAlive {
+			reports[i].Action = orphanOwnerAlive
+			continue
+		}
+		wg.Add(1)
+		go func(report *orphanReport) {
+			defer wg.Done()
+			report.Action, report.Error = terminateOrphan(report.agentRecord)
+		}(&reports[i])
+	}
+	wg.Wait()
+
+	for _, report := range reports {
+		if report.Error != "" {
+			log.Printf("[orphans] agent pid %d (session %d, %q): %s: %s", report.PID, report.SessionID, report.Command, report.Action, report.Error)
+			continue
+		}
+		log.Printf("[orphans] agent pid %d (session %d, %q): %s", report.PID, report.SessionID, report.Command, report.Action)
+	}
+
+	a.mu.Lock()
+	a.orphans = reports
+	a.recoveredAt = time.Now()
+	a.recovering = false
+	a.mu.Unlock()
+}
+
+// terminateOrphan asks an orphaned agent to exit (SIGTERM on Unix), then
+// kills it if it is still running after orphanTermGrace. It returns the
+// action taken.
+func terminateOrphan(record agentRecord) (string, string) {
+	current, err := processStartTime(record.PID)
+	switch {
+	case errors.Is(err, errProcessGone):
+		return orphanExited, ""
+	case err != nil:
+		return orphanUnverified, err.Error()
+	case record.StartTime == "":
+		return orphanUnverified, "no start time was recorded for this agent"
+	case current != record.StartTime:
+		return orphanPIDReused, ""
+	}
+
+	proc, err := os.FindProcess(record.PID)
+	if err != nil {
+		return orphanFailed, err.Error()
+	}
+	if err := terminateProcess(proc); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return orphanFailed, err.Error()
+	}
+	deadline := time.Now().Add(orphanTermGrace)
+	for time.Now().Before(deadline) {
+		if !sameProcess(record.PID, record.StartTime) {
+			return orphanTerminated, ""
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err := proc.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return orphanFailed, err.Error()
+	}
+	return orphanKilled, ""
+}
+
+// sameProcess reports whether pid is still the process that started at
+// startTime. It is false wherever start times are unavailable.
+func sameProcess(pid int, startTime string) bool {
+	current, err := processStartTime(pid)
+	return err == nil && (startTime == "" || current == startTime)
+}
+
+type orphanStatus struct {
+	Recovering  bool           `json:"recovering"`
+	RecoveredAt int64          `json:"recoveredAt,omitempty"`
+	OwnerPID    int            `json:"previousOwnerPid,omitempty"`
+	Orphans     []orphanReport `json:"orphans"`
+}
+
+func (a *agentPIDs) status() orphanStatus {
+	status := orphanStatus{Orphans: []orphanReport{}}
+	if a == nil {
+		return status
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	status.Recovering = a.recovering
+	status.OwnerPID = a.previous.OwnerPID
+	if !a.recoveredAt.IsZero() {
+		status.RecoveredAt = a.recoveredAt.UnixMilli()
+	}
+	status.Orphans = append(status.Orphans, a.orphans...)
+	return status
+}
+
+func (s *Server) handleAdminOrphans(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.agentPIDs.status())
+}
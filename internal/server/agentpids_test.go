@@ -0,0 +1,102 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+	"darkhold-go/internal/events"
+)
+
+func TestAgentPIDsRecordAndForget(t *testing.T) {
+	dataDir := t.TempDir()
+	a := openAgentPIDs(dataDir)
+	a.record(1, os.Getpid(), "codex app-server")
+	a.record(2, -3, config.FakeAgentCmd)
+
+	var file agentRegistryFile
+	data, err := os.ReadFile(filepath.Join(dataDir, "agents.json"))
+	if err != nil || json.Unmarshal(data, &file) != nil {
+		t.Fatalf("failed to read registry: %v", err)
+	}
+	if file.OwnerPID != os.Getpid() || len(file.Agents) != 1 || file.Agents[0].SessionID != 1 {
+		t.Fatalf("unexpected registry %+v", file)
+	}
+
+	a.forget(os.Getpid())
+	if reopened := openAgentPIDs(dataDir); reopened.recovering {
+		t.Fatal("expected nothing to recover once every agent was forgotten")
+	}
+}
+
+func TestRecoverOrphanedAgents(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("process start times are only read on linux")
+	}
+	orphan := exec.Command("sleep", "60")
+	if err := orphan.Start(); err != nil {
+		t.Skipf("cannot start sleep: %v", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		_ = orphan.Wait()
+		close(exited)
+	}()
+	defer func() { _ = orphan.Process.Kill() }()
+
+	gone := exec.Command("true")
+	if err := gone.Run(); err != nil {
+		t.Skipf("cannot run true: %v", err)
+	}
+	orphanStart, err := processStartTime(orphan.Process.Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataDir := t.TempDir()
+	previous, _ := json.Marshal(agentRegistryFile{
+		OwnerPID:       gone.Process.Pid,
+		OwnerStartTime: "1",
+		Agents: []agentRecord{
+			{PID: orphan.Process.Pid, StartTime: orphanStart, SessionID: 1, Command: "sleep 60"},
+			{PID: os.Getpid(), StartTime: "1", SessionID: 2, Command: "codex app-server"},
+			{PID: gone.Process.Pid, StartTime: "1", SessionID: 3, Command: "codex app-server"},
+		},
+	})
+	if err := os.WriteFile(filepath.Join(dataDir, "agents.json"), previous, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(config.Config{DataDir: dataDir, AgentCmd: config.FakeAgentCmd}, events.NewMemory())
+	defer func() { _ = s.Shutdown(t.Context()) }()
+
+	select {
+	case <-exited:
+	case <-time.After(orphanTermGrace + 2*time.Second):
+		t.Fatal("orphaned agent was not terminated")
+	}
+	waitForCondition(t, 5*time.Second, 10*time.Millisecond, func() bool {
+		return !s.agentPIDs.status().Recovering
+	})
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/admin/orphans", nil))
+	var status orphanStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+	actions := map[int]string{}
+	for _, report := range status.Orphans {
+		actions[report.SessionID] = report.Action
+	}
+	if actions[1] != orphanTerminated || actions[2] != orphanPIDReused || actions[3] != orphanExited || status.RecoveredAt == 0 {
+		t.Fatalf("unexpected recovery status %+v", status)
+	}
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"darkhold-go/internal/config"
+	"darkhold-go/internal/fakeagent"
+)
+
+// agentProcess is a running app-server, either a child process or the
+// built-in fake agent.
+type agentProcess interface {
+	Pid() int
+	Interrupt() error
+	Kill() error
+	// Wait blocks until exit; it may be called from several goroutines.
+	Wait() error
+	// ExitCode is the exit status after Wait returns.
+	ExitCode() int
+}
+
+type agentPipes struct {
+	stdin  io.WriteCloser
+	stdout io.Reader
+	stderr io.Reader
+}
+
+// startAgent launches the configured agent command.
+func startAgent(cfg config.Config) (agentProcess, agentPipes, error) {
+	if cfg.AgentCmd == config.FakeAgentCmd {
+		p := fakeagent.Start(fakeagent.Options{
+			Latency:      cfg.FakeLatency,
+			CrashRate:    cfg.FakeCrashRate,
+			ApprovalRate: cfg.FakeApprovalRate,
+			HangFile:     os.Getenv(fakeagent.HangFileEnv),
+		})
+		return p, agentPipes{stdin: p.Stdin, stdout: p.Stdout, stderr: p.Stderr}, nil
+	}
+
+	args := strings.Fields(cfg.AgentCmd)
+	if len(args) == 0 {
+		args = strings.Fields(config.DefaultAgentCmd)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	prepareAgentCmd(cmd)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, agentPipes{}, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, agentPipes{}, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, agentPipes{}, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, agentPipes{}, err
+	}
+	job, err := attachAgentJob(cmd.Process)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, agentPipes{}, err
+	}
+	return newExecAgent(cmd, job), agentPipes{stdin: stdin, stdout: stdout, stderr: stderr}, nil
+}
+
+// execAgent adapts an *exec.Cmd. exec.Cmd.Wait must be called exactly once,
+// so it runs in one goroutine and every Wait caller shares its result. job
+// holds the platform's handle on the agent's process tree.
+type execAgent struct {
+	cmd  *exec.Cmd
+	job  agentJob
+	done chan struct{}
+
+	mu      sync.Mutex
+	waitErr error
+}
+
+func newExecAgent(cmd *exec.Cmd, job agentJob) *execAgent {
+	a := &execAgent{cmd: cmd, job: job, done: make(chan struct{})}
+	go func() {
+		err := cmd.Wait()
+		job.close()
+		a.mu.Lock()
+		a.waitErr = err
+		a.mu.Unlock()
+		close(a.done)
+	}()
+	return a
+}
+
+func (a *execAgent) Pid() int {
+	if a.cmd.Process == nil {
+		return 0
+	}
+	return a.cmd.Process.Pid
+}
+
+func (a *execAgent) Interrupt() error {
+	if a.cmd.Process == nil {
+		return errors.New("process not started")
+	}
+	return interruptAgent(a.cmd.Process, a.job)
+}
+
+func (a *execAgent) Kill() error {
+	if a.cmd.Process == nil {
+		return errors.New("process not started")
+	}
+	return a.job.kill(a.cmd.Process)
+}
+
+func (a *execAgent) Wait() error {
+	<-a.done
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.waitErr
+}
+
+func (a *execAgent) ExitCode() int {
+	select {
+	case <-a.done:
+	default:
+		return -1
+	}
+	if a.cmd.ProcessState == nil {
+		return -1
+	}
+	return a.cmd.ProcessState.ExitCode()
+}
@@ -0,0 +1,34 @@
+//go:build !windows
+
+package server
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// agentJob is a no-op on Unix: agents are signalled directly.
+type agentJob struct{}
+
+func prepareAgentCmd(cmd *exec.Cmd) {}
+
+func attachAgentJob(proc *os.Process) (agentJob, error) {
+	return agentJob{}, nil
+}
+
+func (agentJob) kill(proc *os.Process) error {
+	return proc.Kill()
+}
+
+func (agentJob) close() {}
+
+// interruptAgent asks an agent to shut down as if Ctrl-C was pressed.
+func interruptAgent(proc *os.Process, job agentJob) error {
+	return proc.Signal(os.Interrupt)
+}
+
+// terminateProcess asks a process that is not our child to exit.
+func terminateProcess(proc *os.Process) error {
+	return proc.Signal(syscall.SIGTERM)
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	processSetQuota  = 0x0100
+	processTerminate = 0x0001
+
+	ctrlBreakEvent = 1
+
+	jobObjectExtendedLimitInformationClass = 9
+	jobObjectLimitKillOnJobClose           = 0x2000
+)
+
+var (
+	kernel32                     = syscall.NewLazyDLL("kernel32.dll")
+	procCreateJobObjectW         = kernel32.NewProc("CreateJobObjectW")
+	procSetInformationJobObject  = kernel32.NewProc("SetInformationJobObject")
+	procAssignProcessToJobObject = kernel32.NewProc("AssignProcessToJobObject")
+	procTerminateJobObject       = kernel32.NewProc("TerminateJobObject")
+	procGenerateConsoleCtrlEvent = kernel32.NewProc("GenerateConsoleCtrlEvent")
+)
+
+type jobObjectBasicLimitInformation struct {
+	PerProcessUserTimeLimit int64
+	PerJobUserTimeLimit     int64
+	LimitFlags              uint32
+	MinimumWorkingSetSize   uintptr
+	MaximumWorkingSetSize   uintptr
+	ActiveProcessLimit      uint32
+	Affinity                uintptr
+	PriorityClass           uint32
+	SchedulingClass         uint32
+}
+
+type ioCounters struct {
+	ReadOperationCount  uint64
+	WriteOperationCount uint64
+	OtherOperationCount uint64
+	ReadTransferCount   uint64
+	WriteTransferCount  uint64
+	OtherTransferCount  uint64
+}
+
+type jobObjectExtendedLimitInformation struct {
+	BasicLimitInformation jobObjectBasicLimitInformation
+	IoInfo                ioCounters
+	ProcessMemoryLimit    uintptr
+	JobMemoryLimit        uintptr
+	PeakProcessMemoryUsed uintptr
+	PeakJobMemoryUsed     uintptr
+}
+
+// agentJob is a Job object holding an agent and every process it starts.
+// The job kills them all when its last handle closes, so agents cannot
+// outlive a darkhold that crashed or was stopped by the service manager.
+type agentJob struct {
+	handle syscall.Handle
+}
+
+// prepareAgentCmd starts agents in their own process group, so a Ctrl-C in
+// darkhold's console is not delivered to them twice and CTRL_BREAK can be
+// sent to one agent at a time.
+func prepareAgentCmd(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+func attachAgentJob(proc *os.Process) (agentJob, error) {
+	r, _, err := procCreateJobObjectW.Call(0, 0)
+	if r == 0 {
+		return agentJob{}, err
+	}
+	job := agentJob{handle: syscall.Handle(r)}
+	var info jobObjectExtendedLimitInformation
+	info.BasicLimitInformation.LimitFlags = jobObjectLimitKillOnJobClose
+	if r, _, err := procSetInformationJobObject.Call(uintptr(job.handle), jobObjectExtendedLimitInformationClass, uintptr(unsafe.Pointer(&info)), unsafe.Sizeof(info)); r == 0 {
+		job.close()
+		return agentJob{}, err
+	}
+	handle, err := syscall.OpenProcess(processSetQuota|processTerminate, false, uint32(proc.Pid))
+	if err != nil {
+		job.close()
+		return agentJob{}, err
+	}
+	defer syscall.CloseHandle(handle)
+	if r, _, err := procAssignProcessToJobObject.Call(uintptr(job.handle), uintptr(handle)); r == 0 {
+		job.close()
+		return agentJob{}, err
+	}
+	return job, nil
+}
+
+// kill terminates the agent's whole process tree.
+func (j agentJob) kill(proc *os.Process) error {
+	if j.handle == 0 {
+		return proc.Kill()
+	}
+	if r, _, err := procTerminateJobObject.Call(uintptr(j.handle), 1); r == 0 {
+		return err
+	}
+	return nil
+}
+
+func (j agentJob) close() {
+	if j.handle != 0 {
+		_ = syscall.CloseHandle(j.handle)
+	}
+}
+
+// interruptAgent sends CTRL_BREAK to the agent's process group, the closest
+// Windows has to SIGINT. Without a console (running as a service) there is
+// no way to ask politely, so the agent's tree is terminated instead.
+func interruptAgent(proc *os.Process, job agentJob) error {
+	if r, _, _ := procGenerateConsoleCtrlEvent.Call(ctrlBreakEvent, uintptr(proc.Pid)); r != 0 {
+		return nil
+	}
+	return job.kill(proc)
+}
+
+// terminateProcess kills a process that is not our child. Windows has no
+// signal a console-less process could catch.
+func terminateProcess(proc *os.Process) error {
+	return proc.Kill()
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"maps"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+var errProcessUsageUnsupported = errors.New("process resource usage is not available on this platform")
+
+const (
+	alertAgentResources = "agentResources"
+
+	agentLimitRSS = "rss"
+	agentLimitCPU = "cpu"
+)
+
+// agentUsage is the latest resource sample of a session's agent process and
+// everything it started: the npm codex package, for one, runs the real
+// binary as a child of a node launcher.
+type agentUsage struct {
+	CPUSeconds float64 `json:"cpuSeconds"`
+	// CPUPercent is the share of one core used since the previous sample.
+	CPUPercent float64 `json:"cpuPercent"`
+	RSSBytes   int64   `json:"rssBytes"`
+	Processes  int     `json:"processes"`
+	SampledAt  int64   `json:"sampledAt"`
+}
+
+// agentResourceKills counts agents killed by --max-agent-rss and
+// --max-agent-cpu since the server started.
+type agentResourceKills struct {
+	rss atomic.Int64
+	cpu atomic.Int64
+}
+
+// agentUsageSampler samples every live session each --agent-sample-interval
+// until shutdown.
+func (s *Server) agentUsageSampler() {
+	for {
+		select {
+		case <-s.reaperStop:
+			return
+		case <-time.After(s.cfg.AgentSampleInterval):
+		}
+		s.sampleAgentUsage(time.Now())
+	}
+}
+
+// sampleAgentUsage records the resources of each live session's process tree
+// and kills the ones over a limit.
+func (s *Server) sampleAgentUsage(now time.Time) {
+	s.sessionsMu.RLock()
+	sessions := make([]*session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.sessionsMu.RUnlock()
+
+	parents, err := listProcesses()
+	if err != nil {
+		if !errors.Is(err, errProcessUsageUnsupported) {
+			log.Printf("[usage] failed to list processes: %v", err)
+		}
+		return
+	}
+	for _, sess := range sessions {
+		if sess.proc == nil {
+			continue
+		}
+		pid := sess.proc.Pid()
+		if _, running := parents[pid]; pid <= 0 || !running {
+			continue
+		}
+		var cpu time.Duration
+		var rss int64
+		count := 0
+		for _, member := range processTree(parents, pid) {
+			memberCPU, memberRSS, err := processUsage(member)
+			if err != nil {
+				continue
+			}
+			cpu += memberCPU
+			rss += memberRSS
+			count++
+		}
+		if count == 0 {
+			continue
+		}
+		if usage, limit := s.recordAgentUsage(sess, now, cpu, rss, count); limit != "" {
+			s.killAgentForResources(sess, limit, usage)
+		}
+	}
+}
+
+// processTree returns pid and its descendants, given every process's parent.
+func processTree(parents map[int]int, pid int) []int {
+	children := map[int][]int{}
+	for child, parent := range parents {
+		if child != parent {
+			children[parent] = append(children[parent], child)
+		}
+	}
+	tree := []int{pid}
+	seen := map[int]bool{pid: true}
+	for i := 0; i < len(tree); i++ {
+		for _, child := range children[tree[i]] {
+			if !seen[child] {
+				seen[child] = true
+				tree = append(tree, child)
+			}
+		}
+	}
+	return tree
+}
+
+// recordAgentUsage stores a sample on sess and returns the limit it broke,
+// if any. CPU counts as over once every sample for --max-agent-cpu-for was.
+func (s *Server) recordAgentUsage(sess *session, now time.Time, cpu time.Duration, rss int64, processes int) (agentUsage, string) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	usage := agentUsage{CPUSeconds: cpu.Seconds(), RSSBytes: rss, Processes: processes, SampledAt: now.UnixMilli()}
+	previousAt := sess.usageAt
+	if !previousAt.IsZero() && now.After(previousAt) && cpu >= sess.usageCPU {
+		usage.CPUPercent = 100 * float64(cpu-sess.usageCPU) / float64(now.Sub(previousAt))
+	}
+	sess.usage, sess.usageCPU, sess.usageAt = &usage, cpu, now
+	if sess.closed || sess.unhealthy || sess.stopRequested {
+		return usage, ""
+	}
+	if limit := s.cfg.MaxAgentRSSBytes; limit > 0 && rss > limit {
+		return usage, agentLimitRSS
+	}
+	if limit := s.cfg.MaxAgentCPUPercent; limit > 0 && !previousAt.IsZero() && usage.CPUPercent > limit {
+		if sess.cpuOverSince.IsZero() {
+			sess.cpuOverSince = previousAt
+		}
+		if now.Sub(sess.cpuOverSince) >= s.cfg.MaxAgentCPUFor {
+			return usage, agentLimitCPU
+		}
+		return usage, ""
+	}
+	sess.cpuOverSince = time.Time{}
+	return usage, ""
+}
+
+// killAgentForResources raises a darkhold/alert on each of the session's
+// threads and fails the session over, like one that stopped answering.
+func (s *Server) killAgentForResources(sess *session, limit string, usage agentUsage) {
+	sess.mu.Lock()
+	sess.killedFor = limit
+	threadIDs := make([]string, 0, len(sess.knownThreadIDs))
+	for threadID := range sess.knownThreadIDs {
+		threadIDs = append(threadIDs, threadID)
+	}
+	sess.mu.Unlock()
+	sort.Strings(threadIDs)
+
+	alert := map[string]any{"alert": alertAgentResources, "limit": limit, "sessionId": sess.id, "pid": sess.proc.Pid(),
+		"rssBytes": usage.RSSBytes, "cpuPercent": usage.CPUPercent}
+	if limit == agentLimitRSS {
+		s.agentKills.rss.Add(1)
+		alert["thresholdBytes"] = s.cfg.MaxAgentRSSBytes
+		log.Printf("[session=%d] agent uses %d bytes of memory, over --max-agent-rss %d; killing", sess.id, usage.RSSBytes, s.cfg.MaxAgentRSSBytes)
+	} else {
+		s.agentKills.cpu.Add(1)
+		alert["thresholdPercent"] = s.cfg.MaxAgentCPUPercent
+		alert["thresholdMs"] = s.cfg.MaxAgentCPUFor.Milliseconds()
+		log.Printf("[session=%d] agent used over %.0f%% CPU for %s, over --max-agent-cpu; killing", sess.id, s.cfg.MaxAgentCPUPercent, s.cfg.MaxAgentCPUFor)
+	}
+	for _, threadID := range threadIDs {
+		params := map[string]any{"threadId": threadID}
+		maps.Copy(params, alert)
+		encoded, _ := json.Marshal(map[string]any{"method": "darkhold/alert", "params": params})
+		s.publishThreadEvent(threadID, string(encoded))
+	}
+	s.failoverSession(sess, limit)
+}
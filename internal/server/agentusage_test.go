@@ -0,0 +1,89 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestAgentUsageSamplingAndRSSLimit(t *testing.T) {
+	if _, err := listProcesses(); errors.Is(err, errProcessUsageUnsupported) {
+		t.Skip(err)
+	}
+	s := startIntegrationServer(t, withFakeAgentProcess(t))
+	defer s.close()
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+
+	s.app.sampleAgentUsage(time.Now())
+	_, listed := doJSON(t, http.MethodGet, s.http.URL+"/api/admin/sessions", nil)
+	info := listed["sessions"].([]any)[0].(map[string]any)
+	usage, _ := info["usage"].(map[string]any)
+	if usage == nil || usage["rssBytes"].(float64) <= 0 || usage["processes"].(float64) < 1 {
+		t.Fatalf("expected a usage sample, got %v", info)
+	}
+
+	resp, err := http.Get(s.http.URL + "/api/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(metrics), "# TYPE darkhold_agent_resident_memory_bytes gauge\ndarkhold_agent_resident_memory_bytes{session=\"1\"") ||
+		!strings.Contains(string(metrics), "darkhold_agent_sessions 1\n") {
+		t.Fatalf("unexpected metrics:\n%s", metrics)
+	}
+
+	s.app.cfg.MaxAgentRSSBytes = 1
+	s.app.sampleAgentUsage(time.Now())
+	alerts := alertEvents(t, s, threadID)
+	if len(alerts) != 1 || alerts[0]["alert"] != alertAgentResources || alerts[0]["limit"] != agentLimitRSS || alerts[0]["thresholdBytes"] != float64(1) {
+		t.Fatalf("expected an rss alert, got %v", alerts)
+	}
+	waitForCondition(t, 5*time.Second, 20*time.Millisecond, func() bool {
+		_, listed := doJSON(t, http.MethodGet, s.http.URL+"/api/admin/sessions", nil)
+		for _, raw := range listed["sessions"].([]any) {
+			if info := raw.(map[string]any); info["killedFor"] == agentLimitRSS && info["alive"] == false {
+				return true
+			}
+		}
+		return false
+	})
+	if s.app.agentKills.rss.Load() != 1 {
+		t.Fatalf("expected one kill to be counted, got %d", s.app.agentKills.rss.Load())
+	}
+}
+
+func TestAgentCPULimitNeedsSustainedUse(t *testing.T) {
+	s := &Server{cfg: config.Config{MaxAgentCPUPercent: 150, MaxAgentCPUFor: time.Minute}}
+	sess := &session{id: 1}
+	start := time.Now()
+	sample := func(offset time.Duration, cpu time.Duration) string {
+		_, limit := s.recordAgentUsage(sess, start.Add(offset), cpu, 1<<20, 1)
+		return limit
+	}
+	// 200% of a core for 30s, a quiet 30s, then 200% for a full minute.
+	steps := []struct {
+		offset, cpu time.Duration
+		want        string
+	}{
+		{0, 0, ""},
+		{30 * time.Second, 60 * time.Second, ""},
+		{60 * time.Second, 61 * time.Second, ""},
+		{90 * time.Second, 121 * time.Second, ""},
+		{120 * time.Second, 181 * time.Second, agentLimitCPU},
+	}
+	for i, step := range steps {
+		if got := sample(step.offset, step.cpu); got != step.want {
+			t.Fatalf("step %d: expected limit %q, got %q (usage %+v)", i, step.want, got, sess.usage)
+		}
+	}
+	if sess.usage.CPUPercent != 200 {
+		t.Fatalf("expected 200%% CPU, got %v", sess.usage.CPUPercent)
+	}
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+const (
+	alertTurnDuration    = "turnDuration"
+	alertApprovalPending = "approvalPending"
+)
+
+// alertTurn is a running turn watched for --alert-turn-duration.
+type alertTurn struct {
+	turnID    string
+	startedAt time.Time
+	alerted   bool
+}
+
+// alertCheckInterval is how often thresholds are checked: a quarter of the
+// shortest one, between 1s and 30s.
+func alertCheckInterval(thresholds ...time.Duration) time.Duration {
+	interval := 30 * time.Second
+	for _, threshold := range thresholds {
+		if threshold > 0 && threshold/4 < interval {
+			interval = threshold / 4
+		}
+	}
+	return max(interval, time.Second)
+}
+
+// alertWatcher checks the alert thresholds until shutdown.
+func (s *Server) alertWatcher() {
+	interval := alertCheckInterval(s.cfg.AlertTurnDuration, s.cfg.AlertApprovalPending)
+	for {
+		select {
+		case <-s.reaperStop:
+			return
+		case <-time.After(interval):
+		}
+		s.checkAlerts(time.Now())
+	}
+}
+
+// trackAlertTurn starts timing a turn when turn/started arrives.
+func (s *Server) trackAlertTurn(threadID string, params map[string]any) {
+	if s.cfg.AlertTurnDuration <= 0 {
+		return
+	}
+	turnID, _ := params["turnId"].(string)
+	if turn, ok := params["turn"].(map[string]any); ok && turnID == "" {
+		turnID, _ = turn["id"].(string)
+	}
+	s.alertsMu.Lock()
+	defer s.alertsMu.Unlock()
+	if s.alertTurns == nil {
+		s.alertTurns = map[string]*alertTurn{}
+	}
+	s.alertTurns[threadID] = &alertTurn{turnID: turnID, startedAt: time.Now()}
+}
+
+// forgetAlertTurn stops timing the thread's turn once it ends.
+func (s *Server) forgetAlertTurn(threadID string) {
+	s.alertsMu.Lock()
+	delete(s.alertTurns, threadID)
+	s.alertsMu.Unlock()
+}
+
+// checkAlerts raises an alert, once each, for turns running longer than
+// --alert-turn-duration and approvals pending longer than
+// --alert-approval-pending at now.
+func (s *Server) checkAlerts(now time.Time) {
+	var raised []map[string]any
+
+	if threshold := s.cfg.AlertTurnDuration; threshold > 0 {
+		s.alertsMu.Lock()
+		for threadID, turn := range s.alertTurns {
+			if turn.alerted || now.Sub(turn.startedAt) < threshold {
+				continue
+			}
+			turn.alerted = true
+			alert := map[string]any{"threadId": threadID, "alert": alertTurnDuration, "startedAt": turn.startedAt.UnixMilli(),
+				"elapsedMs": now.Sub(turn.startedAt).Milliseconds(), "thresholdMs": threshold.Milliseconds()}
+			if turn.turnID != "" {
+				alert["turnId"] = turn.turnID
+			}
+			raised = append(raised, alert)
+		}
+		s.alertsMu.Unlock()
+	}
+
+	if threshold := s.cfg.AlertApprovalPending; threshold > 0 {
+		s.alertsMu.Lock()
+		s.sessionsMu.RLock()
+		for key := range s.alertedInteractions {
+			if _, pending := s.pendingResponses[key.threadID][key.requestID]; !pending {
+				delete(s.alertedInteractions, key)
+			}
+		}
+		for threadID, threadPending := range s.pendingResponses {
+			for requestID, pending := range threadPending {
+				key := alertInteractionKey{threadID: threadID, requestID: requestID}
+				if reverseRequestHandlerFor(pending.method).kind != reverseKindApproval || pending.receivedAt.IsZero() ||
+					now.Sub(pending.receivedAt) < threshold || s.alertedInteractions[key] {
+					continue
+				}
+				if s.alertedInteractions == nil {
+					s.alertedInteractions = map[alertInteractionKey]bool{}
+				}
+				s.alertedInteractions[key] = true
+				raised = append(raised, map[string]any{"threadId": threadID, "alert": alertApprovalPending, "requestId": requestID,
+					"method": pending.method, "startedAt": pending.receivedAt.UnixMilli(),
+					"elapsedMs": now.Sub(pending.receivedAt).Milliseconds(), "thresholdMs": threshold.Milliseconds()})
+			}
+		}
+		s.sessionsMu.RUnlock()
+		s.alertsMu.Unlock()
+	}
+
+	for _, alert := range raised {
+		s.raiseAlert(alert)
+	}
+}
+
+type alertInteractionKey struct {
+	threadID  string
+	requestID string
+}
+
+// raiseAlert logs the alert and publishes it to the thread as darkhold/alert,
+// which reaches SSE clients and webhook and MQTT subscribers like any event.
+func (s *Server) raiseAlert(params map[string]any) {
+	threadID, _ := params["threadId"].(string)
+	elapsed := time.Duration(params["elapsedMs"].(int64)) * time.Millisecond
+	if params["alert"] == alertTurnDuration {
+		log.Printf("[alert] thread %s: turn %v has been running for %s", threadID, params["turnId"], elapsed.Round(time.Second))
+	} else {
+		log.Printf("[alert] thread %s: %v %v has been pending for %s", threadID, params["method"], params["requestId"], elapsed.Round(time.Second))
+	}
+	encoded, _ := json.Marshal(map[string]any{"method": "darkhold/alert", "params": params})
+	s.publishThreadEvent(threadID, string(encoded))
+}
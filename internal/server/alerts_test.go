@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func alertEvents(t *testing.T, s *integrationServer, threadID string) []map[string]any {
+	t.Helper()
+	records, err := s.app.eventStore.ReadRange(threadID, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var alerts []map[string]any
+	for _, record := range records {
+		var event struct {
+			Method string         `json:"method"`
+			Params map[string]any `json:"params"`
+		}
+		if json.Unmarshal([]byte(record.Payload), &event) == nil && event.Method == "darkhold/alert" {
+			alerts = append(alerts, event.Params)
+		}
+	}
+	return alerts
+}
+
+func TestAlertsForSlowTurnsAndPendingApprovals(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.AlertTurnDuration = time.Minute
+		cfg.AlertApprovalPending = 5 * time.Minute
+	})
+	defer s.close()
+
+	s.app.observeThreadEvent("t-slow", "turn/started", map[string]any{"threadId": "t-slow", "turn": map[string]any{"id": "turn-1"}})
+	s.app.checkAlerts(time.Now().Add(30 * time.Second))
+	if alerts := alertEvents(t, s, "t-slow"); len(alerts) != 0 {
+		t.Fatalf("expected no alert before the threshold, got %v", alerts)
+	}
+	s.app.checkAlerts(time.Now().Add(2 * time.Minute))
+	s.app.checkAlerts(time.Now().Add(3 * time.Minute))
+	alerts := alertEvents(t, s, "t-slow")
+	if len(alerts) != 1 || alerts[0]["alert"] != alertTurnDuration || alerts[0]["turnId"] != "turn-1" || alerts[0]["thresholdMs"] != float64(60000) {
+		t.Fatalf("expected one turn alert, got %v", alerts)
+	}
+	s.app.observeThreadEvent("t-slow", "turn/completed", map[string]any{"threadId": "t-slow"})
+	if len(s.app.alertTurns) != 0 {
+		t.Fatalf("expected the finished turn to be forgotten, got %v", s.app.alertTurns)
+	}
+
+	s.app.sessionsMu.Lock()
+	s.app.pendingResponses["t-wait"] = map[string]pendingInteraction{
+		"7": {method: "item/commandExecution/requestApproval", receivedAt: time.Now().Add(-10 * time.Minute)},
+		"8": {method: "item/commandExecution/requestApproval", receivedAt: time.Now()},
+		"9": {method: "item/tool/requestUserInput", receivedAt: time.Now().Add(-10 * time.Minute)},
+	}
+	s.app.sessionsMu.Unlock()
+	s.app.checkAlerts(time.Now())
+	s.app.checkAlerts(time.Now())
+	alerts = alertEvents(t, s, "t-wait")
+	if len(alerts) != 1 || alerts[0]["alert"] != alertApprovalPending || alerts[0]["requestId"] != "7" {
+		t.Fatalf("expected one alert for the old approval only, got %v", alerts)
+	}
+
+	s.app.sessionsMu.Lock()
+	delete(s.app.pendingResponses, "t-wait")
+	s.app.sessionsMu.Unlock()
+	s.app.checkAlerts(time.Now())
+	if len(s.app.alertedInteractions) != 0 {
+		t.Fatalf("expected answered approvals to be forgotten, got %v", s.app.alertedInteractions)
+	}
+}
+
+func TestAlertCheckInterval(t *testing.T) {
+	for _, tc := range []struct {
+		thresholds []time.Duration
+		want       time.Duration
+	}{
+		{[]time.Duration{time.Hour, 0}, 30 * time.Second},
+		{[]time.Duration{time.Minute, 10 * time.Minute}, 15 * time.Second},
+		{[]time.Duration{0, 2 * time.Second}, time.Second},
+	} {
+		if got := alertCheckInterval(tc.thresholds...); got != tc.want {
+			t.Errorf("alertCheckInterval(%v) = %s, want %s", tc.thresholds, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"darkhold-go/internal/archive"
+	"darkhold-go/internal/config"
+	"darkhold-go/internal/events"
+)
+
+const (
+	errCodeArchiveDisabled = "ARCHIVE_DISABLED"
+	errCodeArchiveNotFound = "ARCHIVE_NOT_FOUND"
+	errCodeArchiveConflict = "ARCHIVE_CONFLICT"
+	errCodeArchiveFailed   = "ARCHIVE_FAILED"
+)
+
+// archiver uploads thread logs to S3-compatible storage and remembers the
+// log modification time each thread was last uploaded at.
+type archiver struct {
+	client *archive.S3
+	prefix string
+
+	mu        sync.Mutex
+	uploaded  map[string]time.Time
+	lastRunAt time.Time
+	failures  int64
+	lastError string
+}
+
+type archiveStatus struct {
+	Enabled   bool             `json:"enabled"`
+	Bucket    string           `json:"bucket,omitempty"`
+	Prefix    string           `json:"prefix,omitempty"`
+	Interval  string           `json:"interval,omitempty"`
+	LastRunAt int64            `json:"lastRunAt,omitempty"`
+	Failures  int64            `json:"failures"`
+	LastError string           `json:"lastError,omitempty"`
+	Uploaded  map[string]int64 `json:"uploaded"`
+}
+
+// archiveUpload describes one thread's uploaded objects.
+type archiveUpload struct {
+	ThreadID   string   `json:"threadId"`
+	Keys       []string `json:"keys"`
+	UploadedAt int64    `json:"uploadedAt"`
+}
+
+func openArchiver(cfg config.Config) *archiver {
+	if cfg.S3Bucket == "" {
+		return nil
+	}
+	client, err := archive.NewS3(archive.S3Config{
+		Endpoint:  cfg.S3Endpoint,
+		Region:    cfg.S3Region,
+		Bucket:    cfg.S3Bucket,
+		AccessKey: cfg.S3AccessKey,
+		SecretKey: cfg.S3SecretKey,
+	}, nil)
+	if err != nil {
+		log.Printf("[archive] disabled: %v", err)
+		return nil
+	}
+	return &archiver{client: client, prefix: cfg.S3Prefix, uploaded: map[string]time.Time{}}
+}
+
+func (a *archiver) key(threadID, name string) string {
+	return a.prefix + threadID + "/" + name
+}
+
+// uploadArchive uploads a thread's raw event log, a markdown transcript and
+// its darkhold metadata.
+func (s *Server) uploadArchive(ctx context.Context, threadID string) (archiveUpload, error) {
+	a := s.archiver
+	startedAt := time.Now()
+	raw, err := s.eventStore.ExportLog(threadID)
+	if err != nil {
+		return archiveUpload{}, err
+	}
+	records, err := s.eventStore.ReadRange(threadID, "", 0)
+	if err != nil {
+		return archiveUpload{}, err
+	}
+	meta, _ := s.threadIndex.Get(threadID)
+	metaJSON, _ := json.MarshalIndent(meta, "", "  ")
+
+	objects := []struct {
+		name        string
+		body        []byte
+		contentType string
+	}{
+		{"events.jsonl", raw, "application/x-ndjson"},
+		{"transcript.md", []byte(archive.Transcript(meta.Title, events.Payloads(records))), "text/markdown; charset=utf-8"},
+		{"metadata.json", metaJSON, "application/json"},
+	}
+	upload := archiveUpload{ThreadID: threadID, Keys: make([]string, 0, len(objects))}
+	for _, object := range objects {
+		key := a.key(threadID, object.name)
+		if err := a.client.Put(ctx, key, object.body, object.contentType); err != nil {
+			a.mu.Lock()
+			a.failures++
+			a.lastError = err.Error()
+			a.mu.Unlock()
+			return archiveUpload{}, err
+		}
+		upload.Keys = append(upload.Keys, key)
+	}
+	upload.UploadedAt = time.Now().UnixMilli()
+	a.mu.Lock()
+	a.uploaded[threadID] = startedAt
+	a.mu.Unlock()
+	return upload, nil
+}
+
+// archiveChangedThreads uploads every thread whose log changed since its last
+// upload.
+func (s *Server) archiveChangedThreads(ctx context.Context) {
+	logs, err := s.eventStore.Logs()
+	if err != nil {
+		log.Printf("[archive] failed to list event logs: %v", err)
+		return
+	}
+	byKey := s.metadataByLogKey()
+	for _, l := range logs {
+		threadID := l.Key
+		if meta, ok := byKey[l.Key]; ok {
+			threadID = meta.ThreadID
+		}
+		s.archiver.mu.Lock()
+		last, seen := s.archiver.uploaded[threadID]
+		s.archiver.mu.Unlock()
+		if seen && !l.ModTime.After(last) {
+			continue
+		}
+		if _, err := s.uploadArchive(ctx, threadID); err != nil {
+			log.Printf("[archive] failed to upload thread %s: %v", threadID, err)
+		}
+	}
+	s.archiver.mu.Lock()
+	s.archiver.lastRunAt = time.Now()
+	s.archiver.mu.Unlock()
+}
+
+func (s *Server) archiveScheduler() {
+	for {
+		select {
+		case <-s.reaperStop:
+			return
+		case <-time.After(s.cfg.ArchiveInterval):
+		}
+		s.archiveChangedThreads(context.Background())
+	}
+}
+
+// restoreArchive downloads a thread's event log back into the local store.
+func (s *Server) restoreArchive(ctx context.Context, threadID string, overwrite bool) error {
+	raw, err := s.archiver.client.Get(ctx, s.archiver.key(threadID, "events.jsonl"))
+	if err != nil {
+		return err
+	}
+	if err := s.eventStore.ImportLog(threadID, raw, overwrite); err != nil {
+		return err
+	}
+	s.archiver.mu.Lock()
+	s.archiver.uploaded[threadID] = time.Now()
+	s.archiver.mu.Unlock()
+	return nil
+}
+
+func (s *Server) handleArchive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if s.archiver == nil {
+		writeJSON(w, http.StatusOK, archiveStatus{Uploaded: map[string]int64{}})
+		return
+	}
+	a := s.archiver
+	a.mu.Lock()
+	status := archiveStatus{
+		Enabled:   true,
+		Bucket:    a.client.Bucket(),
+		Prefix:    a.prefix,
+		Failures:  a.failures,
+		LastError: a.lastError,
+		Uploaded:  make(map[string]int64, len(a.uploaded)),
+	}
+	if !a.lastRunAt.IsZero() {
+		status.LastRunAt = a.lastRunAt.UnixMilli()
+	}
+	for threadID, at := range a.uploaded {
+		status.Uploaded[threadID] = at.UnixMilli()
+	}
+	a.mu.Unlock()
+	if s.cfg.ArchiveInterval > 0 {
+		status.Interval = s.cfg.ArchiveInterval.String()
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) decodeArchiveRequest(w http.ResponseWriter, r *http.Request, request any) bool {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return false
+	}
+	if s.archiver == nil {
+		writeError(w, http.StatusServiceUnavailable, errCodeArchiveDisabled, "archiving is not configured; set --s3-bucket.")
+		return false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleArchiveUpload(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ThreadID string `json:"threadId"`
+	}
+	if !s.decodeArchiveRequest(w, r, &request) {
+		return
+	}
+	threadID := strings.TrimSpace(request.ThreadID)
+	if threadID == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+		return
+	}
+	upload, err := s.uploadArchive(r.Context(), threadID)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, errCodeArchiveFailed, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, upload)
+}
+
+func (s *Server) handleArchiveRestore(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ThreadID  string `json:"threadId"`
+		Overwrite bool   `json:"overwrite"`
+	}
+	if !s.decodeArchiveRequest(w, r, &request) {
+		return
+	}
+	threadID := strings.TrimSpace(request.ThreadID)
+	if threadID == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+		return
+	}
+	switch err := s.restoreArchive(r.Context(), threadID, request.Overwrite); {
+	case errors.Is(err, archive.ErrNotFound):
+		writeError(w, http.StatusNotFound, errCodeArchiveNotFound, "no archive found for thread.")
+		return
+	case errors.Is(err, events.ErrLogExists):
+		writeError(w, http.StatusConflict, errCodeArchiveConflict, "thread already has a local event log; pass overwrite to replace it.")
+		return
+	case err != nil:
+		writeError(w, http.StatusBadGateway, errCodeArchiveFailed, err.Error())
+		return
+	}
+	s.rpcCache.invalidateThread(threadID, true)
+	records, _ := s.eventStore.ReadRange(threadID, "", 0)
+	writeJSON(w, http.StatusOK, map[string]any{"threadId": threadID, "events": len(records)})
+}
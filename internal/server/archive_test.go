@@ -0,0 +1,106 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"darkhold-go/internal/config"
+)
+
+// fakeS3 stores objects in memory, keyed by request path.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(body)
+	}
+}
+
+func (f *fakeS3) object(path string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return string(f.objects[path])
+}
+
+func TestArchiveUploadAndRestore(t *testing.T) {
+	bucket := &fakeS3{objects: map[string][]byte{}}
+	s3 := httptest.NewServer(bucket)
+	defer s3.Close()
+
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.S3Endpoint = s3.URL
+		cfg.S3Bucket = "logs"
+		cfg.S3AccessKey = "key"
+		cfg.S3SecretKey = "secret"
+		cfg.S3Prefix = "darkhold/"
+	})
+	defer s.close()
+
+	s.app.publishThreadEvent("thread-a", `{"method":"item/completed","params":{"item":{"type":"agentMessage","text":"Done."}}}`)
+
+	resp, payload := doJSON(t, http.MethodPost, s.http.URL+"/api/archive/upload", map[string]any{"threadId": "thread-a"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("upload failed: %d %v", resp.StatusCode, payload)
+	}
+	if !strings.Contains(bucket.object("/logs/darkhold/thread-a/events.jsonl"), "agentMessage") {
+		t.Fatal("expected raw event log to be uploaded")
+	}
+	if !strings.Contains(bucket.object("/logs/darkhold/thread-a/transcript.md"), "## Agent\n\nDone.") {
+		t.Fatalf("unexpected transcript %q", bucket.object("/logs/darkhold/thread-a/transcript.md"))
+	}
+
+	resp, payload = doJSON(t, http.MethodPost, s.http.URL+"/api/archive/restore", map[string]any{"threadId": "thread-a"})
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected conflict with a local log, got %d %v", resp.StatusCode, payload)
+	}
+
+	if err := s.store.Delete("thread-a"); err != nil {
+		t.Fatal(err)
+	}
+	resp, payload = doJSON(t, http.MethodPost, s.http.URL+"/api/archive/restore", map[string]any{"threadId": "thread-a"})
+	if resp.StatusCode != http.StatusOK || payload["events"] != float64(1) {
+		t.Fatalf("restore failed: %d %v", resp.StatusCode, payload)
+	}
+	if events, _ := s.store.Read("thread-a"); len(events) != 1 || !strings.Contains(events[0], "Done.") {
+		t.Fatalf("unexpected restored events %v", events)
+	}
+
+	resp, payload = doJSON(t, http.MethodPost, s.http.URL+"/api/archive/restore", map[string]any{"threadId": "thread-missing"})
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected missing archive to 404, got %d %v", resp.StatusCode, payload)
+	}
+
+	resp, payload = doJSON(t, http.MethodGet, s.http.URL+"/api/archive", nil)
+	uploaded, _ := payload["uploaded"].(map[string]any)
+	if resp.StatusCode != http.StatusOK || payload["enabled"] != true || uploaded["thread-a"] == nil {
+		t.Fatalf("unexpected status: %d %v", resp.StatusCode, payload)
+	}
+}
+
+func TestArchiveDisabledWithoutBucket(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	resp, payload := doJSON(t, http.MethodPost, s.http.URL+"/api/archive/upload", map[string]any{"threadId": "thread-a"})
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d %v", resp.StatusCode, payload)
+	}
+}
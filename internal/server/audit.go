@@ -0,0 +1,58 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// auditLog appends actions that run code outside the agent, such as
+// terminal sessions, to <data-dir>/audit.jsonl, one JSON object per line.
+// Without a data dir nothing is recorded.
+type auditLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+type auditEntry struct {
+	Time     int64          `json:"ts"`
+	Action   string         `json:"action"`
+	Identity string         `json:"identity"`
+	Remote   string         `json:"remote,omitempty"`
+	Details  map[string]any `json:"details,omitempty"`
+}
+
+func openAuditLog(dataDir string) *auditLog {
+	if dataDir == "" {
+		return nil
+	}
+	return &auditLog{path: filepath.Join(dataDir, "audit.jsonl")}
+}
+
+// record appends an entry for an action taken on behalf of r's caller.
+func (a *auditLog) record(r *http.Request, action string, details map[string]any) {
+	if a == nil {
+		return
+	}
+	line, _ := json.Marshal(auditEntry{
+		Time:     time.Now().UnixMilli(),
+		Action:   action,
+		Identity: clientIdentity(r.Context()),
+		Remote:   remoteHost(r),
+		Details:  details,
+	})
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err == nil {
+		_, err = f.Write(append(line, '\n'))
+		f.Close()
+	}
+	if err != nil {
+		log.Printf("[audit] failed to record %s: %v", action, err)
+	}
+}
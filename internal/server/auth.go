@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+const (
+	errCodeUnauthorized = "UNAUTHORIZED"
+
+	// anonymousIdentity is the usage key for requests when no API keys are
+	// configured.
+	anonymousIdentity = "anonymous"
+	apiKeyCookie      = "darkhold_key"
+)
+
+type clientIdentityKey struct{}
+
+func withClientIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, clientIdentityKey{}, identity)
+}
+
+// clientIdentity returns the API key name the request was made with, or
+// anonymousIdentity.
+func clientIdentity(ctx context.Context) string {
+	if identity, ok := ctx.Value(clientIdentityKey{}).(string); ok && identity != "" {
+		return identity
+	}
+	return anonymousIdentity
+}
+
+// requestToken reads the API key from the Authorization header, the
+// access_token query parameter (for EventSource), or the browser cookie.
+func requestToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	if token := r.URL.Query().Get("access_token"); token != "" {
+		return token
+	}
+	if cookie, err := r.Cookie(apiKeyCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// lookupAPIKey returns the name of the configured key matching token.
+func (s *Server) lookupAPIKey(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	for _, key := range s.cfg.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(key.Token), []byte(token)) == 1 {
+			return key.Name, true
+		}
+	}
+	return "", false
+}
+
+// authenticate resolves the caller's identity. A ?share= token is the only
+// credential considered when present, and reads its thread's events. API
+// routes other than health, signed quick links and the sign-in routes
+// require a valid key, mapped client certificate or OIDC session once any is
+// configured; the web UI
+// assets stay public and remember a valid ?access_token= in a cookie, or with
+// OIDC send the browser to sign in first.
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	if r.URL.Query().Has(shareLinkParam) {
+		identity, ok := s.shareLinkIdentity(w, r)
+		if !ok {
+			return r, false
+		}
+		return r.WithContext(withClientScopes(withClientIdentity(r.Context(), identity), []string{"read"})), true
+	}
+	if len(s.cfg.APIKeys) == 0 && s.oidc == nil && len(s.cfg.ClientCertScopes) == 0 {
+		return r, true
+	}
+	name, ok := s.lookupAPIKey(requestToken(r))
+	// Embedded widgets show thread data, so they are guarded like the API.
+	isAPI := r.URL.Path == "/api" || strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, embedThreadPathPrefix)
+	if !ok {
+		if identity, scopes, ok := s.clientCertIdentity(r); ok {
+			return r.WithContext(withClientScopes(withClientIdentity(r.Context(), identity), scopes)), true
+		}
+		if sess, ok := s.oidc.session(r); ok {
+			return r.WithContext(withClientScopes(withClientIdentity(r.Context(), sess.Identity), sess.Scopes)), true
+		}
+		switch {
+		case r.URL.Path == "/api/health" || r.URL.Path == quickLinkPath || r.URL.Path == oidcLoginPath || r.URL.Path == oidcCallbackPath:
+		case isAPI:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="darkhold"`)
+			if s.oidc != nil {
+				writeErrorDetails(w, http.StatusUnauthorized, errCodeUnauthorized, "sign in or use a valid API key.", map[string]any{"login": oidcLoginPath})
+				return r, false
+			}
+			if len(s.cfg.APIKeys) == 0 {
+				writeError(w, http.StatusUnauthorized, errCodeUnauthorized, "a valid client certificate is required.")
+				return r, false
+			}
+			writeError(w, http.StatusUnauthorized, errCodeUnauthorized, "a valid API key is required.")
+			return r, false
+		case s.oidc != nil && r.Method == http.MethodGet && r.URL.Query().Get("access_token") == "":
+			http.Redirect(w, r, oidcLoginRedirect(r), http.StatusFound)
+			return r, false
+		}
+		return r, true
+	}
+	if !isAPI && r.URL.Query().Get("access_token") != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     apiKeyCookie,
+			Value:    requestToken(r),
+			Path:     "/",
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+	return r.WithContext(withClientIdentity(r.Context(), name)), true
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	"darkhold-go/internal/events"
+	"darkhold-go/internal/threads"
+	"darkhold-go/internal/webhooks"
+)
+
+// autoArchiveCheckInterval is how often threads are checked against
+// --auto-archive-days.
+const autoArchiveCheckInterval = time.Hour
+
+// autoArchivedThread is one thread archived by a sweep.
+type autoArchivedThread struct {
+	ThreadID       string            `json:"threadId"`
+	LastActivityAt int64             `json:"lastActivityAt"`
+	AgentArchived  bool              `json:"agentArchived"`
+	Compaction     events.Compaction `json:"compaction"`
+}
+
+// autoArchiveRun summarizes one sweep. It is published as
+// darkhold/autoArchive/completed and served by /api/admin/auto-archive.
+type autoArchiveRun struct {
+	StartedAt    int64                `json:"startedAt"`
+	CompletedAt  int64                `json:"completedAt"`
+	IdleAfterMs  int64                `json:"idleAfterMs"`
+	Archived     []autoArchivedThread `json:"archived"`
+	Failed       map[string]string    `json:"failed,omitempty"`
+	BytesDropped int64                `json:"bytesDropped"`
+}
+
+// autoArchiveState holds the most recent sweep.
+type autoArchiveState struct {
+	mu   sync.Mutex
+	last *autoArchiveRun
+}
+
+func (s *Server) autoArchiver() {
+	for {
+		s.autoArchiveIdleThreads(time.Now())
+		select {
+		case <-s.reaperStop:
+			return
+		case <-time.After(autoArchiveCheckInterval):
+		}
+	}
+}
+
+// autoArchiveIdleThreads archives every thread whose log has not changed for
+// --auto-archive-days as of now. Archived threads and threads bound to an open
+// session are skipped. Each archived thread's log is compacted and gets a
+// darkhold/thread/autoArchived event; a sweep that archived anything is
+// summarized in a darkhold/autoArchive/completed webhook event.
+func (s *Server) autoArchiveIdleThreads(now time.Time) autoArchiveRun {
+	run := autoArchiveRun{StartedAt: now.UnixMilli(), IdleAfterMs: s.cfg.AutoArchiveAfter.Milliseconds(), Archived: []autoArchivedThread{}}
+	logs, err := s.eventStore.Logs()
+	if err != nil {
+		log.Printf("[auto-archive] failed to list event logs: %v", err)
+		return run
+	}
+	byKey := s.metadataByLogKey()
+	live := s.liveThreadKeys()
+	cutoff := now.Add(-s.cfg.AutoArchiveAfter)
+
+	var idle []autoArchivedThread
+	for _, l := range logs {
+		meta, ok := byKey[l.Key]
+		if !ok {
+			meta = threads.Metadata{ThreadID: l.Key}
+		}
+		lastActivity := threadLastActivity(meta, l)
+		if live[l.Key] || meta.ArchivedAt != 0 || lastActivity.After(cutoff) {
+			continue
+		}
+		idle = append(idle, autoArchivedThread{ThreadID: meta.ThreadID, LastActivityAt: lastActivity.UnixMilli()})
+	}
+	sort.Slice(idle, func(i, j int) bool { return idle[i].LastActivityAt < idle[j].LastActivityAt })
+
+	for _, thread := range idle {
+		if err := s.autoArchiveThread(&thread); err != nil {
+			log.Printf("[auto-archive] failed to archive thread %s: %v", thread.ThreadID, err)
+			if run.Failed == nil {
+				run.Failed = map[string]string{}
+			}
+			run.Failed[thread.ThreadID] = err.Error()
+			continue
+		}
+		run.Archived = append(run.Archived, thread)
+		run.BytesDropped += thread.Compaction.BytesDropped
+	}
+	run.CompletedAt = time.Now().UnixMilli()
+
+	s.autoArchive.mu.Lock()
+	s.autoArchive.last = &run
+	s.autoArchive.mu.Unlock()
+	if len(run.Archived) > 0 || len(run.Failed) > 0 {
+		log.Printf("[auto-archive] archived %d idle thread(s), %d failed, %d bytes compacted away", len(run.Archived), len(run.Failed), run.BytesDropped)
+		s.publishServerEvent("darkhold/autoArchive/completed", run)
+	}
+	return run
+}
+
+func (s *Server) autoArchiveThread(thread *autoArchivedThread) error {
+	if s.cfg.AutoArchiveAgent {
+		// A successful dispatch records the archive like a client call would.
+		response, err := s.dispatchRPC(context.Background(), "thread/archive", map[string]any{"threadId": thread.ThreadID})
+		if err != nil {
+			return err
+		}
+		if errObj, ok := response["error"].(map[string]any); ok {
+			return fmt.Errorf("thread/archive: %v", errObj["message"])
+		}
+		thread.AgentArchived = true
+	} else {
+		s.recordThreadArchived(thread.ThreadID, "thread/archive")
+	}
+
+	// Eviction deletes whole logs; keep it from racing the rewrite.
+	s.storageMu.Lock()
+	compaction, err := events.Compact(s.eventStore, thread.ThreadID)
+	s.storageMu.Unlock()
+	if err != nil {
+		log.Printf("[auto-archive] failed to compact thread %s: %v", thread.ThreadID, err)
+	}
+	thread.Compaction = compaction
+
+	notice, _ := json.Marshal(map[string]any{"method": "darkhold/thread/autoArchived", "params": thread})
+	s.publishThreadEvent(thread.ThreadID, string(notice))
+	return nil
+}
+
+// publishServerEvent sends an event that belongs to no thread to webhook
+// subscribers without a thread filter.
+func (s *Server) publishServerEvent(method string, params any) {
+	payload, _ := json.Marshal(map[string]any{"method": method, "params": params})
+	s.webhooks.Publish(webhooks.Event{
+		EventID: ulid.Make().String(),
+		Time:    time.Now().UnixMilli(),
+		Method:  method,
+		Payload: string(payload),
+	})
+}
+
+func (s *Server) handleAdminAutoArchive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	s.autoArchive.mu.Lock()
+	last := s.autoArchive.last
+	s.autoArchive.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"enabled":     s.cfg.AutoArchiveAfter > 0,
+		"idleAfterMs": s.cfg.AutoArchiveAfter.Milliseconds(),
+		"agent":       s.cfg.AutoArchiveAgent,
+		"lastRun":     last,
+	})
+}
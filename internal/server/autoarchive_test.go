@@ -0,0 +1,61 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestAutoArchiveArchivesAndCompactsIdleThreads(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.FakeApprovalRate = 0
+		cfg.AutoArchiveAgent = true
+	})
+	defer s.close()
+	// Set after startup so the background sweep stays off.
+	s.app.cfg.AutoArchiveAfter = 24 * time.Hour
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	liveID := started["thread"].(map[string]any)["id"].(string)
+
+	stale := time.Now().Add(-48 * time.Hour)
+	for _, threadID := range []string{"thread-idle", "thread-recent", liveID} {
+		s.app.publishThreadEvent(threadID, `{"method":"item/agentMessage/delta","params":{"itemId":"m1","delta":"hi"}}`)
+		s.app.publishThreadEvent(threadID, `{"method":"item/completed","params":{"item":{"id":"m1","type":"agentMessage","text":"hi"}}}`)
+		if threadID != "thread-recent" {
+			if err := os.Chtimes(filepath.Join(s.store.RootDir, s.store.Key(threadID)+".jsonl"), stale, stale); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	run := s.app.autoArchiveIdleThreads(time.Now())
+	if len(run.Archived) != 1 || run.Archived[0].ThreadID != "thread-idle" || !run.Archived[0].AgentArchived || run.Archived[0].Compaction.Dropped != 1 {
+		t.Fatalf("unexpected run %+v", run)
+	}
+	if meta, _ := s.app.threadIndex.Get("thread-idle"); meta.ArchivedAt == 0 {
+		t.Fatal("expected the idle thread to be marked archived")
+	}
+	idle, _ := s.store.Read("thread-idle")
+	if len(idle) != 2 || strings.Contains(idle[0], "delta") || !strings.Contains(idle[1], "darkhold/thread/autoArchived") {
+		t.Fatalf("unexpected idle thread log %v", idle)
+	}
+	for _, threadID := range []string{"thread-recent", liveID} {
+		if meta, _ := s.app.threadIndex.Get(threadID); meta.ArchivedAt != 0 {
+			t.Fatalf("expected %s to stay active", threadID)
+		}
+	}
+
+	if again := s.app.autoArchiveIdleThreads(time.Now()); len(again.Archived) != 0 {
+		t.Fatalf("expected archived threads to be skipped, got %+v", again)
+	}
+	resp, body := doJSON(t, http.MethodGet, s.http.URL+"/api/admin/auto-archive", nil)
+	if resp.StatusCode != http.StatusOK || body["enabled"] != true || body["lastRun"] == nil {
+		t.Fatalf("unexpected status %d %v", resp.StatusCode, body)
+	}
+}
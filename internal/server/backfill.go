@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// coldBackfillRetry is how long a thread/read backfill attempt is remembered,
+// so clients polling a thread the agent does not know (or that has no turns)
+// do not trigger a thread/read on every request.
+const coldBackfillRetry = time.Minute
+
+// coldBackfill is one thread/read backfill; concurrent requests for the same
+// thread wait on done instead of issuing their own.
+type coldBackfill struct {
+	done      chan struct{}
+	startedAt time.Time
+}
+
+// backfillColdThread fills an empty thread log from thread/read before it is
+// replayed, for threads darkhold has no history for (for example after a
+// restart with a fresh data dir). The events come from thread/read
+// reconciliation, so they carry synthesized provenance. It reports whether a
+// backfill ran or was awaited, i.e. whether the log is worth reading again.
+func (s *Server) backfillColdThread(ctx context.Context, threadID string) bool {
+	if s.threadHasLiveSession(threadID) {
+		// Its events are being recorded as they happen; an empty log just
+		// means nothing has happened yet.
+		return false
+	}
+	now := time.Now()
+	s.coldMu.Lock()
+	for id, attempt := range s.coldBackfills {
+		if now.Sub(attempt.startedAt) > coldBackfillRetry {
+			delete(s.coldBackfills, id)
+		}
+	}
+	if attempt, ok := s.coldBackfills[threadID]; ok {
+		s.coldMu.Unlock()
+		select {
+		case <-attempt.done:
+		case <-ctx.Done():
+		}
+		return true
+	}
+	attempt := &coldBackfill{done: make(chan struct{}), startedAt: now}
+	s.coldBackfills[threadID] = attempt
+	s.coldMu.Unlock()
+	defer close(attempt.done)
+
+	// A cached thread/read would skip reconciliation.
+	s.rpcCache.invalidateThread(threadID, false)
+	response, err := s.dispatchRPC(ctx, "thread/read", map[string]any{"threadId": threadID, "includeTurns": true})
+	if err == nil {
+		if errObj, ok := response["error"].(map[string]any); ok {
+			err = fmt.Errorf("%v", errObj["message"])
+		}
+	}
+	if err != nil {
+		log.Printf("[events] cold backfill of thread %s failed: %v", threadID, err)
+		return false
+	}
+	return true
+}
+
+func (s *Server) threadHasLiveSession(threadID string) bool {
+	s.sessionsMu.RLock()
+	defer s.sessionsMu.RUnlock()
+	sessionID, ok := s.threadToSession[threadID]
+	if !ok {
+		return false
+	}
+	sess, ok := s.sessions[sessionID]
+	if !ok {
+		return false
+	}
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return !sess.closed
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestColdThreadIsBackfilledFromThreadRead(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) { cfg.FakeApprovalRate = 0 })
+	defer s.close()
+
+	runTurn := func() string {
+		started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+		threadID := started["thread"].(map[string]any)["id"].(string)
+		_ = postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "remember me"}}})
+		waitForCondition(t, 5*time.Second, 10*time.Millisecond, func() bool {
+			events, _ := s.store.Read(threadID)
+			return len(events) > 0 && strings.Contains(events[len(events)-1], "turn/completed")
+		})
+		// Forget the log and the session binding, as after a restart.
+		if err := s.store.Delete(threadID); err != nil {
+			t.Fatal(err)
+		}
+		s.app.sessionsMu.Lock()
+		delete(s.app.threadToSession, threadID)
+		s.app.sessionsMu.Unlock()
+		return threadID
+	}
+
+	threadID := runTurn()
+	_, body := doJSON(t, http.MethodGet, s.http.URL+"/api/thread/events?threadId="+threadID, nil)
+	events, _ := body["events"].([]any)
+	if len(events) < 3 {
+		t.Fatalf("expected backfilled events, got %v", body)
+	}
+	first, _ := events[0].(string)
+	if !strings.Contains(first, "turn/started") || !strings.Contains(first, `"source":"thread/read"`) {
+		t.Fatalf("unexpected first event %s", first)
+	}
+
+	streamed := runTurn()
+	stream := openSSE(t, s.http.URL, streamed, "")
+	defer stream.Body.Close()
+	waitForSSEEvent(t, stream, func(event sseEvent) bool {
+		return strings.Contains(event.Data, "fake reply: remember me") && strings.Contains(event.Data, `"synthesized":true`)
+	}, 5*time.Second)
+}
+
+func TestColdBackfillSkipsThreadsWithLiveSessions(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) { cfg.FakeApprovalRate = 0 })
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	if s.app.backfillColdThread(t.Context(), threadID) {
+		t.Fatal("a thread bound to a live session has no history to backfill")
+	}
+	if s.app.backfillColdThread(t.Context(), "no-such-thread") {
+		t.Fatal("expected backfill of an unknown thread to fail")
+	}
+	s.app.coldMu.Lock()
+	_, remembered := s.app.coldBackfills["no-such-thread"]
+	s.app.coldMu.Unlock()
+	if !remembered {
+		t.Fatal("failed attempts should be remembered to avoid repeated thread/read calls")
+	}
+}
@@ -0,0 +1,393 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	browserfs "darkhold-go/internal/fs"
+
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	errCodeBroadcastNotFound = "BROADCAST_NOT_FOUND"
+
+	maxBroadcastTargets = 50
+	// broadcastHistory is how many broadcasts stay queryable in memory.
+	broadcastHistory = 64
+	// broadcastRingSize bounds the progress events kept for stream replay.
+	broadcastRingSize = 1000
+)
+
+// Broadcast target statuses. completed, failed, aborted and error are
+// terminal.
+const (
+	broadcastStarting            = "starting"
+	broadcastRunning             = "running"
+	broadcastAwaitingInteraction = "awaitingInteraction"
+	broadcastCompleted           = "completed"
+	broadcastFailed              = "failed"
+	broadcastAborted             = "aborted"
+	broadcastError               = "error"
+)
+
+type broadcastTarget struct {
+	ThreadID string `json:"threadId,omitempty"`
+	Cwd      string `json:"cwd,omitempty"`
+	Status   string `json:"status"`
+	TurnID   string `json:"turnId,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type broadcastSummary struct {
+	Total     int  `json:"total"`
+	Pending   int  `json:"pending"`
+	Completed int  `json:"completed"`
+	Failed    int  `json:"failed"`
+	Done      bool `json:"done"`
+}
+
+type broadcastView struct {
+	CorrelationID string            `json:"correlationId"`
+	CreatedAt     int64             `json:"createdAt"`
+	Targets       []broadcastTarget `json:"targets"`
+	Summary       broadcastSummary  `json:"summary"`
+}
+
+// broadcast tracks one turn/start fanned out across several threads. Progress
+// events are kept in a lineRing so streams can resume with Last-Event-ID.
+type broadcast struct {
+	id        string
+	createdAt time.Time
+	events    *lineRing
+
+	mu      sync.Mutex
+	targets []*broadcastTarget
+}
+
+func isTerminalBroadcastStatus(status string) bool {
+	switch status {
+	case broadcastCompleted, broadcastFailed, broadcastAborted, broadcastError:
+		return true
+	}
+	return false
+}
+
+func (b *broadcast) viewLocked() broadcastView {
+	view := broadcastView{CorrelationID: b.id, CreatedAt: b.createdAt.UnixMilli(), Targets: make([]broadcastTarget, 0, len(b.targets))}
+	for _, target := range b.targets {
+		view.Targets = append(view.Targets, *target)
+		switch {
+		case target.Status == broadcastCompleted:
+			view.Summary.Completed++
+		case isTerminalBroadcastStatus(target.Status):
+			view.Summary.Failed++
+		default:
+			view.Summary.Pending++
+		}
+	}
+	view.Summary.Total = len(b.targets)
+	view.Summary.Done = view.Summary.Pending == 0
+	return view
+}
+
+func (b *broadcast) view() broadcastView {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.viewLocked()
+}
+
+// update applies fn to target and, if it changed the status, emits a progress
+// event (plus a completion event when that made every target terminal).
+func (b *broadcast) update(target *broadcastTarget, fn func(*broadcastTarget)) {
+	b.mu.Lock()
+	before := *target
+	wasDone := b.viewLocked().Summary.Done
+	fn(target)
+	if *target == before {
+		b.mu.Unlock()
+		return
+	}
+	view := b.viewLocked()
+	snapshot := *target
+	b.mu.Unlock()
+
+	progress, _ := json.Marshal(map[string]any{
+		"method": "darkhold/broadcast/progress",
+		"params": map[string]any{"correlationId": b.id, "target": snapshot, "summary": view.Summary},
+	})
+	b.events.append(string(progress))
+	if view.Summary.Done && !wasDone {
+		completed, _ := json.Marshal(map[string]any{"method": "darkhold/broadcast/completed", "params": view})
+		b.events.append(string(completed))
+	}
+}
+
+type broadcastTurnRequest struct {
+	ThreadIDs    []string       `json:"threadIds"`
+	Cwds         []string       `json:"cwds"`
+	Input        []any          `json:"input"`
+	TurnParams   map[string]any `json:"turnParams"`
+	ThreadParams map[string]any `json:"threadParams"`
+}
+
+func (s *Server) handleBroadcastTurnStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
+	var request broadcastTurnRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+		return
+	}
+	input, err := normalizeTurnInput(request.Input, s.cfg.MaxTurnInputChars)
+	if err != nil {
+		writeInvalidTurnInput(w, err.(*turnInputError))
+		return
+	}
+	request.Input = input
+	b := &broadcast{id: ulid.Make().String(), createdAt: time.Now(), events: newLineRing(broadcastRingSize)}
+	for _, threadID := range request.ThreadIDs {
+		if threadID = strings.TrimSpace(threadID); threadID != "" {
+			b.targets = append(b.targets, &broadcastTarget{ThreadID: threadID, Status: broadcastStarting})
+		}
+	}
+	for _, cwd := range request.Cwds {
+		cwd = strings.TrimSpace(cwd)
+		if cwd == "" {
+			continue
+		}
+		resolved, err := browserfs.ResolvePath(cwd)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidPath, err.Error())
+			return
+		}
+		b.targets = append(b.targets, &broadcastTarget{Cwd: resolved, Status: broadcastStarting})
+	}
+	if len(b.targets) == 0 {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadIds or cwds is required.")
+		return
+	}
+	if len(b.targets) > maxBroadcastTargets {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "too many broadcast targets.")
+		return
+	}
+	s.registerBroadcast(b)
+
+	// The turns outlive this request; only the start calls are awaited.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), s.rpcTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, target := range b.targets {
+		wg.Go(func() {
+			s.startBroadcastTarget(ctx, b, target, request)
+		})
+	}
+	wg.Wait()
+	writeJSON(w, http.StatusOK, b.view())
+}
+
+func (s *Server) startBroadcastTarget(ctx context.Context, b *broadcast, target *broadcastTarget, request broadcastTurnRequest) {
+	fail := func(err error) {
+		b.update(target, func(t *broadcastTarget) {
+			t.Status = broadcastError
+			t.Error = err.Error()
+		})
+	}
+	threadID := target.ThreadID
+	if threadID == "" {
+		params := map[string]any{}
+		maps.Copy(params, request.ThreadParams)
+		params["cwd"] = target.Cwd
+		result, err := s.dispatchForResult(ctx, "thread/start", params)
+		if err != nil {
+			fail(err)
+			return
+		}
+		threadObj, _ := result["thread"].(map[string]any)
+		threadID, _ = threadObj["id"].(string)
+		if threadID == "" {
+			fail(errors.New("thread/start returned no thread id"))
+			return
+		}
+		b.update(target, func(t *broadcastTarget) { t.ThreadID = threadID })
+	}
+
+	s.broadcastsMu.Lock()
+	s.threadBroadcasts[threadID] = b.id
+	s.broadcastsMu.Unlock()
+
+	params := map[string]any{}
+	maps.Copy(params, request.TurnParams)
+	params["threadId"] = threadID
+	params["input"] = request.Input
+	result, err := s.dispatchForResult(ctx, "turn/start", params)
+	if err != nil {
+		fail(err)
+		s.forgetThreadBroadcast(threadID, b.id)
+		return
+	}
+	turnID := eventTurnID(result)
+	b.update(target, func(t *broadcastTarget) {
+		if t.Status == broadcastStarting {
+			t.Status = broadcastRunning
+		}
+		if t.TurnID == "" {
+			t.TurnID = turnID
+		}
+	})
+}
+
+// forgetThreadBroadcast stops mapping a thread's events onto broadcast id,
+// unless a newer broadcast has taken the thread over.
+func (s *Server) forgetThreadBroadcast(threadID, id string) {
+	s.broadcastsMu.Lock()
+	defer s.broadcastsMu.Unlock()
+	if s.threadBroadcasts[threadID] == id {
+		delete(s.threadBroadcasts, threadID)
+	}
+}
+
+// dispatchForResult runs dispatchRPC and folds upstream errors into err.
+func (s *Server) dispatchForResult(ctx context.Context, method string, params any) (map[string]any, error) {
+	response, err := s.dispatchRPC(ctx, method, params)
+	if err != nil {
+		return nil, err
+	}
+	if errObj, ok := response["error"].(map[string]any); ok {
+		message, _ := errObj["message"].(string)
+		return nil, errors.New(method + ": " + message)
+	}
+	result, _ := response["result"].(map[string]any)
+	return result, nil
+}
+
+func (s *Server) registerBroadcast(b *broadcast) {
+	s.broadcastsMu.Lock()
+	defer s.broadcastsMu.Unlock()
+	s.broadcasts[b.id] = b
+	s.broadcastOrder = append(s.broadcastOrder, b.id)
+	for len(s.broadcastOrder) > broadcastHistory {
+		evicted := s.broadcastOrder[0]
+		s.broadcastOrder = s.broadcastOrder[1:]
+		delete(s.broadcasts, evicted)
+		for threadID, id := range s.threadBroadcasts {
+			if id == evicted {
+				delete(s.threadBroadcasts, threadID)
+			}
+		}
+	}
+}
+
+// observeBroadcastEvent maps a thread's turn lifecycle onto the status of the
+// broadcast target it belongs to, if any. Only the broadcast's own turn
+// counts: events of another turn are ignored, and the thread is let go once
+// its target is terminal.
+func (s *Server) observeBroadcastEvent(threadID, method string, params map[string]any) {
+	s.broadcastsMu.Lock()
+	b := s.broadcasts[s.threadBroadcasts[threadID]]
+	s.broadcastsMu.Unlock()
+	if b == nil {
+		return
+	}
+	status := ""
+	errMessage := ""
+	switch method {
+	case "turn/started":
+		status = broadcastRunning
+	case "darkhold/interaction/request":
+		status = broadcastAwaitingInteraction
+	case "darkhold/interaction/resolved":
+		status = broadcastRunning
+	case "turn/aborted":
+		status = broadcastAborted
+	case "turn/completed", "turn/failed":
+		status = broadcastCompleted
+		if failure, failed := classifyTurnFailure(method, params); failed {
+			status = broadcastFailed
+			errMessage = failure.Message
+		}
+	default:
+		return
+	}
+	turnID := eventTurnID(params)
+	b.mu.Lock()
+	var target *broadcastTarget
+	for _, candidate := range b.targets {
+		if candidate.ThreadID == threadID {
+			target = candidate
+		}
+	}
+	b.mu.Unlock()
+	if target == nil {
+		return
+	}
+	terminal := false
+	b.update(target, func(t *broadcastTarget) {
+		if isTerminalBroadcastStatus(t.Status) {
+			terminal = true
+			return
+		}
+		if t.TurnID != "" && turnID != "" && turnID != t.TurnID {
+			return
+		}
+		if method == "darkhold/interaction/resolved" && t.Status != broadcastAwaitingInteraction {
+			return
+		}
+		t.Status = status
+		t.Error = errMessage
+		if turnID != "" {
+			t.TurnID = turnID
+		}
+		terminal = isTerminalBroadcastStatus(t.Status)
+	})
+	if terminal {
+		s.forgetThreadBroadcast(threadID, b.id)
+	}
+}
+
+func (s *Server) broadcastFromRequest(w http.ResponseWriter, r *http.Request) *broadcast {
+	id := strings.TrimSpace(r.URL.Query().Get("correlationId"))
+	if id == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "correlationId is required.")
+		return nil
+	}
+	s.broadcastsMu.Lock()
+	b := s.broadcasts[id]
+	s.broadcastsMu.Unlock()
+	if b == nil {
+		writeError(w, http.StatusNotFound, errCodeBroadcastNotFound, "broadcast not found.")
+		return nil
+	}
+	return b
+}
+
+func (s *Server) handleBroadcast(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if b := s.broadcastFromRequest(w, r); b != nil {
+		writeJSON(w, http.StatusOK, b.view())
+	}
+}
+
+func (s *Server) handleBroadcastStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	b := s.broadcastFromRequest(w, r)
+	if b == nil {
+		return
+	}
+	streamLineRing(w, r, b.events, func(line logLine) string { return line.Text })
+}
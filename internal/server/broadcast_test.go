@@ -0,0 +1,95 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBroadcastTurnStartTracksEveryThread(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	threadIDs := []string{"thread-a", "thread-b"}
+	streams := map[string]*http.Response{}
+	for _, threadID := range threadIDs {
+		streams[threadID] = openSSE(t, s.http.URL, threadID, "")
+		defer streams[threadID].Body.Close()
+	}
+
+	resp, started := doJSON(t, http.MethodPost, s.http.URL+"/api/broadcast/turn/start", map[string]any{
+		"threadIds": threadIDs,
+		"input":     []any{map[string]any{"type": "text", "text": "update the dependency and run tests"}},
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("broadcast failed: %d %v", resp.StatusCode, started)
+	}
+	correlationID, _ := started["correlationId"].(string)
+	if correlationID == "" || started["summary"].(map[string]any)["total"] != float64(2) {
+		t.Fatalf("unexpected broadcast response: %v", started)
+	}
+
+	progress, err := http.Get(s.http.URL + "/api/broadcast/stream?correlationId=" + correlationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer progress.Body.Close()
+
+	for _, threadID := range threadIDs {
+		acceptNextApproval(t, s.http.URL, threadID, streams[threadID])
+	}
+	completed := waitForSSEEvent(t, progress, func(event sseEvent) bool {
+		return parseJSON(t, event.Data)["method"] == "darkhold/broadcast/completed"
+	}, 10*time.Second)
+	summary := parseJSON(t, completed.Data)["params"].(map[string]any)["summary"].(map[string]any)
+	if summary["completed"] != float64(2) || summary["done"] != true {
+		t.Fatalf("unexpected broadcast summary: %v", summary)
+	}
+
+	_, view := doJSON(t, http.MethodGet, s.http.URL+"/api/broadcast?correlationId="+correlationID, nil)
+	for _, target := range view["targets"].([]any) {
+		if target.(map[string]any)["status"] != broadcastCompleted {
+			t.Fatalf("unexpected target state: %v", view)
+		}
+	}
+
+	missing, _ := doJSON(t, http.MethodGet, s.http.URL+"/api/broadcast?correlationId=nope", nil)
+	if missing.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown broadcast, got %d", missing.StatusCode)
+	}
+}
+
+func TestBroadcastTargetFollowsOnlyItsOwnTurn(t *testing.T) {
+	s := &Server{broadcasts: map[string]*broadcast{}, threadBroadcasts: map[string]string{}}
+	target := &broadcastTarget{ThreadID: "t-1", Status: broadcastRunning, TurnID: "turn-1"}
+	b := &broadcast{id: "b-1", createdAt: time.Now(), events: newLineRing(broadcastRingSize), targets: []*broadcastTarget{target}}
+	s.registerBroadcast(b)
+	s.threadBroadcasts["t-1"] = b.id
+
+	s.observeBroadcastEvent("t-1", "turn/completed", map[string]any{"turn": map[string]any{"id": "turn-0", "status": "completed"}})
+	if target.Status != broadcastRunning {
+		t.Fatalf("another turn's completion must not finish the target: %+v", target)
+	}
+	s.observeBroadcastEvent("t-1", "turn/completed", map[string]any{"turn": map[string]any{"id": "turn-1", "status": "completed"}})
+	if target.Status != broadcastCompleted {
+		t.Fatalf("expected the target's own turn to complete it: %+v", target)
+	}
+	if _, mapped := s.threadBroadcasts["t-1"]; mapped {
+		t.Fatal("a terminal target must let go of its thread")
+	}
+
+	// Events that still reach the broadcast, such as a late failure, must
+	// neither reopen the target nor announce completion twice.
+	s.threadBroadcasts["t-1"] = b.id
+	s.observeBroadcastEvent("t-1", "turn/failed", map[string]any{"turnId": "turn-1", "error": map[string]any{"message": "late"}})
+	completions := 0
+	for _, line := range b.events.snapshot(0) {
+		if strings.Contains(line.Text, "darkhold/broadcast/completed") {
+			completions++
+		}
+	}
+	if target.Status != broadcastCompleted || completions != 1 {
+		t.Fatalf("expected one completion and a completed target, got %d and %+v", completions, target)
+	}
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"darkhold-go/internal/config"
+	"darkhold-go/internal/indexdb"
+	"darkhold-go/internal/usage"
+)
+
+const errCodeBudgetExceeded = "BUDGET_EXCEEDED"
+
+// openUsageTracker loads today's usage counters from db, falling back to
+// in-memory counters if they cannot be read.
+func openUsageTracker(db *indexdb.DB) *usage.Tracker {
+	if db != nil {
+		tracker, err := usage.OpenStore(db.Usage())
+		if err == nil {
+			return tracker
+		}
+		log.Printf("[usage] failed to load usage counters, using in-memory counters: %v", err)
+	}
+	tracker, _ := usage.Open("")
+	return tracker
+}
+
+// budgetExceededError rejects a turn/start once a daily limit is used up.
+type budgetExceededError struct {
+	Scope    string    `json:"scope"`
+	Key      string    `json:"key,omitempty"`
+	Metric   string    `json:"metric"`
+	Limit    int64     `json:"limit"`
+	Used     int64     `json:"used"`
+	ResetsAt time.Time `json:"resetsAt"`
+}
+
+func (e *budgetExceededError) Error() string {
+	owner := "the server-wide"
+	if e.Scope == "key" {
+		owner = fmt.Sprintf("API key %q's", e.Key)
+	}
+	return fmt.Sprintf("%s daily %s budget is exhausted (%d of %d used); it resets at %s",
+		owner, e.Metric, e.Used, e.Limit, e.ResetsAt.Format(time.RFC3339))
+}
+
+func writeBudgetExceeded(w http.ResponseWriter, err *budgetExceededError) {
+	retryAfter := max(int(time.Until(err.ResetsAt).Seconds()), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	writeErrorDetails(w, http.StatusTooManyRequests, errCodeBudgetExceeded, err.Error(), err)
+}
+
+func budgetCheck(scope, key string, budget config.Budget, used usage.Counts, resetsAt time.Time) *budgetExceededError {
+	switch {
+	case budget.TurnsPerDay > 0 && used.Turns >= budget.TurnsPerDay:
+		return &budgetExceededError{Scope: scope, Key: key, Metric: "turns", Limit: budget.TurnsPerDay, Used: used.Turns, ResetsAt: resetsAt}
+	case budget.TokensPerDay > 0 && used.Tokens >= budget.TokensPerDay:
+		return &budgetExceededError{Scope: scope, Key: key, Metric: "tokens", Limit: budget.TokensPerDay, Used: used.Tokens, ResetsAt: resetsAt}
+	}
+	return nil
+}
+
+// reserveTurnBudget admits a turn/start for identity against its daily
+// budgets and holds one turn plus the input's estimated tokens until the
+// turn is over, so concurrent starts cannot all pass for the last turn of a
+// day.
+func (s *Server) reserveTurnBudget(identity string, params map[string]any) (*usage.Reservation, error) {
+	chars, _ := inputTextChars(params)
+	estimate := usage.Counts{Turns: 1, Tokens: int64((chars + estimateCharsPerToken - 1) / estimateCharsPerToken)}
+	return s.usage.Reserve(identity, estimate, s.budgetAllows(identity))
+}
+
+// checkTurnBudget reports whether identity may start another turn today,
+// counting the turns and tokens held for turns still running.
+func (s *Server) checkTurnBudget(identity string) error {
+	_, err := s.usage.Reserve(identity, usage.Counts{}, s.budgetAllows(identity))
+	return err
+}
+
+func (s *Server) budgetAllows(identity string) func(used, total usage.Counts) error {
+	resetsAt := s.usage.ResetsAt()
+	return func(used, total usage.Counts) error {
+		if err := budgetCheck("key", identity, s.cfg.KeyBudgets[identity], used, resetsAt); err != nil {
+			return err
+		}
+		if err := budgetCheck("global", "", s.cfg.GlobalBudget, total, resetsAt); err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// holdTurnBudget keeps a turn/start's reservation with its thread from just
+// before the call, as the turn can finish before the call returns.
+func (s *Server) holdTurnBudget(threadID string, reservation *usage.Reservation) {
+	s.threadsMu.Lock()
+	defer s.threadsMu.Unlock()
+	if previous := s.turnBudgets[threadID]; previous != nil && previous != reservation {
+		previous.Release()
+	}
+	s.turnBudgets[threadID] = reservation
+}
+
+// releaseTurnBudget gives back the reservation of a turn/start that failed.
+func (s *Server) releaseTurnBudget(threadID string, reservation *usage.Reservation) {
+	reservation.Release()
+	s.threadsMu.Lock()
+	if s.turnBudgets[threadID] == reservation {
+		delete(s.turnBudgets, threadID)
+	}
+	s.threadsMu.Unlock()
+}
+
+// settleTurnBudget gives back what a finished turn's reservation still
+// holds; its tokens have been counted as they were reported.
+func (s *Server) settleTurnBudget(threadID string) {
+	s.threadsMu.Lock()
+	reservation := s.turnBudgets[threadID]
+	delete(s.turnBudgets, threadID)
+	s.threadsMu.Unlock()
+	if reservation != nil {
+		reservation.Release()
+	}
+}
+
+// recordTurnStarted counts a turn against identity and attributes the
+// thread's subsequent token usage to it.
+func (s *Server) recordTurnStarted(threadID, identity string, reservation *usage.Reservation) {
+	s.threadsMu.Lock()
+	s.turnOwners[threadID] = identity
+	s.threadsMu.Unlock()
+	if err := reservation.Use(usage.Counts{Turns: 1}); err != nil {
+		log.Printf("[usage] failed to record turn for %s: %v", identity, err)
+	}
+	if threadID == "" {
+		// No turn end will settle it.
+		reservation.Release()
+	}
+}
+
+// recordTokenUsage adds the tokens of the latest model call reported by a
+// thread/tokenUsage/updated notification.
+func (s *Server) recordTokenUsage(threadID string, params map[string]any) {
+	tokenUsage, _ := params["tokenUsage"].(map[string]any)
+	last, _ := tokenUsage["last"].(map[string]any)
+	tokens, _ := last["totalTokens"].(float64)
+	if tokens <= 0 {
+		return
+	}
+	s.threadsMu.RLock()
+	identity, ok := s.turnOwners[threadID]
+	reservation := s.turnBudgets[threadID]
+	s.threadsMu.RUnlock()
+	if !ok {
+		identity = anonymousIdentity
+	}
+	var err error
+	if reservation != nil {
+		err = reservation.Use(usage.Counts{Tokens: int64(tokens)})
+	} else {
+		err = s.usage.AddTokens(identity, int64(tokens))
+	}
+	if err != nil {
+		log.Printf("[usage] failed to record tokens for %s: %v", identity, err)
+	}
+}
+
+type usageBudgetView struct {
+	TurnsPerDay  int64 `json:"turnsPerDay,omitempty"`
+	TokensPerDay int64 `json:"tokensPerDay,omitempty"`
+}
+
+type usageEntry struct {
+	Key       string           `json:"key,omitempty"`
+	Turns     int64            `json:"turns"`
+	Tokens    int64            `json:"tokens"`
+	Budget    *usageBudgetView `json:"budget,omitempty"`
+	Remaining *usageBudgetView `json:"remaining,omitempty"`
+	Exceeded  bool             `json:"exceeded"`
+}
+
+func newUsageEntry(key string, counts usage.Counts, budget config.Budget) usageEntry {
+	entry := usageEntry{Key: key, Turns: counts.Turns, Tokens: counts.Tokens}
+	if budget.IsZero() {
+		return entry
+	}
+	entry.Budget = &usageBudgetView{TurnsPerDay: budget.TurnsPerDay, TokensPerDay: budget.TokensPerDay}
+	entry.Remaining = &usageBudgetView{}
+	if budget.TurnsPerDay > 0 {
+		entry.Remaining.TurnsPerDay = max(budget.TurnsPerDay-counts.Turns, 0)
+		entry.Exceeded = entry.Exceeded || counts.Turns >= budget.TurnsPerDay
+	}
+	if budget.TokensPerDay > 0 {
+		entry.Remaining.TokensPerDay = max(budget.TokensPerDay-counts.Tokens, 0)
+		entry.Exceeded = entry.Exceeded || counts.Tokens >= budget.TokensPerDay
+	}
+	return entry
+}
+
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	day, snapshot := s.usage.Snapshot()
+	for _, key := range s.cfg.APIKeys {
+		if _, ok := snapshot[key.Name]; !ok {
+			snapshot[key.Name] = usage.Counts{}
+		}
+	}
+	keys := make([]usageEntry, 0, len(snapshot))
+	for key, counts := range snapshot {
+		keys = append(keys, newUsageEntry(key, counts, s.cfg.KeyBudgets[key]))
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+	writeJSON(w, http.StatusOK, map[string]any{
+		"day":      day,
+		"resetsAt": s.usage.ResetsAt().UnixMilli(),
+		"caller":   clientIdentity(r.Context()),
+		"global":   newUsageEntry("", s.usage.Total(), s.cfg.GlobalBudget),
+		"keys":     keys,
+	})
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"darkhold-go/internal/config"
+)
+
+func TestTurnBudgetPerAPIKey(t *testing.T) {
+	const internToken = "intern-token-0123456789"
+	const leadToken = "lead-token-0123456789"
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.APIKeys = []config.APIKey{{Name: "intern", Token: internToken}, {Name: "lead", Token: leadToken}}
+		cfg.KeyBudgets = map[string]config.Budget{"intern": {TurnsPerDay: 1}}
+	})
+	defer s.close()
+
+	resp, payload := doJSON(t, http.MethodGet, s.http.URL+"/api/threads", nil)
+	if resp.StatusCode != http.StatusUnauthorized || payload["code"] != errCodeUnauthorized {
+		t.Fatalf("expected missing key to be rejected: %d %v", resp.StatusCode, payload)
+	}
+	if resp, _ := doJSON(t, http.MethodGet, s.http.URL+"/api/health", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("health should not require a key, got %d", resp.StatusCode)
+	}
+
+	rpc := func(token, method string, params map[string]any) (*http.Response, map[string]any) {
+		return doJSON(t, http.MethodPost, s.http.URL+"/api/rpc?access_token="+token, map[string]any{"method": method, "params": params})
+	}
+	_, started := rpc(internToken, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	turn := map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "hello"}}}
+
+	if resp, payload := rpc(internToken, "turn/start", turn); resp.StatusCode != http.StatusOK {
+		t.Fatalf("first turn should be allowed: %d %v", resp.StatusCode, payload)
+	}
+	resp, payload = rpc(internToken, "turn/start", turn)
+	if resp.StatusCode != http.StatusTooManyRequests || payload["code"] != errCodeBudgetExceeded || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("expected budget rejection: %d %v", resp.StatusCode, payload)
+	}
+	details := payload["details"].(map[string]any)
+	if details["scope"] != "key" || details["metric"] != "turns" || details["limit"] != float64(1) {
+		t.Fatalf("unexpected budget details: %v", details)
+	}
+	if resp, payload := rpc(leadToken, "turn/start", turn); resp.StatusCode != http.StatusOK {
+		t.Fatalf("other keys should be unaffected: %d %v", resp.StatusCode, payload)
+	}
+
+	_, report := doJSON(t, http.MethodGet, s.http.URL+"/api/usage?access_token="+internToken, nil)
+	if report["caller"] != "intern" {
+		t.Fatalf("unexpected caller: %v", report)
+	}
+	for _, raw := range report["keys"].([]any) {
+		entry := raw.(map[string]any)
+		if entry["key"] == "intern" && (entry["turns"] != float64(1) || entry["exceeded"] != true) {
+			t.Fatalf("unexpected intern usage: %v", entry)
+		}
+	}
+}
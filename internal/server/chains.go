@@ -0,0 +1,278 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"darkhold-go/internal/threads"
+)
+
+const (
+	errCodeChainCycle = "CHAIN_CYCLE"
+
+	// chainReplyLimit caps, in characters, how much of the finished turn's
+	// reply a chained turn is given.
+	chainReplyLimit = 32000
+)
+
+// threadChainView is the body of GET, PUT and DELETE /api/thread/chain for
+// one thread: what it follows and which threads follow it.
+type threadChainView struct {
+	ThreadID   string         `json:"threadId"`
+	Chain      *threads.Chain `json:"chain"`
+	Dependents []string       `json:"dependents"`
+	Version    int64          `json:"version"`
+}
+
+// threadChainEdge is one chain in the graph listing.
+type threadChainEdge struct {
+	ThreadID string `json:"threadId"`
+	threads.Chain
+}
+
+// threadChainedParams is published as darkhold/thread/chained on the thread
+// a chain started a turn on, or failed to.
+type threadChainedParams struct {
+	ThreadID    string `json:"threadId"`
+	After       string `json:"after"`
+	AfterTurnID string `json:"afterTurnId"`
+	Repeat      bool   `json:"repeat,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// chainCycleError refuses a chain that would make a thread wait on itself.
+type chainCycleError struct {
+	Path []string `json:"path"`
+}
+
+func (e *chainCycleError) Error() string {
+	return "chain would loop: " + strings.Join(e.Path, " -> ")
+}
+
+// handleThreadChain declares that a thread's next turn starts when a turn
+// of another thread completes. GET without threadId lists every chain.
+func (s *Server) handleThreadChain(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		threadID := strings.TrimSpace(r.URL.Query().Get("threadId"))
+		if threadID == "" {
+			chains := []threadChainEdge{}
+			for _, meta := range s.threadIndex.List() {
+				if meta.Chain != nil {
+					chains = append(chains, threadChainEdge{ThreadID: meta.ThreadID, Chain: *meta.Chain})
+				}
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"chains": chains})
+			return
+		}
+		meta, ok := s.threadIndex.Get(threadID)
+		if !ok {
+			writeError(w, http.StatusNotFound, errCodeThreadNotFound, "thread not found.")
+			return
+		}
+		writeThreadVersion(w, meta.Version)
+		writeJSON(w, http.StatusOK, s.threadChainView(meta))
+	case http.MethodPut:
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
+		var request struct {
+			ThreadID string `json:"threadId"`
+			After    string `json:"after"`
+			Prompt   string `json:"prompt"`
+			Repeat   bool   `json:"repeat"`
+			Version  *int64 `json:"version"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+			return
+		}
+		request.ThreadID = strings.TrimSpace(request.ThreadID)
+		if request.ThreadID == "" {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+			return
+		}
+		chain := threads.Chain{After: strings.TrimSpace(request.After), Prompt: strings.TrimSpace(request.Prompt), Repeat: request.Repeat, CreatedAt: time.Now().UnixMilli(), CreatedBy: clientIdentity(r.Context())}
+		if err := chain.Validate(request.ThreadID); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+			return
+		}
+		expected, checked, err := threadVersionPrecondition(r, request.Version)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+			return
+		}
+		for _, threadID := range []string{request.ThreadID, chain.After} {
+			if _, ok := s.threadIndex.Get(threadID); !ok {
+				writeErrorDetails(w, http.StatusNotFound, errCodeThreadNotFound, "thread not found.", map[string]any{"threadId": threadID})
+				return
+			}
+		}
+		// The check reads the index outside the update; two racing PUTs
+		// could still close a loop, which a one-shot chain would break.
+		if path := threads.ChainCycle(s.threadIndex.List(), request.ThreadID, chain.After); path != nil {
+			cycle := &chainCycleError{Path: path}
+			writeErrorDetails(w, http.StatusConflict, errCodeChainCycle, cycle.Error()+".", cycle)
+			return
+		}
+		s.updateThreadChain(w, r, request.ThreadID, expected, checked, &chain)
+	case http.MethodDelete:
+		threadID := strings.TrimSpace(r.URL.Query().Get("threadId"))
+		if threadID == "" {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+			return
+		}
+		if _, ok := s.threadIndex.Get(threadID); !ok {
+			writeError(w, http.StatusNotFound, errCodeThreadNotFound, "thread not found.")
+			return
+		}
+		expected, checked, err := threadVersionPrecondition(r, nil)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+			return
+		}
+		s.updateThreadChain(w, r, threadID, expected, checked, nil)
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+func (s *Server) updateThreadChain(w http.ResponseWriter, r *http.Request, threadID string, expected int64, checked bool, chain *threads.Chain) {
+	meta, err := s.threadIndex.Update(threadID, func(meta *threads.Metadata) error {
+		if err := checkThreadVersion(meta.Version, expected, checked); err != nil {
+			return err
+		}
+		meta.Chain = chain
+		meta.Version++
+		meta.UpdatedAt = time.Now().UnixMilli()
+		return nil
+	})
+	if err != nil {
+		writeThreadUpdateError(w, err)
+		return
+	}
+	details := map[string]any{"threadId": threadID}
+	if chain != nil {
+		details["after"], details["repeat"] = chain.After, chain.Repeat
+	}
+	s.audit.record(r, "thread.chain", details)
+	writeThreadVersion(w, meta.Version)
+	writeJSON(w, http.StatusOK, s.threadChainView(meta))
+}
+
+func (s *Server) threadChainView(meta threads.Metadata) threadChainView {
+	view := threadChainView{ThreadID: meta.ThreadID, Chain: meta.Chain, Dependents: []string{}, Version: meta.Version}
+	for _, other := range s.threadIndex.List() {
+		if other.Chain != nil && other.Chain.After == meta.ThreadID {
+			view.Dependents = append(view.Dependents, other.ThreadID)
+		}
+	}
+	return view
+}
+
+// fireThreadChains starts the next turn of every thread chained to
+// threadID once one of its turns completes without error. A one-shot chain
+// is removed before its turn is submitted, so a slow submit cannot fire it
+// twice.
+func (s *Server) fireThreadChains(threadID string, params map[string]any) {
+	turn, _ := params["turn"].(map[string]any)
+	status, _ := turn["status"].(string)
+	turnID := eventTurnID(params)
+	if status != "completed" || turn["error"] != nil || turnID == "" {
+		return
+	}
+	var dependents []threads.Metadata
+	for _, meta := range s.threadIndex.List() {
+		if meta.Chain != nil && meta.Chain.After == threadID {
+			dependents = append(dependents, meta)
+		}
+	}
+	if len(dependents) == 0 {
+		return
+	}
+	var reply string
+	if message, ok := s.turnMessages.latest(threadID, turnID); ok {
+		reply = message.Text
+	}
+	for _, meta := range dependents {
+		chain := *meta.Chain
+		if !chain.Repeat {
+			var fired bool
+			if _, err := s.threadIndex.Update(meta.ThreadID, func(meta *threads.Metadata) error {
+				if meta.Chain == nil || meta.Chain.After != threadID || meta.Chain.Repeat {
+					return errChainChanged
+				}
+				meta.Chain = nil
+				meta.Version++
+				meta.UpdatedAt = time.Now().UnixMilli()
+				fired = true
+				return nil
+			}); err != nil && !errors.Is(err, errChainChanged) {
+				log.Printf("[chain] clear chain on thread %s: %v", meta.ThreadID, err)
+			}
+			if !fired {
+				continue
+			}
+		}
+		go func(dependent string) {
+			defer s.recoverPanic("thread-chain")
+			s.startChainedTurn(dependent, chain, threadID, turnID, reply)
+		}(meta.ThreadID)
+	}
+}
+
+var errChainChanged = errors.New("chain changed")
+
+// startChainedTurn submits the chained turn/start through dispatchRPC as the
+// chain's creator, so it is budgeted, hooked, locked and snapshotted like
+// any other turn. A thread with a turn already running is left alone.
+func (s *Server) startChainedTurn(threadID string, chain threads.Chain, after, afterTurnID, reply string) {
+	event := threadChainedParams{ThreadID: threadID, After: after, AfterTurnID: afterTurnID, Repeat: chain.Repeat}
+	params := map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": chainedTurnText(chain, after, reply)}}}
+	ctx, cancel := context.WithTimeout(withClientIdentity(context.Background(), chain.CreatedBy), s.rpcTimeout)
+	defer cancel()
+	if err := s.submitChainedTurn(ctx, threadID, params); err != nil {
+		event.Error = err.Error()
+		log.Printf("[chain] start turn on thread %s after %s turn %s: %v", threadID, after, afterTurnID, err)
+	} else {
+		log.Printf("[chain] started turn on thread %s after %s turn %s", threadID, after, afterTurnID)
+	}
+	encoded, _ := json.Marshal(map[string]any{"method": "darkhold/thread/chained", "params": event})
+	s.publishThreadEvent(threadID, string(encoded))
+}
+
+var errChainThreadBusy = errors.New("thread already has a turn running")
+
+func (s *Server) submitChainedTurn(ctx context.Context, threadID string, params map[string]any) error {
+	if _, active := s.threadLiveState(); active[threadID] {
+		return errChainThreadBusy
+	}
+	s.sessionsMu.RLock()
+	_, bound := s.threadToSession[threadID]
+	s.sessionsMu.RUnlock()
+	if !bound {
+		if _, err := s.dispatchForResult(ctx, "thread/resume", map[string]any{"threadId": threadID}); err != nil {
+			return err
+		}
+	}
+	_, err := s.dispatchForResult(ctx, "turn/start", params)
+	return err
+}
+
+// chainedTurnText is the chain's prompt followed by the finished turn's
+// reply, marked so the agent can tell the two apart.
+func chainedTurnText(chain threads.Chain, after, reply string) string {
+	reply = truncateRunes(reply, chainReplyLimit)
+	var b strings.Builder
+	if chain.Prompt != "" {
+		b.WriteString(chain.Prompt)
+		b.WriteString("\n\n")
+	}
+	b.WriteString(`<darkhold-chain thread="` + after + "\">\n")
+	b.WriteString(reply)
+	b.WriteString("\n</darkhold-chain>")
+	return b.String()
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+	"darkhold-go/internal/threads"
+)
+
+func TestThreadChainStartsTheNextTurnOnce(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.FakeApprovalRate = 0
+	})
+	defer s.close()
+
+	startThread := func() string {
+		started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+		return started["thread"].(map[string]any)["id"].(string)
+	}
+	first, second := startThread(), startThread()
+
+	resp, view := doJSON(t, http.MethodPut, s.http.URL+"/api/thread/chain", map[string]any{"threadId": second, "after": first, "prompt": "Review this plan."})
+	if resp.StatusCode != http.StatusOK || view["chain"].(map[string]any)["after"] != first {
+		t.Fatalf("unexpected put: %d %v", resp.StatusCode, view)
+	}
+	resp, view = doJSON(t, http.MethodPut, s.http.URL+"/api/thread/chain", map[string]any{"threadId": first, "after": second})
+	details, _ := view["details"].(map[string]any)
+	if resp.StatusCode != http.StatusConflict || view["code"] != errCodeChainCycle || len(details["path"].([]any)) != 3 {
+		t.Fatalf("expected the loop refused: %d %v", resp.StatusCode, view)
+	}
+	resp, view = doJSON(t, http.MethodGet, s.http.URL+"/api/thread/chain?threadId="+first, nil)
+	if dependents, _ := view["dependents"].([]any); resp.StatusCode != http.StatusOK || len(dependents) != 1 || dependents[0] != second {
+		t.Fatalf("expected %s listed as a dependent: %v", second, view)
+	}
+
+	postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": first, "input": []any{map[string]any{"type": "text", "text": "plan it"}}})
+	var chained map[string]any
+	waitForCondition(t, 5*time.Second, 20*time.Millisecond, func() bool {
+		events, _ := s.store.Read(second)
+		for _, event := range events {
+			if strings.Contains(event, "darkhold/thread/chained") {
+				chained = parseJSON(t, event)["params"].(map[string]any)
+			}
+		}
+		return chained != nil
+	})
+	if chained["after"] != first || chained["afterTurnId"] == "" || chained["error"] != nil {
+		t.Fatalf("unexpected chained event: %v", chained)
+	}
+	waitForCondition(t, 5*time.Second, 20*time.Millisecond, func() bool {
+		events, _ := s.store.Read(second)
+		for _, event := range events {
+			if strings.Contains(event, `"turn/completed"`) {
+				return true
+			}
+		}
+		return false
+	})
+
+	resp, view = doJSON(t, http.MethodGet, s.http.URL+"/api/thread/chain", nil)
+	if chains, _ := view["chains"].([]any); resp.StatusCode != http.StatusOK || len(chains) != 0 {
+		t.Fatalf("expected the one-shot chain removed: %v", view)
+	}
+
+	text := chainedTurnText(threads.Chain{Prompt: "Review this plan."}, first, "the plan")
+	if !strings.HasPrefix(text, "Review this plan.\n\n<darkhold-chain") || !strings.Contains(text, "the plan\n</darkhold-chain>") {
+		t.Fatalf("unexpected chained input %q", text)
+	}
+}
+
+func TestChainedTurnWaitsForTheThreadLockAndCountsAgainstItsCreatorsBudget(t *testing.T) {
+	const internToken = "intern-token-0123456789"
+	const leadToken = "lead-token-0123456789"
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.FakeApprovalRate = 0
+		cfg.APIKeys = []config.APIKey{{Name: "intern", Token: internToken}, {Name: "lead", Token: leadToken}}
+		cfg.KeyBudgets = map[string]config.Budget{"intern": {TurnsPerDay: 1}}
+	})
+	defer s.close()
+
+	rpc := func(method string, params map[string]any) map[string]any {
+		t.Helper()
+		resp, body := doJSON(t, http.MethodPost, s.http.URL+"/api/rpc?access_token="+leadToken, map[string]any{"method": method, "params": params})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: %d %v", method, resp.StatusCode, body)
+		}
+		return body
+	}
+	startThread := func() string {
+		return rpc("thread/start", map[string]any{"cwd": s.baseDir})["thread"].(map[string]any)["id"].(string)
+	}
+	first, second := startThread(), startThread()
+	if resp, view := doJSON(t, http.MethodPut, s.http.URL+"/api/thread/chain?access_token="+internToken, map[string]any{"threadId": second, "after": first, "repeat": true}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected put: %d %v", resp.StatusCode, view)
+	}
+	chainedEvents := func() []map[string]any {
+		var chained []map[string]any
+		events, _ := s.store.Read(second)
+		for _, event := range events {
+			if strings.Contains(event, "darkhold/thread/chained") {
+				chained = append(chained, parseJSON(t, event)["params"].(map[string]any))
+			}
+		}
+		return chained
+	}
+	turnStarts := func() int {
+		events, _ := s.store.Read(second)
+		count := 0
+		for _, event := range events {
+			if strings.Contains(event, `"turn/started"`) {
+				count++
+			}
+		}
+		return count
+	}
+	runFirst := func() {
+		rpc("turn/start", map[string]any{"threadId": first, "input": []any{map[string]any{"type": "text", "text": "plan it"}}})
+	}
+
+	unlock, err := s.app.lockThread(context.Background(), second, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runFirst()
+	time.Sleep(300 * time.Millisecond)
+	if len(chainedEvents()) != 0 || turnStarts() != 0 {
+		t.Fatalf("expected the chained turn to wait for the thread lock, got %v", chainedEvents())
+	}
+	unlock()
+	waitForCondition(t, 5*time.Second, 20*time.Millisecond, func() bool { return len(chainedEvents()) == 1 })
+	if chained := chainedEvents()[0]; chained["error"] != nil {
+		t.Fatalf("expected the chained turn to start once the lock was free: %v", chained)
+	}
+	waitForCondition(t, 5*time.Second, 20*time.Millisecond, func() bool {
+		events, _ := s.store.Read(second)
+		return strings.Contains(strings.Join(events, "\n"), `"turn/completed"`)
+	})
+
+	runFirst()
+	waitForCondition(t, 5*time.Second, 20*time.Millisecond, func() bool { return len(chainedEvents()) == 2 })
+	if chained := chainedEvents()[1]; !strings.Contains(fmt.Sprint(chained["error"]), "budget") {
+		t.Fatalf("expected the chain creator's budget to refuse the second chained turn: %v", chained)
+	}
+	if turnStarts() != 1 {
+		t.Fatalf("expected one chained turn on %s, got %d", second, turnStarts())
+	}
+}
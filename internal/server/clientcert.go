@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// clientCertPrefix marks identities signed in with a client certificate.
+const clientCertPrefix = "cert:"
+
+// clientCertIdentity maps the verified client certificate of r to an identity
+// and its scopes. Each subject alternative name is looked up as given, then
+// DNS names against "*.domain" keys; the first hit wins. Certificates the
+// listener did not verify against --tls-client-ca are ignored.
+func (s *Server) clientCertIdentity(r *http.Request) (identity string, scopes []string, ok bool) {
+	if len(s.cfg.ClientCertScopes) == 0 || r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.PeerCertificates) == 0 {
+		return "", nil, false
+	}
+	leaf := r.TLS.PeerCertificates[0]
+	names := append([]string{}, leaf.DNSNames...)
+	names = append(names, leaf.EmailAddresses...)
+	for _, uri := range leaf.URIs {
+		names = append(names, uri.String())
+	}
+	for _, name := range names {
+		if scopes, ok := s.cfg.ClientCertScopes[name]; ok {
+			return clientCertPrefix + name, scopes, true
+		}
+	}
+	for _, name := range leaf.DNSNames {
+		if _, domain, found := strings.Cut(name, "."); found && domain != "" {
+			if scopes, ok := s.cfg.ClientCertScopes["*."+domain]; ok {
+				return clientCertPrefix + name, scopes, true
+			}
+		}
+	}
+	return "", nil, false
+}
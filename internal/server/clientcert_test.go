@@ -0,0 +1,107 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+// issueClientCert signs a client certificate for dnsName with parent, or
+// self-signs a CA when parent is nil.
+func issueClientCert(t *testing.T, parent *tls.Certificate, dnsName string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: dnsName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{dnsName},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, any(key)
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestClientCertificatesMapToScopes(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.APIKeys = []config.APIKey{{Name: "ops", Token: "0123456789abcdef"}}
+		cfg.ClientCertScopes = map[string][]string{"*.bots.example.ts.net": {"read"}}
+	})
+	defer s.close()
+	ca := issueClientCert(t, nil, "darkhold test CA")
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+	tlsServer := httptest.NewUnstartedServer(s.app.Handler())
+	tlsServer.TLS = &tls.Config{ClientCAs: pool, ClientAuth: tls.VerifyClientCertIfGiven}
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+
+	clientWith := func(certs ...tls.Certificate) *http.Client {
+		transport := tlsServer.Client().Transport.(*http.Transport).Clone()
+		transport.TLSClientConfig.Certificates = certs
+		return &http.Client{Transport: transport}
+	}
+	call := func(client *http.Client, method, path string) (int, map[string]any) {
+		t.Helper()
+		req, _ := http.NewRequest(method, tlsServer.URL+path, strings.NewReader(`{}`))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var body map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+
+	bot := clientWith(issueClientCert(t, &ca, "ci.bots.example.ts.net"))
+	if status, me := call(bot, http.MethodGet, "/api/auth/me"); status != http.StatusOK || me["identity"] != "cert:ci.bots.example.ts.net" || me["provider"] != "clientCert" {
+		t.Fatalf("expected the certificate to sign the bot in, got %d %v", status, me)
+	}
+	if status, _ := call(bot, http.MethodGet, "/api/threads"); status != http.StatusOK {
+		t.Fatalf("expected read access, got %d", status)
+	}
+	if status, body := call(bot, http.MethodPost, "/api/thread/create"); status != http.StatusForbidden || body["details"].(map[string]any)["scope"] != "write" {
+		t.Fatalf("expected writes to need the write scope, got %d %v", status, body)
+	}
+
+	// Unmapped names and certificates from other CAs are not identities.
+	for name, cert := range map[string]tls.Certificate{
+		"unmapped": issueClientCert(t, &ca, "laptop.example.ts.net"),
+		"foreign":  issueClientCert(t, nil, "ci.bots.example.ts.net"),
+	} {
+		if status, _ := call(clientWith(cert), http.MethodGet, "/api/threads"); status != http.StatusUnauthorized {
+			t.Fatalf("%s: expected the certificate to be refused, got %d", name, status)
+		}
+	}
+	if status, _ := call(clientWith(), http.MethodGet, "/api/threads"); status != http.StatusUnauthorized {
+		t.Fatalf("expected a certificate-less call without a key to be refused, got %d", status)
+	}
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"maps"
+	"net/http"
+
+	"darkhold-go/internal/events"
+	"darkhold-go/internal/threads"
+)
+
+// clusterInteractionsChannel carries interaction responses to the replica
+// that owns the waiting app-server session.
+const clusterInteractionsChannel = "interactions"
+
+// clusterStore is an event store shared by several darkhold replicas (the
+// Postgres backend). Replicas share the thread index through it and hear
+// about each other's events, so any replica can stream any thread.
+type clusterStore interface {
+	events.Storage
+	InstanceID() string
+	ThreadIndexBackend() threads.Backend
+	Listen(ctx context.Context, onEvent func(threadID string, record events.Record), onDocument func(name string), onMessage func(channel string, payload []byte))
+	Notify(ctx context.Context, channel string, payload []byte) error
+}
+
+// forwardedInteraction is an interaction response relayed between replicas.
+type forwardedInteraction struct {
+	ThreadID   string         `json:"threadId"`
+	RequestID  string         `json:"requestId"`
+	Result     any            `json:"result,omitempty"`
+	Error      any            `json:"error,omitempty"`
+	Resolution map[string]any `json:"resolution,omitempty"`
+}
+
+// startCluster subscribes to the other replicas until Shutdown.
+func (s *Server) startCluster(cluster clusterStore) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cluster = cluster
+	s.clusterStop = cancel
+	log.Printf("[cluster] joined as instance %s", cluster.InstanceID())
+	go cluster.Listen(ctx, s.relayClusterEvent, s.reloadClusterDocument, s.handleClusterMessage)
+}
+
+// relayClusterEvent pushes an event another replica stored to this replica's
+// SSE subscribers. Webhooks and MQTT are left to the replica that produced it.
+// With Redis fan-out the event already reached SSE subscribers that way.
+func (s *Server) relayClusterEvent(threadID string, record events.Record) {
+	if s.fanout == nil {
+		msg := newThreadEventMessage(record.ID, record.Payload)
+		if err := s.sseProvider.Publish(msg, []string{threadID}); err != nil {
+			log.Printf("[cluster] failed to broadcast event for thread %s: %v", threadID, err)
+		}
+	}
+	s.invalidateThreadEvent(threadID, record.Payload)
+}
+
+func (s *Server) reloadClusterDocument(name string) {
+	if err := s.threadIndex.Reload(); err != nil {
+		log.Printf("[cluster] failed to reload %s: %v", name, err)
+	}
+}
+
+func (s *Server) handleClusterMessage(channel string, payload []byte) {
+	if channel != clusterInteractionsChannel {
+		return
+	}
+	var fwd forwardedInteraction
+	if err := json.Unmarshal(payload, &fwd); err != nil {
+		return
+	}
+	// Every replica hears the message; only the one holding the request acts.
+	err := s.resolveInteraction(fwd.ThreadID, fwd.RequestID, fwd.Result, fwd.Error, fwd.Resolution)
+	switch {
+	case errors.Is(err, errInteractionNotFound):
+	case err != nil:
+		log.Printf("[cluster] failed to resolve forwarded request %s on thread %s: %v", fwd.RequestID, fwd.ThreadID, err)
+	default:
+		log.Printf("[cluster] resolved forwarded request %s on thread %s", fwd.RequestID, fwd.ThreadID)
+	}
+}
+
+// interactionOpenInLog reports whether the shared log holds a request that
+// has not been resolved yet, i.e. one pending on another replica.
+func (s *Server) interactionOpenInLog(threadID, requestID string) bool {
+	records, err := s.eventStore.ReadRange(threadID, "", 0)
+	if err != nil {
+		return false
+	}
+	open := false
+	for _, record := range records {
+		var event struct {
+			Method string `json:"method"`
+			Params struct {
+				RequestID string `json:"requestId"`
+			} `json:"params"`
+		}
+		if json.Unmarshal([]byte(record.Payload), &event) != nil || event.Params.RequestID != requestID {
+			continue
+		}
+		switch event.Method {
+		case "darkhold/interaction/request":
+			open = true
+		case "darkhold/interaction/resolved":
+			open = false
+		}
+	}
+	return open
+}
+
+// resolveInteractionAnywhere resolves an interaction held by this replica or,
+// in a cluster, forwards the response to the replica that holds it. It
+// reports whether the response was forwarded; forwarded responses are
+// resolved asynchronously and appear as darkhold/interaction/resolved.
+func (s *Server) resolveInteractionAnywhere(ctx context.Context, threadID, requestID string, result, rpcErr any, resolution map[string]any) (bool, error) {
+	err := s.resolveInteraction(threadID, requestID, result, rpcErr, resolution)
+	if s.cluster == nil || !errors.Is(err, errInteractionNotFound) || !s.interactionOpenInLog(threadID, requestID) {
+		return false, err
+	}
+	payload, _ := json.Marshal(forwardedInteraction{
+		ThreadID:   threadID,
+		RequestID:  requestID,
+		Result:     result,
+		Error:      rpcErr,
+		Resolution: resolution,
+	})
+	if err := s.cluster.Notify(ctx, clusterInteractionsChannel, payload); err != nil {
+		log.Printf("[cluster] failed to forward request %s on thread %s: %v", requestID, threadID, err)
+		return false, errSessionUnavailable
+	}
+	return true, nil
+}
+
+// writeInteractionForwarded answers a response handed to another replica.
+func writeInteractionForwarded(w http.ResponseWriter, extra map[string]any) {
+	body := map[string]any{"ok": true, "forwarded": true}
+	maps.Copy(body, extra)
+	writeJSON(w, http.StatusAccepted, body)
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+	"darkhold-go/internal/events"
+	"darkhold-go/internal/threads"
+)
+
+// fakeCluster is one replica's view of a shared in-memory store; replicas
+// created from the same hub see each other's events, documents and messages.
+type fakeCluster struct {
+	*events.Memory
+	hub *fakeClusterHub
+	id  string
+}
+
+type fakeClusterHub struct {
+	store *events.Memory
+
+	mu        sync.Mutex
+	document  []byte
+	listeners map[string]fakeClusterListener
+}
+
+type fakeClusterListener struct {
+	onEvent    func(string, events.Record)
+	onDocument func(string)
+	onMessage  func(string, []byte)
+}
+
+func newFakeClusterHub() *fakeClusterHub {
+	return &fakeClusterHub{store: events.NewMemory(), listeners: map[string]fakeClusterListener{}}
+}
+
+func (h *fakeClusterHub) replica(id string) *fakeCluster {
+	return &fakeCluster{Memory: h.store, hub: h, id: id}
+}
+
+func (h *fakeClusterHub) others(origin string) []fakeClusterListener {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := []fakeClusterListener{}
+	for id, l := range h.listeners {
+		if id != origin {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+func (c *fakeCluster) InstanceID() string { return c.id }
+
+func (c *fakeCluster) Append(threadID, payload string) (events.Record, error) {
+	record, err := c.Memory.Append(threadID, payload)
+	if err == nil {
+		for _, l := range c.hub.others(c.id) {
+			l.onEvent(threadID, record)
+		}
+	}
+	return record, err
+}
+
+func (c *fakeCluster) ThreadIndexBackend() threads.Backend { return fakeClusterDocument{c} }
+
+func (c *fakeCluster) Listen(ctx context.Context, onEvent func(string, events.Record), onDocument func(string), onMessage func(string, []byte)) {
+	c.hub.mu.Lock()
+	c.hub.listeners[c.id] = fakeClusterListener{onEvent, onDocument, onMessage}
+	c.hub.mu.Unlock()
+	<-ctx.Done()
+	c.hub.mu.Lock()
+	delete(c.hub.listeners, c.id)
+	c.hub.mu.Unlock()
+}
+
+func (c *fakeCluster) Notify(ctx context.Context, channel string, payload []byte) error {
+	for _, l := range c.hub.others(c.id) {
+		go l.onMessage(channel, payload)
+	}
+	return nil
+}
+
+type fakeClusterDocument struct{ c *fakeCluster }
+
+func (d fakeClusterDocument) Load() ([]byte, error) {
+	d.c.hub.mu.Lock()
+	defer d.c.hub.mu.Unlock()
+	return d.c.hub.document, nil
+}
+
+func (d fakeClusterDocument) Save(data []byte) error {
+	d.c.hub.mu.Lock()
+	d.c.hub.document = append([]byte(nil), data...)
+	d.c.hub.mu.Unlock()
+	for _, l := range d.c.hub.others(d.c.id) {
+		go l.onDocument("threads")
+	}
+	return nil
+}
+
+func TestClusterReplicasShareThreadsAndApprovals(t *testing.T) {
+	if !canUseLoopbackSockets() {
+		t.Skip("loopback sockets are not available in this environment")
+	}
+	hub := newFakeClusterHub()
+	replica := func(id string) (*Server, *httptest.Server) {
+		cfg := config.Config{Bind: "127.0.0.1", Port: 0, AgentCmd: config.FakeAgentCmd, FakeApprovalRate: 1}
+		app := New(cfg, hub.replica(id))
+		return app, httptest.NewServer(app.Handler())
+	}
+	appA, httpA := replica("a")
+	defer httpA.Close()
+	defer appA.Shutdown(context.Background())
+	appB, httpB := replica("b")
+	defer httpB.Close()
+	defer appB.Shutdown(context.Background())
+	waitForCondition(t, 2*time.Second, 10*time.Millisecond, func() bool { return len(hub.others("")) == 2 })
+
+	started := postRPC[map[string]any](t, httpA.URL, "thread/start", map[string]any{"cwd": t.TempDir()})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	streamB := openSSE(t, httpB.URL, threadID, "")
+	defer streamB.Body.Close()
+
+	// The turn runs on replica A; the approval arrives and is answered on B.
+	_ = postRPC[map[string]any](t, httpA.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "hello"}}})
+	event := waitForSSEEvent(t, streamB, func(event sseEvent) bool {
+		return parseJSON(t, event.Data)["method"] == "darkhold/interaction/request"
+	}, 10*time.Second)
+	requestID := parseJSON(t, event.Data)["params"].(map[string]any)["requestId"].(string)
+	resp, payload := doJSON(t, http.MethodPost, httpB.URL+"/api/thread/interaction/respond", map[string]any{"threadId": threadID, "requestId": requestID, "result": map[string]any{"decision": "accept"}})
+	if resp.StatusCode != http.StatusAccepted || payload["forwarded"] != true {
+		t.Fatalf("expected response to be forwarded, got %d %v", resp.StatusCode, payload)
+	}
+	waitForSSEEvent(t, streamB, func(event sseEvent) bool {
+		return parseJSON(t, event.Data)["method"] == "turn/completed"
+	}, 10*time.Second)
+
+	resp, _ = doJSON(t, http.MethodPost, httpB.URL+"/api/thread/interaction/respond", map[string]any{"threadId": threadID, "requestId": requestID, "result": map[string]any{}})
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected resolved interaction to conflict on every replica, got %d", resp.StatusCode)
+	}
+
+	if _, err := appB.threadIndex.Update(threadID, func(m *threads.Metadata) error {
+		m.Title = "shared"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	waitForCondition(t, 2*time.Second, 10*time.Millisecond, func() bool {
+		meta, _ := appA.threadIndex.Get(threadID)
+		return meta.Title == "shared"
+	})
+}
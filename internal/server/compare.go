@@ -0,0 +1,253 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxCompareAgentText bounds the agent reply kept per turn summary; the full
+// text is available from thread/read.
+const maxCompareAgentText = 2000
+
+type compareFileChange struct {
+	Path string `json:"path"`
+	Kind string `json:"kind,omitempty"`
+	Diff string `json:"diff"`
+}
+
+type compareTurn struct {
+	Index       int                 `json:"index"`
+	TurnID      string              `json:"turnId,omitempty"`
+	Status      string              `json:"status,omitempty"`
+	UserText    string              `json:"userText"`
+	AgentText   string              `json:"agentText"`
+	Commands    []string            `json:"commands"`
+	FileChanges []compareFileChange `json:"fileChanges"`
+}
+
+type compareTurnPair struct {
+	Index     int          `json:"index"`
+	Left      *compareTurn `json:"left"`
+	Right     *compareTurn `json:"right"`
+	Identical bool         `json:"identical"`
+}
+
+type compareFileSide struct {
+	Kind string `json:"kind,omitempty"`
+	Diff string `json:"diff"`
+}
+
+type compareFile struct {
+	Path  string           `json:"path"`
+	Left  *compareFileSide `json:"left"`
+	Right *compareFileSide `json:"right"`
+	Same  bool             `json:"same"`
+}
+
+type compareThreadInfo struct {
+	ThreadID  string `json:"threadId"`
+	TurnCount int    `json:"turnCount"`
+}
+
+type threadComparison struct {
+	Left  compareThreadInfo `json:"left"`
+	Right compareThreadInfo `json:"right"`
+	// DivergedAt is the index of the first turn pair that differs, or nil when
+	// one thread is a prefix of the other.
+	DivergedAt *int              `json:"divergedAt"`
+	Turns      []compareTurnPair `json:"turns"`
+	Files      []compareFile     `json:"files"`
+}
+
+func (s *Server) handleThreadCompare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	leftID := strings.TrimSpace(r.URL.Query().Get("left"))
+	rightID := strings.TrimSpace(r.URL.Query().Get("right"))
+	if leftID == "" || rightID == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "left and right thread IDs are required.")
+		return
+	}
+	turnsByID := map[string][]any{}
+	for _, threadID := range []string{leftID, rightID} {
+		if _, done := turnsByID[threadID]; done {
+			continue
+		}
+		response, err := s.dispatchRPC(r.Context(), "thread/read", map[string]any{"threadId": threadID, "includeTurns": true})
+		if err != nil {
+			writeDispatchError(w, err)
+			return
+		}
+		if errObj, ok := response["error"].(map[string]any); ok {
+			writeUpstreamRPCError(w, errObj)
+			return
+		}
+		result, _ := response["result"].(map[string]any)
+		threadObj, _ := result["thread"].(map[string]any)
+		turns, _ := threadObj["turns"].([]any)
+		turnsByID[threadID] = turns
+	}
+	writeJSON(w, http.StatusOK, compareThreads(leftID, turnsByID[leftID], rightID, turnsByID[rightID]))
+}
+
+// compareThreads aligns two threads' turns by position and groups their file
+// changes by path.
+func compareThreads(leftID string, leftTurns []any, rightID string, rightTurns []any) threadComparison {
+	left := summarizeCompareTurns(leftTurns)
+	right := summarizeCompareTurns(rightTurns)
+	out := threadComparison{
+		Left:  compareThreadInfo{ThreadID: leftID, TurnCount: len(left)},
+		Right: compareThreadInfo{ThreadID: rightID, TurnCount: len(right)},
+		Turns: []compareTurnPair{},
+		Files: []compareFile{},
+	}
+	for i := 0; i < max(len(left), len(right)); i++ {
+		pair := compareTurnPair{Index: i}
+		if i < len(left) {
+			pair.Left = &left[i]
+		}
+		if i < len(right) {
+			pair.Right = &right[i]
+		}
+		pair.Identical = pair.Left != nil && pair.Right != nil && sameCompareTurn(*pair.Left, *pair.Right)
+		if !pair.Identical && out.DivergedAt == nil && pair.Left != nil && pair.Right != nil {
+			index := i
+			out.DivergedAt = &index
+		}
+		out.Turns = append(out.Turns, pair)
+	}
+
+	files := map[string]*compareFile{}
+	collect := func(turns []compareTurn, side func(*compareFile) **compareFileSide) {
+		for _, turn := range turns {
+			for _, change := range turn.FileChanges {
+				file := files[change.Path]
+				if file == nil {
+					file = &compareFile{Path: change.Path}
+					files[change.Path] = file
+				}
+				slot := side(file)
+				if *slot == nil {
+					*slot = &compareFileSide{}
+				}
+				(*slot).Kind = change.Kind
+				if (*slot).Diff != "" {
+					(*slot).Diff += "\n"
+				}
+				(*slot).Diff += change.Diff
+			}
+		}
+	}
+	collect(left, func(f *compareFile) **compareFileSide { return &f.Left })
+	collect(right, func(f *compareFile) **compareFileSide { return &f.Right })
+	for _, file := range files {
+		file.Same = file.Left != nil && file.Right != nil && *file.Left == *file.Right
+		out.Files = append(out.Files, *file)
+	}
+	sort.Slice(out.Files, func(i, j int) bool { return out.Files[i].Path < out.Files[j].Path })
+	return out
+}
+
+func sameCompareTurn(a, b compareTurn) bool {
+	if a.UserText != b.UserText || a.AgentText != b.AgentText || len(a.FileChanges) != len(b.FileChanges) {
+		return false
+	}
+	for i := range a.FileChanges {
+		if a.FileChanges[i] != b.FileChanges[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func summarizeCompareTurns(turns []any) []compareTurn {
+	out := make([]compareTurn, 0, len(turns))
+	for index, raw := range turns {
+		turnObj, _ := raw.(map[string]any)
+		turn := compareTurn{Index: index, Commands: []string{}, FileChanges: []compareFileChange{}}
+		turn.TurnID, _ = turnObj["id"].(string)
+		turn.Status, _ = turnObj["status"].(string)
+		items, _ := turnObj["items"].([]any)
+		var userParts []string
+		for _, rawItem := range items {
+			item, _ := rawItem.(map[string]any)
+			switch item["type"] {
+			case "userMessage":
+				content, _ := item["content"].([]any)
+				for _, rawEntry := range content {
+					entry, _ := rawEntry.(map[string]any)
+					if text, ok := entry["text"].(string); ok && entry["type"] == "text" {
+						userParts = append(userParts, text)
+					}
+				}
+			case "agentMessage":
+				if text, ok := item["text"].(string); ok {
+					turn.AgentText = truncateRunes(text, maxCompareAgentText)
+				}
+			case "commandExecution":
+				if command, ok := item["command"].(string); ok {
+					turn.Commands = append(turn.Commands, command)
+				}
+			case "fileChange":
+				changes, _ := item["changes"].([]any)
+				for i, rawChange := range changes {
+					change, _ := rawChange.(map[string]any)
+					turn.FileChanges = append(turn.FileChanges, normalizeFileChange(change, i))
+				}
+			}
+		}
+		turn.UserText = strings.TrimSpace(strings.Join(userParts, "\n"))
+		out = append(out, turn)
+	}
+	return out
+}
+
+// normalizeFileChange mirrors the web client's diff extraction so both agree
+// on what a change's path and diff are. A change that only carries the
+// file's before and after text gets a unified diff computed from them.
+func normalizeFileChange(change map[string]any, index int) compareFileChange {
+	out := compareFileChange{}
+	for _, key := range []string{"path", "filePath", "file_path", "relativePath", "filename"} {
+		if value, ok := change[key].(string); ok && strings.TrimSpace(value) != "" {
+			out.Path = value
+			break
+		}
+	}
+	if out.Path == "" {
+		out.Path = "change-" + strconv.Itoa(index+1)
+	}
+	switch kind := change["kind"].(type) {
+	case string:
+		out.Kind = kind
+	case map[string]any:
+		out.Kind, _ = kind["type"].(string)
+	}
+	for _, key := range []string{"diff", "patch", "unifiedDiff", "unified_diff", "content"} {
+		if value, ok := change[key].(string); ok && strings.TrimSpace(value) != "" {
+			out.Diff = value
+			return out
+		}
+	}
+	before, _ := change["before"].(string)
+	after, _ := change["after"].(string)
+	if before != "" || after != "" {
+		out.Diff = unifiedDiff(before, after)
+		return out
+	}
+	encoded, _ := json.MarshalIndent(change, "", "  ")
+	out.Diff = string(encoded)
+	return out
+}
+
+func truncateRunes(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "…"
+}
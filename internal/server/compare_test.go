@@ -0,0 +1,62 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func compareTestTurn(user, agent string, changes ...map[string]any) any {
+	items := []any{
+		map[string]any{"type": "userMessage", "content": []any{map[string]any{"type": "text", "text": user}}},
+		map[string]any{"type": "agentMessage", "text": agent},
+	}
+	if len(changes) > 0 {
+		raw := make([]any, 0, len(changes))
+		for _, change := range changes {
+			raw = append(raw, change)
+		}
+		items = append(items, map[string]any{"type": "fileChange", "changes": raw})
+	}
+	return map[string]any{"id": "turn", "status": "completed", "items": items}
+}
+
+func TestCompareThreadsAlignsTurnsAndFiles(t *testing.T) {
+	shared := compareTestTurn("add a cache", "planning", map[string]any{"path": "go.mod", "kind": "update", "diff": "+require x"})
+	parent := []any{shared, compareTestTurn("use an LRU", "done", map[string]any{"path": "cache.go", "kind": "add", "diff": "+lru"})}
+	fork := []any{shared, compareTestTurn("use a TTL map", "done", map[string]any{"path": "cache.go", "kind": "add", "diff": "+ttl"}), compareTestTurn("add tests", "ok")}
+
+	got := compareThreads("parent", parent, "fork", fork)
+	if got.Left.TurnCount != 2 || got.Right.TurnCount != 3 || len(got.Turns) != 3 {
+		t.Fatalf("unexpected alignment: %+v", got)
+	}
+	if !got.Turns[0].Identical || got.Turns[1].Identical || got.Turns[2].Left != nil {
+		t.Fatalf("unexpected turn pairs: %+v", got.Turns)
+	}
+	if got.DivergedAt == nil || *got.DivergedAt != 1 {
+		t.Fatalf("expected divergence at turn 1, got %v", got.DivergedAt)
+	}
+	if len(got.Files) != 2 || got.Files[0].Path != "cache.go" || got.Files[0].Same || got.Files[0].Right.Diff != "+ttl" {
+		t.Fatalf("unexpected files: %+v", got.Files)
+	}
+	if got.Files[1].Path != "go.mod" || !got.Files[1].Same {
+		t.Fatalf("expected go.mod to match on both sides: %+v", got.Files[1])
+	}
+}
+
+func TestThreadCompareEndpoint(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+
+	resp, payload := doJSON(t, http.MethodGet, s.http.URL+"/api/thread/compare?left="+threadID+"&right="+threadID, nil)
+	if resp.StatusCode != http.StatusOK || payload["left"].(map[string]any)["threadId"] != threadID || payload["divergedAt"] != nil {
+		t.Fatalf("unexpected comparison: %d %v", resp.StatusCode, payload)
+	}
+
+	resp, payload = doJSON(t, http.MethodGet, s.http.URL+"/api/thread/compare?left="+threadID, nil)
+	if resp.StatusCode != http.StatusBadRequest || payload["code"] != errCodeInvalidRequest {
+		t.Fatalf("expected missing right thread to be rejected: %d %v", resp.StatusCode, payload)
+	}
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	browserfs "darkhold-go/internal/fs"
+)
+
+// Confinement levels attached to exec interaction requests.
+const (
+	confinementOK      = "ok"
+	confinementWarning = "warning"
+)
+
+// confinementCheck reports whether an exec approval stays inside the browser
+// root. Outside lists the cwd and referenced paths that fall outside it.
+type confinementCheck struct {
+	Level   string   `json:"level"`
+	Root    string   `json:"root"`
+	Cwd     string   `json:"cwd,omitempty"`
+	Outside []string `json:"outside,omitempty"`
+}
+
+// confinementExemptPaths are outside every root but harmless to reference.
+var confinementExemptPaths = map[string]bool{
+	"/dev/null":   true,
+	"/dev/stdin":  true,
+	"/dev/stdout": true,
+	"/dev/stderr": true,
+}
+
+// checkConfinement inspects an exec approval's cwd (falling back to the
+// thread's cwd) and the paths its command references. It returns nil for
+// requests without a command.
+func (s *Server) checkConfinement(threadID string, params map[string]any) *confinementCheck {
+	command := approvalCommandText(params)
+	if command == "" {
+		return nil
+	}
+	cwd, _ := params["cwd"].(string)
+	if cwd == "" {
+		meta, _ := s.threadIndex.Get(threadID)
+		cwd = meta.Cwd
+	}
+	check := &confinementCheck{Level: confinementOK, Root: browserfs.GetHomeRoot(), Cwd: cwd}
+	if cwd != "" && !browserfs.IsWithinRoot(cwd) {
+		check.Outside = append(check.Outside, cwd)
+	}
+	for _, path := range commandPaths(command, cwd) {
+		if !confinementExemptPaths[path] && !browserfs.IsWithinRoot(path) {
+			check.Outside = append(check.Outside, path)
+		}
+	}
+	if len(check.Outside) > 0 {
+		check.Level = confinementWarning
+	}
+	return check
+}
+
+// commandPaths picks the path arguments out of a shell command: absolute
+// paths, ~ paths, and relative paths that climb with "..", resolved against
+// cwd. Program names (the first word of each pipeline stage) are skipped so
+// "/usr/bin/env" alone is not flagged. This is a heuristic, not a shell
+// parser.
+func commandPaths(command, cwd string) []string {
+	home, _ := os.UserHomeDir()
+	var paths []string
+	programNext := true
+	for field := range strings.FieldsSeq(command) {
+		switch field {
+		case "|", "||", "&&", ";", "&":
+			programNext = true
+			continue
+		}
+		isProgram := programNext
+		programNext = strings.HasSuffix(field, ";")
+		if isProgram {
+			if !strings.Contains(field, "=") {
+				continue
+			}
+			// VAR=value prefixes keep the program still to come.
+			programNext = true
+		}
+		token := strings.Trim(field, "\"'`();|&<>")
+		if eq := strings.LastIndexByte(token, '='); eq >= 0 {
+			token = token[eq+1:]
+		}
+		var path string
+		switch {
+		case token == "~" || strings.HasPrefix(token, "~/"):
+			if home == "" {
+				continue
+			}
+			path = filepath.Join(home, strings.TrimPrefix(token, "~"))
+		case filepath.IsAbs(token):
+			path = token
+		case cwd != "" && (token == ".." || strings.HasPrefix(token, "../") || strings.Contains(token, "/../")):
+			path = filepath.Join(cwd, token)
+		default:
+			continue
+		}
+		paths = append(paths, filepath.Clean(path))
+	}
+	return paths
+}
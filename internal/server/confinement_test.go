@@ -0,0 +1,69 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestCommandPaths(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	got := commandPaths(`/usr/bin/env FOO=/opt/x make -C ../other --out=/tmp/a.log "~/notes.txt" > /dev/null; cat src/main.go | tee ./a/../../b`, "/work/repo")
+	want := []string{"/opt/x", "/work/other", "/tmp/a.log", filepath.Join(home, "notes.txt"), "/dev/null", "/work/b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("commandPaths = %v, want %v", got, want)
+	}
+}
+
+func TestExecApprovalsCarryConfinement(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	stream := openSSE(t, s.http.URL, threadID, "")
+	defer stream.Body.Close()
+	_ = postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "run it"}}})
+	request := waitForSSEEvent(t, stream, func(event sseEvent) bool {
+		return parseJSON(t, event.Data)["method"] == "darkhold/interaction/request"
+	}, 10*time.Second)
+	params, _ := parseJSON(t, request.Data)["params"].(map[string]any)
+	check, _ := params["confinement"].(map[string]any)
+	if check["level"] != confinementOK || check["outside"] != nil {
+		t.Fatalf("expected an in-root command to pass, got %v", params)
+	}
+
+	withOutside := s.app.checkConfinement(threadID, map[string]any{"command": "cp secrets.env /srv/share/", "cwd": s.baseDir})
+	if withOutside.Level != confinementWarning || !reflect.DeepEqual(withOutside.Outside, []string{"/srv/share"}) {
+		t.Fatalf("unexpected check %+v", withOutside)
+	}
+}
+
+func TestOutsideRootApprovalsCanBeRejected(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) { cfg.RejectOutsideRoot = true })
+	defer s.close()
+
+	const threadID = "thread-confined"
+	sess, stdin := injectSession(s.app, 903)
+	defer removeSession(s.app, sess)
+	line, _ := json.Marshal(map[string]any{
+		"id":     11,
+		"method": "item/commandExecution/requestApproval",
+		"params": map[string]any{"threadId": threadID, "turnId": "turn-1", "itemId": "call-1", "command": "cat /etc/passwd", "cwd": s.baseDir},
+	})
+	s.app.handleSessionLine(sess, string(line))
+	waitForCondition(t, 5*time.Second, 10*time.Millisecond, func() bool {
+		return strings.Contains(stdin.String(), `"id":11`)
+	})
+	events, _ := s.store.Read(threadID)
+	joined := strings.Join(events, "\n")
+	if strings.Contains(joined, "darkhold/interaction/request") || !strings.Contains(joined, `"rule":"outside-root"`) || !strings.Contains(joined, "/etc/passwd") {
+		t.Fatalf("expected an outside-root violation, got %s", joined)
+	}
+}
@@ -0,0 +1,376 @@
+package server
+
+import (
+	"encoding/json"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	browserfs "darkhold-go/internal/fs"
+	"darkhold-go/internal/netfs"
+)
+
+const (
+	// cwdWatchQuiet is how long a watched cwd must go without changes before
+	// the batch so far is published; cwdWatchMaxDelay bounds the wait while
+	// changes keep coming.
+	cwdWatchQuiet    = 250 * time.Millisecond
+	cwdWatchMaxDelay = time.Second
+	// cwdWatchMaxDirs caps the directories watched per thread, since each
+	// costs an inotify watch (or file handle elsewhere).
+	cwdWatchMaxDirs = 2000
+	// cwdWatchMaxChanges caps the paths in one event; the rest are dropped
+	// and the event is marked truncated.
+	cwdWatchMaxChanges = 200
+
+	// A cwd on a network filesystem is polled instead: inotify there only
+	// sees changes made by this host, not by an agent or editor on another.
+	// Each scan is a walk of the tree, so it runs every cwdPollInterval and
+	// a batch is published after a scan that finds nothing new, or after
+	// cwdPollMaxDelay while changes keep coming. cwdPollMaxEntries caps the
+	// files and directories one scan records.
+	cwdPollInterval   = 2 * time.Second
+	cwdPollMaxDelay   = 10 * time.Second
+	cwdPollMaxEntries = 50000
+)
+
+// cwdWatchSkipDirs are not watched: they change constantly and are rarely
+// what a user wants to see appear.
+var cwdWatchSkipDirs = map[string]bool{".git": true, "node_modules": true}
+
+// cwdWatch follows one thread's cwd while a turn is running, with fsnotify
+// or, when watcher is nil, by polling.
+type cwdWatch struct {
+	threadID string
+	turnID   string
+	root     string
+	watcher  *fsnotify.Watcher
+	dirs     int
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// startCwdWatch starts watching the thread's cwd for the turn that just
+// started, when the thread has watchFiles on and its cwd is inside the
+// browser root.
+func (s *Server) startCwdWatch(threadID string, params map[string]any) {
+	meta, ok := s.threadIndex.Get(threadID)
+	if !ok || !meta.WatchFiles || meta.Cwd == "" {
+		return
+	}
+	root, err := browserfs.ResolvePath(meta.Cwd)
+	if err != nil {
+		return
+	}
+	turnID, _ := params["turnId"].(string)
+	if turn, ok := params["turn"].(map[string]any); ok && turnID == "" {
+		turnID, _ = turn["id"].(string)
+	}
+
+	s.cwdWatchMu.Lock()
+	defer s.cwdWatchMu.Unlock()
+	if s.cwdWatches == nil {
+		s.cwdWatches = map[string]*cwdWatch{}
+	}
+	if existing := s.cwdWatches[threadID]; existing != nil {
+		existing.turnID = turnID
+		return
+	}
+	if netfs.Detect(root).Network {
+		watch := &cwdWatch{threadID: threadID, turnID: turnID, root: root, stop: make(chan struct{}), done: make(chan struct{})}
+		s.cwdWatches[threadID] = watch
+		go s.runCwdPoll(watch)
+		return
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("[fs-watch] failed to watch %s for thread %s: %v", root, threadID, err)
+		return
+	}
+	watch := &cwdWatch{threadID: threadID, turnID: turnID, root: root, watcher: watcher, stop: make(chan struct{}), done: make(chan struct{})}
+	watch.addTree(root)
+	s.cwdWatches[threadID] = watch
+	go s.runCwdWatch(watch)
+}
+
+// stopCwdWatch stops the thread's watcher, publishing what it had batched.
+func (s *Server) stopCwdWatch(threadID string) {
+	s.cwdWatchMu.Lock()
+	watch := s.cwdWatches[threadID]
+	delete(s.cwdWatches, threadID)
+	s.cwdWatchMu.Unlock()
+	if watch != nil {
+		close(watch.stop)
+		<-watch.done
+	}
+}
+
+// stopCwdWatches stops every watcher, for shutdown.
+func (s *Server) stopCwdWatches() {
+	s.cwdWatchMu.Lock()
+	threadIDs := make([]string, 0, len(s.cwdWatches))
+	for threadID := range s.cwdWatches {
+		threadIDs = append(threadIDs, threadID)
+	}
+	s.cwdWatchMu.Unlock()
+	for _, threadID := range threadIDs {
+		s.stopCwdWatch(threadID)
+	}
+}
+
+// addTree watches dir and the directories below it, up to cwdWatchMaxDirs.
+// Symlinked directories are not followed.
+func (w *cwdWatch) addTree(dir string) {
+	_ = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		if path != dir && cwdWatchSkipDirs[entry.Name()] {
+			return filepath.SkipDir
+		}
+		if w.dirs >= cwdWatchMaxDirs {
+			return filepath.SkipAll
+		}
+		if err := w.watcher.Add(path); err != nil {
+			return filepath.SkipDir
+		}
+		w.dirs++
+		if w.dirs == cwdWatchMaxDirs {
+			log.Printf("[fs-watch] thread %s: watching the first %d directories only", w.threadID, cwdWatchMaxDirs)
+		}
+		return nil
+	})
+}
+
+func (s *Server) runCwdWatch(w *cwdWatch) {
+	defer close(w.done)
+	defer w.watcher.Close()
+
+	changes := map[string]string{}
+	var quiet, deadline <-chan time.Time
+	flush := func() {
+		quiet, deadline = nil, nil
+		if len(changes) > 0 {
+			s.publishCwdChanges(w, changes)
+			changes = map[string]string{}
+		}
+	}
+	for {
+		select {
+		case <-w.stop:
+			flush()
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				flush()
+				return
+			}
+			change := cwdChangeType(event.Op)
+			if change == "" || w.skipped(event.Name) {
+				continue
+			}
+			if change == "created" {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					w.addTree(event.Name)
+				}
+			}
+			mergeCwdChange(changes, event.Name, change)
+			quiet = time.After(cwdWatchQuiet)
+			if deadline == nil {
+				deadline = time.After(cwdWatchMaxDelay)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				flush()
+				return
+			}
+			log.Printf("[fs-watch] thread %s: %v", w.threadID, err)
+		case <-quiet:
+			flush()
+		case <-deadline:
+			flush()
+		}
+	}
+}
+
+// cwdPollEntry is what a poll scan records about one path.
+type cwdPollEntry struct {
+	dir     bool
+	size    int64
+	modTime time.Time
+}
+
+// runCwdPoll follows a cwd on a network filesystem by comparing scans of it.
+// Stopping takes one last scan, so changes made late in the turn are not
+// lost.
+func (s *Server) runCwdPoll(w *cwdWatch) {
+	defer close(w.done)
+	ticker := time.NewTicker(cwdPollInterval)
+	defer ticker.Stop()
+
+	snapshot := w.scan()
+	changes := map[string]string{}
+	var pendingSince time.Time
+	for {
+		stopping := false
+		select {
+		case <-w.stop:
+			stopping = true
+		case <-ticker.C:
+		}
+		next := w.scan()
+		found := diffCwdSnapshots(snapshot, next, changes)
+		snapshot = next
+		if found && pendingSince.IsZero() {
+			pendingSince = time.Now()
+		}
+		if len(changes) > 0 && (stopping || !found || time.Since(pendingSince) >= cwdPollMaxDelay) {
+			s.publishCwdChanges(w, changes)
+			changes = map[string]string{}
+			pendingSince = time.Time{}
+		}
+		if stopping {
+			return
+		}
+	}
+}
+
+// scan records every file and directory below the cwd, skipping the
+// directories a watch skips and stopping at cwdWatchMaxDirs directories or
+// cwdPollMaxEntries entries. Symlinks are recorded but not followed.
+func (w *cwdWatch) scan() map[string]cwdPollEntry {
+	entries := map[string]cwdPollEntry{}
+	dirs := 0
+	_ = filepath.WalkDir(w.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if len(entries) >= cwdPollMaxEntries {
+			return filepath.SkipAll
+		}
+		if entry.IsDir() {
+			if path != w.root && cwdWatchSkipDirs[entry.Name()] {
+				return filepath.SkipDir
+			}
+			if dirs >= cwdWatchMaxDirs {
+				return filepath.SkipDir
+			}
+			dirs++
+		}
+		if path == w.root {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		entries[path] = cwdPollEntry{dir: entry.IsDir(), size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return entries
+}
+
+// diffCwdSnapshots folds the differences between two scans into changes and
+// reports whether there were any. Directories are only reported created or
+// removed, as with fsnotify.
+func diffCwdSnapshots(before, after map[string]cwdPollEntry, changes map[string]string) bool {
+	found := false
+	for path, entry := range after {
+		old, ok := before[path]
+		switch {
+		case !ok:
+			mergeCwdChange(changes, path, "created")
+		case old.dir != entry.dir:
+			mergeCwdChange(changes, path, "removed")
+			mergeCwdChange(changes, path, "created")
+		case !entry.dir && (old.size != entry.size || !old.modTime.Equal(entry.modTime)):
+			mergeCwdChange(changes, path, "modified")
+		default:
+			continue
+		}
+		found = true
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			mergeCwdChange(changes, path, "removed")
+			found = true
+		}
+	}
+	return found
+}
+
+// cwdChangeType maps an fsnotify op to the change type clients see. A
+// rename reports the old path as removed; the new one arrives as created.
+// Permission changes are not reported.
+func cwdChangeType(op fsnotify.Op) string {
+	switch {
+	case op.Has(fsnotify.Create):
+		return "created"
+	case op.Has(fsnotify.Remove), op.Has(fsnotify.Rename):
+		return "removed"
+	case op.Has(fsnotify.Write):
+		return "modified"
+	}
+	return ""
+}
+
+// skipped reports whether path is, or lies in, a directory below the cwd
+// that is never watched, for events about the skipped directory itself.
+func (w *cwdWatch) skipped(path string) bool {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil {
+		return true
+	}
+	for part := range strings.SplitSeq(filepath.ToSlash(rel), "/") {
+		if cwdWatchSkipDirs[part] {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeCwdChange folds a change into the batch: a path created and then
+// changed stays created, one created and removed again is dropped, and one
+// removed and recreated counts as modified.
+func mergeCwdChange(changes map[string]string, path, change string) {
+	switch previous := changes[path]; {
+	case previous == "created" && change == "modified":
+	case previous == "created" && change == "removed":
+		delete(changes, path)
+	case previous == "removed" && change == "created":
+		changes[path] = "modified"
+	default:
+		changes[path] = change
+	}
+}
+
+func (s *Server) publishCwdChanges(w *cwdWatch, changes map[string]string) {
+	paths := make([]string, 0, len(changes))
+	for path := range changes {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	params := map[string]any{"threadId": w.threadID}
+	if len(paths) > cwdWatchMaxChanges {
+		paths = paths[:cwdWatchMaxChanges]
+		params["truncated"] = true
+	}
+	list := make([]map[string]any, 0, len(paths))
+	for _, path := range paths {
+		list = append(list, map[string]any{"path": path, "type": changes[path]})
+	}
+	params["changes"] = list
+	s.cwdWatchMu.Lock()
+	if w.turnID != "" {
+		params["turnId"] = w.turnID
+	}
+	s.cwdWatchMu.Unlock()
+	encoded, _ := json.Marshal(map[string]any{"method": "darkhold/fs/changed", "params": params})
+	s.publishThreadEvent(w.threadID, string(encoded))
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/threads"
+)
+
+func TestCwdWatchPublishesChangesDuringTurn(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	cwd, _ := filepath.EvalSymlinks(s.baseDir)
+	if err := os.MkdirAll(filepath.Join(cwd, "src"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.app.threadIndex.Update("t-watch", func(m *threads.Metadata) error {
+		m.Cwd = cwd
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	resp, _ := doJSON(t, http.MethodPatch, s.http.URL+"/api/thread/meta", map[string]any{"threadId": "t-watch", "watchFiles": true})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected watchFiles to be set, got %d", resp.StatusCode)
+	}
+
+	s.app.observeThreadEvent("t-watch", "turn/started", map[string]any{"threadId": "t-watch", "turn": map[string]any{"id": "turn-1"}})
+	changed := filepath.Join(cwd, "src", "main.go")
+	if err := os.WriteFile(changed, []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(cwd, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	var params map[string]any
+	waitForCondition(t, 5*time.Second, 20*time.Millisecond, func() bool {
+		records, _ := s.app.eventStore.ReadRange("t-watch", "", 0)
+		for _, record := range records {
+			var event struct {
+				Method string         `json:"method"`
+				Params map[string]any `json:"params"`
+			}
+			if json.Unmarshal([]byte(record.Payload), &event) == nil && event.Method == "darkhold/fs/changed" {
+				params = event.Params
+				return true
+			}
+		}
+		return false
+	})
+	if params["turnId"] != "turn-1" {
+		t.Fatalf("expected the event to name the turn, got %v", params)
+	}
+	changes, _ := params["changes"].([]any)
+	if len(changes) != 1 {
+		t.Fatalf("expected one change with .git left out, got %v", params)
+	}
+	if change := changes[0].(map[string]any); change["path"] != changed || change["type"] != "created" {
+		t.Fatalf("expected main.go to be reported created, got %v", change)
+	}
+
+	s.app.observeThreadEvent("t-watch", "turn/completed", map[string]any{"threadId": "t-watch"})
+	s.app.cwdWatchMu.Lock()
+	running := len(s.app.cwdWatches)
+	s.app.cwdWatchMu.Unlock()
+	if running != 0 {
+		t.Fatalf("expected the watcher to stop with the turn, %d still running", running)
+	}
+}
+
+func TestMergeCwdChange(t *testing.T) {
+	changes := map[string]string{}
+	mergeCwdChange(changes, "a", "created")
+	mergeCwdChange(changes, "a", "modified")
+	mergeCwdChange(changes, "b", "created")
+	mergeCwdChange(changes, "b", "removed")
+	mergeCwdChange(changes, "c", "removed")
+	mergeCwdChange(changes, "c", "created")
+	if len(changes) != 2 || changes["a"] != "created" || changes["c"] != "modified" {
+		t.Fatalf("unexpected merged changes: %v", changes)
+	}
+}
+
+func TestDiffCwdSnapshots(t *testing.T) {
+	root := t.TempDir()
+	w := &cwdWatch{root: root}
+	kept := filepath.Join(root, "kept.txt")
+	gone := filepath.Join(root, "gone.txt")
+	for _, path := range []string{kept, gone} {
+		if err := os.WriteFile(path, []byte("one"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := w.scan()
+
+	if err := os.WriteFile(kept, []byte("longer"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(gone); err != nil {
+		t.Fatal(err)
+	}
+	added := filepath.Join(root, "src", "main.go")
+	if err := os.MkdirAll(filepath.Dir(added), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(added, []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "node_modules", "x"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	after := w.scan()
+
+	changes := map[string]string{}
+	if !diffCwdSnapshots(before, after, changes) {
+		t.Fatal("expected changes to be found")
+	}
+	want := map[string]string{
+		kept:                "modified",
+		gone:                "removed",
+		filepath.Dir(added): "created",
+		added:               "created",
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("expected %v, got %v", want, changes)
+	}
+	for path, change := range want {
+		if changes[path] != change {
+			t.Fatalf("expected %s %s, got %v", path, change, changes)
+		}
+	}
+	if diffCwdSnapshots(after, w.scan(), map[string]string{}) {
+		t.Fatal("expected an unchanged tree to report nothing")
+	}
+}
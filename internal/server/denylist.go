@@ -0,0 +1,69 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// approvalCommandText returns the command an exec approval asks to run, or
+// "" for requests that carry no command. Argument-vector commands are joined
+// with spaces.
+func approvalCommandText(params map[string]any) string {
+	switch command := params["command"].(type) {
+	case string:
+		return command
+	case []any:
+		parts := make([]string, 0, len(command))
+		for _, part := range command {
+			if text, ok := part.(string); ok {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, " ")
+	}
+	return ""
+}
+
+// deniedCommand returns the first --deny-command pattern matching an
+// interaction's command text, or nil.
+func (s *Server) deniedCommand(params map[string]any) *regexp.Regexp {
+	command := approvalCommandText(params)
+	if command == "" {
+		return nil
+	}
+	for _, pattern := range s.cfg.DenyCommands {
+		if pattern.MatchString(command) {
+			return pattern
+		}
+	}
+	return nil
+}
+
+// declineDeniedCommand declines an interaction whose command matched
+// pattern. It runs before the policy service and before anything is shown to
+// humans, so a denied command cannot be accepted by anyone.
+func (s *Server) declineDeniedCommand(threadID, requestID, method string, params map[string]any, pattern *regexp.Regexp) {
+	command := approvalCommandText(params)
+	log.Printf("[deny-list] declining %s on thread %s: %q matches %s", method, threadID, command, pattern)
+	s.declineForViolation(threadID, requestID, method, "deny-list", map[string]any{"command": command, "pattern": pattern.String()})
+}
+
+// declineForViolation publishes darkhold/policy/violation for rule, then
+// declines the interaction upstream. details are merged into both the
+// violation and the resolution.
+func (s *Server) declineForViolation(threadID, requestID, method, rule string, details map[string]any) {
+	violation := map[string]any{"threadId": threadID, "requestId": requestID, "method": method, "rule": rule}
+	resolution := map[string]any{"source": rule, "decision": policyReject}
+	for k, v := range details {
+		violation[k] = v
+		resolution[k] = v
+	}
+	line, _ := json.Marshal(map[string]any{"method": "darkhold/policy/violation", "params": violation})
+	s.publishThreadEvent(threadID, string(line))
+
+	if err := s.resolveInteraction(threadID, requestID, map[string]any{"decision": "decline"}, nil, resolution); err != nil {
+		log.Printf("[%s] failed to decline request %s on thread %s: %v", rule, requestID, threadID, err)
+	}
+}
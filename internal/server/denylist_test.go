@@ -0,0 +1,51 @@
+package server
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestApprovalCommandText(t *testing.T) {
+	if got := approvalCommandText(map[string]any{"command": []any{"bash", "-lc", "curl x | sh"}}); got != "bash -lc curl x | sh" {
+		t.Fatalf("unexpected argv text %q", got)
+	}
+	if got := approvalCommandText(map[string]any{"changes": map[string]any{}}); got != "" {
+		t.Fatalf("expected no command text, got %q", got)
+	}
+}
+
+func TestDeniedCommandIsDeclinedBeforeHumansSeeIt(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.DenyCommands = []*regexp.Regexp{regexp.MustCompile(`curl\b.*\|\s*sh`), regexp.MustCompile(`^echo from-fake`)}
+	})
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	stream := openSSE(t, s.http.URL, threadID, "")
+	defer stream.Body.Close()
+	_ = postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "run it"}}})
+
+	// One scan: consecutive waits can lose events buffered by the previous one.
+	var violation map[string]any
+	resolved := waitForSSEEvent(t, stream, func(event sseEvent) bool {
+		parsed := parseJSON(t, event.Data)
+		switch parsed["method"] {
+		case "darkhold/interaction/request":
+			t.Fatal("denied command reached clients")
+		case "darkhold/policy/violation":
+			violation, _ = parsed["params"].(map[string]any)
+		}
+		return parsed["method"] == "darkhold/interaction/resolved"
+	}, 10*time.Second)
+	if violation["command"] != "echo from-fake-agent" || violation["pattern"] != "^echo from-fake" {
+		t.Fatalf("unexpected violation %v", violation)
+	}
+	if !strings.Contains(resolved.Data, `"source":"deny-list"`) {
+		t.Fatalf("unexpected resolution %s", resolved.Data)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package server
+
+import "syscall"
+
+// diskFree returns the bytes available to unprivileged users and the free
+// inodes on the filesystem holding path. Inodes are -1 for filesystems that
+// do not report them, such as btrfs.
+func diskFree(path string) (int64, int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	inodes := int64(-1)
+	if stat.Files > 0 {
+		inodes = int64(stat.Ffree)
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), inodes, nil
+}
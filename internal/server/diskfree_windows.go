@@ -0,0 +1,17 @@
+package server
+
+import "golang.org/x/sys/windows"
+
+// diskFree returns the bytes available to the caller on the volume holding
+// path. NTFS has no inode limit, so inodes are always -1.
+func diskFree(path string) (int64, int64, error) {
+	dir, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	var available, total, free uint64
+	if err := windows.GetDiskFreeSpaceEx(dir, &available, &total, &free); err != nil {
+		return 0, 0, err
+	}
+	return int64(available), -1, nil
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"darkhold-go/internal/events"
+	browserfs "darkhold-go/internal/fs"
+	"darkhold-go/internal/netfs"
+)
+
+const errCodeInsufficientStorage = "INSUFFICIENT_STORAGE"
+
+// diskCheck is the free space on the filesystem holding one of the places
+// darkhold and the agent write to.
+type diskCheck struct {
+	// Purpose is "browserRoot" or "eventStore".
+	Purpose    string `json:"purpose"`
+	Path       string `json:"path"`
+	FreeBytes  int64  `json:"freeBytes"`
+	FreeInodes int64  `json:"freeInodes"`
+	// Low names what is below its threshold: "bytes" or "inodes".
+	Low string `json:"low,omitempty"`
+	// FSType and Network come from netfs.Detect. On a network
+	// filesystem darkhold locks and watches files differently.
+	FSType  string `json:"fsType,omitempty"`
+	Network bool   `json:"network"`
+}
+
+// diskLowError refuses a turn/start while a filesystem is nearly full: an
+// agent writing into a full disk fails in ways that are hard to trace back.
+type diskLowError struct {
+	diskCheck
+	MinFreeBytes  int64 `json:"minFreeBytes"`
+	MinFreeInodes int   `json:"minFreeInodes"`
+}
+
+func (e *diskLowError) Error() string {
+	place := "the browser root"
+	if e.Purpose == "eventStore" {
+		place = "the event store"
+	}
+	if e.Low == "inodes" {
+		return fmt.Sprintf("only %d inodes are free on the filesystem holding %s (%s); turns need at least %d (--min-free-inodes)",
+			e.FreeInodes, place, e.Path, e.MinFreeInodes)
+	}
+	return fmt.Sprintf("only %s is free on the filesystem holding %s (%s); turns need at least %s (--min-free-disk)",
+		formatByteSize(e.FreeBytes), place, e.Path, formatByteSize(e.MinFreeBytes))
+}
+
+func writeDiskLow(w http.ResponseWriter, err *diskLowError) {
+	writeErrorDetails(w, http.StatusInsufficientStorage, errCodeInsufficientStorage, err.Error(), err)
+}
+
+// diskChecks measures the filesystems holding the browser root and, for the
+// JSONL store, the event logs. Places that cannot be measured are left out.
+func (s *Server) diskChecks() []diskCheck {
+	places := []diskCheck{{Purpose: "browserRoot", Path: browserfs.GetHomeRoot()}}
+	if store, ok := s.eventStore.(*events.Store); ok {
+		places = append(places, diskCheck{Purpose: "eventStore", Path: store.RootDir})
+	}
+	checks := make([]diskCheck, 0, len(places))
+	for _, check := range places {
+		var err error
+		check.FreeBytes, check.FreeInodes, err = diskFree(existingAncestor(check.Path))
+		if err != nil {
+			continue
+		}
+		fs := netfs.Detect(check.Path)
+		check.FSType, check.Network = fs.Type, fs.Network
+		switch {
+		case s.cfg.MinFreeDiskBytes > 0 && check.FreeBytes < s.cfg.MinFreeDiskBytes:
+			check.Low = "bytes"
+		case s.cfg.MinFreeInodes > 0 && check.FreeInodes >= 0 && check.FreeInodes < int64(s.cfg.MinFreeInodes):
+			check.Low = "inodes"
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// checkDiskSpace reports whether there is room to start a turn.
+func (s *Server) checkDiskSpace() error {
+	if s.cfg.MinFreeDiskBytes <= 0 && s.cfg.MinFreeInodes <= 0 {
+		return nil
+	}
+	for _, check := range s.diskChecks() {
+		if check.Low != "" {
+			return &diskLowError{diskCheck: check, MinFreeBytes: s.cfg.MinFreeDiskBytes, MinFreeInodes: s.cfg.MinFreeInodes}
+		}
+	}
+	return nil
+}
+
+// existingAncestor returns path or its nearest parent that exists, so a
+// store directory not created yet is measured where it will be.
+func existingAncestor(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+func formatByteSize(n int64) string {
+	const unit = 1 << 10
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, suffix := float64(n)/unit, "KiB"
+	for _, next := range []string{"MiB", "GiB", "TiB"} {
+		if value < unit {
+			break
+		}
+		value, suffix = value/unit, next
+	}
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
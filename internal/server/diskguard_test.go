@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"darkhold-go/internal/config"
+)
+
+func TestTurnStartRefusedWhenDiskIsLow(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.MinFreeDiskBytes = 1 << 62
+	})
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	turn := map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "hello"}}}
+	resp, payload := doJSON(t, http.MethodPost, s.http.URL+"/api/rpc", map[string]any{"method": "turn/start", "params": turn})
+	if resp.StatusCode != http.StatusInsufficientStorage || payload["code"] != errCodeInsufficientStorage {
+		t.Fatalf("expected the turn to be refused, got %d %v", resp.StatusCode, payload)
+	}
+	details, _ := payload["details"].(map[string]any)
+	if details["purpose"] != "browserRoot" || details["low"] != "bytes" || details["minFreeBytes"] != float64(1<<62) {
+		t.Fatalf("unexpected details: %v", details)
+	}
+
+	_, health := doJSON(t, http.MethodGet, s.http.URL+"/api/health", nil)
+	disk, _ := health["disk"].([]any)
+	warnings, _ := health["warnings"].([]any)
+	if len(disk) != 2 || len(warnings) != 2 {
+		t.Fatalf("expected both filesystems reported low, got %v", health)
+	}
+}
+
+func TestFormatByteSize(t *testing.T) {
+	for n, want := range map[int64]string{512: "512 B", 1536: "1.5 KiB", 512 << 20: "512.0 MiB", 3 << 40: "3.0 TiB"} {
+		if got := formatByteSize(n); got != want {
+			t.Errorf("formatByteSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"maps"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tmaxmax/go-sse"
+)
+
+const (
+	errCodeInvalidDraft = "INVALID_DRAFT"
+	errCodeDraftFull    = "DRAFT_FULL"
+)
+
+const (
+	// maxDraftKeys bounds the entries one thread's draft can hold.
+	maxDraftKeys = 32
+	// maxDraftValueBytes bounds one draft entry.
+	maxDraftValueBytes = 64 << 10
+)
+
+var draftKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,64}$`)
+
+// draftEntry is one value in a thread's draft scratch space.
+type draftEntry struct {
+	Value string `json:"value"`
+	// Version increases with every write to the thread's draft, so clients
+	// can drop changes older than what they show.
+	Version   int64  `json:"version"`
+	UpdatedAt int64  `json:"updatedAt"`
+	UpdatedBy string `json:"updatedBy"`
+	// ClientID is the writer's self-chosen id, echoed so clients can skip
+	// their own changes.
+	ClientID string `json:"clientId,omitempty"`
+}
+
+// draftChange is the params of darkhold/draft/changed.
+type draftChange struct {
+	ThreadID string `json:"threadId"`
+	Key      string `json:"key"`
+	draftEntry
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// draftStore holds unsent input per thread, such as a half-typed prompt, so
+// it follows the user between devices. It lives in memory: drafts are
+// scratch, and are not worth a disk write per keystroke. Changes are pushed
+// to the thread's SSE subscribers without being stored in the thread log.
+type draftStore struct {
+	mu      sync.Mutex
+	threads map[string]map[string]draftEntry
+	version int64
+	// events carries darkhold/draft/* messages to thread streams. It has no
+	// replayer: a new stream gets the current entries instead.
+	events sse.Joe
+}
+
+func (d *draftStore) entries(threadID string) map[string]draftEntry {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make(map[string]draftEntry, len(d.threads[threadID]))
+	maps.Copy(out, d.threads[threadID])
+	return out
+}
+
+func (d *draftStore) set(threadID, key string, entry draftEntry) (draftEntry, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	draft := d.threads[threadID]
+	if _, exists := draft[key]; !exists && len(draft) >= maxDraftKeys {
+		return draftEntry{}, false
+	}
+	if draft == nil {
+		if d.threads == nil {
+			d.threads = map[string]map[string]draftEntry{}
+		}
+		draft = map[string]draftEntry{}
+		d.threads[threadID] = draft
+	}
+	d.version++
+	entry.Version = d.version
+	draft[key] = entry
+	return entry, true
+}
+
+// remove deletes the named keys, or every key when none are named. It
+// returns the deleted keys in order and the version of the deletion.
+func (d *draftStore) remove(threadID string, keys ...string) ([]string, int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	draft := d.threads[threadID]
+	if len(keys) == 0 {
+		for key := range draft {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+	}
+	deleted := []string{}
+	for _, key := range keys {
+		if _, ok := draft[key]; ok {
+			delete(draft, key)
+			deleted = append(deleted, key)
+		}
+	}
+	if len(draft) == 0 {
+		delete(d.threads, threadID)
+	}
+	if len(deleted) > 0 {
+		d.version++
+	}
+	return deleted, d.version
+}
+
+func (d *draftStore) publish(threadID, method string, params any) {
+	payload, _ := json.Marshal(map[string]any{"method": method, "params": params})
+	msg := newThreadEventMessage("", string(payload))
+	if err := d.events.Publish(msg, []string{threadID}); err != nil {
+		log.Printf("[drafts] failed to broadcast %s for thread %s: %v", method, threadID, err)
+	}
+}
+
+// snapshot returns a darkhold/draft/changed message per current entry, for a
+// stream that just connected.
+func (d *draftStore) snapshot(threadID string) []*sse.Message {
+	entries := d.entries(threadID)
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	messages := make([]*sse.Message, 0, len(keys))
+	for _, key := range keys {
+		payload, _ := json.Marshal(map[string]any{
+			"method": "darkhold/draft/changed",
+			"params": draftChange{ThreadID: threadID, Key: key, draftEntry: entries[key]},
+		})
+		messages = append(messages, newThreadEventMessage("", string(payload)))
+	}
+	return messages
+}
+
+// clearThreadDraft drops a thread's draft once a turn has been started from
+// it, and tells the other clients.
+func (s *Server) clearThreadDraft(threadID, reason string) {
+	if deleted, version := s.drafts.remove(threadID); len(deleted) > 0 {
+		s.drafts.publish(threadID, "darkhold/draft/cleared", map[string]any{"threadId": threadID, "keys": deleted, "version": version, "reason": reason})
+	}
+}
+
+// handleThreadDraft reads and writes a thread's draft:
+//
+//	GET    ?threadId=                         {threadId, entries: {key: entry}}
+//	PUT    {threadId, key, value, clientId}   one entry
+//	DELETE ?threadId=[&key=][&clientId=]      one key, or the whole draft
+//
+// The key defaults to "prompt". Drafts are cleared when a turn starts on the
+// thread.
+func (s *Server) handleThreadDraft(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		threadID := strings.TrimSpace(r.URL.Query().Get("threadId"))
+		if threadID == "" {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"threadId": threadID, "entries": s.drafts.entries(threadID)})
+	case http.MethodPut:
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
+		var request struct {
+			ThreadID string `json:"threadId"`
+			Key      string `json:"key"`
+			Value    string `json:"value"`
+			ClientID string `json:"clientId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+			return
+		}
+		request.ThreadID = strings.TrimSpace(request.ThreadID)
+		if request.ThreadID == "" {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+			return
+		}
+		if request.Key == "" {
+			request.Key = "prompt"
+		}
+		if !draftKeyPattern.MatchString(request.Key) {
+			writeErrorDetails(w, http.StatusBadRequest, errCodeInvalidDraft, "key must be 1-64 letters, digits, '_', '.', ':' or '-'.", map[string]any{"field": "key"})
+			return
+		}
+		if len(request.Value) > maxDraftValueBytes {
+			writeErrorDetails(w, http.StatusBadRequest, errCodeInvalidDraft, "value is too large.", map[string]any{"field": "value", "maxBytes": maxDraftValueBytes})
+			return
+		}
+		if len(request.ClientID) > 128 {
+			writeErrorDetails(w, http.StatusBadRequest, errCodeInvalidDraft, "clientId must be at most 128 bytes.", map[string]any{"field": "clientId"})
+			return
+		}
+		entry, ok := s.drafts.set(request.ThreadID, request.Key, draftEntry{
+			Value:     request.Value,
+			UpdatedAt: time.Now().UnixMilli(),
+			UpdatedBy: clientIdentity(r.Context()),
+			ClientID:  request.ClientID,
+		})
+		if !ok {
+			writeErrorDetails(w, http.StatusConflict, errCodeDraftFull, "the thread's draft has too many keys.", map[string]any{"maxKeys": maxDraftKeys})
+			return
+		}
+		s.drafts.publish(request.ThreadID, "darkhold/draft/changed", draftChange{ThreadID: request.ThreadID, Key: request.Key, draftEntry: entry})
+		writeJSON(w, http.StatusOK, draftChange{ThreadID: request.ThreadID, Key: request.Key, draftEntry: entry})
+	case http.MethodDelete:
+		query := r.URL.Query()
+		threadID := strings.TrimSpace(query.Get("threadId"))
+		if threadID == "" {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+			return
+		}
+		key := query.Get("key")
+		keys := []string{}
+		if key != "" {
+			keys = append(keys, key)
+		}
+		deleted, version := s.drafts.remove(threadID, keys...)
+		switch {
+		case len(deleted) == 0:
+		case key != "":
+			s.drafts.publish(threadID, "darkhold/draft/changed", draftChange{
+				ThreadID:   threadID,
+				Key:        key,
+				draftEntry: draftEntry{Version: version, UpdatedAt: time.Now().UnixMilli(), UpdatedBy: clientIdentity(r.Context()), ClientID: query.Get("clientId")},
+				Deleted:    true,
+			})
+		default:
+			s.drafts.publish(threadID, "darkhold/draft/cleared", map[string]any{"threadId": threadID, "keys": deleted, "version": version, "reason": "deleted"})
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"threadId": threadID, "deleted": deleted})
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThreadDraftSyncsOverSSEAndClearsOnTurnStart(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+
+	resp, entry := doJSON(t, http.MethodPut, s.http.URL+"/api/thread/draft", map[string]any{"threadId": threadID, "value": "fix the flaky", "clientId": "desktop"})
+	if resp.StatusCode != http.StatusOK || entry["key"] != "prompt" || entry["version"] != float64(1) {
+		t.Fatalf("unexpected put: %d %v", resp.StatusCode, entry)
+	}
+
+	// A client that connects later gets the current draft without an id.
+	stream := openSSE(t, s.http.URL, threadID, "")
+	defer stream.Body.Close()
+	snapshot := waitForSSEEvent(t, stream, func(event sseEvent) bool {
+		return strings.Contains(event.Data, "darkhold/draft/changed")
+	}, 3*time.Second)
+	if snapshot.ID != "" || !strings.Contains(snapshot.Data, "fix the flaky") {
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+
+	doJSON(t, http.MethodPut, s.http.URL+"/api/thread/draft", map[string]any{"threadId": threadID, "value": "fix the flaky test", "clientId": "desktop"})
+	live := waitForSSEEvent(t, stream, func(event sseEvent) bool {
+		return strings.Contains(event.Data, "fix the flaky test")
+	}, 3*time.Second)
+	if params := parseJSON(t, live.Data)["params"].(map[string]any); params["clientId"] != "desktop" || params["version"] != float64(2) {
+		t.Fatalf("unexpected change: %v", params)
+	}
+
+	if events, _ := s.store.Read(threadID); strings.Contains(strings.Join(events, "\n"), "darkhold/draft/") {
+		t.Fatalf("expected drafts to stay out of the thread log: %v", events)
+	}
+
+	postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "fix the flaky test"}}})
+	waitForSSEEvent(t, stream, func(event sseEvent) bool {
+		return strings.Contains(event.Data, "darkhold/draft/cleared") && strings.Contains(event.Data, "turnStarted")
+	}, 3*time.Second)
+	_, view := doJSON(t, http.MethodGet, s.http.URL+"/api/thread/draft?threadId="+threadID, nil)
+	if entries, _ := view["entries"].(map[string]any); len(entries) != 0 {
+		t.Fatalf("expected the draft to be cleared: %v", view)
+	}
+
+	resp, invalid := doJSON(t, http.MethodPut, s.http.URL+"/api/thread/draft", map[string]any{"threadId": threadID, "key": "no spaces", "value": "x"})
+	if resp.StatusCode != http.StatusBadRequest || invalid["code"] != errCodeInvalidDraft {
+		t.Fatalf("expected INVALID_DRAFT: %d %v", resp.StatusCode, invalid)
+	}
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"bytes"
+	"crypto/rand"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	embedThreadPathPrefix = "/embed/thread/"
+	// embedMaxMessageRunes clips the last agent message shown by a widget.
+	embedMaxMessageRunes = 600
+)
+
+//go:embed widgets/thread.html
+var embedThreadHTML string
+
+var embedThreadTemplate = template.Must(template.New("thread").Parse(embedThreadHTML))
+
+// embedThreadState is what the thread widget shows. The page starts from the
+// state folded over the stored log and applies the same rules to each event
+// from the thread's SSE stream, which resumes after LastEventID.
+type embedThreadState struct {
+	ThreadID    string `json:"threadId"`
+	Title       string `json:"title"`
+	Status      string `json:"status"`
+	LastMessage string `json:"lastMessage"`
+	// Pending maps unresolved interaction request IDs to a short label.
+	Pending     map[string]string `json:"pending"`
+	UpdatedAt   int64             `json:"updatedAt,omitempty"`
+	LastEventID string            `json:"lastEventId,omitempty"`
+	StreamURL   string            `json:"streamUrl"`
+}
+
+// apply folds one stored event into the state; the widget's script mirrors
+// it.
+func (st *embedThreadState) apply(payload string) bool {
+	var event struct {
+		Method string `json:"method"`
+		Params struct {
+			RequestID json.RawMessage `json:"requestId"`
+			Method    string          `json:"method"`
+			Params    struct {
+				Command any `json:"command"`
+				Reason  any `json:"reason"`
+			} `json:"params"`
+			Turn struct {
+				Status string `json:"status"`
+			} `json:"turn"`
+			Item struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"item"`
+		} `json:"params"`
+	}
+	if json.Unmarshal([]byte(payload), &event) != nil {
+		return false
+	}
+	params := event.Params
+	switch event.Method {
+	case "turn/started":
+		st.Status = "running"
+	case "turn/completed":
+		st.Status = "completed"
+		if params.Turn.Status == "failed" || params.Turn.Status == "interrupted" {
+			st.Status = params.Turn.Status
+		}
+	case "item/completed":
+		if params.Item.Type != "agentMessage" || strings.TrimSpace(params.Item.Text) == "" {
+			return false
+		}
+		st.LastMessage = clipEmbedText(params.Item.Text)
+	case "darkhold/interaction/request":
+		if len(params.RequestID) == 0 {
+			return false
+		}
+		label := "interaction"
+		for _, candidate := range []any{params.Params.Command, params.Params.Reason, params.Method} {
+			if text, ok := candidate.(string); ok && strings.TrimSpace(text) != "" {
+				label = clipEmbedText(text)
+				break
+			}
+		}
+		st.Pending[embedRequestID(params.RequestID)] = label
+	case "darkhold/interaction/resolved":
+		delete(st.Pending, embedRequestID(params.RequestID))
+	default:
+		return false
+	}
+	return true
+}
+
+// embedRequestID keys a request ID the way the script's String() does, so
+// numeric and string IDs both match their resolution.
+func embedRequestID(raw json.RawMessage) string {
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+	return string(raw)
+}
+
+func clipEmbedText(text string) string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) <= embedMaxMessageRunes {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:embedMaxMessageRunes-1]) + "…"
+}
+
+// handleEmbedThread serves /embed/thread/{id}: a self-contained page with
+// the thread's status, last agent message and pending approvals, meant to be
+// framed by dashboards. It authenticates like the API, so a dashboard that
+// cannot send headers uses a share link (?share=) or ?access_token=, which
+// the page passes on to its event stream.
+func (s *Server) handleEmbedThread(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeMethodNotAllowed(w)
+		return
+	}
+	threadID := strings.TrimSpace(r.PathValue("id"))
+	records, err := s.eventStore.ReadRange(threadID, "", 0)
+	if err == nil && len(records) == 0 && s.backfillColdThread(r.Context(), threadID) {
+		records, err = s.eventStore.ReadRange(threadID, "", 0)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
+		return
+	}
+	meta, known := s.threadIndex.Get(threadID)
+	if !known && len(records) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	state := embedThreadState{ThreadID: threadID, Title: meta.Title, Status: "idle", Pending: map[string]string{}}
+	if state.Title == "" {
+		state.Title = "Thread " + threadID
+	}
+	for _, record := range records {
+		if state.apply(record.Payload) {
+			state.UpdatedAt = record.Time
+		}
+	}
+	stream := url.Values{"threadId": {threadID}, "eventNames": {"false"}}
+	if len(records) > 0 {
+		state.LastEventID = records[len(records)-1].ID
+		stream.Set("lastEventId", state.LastEventID)
+	}
+	for _, param := range []string{shareLinkParam, "access_token"} {
+		if value := r.URL.Query().Get(param); value != "" {
+			stream.Set(param, value)
+		}
+	}
+	state.StreamURL = "/api/thread/events/stream?" + stream.Encode()
+
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+	page := struct {
+		Nonce      string
+		Title      string
+		State      embedThreadState
+		MaxMessage int
+	}{base64.RawURLEncoding.EncodeToString(nonce), state.Title, state, embedMaxMessageRunes}
+	var body bytes.Buffer
+	if err := embedThreadTemplate.Execute(&body, page); err != nil {
+		log.Printf("[embed] failed to render thread %s: %v", threadID, err)
+		writeError(w, http.StatusInternalServerError, errCodeInternal, "failed to render the widget.")
+		return
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	header.Set("Cache-Control", "no-store")
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Referrer-Policy", "no-referrer")
+	header.Set("Content-Security-Policy", "default-src 'none'; script-src 'nonce-"+page.Nonce+"'; style-src 'nonce-"+page.Nonce+"'; "+
+		"connect-src 'self'; base-uri 'none'; form-action 'none'; frame-ancestors "+s.embedFrameAncestors())
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(body.Bytes())
+	}
+}
+
+func (s *Server) embedFrameAncestors() string {
+	if sources := strings.TrimSpace(s.cfg.EmbedFrameAncestors); sources != "" {
+		return sources
+	}
+	return "*"
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestEmbedThreadWidget(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	_ = postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "hello"}}})
+	waitForCondition(t, 5*time.Second, 10*time.Millisecond, func() bool {
+		events, _ := s.store.Read(threadID)
+		return strings.Contains(strings.Join(events, "\n"), "darkhold/interaction/request")
+	})
+
+	get := func(path string) (*http.Response, string) {
+		t.Helper()
+		resp, err := http.Get(s.http.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+	resp, body := get("/embed/thread/" + threadID)
+	csp := resp.Header.Get("Content-Security-Policy")
+	if resp.StatusCode != http.StatusOK || !strings.HasSuffix(csp, "frame-ancestors *") || !strings.Contains(csp, "script-src 'nonce-") {
+		t.Fatalf("unexpected widget response %d %q", resp.StatusCode, csp)
+	}
+	nonce := csp[strings.Index(csp, "'nonce-")+len("'nonce-"):]
+	nonce = nonce[:strings.Index(nonce, "'")]
+	if !strings.Contains(body, `<script nonce="`+nonce+`">`) || !strings.Contains(body, `"pending":{"9001":"echo from-fake-agent"`) || !strings.Contains(body, `"status":"running"`) {
+		t.Fatalf("widget should start from the stored state:\n%s", body)
+	}
+
+	_, payload := doJSON(t, http.MethodPost, s.http.URL+"/api/thread/shares", map[string]any{"threadId": threadID})
+	embedURL := payload["links"].(map[string]any)["embed"].(string)
+	s.app.cfg.APIKeys = []config.APIKey{{Name: "owner", Token: "owner-token-0123456789"}}
+	if resp, _ := get("/embed/thread/" + threadID); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("widget should need credentials once API keys are set, got %d", resp.StatusCode)
+	}
+	resp, body = get(strings.TrimPrefix(embedURL, s.http.URL))
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `\u0026share=`) {
+		t.Fatalf("share link should open the widget and its stream: %d\n%s", resp.StatusCode, body)
+	}
+}
+
+func TestEmbedThreadStateFoldsEvents(t *testing.T) {
+	st := embedThreadState{Status: "idle", Pending: map[string]string{}}
+	for _, payload := range []string{
+		`{"method":"turn/started","params":{"threadId":"t"}}`,
+		`{"method":"item/completed","params":{"item":{"type":"agentMessage","text":"  Working on it.  "}}}`,
+		`{"method":"darkhold/interaction/request","params":{"requestId":7,"method":"item/commandExecution/requestApproval","params":{"command":"make test"}}}`,
+		`{"method":"darkhold/interaction/request","params":{"requestId":"8","method":"item/tool/requestUserInput","params":{}}}`,
+		`{"method":"darkhold/interaction/resolved","params":{"requestId":"8"}}`,
+		`{"method":"turn/completed","params":{"turn":{"status":"failed"}}}`,
+	} {
+		st.apply(payload)
+	}
+	if st.Status != "failed" || st.LastMessage != "Working on it." || len(st.Pending) != 1 || st.Pending["7"] != "make test" {
+		t.Fatalf("unexpected state %+v", st)
+	}
+	if long := clipEmbedText(strings.Repeat("é", embedMaxMessageRunes+5)); len([]rune(long)) != embedMaxMessageRunes {
+		t.Fatalf("expected the message to be clipped to %d runes, got %d", embedMaxMessageRunes, len([]rune(long)))
+	}
+}
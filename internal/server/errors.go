@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Error codes returned in the "code" field of every API error response.
+// Clients should branch on these instead of matching on the message text.
+const (
+	errCodeMethodNotAllowed    = "METHOD_NOT_ALLOWED"
+	errCodeNotFound            = "NOT_FOUND"
+	errCodeForbidden           = "FORBIDDEN"
+	errCodeInvalidJSON         = "INVALID_JSON"
+	errCodeInvalidRequest      = "INVALID_REQUEST"
+	errCodeInvalidPath         = "INVALID_PATH"
+	errCodeStorage             = "STORAGE_ERROR"
+	errCodeSessionSpawnFailed  = "SESSION_SPAWN_FAILED"
+	errCodeSessionInitFailed   = "SESSION_INIT_FAILED"
+	errCodeSessionUnavailable  = "SESSION_UNAVAILABLE"
+	errCodeSessionSaturated    = "SESSION_SATURATED"
+	errCodeRPCTimeout          = "RPC_TIMEOUT"
+	errCodeRPCCanceled         = "RPC_CANCELED"
+	errCodeRPCError            = "RPC_ERROR"
+	errCodeThreadNotFound      = "THREAD_NOT_FOUND"
+	errCodeInteractionResolved = "INTERACTION_RESOLVED"
+	errCodeInternal            = "INTERNAL"
+)
+
+var (
+	errSessionUnavailable = errors.New("app-server session is unavailable")
+	errSessionClosed      = errors.New("app-server session closed")
+	errSessionUnhealthy   = errors.New("app-server session stopped answering health pings")
+	errSessionSaturated   = errors.New("app-server session has too many RPCs in flight")
+	errRPCTimeout         = errors.New("RPC request timed out")
+
+	errInteractionNotFound = errors.New("interaction request not found or already resolved")
+)
+
+// apiError is the stable error envelope. Error stays a plain string so older
+// clients that only read payload.error keep working; it is in the response's
+// Content-Language, with the English original in SourceError when it was
+// translated.
+type apiError struct {
+	Error       string `json:"error"`
+	Code        string `json:"code"`
+	Details     any    `json:"details,omitempty"`
+	SourceError string `json:"sourceError,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeErrorDetails(w, status, code, message, nil)
+}
+
+func writeErrorDetails(w http.ResponseWriter, status int, code, message string, details any) {
+	writeJSON(w, status, localizeError(w, apiError{Error: message, Code: code, Details: details}))
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter) {
+	writeError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+}
+
+// writeSessionError maps errors from session RPC plumbing onto a status and
+// code, using fallbackCode for anything that is not a known session failure.
+func writeSessionError(w http.ResponseWriter, err error, fallbackCode string) {
+	switch {
+	case errors.Is(err, errSessionSaturated):
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusServiceUnavailable, errCodeSessionSaturated, err.Error())
+	case errors.Is(err, errRPCTimeout):
+		writeError(w, http.StatusGatewayTimeout, errCodeRPCTimeout, err.Error())
+	case errors.Is(err, errSessionUnavailable), errors.Is(err, errSessionClosed), errors.Is(err, errSessionUnhealthy):
+		writeError(w, http.StatusServiceUnavailable, errCodeSessionUnavailable, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		writeError(w, http.StatusServiceUnavailable, errCodeRPCCanceled, err.Error())
+	default:
+		writeError(w, http.StatusBadGateway, fallbackCode, err.Error())
+	}
+}
+
+// writeUpstreamRPCError surfaces a JSON-RPC error object returned by the
+// agent, keeping the upstream code and data available under details.
+func writeUpstreamRPCError(w http.ResponseWriter, errObj map[string]any) {
+	message, _ := errObj["message"].(string)
+	if message == "" {
+		message = "RPC error"
+	}
+	code := errCodeRPCError
+	status := http.StatusBadRequest
+	if strings.Contains(strings.ToLower(message), "thread not found") {
+		code = errCodeThreadNotFound
+		status = http.StatusNotFound
+	}
+	writeErrorDetails(w, status, code, message, map[string]any{"rpcError": errObj})
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestAdminEventChainVerifiesStoredAndExportedLogs(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+	s.store.Chain = true
+	s.app.publishThreadEvent("thread-audited", `{"method":"turn/started","params":{"turnId":"t1"}}`)
+	s.app.publishThreadEvent("thread-audited", `{"method":"turn/completed","params":{"turnId":"t1"}}`)
+
+	resp, stored := doJSON(t, http.MethodGet, s.http.URL+"/api/admin/event-chain?threadId=thread-audited", nil)
+	if resp.StatusCode != http.StatusOK || stored["valid"] != true || stored["chained"] != float64(2) || stored["threadId"] != "thread-audited" {
+		t.Fatalf("unexpected report %d %v", resp.StatusCode, stored)
+	}
+
+	data, err := s.store.ExportLog("thread-audited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := bytes.Replace(data, []byte(`turn/started`), []byte(`turn/failed`), 1)
+	resp, err = http.Post(s.http.URL+"/api/admin/event-chain", "application/x-ndjson", bytes.NewReader(tampered))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var report map[string]any
+	_ = json.NewDecoder(resp.Body).Decode(&report)
+	if resp.StatusCode != http.StatusOK || report["valid"] != false || report["breaks"] == nil {
+		t.Fatalf("expected the edited export to fail verification, got %d %v", resp.StatusCode, report)
+	}
+}
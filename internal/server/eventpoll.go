@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tmaxmax/go-sse"
+
+	"darkhold-go/internal/events"
+)
+
+// Long-poll bounds. The default stays under the 30s many proxies allow a
+// response to stay open.
+const (
+	pollDefaultTimeout = 25 * time.Second
+	pollMaxTimeout     = 60 * time.Second
+	pollDefaultLimit   = 500
+	pollMaxLimit       = 1000
+	// pollRecheck rereads the log while waiting, in case an event landed
+	// before the subscription was in place.
+	pollRecheck = time.Second
+)
+
+// handleThreadEventsPoll is the long-poll fallback for clients whose network
+// strips or cuts off SSE:
+//
+//	GET ?threadId=&afterId=[&timeoutSec=][&limit=][&render=html]
+//	    {threadId, schemaVersion, events, records, lastEventId, more, timedOut}
+//
+// Stored events after afterId are returned at once; when there are none
+// the request waits up to timeoutSec (default 25, at most 60, 0 returns
+// immediately) for the next one. lastEventId is the afterId for the next
+// poll, more means the limit cut the batch short, and timedOut that
+// nothing arrived in time.
+func (s *Server) handleThreadEventsPoll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	query := r.URL.Query()
+	threadID := strings.TrimSpace(query.Get("threadId"))
+	if threadID == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+		return
+	}
+	afterID := strings.TrimSpace(query.Get("afterId"))
+	timeout := pollDefaultTimeout
+	if raw := query.Get("timeoutSec"); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil || seconds < 0 || time.Duration(seconds)*time.Second > pollMaxTimeout {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "timeoutSec must be between 0 and 60.")
+			return
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+	limit := pollDefaultLimit
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > pollMaxLimit {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "limit must be between 1 and 1000.")
+			return
+		}
+		limit = n
+	}
+	if afterID == "" {
+		if all, err := s.eventStore.ReadRange(threadID, "", 1); err == nil && len(all) == 0 {
+			s.backfillColdThread(r.Context(), threadID)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	writer := &channelMessageWriter{ch: make(chan *sse.Message, 1)}
+	go func() {
+		_ = s.sseProvider.Subscribe(ctx, sse.Subscription{Client: writer, Topics: []string{threadID}})
+	}()
+
+	records, err := s.eventStore.ReadRange(threadID, afterID, limit+1)
+	if err == nil && len(records) == 0 && timeout > 0 {
+		recheck := time.NewTicker(pollRecheck)
+		defer recheck.Stop()
+	wait:
+		for err == nil && len(records) == 0 {
+			select {
+			case <-writer.ch:
+			case <-recheck.C:
+			case <-ctx.Done():
+				break wait
+			}
+			records, err = s.eventStore.ReadRange(threadID, afterID, limit+1)
+		}
+	}
+	if r.Context().Err() != nil {
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
+		return
+	}
+	more := len(records) > limit
+	if more {
+		records = records[:limit]
+	}
+	render := query.Get("render") == "html"
+	payloads := make([]string, 0, len(records))
+	stamps := make([]eventStamp, 0, len(records))
+	lastEventID := afterID
+	for _, record := range records {
+		if render {
+			record.Payload = withRenderedAgentMessage(record.Payload)
+		}
+		payloads = append(payloads, record.Payload)
+		stamps = append(stamps, eventStamp{ID: record.ID, Seq: record.Seq, Time: record.Time})
+		lastEventID = record.ID
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"threadId":      threadID,
+		"schemaVersion": events.SchemaVersion,
+		"events":        payloads,
+		"records":       stamps,
+		"lastEventId":   lastEventID,
+		"more":          more,
+		"timedOut":      len(records) == 0,
+	})
+}
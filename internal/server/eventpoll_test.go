@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThreadEventsPollWaitsForTheNextEvent(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	const threadID = "t-poll"
+	s.app.publishThreadEvent(threadID, `{"method":"turn/started","params":{"threadId":"t-poll","turn":{"id":"turn-1"}}}`)
+	_, first := doJSON(t, http.MethodGet, s.http.URL+"/api/thread/events/poll?threadId="+threadID, nil)
+	cursor, _ := first["lastEventId"].(string)
+	if events, _ := first["events"].([]any); len(events) != 1 || cursor == "" || first["timedOut"] != false {
+		t.Fatalf("expected the stored event at once: %v", first)
+	}
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		s.app.publishThreadEvent(threadID, `{"method":"turn/completed","params":{"threadId":"t-poll","turn":{"id":"turn-1"}}}`)
+	}()
+	started := time.Now()
+	_, next := doJSON(t, http.MethodGet, s.http.URL+"/api/thread/events/poll?threadId="+threadID+"&afterId="+cursor+"&timeoutSec=10", nil)
+	events, _ := next["events"].([]any)
+	if len(events) != 1 || !strings.Contains(events[0].(string), "turn/completed") || next["lastEventId"] == cursor {
+		t.Fatalf("expected the poll to return the new event: %v", next)
+	}
+	if waited := time.Since(started); waited > 3*time.Second {
+		t.Fatalf("expected the poll to return as the event arrived, waited %v", waited)
+	}
+
+	_, empty := doJSON(t, http.MethodGet, s.http.URL+"/api/thread/events/poll?threadId="+threadID+"&afterId="+next["lastEventId"].(string)+"&timeoutSec=0", nil)
+	if events, _ := empty["events"].([]any); len(events) != 0 || empty["timedOut"] != true || empty["lastEventId"] != next["lastEventId"] {
+		t.Fatalf("expected an empty, timed-out poll to keep the cursor: %v", empty)
+	}
+	resp, invalid := doJSON(t, http.MethodGet, s.http.URL+"/api/thread/events/poll?threadId="+threadID+"&timeoutSec=61", nil)
+	if resp.StatusCode != http.StatusBadRequest || invalid["code"] != errCodeInvalidRequest {
+		t.Fatalf("expected timeoutSec over 60 to be rejected: %d %v", resp.StatusCode, invalid)
+	}
+}
@@ -0,0 +1,394 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	"darkhold-go/internal/events"
+	"darkhold-go/internal/snapshots"
+	"darkhold-go/internal/threads"
+)
+
+// Where a darkhold event is delivered. Thread events are stored in the
+// thread log and streamed; draft events are streamed but never stored;
+// session events are kept in the session event history; server events go
+// to webhooks only; broadcast events go to the broadcast stream.
+const (
+	eventScopeThread    = "thread"
+	eventScopeDraft     = "draft"
+	eventScopeSessions  = "sessions"
+	eventScopeServer    = "server"
+	eventScopeBroadcast = "broadcast"
+)
+
+// eventDoc describes one event method darkhold synthesizes. params is a
+// zero value of the type its params are encoded from, or document, so the
+// schema is generated from Go types rather than kept by hand.
+type eventDoc struct {
+	method      string
+	scope       string
+	description string
+	params      any
+}
+
+// The params of events built as maps where they are published. Each field
+// mirrors a key set there; variant-specific keys are omitempty.
+
+type interactionRequestParams struct {
+	ThreadID    string            `json:"threadId"`
+	RequestID   string            `json:"requestId"`
+	Method      string            `json:"method"`
+	Kind        string            `json:"kind" enum:"approval,userInput,toolCall,elicitation,generic"`
+	Params      any               `json:"params"`
+	Confinement *confinementCheck `json:"confinement,omitempty"`
+}
+
+type interactionResolvedParams struct {
+	ThreadID  string   `json:"threadId"`
+	RequestID string   `json:"requestId"`
+	Source    string   `json:"source" enum:"http,websocket,quick-link,policy,auto-respond,deny-list,outside-root"`
+	Decision  string   `json:"decision,omitempty"`
+	Reason    string   `json:"reason,omitempty"`
+	Kind      string   `json:"kind,omitempty"`
+	By        string   `json:"by,omitempty"`
+	Command   string   `json:"command,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
+	Root      string   `json:"root,omitempty"`
+	Outside   []string `json:"outside,omitempty"`
+}
+
+type draftClearedParams struct {
+	ThreadID string   `json:"threadId"`
+	Keys     []string `json:"keys"`
+	Version  int64    `json:"version"`
+	Reason   string   `json:"reason" enum:"turnStarted,deleted"`
+}
+
+type alertParams struct {
+	ThreadID         string  `json:"threadId"`
+	Alert            string  `json:"alert" enum:"turnDuration,approvalPending,agentResources"`
+	TurnID           string  `json:"turnId,omitempty"`
+	RequestID        string  `json:"requestId,omitempty"`
+	Method           string  `json:"method,omitempty"`
+	StartedAt        int64   `json:"startedAt,omitempty"`
+	ElapsedMs        int64   `json:"elapsedMs,omitempty"`
+	ThresholdMs      int64   `json:"thresholdMs,omitempty"`
+	Limit            string  `json:"limit,omitempty" enum:"rss,cpu"`
+	SessionID        int     `json:"sessionId,omitempty"`
+	Pid              int     `json:"pid,omitempty"`
+	RSSBytes         int64   `json:"rssBytes,omitempty"`
+	CPUPercent       float64 `json:"cpuPercent,omitempty"`
+	ThresholdBytes   int64   `json:"thresholdBytes,omitempty"`
+	ThresholdPercent float64 `json:"thresholdPercent,omitempty"`
+}
+
+type turnSnapshotParams struct {
+	ThreadID string             `json:"threadId"`
+	TurnID   string             `json:"turnId"`
+	Snapshot snapshots.Snapshot `json:"snapshot"`
+}
+
+type turnRolledBackParams struct {
+	ThreadID   string   `json:"threadId"`
+	TurnID     string   `json:"turnId"`
+	SnapshotID string   `json:"snapshotId"`
+	Mode       string   `json:"mode"`
+	Restored   []string `json:"restored"`
+	Removed    []string `json:"removed"`
+	By         string   `json:"by"`
+	At         int64    `json:"at"`
+}
+
+type turnRetryingParams struct {
+	ThreadID    string `json:"threadId"`
+	TurnID      string `json:"turnId"`
+	Attempt     int    `json:"attempt"`
+	MaxAttempts int    `json:"maxAttempts"`
+	DelayMs     int64  `json:"delayMs"`
+	Reason      string `json:"reason"`
+	Error       string `json:"error"`
+}
+
+type turnRetryStoppedParams struct {
+	ThreadID   string `json:"threadId"`
+	TurnID     string `json:"turnId"`
+	Attempts   int    `json:"attempts"`
+	StopReason string `json:"stopReason" enum:"exhausted,permanent"`
+	Reason     string `json:"reason"`
+	Error      string `json:"error"`
+}
+
+type turnPreambleParams struct {
+	ThreadID string   `json:"threadId"`
+	Sources  []string `json:"sources"`
+	Text     string   `json:"text"`
+}
+
+type turnLimitExceededParams struct {
+	ThreadID    string `json:"threadId"`
+	TurnID      string `json:"turnId,omitempty"`
+	Limit       string `json:"limit" enum:"duration,cpu"`
+	StartedAt   int64  `json:"startedAt"`
+	ElapsedMs   int64  `json:"elapsedMs"`
+	ThresholdMs int64  `json:"thresholdMs"`
+	CPUMs       int64  `json:"cpuMs,omitempty"`
+}
+
+type turnTransformedParams struct {
+	ThreadID string        `json:"threadId"`
+	TurnID   string        `json:"turnId,omitempty"`
+	Hooks    []turnHookRun `json:"hooks"`
+}
+
+type reviewFileParams struct {
+	ThreadID string `json:"threadId"`
+	TurnID   string `json:"turnId"`
+	Path     string `json:"path"`
+	Action   string `json:"action"`
+	By       string `json:"by"`
+	At       int64  `json:"at"`
+}
+
+type threadSettingsChangedParams struct {
+	ThreadID string           `json:"threadId"`
+	Settings threads.Settings `json:"settings"`
+	Previous threads.Settings `json:"previous"`
+	Changed  []string         `json:"changed"`
+	By       string           `json:"by"`
+	At       int64            `json:"at"`
+}
+
+type threadFencedParams struct {
+	ThreadID       string `json:"threadId"`
+	SessionID      int    `json:"sessionId"`
+	OwnerSessionID int    `json:"ownerSessionId"`
+	Epoch          uint64 `json:"epoch"`
+	Method         string `json:"method"`
+}
+
+type storageEvictedParams struct {
+	ThreadID string `json:"threadId"`
+	Bytes    int64  `json:"bytes"`
+	Reason   string `json:"reason" enum:"lru,archived"`
+	MaxBytes int64  `json:"maxBytes"`
+}
+
+type sessionEventParams struct {
+	SessionID     int      `json:"sessionId"`
+	State         string   `json:"state" enum:"spawned,initialized,unhealthy,reaped,exited"`
+	PreviousState string   `json:"previousState,omitempty"`
+	Pid           int      `json:"pid,omitempty"`
+	ThreadIDs     []string `json:"threadIds"`
+}
+
+type sessionInitializedParams struct {
+	sessionEventParams
+	DurationMs int64 `json:"durationMs"`
+}
+
+type sessionUnhealthyParams struct {
+	sessionEventParams
+	Reason string `json:"reason"`
+	Error  string `json:"error,omitempty"`
+}
+
+type sessionReapedParams struct {
+	sessionEventParams
+	IdleMs int64 `json:"idleMs"`
+}
+
+type sessionExitedParams struct {
+	sessionEventParams
+	ExitCode  int    `json:"exitCode"`
+	Crashed   bool   `json:"crashed"`
+	UptimeMs  int64  `json:"uptimeMs"`
+	KilledFor string `json:"killedFor,omitempty" enum:"rss,cpu"`
+}
+
+type rpcAbandonedParams struct {
+	ThreadID      string `json:"threadId"`
+	Method        string `json:"method"`
+	Reason        string `json:"reason" enum:"canceled,timeout"`
+	AbandonedAt   int64  `json:"abandonedAt"`
+	LatencyMs     int64  `json:"latencyMs"`
+	Result        any    `json:"result,omitempty"`
+	Error         any    `json:"error,omitempty"`
+	ResultOmitted bool   `json:"resultOmitted,omitempty"`
+	TurnID        string `json:"turnId,omitempty"`
+	Interrupted   bool   `json:"interrupted,omitempty"`
+}
+
+type policyViolationParams struct {
+	ThreadID  string   `json:"threadId"`
+	RequestID string   `json:"requestId"`
+	Method    string   `json:"method"`
+	Rule      string   `json:"rule" enum:"deny-list,outside-root"`
+	Command   string   `json:"command,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
+	Root      string   `json:"root,omitempty"`
+	Outside   []string `json:"outside,omitempty"`
+}
+
+type historyImportedParams struct {
+	ThreadID string `json:"threadId"`
+	Turns    int    `json:"turns"`
+}
+
+type fsChange struct {
+	Path string `json:"path"`
+	Type string `json:"type" enum:"created,modified,removed"`
+}
+
+type fsChangedParams struct {
+	ThreadID  string     `json:"threadId"`
+	TurnID    string     `json:"turnId,omitempty"`
+	Changes   []fsChange `json:"changes"`
+	Truncated bool       `json:"truncated,omitempty"`
+}
+
+type execStartedParams struct {
+	ThreadID string `json:"threadId"`
+	ExecID   string `json:"execId"`
+	Command  string `json:"command"`
+	Cwd      string `json:"cwd"`
+}
+
+type broadcastProgressParams struct {
+	CorrelationID string           `json:"correlationId"`
+	Target        broadcastTarget  `json:"target"`
+	Summary       broadcastSummary `json:"summary"`
+}
+
+// darkholdEvents lists every event method darkhold publishes itself, for
+// /api/events/schema. A method published anywhere in the package must be
+// listed; TestEventSchemaCoversPublishedMethods checks.
+var darkholdEvents = []eventDoc{
+	{"darkhold/interaction/request", eventScopeThread, "The agent asked the client for an approval, input or tool result; answer with POST /api/thread/interaction/respond.", interactionRequestParams{}},
+	{"darkhold/interaction/resolved", eventScopeThread, "An interaction was answered, by a client or by darkhold's policy, auto-respond or deny-list.", interactionResolvedParams{}},
+	{"darkhold/draft/changed", eventScopeDraft, "A thread draft entry was written; also sent for each entry when a stream connects.", draftChange{}},
+	{"darkhold/draft/cleared", eventScopeDraft, "Draft entries were dropped because a turn was started from them or they were deleted.", draftClearedParams{}},
+	{"darkhold/alert", eventScopeThread, "A turn or approval passed its --alert-* threshold, or the agent was killed for exceeding --max-agent-rss or --max-agent-cpu.", alertParams{}},
+	{"darkhold/turn/snapshot", eventScopeThread, "The workspace was snapshotted before a turn, so it can be rolled back.", turnSnapshotParams{}},
+	{"darkhold/turn/rolledBack", eventScopeThread, "A turn's file changes were rolled back to its snapshot.", turnRolledBackParams{}},
+	{"darkhold/turn/retrying", eventScopeThread, "A failed turn will be resubmitted after delayMs under the thread's retry policy.", turnRetryingParams{}},
+	{"darkhold/turn/retryStopped", eventScopeThread, "A failed turn will not be retried again.", turnRetryStoppedParams{}},
+	{"darkhold/turn/preamble", eventScopeThread, "The system preamble a turn was started with.", turnPreambleParams{}},
+	{"darkhold/turn/limitExceeded", eventScopeThread, "A turn ran past --max-turn-duration or --max-turn-cpu and is being interrupted.", turnLimitExceededParams{}},
+	{"darkhold/turn/transformed", eventScopeThread, "--turn-hook hooks rewrote, annotated or failed on the turn's input.", turnTransformedParams{}},
+	{"darkhold/turn/postHook", eventScopeThread, "A --post-turn-hook finished running on a completed turn.", postTurnResult{}},
+	{"darkhold/review/file", eventScopeThread, "A file changed by a turn was accepted or reverted in review.", reviewFileParams{}},
+	{"darkhold/thread/settingsChanged", eventScopeThread, "The thread's model, effort or approval policy overrides changed.", threadSettingsChangedParams{}},
+	{"darkhold/thread/replay", eventScopeThread, "A turn replayed from another thread ended, or the replay stopped.", threadReplayProgress{}},
+	{"darkhold/thread/chained", eventScopeThread, "A chain started the thread's next turn after a turn of the thread it follows completed, or failed to.", threadChainedParams{}},
+	{"darkhold/thread/fenced", eventScopeThread, "Messages for the thread from a session that no longer owns it are being quarantined.", threadFencedParams{}},
+	{"darkhold/thread/autoArchived", eventScopeThread, "The thread was archived and compacted for being idle past --auto-archive-days.", autoArchivedThread{}},
+	{"darkhold/storage/evicted", eventScopeThread, "The thread's log was deleted to keep the store under --max-storage-bytes.", storageEvictedParams{}},
+	{"darkhold/rpc/abandoned", eventScopeThread, "The agent answered an RPC after its caller gave up; a turn it started was interrupted.", rpcAbandonedParams{}},
+	{"darkhold/policy/violation", eventScopeThread, "An interaction broke a darkhold rule and was declined.", policyViolationParams{}},
+	{"darkhold/history/imported", eventScopeThread, "The events after this one were imported from the agent's history rather than seen live.", historyImportedParams{}},
+	{"darkhold/fs/changed", eventScopeThread, "Files under the thread's cwd changed.", fsChangedParams{}},
+	{"darkhold/exec/started", eventScopeThread, "A POST /api/exec command started in the thread's cwd.", execStartedParams{}},
+	{"darkhold/exec/completed", eventScopeThread, "A POST /api/exec command finished.", execResult{}},
+	{"darkhold/session/spawned", eventScopeSessions, "An agent process was started.", sessionEventParams{}},
+	{"darkhold/session/initialized", eventScopeSessions, "An agent process answered initialize.", sessionInitializedParams{}},
+	{"darkhold/session/unhealthy", eventScopeSessions, "An agent process failed to initialize or stopped answering, and was failed over.", sessionUnhealthyParams{}},
+	{"darkhold/session/reaped", eventScopeSessions, "An idle agent process was stopped.", sessionReapedParams{}},
+	{"darkhold/session/exited", eventScopeSessions, "An agent process exited.", sessionExitedParams{}},
+	{"darkhold/broadcast/progress", eventScopeBroadcast, "A broadcast turn/start target changed status.", broadcastProgressParams{}},
+	{"darkhold/broadcast/completed", eventScopeBroadcast, "Every target of a broadcast turn/start finished.", broadcastView{}},
+	{"darkhold/autoArchive/completed", eventScopeServer, "An auto-archive sweep archived or failed to archive threads.", autoArchiveRun{}},
+}
+
+// eventSchemaView is one entry of GET /api/events/schema.
+type eventSchemaView struct {
+	Method      string `json:"method"`
+	Scope       string `json:"scope"`
+	Description string `json:"description"`
+	// SSEEvent is the "event:" field thread streams send the method with.
+	SSEEvent string         `json:"sseEvent,omitempty"`
+	Params   map[string]any `json:"params"`
+}
+
+// handleEventSchema lists the events darkhold synthesizes, with a JSON
+// Schema for each one's params:
+//
+//	GET    {schemaVersion, events: [{method, scope, description, sseEvent, params}]}
+func (s *Server) handleEventSchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	views := make([]eventSchemaView, 0, len(darkholdEvents))
+	for _, doc := range darkholdEvents {
+		view := eventSchemaView{Method: doc.method, Scope: doc.scope, Description: doc.description, Params: jsonSchema(reflect.TypeOf(doc.params))}
+		if doc.scope == eventScopeThread || doc.scope == eventScopeDraft {
+			view.SSEEvent = sseEventName(doc.method)
+		}
+		views = append(views, view)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"schemaVersion": events.SchemaVersion, "events": views})
+}
+
+// jsonSchema describes how encoding/json encodes a value of type t. Struct
+// fields follow their json tags, with omitempty fields optional and the
+// rest required, and embedded structs flattened; an enum tag lists the
+// allowed values of a string field. Interfaces accept anything.
+func jsonSchema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": jsonSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchema(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]any{}
+		required := []string{}
+		addStructFields(t, properties, &required)
+		return map[string]any{"type": "object", "properties": properties, "required": required}
+	}
+	return map[string]any{}
+}
+
+func addStructFields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addStructFields(field.Type, properties, required)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema := jsonSchema(field.Type)
+		if enum := field.Tag.Get("enum"); enum != "" {
+			schema["enum"] = strings.Split(enum, ",")
+		}
+		properties[name] = schema
+		if !strings.Contains(","+options+",", ",omitempty,") {
+			*required = append(*required, name)
+		}
+	}
+}
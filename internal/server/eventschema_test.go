@@ -0,0 +1,99 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestEventSchemaCoversPublishedMethods(t *testing.T) {
+	listed := map[string]bool{}
+	for _, doc := range darkholdEvents {
+		if listed[doc.method] {
+			t.Errorf("%s is listed twice", doc.method)
+		}
+		listed[doc.method] = true
+	}
+	for state := range sessionStateOrder {
+		if !listed["darkhold/session/"+state] {
+			t.Errorf("darkhold/session/%s is not listed", state)
+		}
+	}
+
+	methodLiteral := regexp.MustCompile(`"(darkhold/[A-Za-z]+/[A-Za-z]+)"`)
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		source, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, match := range methodLiteral.FindAllStringSubmatch(string(source), -1) {
+			if !listed[match[1]] {
+				t.Errorf("%s uses %s, which /api/events/schema does not list", file, match[1])
+			}
+		}
+	}
+}
+
+func TestEventSchemaEndpoint(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	res, body := doJSON(t, http.MethodGet, s.http.URL+"/api/events/schema", nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", res.StatusCode, body)
+	}
+	if body["schemaVersion"] != float64(1) {
+		t.Fatalf("expected schemaVersion 1, got %v", body["schemaVersion"])
+	}
+	byMethod := map[string]map[string]any{}
+	for _, raw := range body["events"].([]any) {
+		event := raw.(map[string]any)
+		byMethod[event["method"].(string)] = event
+	}
+	if len(byMethod) != len(darkholdEvents) {
+		t.Fatalf("expected %d events, got %d", len(darkholdEvents), len(byMethod))
+	}
+
+	abandoned := byMethod["darkhold/rpc/abandoned"]
+	if abandoned["scope"] != eventScopeThread || abandoned["sseEvent"] != "darkhold" {
+		t.Fatalf("unexpected abandoned entry: %v", abandoned)
+	}
+	params := abandoned["params"].(map[string]any)
+	required := map[string]bool{}
+	for _, name := range params["required"].([]any) {
+		required[name.(string)] = true
+	}
+	if !required["reason"] || required["turnId"] {
+		t.Fatalf("expected reason required and turnId optional, got %v", params["required"])
+	}
+	reason := params["properties"].(map[string]any)["reason"].(map[string]any)
+	if reason["type"] != "string" || len(reason["enum"].([]any)) != 2 {
+		t.Fatalf("unexpected reason schema: %v", reason)
+	}
+
+	// Embedded structs are flattened, as encoding/json does.
+	draft := byMethod["darkhold/draft/changed"]["params"].(map[string]any)["properties"].(map[string]any)
+	if draft["version"].(map[string]any)["type"] != "integer" || draft["key"] == nil {
+		t.Fatalf("expected the draft entry fields in draft/changed, got %v", draft)
+	}
+	if byMethod["darkhold/draft/changed"]["sseEvent"] != "draft" {
+		t.Fatalf("expected draft/changed on the draft SSE event, got %v", byMethod["darkhold/draft/changed"])
+	}
+	exited := byMethod["darkhold/session/exited"]
+	if exited["scope"] != eventScopeSessions || exited["sseEvent"] != nil {
+		t.Fatalf("unexpected session/exited entry: %v", exited)
+	}
+	if exited["params"].(map[string]any)["properties"].(map[string]any)["threadIds"].(map[string]any)["type"] != "array" {
+		t.Fatalf("expected threadIds in session/exited, got %v", exited["params"])
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"darkhold-go/internal/events"
+)
+
+// ndjsonFlushEvery is how many records are written between flushes, so
+// slow clients see progress without a flush per line.
+const ndjsonFlushEvery = 256
+
+// handleThreadEventsNDJSON streams a thread's stored records:
+//
+//	GET ?threadId=&afterId=
+//
+// one {id, seq, ts, payload} JSON object per line, read from the store a
+// record at a time and sent chunked, so a long thread never sits in memory
+// as one array. A storage error after the first line aborts the response,
+// which the client sees as a truncated chunked body rather than a clean end.
+func (s *Server) handleThreadEventsNDJSON(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	query := r.URL.Query()
+	threadID := strings.TrimSpace(query.Get("threadId"))
+	if threadID == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+		return
+	}
+	afterID := strings.TrimSpace(query.Get("afterId"))
+	if afterID == "" {
+		if all, err := s.eventStore.ReadRange(threadID, "", 1); err == nil && len(all) == 0 {
+			s.backfillColdThread(r.Context(), threadID)
+		}
+	}
+
+	out := bufio.NewWriterSize(w, 32<<10)
+	controller := http.NewResponseController(w)
+	started := false
+	start := func() {
+		started = true
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set(eventSchemaHeader, strconv.Itoa(events.SchemaVersion))
+		w.WriteHeader(http.StatusOK)
+	}
+	written := 0
+	err := events.ScanRange(s.eventStore, threadID, afterID, func(record events.Record) error {
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		if !started {
+			start()
+		}
+		line, _ := json.Marshal(record)
+		if _, err := out.Write(append(line, '\n')); err != nil {
+			return err
+		}
+		written++
+		if written%ndjsonFlushEvery == 0 {
+			if err := out.Flush(); err != nil {
+				return err
+			}
+			_ = controller.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		if !started {
+			writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
+			return
+		}
+		if r.Context().Err() == nil {
+			log.Printf("[events] ndjson stream of thread %s failed after %d records: %v", threadID, written, err)
+		}
+		panic(http.ErrAbortHandler)
+	}
+	if !started {
+		start()
+	}
+	_ = out.Flush()
+}
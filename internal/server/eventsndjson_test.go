@@ -0,0 +1,60 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"darkhold-go/internal/events"
+)
+
+func TestThreadEventsNDJSON(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+	for i := range ndjsonFlushEvery + 44 {
+		s.app.publishThreadEvent("t-bulk", fmt.Sprintf(`{"method":"item/agentMessage/delta","params":{"threadId":"t-bulk","delta":"%d"}}`, i))
+	}
+
+	read := func(query string) (*http.Response, []events.Record) {
+		t.Helper()
+		resp, err := http.Get(s.http.URL + "/api/thread/events.ndjson?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var records []events.Record
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			var record events.Record
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				t.Fatalf("line %q is not a record: %v", scanner.Text(), err)
+			}
+			records = append(records, record)
+		}
+		return resp, records
+	}
+
+	resp, records := read("threadId=t-bulk")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/x-ndjson" || len(records) != ndjsonFlushEvery+44 {
+		t.Fatalf("unexpected stream: %d %q, %d records", resp.StatusCode, resp.Header.Get("Content-Type"), len(records))
+	}
+	if len(resp.TransferEncoding) != 1 || resp.TransferEncoding[0] != "chunked" {
+		t.Fatalf("expected a chunked response, got %v", resp.TransferEncoding)
+	}
+	if records[0].Seq == 0 || records[0].Time == 0 || records[1].ID <= records[0].ID {
+		t.Fatalf("expected stamped records in order, got %+v %+v", records[0], records[1])
+	}
+
+	_, tail := read("threadId=t-bulk&afterId=" + records[len(records)-3].ID)
+	if len(tail) != 2 || tail[0].ID != records[len(records)-2].ID {
+		t.Fatalf("expected the last two records, got %+v", tail)
+	}
+	if resp, empty := read("threadId=t-empty&afterId=" + records[0].ID); resp.StatusCode != http.StatusOK || len(empty) != 0 {
+		t.Fatalf("expected an empty stream, got %d with %d records", resp.StatusCode, len(empty))
+	}
+	if resp, _ := read(""); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 without threadId, got %d", resp.StatusCode)
+	}
+}
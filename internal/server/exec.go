@@ -0,0 +1,161 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	browserfs "darkhold-go/internal/fs"
+)
+
+const (
+	errCodeExecDisabled   = "EXEC_DISABLED"
+	errCodeExecNotAllowed = "EXEC_NOT_ALLOWED"
+
+	// execOutputLimit caps the captured bytes of stdout and of stderr.
+	execOutputLimit = 1 << 20
+	// execWaitDelay is how long output pipes may stay open after a timed-out
+	// command is killed, in case it left children holding them.
+	execWaitDelay = 2 * time.Second
+)
+
+// execResult is the outcome of one /api/exec command, returned to the caller
+// and published as darkhold/exec/completed. Error is set when the command
+// could not be started.
+type execResult struct {
+	ExecID     string `json:"execId"`
+	ThreadID   string `json:"threadId"`
+	Command    string `json:"command"`
+	Cwd        string `json:"cwd"`
+	ExitCode   int    `json:"exitCode"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	Truncated  bool   `json:"truncated,omitempty"`
+	TimedOut   bool   `json:"timedOut,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+}
+
+// cappedBuffer keeps the first limit bytes written to it and drops the rest,
+// so a chatty command cannot exhaust memory.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := b.limit - b.buf.Len(); room < n {
+		b.truncated = true
+		p = p[:max(room, 0)]
+	}
+	b.buf.Write(p)
+	return n, nil
+}
+
+// execAllowed reports whether command matches an --exec-allow pattern.
+func (s *Server) execAllowed(command string) bool {
+	for _, pattern := range s.cfg.ExecAllow {
+		if pattern.MatchString(command) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleExec runs an allowlisted command in a thread's cwd without involving
+// the agent. The command line is split on whitespace and run directly, not
+// through a shell. The call blocks until the command finishes; a client that
+// disconnects does not stop it, since the result is also published to the
+// thread.
+func (s *Server) handleExec(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if len(s.cfg.ExecAllow) == 0 {
+		writeError(w, http.StatusForbidden, errCodeExecDisabled, "command execution is disabled; allow commands with --exec-allow.")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
+	var request struct {
+		ThreadID  string `json:"threadId"`
+		Command   string `json:"command"`
+		TimeoutMs int64  `json:"timeoutMs"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+		return
+	}
+	threadID := strings.TrimSpace(request.ThreadID)
+	command := strings.Join(strings.Fields(request.Command), " ")
+	if threadID == "" || command == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId and command are required.")
+		return
+	}
+	if !s.execAllowed(command) {
+		writeErrorDetails(w, http.StatusForbidden, errCodeExecNotAllowed, "command is not on the --exec-allow list.", map[string]any{"command": command})
+		return
+	}
+	meta, ok := s.threadIndex.Get(threadID)
+	if !ok {
+		writeError(w, http.StatusNotFound, errCodeThreadNotFound, "thread not found.")
+		return
+	}
+	cwd, err := browserfs.ResolvePath(meta.Cwd)
+	if meta.Cwd == "" || err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidPath, "thread cwd is unknown or outside the base path.")
+		return
+	}
+	timeout := s.cfg.ExecTimeout
+	if requested := time.Duration(request.TimeoutMs) * time.Millisecond; requested > 0 && requested < timeout {
+		timeout = requested
+	}
+
+	result := execResult{ExecID: ulid.Make().String(), ThreadID: threadID, Command: command, Cwd: cwd}
+	s.publishExecEvent(threadID, "darkhold/exec/started", map[string]any{"threadId": threadID, "execId": result.ExecID, "command": command, "cwd": cwd})
+	s.runExec(context.WithoutCancel(r.Context()), timeout, &result)
+	s.publishExecEvent(threadID, "darkhold/exec/completed", result)
+	s.audit.record(r, "exec", map[string]any{"execId": result.ExecID, "threadId": threadID, "command": command, "cwd": cwd, "exitCode": result.ExitCode, "timedOut": result.TimedOut})
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) runExec(ctx context.Context, timeout time.Duration, result *execResult) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	args := strings.Fields(result.Command)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = result.Cwd
+	cmd.WaitDelay = execWaitDelay
+	stdout := &cappedBuffer{limit: execOutputLimit}
+	stderr := &cappedBuffer{limit: execOutputLimit}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	started := time.Now()
+	err := cmd.Run()
+	result.DurationMs = time.Since(started).Milliseconds()
+	result.Stdout, result.Stderr = stdout.buf.String(), stderr.buf.String()
+	result.Truncated = stdout.truncated || stderr.truncated
+	result.TimedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+	result.ExitCode = -1
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) && !result.TimedOut {
+		result.Error = err.Error()
+	}
+}
+
+func (s *Server) publishExecEvent(threadID, method string, params any) {
+	line, _ := json.Marshal(map[string]any{"method": method, "params": params})
+	s.publishThreadEvent(threadID, string(line))
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestCappedBufferTruncates(t *testing.T) {
+	b := &cappedBuffer{limit: 4}
+	if n, err := b.Write([]byte("abc")); n != 3 || err != nil {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if n, err := b.Write([]byte("defg")); n != 4 || err != nil {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if b.buf.String() != "abcd" || !b.truncated {
+		t.Fatalf("unexpected buffer %q (truncated %v)", b.buf.String(), b.truncated)
+	}
+}
+
+func TestExecIsDisabledWithoutAllowList(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	resp, body := doJSON(t, http.MethodPost, s.http.URL+"/api/exec", map[string]any{"threadId": "t", "command": "echo hi"})
+	if resp.StatusCode != http.StatusForbidden || body["code"] != errCodeExecDisabled {
+		t.Fatalf("expected EXEC_DISABLED, got %d %v", resp.StatusCode, body)
+	}
+}
+
+func TestExecRunsAllowlistedCommandsInThreadCwd(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.FakeApprovalRate = 0
+		cfg.ExecAllow = []*regexp.Regexp{regexp.MustCompile(`^(?:pwd|echo [a-z]+|sleep [0-9]+)$`)}
+		cfg.ExecTimeout = time.Minute
+	})
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+
+	resp, body := doJSON(t, http.MethodPost, s.http.URL+"/api/exec", map[string]any{"threadId": threadID, "command": "echo hi; rm -rf x"})
+	if resp.StatusCode != http.StatusForbidden || body["code"] != errCodeExecNotAllowed {
+		t.Fatalf("expected EXEC_NOT_ALLOWED, got %d %v", resp.StatusCode, body)
+	}
+
+	realBase, _ := filepath.EvalSymlinks(s.baseDir)
+	resp, body = doJSON(t, http.MethodPost, s.http.URL+"/api/exec", map[string]any{"threadId": threadID, "command": "  pwd "})
+	if resp.StatusCode != http.StatusOK || body["exitCode"] != float64(0) || strings.TrimSpace(body["stdout"].(string)) != realBase {
+		t.Fatalf("unexpected pwd result %d %v", resp.StatusCode, body)
+	}
+
+	resp, body = doJSON(t, http.MethodPost, s.http.URL+"/api/exec", map[string]any{"threadId": threadID, "command": "sleep 5", "timeoutMs": 100})
+	if resp.StatusCode != http.StatusOK || body["timedOut"] != true || body["exitCode"] != float64(-1) {
+		t.Fatalf("expected a timeout, got %d %v", resp.StatusCode, body)
+	}
+
+	events, _ := s.store.Read(threadID)
+	joined := strings.Join(events, "\n")
+	if strings.Count(joined, "darkhold/exec/started") != 2 || strings.Count(joined, "darkhold/exec/completed") != 2 {
+		t.Fatalf("expected exec events in the thread log, got %s", joined)
+	}
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServerRunsTurnsOnBuiltInFakeAgent(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	stream := openSSE(t, s.http.URL, threadID, "")
+	defer stream.Body.Close()
+
+	_ = postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "hello fake"}}})
+	acceptNextApproval(t, s.http.URL, threadID, stream)
+	waitForSSEEvent(t, stream, func(event sseEvent) bool {
+		return parseJSON(t, event.Data)["method"] == "turn/completed"
+	}, 10*time.Second)
+
+	_, report := doJSON(t, http.MethodGet, s.http.URL+"/api/usage", nil)
+	if global := report["global"].(map[string]any); global["turns"] != float64(1) || global["tokens"].(float64) <= 0 {
+		t.Fatalf("expected fake agent token usage to be tracked: %v", report)
+	}
+}
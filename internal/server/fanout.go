@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/tmaxmax/go-sse"
+
+	"darkhold-go/internal/config"
+	"darkhold-go/internal/redis"
+)
+
+// fanoutBus carries SSE messages between darkhold instances.
+type fanoutBus interface {
+	Publish(payload []byte)
+	Close()
+}
+
+// fanoutMessage is one SSE message as sent over the bus.
+type fanoutMessage struct {
+	Origin string   `json:"origin"`
+	Topics []string `json:"topics"`
+	Event  string   `json:"event"`
+}
+
+// fanoutProvider is an sse.Provider that also sends every published message
+// to the other instances on the bus, and publishes theirs to its own
+// subscribers. Subscriptions stay local.
+type fanoutProvider struct {
+	*sse.Joe
+	bus    fanoutBus
+	origin string
+	// onRemote runs after a message from another instance is published,
+	// with the message's data.
+	onRemote func(topics []string, payload string)
+}
+
+func newFanoutProvider(local *sse.Joe, onRemote func(topics []string, payload string)) *fanoutProvider {
+	origin := make([]byte, 6)
+	_, _ = rand.Read(origin)
+	return &fanoutProvider{Joe: local, origin: hex.EncodeToString(origin), onRemote: onRemote}
+}
+
+// openFanout wraps local in a Redis fan-out provider when --redis-url is set.
+// It returns nil when fan-out is disabled.
+func openFanout(cfg config.Config, local *sse.Joe, onRemote func(topics []string, payload string)) *fanoutProvider {
+	if cfg.RedisURL == "" {
+		return nil
+	}
+	p := newFanoutProvider(local, onRemote)
+	bus, err := redis.New(redis.Options{URL: cfg.RedisURL, Channel: cfg.RedisChannel}, p.receive)
+	if err != nil {
+		log.Printf("[fanout] disabled: %v", err)
+		return nil
+	}
+	p.bus = bus
+	log.Printf("[fanout] publishing SSE events on redis channel %s as instance %s", cfg.RedisChannel, p.origin)
+	return p
+}
+
+func (p *fanoutProvider) Publish(message *sse.Message, topics []string) error {
+	if err := p.Joe.Publish(message, topics); err != nil {
+		return err
+	}
+	event, err := message.MarshalText()
+	if err != nil {
+		return err
+	}
+	payload, _ := json.Marshal(fanoutMessage{Origin: p.origin, Topics: topics, Event: string(event)})
+	p.bus.Publish(payload)
+	return nil
+}
+
+// receive publishes a message from another instance to local subscribers.
+func (p *fanoutProvider) receive(payload []byte) {
+	var msg fanoutMessage
+	if err := json.Unmarshal(payload, &msg); err != nil || msg.Origin == p.origin || len(msg.Topics) == 0 {
+		return
+	}
+	message := &sse.Message{}
+	if err := message.UnmarshalText([]byte(msg.Event)); err != nil {
+		log.Printf("[fanout] dropping malformed event from %s: %v", msg.Origin, err)
+		return
+	}
+	if err := p.Joe.Publish(message, msg.Topics); err != nil {
+		log.Printf("[fanout] failed to broadcast event from %s: %v", msg.Origin, err)
+	}
+	if p.onRemote != nil {
+		p.onRemote(msg.Topics, fanoutEventData(msg.Event))
+	}
+}
+
+// fanoutEventData joins the data lines of a serialized SSE event.
+func fanoutEventData(event string) string {
+	var data []string
+	for line := range strings.SplitSeq(event, "\n") {
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data = append(data, strings.TrimPrefix(value, " "))
+		}
+	}
+	return strings.Join(data, "\n")
+}
+
+func (p *fanoutProvider) Shutdown(ctx context.Context) error {
+	p.bus.Close()
+	return p.Joe.Shutdown(ctx)
+}
+
+// invalidateRemoteTopics drops cached RPC results for threads that changed on
+// another instance.
+func (s *Server) invalidateRemoteTopics(topics []string, payload string) {
+	for _, topic := range topics {
+		s.invalidateThreadEvent(topic, payload)
+	}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tmaxmax/go-sse"
+
+	"darkhold-go/internal/config"
+)
+
+// memoryBus delivers every published payload to all joined providers, like a
+// Redis channel shared by several instances.
+type memoryBus struct {
+	mu      sync.Mutex
+	members []*fanoutProvider
+}
+
+type memoryBusMember struct{ bus *memoryBus }
+
+func (m memoryBusMember) Publish(payload []byte) {
+	m.bus.mu.Lock()
+	members := append([]*fanoutProvider(nil), m.bus.members...)
+	m.bus.mu.Unlock()
+	for _, p := range members {
+		p.receive(payload)
+	}
+}
+
+func (m memoryBusMember) Close() {}
+
+// join swaps s onto a fan-out provider attached to the bus.
+func (b *memoryBus) join(s *Server) {
+	p := newFanoutProvider(s.sseProvider.(*sse.Joe), s.invalidateRemoteTopics)
+	p.bus = memoryBusMember{b}
+	b.mu.Lock()
+	b.members = append(b.members, p)
+	b.mu.Unlock()
+	s.fanout = p
+	s.sseProvider = p
+}
+
+func TestFanoutDeliversEventsToOtherInstances(t *testing.T) {
+	noApprovals := func(cfg *config.Config) { cfg.FakeApprovalRate = 0 }
+	a := startIntegrationServer(t, noApprovals)
+	defer a.close()
+	b := startIntegrationServer(t, noApprovals)
+	defer b.close()
+	bus := &memoryBus{}
+	bus.join(a.app)
+	bus.join(b.app)
+
+	started := postRPC[map[string]any](t, a.http.URL, "thread/start", map[string]any{"cwd": a.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	streamA := openSSE(t, a.http.URL, threadID, "")
+	defer streamA.Body.Close()
+	streamB := openSSE(t, b.http.URL, threadID, "")
+	defer streamB.Body.Close()
+
+	_ = postRPC[map[string]any](t, a.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "hello"}}})
+	completed := func(event sseEvent) bool { return parseJSON(t, event.Data)["method"] == "turn/completed" }
+	local := waitForSSEEvent(t, streamA, completed, 10*time.Second)
+	remote := waitForSSEEvent(t, streamB, completed, 10*time.Second)
+	if remote.ID != local.ID || remote.Data != local.Data {
+		t.Fatalf("remote event %+v differs from local %+v", remote, local)
+	}
+}
+
+func TestFanoutEventData(t *testing.T) {
+	msg := newThreadEventMessage("7", `{"method":"turn/completed"}`)
+	event, _ := msg.MarshalText()
+	if got := fanoutEventData(string(event)); got != `{"method":"turn/completed"}` {
+		t.Fatalf("unexpected data %q from %q", got, event)
+	}
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Quarantine bounds: stale events kept per thread, threads kept, and bytes
+// kept of each event.
+const (
+	quarantinePerThread = 50
+	quarantineThreads   = 256
+	quarantineLineBytes = 4096
+)
+
+// quarantinedEvent is an agent message held back because it came from a
+// session that no longer owns the thread.
+type quarantinedEvent struct {
+	ThreadID       string `json:"threadId"`
+	SessionID      int    `json:"sessionId"`
+	OwnerSessionID int    `json:"ownerSessionId"`
+	Epoch          uint64 `json:"epoch"`
+	Method         string `json:"method"`
+	At             int64  `json:"at"`
+	Line           string `json:"line"`
+	Truncated      bool   `json:"truncated,omitempty"`
+}
+
+type quarantine struct {
+	mu     sync.Mutex
+	events map[string][]quarantinedEvent
+	order  []string // threads, oldest first
+	fenced map[string]map[int]bool
+	total  int64
+}
+
+// add stores an event and reports whether it is the first one quarantined
+// from that session for the thread.
+func (q *quarantine) add(event quarantinedEvent) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.events == nil {
+		q.events, q.fenced = map[string][]quarantinedEvent{}, map[string]map[int]bool{}
+	}
+	if len(event.Line) > quarantineLineBytes {
+		event.Line, event.Truncated = event.Line[:quarantineLineBytes], true
+	}
+	if _, seen := q.events[event.ThreadID]; !seen {
+		q.order = append(q.order, event.ThreadID)
+		if len(q.order) > quarantineThreads {
+			oldest := q.order[0]
+			q.order = q.order[1:]
+			delete(q.events, oldest)
+			delete(q.fenced, oldest)
+		}
+	}
+	list := append(q.events[event.ThreadID], event)
+	if len(list) > quarantinePerThread {
+		list = list[len(list)-quarantinePerThread:]
+	}
+	q.events[event.ThreadID] = list
+	q.total++
+	if q.fenced[event.ThreadID] == nil {
+		q.fenced[event.ThreadID] = map[int]bool{}
+	}
+	first := !q.fenced[event.ThreadID][event.SessionID]
+	q.fenced[event.ThreadID][event.SessionID] = true
+	return first
+}
+
+func (q *quarantine) list(threadID string) (int64, []quarantinedEvent) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]quarantinedEvent, 0)
+	for _, id := range q.order {
+		if threadID == "" || id == threadID {
+			out = append(out, q.events[id]...)
+		}
+	}
+	return q.total, out
+}
+
+// admitThreadEvent is the per-thread fence: it reports whether sess may add
+// events to the thread. The session bound to the thread may; so may any
+// session when the thread is unbound or its session has exited, in which
+// case sess takes the thread over. Anything else comes from a stale session,
+// for example one still flushing a turn after the thread was resumed
+// elsewhere, and is refused with the current owner and fence epoch.
+func (s *Server) admitThreadEvent(threadID string, sess *session) (ok bool, owner int, epoch uint64) {
+	s.sessionsMu.Lock()
+	owner, bound := s.threadToSession[threadID]
+	if bound && owner != sess.id {
+		if current, alive := s.sessions[owner]; alive {
+			current.mu.Lock()
+			closed := current.closed
+			current.mu.Unlock()
+			if !closed {
+				epoch = s.threadEpochs[threadID]
+				s.sessionsMu.Unlock()
+				return false, owner, epoch
+			}
+		}
+	}
+	s.sessionsMu.Unlock()
+	s.bindThreadToSession(threadID, sess)
+	return true, sess.id, 0
+}
+
+// quarantineThreadEvent keeps a stale session's message out of the thread
+// log. The first one from each session is announced on the thread as
+// darkhold/thread/fenced.
+func (s *Server) quarantineThreadEvent(threadID string, sess *session, owner int, epoch uint64, method, line string) {
+	first := s.quarantine.add(quarantinedEvent{
+		ThreadID:       threadID,
+		SessionID:      sess.id,
+		OwnerSessionID: owner,
+		Epoch:          epoch,
+		Method:         method,
+		At:             time.Now().UnixMilli(),
+		Line:           line,
+	})
+	if !first {
+		return
+	}
+	log.Printf("[session=%d] quarantining %s for thread %s: owned by session %d (epoch %d)", sess.id, method, threadID, owner, epoch)
+	notice, _ := json.Marshal(map[string]any{
+		"method": "darkhold/thread/fenced",
+		"params": map[string]any{
+			"threadId":       threadID,
+			"sessionId":      sess.id,
+			"ownerSessionId": owner,
+			"epoch":          epoch,
+			"method":         method,
+		},
+	})
+	s.publishThreadEvent(threadID, string(notice))
+}
+
+// handleAdminQuarantine lists messages held back by the thread fence:
+//
+//	GET [?threadId=]    {total, events}
+//
+// total counts every quarantined message since start; events keeps the
+// latest of each thread.
+func (s *Server) handleAdminQuarantine(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	total, events := s.quarantine.list(strings.TrimSpace(r.URL.Query().Get("threadId")))
+	writeJSON(w, http.StatusOK, map[string]any{"total": total, "events": events})
+}
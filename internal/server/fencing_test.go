@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestThreadFenceKeepsOneSessionsOrder documents the ordering guarantee: a
+// thread's log only takes events from the session that owns the thread, in
+// the order that session sent them. Late output from a session the thread
+// moved away from is quarantined instead of interleaved.
+func TestThreadFenceKeepsOneSessionsOrder(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	const threadID = "thread-fenced"
+	stale, _ := injectSession(s.app, 901)
+	owner, _ := injectSession(s.app, 902)
+	defer removeSession(s.app, stale)
+	defer removeSession(s.app, owner)
+	delta := func(text string) string {
+		line, _ := json.Marshal(map[string]any{"method": "item/agentMessage/delta", "params": map[string]any{"threadId": threadID, "delta": text}})
+		return string(line)
+	}
+
+	s.app.handleSessionLine(stale, delta("old-1"))
+	// The thread is resumed on another session while the first still flushes.
+	s.app.bindThreadToSession(threadID, owner)
+	for i, sess := range []*session{stale, owner, stale, owner, stale, owner} {
+		s.app.handleSessionLine(sess, delta([]string{"late-a", "new-1", "late-b", "new-2", "late-c", "new-3"}[i]))
+	}
+
+	events, _ := s.store.Read(threadID)
+	var deltas []string
+	fenced := 0
+	for _, event := range events {
+		msg := parseJSON(t, event)
+		switch msg["method"] {
+		case "item/agentMessage/delta":
+			deltas = append(deltas, msg["params"].(map[string]any)["delta"].(string))
+		case "darkhold/thread/fenced":
+			fenced++
+			if params := msg["params"].(map[string]any); params["sessionId"] != float64(901) || params["ownerSessionId"] != float64(902) || params["epoch"] != float64(2) {
+				t.Fatalf("unexpected fence notice: %v", params)
+			}
+		}
+	}
+	if strings.Join(deltas, ",") != "old-1,new-1,new-2,new-3" {
+		t.Fatalf("expected only the owner's events after the move, in order: %v", deltas)
+	}
+	if fenced != 1 {
+		t.Fatalf("expected one fence notice for the stale session, got %d", fenced)
+	}
+
+	_, quarantined := doJSON(t, http.MethodGet, s.http.URL+"/api/admin/quarantine?threadId="+threadID, nil)
+	if list, _ := quarantined["events"].([]any); quarantined["total"] != float64(3) || len(list) != 3 || list[0].(map[string]any)["method"] != "item/agentMessage/delta" {
+		t.Fatalf("unexpected quarantine: %v", quarantined)
+	}
+
+	// Once the owner exits, another session may take the thread over.
+	owner.mu.Lock()
+	owner.closed = true
+	owner.mu.Unlock()
+	s.app.handleSessionLine(stale, delta("adopted"))
+	if events, _ := s.store.Read(threadID); !strings.Contains(events[len(events)-1], "adopted") {
+		t.Fatalf("expected a live session to adopt a thread whose owner exited: %v", events[len(events)-1])
+	}
+}
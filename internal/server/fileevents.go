@@ -0,0 +1,77 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+
+	browserfs "darkhold-go/internal/fs"
+)
+
+// fileChangeMethods are the notifications whose item may be a fileChange.
+var fileChangeMethods = map[string]bool{
+	"item/started":   true,
+	"item/updated":   true,
+	"item/completed": true,
+}
+
+// annotateFileChanges adds language and size to each change of a fileChange
+// item or file-change approval request, so clients can pick a highlighter
+// and skip fetching huge files. language is omitted when unknown, size when
+// the file does not exist (yet) or resolves outside the browser root.
+// Relative paths resolve against the thread cwd. It reports whether params
+// changed.
+func (s *Server) annotateFileChanges(threadID, method string, params map[string]any) bool {
+	var changes []map[string]any
+	collect := func(raw any) {
+		switch list := raw.(type) {
+		case []any:
+			for _, entry := range list {
+				if change, ok := entry.(map[string]any); ok {
+					changes = append(changes, change)
+				}
+			}
+		case map[string]any:
+			// applyPatchApproval keys its changes by path.
+			for path, entry := range list {
+				if change, ok := entry.(map[string]any); ok {
+					if _, set := change["path"]; !set {
+						change["path"] = path
+					}
+					changes = append(changes, change)
+				}
+			}
+		}
+	}
+	switch {
+	case fileChangeMethods[method]:
+		if item, ok := params["item"].(map[string]any); ok && item["type"] == "fileChange" {
+			collect(item["changes"])
+		}
+	case method == "item/fileChange/requestApproval", method == "applyPatchApproval":
+		collect(params["changes"])
+		collect(params["fileChanges"])
+	}
+	if len(changes) == 0 {
+		return false
+	}
+	meta, _ := s.threadIndex.Get(threadID)
+	for i, change := range changes {
+		path := normalizeFileChange(change, i).Path
+		language := browserfs.LanguageForName(path)
+		if !filepath.IsAbs(path) && meta.Cwd != "" {
+			path = filepath.Join(meta.Cwd, path)
+		}
+		if filepath.IsAbs(path) && browserfs.IsWithinRoot(path) {
+			if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+				change["size"] = info.Size()
+				if language == "" {
+					language = browserfs.DetectLanguage(path)
+				}
+			}
+		}
+		if language != "" {
+			change["language"] = language
+		}
+	}
+	return true
+}
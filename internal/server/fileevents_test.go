@@ -0,0 +1,57 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"darkhold-go/internal/threads"
+)
+
+func TestFileChangeEventsCarryLanguageAndSize(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	if _, err := s.app.threadIndex.Update("t-files", func(m *threads.Metadata) error {
+		m.ThreadID, m.Cwd = "t-files", s.baseDir
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(s.baseDir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(s.baseDir, "run"), []byte("#!/bin/bash\necho hi\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	params := map[string]any{"threadId": "t-files", "item": map[string]any{"type": "fileChange", "changes": []any{
+		map[string]any{"path": "main.go", "kind": "update"},
+		map[string]any{"path": filepath.Join(s.baseDir, "run"), "kind": "update"},
+		map[string]any{"path": "web/app.tsx", "kind": "add"},
+		map[string]any{"path": "/etc/hosts", "kind": "update"},
+	}}}
+	if !s.app.annotateFileChanges("t-files", "item/completed", params) {
+		t.Fatal("expected the fileChange item to be annotated")
+	}
+	changes := params["item"].(map[string]any)["changes"].([]any)
+	want := []struct {
+		language string
+		size     any
+	}{{"go", int64(13)}, {"shell", int64(20)}, {"tsx", nil}, {"", nil}}
+	for i, w := range want {
+		change := changes[i].(map[string]any)
+		if language, _ := change["language"].(string); language != w.language || change["size"] != w.size {
+			t.Fatalf("change %d: unexpected annotation %v", i, change)
+		}
+	}
+
+	approval := map[string]any{"threadId": "t-files", "fileChanges": map[string]any{"main.go": map[string]any{"type": "update"}}}
+	s.app.annotateFileChanges("t-files", "applyPatchApproval", approval)
+	if change := approval["fileChanges"].(map[string]any)["main.go"].(map[string]any); change["language"] != "go" {
+		t.Fatalf("expected approval changes to be annotated: %v", change)
+	}
+	if s.app.annotateFileChanges("t-files", "item/completed", map[string]any{"item": map[string]any{"type": "agentMessage"}}) {
+		t.Fatal("expected other items to be left alone")
+	}
+}
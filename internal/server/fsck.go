@@ -0,0 +1,42 @@
+package server
+
+import (
+	"log"
+	"net/http"
+
+	"darkhold-go/internal/events"
+)
+
+const (
+	errCodeFsckUnsupported = "FSCK_UNSUPPORTED"
+	errCodeFsckFailed      = "FSCK_FAILED"
+)
+
+// handleAdminFsck checks the JSONL event store, the same check as
+// `darkhold fsck`:
+//
+//	GET     report issues without changing anything
+//	POST    repair what can be repaired, then report
+//
+// Both answer with events.FsckReport.
+func (s *Server) handleAdminFsck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	store, ok := s.eventStore.(*events.Store)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, errCodeFsckUnsupported, "integrity checks need the jsonl event store.")
+		return
+	}
+	repair := r.Method == http.MethodPost
+	report, err := store.Fsck(repair)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, errCodeFsckFailed, err.Error())
+		return
+	}
+	if repair && report.Repaired > 0 {
+		log.Printf("[fsck] repaired %d issues for %s, %d left", report.Repaired, clientIdentity(r.Context()), report.Unrepaired)
+	}
+	writeJSON(w, http.StatusOK, report)
+}
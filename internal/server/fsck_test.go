@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"darkhold-go/internal/events"
+)
+
+func TestAdminFsckReportsAndRepairsTruncatedLogs(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	s.app.publishThreadEvent("thread-crashed", `{"method":"turn/started","params":{"turnId":"t1"}}`)
+	path := filepath.Join(s.store.RootDir, s.store.Key("thread-crashed")+".jsonl")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"id":"01J0000000000000000000000","seq":`)
+	f.Close()
+
+	resp, check := doJSON(t, http.MethodGet, s.http.URL+"/api/admin/fsck", nil)
+	issues, _ := check["issues"].([]any)
+	if resp.StatusCode != http.StatusOK || len(issues) != 1 || issues[0].(map[string]any)["kind"] != events.IssueTruncatedLine || check["unrepaired"] != float64(1) {
+		t.Fatalf("unexpected check: %d %v", resp.StatusCode, check)
+	}
+	resp, repaired := doJSON(t, http.MethodPost, s.http.URL+"/api/admin/fsck", nil)
+	if resp.StatusCode != http.StatusOK || repaired["repaired"] != float64(1) || repaired["unrepaired"] != float64(0) {
+		t.Fatalf("unexpected repair: %d %v", resp.StatusCode, repaired)
+	}
+
+	s.app.publishThreadEvent("thread-crashed", `{"method":"turn/completed","params":{"turnId":"t1"}}`)
+	if stored, err := s.store.Read("thread-crashed"); err != nil || len(stored) != 2 {
+		t.Fatalf("expected appends to continue cleanly after repair: %v %v", stored, err)
+	}
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	browserfs "darkhold-go/internal/fs"
+	"darkhold-go/internal/places"
+)
+
+const (
+	// maxFavoriteNameBytes bounds the label a user gives a favorite.
+	maxFavoriteNameBytes = 200
+
+	defaultCompletions = 20
+	maxCompletions     = 100
+)
+
+func openPlaces(dataDir string) *places.Store {
+	path := ""
+	if dataDir != "" {
+		path = filepath.Join(dataDir, "fs-places.json")
+	}
+	store, err := places.Open(path)
+	if err != nil {
+		log.Printf("[places] failed to load %s, using in-memory places: %v", path, err)
+		store, _ = places.Open("")
+	}
+	return store
+}
+
+// recordRecentCwd remembers the cwd of a thread that was started or resumed,
+// for the folder picker's recent list. Cwds outside the browser root are
+// never offered, so they are not recorded.
+func (s *Server) recordRecentCwd(threadObj map[string]any) {
+	cwd, _ := threadObj["cwd"].(string)
+	if cwd == "" {
+		return
+	}
+	resolved, err := browserfs.ResolvePath(cwd)
+	if err != nil {
+		return
+	}
+	if err := s.places.Touch(resolved); err != nil {
+		log.Printf("[places] failed to record recent directory %s: %v", resolved, err)
+	}
+}
+
+// resolvePlaceDir resolves a directory inside the browser root.
+func resolvePlaceDir(path string) (string, bool) {
+	resolved, err := browserfs.ResolvePath(path)
+	if err != nil {
+		return "", false
+	}
+	info, err := os.Stat(resolved)
+	return resolved, err == nil && info.IsDir()
+}
+
+// handleFSRecent lists directories recently used as thread cwds, most recent
+// first: GET /api/fs/recent. DELETE /api/fs/recent?path= forgets one.
+// Directories that were removed or fall outside the current root are left out.
+func (s *Server) handleFSRecent(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		recent := []places.Recent{}
+		for _, entry := range s.places.Recent() {
+			if resolved, ok := resolvePlaceDir(entry.Path); ok && resolved == entry.Path {
+				recent = append(recent, entry)
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"recent": recent})
+	case http.MethodDelete:
+		path := strings.TrimSpace(r.URL.Query().Get("path"))
+		if path == "" {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "path is required.")
+			return
+		}
+		removed, err := s.places.Forget(filepath.Clean(path))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"removed": removed})
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+// handleFSFavorites lists, stars and unstars favorite directories:
+// GET /api/fs/favorites, POST {path, name?} and DELETE ?path=.
+func (s *Server) handleFSFavorites(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		favorites := []places.Favorite{}
+		for _, entry := range s.places.Favorites() {
+			if resolved, ok := resolvePlaceDir(entry.Path); ok && resolved == entry.Path {
+				favorites = append(favorites, entry)
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"favorites": favorites})
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
+		var request struct {
+			Path string `json:"path"`
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+			return
+		}
+		request.Name = strings.TrimSpace(request.Name)
+		if len(request.Name) > maxFavoriteNameBytes {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "name is too long.")
+			return
+		}
+		resolved, ok := resolvePlaceDir(request.Path)
+		if !ok {
+			writeError(w, http.StatusBadRequest, errCodeInvalidPath, "path must be a directory inside the browser root.")
+			return
+		}
+		favorite, err := s.places.AddFavorite(resolved, request.Name)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, favorite)
+	case http.MethodDelete:
+		path := strings.TrimSpace(r.URL.Query().Get("path"))
+		if path == "" {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "path is required.")
+			return
+		}
+		// Favorites are stored resolved; a path that no longer resolves is
+		// matched as given, so removed directories can still be unstarred.
+		target := filepath.Clean(path)
+		if resolved, err := browserfs.ResolvePath(path); err == nil {
+			target = resolved
+		}
+		removed, err := s.places.RemoveFavorite(target)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"removed": removed})
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+// fsCompletion is a directory completion with what ranks it.
+type fsCompletion struct {
+	browserfs.Completion
+	// LastUsedAt is the latest use of the directory, or of one below it, as
+	// a thread cwd.
+	LastUsedAt int64 `json:"lastUsedAt,omitempty"`
+	Favorite   bool  `json:"favorite,omitempty"`
+}
+
+// handleFSComplete offers type-ahead for the folder picker:
+// GET /api/fs/complete?prefix=~/wo[&limit=20]. Directories completing the
+// prefix are ranked by when they, or a directory below them, were last used
+// as a thread cwd, then favorites first, then by name.
+func (s *Server) handleFSComplete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	query := r.URL.Query()
+	limit := defaultCompletions
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxCompletions {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, fmt.Sprintf("limit must be between 1 and %d.", maxCompletions))
+			return
+		}
+		limit = n
+	}
+	prefix := query.Get("prefix")
+	found, err := browserfs.CompleteDirectory(prefix)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidPath, err.Error())
+		return
+	}
+
+	recent := s.places.Recent()
+	favorites := map[string]bool{}
+	for _, favorite := range s.places.Favorites() {
+		favorites[favorite.Path] = true
+	}
+	completions := make([]fsCompletion, 0, len(found))
+	for _, completion := range found {
+		entry := fsCompletion{Completion: completion, Favorite: favorites[completion.Path]}
+		for _, used := range recent {
+			if browserfs.PathWithin(used.Path, completion.Path) {
+				entry.LastUsedAt = max(entry.LastUsedAt, used.LastUsedAt)
+			}
+		}
+		completions = append(completions, entry)
+	}
+	sort.SliceStable(completions, func(i, j int) bool {
+		a, b := completions[i], completions[j]
+		if a.LastUsedAt != b.LastUsedAt {
+			return a.LastUsedAt > b.LastUsedAt
+		}
+		return a.Favorite && !b.Favorite
+	})
+	if len(completions) > limit {
+		completions = completions[:limit]
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"prefix": prefix, "completions": completions})
+}
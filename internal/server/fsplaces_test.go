@@ -0,0 +1,87 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"darkhold-go/internal/config"
+)
+
+func TestFSRecentAndFavorites(t *testing.T) {
+	dataDir := t.TempDir()
+	s := startIntegrationServer(t, func(cfg *config.Config) { cfg.DataDir = dataDir })
+	defer s.close()
+
+	service := filepath.Join(s.baseDir, "work", "org", "service")
+	if err := os.MkdirAll(service, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	service, _ = filepath.EvalSymlinks(service)
+	_ = postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": service})
+
+	_, body := doJSON(t, http.MethodGet, s.http.URL+"/api/fs/recent", nil)
+	recent, _ := body["recent"].([]any)
+	if len(recent) != 1 || recent[0].(map[string]any)["path"] != service || recent[0].(map[string]any)["uses"] != float64(1) {
+		t.Fatalf("expected the thread cwd in recent directories, got %v", body)
+	}
+
+	resp, body := doJSON(t, http.MethodPost, s.http.URL+"/api/fs/favorites", map[string]any{"path": service, "name": "Service"})
+	if resp.StatusCode != http.StatusOK || body["path"] != service || body["name"] != "Service" {
+		t.Fatalf("expected the favorite to be stored, got %d %v", resp.StatusCode, body)
+	}
+	resp, body = doJSON(t, http.MethodPost, s.http.URL+"/api/fs/favorites", map[string]any{"path": filepath.Dir(s.baseDir)})
+	if resp.StatusCode != http.StatusBadRequest || body["code"] != errCodeInvalidPath {
+		t.Fatalf("expected a directory outside the root to be refused, got %d %v", resp.StatusCode, body)
+	}
+
+	reopened := openPlaces(dataDir)
+	if favorites := reopened.Favorites(); len(favorites) != 1 || favorites[0].Path != service {
+		t.Fatalf("expected the favorite to be persisted, got %+v", favorites)
+	}
+	if recents := reopened.Recent(); len(recents) != 1 {
+		t.Fatalf("expected the recent directory to be persisted, got %+v", recents)
+	}
+
+	if err := os.RemoveAll(service); err != nil {
+		t.Fatal(err)
+	}
+	_, body = doJSON(t, http.MethodGet, s.http.URL+"/api/fs/favorites", nil)
+	if favorites, _ := body["favorites"].([]any); len(favorites) != 0 {
+		t.Fatalf("expected removed directories to be left out, got %v", body)
+	}
+	_, body = doJSON(t, http.MethodDelete, s.http.URL+"/api/fs/favorites?"+url.Values{"path": {service}}.Encode(), nil)
+	if body["removed"] != true {
+		t.Fatalf("expected a removed directory to still be unstarred, got %v", body)
+	}
+}
+
+func TestFSCompleteRanksByRecency(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	for _, dir := range []string{"work", "workshop/app"} {
+		if err := os.MkdirAll(filepath.Join(s.baseDir, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	app, _ := filepath.EvalSymlinks(filepath.Join(s.baseDir, "workshop", "app"))
+	_ = postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": app})
+
+	resp, body := doJSON(t, http.MethodGet, s.http.URL+"/api/fs/complete?"+url.Values{"prefix": {"~/wo"}}.Encode(), nil)
+	completions, _ := body["completions"].([]any)
+	if resp.StatusCode != http.StatusOK || len(completions) != 2 {
+		t.Fatalf("expected two completions, got %d %v", resp.StatusCode, body)
+	}
+	first := completions[0].(map[string]any)
+	if first["value"] != "~/workshop/" || first["lastUsedAt"] == nil {
+		t.Fatalf("expected the recently used directory first, got %v", completions)
+	}
+
+	resp, body = doJSON(t, http.MethodGet, s.http.URL+"/api/fs/complete?"+url.Values{"prefix": {"~/../"}}.Encode(), nil)
+	if resp.StatusCode != http.StatusBadRequest || body["code"] != errCodeInvalidPath {
+		t.Fatalf("expected a prefix outside the root to be refused, got %d %v", resp.StatusCode, body)
+	}
+}
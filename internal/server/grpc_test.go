@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"darkhold-go/internal/config"
+	"darkhold-go/internal/grpcapi"
+	pb "darkhold-go/internal/grpcapi/darkholdv1"
+)
+
+func TestGRPCAPIDrivesThreads(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.FakeApprovalRate = 0
+	})
+	defer s.close()
+
+	listener := bufconn.Listen(1 << 20)
+	gs := grpcapi.NewServer(s.app.Handler())
+	go func() { _ = gs.Serve(listener) }()
+	defer gs.Stop()
+	conn, err := grpc.NewClient("passthrough:///darkhold",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	thread, err := pb.NewThreadServiceClient(conn).StartThread(ctx, &pb.StartThreadRequest{Cwd: s.baseDir})
+	if err != nil || thread.ThreadId == "" {
+		t.Fatalf("thread/start: %v, %v", thread, err)
+	}
+	stream, err := pb.NewEventServiceClient(conn).Subscribe(ctx, &pb.SubscribeRequest{ThreadId: thread.ThreadId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	turn, err := pb.NewTurnServiceClient(conn).StartTurn(ctx, &pb.StartTurnRequest{ThreadId: thread.ThreadId, Text: "hello"})
+	if err != nil || turn.TurnId == "" {
+		t.Fatalf("turn/start: %v, %v", turn, err)
+	}
+	lastSeq := int64(0)
+	for {
+		got, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("waiting for turn/completed: %v", err)
+		}
+		if got.Event.Seq <= lastSeq {
+			t.Fatalf("expected events in log order, got seq %d after %d", got.Event.Seq, lastSeq)
+		}
+		lastSeq = got.Event.Seq
+		if got.Event.Method == "turn/completed" {
+			break
+		}
+	}
+
+	threads, err := pb.NewThreadServiceClient(conn).ListThreads(ctx, &pb.ListThreadsRequest{})
+	if err != nil || len(threads.Threads) == 0 || threads.Threads[0].ThreadId != thread.ThreadId {
+		t.Fatalf("unexpected thread list: %v, %v", threads, err)
+	}
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"darkhold-go/internal/events"
+	"darkhold-go/internal/threads"
+)
+
+// historyImportPageSize is the thread/list page size used while importing.
+const historyImportPageSize = 50
+
+// historyImport records a finished import in <data-dir>/history-import.json,
+// so it only runs once per data dir.
+type historyImport struct {
+	CompletedAt int64 `json:"completedAt"`
+	Threads     int   `json:"threads"`
+	Skipped     int   `json:"skipped"`
+	Events      int   `json:"events"`
+}
+
+func (s *Server) historyImportPath() string {
+	if s.cfg.DataDir == "" {
+		return ""
+	}
+	return filepath.Join(s.cfg.DataDir, "history-import.json")
+}
+
+// importCodexHistoryOnce runs importCodexHistory unless a previous run
+// already completed for this data dir.
+func (s *Server) importCodexHistoryOnce() {
+	path := s.historyImportPath()
+	if path != "" {
+		if _, err := os.Stat(path); err == nil {
+			return
+		}
+	}
+	result, err := s.importCodexHistory(context.Background())
+	if err != nil {
+		log.Printf("[history] import failed: %v", err)
+		return
+	}
+	log.Printf("[history] imported %d thread(s) (%d events); %d already known", result.Threads, result.Events, result.Skipped)
+	if path == "" {
+		return
+	}
+	data, _ := json.MarshalIndent(result, "", "  ")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		log.Printf("[history] failed to record import: %v", err)
+	}
+}
+
+// importCodexHistory pages through thread/list and, for every thread without
+// a local event log, rebuilds one from thread/read turns and fills in its
+// metadata, so conversations started outside darkhold appear in the web UI.
+func (s *Server) importCodexHistory(ctx context.Context) (historyImport, error) {
+	var result historyImport
+	known := map[string]bool{}
+	logs, err := s.eventStore.Logs()
+	if err != nil {
+		return result, err
+	}
+	for _, l := range logs {
+		known[l.Key] = true
+	}
+
+	cursor := ""
+	for {
+		params := map[string]any{"limit": historyImportPageSize}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		response, err := s.dispatchRPC(ctx, "thread/list", params)
+		if err != nil {
+			return result, err
+		}
+		if errObj, ok := response["error"].(map[string]any); ok {
+			return result, fmt.Errorf("thread/list: %v", errObj["message"])
+		}
+		page, _ := response["result"].(map[string]any)
+		data, _ := page["data"].([]any)
+		for _, raw := range data {
+			summary, _ := raw.(map[string]any)
+			threadID, _ := summary["id"].(string)
+			if threadID == "" {
+				continue
+			}
+			if known[s.eventStore.Key(threadID)] {
+				result.Skipped++
+				continue
+			}
+			count, err := s.importThread(ctx, threadID, summary)
+			if err != nil {
+				log.Printf("[history] failed to import thread %s: %v", threadID, err)
+				continue
+			}
+			result.Threads++
+			result.Events += count
+		}
+		cursor, _ = page["nextCursor"].(string)
+		if cursor == "" || len(data) == 0 {
+			break
+		}
+	}
+	result.CompletedAt = time.Now().UnixMilli()
+	return result, nil
+}
+
+// skipRehydrateKey marks dispatches whose thread/read result the caller
+// records itself, so dispatchRPC does not also backfill it.
+type skipRehydrateKey struct{}
+
+func skipRehydrate(ctx context.Context) bool {
+	skip, _ := ctx.Value(skipRehydrateKey{}).(bool)
+	return skip
+}
+
+func (s *Server) importThread(ctx context.Context, threadID string, summary map[string]any) (int, error) {
+	ctx = context.WithValue(ctx, skipRehydrateKey{}, true)
+	response, err := s.dispatchRPC(ctx, "thread/read", map[string]any{"threadId": threadID, "includeTurns": true})
+	if err != nil {
+		return 0, err
+	}
+	if errObj, ok := response["error"].(map[string]any); ok {
+		return 0, fmt.Errorf("thread/read: %v", errObj["message"])
+	}
+	result, _ := response["result"].(map[string]any)
+	thread, _ := result["thread"].(map[string]any)
+	turns, _ := thread["turns"].([]any)
+
+	payloads := importedThreadEvents(threadID, turns)
+	for _, payload := range payloads {
+		if _, err := s.eventStore.Append(threadID, payload); err != nil {
+			return 0, err
+		}
+	}
+
+	preview, _ := summary["preview"].(string)
+	_, err = s.threadIndex.Update(threadID, func(meta *threads.Metadata) error {
+		if cwd, _ := summary["cwd"].(string); cwd != "" && meta.Cwd == "" {
+			meta.Cwd = cwd
+		}
+		if createdAt := unixSeconds(summary["createdAt"]); createdAt > 0 {
+			meta.CreatedAt = createdAt
+		}
+		if updatedAt := unixSeconds(summary["updatedAt"]); updatedAt > 0 {
+			meta.UpdatedAt = updatedAt
+		}
+		if meta.Title == "" {
+			if title := threads.DeriveTitle(preview); title != "" {
+				meta.Title = title
+				meta.TitleSource = threads.TitleSourceAuto
+			}
+		}
+		meta.ImportedAt = time.Now().UnixMilli()
+		return nil
+	})
+	return len(payloads), err
+}
+
+// importedThreadEvents replays thread/read turns as the notifications a live
+// session would have produced, after a darkhold/history/imported marker.
+func importedThreadEvents(threadID string, turns []any) []string {
+	marker, _ := json.Marshal(map[string]any{
+		"method": "darkhold/history/imported",
+		"params": map[string]any{"threadId": threadID, "turns": len(turns)},
+	})
+	return append([]string{string(marker)}, events.TurnEvents(threadID, turns, events.SourceHistoryImport)...)
+}
+
+// unixSeconds converts an app-server timestamp in seconds to milliseconds.
+func unixSeconds(v any) int64 {
+	seconds, _ := v.(float64)
+	return int64(seconds * 1000)
+}
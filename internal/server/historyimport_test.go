@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestImportCodexHistoryRebuildsUnknownThreads(t *testing.T) {
+	dataDir := t.TempDir()
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.FakeApprovalRate = 0
+		cfg.DataDir = dataDir
+	})
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	_ = postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "fix the flaky test"}}})
+	waitForCondition(t, 5*time.Second, 10*time.Millisecond, func() bool {
+		events, _ := s.store.Read(threadID)
+		return len(events) > 0 && strings.Contains(events[len(events)-1], "turn/completed")
+	})
+
+	// Forget everything darkhold recorded, as if the thread had been created
+	// from the terminal.
+	if err := s.store.Delete(threadID); err != nil {
+		t.Fatal(err)
+	}
+	s.app.importCodexHistoryOnce()
+
+	events, err := s.store.Read(threadID)
+	if err != nil || len(events) < 4 {
+		t.Fatalf("expected imported events, got %v %v", events, err)
+	}
+	if !strings.Contains(events[0], "darkhold/history/imported") || !strings.Contains(events[1], "turn/started") || !strings.Contains(events[len(events)-1], "turn/completed") {
+		t.Fatalf("unexpected imported events %v", events)
+	}
+	if !strings.Contains(strings.Join(events, "\n"), "fake reply: fix the flaky test") {
+		t.Fatalf("expected agent message in imported events %v", events)
+	}
+	meta, ok := s.app.threadIndex.Get(threadID)
+	if !ok || meta.ImportedAt == 0 || meta.Title == "" || meta.Cwd != s.baseDir {
+		t.Fatalf("unexpected metadata %+v", meta)
+	}
+
+	// A completed import is not repeated.
+	if _, err := os.Stat(filepath.Join(dataDir, "history-import.json")); err != nil {
+		t.Fatal(err)
+	}
+	before := len(events)
+	if err := s.store.Delete(threadID); err != nil {
+		t.Fatal(err)
+	}
+	s.app.importCodexHistoryOnce()
+	if events, _ := s.store.Read(threadID); len(events) != 0 {
+		t.Fatalf("expected second import to be skipped, got %d of %d events", len(events), before)
+	}
+}
+
+func TestImportCodexHistorySkipsKnownThreads(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.FakeApprovalRate = 0
+	})
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	s.app.publishThreadEvent(threadID, `{"method":"darkhold/test","params":{}}`)
+
+	result, err := s.app.importCodexHistory(context.Background())
+	if err != nil || result.Threads != 0 || result.Skipped != 1 {
+		t.Fatalf("unexpected result %+v %v", result, err)
+	}
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"strconv"
+)
+
+// interactionContentKeys carry what an approval is actually about. When a
+// request has any of them, per-attempt identifiers are left out of its
+// fingerprint so a re-sent request matches the original.
+var interactionContentKeys = []string{"command", "changes", "fileChanges", "patch", "questions"}
+
+// interactionAttemptKeys identify one attempt at a request rather than its
+// content; they differ between a request and its re-sent copy.
+var interactionAttemptKeys = []string{"itemId", "callId", "approvalId"}
+
+// upstreamRequest is one agent request awaiting the response to a pending
+// interaction.
+type upstreamRequest struct {
+	sessionID int
+	requestID int64
+}
+
+// interactionFingerprint identifies an upstream request by method, turn and
+// content. Requests without recognizable content keep their item IDs, so two
+// different patches in one turn are never confused.
+func interactionFingerprint(method string, params map[string]any) string {
+	content := make(map[string]any, len(params))
+	hasContent := false
+	maps.Copy(content, params)
+	for _, key := range interactionContentKeys {
+		if _, ok := params[key]; ok {
+			hasContent = true
+		}
+	}
+	if hasContent {
+		for _, key := range interactionAttemptKeys {
+			delete(content, key)
+		}
+	}
+	// Maps marshal with sorted keys, so equal content hashes equally.
+	data, _ := json.Marshal(map[string]any{"method": method, "params": content})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// registerInteraction records an upstream request as pending on threadID, or
+// coalesces it into an earlier unresolved request with the same fingerprint.
+// A duplicate of a request that is still pending is answered alongside it; a
+// duplicate of one whose session exited takes over its request ID. It
+// reports the darkhold request ID and whether the request was coalesced.
+// Callers hold sessionsMu.
+func (s *Server) registerInteraction(threadID string, entry pendingInteraction) (string, bool) {
+	threadPending := s.pendingResponses[threadID]
+	if threadPending == nil {
+		threadPending = map[string]pendingInteraction{}
+		s.pendingResponses[threadID] = threadPending
+	}
+	fingerprints := s.interactionFingerprints[threadID]
+	if fingerprints == nil {
+		fingerprints = map[string]string{}
+		s.interactionFingerprints[threadID] = fingerprints
+	}
+
+	if original, ok := fingerprints[entry.fingerprint]; ok {
+		if existing, live := threadPending[original]; live {
+			existing.duplicates = append(existing.duplicates, upstreamRequest{sessionID: entry.sessionID, requestID: entry.requestID})
+			threadPending[original] = existing
+		} else {
+			threadPending[original] = entry
+		}
+		return original, true
+	}
+
+	requestID := strconv.FormatInt(entry.requestID, 10)
+	threadPending[requestID] = entry
+	fingerprints[entry.fingerprint] = requestID
+	return requestID, false
+}
+
+// dropSessionInteractions forgets the requests an exited session was
+// waiting on. A pending interaction whose duplicates live on in another
+// session moves to the first of them. Fingerprints are kept so the agent's
+// re-sent request, after the thread is resumed, finds the prompt clients
+// already have. Callers hold sessionsMu.
+func (s *Server) dropSessionInteractions(sessionID int) {
+	for threadID, pending := range s.pendingResponses {
+		for requestID, entry := range pending {
+			live := entry.duplicates[:0:0]
+			for _, duplicate := range entry.duplicates {
+				if duplicate.sessionID != sessionID {
+					live = append(live, duplicate)
+				}
+			}
+			entry.duplicates = live
+			if entry.sessionID == sessionID {
+				if len(live) == 0 {
+					delete(pending, requestID)
+					continue
+				}
+				entry.sessionID, entry.requestID = live[0].sessionID, live[0].requestID
+				entry.duplicates = live[1:]
+			}
+			pending[requestID] = entry
+		}
+		if len(pending) == 0 {
+			delete(s.pendingResponses, threadID)
+		}
+	}
+}
+
+// forgetOrphanedInteractions drops fingerprints of a thread's requests that
+// are no longer pending once its turn has ended; re-sent requests only
+// arrive while the turn is still running.
+func (s *Server) forgetOrphanedInteractions(threadID string) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	for fingerprint, requestID := range s.interactionFingerprints[threadID] {
+		if _, ok := s.pendingResponses[threadID][requestID]; !ok {
+			delete(s.interactionFingerprints[threadID], fingerprint)
+		}
+	}
+	if len(s.interactionFingerprints[threadID]) == 0 {
+		delete(s.interactionFingerprints, threadID)
+	}
+}
+
+// forgetInteractionFingerprint drops the fingerprint of a resolved request.
+// Callers hold sessionsMu.
+func (s *Server) forgetInteractionFingerprint(threadID, requestID string, entry pendingInteraction) {
+	fingerprints := s.interactionFingerprints[threadID]
+	if fingerprints[entry.fingerprint] == requestID {
+		delete(fingerprints, entry.fingerprint)
+	}
+	if len(fingerprints) == 0 {
+		delete(s.interactionFingerprints, threadID)
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingStdin captures the lines darkhold writes to an injected session.
+type recordingStdin struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (r *recordingStdin) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.buf.Write(p)
+}
+
+func (r *recordingStdin) Close() error { return nil }
+
+func (r *recordingStdin) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.buf.String()
+}
+
+func injectSession(s *Server, id int) (*session, *recordingStdin) {
+	stdin := &recordingStdin{}
+	sess := &session{
+		id:             id,
+		stdin:          stdin,
+		pending:        map[int64]chan map[string]any{},
+		knownThreadIDs: map[string]struct{}{},
+		activeTurnIDs:  map[string]string{},
+	}
+	s.sessionsMu.Lock()
+	s.sessions[id] = sess
+	s.sessionsMu.Unlock()
+	return sess, stdin
+}
+
+// removeSession drops an injected session, which has no process to stop.
+func removeSession(s *Server, sess *session) {
+	s.sessionsMu.Lock()
+	delete(s.sessions, sess.id)
+	s.sessionsMu.Unlock()
+}
+
+func TestInteractionFingerprint(t *testing.T) {
+	command := map[string]any{"threadId": "t", "turnId": "turn-1", "itemId": "a", "command": "make test", "cwd": "/src"}
+	resent := map[string]any{"threadId": "t", "turnId": "turn-1", "itemId": "b", "command": "make test", "cwd": "/src"}
+	nextTurn := map[string]any{"threadId": "t", "turnId": "turn-2", "itemId": "a", "command": "make test", "cwd": "/src"}
+	method := "item/commandExecution/requestApproval"
+	if interactionFingerprint(method, command) != interactionFingerprint(method, resent) {
+		t.Fatal("a re-sent command approval should match the original")
+	}
+	if interactionFingerprint(method, command) == interactionFingerprint(method, nextTurn) {
+		t.Fatal("approvals in different turns should not match")
+	}
+	patchA := map[string]any{"threadId": "t", "turnId": "turn-1", "itemId": "a"}
+	patchB := map[string]any{"threadId": "t", "turnId": "turn-1", "itemId": "b"}
+	if interactionFingerprint("item/fileChange/requestApproval", patchA) == interactionFingerprint("item/fileChange/requestApproval", patchB) {
+		t.Fatal("approvals without content should keep their item IDs")
+	}
+}
+
+func TestDuplicateApprovalsCoalesce(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	const threadID = "thread-dedup"
+	approval := func(id int) string {
+		line, _ := json.Marshal(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      id,
+			"method":  "item/commandExecution/requestApproval",
+			"params":  map[string]any{"threadId": threadID, "turnId": "turn-1", "itemId": "call-" + strconv.Itoa(id), "command": "rm -rf build", "cwd": "/src"},
+		})
+		return string(line)
+	}
+	countRequests := func() int {
+		events, _ := s.store.Read(threadID)
+		n := 0
+		for _, event := range events {
+			if strings.Contains(event, "darkhold/interaction/request") {
+				n++
+			}
+		}
+		return n
+	}
+
+	first, _ := injectSession(s.app, 901)
+	s.app.handleSessionLine(first, approval(7))
+	// The session dies and the agent re-sends the approval after a resume.
+	removeSession(s.app, first)
+	s.app.sessionsMu.Lock()
+	s.app.dropSessionInteractions(first.id)
+	s.app.sessionsMu.Unlock()
+	second, secondStdin := injectSession(s.app, 902)
+	defer removeSession(s.app, second)
+	s.app.handleSessionLine(second, approval(3))
+	// A retry within the same session is folded in too.
+	s.app.handleSessionLine(second, approval(4))
+	if n := countRequests(); n != 1 {
+		t.Fatalf("expected one interaction request, got %d", n)
+	}
+
+	resp, payload := doJSON(t, http.MethodPost, s.http.URL+"/api/thread/interaction/respond", map[string]any{"threadId": threadID, "requestId": "7", "result": map[string]any{"decision": "accept"}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the original request ID to resolve, got %d %v", resp.StatusCode, payload)
+	}
+	written := secondStdin.String()
+	if !strings.Contains(written, `"id":3`) || !strings.Contains(written, `"id":4`) {
+		t.Fatalf("expected both upstream requests answered, got %q", written)
+	}
+	s.app.sessionsMu.Lock()
+	remaining := len(s.app.interactionFingerprints[threadID])
+	s.app.sessionsMu.Unlock()
+	if remaining != 0 {
+		t.Fatal("resolved requests should drop their fingerprint")
+	}
+
+	// Once resolved, the same content is a new request.
+	s.app.handleSessionLine(second, approval(5))
+	if n := countRequests(); n != 2 {
+		t.Fatalf("expected a fresh request after resolution, got %d", n)
+	}
+}
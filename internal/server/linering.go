@@ -0,0 +1,94 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// logLine is one timestamped line captured from an agent process.
+type logLine struct {
+	Seq  int64  `json:"seq"`
+	Time int64  `json:"time"`
+	Text string `json:"text"`
+}
+
+// lineRing keeps the most recent lines of a stream and fans new lines out
+// to live subscribers. Slow subscribers drop lines rather than block the
+// writer.
+type lineRing struct {
+	mu          sync.Mutex
+	lines       []logLine
+	start       int
+	capacity    int
+	seq         int64
+	subscribers map[chan logLine]struct{}
+}
+
+func newLineRing(capacity int) *lineRing {
+	return &lineRing{capacity: capacity, subscribers: map[chan logLine]struct{}{}}
+}
+
+func (r *lineRing) append(text string) logLine {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	line := logLine{Seq: r.seq, Time: time.Now().UnixMilli(), Text: text}
+	if len(r.lines) < r.capacity {
+		r.lines = append(r.lines, line)
+	} else {
+		r.lines[r.start] = line
+		r.start = (r.start + 1) % r.capacity
+	}
+	for ch := range r.subscribers {
+		select {
+		case ch <- line:
+		default:
+		}
+	}
+	return line
+}
+
+// restore loads lines saved from an earlier ring, oldest first, keeping the
+// newest that fit and continuing their sequence numbers.
+func (r *lineRing) restore(lines []logLine) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(lines) > r.capacity {
+		lines = lines[len(lines)-r.capacity:]
+	}
+	r.lines = append([]logLine(nil), lines...)
+	r.start = 0
+	if len(lines) > 0 {
+		r.seq = lines[len(lines)-1].Seq
+	}
+}
+
+// snapshot returns buffered lines with Seq greater than afterSeq, oldest
+// first.
+func (r *lineRing) snapshot(afterSeq int64) []logLine {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]logLine, 0, len(r.lines))
+	for i := 0; i < len(r.lines); i++ {
+		line := r.lines[(r.start+i)%len(r.lines)]
+		if line.Seq > afterSeq {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// subscribe returns the current backlog after afterSeq and a channel of new
+// lines. The caller must call the returned cancel func.
+func (r *lineRing) subscribe(afterSeq int64) ([]logLine, <-chan logLine, func()) {
+	ch := make(chan logLine, 64)
+	r.mu.Lock()
+	r.subscribers[ch] = struct{}{}
+	r.mu.Unlock()
+	backlog := r.snapshot(afterSeq)
+	return backlog, ch, func() {
+		r.mu.Lock()
+		delete(r.subscribers, ch)
+		r.mu.Unlock()
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"darkhold-go/internal/i18n"
+)
+
+// negotiateLocale picks the language of an API response from its
+// Accept-Language header, falling back to --locale, and records it as the
+// response's Content-Language, which localizeError reads back. English
+// responses to requests that did not ask for a language are left unmarked.
+func (s *Server) negotiateLocale(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api" && !strings.HasPrefix(r.URL.Path, "/api/") {
+		return
+	}
+	fallback := s.defaultLocale()
+	accept := r.Header.Get("Accept-Language")
+	if accept == "" && fallback == i18n.DefaultLocale {
+		return
+	}
+	w.Header().Set("Content-Language", i18n.Negotiate(accept, fallback))
+	w.Header().Add("Vary", "Accept-Language")
+}
+
+func (s *Server) defaultLocale() string {
+	if s.cfg.Locale != "" {
+		return s.cfg.Locale
+	}
+	return i18n.DefaultLocale
+}
+
+// localizeError translates an error into the response's Content-Language:
+// verbatim when the catalog has the message, otherwise with the generic
+// message for its code. The English text stays in sourceError, since
+// messages built from errors carry details the generic one lacks.
+func localizeError(w http.ResponseWriter, e apiError) apiError {
+	locale := w.Header().Get("Content-Language")
+	if locale == "" || locale == i18n.DefaultLocale {
+		return e
+	}
+	text, ok := i18n.Translate(locale, e.Error)
+	if !ok {
+		text, ok = i18n.Lookup(locale, "error."+e.Code, nil)
+	}
+	if ok && text != e.Error {
+		e.SourceError, e.Error = e.Error, text
+	}
+	return e
+}
+
+// notificationKey names the catalog message summarizing a stored event for
+// people, such as webhook receivers: approval prompts and turn outcomes.
+func notificationKey(payload string) (string, map[string]string) {
+	var event struct {
+		Method string `json:"method"`
+		Params struct {
+			Method string `json:"method"`
+			Params struct {
+				Command any `json:"command"`
+			} `json:"params"`
+			Turn struct {
+				Status string `json:"status"`
+			} `json:"turn"`
+		} `json:"params"`
+	}
+	if json.Unmarshal([]byte(payload), &event) != nil {
+		return "", nil
+	}
+	switch event.Method {
+	case "darkhold/interaction/request":
+		switch event.Params.Method {
+		case "item/commandExecution/requestApproval":
+			if command := commandText(event.Params.Params.Command); command != "" {
+				return "notify.approval.command", map[string]string{"command": command}
+			}
+		case "item/fileChange/requestApproval":
+			return "notify.approval.fileChange", nil
+		case "item/tool/requestUserInput":
+			return "notify.input", nil
+		case "mcpServer/elicitation/request":
+			return "notify.elicitation", nil
+		}
+		return "notify.interaction", nil
+	case "turn/completed":
+		switch event.Params.Turn.Status {
+		case "failed", "interrupted":
+			return "notify.turn." + event.Params.Turn.Status, nil
+		}
+		return "notify.turn.completed", nil
+	}
+	return "", nil
+}
+
+// commandText renders an approval's command, which agents send as a string
+// or an argv array.
+func commandText(command any) string {
+	switch command := command.(type) {
+	case string:
+		return strings.TrimSpace(command)
+	case []any:
+		parts := make([]string, 0, len(command))
+		for _, part := range command {
+			if text, ok := part.(string); ok {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, " ")
+	}
+	return ""
+}
+
+// notificationMessage returns the webhook summary of a stored event, or nil
+// for events nobody acts on.
+func (s *Server) notificationMessage(payload string) func(locale string) string {
+	key, vars := notificationKey(payload)
+	if key == "" {
+		return nil
+	}
+	fallback := s.defaultLocale()
+	return func(locale string) string {
+		if locale == "" {
+			locale = fallback
+		}
+		text, _ := i18n.Lookup(locale, key, vars)
+		return text
+	}
+}
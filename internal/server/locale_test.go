@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+	"darkhold-go/internal/webhooks"
+)
+
+func TestErrorsFollowAcceptLanguage(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+	get := func(path, acceptLanguage string) (*http.Response, map[string]any) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, s.http.URL+path, nil)
+		if acceptLanguage != "" {
+			req.Header.Set("Accept-Language", acceptLanguage)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var payload map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&payload)
+		return resp, payload
+	}
+
+	resp, payload := get("/api/thread/events", "de-CH, de;q=0.9, en;q=0.5")
+	if resp.Header.Get("Content-Language") != "de" || payload["error"] != "threadId fehlt." || payload["sourceError"] != "threadId is required." || payload["code"] != errCodeInvalidRequest {
+		t.Fatalf("expected a German error, got %q %v", resp.Header.Get("Content-Language"), payload)
+	}
+	if _, payload = get("/api/no-such-route", "de"); payload["error"] != "Nicht gefunden." || !strings.HasPrefix(payload["sourceError"].(string), "no API route matches") {
+		t.Fatalf("expected the generic message for the code, got %v", payload)
+	}
+	resp, payload = get("/api/thread/events", "")
+	if resp.Header.Get("Content-Language") != "" || payload["error"] != "threadId is required." || payload["sourceError"] != nil {
+		t.Fatalf("expected the English error unchanged, got %q %v", resp.Header.Get("Content-Language"), payload)
+	}
+
+	s.app.cfg.Locale = "es"
+	resp, payload = get("/api/thread/events", "ja")
+	if resp.Header.Get("Content-Language") != "es" || payload["error"] != "Falta threadId." {
+		t.Fatalf("expected the --locale fallback, got %q %v", resp.Header.Get("Content-Language"), payload)
+	}
+}
+
+func TestWebhookMessagesUseSubscriptionLocale(t *testing.T) {
+	received := make(chan webhooks.Envelope, 16)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var envelope webhooks.Envelope
+		_ = json.Unmarshal(body, &envelope)
+		received <- envelope
+	}))
+	defer endpoint.Close()
+
+	s := startIntegrationServer(t, func(cfg *config.Config) { cfg.Locale = "fr" })
+	defer s.close()
+	if resp, payload := doJSON(t, http.MethodPost, s.http.URL+"/api/webhooks", map[string]any{"url": endpoint.URL, "locale": "tlh"}); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected an unknown locale to be rejected, got %d %v", resp.StatusCode, payload)
+	}
+	for _, locale := range []string{"de-DE", ""} {
+		if resp, payload := doJSON(t, http.MethodPost, s.http.URL+"/api/webhooks", map[string]any{"url": endpoint.URL, "locale": locale, "methods": []string{"darkhold/interaction/request"}}); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("unexpected create response %d %v", resp.StatusCode, payload)
+		}
+	}
+
+	s.app.publishThreadEvent("thread-l10n", `{"method":"darkhold/interaction/request","params":{"requestId":7,"method":"item/commandExecution/requestApproval","params":{"command":["make","test"]}}}`)
+	messages := map[string]bool{}
+	for range 2 {
+		select {
+		case envelope := <-received:
+			messages[envelope.Message] = true
+		case <-time.After(3 * time.Second):
+			t.Fatal("webhook was not delivered")
+		}
+	}
+	if !messages["Freigabe erforderlich, um auszuführen: make test"] || !messages["Approbation requise pour exécuter : make test"] {
+		t.Fatalf("unexpected messages %v", messages)
+	}
+}
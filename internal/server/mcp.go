@@ -0,0 +1,42 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+
+	"darkhold-go/internal/mcp"
+)
+
+// mcpEndpoint serves the MCP Streamable HTTP transport on POST /api/mcp. Tool
+// calls go through api, the server's own authenticated handler, with the
+// caller's credentials. Every message is answered with a single JSON body;
+// there is no server-initiated stream, so GET is not allowed.
+func (s *Server) mcpEndpoint(api http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w)
+			return
+		}
+		// Browsers always send Origin; refuse pages from other sites so a
+		// local server cannot be driven through DNS rebinding.
+		if origin := r.Header.Get("Origin"); origin != "" {
+			if parsed, err := url.Parse(origin); err != nil || parsed.Host != r.Host {
+				writeError(w, http.StatusForbidden, errCodeForbidden, "cross-origin MCP requests are not allowed.")
+				return
+			}
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.maxRequestBodySize))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+			return
+		}
+		answer := mcp.NewServer(mcp.NewHandlerClient(api, r)).Handle(r.Context(), body)
+		if answer == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(answer)
+	}
+}
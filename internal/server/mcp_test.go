@@ -0,0 +1,113 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMCPEndpointDrivesThreads(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	nextID := 0
+	call := func(method string, params any) map[string]any {
+		t.Helper()
+		nextID++
+		body, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": nextID, "method": method, "params": params})
+		resp, err := http.Post(s.http.URL+"/api/mcp", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var payload map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: HTTP %d, %v", method, resp.StatusCode, err)
+		}
+		if payload["error"] != nil {
+			t.Fatalf("%s: %v", method, payload["error"])
+		}
+		return payload["result"].(map[string]any)
+	}
+	tool := func(name string, args map[string]any) map[string]any {
+		t.Helper()
+		result := call("tools/call", map[string]any{"name": name, "arguments": args})
+		if result["isError"] != false {
+			t.Fatalf("%s failed: %v", name, result["content"])
+		}
+		return result["structuredContent"].(map[string]any)
+	}
+
+	initialized := call("initialize", map[string]any{"protocolVersion": "2025-03-26", "capabilities": map[string]any{}, "clientInfo": map[string]any{"name": "test"}})
+	if initialized["protocolVersion"] != "2025-03-26" {
+		t.Fatalf("expected the requested protocol version, got %v", initialized)
+	}
+	resp, err := http.Post(s.http.URL+"/api/mcp", "application/json", strings.NewReader(`{"jsonrpc":"2.0","method":"notifications/initialized"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected notifications to be accepted, got %d", resp.StatusCode)
+	}
+	if listed := call("tools/list", map[string]any{}); len(listed["tools"].([]any)) != 5 {
+		t.Fatalf("unexpected tools %v", listed)
+	}
+
+	threadID := tool("start_thread", map[string]any{"cwd": s.baseDir})["thread"].(map[string]any)["id"].(string)
+	tool("start_turn", map[string]any{"threadId": threadID, "text": "hello over mcp"})
+
+	var pending []any
+	waitForCondition(t, 10*time.Second, 20*time.Millisecond, func() bool {
+		pending, _ = tool("read_transcript", map[string]any{"threadId": threadID})["pendingInteractions"].([]any)
+		return len(pending) == 1
+	})
+	request := pending[0].(map[string]any)
+	if request["kind"] != reverseKindApproval {
+		t.Fatalf("expected an approval, got %v", request)
+	}
+
+	rejected := call("tools/call", map[string]any{"name": "respond_interaction", "arguments": map[string]any{"threadId": threadID, "requestId": request["requestId"], "result": map[string]any{"decision": "sure"}}})
+	if rejected["isError"] != true || !strings.Contains(rejected["content"].([]any)[0].(map[string]any)["text"].(string), errCodeInvalidInteractionResult) {
+		t.Fatalf("expected an invalid decision to fail, got %v", rejected)
+	}
+	tool("respond_interaction", map[string]any{"threadId": threadID, "requestId": request["requestId"], "result": map[string]any{"decision": "accept"}})
+
+	waitForCondition(t, 10*time.Second, 20*time.Millisecond, func() bool {
+		transcript := tool("read_transcript", map[string]any{"threadId": threadID})
+		turns, _ := transcript["turns"].([]any)
+		if len(turns) != 1 || len(transcript["pendingInteractions"].([]any)) != 0 {
+			return false
+		}
+		turn := turns[0].(map[string]any)
+		return turn["user"] == "hello over mcp" && turn["agent"] != ""
+	})
+
+	listed := tool("list_threads", map[string]any{"cwdPrefix": s.baseDir})
+	if threads, _ := listed["threads"].([]any); len(threads) != 1 {
+		t.Fatalf("expected the thread to be listed, got %v", listed)
+	}
+}
+
+func TestMCPEndpointRejectsOtherOrigins(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	resp, body := doJSON(t, http.MethodGet, s.http.URL+"/api/mcp", nil)
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected GET to be rejected, got %d %v", resp.StatusCode, body)
+	}
+	req, _ := http.NewRequest(http.MethodPost, s.http.URL+"/api/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+	req.Header.Set("Origin", "http://evil.example")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected a cross-origin request to be rejected, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,413 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	browserfs "darkhold-go/internal/fs"
+)
+
+const (
+	errCodeInvalidMCPServer  = "INVALID_MCP_SERVER"
+	errCodeMCPServerExists   = "MCP_SERVER_EXISTS"
+	errCodeMCPServerNotFound = "MCP_SERVER_NOT_FOUND"
+
+	// mcpServerListTTL is how long GET /api/mcp/servers reuses the agent's
+	// answer. Writes through darkhold drop it early.
+	mcpServerListTTL = 30 * time.Second
+)
+
+var (
+	mcpServerNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	envNamePattern       = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// mcpServerConfig is one entry of the agent's mcp_servers config table, in
+// the agent's key spelling.
+type mcpServerConfig struct {
+	Command           string            `json:"command,omitempty"`
+	Args              []string          `json:"args,omitempty"`
+	Env               map[string]string `json:"env,omitempty"`
+	Cwd               string            `json:"cwd,omitempty"`
+	URL               string            `json:"url,omitempty"`
+	BearerTokenEnvVar string            `json:"bearer_token_env_var,omitempty"`
+	Enabled           *bool             `json:"enabled,omitempty"`
+	StartupTimeoutSec *float64          `json:"startup_timeout_sec,omitempty"`
+	ToolTimeoutSec    *float64          `json:"tool_timeout_sec,omitempty"`
+}
+
+// mcpServerRequest is the body of POST and PUT /api/mcp/servers.
+type mcpServerRequest struct {
+	Name              string            `json:"name"`
+	Command           string            `json:"command"`
+	Args              []string          `json:"args"`
+	Env               map[string]string `json:"env"`
+	Cwd               string            `json:"cwd"`
+	URL               string            `json:"url"`
+	BearerTokenEnvVar string            `json:"bearerTokenEnvVar"`
+	Enabled           *bool             `json:"enabled"`
+	StartupTimeoutSec *float64          `json:"startupTimeoutSec"`
+	ToolTimeoutSec    *float64          `json:"toolTimeoutSec"`
+}
+
+// mcpServerView is one server as listed by GET /api/mcp/servers. Env values
+// are left out since they often hold credentials.
+type mcpServerView struct {
+	Name              string         `json:"name"`
+	Transport         string         `json:"transport"`
+	Command           string         `json:"command,omitempty"`
+	Args              []string       `json:"args,omitempty"`
+	EnvKeys           []string       `json:"envKeys,omitempty"`
+	Cwd               string         `json:"cwd,omitempty"`
+	URL               string         `json:"url,omitempty"`
+	BearerTokenEnvVar string         `json:"bearerTokenEnvVar,omitempty"`
+	Enabled           bool           `json:"enabled"`
+	StartupTimeoutSec *float64       `json:"startupTimeoutSec,omitempty"`
+	ToolTimeoutSec    *float64       `json:"toolTimeoutSec,omitempty"`
+	Status            map[string]any `json:"status"`
+}
+
+// mcpServerCache holds the last server listing.
+type mcpServerCache struct {
+	mu        sync.Mutex
+	servers   []mcpServerView
+	fetchedAt time.Time
+}
+
+func (c *mcpServerCache) get() ([]mcpServerView, time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.servers == nil || time.Since(c.fetchedAt) > mcpServerListTTL {
+		return nil, time.Time{}, false
+	}
+	return c.servers, c.fetchedAt, true
+}
+
+func (c *mcpServerCache) put(servers []mcpServerView) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.servers, c.fetchedAt = servers, time.Now()
+	return c.fetchedAt
+}
+
+func (c *mcpServerCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.servers = nil
+}
+
+// mcpServerError is a server definition darkhold refuses to write.
+type mcpServerError struct {
+	Field  string
+	Reason string
+}
+
+func (e *mcpServerError) Error() string { return e.Field + " " + e.Reason }
+
+// validate checks a definition and returns it in the agent's spelling.
+func (r mcpServerRequest) validate() (mcpServerConfig, error) {
+	if !mcpServerNamePattern.MatchString(r.Name) {
+		return mcpServerConfig{}, &mcpServerError{Field: "name", Reason: "must be 1-64 letters, digits, '-' or '_'"}
+	}
+	command, rawURL := strings.TrimSpace(r.Command), strings.TrimSpace(r.URL)
+	if (command == "") == (rawURL == "") {
+		return mcpServerConfig{}, &mcpServerError{Field: "command", Reason: "or url is required, but not both"}
+	}
+	cfg := mcpServerConfig{
+		Command:           command,
+		Args:              r.Args,
+		Env:               r.Env,
+		URL:               rawURL,
+		BearerTokenEnvVar: strings.TrimSpace(r.BearerTokenEnvVar),
+		Enabled:           r.Enabled,
+		StartupTimeoutSec: r.StartupTimeoutSec,
+		ToolTimeoutSec:    r.ToolTimeoutSec,
+	}
+	if rawURL != "" {
+		parsed, err := url.Parse(rawURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return mcpServerConfig{}, &mcpServerError{Field: "url", Reason: "must be an http or https URL"}
+		}
+		if len(r.Args) > 0 || len(r.Env) > 0 || r.Cwd != "" {
+			return mcpServerConfig{}, &mcpServerError{Field: "url", Reason: "servers cannot have args, env or cwd"}
+		}
+	} else if cfg.BearerTokenEnvVar != "" {
+		return mcpServerConfig{}, &mcpServerError{Field: "bearerTokenEnvVar", Reason: "only applies to url servers"}
+	}
+	for key := range r.Env {
+		if !envNamePattern.MatchString(key) {
+			return mcpServerConfig{}, &mcpServerError{Field: "env", Reason: "has an invalid variable name: " + key}
+		}
+	}
+	if cfg.BearerTokenEnvVar != "" && !envNamePattern.MatchString(cfg.BearerTokenEnvVar) {
+		return mcpServerConfig{}, &mcpServerError{Field: "bearerTokenEnvVar", Reason: "must be an environment variable name"}
+	}
+	if r.Cwd != "" {
+		cwd, err := browserfs.ResolvePath(r.Cwd)
+		if err != nil {
+			return mcpServerConfig{}, &mcpServerError{Field: "cwd", Reason: "must be a directory under the base path"}
+		}
+		cfg.Cwd = cwd
+	}
+	for field, timeout := range map[string]*float64{"startupTimeoutSec": r.StartupTimeoutSec, "toolTimeoutSec": r.ToolTimeoutSec} {
+		if timeout != nil && *timeout <= 0 {
+			return mcpServerConfig{}, &mcpServerError{Field: field, Reason: "must be positive"}
+		}
+	}
+	return cfg, nil
+}
+
+func (cfg mcpServerConfig) view(name string) mcpServerView {
+	view := mcpServerView{
+		Name:              name,
+		Transport:         "stdio",
+		Command:           cfg.Command,
+		Args:              cfg.Args,
+		Cwd:               cfg.Cwd,
+		URL:               cfg.URL,
+		BearerTokenEnvVar: cfg.BearerTokenEnvVar,
+		Enabled:           cfg.Enabled == nil || *cfg.Enabled,
+		StartupTimeoutSec: cfg.StartupTimeoutSec,
+		ToolTimeoutSec:    cfg.ToolTimeoutSec,
+	}
+	if cfg.URL != "" {
+		view.Transport = "http"
+	}
+	for key := range cfg.Env {
+		view.EnvKeys = append(view.EnvKeys, key)
+	}
+	sort.Strings(view.EnvKeys)
+	return view
+}
+
+// agentRPC dispatches a call and unwraps its result, returning upstream
+// JSON-RPC errors as the second value.
+func (s *Server) agentRPC(ctx context.Context, method string, params map[string]any) (map[string]any, map[string]any, error) {
+	response, err := s.dispatchRPC(ctx, method, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	if errObj, ok := response["error"].(map[string]any); ok {
+		return nil, errObj, nil
+	}
+	result, _ := response["result"].(map[string]any)
+	return result, nil, nil
+}
+
+// configuredMCPServers reads mcp_servers from the agent's config.
+func (s *Server) configuredMCPServers(ctx context.Context) (map[string]mcpServerConfig, map[string]any, error) {
+	result, rpcErr, err := s.agentRPC(ctx, "config/read", map[string]any{})
+	if err != nil || rpcErr != nil {
+		return nil, rpcErr, err
+	}
+	config, _ := result["config"].(map[string]any)
+	servers := map[string]mcpServerConfig{}
+	raw, _ := config["mcp_servers"].(map[string]any)
+	for name, entry := range raw {
+		encoded, _ := json.Marshal(entry)
+		var cfg mcpServerConfig
+		if err := json.Unmarshal(encoded, &cfg); err != nil {
+			log.Printf("[mcp-servers] skipping unreadable server %s: %v", name, err)
+			continue
+		}
+		servers[name] = cfg
+	}
+	return servers, nil, nil
+}
+
+// mcpServerStatuses returns the agent's runtime view of its MCP servers
+// (tools, resources, auth) by name. It is best-effort: agents without
+// mcpServerStatus/list just report no statuses.
+func (s *Server) mcpServerStatuses(ctx context.Context) map[string]map[string]any {
+	statuses := map[string]map[string]any{}
+	result, rpcErr, err := s.agentRPC(ctx, "mcpServerStatus/list", map[string]any{})
+	if err != nil || rpcErr != nil {
+		log.Printf("[mcp-servers] mcpServerStatus/list failed: %v %v", err, rpcErr)
+		return statuses
+	}
+	entries, _ := result["data"].([]any)
+	for _, raw := range entries {
+		entry, _ := raw.(map[string]any)
+		if name, ok := entry["name"].(string); ok {
+			statuses[name] = entry
+		}
+	}
+	return statuses
+}
+
+func (s *Server) listMCPServers(ctx context.Context) ([]mcpServerView, map[string]any, error) {
+	configured, rpcErr, err := s.configuredMCPServers(ctx)
+	if err != nil || rpcErr != nil {
+		return nil, rpcErr, err
+	}
+	statuses := s.mcpServerStatuses(ctx)
+	servers := make([]mcpServerView, 0, len(configured))
+	for name, cfg := range configured {
+		view := cfg.view(name)
+		view.Status = statuses[name]
+		servers = append(servers, view)
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].Name < servers[j].Name })
+	return servers, nil, nil
+}
+
+// handleMCPServers manages the agent's MCP servers: GET lists them (cached
+// for mcpServerListTTL unless ?refresh=true), POST adds one, PUT adds or
+// replaces one and DELETE ?name= removes one. Writes go through the agent's
+// config/value/write, so they land in its config file.
+func (s *Server) handleMCPServers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		servers, fetchedAt, cached := s.mcpServers.get()
+		if !cached || r.URL.Query().Get("refresh") == "true" {
+			var rpcErr map[string]any
+			var err error
+			servers, rpcErr, err = s.listMCPServers(r.Context())
+			if err != nil {
+				writeDispatchError(w, err)
+				return
+			}
+			if rpcErr != nil {
+				writeUpstreamRPCError(w, rpcErr)
+				return
+			}
+			fetchedAt, cached = s.mcpServers.put(servers), false
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"servers": servers, "cached": cached, "fetchedAt": fetchedAt.UnixMilli()})
+	case http.MethodPost, http.MethodPut:
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
+		var request mcpServerRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+			return
+		}
+		request.Name = strings.TrimSpace(request.Name)
+		cfg, err := request.validate()
+		var invalid *mcpServerError
+		if errors.As(err, &invalid) {
+			writeErrorDetails(w, http.StatusBadRequest, errCodeInvalidMCPServer, invalid.Error()+".", map[string]any{"field": invalid.Field, "reason": invalid.Reason})
+			return
+		}
+		configured, rpcErr, err := s.configuredMCPServers(r.Context())
+		if !s.writeAgentRPCFailure(w, rpcErr, err) {
+			return
+		}
+		_, exists := configured[request.Name]
+		if exists && r.Method == http.MethodPost {
+			writeErrorDetails(w, http.StatusConflict, errCodeMCPServerExists, "an MCP server with this name already exists; use PUT to replace it.", map[string]any{"name": request.Name})
+			return
+		}
+		if !s.writeMCPServerConfig(r.Context(), w, request.Name, cfg) {
+			return
+		}
+		s.audit.record(r, "mcp.server.add", map[string]any{"name": request.Name, "transport": cfg.view(request.Name).Transport, "replaced": exists})
+		status := http.StatusCreated
+		if exists {
+			status = http.StatusOK
+		}
+		writeJSON(w, status, map[string]any{"ok": true, "server": cfg.view(request.Name)})
+	case http.MethodDelete:
+		name := strings.TrimSpace(r.URL.Query().Get("name"))
+		if name == "" {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "name is required.")
+			return
+		}
+		configured, rpcErr, err := s.configuredMCPServers(r.Context())
+		if !s.writeAgentRPCFailure(w, rpcErr, err) {
+			return
+		}
+		if _, ok := configured[name]; !ok {
+			writeError(w, http.StatusNotFound, errCodeMCPServerNotFound, "MCP server not found.")
+			return
+		}
+		if !s.writeMCPServerConfig(r.Context(), w, name, nil) {
+			return
+		}
+		s.audit.record(r, "mcp.server.remove", map[string]any{"name": name})
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+// writeMCPServerConfig sets (or, with a nil value, removes) one mcp_servers
+// entry in the agent's config. It reports whether the write succeeded and
+// has answered w otherwise.
+func (s *Server) writeMCPServerConfig(ctx context.Context, w http.ResponseWriter, name string, value any) bool {
+	s.mcpServers.invalidate()
+	_, rpcErr, err := s.agentRPC(ctx, "config/value/write", map[string]any{
+		"keyPath":       "mcp_servers." + name,
+		"value":         value,
+		"mergeStrategy": "replace",
+	})
+	return s.writeAgentRPCFailure(w, rpcErr, err)
+}
+
+// writeAgentRPCFailure answers w for a failed agentRPC and reports whether
+// the call succeeded.
+func (s *Server) writeAgentRPCFailure(w http.ResponseWriter, rpcErr map[string]any, err error) bool {
+	switch {
+	case err != nil:
+		writeDispatchError(w, err)
+	case rpcErr != nil:
+		writeUpstreamRPCError(w, rpcErr)
+	default:
+		return true
+	}
+	return false
+}
+
+// handleMCPServerTest asks the agent for a fresh status of one server:
+// whether it started and which tools it offers.
+func (s *Server) handleMCPServerTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
+	var request struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+		return
+	}
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "name is required.")
+		return
+	}
+	servers, rpcErr, err := s.listMCPServers(r.Context())
+	if !s.writeAgentRPCFailure(w, rpcErr, err) {
+		return
+	}
+	s.mcpServers.put(servers)
+	for _, server := range servers {
+		if server.Name != name {
+			continue
+		}
+		tools, _ := server.Status["tools"].(map[string]any)
+		toolNames := make([]string, 0, len(tools))
+		for tool := range tools {
+			toolNames = append(toolNames, tool)
+		}
+		sort.Strings(toolNames)
+		writeJSON(w, http.StatusOK, map[string]any{
+			"name":   name,
+			"ok":     server.Status != nil,
+			"tools":  toolNames,
+			"server": server,
+		})
+		return
+	}
+	writeError(w, http.StatusNotFound, errCodeMCPServerNotFound, "MCP server not found.")
+}
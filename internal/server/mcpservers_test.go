@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"darkhold-go/internal/config"
+)
+
+func TestMCPServerManagement(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.FakeApprovalRate = 0
+	})
+	defer s.close()
+	endpoint := s.http.URL + "/api/mcp/servers"
+
+	resp, body := doJSON(t, http.MethodPost, endpoint, map[string]any{"name": "docs", "command": "npx", "url": "https://example.com/mcp"})
+	if details, _ := body["details"].(map[string]any); resp.StatusCode != http.StatusBadRequest || body["code"] != errCodeInvalidMCPServer || details["field"] != "command" {
+		t.Fatalf("expected command and url together to be rejected, got %d %v", resp.StatusCode, body)
+	}
+	resp, body = doJSON(t, http.MethodPost, endpoint, map[string]any{"name": "docs", "command": "npx", "cwd": "/"})
+	if details, _ := body["details"].(map[string]any); resp.StatusCode != http.StatusBadRequest || details["field"] != "cwd" {
+		t.Fatalf("expected a cwd outside the base path to be rejected, got %d %v", resp.StatusCode, body)
+	}
+
+	server := map[string]any{"name": "docs", "command": "npx", "args": []string{"-y", "docs-mcp"}, "env": map[string]string{"DOCS_TOKEN": "secret"}}
+	if resp, body = doJSON(t, http.MethodPost, endpoint, server); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected the server to be added, got %d %v", resp.StatusCode, body)
+	}
+	if resp, body = doJSON(t, http.MethodPost, endpoint, server); resp.StatusCode != http.StatusConflict || body["code"] != errCodeMCPServerExists {
+		t.Fatalf("expected a duplicate add to conflict, got %d %v", resp.StatusCode, body)
+	}
+	server["args"] = []string{"-y", "docs-mcp@2"}
+	if resp, body = doJSON(t, http.MethodPut, endpoint, server); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected PUT to replace the server, got %d %v", resp.StatusCode, body)
+	}
+
+	resp, body = doJSON(t, http.MethodGet, endpoint, nil)
+	servers, _ := body["servers"].([]any)
+	if resp.StatusCode != http.StatusOK || len(servers) != 1 || body["cached"] != false {
+		t.Fatalf("unexpected listing %d %v", resp.StatusCode, body)
+	}
+	listed := servers[0].(map[string]any)
+	if listed["transport"] != "stdio" || listed["args"].([]any)[1] != "docs-mcp@2" || listed["envKeys"].([]any)[0] != "DOCS_TOKEN" || listed["env"] != nil || listed["status"] == nil {
+		t.Fatalf("unexpected server %v", listed)
+	}
+	if _, body = doJSON(t, http.MethodGet, endpoint, nil); body["cached"] != true {
+		t.Fatalf("expected the second listing to be cached, got %v", body)
+	}
+
+	resp, body = doJSON(t, http.MethodPost, endpoint+"/test", map[string]any{"name": "docs"})
+	if tools, _ := body["tools"].([]any); resp.StatusCode != http.StatusOK || body["ok"] != true || len(tools) != 1 {
+		t.Fatalf("unexpected test result %d %v", resp.StatusCode, body)
+	}
+
+	if resp, body = doJSON(t, http.MethodDelete, endpoint+"?name=docs", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the server to be removed, got %d %v", resp.StatusCode, body)
+	}
+	if resp, body = doJSON(t, http.MethodDelete, endpoint+"?name=docs", nil); resp.StatusCode != http.StatusNotFound || body["code"] != errCodeMCPServerNotFound {
+		t.Fatalf("expected removing a missing server to 404, got %d %v", resp.StatusCode, body)
+	}
+	if _, body = doJSON(t, http.MethodGet, endpoint, nil); len(body["servers"].([]any)) != 0 {
+		t.Fatalf("expected no servers after removal, got %v", body)
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// handleMetrics serves Prometheus text-format metrics: agent sessions and
+// the resources of their process trees, agents killed for them, the
+// since-start counters of /api/stats/overview, and recovered panics.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	s.sessionsMu.RLock()
+	live := make([]*session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		live = append(live, sess)
+	}
+	s.sessionsMu.RUnlock()
+	infos := make([]sessionInfo, 0, len(live))
+	for _, sess := range live {
+		infos = append(infos, sess.info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+
+	s.stats.mu.Lock()
+	totals := s.stats.sinceStart.view()
+	running := len(s.stats.turnStarts)
+	s.stats.mu.Unlock()
+
+	var b strings.Builder
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	sample := func(name, labels string, value float64) {
+		if labels != "" {
+			labels = "{" + labels + "}"
+		}
+		fmt.Fprintf(&b, "%s%s %s\n", name, labels, strconv.FormatFloat(value, 'g', -1, 64))
+	}
+	sessionLabels := func(info sessionInfo) string {
+		return fmt.Sprintf(`session="%d",pid="%d"`, info.ID, info.PID)
+	}
+
+	metric("darkhold_agent_sessions", "gauge", "Agent sessions that have not exited.")
+	sample("darkhold_agent_sessions", "", float64(len(infos)))
+	metric("darkhold_agent_cpu_seconds_total", "counter", "CPU time used by each session's agent process tree.")
+	for _, info := range infos {
+		if info.Usage != nil {
+			sample("darkhold_agent_cpu_seconds_total", sessionLabels(info), info.Usage.CPUSeconds)
+		}
+	}
+	metric("darkhold_agent_cpu_percent", "gauge", "Share of one core used by each session's agent process tree over the last sample interval.")
+	for _, info := range infos {
+		if info.Usage != nil {
+			sample("darkhold_agent_cpu_percent", sessionLabels(info), info.Usage.CPUPercent)
+		}
+	}
+	metric("darkhold_agent_resident_memory_bytes", "gauge", "Resident memory of each session's agent process tree.")
+	for _, info := range infos {
+		if info.Usage != nil {
+			sample("darkhold_agent_resident_memory_bytes", sessionLabels(info), float64(info.Usage.RSSBytes))
+		}
+	}
+	metric("darkhold_agent_active_turns", "gauge", "Turns running on each session.")
+	for _, info := range infos {
+		sample("darkhold_agent_active_turns", sessionLabels(info), float64(info.ActiveTurns))
+	}
+	metric("darkhold_agent_resource_kills_total", "counter", "Agents killed by --max-agent-rss or --max-agent-cpu.")
+	sample("darkhold_agent_resource_kills_total", `limit="rss"`, float64(s.agentKills.rss.Load()))
+	sample("darkhold_agent_resource_kills_total", `limit="cpu"`, float64(s.agentKills.cpu.Load()))
+
+	metric("darkhold_turns_running", "gauge", "Turns running now.")
+	sample("darkhold_turns_running", "", float64(running))
+	metric("darkhold_turns_total", "counter", "Turns finished since the server started, by outcome.")
+	sample("darkhold_turns_total", `status="completed"`, float64(totals.TurnsCompleted))
+	sample("darkhold_turns_total", `status="failed"`, float64(totals.TurnsFailed))
+	sample("darkhold_turns_total", `status="interrupted"`, float64(totals.TurnsInterrupted))
+	metric("darkhold_approvals_total", "counter", "Approval requests answered since the server started, by decision.")
+	sample("darkhold_approvals_total", `decision="granted"`, float64(totals.ApprovalsGranted))
+	sample("darkhold_approvals_total", `decision="denied"`, float64(totals.ApprovalsDenied))
+	metric("darkhold_events_stored_total", "counter", "Thread events stored since the server started.")
+	sample("darkhold_events_stored_total", "", float64(totals.EventsStored))
+	metric("darkhold_sse_clients", "gauge", "Open thread event streams.")
+	sample("darkhold_sse_clients", "", float64(s.sseStreams.stats().Total))
+
+	components, panics, restarts := s.supervisor.snapshot()
+	metric("darkhold_panics_recovered_total", "counter", "Panics recovered in HTTP handlers and background components, by component.")
+	for _, component := range components {
+		sample("darkhold_panics_recovered_total", fmt.Sprintf(`component=%q`, component), float64(panics[component]))
+	}
+	metric("darkhold_supervisor_restarts_total", "counter", "Background components and listeners restarted after a panic or failure, by component.")
+	for _, component := range components {
+		sample("darkhold_supervisor_restarts_total", fmt.Sprintf(`component=%q`, component), float64(restarts[component]))
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write([]byte(b.String()))
+}
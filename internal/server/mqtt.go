@@ -0,0 +1,43 @@
+package server
+
+import (
+	"log"
+
+	"darkhold-go/internal/config"
+	"darkhold-go/internal/mqtt"
+)
+
+// openMQTT starts the MQTT publisher when --mqtt-url is set. A nil publisher
+// disables MQTT mirroring.
+func openMQTT(cfg config.Config) *mqtt.Publisher {
+	if cfg.MQTTURL == "" {
+		return nil
+	}
+	publisher, err := mqtt.New(mqtt.Options{
+		BrokerURL: cfg.MQTTURL,
+		Username:  cfg.MQTTUsername,
+		Password:  cfg.MQTTPassword,
+	})
+	if err != nil {
+		log.Printf("[mqtt] disabled: %v", err)
+		return nil
+	}
+	return publisher
+}
+
+// mqttTopic builds <prefix>/<threadId>/<method>. The method keeps its slashes
+// so subscribers can filter with wildcards such as darkhold/+/turn/#.
+func mqttTopic(prefix, threadID, method string) string {
+	topic := mqtt.TopicSegment(threadID) + "/" + method
+	if prefix != "" {
+		topic = prefix + "/" + topic
+	}
+	return topic
+}
+
+func (s *Server) publishMQTTEvent(threadID, method, payload string) {
+	if s.mqtt == nil {
+		return
+	}
+	s.mqtt.Publish(mqttTopic(s.cfg.MQTTTopicPrefix, threadID, method), []byte(payload))
+}
@@ -0,0 +1,12 @@
+package server
+
+import "testing"
+
+func TestMQTTTopic(t *testing.T) {
+	if got := mqttTopic("darkhold", "thr/1", "item/commandExecution/requestApproval"); got != "darkhold/thr_1/item/commandExecution/requestApproval" {
+		t.Fatalf("unexpected topic %q", got)
+	}
+	if got := mqttTopic("", "t1", "turn/completed"); got != "t1/turn/completed" {
+		t.Fatalf("unexpected topic without prefix %q", got)
+	}
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"maps"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"darkhold-go/internal/indexdb"
+	"darkhold-go/internal/threads"
+)
+
+const errCodeNoteNotFound = "NOTE_NOT_FOUND"
+
+// openIndexDB opens the data dir's index database, which holds thread
+// metadata and usage counters. Without a data dir, or when the database
+// cannot be opened (for example because another process holds it), nil is
+// returned and both are kept in memory; a bad file is never overwritten.
+func openIndexDB(dataDir string) *indexdb.DB {
+	if dataDir == "" {
+		return nil
+	}
+	db, err := indexdb.Open(dataDir)
+	if err != nil {
+		log.Printf("[index] failed to open %s, using in-memory metadata and usage: %v", filepath.Join(dataDir, indexdb.FileName), err)
+		return nil
+	}
+	return db
+}
+
+// openThreadIndex loads persisted thread metadata from db. A corrupt record
+// leaves the database untouched and darkhold continues with an in-memory
+// index so a bad file never blocks startup.
+func openThreadIndex(db *indexdb.DB) *threads.Index {
+	if db != nil {
+		ix, err := threads.OpenStore(db.Threads())
+		if err == nil {
+			return ix
+		}
+		log.Printf("[threads] failed to load the thread index, using in-memory metadata: %v", err)
+	}
+	ix, _ := threads.Open("")
+	return ix
+}
+
+// openClusterThreadIndex keeps the thread index in the shared store so every
+// replica sees the same metadata. Concurrent edits on different replicas are
+// last-writer-wins for the whole document.
+func openClusterThreadIndex(cluster clusterStore) *threads.Index {
+	ix, err := threads.OpenBackend(cluster.ThreadIndexBackend())
+	if err != nil {
+		log.Printf("[threads] failed to load shared thread index, using in-memory metadata: %v", err)
+		ix, _ = threads.Open("")
+	}
+	return ix
+}
+
+func (s *Server) handleThreadNotes(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		threadID := strings.TrimSpace(r.URL.Query().Get("threadId"))
+		if threadID == "" {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+			return
+		}
+		meta, _ := s.threadIndex.Get(threadID)
+		notes := meta.Notes
+		if notes == nil {
+			notes = []threads.Note{}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"threadId": threadID, "notes": notes})
+	case http.MethodPost, http.MethodPut, http.MethodDelete:
+		s.handleThreadNoteMutation(w, r)
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+func (s *Server) handleThreadNoteMutation(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
+	var request struct {
+		ThreadID string  `json:"threadId"`
+		NoteID   string  `json:"noteId"`
+		Text     *string `json:"text"`
+		Pinned   *bool   `json:"pinned"`
+	}
+	if r.Method == http.MethodDelete {
+		request.ThreadID = r.URL.Query().Get("threadId")
+		request.NoteID = r.URL.Query().Get("noteId")
+	} else if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+		return
+	}
+	request.ThreadID = strings.TrimSpace(request.ThreadID)
+	request.NoteID = strings.TrimSpace(request.NoteID)
+	if request.ThreadID == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+		return
+	}
+	if r.Method != http.MethodPost && request.NoteID == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "noteId is required.")
+		return
+	}
+
+	var note threads.Note
+	_, err := s.threadIndex.Update(request.ThreadID, func(meta *threads.Metadata) error {
+		var err error
+		switch r.Method {
+		case http.MethodPost:
+			text := ""
+			if request.Text != nil {
+				text = *request.Text
+			}
+			note, err = meta.AddNote(text, request.Pinned != nil && *request.Pinned)
+		case http.MethodPut:
+			note, err = meta.EditNote(request.NoteID, request.Text, request.Pinned)
+		case http.MethodDelete:
+			err = meta.DeleteNote(request.NoteID)
+		}
+		return err
+	})
+	switch {
+	case errors.Is(err, threads.ErrNoteNotFound):
+		writeError(w, http.StatusNotFound, errCodeNoteNotFound, "note not found.")
+	case errors.Is(err, threads.ErrNoteEmpty), errors.Is(err, threads.ErrNoteTooLong):
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error()+".")
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
+	case r.Method == http.MethodDelete:
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	case r.Method == http.MethodPost:
+		writeJSON(w, http.StatusCreated, note)
+	default:
+		writeJSON(w, http.StatusOK, note)
+	}
+}
+
+// pinnedNotesHeader starts the text item withPinnedNotes adds.
+const pinnedNotesHeader = "Pinned notes for this thread:"
+
+// withPinnedNotes prepends the workspace's and thread's pinned notes to a
+// turn/start input as one text item, so standing context travels with every
+// prompt.
+func (s *Server) withPinnedNotes(threadID string, params map[string]any) map[string]any {
+	meta, _ := s.threadIndex.Get(threadID)
+	pinned := make([]string, 0)
+	if ws, ok := s.threadIndex.WorkspaceForCwd(meta.Cwd); ok {
+		pinned = append(pinned, ws.Settings.PinnedNotes...)
+	}
+	for _, note := range meta.PinnedNotes() {
+		pinned = append(pinned, note.Text)
+	}
+	if len(pinned) == 0 {
+		return params
+	}
+	input, ok := params["input"].([]any)
+	if !ok {
+		return params
+	}
+	var b strings.Builder
+	b.WriteString(pinnedNotesHeader)
+	for _, text := range pinned {
+		b.WriteString("\n- ")
+		b.WriteString(strings.ReplaceAll(text, "\n", "\n  "))
+	}
+	out := make(map[string]any, len(params))
+	maps.Copy(out, params)
+	out["input"] = append([]any{map[string]any{"type": "text", "text": b.String()}}, input...)
+	return out
+}
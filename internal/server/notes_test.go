@@ -0,0 +1,94 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"darkhold-go/internal/threads"
+)
+
+func doJSON(t *testing.T, method, url string, body any) (*http.Response, map[string]any) {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		encoded, _ := json.Marshal(body)
+		reader = bytes.NewReader(encoded)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var payload map[string]any
+	_ = json.NewDecoder(resp.Body).Decode(&payload)
+	return resp, payload
+}
+
+func TestThreadNotesCRUD(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	resp, created := doJSON(t, http.MethodPost, s.http.URL+"/api/thread/notes", map[string]any{"threadId": "t1", "text": "deploy target is staging", "pinned": true})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %v", resp.StatusCode, created)
+	}
+	noteID, _ := created["id"].(string)
+	if noteID == "" || created["pinned"] != true {
+		t.Fatalf("unexpected note: %v", created)
+	}
+
+	resp, updated := doJSON(t, http.MethodPut, s.http.URL+"/api/thread/notes", map[string]any{"threadId": "t1", "noteId": noteID, "pinned": false})
+	if resp.StatusCode != http.StatusOK || updated["pinned"] != false || updated["text"] != "deploy target is staging" {
+		t.Fatalf("unexpected update: %d %v", resp.StatusCode, updated)
+	}
+
+	resp, listed := doJSON(t, http.MethodGet, s.http.URL+"/api/thread/notes?threadId=t1", nil)
+	if notes, _ := listed["notes"].([]any); resp.StatusCode != http.StatusOK || len(notes) != 1 {
+		t.Fatalf("unexpected list: %d %v", resp.StatusCode, listed)
+	}
+
+	resp, _ = doJSON(t, http.MethodDelete, s.http.URL+"/api/thread/notes?threadId=t1&noteId="+noteID, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 on delete, got %d", resp.StatusCode)
+	}
+	resp, missing := doJSON(t, http.MethodDelete, s.http.URL+"/api/thread/notes?threadId=t1&noteId="+noteID, nil)
+	if resp.StatusCode != http.StatusNotFound || missing["code"] != errCodeNoteNotFound {
+		t.Fatalf("expected NOTE_NOT_FOUND, got %d %v", resp.StatusCode, missing)
+	}
+}
+
+func TestPinnedNotesArePrependedToTurnInput(t *testing.T) {
+	s := &Server{}
+	s.threadIndex, _ = threads.Open("")
+	_, _ = s.threadIndex.Update("t1", func(m *threads.Metadata) error {
+		if _, err := m.AddNote("never edit generated files", true); err != nil {
+			return err
+		}
+		_, err := m.AddNote("unpinned scratch", false)
+		return err
+	})
+
+	params := map[string]any{"threadId": "t1", "input": []any{map[string]any{"type": "text", "text": "fix the build"}}}
+	out := s.withPinnedNotes("t1", params)
+	input := out["input"].([]any)
+	if len(input) != 2 {
+		t.Fatalf("expected pinned context plus original input, got %v", input)
+	}
+	context := input[0].(map[string]any)["text"].(string)
+	if !strings.Contains(context, "never edit generated files") || strings.Contains(context, "unpinned scratch") {
+		t.Fatalf("unexpected pinned context: %q", context)
+	}
+	if len(params["input"].([]any)) != 1 {
+		t.Fatal("original params should not be mutated")
+	}
+}
@@ -0,0 +1,333 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"darkhold-go/internal/config"
+	"darkhold-go/internal/oidc"
+)
+
+const (
+	errCodeLoginFailed  = "LOGIN_FAILED"
+	errCodeLoginExpired = "LOGIN_EXPIRED"
+
+	oidcLoginPath    = "/api/auth/oidc/login"
+	oidcCallbackPath = "/api/auth/oidc/callback"
+
+	oidcSessionCookie = "darkhold_session"
+	oidcLoginCookie   = "darkhold_oidc_login"
+	// oidcLoginTTL is how long a user has to finish signing in at the issuer.
+	oidcLoginTTL = 10 * time.Minute
+)
+
+var errOIDCCookieInvalid = errors.New("cookie is invalid or expired")
+
+// oidcAuth signs users in through --oidc-issuer. Sessions are kept by the
+// browser in a signed cookie, so nothing is stored server-side; the signing
+// key lives in <data-dir>/session.key so sessions survive restarts.
+type oidcAuth struct {
+	provider *oidc.Provider
+	signer   tokenSigner
+	cfg      config.Config
+}
+
+// oidcSession is the signed content of the session cookie.
+type oidcSession struct {
+	Identity  string   `json:"id"`
+	Subject   string   `json:"sub"`
+	Name      string   `json:"name,omitempty"`
+	Groups    []string `json:"groups,omitempty"`
+	Scopes    []string `json:"scopes"`
+	ExpiresAt int64    `json:"exp"`
+}
+
+// oidcLoginState is the signed content of the cookie that carries a login
+// from the redirect to the issuer back to the callback.
+type oidcLoginState struct {
+	oidc.Login
+	Redirect  string `json:"redirect"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+func openOIDC(cfg config.Config) *oidcAuth {
+	if cfg.OIDCIssuer == "" {
+		return nil
+	}
+	return &oidcAuth{
+		provider: &oidc.Provider{Issuer: cfg.OIDCIssuer, ClientID: cfg.OIDCClientID, ClientSecret: cfg.OIDCClientSecret, Scopes: cfg.OIDCRequestScopes},
+		signer:   openSigningKey(cfg.DataDir, "session.key", "oidc"),
+		cfg:      cfg,
+	}
+}
+
+// seal signs value for a cookie.
+func (a *oidcAuth) seal(value any) string {
+	return a.signer.seal(value)
+}
+
+// open verifies a sealed cookie value into out.
+func (a *oidcAuth) open(sealed string, out any) error {
+	if !a.signer.open(sealed, out) {
+		return errOIDCCookieInvalid
+	}
+	return nil
+}
+
+// session returns the signed-in user of r, if any.
+func (a *oidcAuth) session(r *http.Request) (oidcSession, bool) {
+	var sess oidcSession
+	cookie, err := r.Cookie(oidcSessionCookie)
+	if a == nil || err != nil || a.open(cookie.Value, &sess) != nil || sess.Identity == "" || time.Now().Unix() > sess.ExpiresAt {
+		return oidcSession{}, false
+	}
+	return sess, true
+}
+
+// redirectURL is the callback registered with the issuer.
+func (a *oidcAuth) redirectURL(r *http.Request) string {
+	if a.cfg.OIDCRedirectURL != "" {
+		return a.cfg.OIDCRedirectURL
+	}
+	base := a.cfg.PublicURL
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return strings.TrimSuffix(base, "/") + oidcCallbackPath
+}
+
+func (a *oidcAuth) secureCookies(r *http.Request) bool {
+	return r.TLS != nil || strings.HasPrefix(a.redirectURL(r), "https://")
+}
+
+// scopesFor grants the default scopes plus those of the user's groups.
+func (a *oidcAuth) scopesFor(groups []string) []string {
+	scopes := slices.Clone(a.cfg.OIDCDefaultScopes)
+	for _, group := range groups {
+		for _, scope := range a.cfg.OIDCGroupScopes[group] {
+			if !slices.Contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	slices.Sort(scopes)
+	return scopes
+}
+
+// sessionFromClaims builds a session from verified ID token claims.
+func (a *oidcAuth) sessionFromClaims(claims map[string]any) (oidcSession, error) {
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return oidcSession{}, errors.New("the ID token has no subject")
+	}
+	sess := oidcSession{Subject: subject, ExpiresAt: time.Now().Add(a.cfg.OIDCSessionTTL).Unix()}
+	sess.Identity = "oidc:" + subject
+	if email, _ := claims["email"].(string); email != "" && claims["email_verified"] != false {
+		sess.Identity = "oidc:" + email
+	}
+	sess.Name, _ = claims["name"].(string)
+	switch groups := claims[a.cfg.OIDCGroupsClaim].(type) {
+	case string:
+		sess.Groups = []string{groups}
+	case []any:
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				sess.Groups = append(sess.Groups, name)
+			}
+		}
+	}
+	sess.Scopes = a.scopesFor(sess.Groups)
+	return sess, nil
+}
+
+// safeRedirect keeps post-login redirects on this server.
+func safeRedirect(target string) string {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return "/"
+	}
+	return target
+}
+
+// handleOIDCLogin sends the browser to the issuer, remembering the login in
+// a short-lived signed cookie. ?redirect= is where to go afterwards.
+func (s *Server) handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if s.oidc == nil {
+		writeError(w, http.StatusNotFound, errCodeNotFound, "OIDC sign-in is not configured.")
+		return
+	}
+	state := oidcLoginState{Login: oidc.NewLogin(), Redirect: safeRedirect(r.URL.Query().Get("redirect")), ExpiresAt: time.Now().Add(oidcLoginTTL).Unix()}
+	target, err := s.oidc.provider.AuthCodeURL(r.Context(), state.Login, s.oidc.redirectURL(r))
+	if err != nil {
+		log.Printf("[oidc] %v", err)
+		writeError(w, http.StatusBadGateway, errCodeLoginFailed, "the identity provider is unavailable.")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcLoginCookie,
+		Value:    s.oidc.seal(state),
+		Path:     oidcCallbackPath,
+		MaxAge:   int(oidcLoginTTL / time.Second),
+		HttpOnly: true,
+		Secure:   s.oidc.secureCookies(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// handleOIDCCallback finishes a sign-in: it checks the state against the
+// login cookie, redeems the code, and sets the session cookie.
+func (s *Server) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if s.oidc == nil {
+		writeError(w, http.StatusNotFound, errCodeNotFound, "OIDC sign-in is not configured.")
+		return
+	}
+	query := r.URL.Query()
+	if issuerErr := query.Get("error"); issuerErr != "" {
+		writeErrorDetails(w, http.StatusUnauthorized, errCodeLoginFailed, "the identity provider refused the sign-in.", map[string]any{"error": issuerErr, "description": query.Get("error_description")})
+		return
+	}
+	var state oidcLoginState
+	cookie, err := r.Cookie(oidcLoginCookie)
+	if err != nil || s.oidc.open(cookie.Value, &state) != nil || time.Now().Unix() > state.ExpiresAt {
+		writeError(w, http.StatusBadRequest, errCodeLoginExpired, "the sign-in expired or was started elsewhere; try again.")
+		return
+	}
+	if query.Get("state") == "" || !hmac.Equal([]byte(query.Get("state")), []byte(state.State)) {
+		writeError(w, http.StatusBadRequest, errCodeLoginFailed, "the sign-in state does not match.")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcLoginCookie, Path: oidcCallbackPath, MaxAge: -1})
+
+	claims, err := s.oidc.provider.Exchange(r.Context(), state.Login, query.Get("code"), s.oidc.redirectURL(r))
+	if err == nil {
+		var sess oidcSession
+		if sess, err = s.oidc.sessionFromClaims(claims); err == nil {
+			s.startOIDCSession(w, r, sess)
+			http.Redirect(w, r, state.Redirect, http.StatusFound)
+			return
+		}
+	}
+	log.Printf("[oidc] sign-in failed: %v", err)
+	writeError(w, http.StatusUnauthorized, errCodeLoginFailed, "the sign-in could not be verified.")
+}
+
+func (s *Server) startOIDCSession(w http.ResponseWriter, r *http.Request, sess oidcSession) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcSessionCookie,
+		Value:    s.oidc.seal(sess),
+		Path:     "/",
+		Expires:  time.Unix(sess.ExpiresAt, 0),
+		HttpOnly: true,
+		Secure:   s.oidc.secureCookies(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	log.Printf("[oidc] %s signed in with scopes %s", sess.Identity, strings.Join(sess.Scopes, ","))
+	s.audit.record(r.WithContext(withClientIdentity(r.Context(), sess.Identity)), "auth.login", map[string]any{
+		"provider": "oidc", "subject": sess.Subject, "groups": sess.Groups, "scopes": sess.Scopes,
+	})
+}
+
+// handleAuthLogout ends an OIDC session. Signing out at the issuer is left
+// to the issuer.
+func (s *Server) handleAuthLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcSessionCookie, Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+	s.audit.record(r, "auth.logout", nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAuthMe reports who the caller is and what they may do.
+func (s *Server) handleAuthMe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	me := map[string]any{"identity": clientIdentity(r.Context()), "scopes": clientScopes(r.Context()), "oidc": s.oidc != nil}
+	if sess, ok := s.oidc.session(r); ok && sess.Identity == clientIdentity(r.Context()) {
+		me["provider"] = "oidc"
+		me["name"] = sess.Name
+		me["groups"] = sess.Groups
+		me["expiresAt"] = sess.ExpiresAt * 1000
+	} else if strings.HasPrefix(clientIdentity(r.Context()), clientCertPrefix) {
+		me["provider"] = "clientCert"
+	} else if len(s.cfg.APIKeys) > 0 {
+		me["provider"] = "apiKey"
+	} else {
+		me["provider"] = "none"
+	}
+	writeJSON(w, http.StatusOK, me)
+}
+
+type clientScopesKey struct{}
+
+func withClientScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, clientScopesKey{}, scopes)
+}
+
+// clientScopes returns the caller's scopes: those of their OIDC session or
+// client certificate, or all of them for API key holders and when
+// authentication is off.
+func clientScopes(ctx context.Context) []string {
+	if scopes, ok := ctx.Value(clientScopesKey{}).([]string); ok {
+		return scopes
+	}
+	return config.AccessScopes
+}
+
+// requiredScope is the scope a request needs.
+func requiredScope(r *http.Request) string {
+	switch path := r.URL.Path; {
+	case strings.HasPrefix(path, "/api/admin/"):
+		return "admin"
+	case path == "/api/thread/interaction/respond" || path == "/api/thread/interaction/quick-links" || path == quickLinkPath:
+		// A quick link answers an interaction, so minting one or redeeming
+		// it while signed in needs approve as much as responding does.
+		return "approve"
+	case path == "/api/session/ws" || path == "/api/terminal/ws":
+		return "write"
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return "read"
+	}
+	return "write"
+}
+
+// authorize refuses API requests the caller's scopes do not cover.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if (!strings.HasPrefix(r.URL.Path, "/api/") && !strings.HasPrefix(r.URL.Path, embedThreadPathPrefix)) || r.URL.Path == oidcLoginPath || r.URL.Path == oidcCallbackPath || r.URL.Path == "/api/auth/logout" || r.URL.Path == "/api/auth/me" {
+		return true
+	}
+	scope := requiredScope(r)
+	if slices.Contains(clientScopes(r.Context()), scope) {
+		return true
+	}
+	writeErrorDetails(w, http.StatusForbidden, errCodeForbidden, "your account lacks the "+scope+" scope.", map[string]any{"scope": scope})
+	return false
+}
+
+// oidcLoginRedirect is where an unauthenticated browser is sent to sign in
+// before it gets back to r.
+func oidcLoginRedirect(r *http.Request) string {
+	return oidcLoginPath + "?redirect=" + url.QueryEscape(r.URL.RequestURI())
+}
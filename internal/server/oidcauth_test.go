@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+
+	"darkhold-go/internal/config"
+)
+
+// startFakeIssuer serves discovery, keys and a token endpoint whose ID token
+// carries claims plus the nonce of the login being finished.
+func startFakeIssuer(t *testing.T, claims map[string]any) (issuer *httptest.Server, nonce *string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce = new(string)
+	mux := http.NewServeMux()
+	issuer = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"issuer": issuer.URL, "authorization_endpoint": issuer.URL + "/authorize",
+			"token_endpoint": issuer.URL + "/token", "jwks_uri": issuer.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []any{map[string]any{"kty": "RSA", "kid": "k1",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()), "e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]any{"iss": issuer.URL, "aud": "darkhold", "nonce": *nonce, "exp": time.Now().Add(time.Hour).Unix()}
+		maps.Copy(body, claims)
+		header, _ := json.Marshal(map[string]any{"alg": "RS256", "kid": "k1"})
+		payload, _ := json.Marshal(body)
+		signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+		digest := sha256.Sum256([]byte(signed))
+		signature, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		_ = json.NewEncoder(w).Encode(map[string]any{"id_token": signed + "." + base64.RawURLEncoding.EncodeToString(signature)})
+	})
+	return issuer, nonce
+}
+
+func TestOIDCSignInGrantsGroupScopes(t *testing.T) {
+	issuer, nonce := startFakeIssuer(t, map[string]any{"sub": "u-1", "email": "ada@example.com", "groups": []any{"eng"}})
+	dataDir := t.TempDir()
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.DataDir = dataDir
+		cfg.OIDCIssuer, cfg.OIDCClientID, cfg.OIDCGroupsClaim = issuer.URL, "darkhold", "groups"
+		cfg.OIDCDefaultScopes = []string{"read"}
+		cfg.OIDCGroupScopes = map[string][]string{"eng": {"write"}, "ops": {"admin"}}
+		cfg.OIDCSessionTTL = time.Hour
+	})
+	defer s.close()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	get := func(path string, cookies ...*http.Cookie) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, s.http.URL+path, nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	cookie := func(resp *http.Response, name string) *http.Cookie {
+		for _, c := range resp.Cookies() {
+			if c.Name == name {
+				return c
+			}
+		}
+		t.Fatalf("no %s cookie in %v", name, resp.Header)
+		return nil
+	}
+
+	if resp := get("/threads?x=1"); resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != oidcLoginPath+"?redirect=%2Fthreads%3Fx%3D1" {
+		t.Fatalf("expected the web UI to send the browser to sign in, got %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if resp, body := doJSON(t, http.MethodGet, s.http.URL+"/api/threads", nil); resp.StatusCode != http.StatusUnauthorized || body["details"].(map[string]any)["login"] != oidcLoginPath {
+		t.Fatalf("expected the API to ask for a sign-in, got %d %v", resp.StatusCode, body)
+	}
+
+	login := get(oidcLoginPath + "?redirect=/threads")
+	authorize, _ := url.Parse(login.Header.Get("Location"))
+	if login.StatusCode != http.StatusFound || !strings.HasPrefix(authorize.String(), issuer.URL+"/authorize?") {
+		t.Fatalf("expected a redirect to the issuer, got %d %q", login.StatusCode, authorize)
+	}
+	loginCookie := cookie(login, oidcLoginCookie)
+	*nonce = authorize.Query().Get("nonce")
+	if resp := get(oidcCallbackPath+"?code=c&state=forged", loginCookie); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected a forged state to be refused, got %d", resp.StatusCode)
+	}
+	callback := get(oidcCallbackPath+"?code=c&state="+url.QueryEscape(authorize.Query().Get("state")), loginCookie)
+	if callback.StatusCode != http.StatusFound || callback.Header.Get("Location") != "/threads" {
+		t.Fatalf("expected the callback to return to /threads, got %d %q", callback.StatusCode, callback.Header.Get("Location"))
+	}
+	session := cookie(callback, oidcSessionCookie)
+
+	req, _ := http.NewRequest(http.MethodGet, s.http.URL+"/api/auth/me", nil)
+	req.AddCookie(session)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var me map[string]any
+	_ = json.NewDecoder(resp.Body).Decode(&me)
+	resp.Body.Close()
+	if me["identity"] != "oidc:ada@example.com" || me["provider"] != "oidc" || strings.Join(toStrings(me["scopes"]), ",") != "read,write" {
+		t.Fatalf("unexpected identity: %v", me)
+	}
+	if resp := get("/api/threads", session); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected read access, got %d", resp.StatusCode)
+	}
+	if resp := get("/api/admin/sessions", session); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected admin routes to need the admin scope, got %d", resp.StatusCode)
+	}
+	req, _ = http.NewRequest(http.MethodPost, s.http.URL+"/api/thread/interaction/respond", strings.NewReader(`{}`))
+	req.AddCookie(session)
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected responding to need the approve scope, got %v %v", resp, err)
+	}
+	req, _ = http.NewRequest(http.MethodPost, s.http.URL+"/api/thread/interaction/quick-links", strings.NewReader(`{}`))
+	req.AddCookie(session)
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected minting quick links to need the approve scope, got %v %v", resp, err)
+	}
+	if resp := get(quickLinkPath+"?token=t&decision=accept", session); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected redeeming a quick link while signed in to need the approve scope, got %d", resp.StatusCode)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(s.http.URL, "http")+"/api/session/ws?threadId=t-1", &websocket.DialOptions{HTTPHeader: http.Header{"Cookie": {session.String()}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.CloseNow()
+	if err := conn.Write(ctx, websocket.MessageText, []byte(`{"jsonrpc":"2.0","id":7,"result":{"decision":"accept"}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if frame := readWSFrame(t, ctx, conn, func(f map[string]any) bool { return f["id"] == float64(7) }); !strings.Contains(fmt.Sprint(frame["error"]), errCodeForbidden) {
+		t.Fatalf("expected a WebSocket response to need the approve scope, got %v", frame)
+	}
+
+	audit, _ := os.ReadFile(filepath.Join(dataDir, "audit.jsonl"))
+	if !strings.Contains(string(audit), `"action":"auth.login","identity":"oidc:ada@example.com"`) {
+		t.Fatalf("expected the sign-in to be audited, got %s", audit)
+	}
+}
+
+func toStrings(value any) []string {
+	items, _ := value.([]any)
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		s, _ := item.(string)
+		out = append(out, s)
+	}
+	return out
+}
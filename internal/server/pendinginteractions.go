@@ -0,0 +1,127 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// pendingInteractionView is one unresolved interaction in
+// /api/interactions/pending, with enough of the thread and the request to
+// decide on it without opening the thread's stream.
+type pendingInteractionView struct {
+	ThreadID    string `json:"threadId"`
+	ThreadTitle string `json:"threadTitle,omitempty"`
+	RequestID   string `json:"requestId"`
+	Method      string `json:"method"`
+	Kind        string `json:"kind"`
+	ReceivedAt  int64  `json:"receivedAt"`
+	AgeMs       int64  `json:"ageMs"`
+	// Command and Cwd are set for exec approvals.
+	Command string `json:"command,omitempty"`
+	Cwd     string `json:"cwd,omitempty"`
+	// Files lists the changes of a file-change approval.
+	Files       []pendingInteractionFile `json:"files,omitempty"`
+	Reason      string                   `json:"reason,omitempty"`
+	Confinement *confinementCheck        `json:"confinement,omitempty"`
+}
+
+type pendingInteractionFile struct {
+	Path string `json:"path"`
+	Kind string `json:"kind,omitempty"`
+}
+
+// handlePendingInteractions lists every unresolved interaction held by this
+// replica across all threads, oldest first, so one approvals inbox can be
+// built without a stream per thread. ?kind= and ?threadId= narrow it.
+func (s *Server) handlePendingInteractions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	kind := strings.TrimSpace(r.URL.Query().Get("kind"))
+	threadFilter := strings.TrimSpace(r.URL.Query().Get("threadId"))
+
+	type pending struct {
+		threadID, requestID string
+		entry               pendingInteraction
+	}
+	var entries []pending
+	s.sessionsMu.RLock()
+	for threadID, requests := range s.pendingResponses {
+		if threadFilter != "" && threadID != threadFilter {
+			continue
+		}
+		for requestID, entry := range requests {
+			entries = append(entries, pending{threadID: threadID, requestID: requestID, entry: entry})
+		}
+	}
+	s.sessionsMu.RUnlock()
+
+	now := time.Now()
+	views := make([]pendingInteractionView, 0, len(entries))
+	for _, pending := range entries {
+		view := s.pendingInteractionView(pending.threadID, pending.requestID, pending.entry, now)
+		if kind != "" && view.Kind != kind {
+			continue
+		}
+		views = append(views, view)
+	}
+	sort.Slice(views, func(i, j int) bool {
+		if views[i].ReceivedAt != views[j].ReceivedAt {
+			return views[i].ReceivedAt < views[j].ReceivedAt
+		}
+		if views[i].ThreadID != views[j].ThreadID {
+			return views[i].ThreadID < views[j].ThreadID
+		}
+		return views[i].RequestID < views[j].RequestID
+	})
+	writeJSON(w, http.StatusOK, map[string]any{"interactions": views, "count": len(views)})
+}
+
+func (s *Server) pendingInteractionView(threadID, requestID string, entry pendingInteraction, now time.Time) pendingInteractionView {
+	view := pendingInteractionView{
+		ThreadID:   threadID,
+		RequestID:  requestID,
+		Method:     entry.method,
+		Kind:       reverseRequestHandlerFor(entry.method).kind,
+		ReceivedAt: entry.receivedAt.UnixMilli(),
+		AgeMs:      now.Sub(entry.receivedAt).Milliseconds(),
+	}
+	if meta, ok := s.threadIndex.Get(threadID); ok {
+		view.ThreadTitle = meta.Title
+	}
+	params, _ := entry.params.(map[string]any)
+	view.Command = approvalCommandText(params)
+	view.Cwd, _ = params["cwd"].(string)
+	view.Reason, _ = params["reason"].(string)
+	view.Confinement = s.checkConfinement(threadID, params)
+	for _, key := range []string{"changes", "fileChanges"} {
+		switch list := params[key].(type) {
+		case []any:
+			for i, raw := range list {
+				if change, ok := raw.(map[string]any); ok {
+					view.Files = append(view.Files, pendingFileChange(change, i))
+				}
+			}
+		case map[string]any:
+			// applyPatchApproval keys its changes by path; annotateFileChanges
+			// has already copied the key into each change.
+			i := 0
+			for _, raw := range list {
+				if change, ok := raw.(map[string]any); ok {
+					view.Files = append(view.Files, pendingFileChange(change, i))
+					i++
+				}
+			}
+		}
+	}
+	sort.Slice(view.Files, func(i, j int) bool { return view.Files[i].Path < view.Files[j].Path })
+	return view
+}
+
+func pendingFileChange(change map[string]any, index int) pendingInteractionFile {
+	normalized := normalizeFileChange(change, index)
+	return pendingInteractionFile{Path: normalized.Path, Kind: normalized.Kind}
+}
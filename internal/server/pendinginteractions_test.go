@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/threads"
+)
+
+func TestPendingInteractionsListsEveryThreadOldestFirst(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	if _, err := s.app.threadIndex.Update("t-exec", func(m *threads.Metadata) error {
+		m.Title = "Fix the build"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	s.app.sessionsMu.Lock()
+	s.app.pendingResponses["t-exec"] = map[string]pendingInteraction{"7": {
+		method:     "item/commandExecution/requestApproval",
+		params:     map[string]any{"command": []any{"make", "test"}, "cwd": s.baseDir, "reason": "run the tests"},
+		receivedAt: now.Add(-time.Minute),
+	}}
+	s.app.pendingResponses["t-patch"] = map[string]pendingInteraction{"3": {
+		method: "applyPatchApproval",
+		params: map[string]any{"fileChanges": map[string]any{
+			"b.go": map[string]any{"path": "b.go", "kind": "update"},
+			"a.go": map[string]any{"path": "a.go", "kind": map[string]any{"type": "add"}},
+		}},
+		receivedAt: now,
+	}}
+	s.app.sessionsMu.Unlock()
+
+	resp, body := doJSON(t, http.MethodGet, s.http.URL+"/api/interactions/pending", nil)
+	interactions, _ := body["interactions"].([]any)
+	if resp.StatusCode != http.StatusOK || body["count"] != float64(2) || len(interactions) != 2 {
+		t.Fatalf("unexpected listing: %d %v", resp.StatusCode, body)
+	}
+	exec, patch := interactions[0].(map[string]any), interactions[1].(map[string]any)
+	if exec["threadId"] != "t-exec" || exec["threadTitle"] != "Fix the build" || exec["command"] != "make test" || exec["kind"] != reverseKindApproval || exec["reason"] != "run the tests" || exec["ageMs"].(float64) < 59000 {
+		t.Fatalf("unexpected exec approval: %v", exec)
+	}
+	files, _ := patch["files"].([]any)
+	if patch["requestId"] != "3" || len(files) != 2 || files[0].(map[string]any)["path"] != "a.go" || files[0].(map[string]any)["kind"] != "add" {
+		t.Fatalf("unexpected patch approval: %v", patch)
+	}
+
+	_, body = doJSON(t, http.MethodGet, s.http.URL+"/api/interactions/pending?threadId=t-patch", nil)
+	if body["count"] != float64(1) {
+		t.Fatalf("expected the threadId filter to apply: %v", body)
+	}
+	_, body = doJSON(t, http.MethodGet, s.http.URL+"/api/interactions/pending?kind="+reverseKindUserInput, nil)
+	if body["count"] != float64(0) {
+		t.Fatalf("expected the kind filter to apply: %v", body)
+	}
+}
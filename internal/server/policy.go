@@ -0,0 +1,115 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// policyRequest is POSTed to --policy-url for every upstream interaction
+// request before any human sees it.
+type policyRequest struct {
+	ThreadID  string         `json:"threadId"`
+	RequestID string         `json:"requestId"`
+	Method    string         `json:"method"`
+	Params    map[string]any `json:"params"`
+}
+
+// policyResponse is the policy service's verdict. Decision is one of
+// "accept", "reject" or "escalate". Result optionally overrides the payload
+// sent upstream for accept/reject.
+type policyResponse struct {
+	Decision string `json:"decision"`
+	Reason   string `json:"reason,omitempty"`
+	Result   any    `json:"result,omitempty"`
+}
+
+const (
+	policyAccept   = "accept"
+	policyReject   = "reject"
+	policyEscalate = "escalate"
+
+	// defaultPolicyTimeout bounds a policy call when --policy-timeout is
+	// zero, so a wedged service cannot hold an interaction forever.
+	defaultPolicyTimeout = 10 * time.Second
+)
+
+// consultPolicy asks the external policy service about an interaction and
+// either resolves it directly or escalates it to SSE clients. Any failure to
+// reach the policy service escalates, so humans remain the fallback.
+func (s *Server) consultPolicy(threadID, requestID, method string, params map[string]any) {
+	timeout := s.cfg.PolicyTimeout
+	if timeout <= 0 {
+		timeout = defaultPolicyTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	verdict, err := s.askPolicy(ctx, policyRequest{ThreadID: threadID, RequestID: requestID, Method: method, Params: params})
+	if err != nil {
+		log.Printf("[policy] escalating %s on thread %s: %v", method, threadID, err)
+		s.publishInteractionRequest(threadID, requestID, method, params)
+		return
+	}
+
+	var result any
+	switch verdict.Decision {
+	case policyAccept:
+		result = map[string]any{"decision": "accept"}
+	case policyReject:
+		result = map[string]any{"decision": "decline"}
+	default:
+		s.publishInteractionRequest(threadID, requestID, method, params)
+		return
+	}
+	if verdict.Result != nil {
+		result = verdict.Result
+	}
+	resolution := map[string]any{"source": "policy", "decision": verdict.Decision}
+	if verdict.Reason != "" {
+		resolution["reason"] = verdict.Reason
+	}
+	err = s.resolveInteraction(threadID, requestID, result, nil, resolution)
+	var invalid *resultShapeError
+	if errors.As(err, &invalid) {
+		log.Printf("[policy] escalating %s on thread %s: result %v", method, threadID, invalid)
+		s.publishInteractionRequest(threadID, requestID, method, params)
+		return
+	}
+	if err != nil {
+		log.Printf("[policy] failed to apply %s for request %s on thread %s: %v", verdict.Decision, requestID, threadID, err)
+	}
+}
+
+func (s *Server) askPolicy(ctx context.Context, request policyRequest) (policyResponse, error) {
+	body, _ := json.Marshal(request)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.PolicyURL, bytes.NewReader(body))
+	if err != nil {
+		return policyResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.policyClient.Do(req)
+	if err != nil {
+		return policyResponse{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return policyResponse{}, fmt.Errorf("policy service returned HTTP %d", resp.StatusCode)
+	}
+	var verdict policyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&verdict); err != nil {
+		return policyResponse{}, fmt.Errorf("invalid policy response: %w", err)
+	}
+	verdict.Decision = strings.ToLower(strings.TrimSpace(verdict.Decision))
+	switch verdict.Decision {
+	case policyAccept, policyReject, policyEscalate:
+		return verdict, nil
+	default:
+		return policyResponse{}, fmt.Errorf("unknown policy decision %q", verdict.Decision)
+	}
+}
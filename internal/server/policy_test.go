@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestPolicyServiceAcceptsWithoutPublishingRequest(t *testing.T) {
+	policy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req policyRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Method != "execCommandApproval" || req.ThreadID == "" || req.RequestID == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(policyResponse{Decision: "accept", Reason: "echo is safe"})
+	}))
+	defer policy.Close()
+
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.PolicyURL = policy.URL
+		cfg.PolicyTimeout = 5 * time.Second
+	})
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	stream := openSSE(t, s.http.URL, threadID, "")
+	defer stream.Body.Close()
+
+	_ = postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "policy LEGACY_APPROVAL"}}})
+	sawRequest := false
+	waitForSSEEvent(t, stream, func(event sseEvent) bool {
+		parsed := parseJSON(t, event.Data)
+		switch parsed["method"] {
+		case "darkhold/interaction/request":
+			sawRequest = true
+		case "darkhold/interaction/resolved":
+			params := parsed["params"].(map[string]any)
+			if params["source"] != "policy" || params["decision"] != "accept" {
+				t.Fatalf("unexpected resolution: %v", params)
+			}
+		}
+		return parsed["method"] == "turn/completed"
+	}, 10*time.Second)
+	if sawRequest {
+		t.Fatal("accepted interactions should not be published to SSE clients")
+	}
+}
+
+func TestPolicyServiceEscalatesToClients(t *testing.T) {
+	policy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(policyResponse{Decision: "escalate"})
+	}))
+	defer policy.Close()
+
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.PolicyURL = policy.URL
+		cfg.PolicyTimeout = 5 * time.Second
+	})
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	stream := openSSE(t, s.http.URL, threadID, "")
+	defer stream.Body.Close()
+
+	_ = postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "escalate"}}})
+	acceptNextApproval(t, s.http.URL, threadID, stream)
+	waitForSSEEvent(t, stream, func(event sseEvent) bool {
+		return parseJSON(t, event.Data)["method"] == "turn/completed"
+	}, 10*time.Second)
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	browserfs "darkhold-go/internal/fs"
+)
+
+const (
+	// postTurnOutputLimit caps the output of a post-turn hook kept in its
+	// event: the combined stdout and stderr of a command, or the body of a
+	// webhook's response.
+	postTurnOutputLimit = 64 << 10
+
+	// Post-turn hook outcomes.
+	postTurnPassed = "passed"
+	postTurnFailed = "failed"
+	postTurnError  = "error"
+)
+
+// postTurnSummary is what a post-turn hook is given about the turn that
+// just completed: JSON on a command's stdin, or a webhook's request body.
+type postTurnSummary struct {
+	ThreadID    string   `json:"threadId"`
+	TurnID      string   `json:"turnId"`
+	Cwd         string   `json:"cwd"`
+	Status      string   `json:"status"`
+	Error       any      `json:"error,omitempty"`
+	Message     string   `json:"message"`
+	Files       []string `json:"files"`
+	CompletedAt int64    `json:"completedAt"`
+}
+
+// postTurnResult is the outcome of one hook on one turn, published as
+// darkhold/turn/postHook. Status is passed for a zero exit or a 2xx
+// response, failed otherwise, and error when the hook could not run or
+// timed out.
+type postTurnResult struct {
+	ThreadID   string `json:"threadId"`
+	TurnID     string `json:"turnId"`
+	Hook       string `json:"hook"`
+	Status     string `json:"status" enum:"passed,failed,error"`
+	ExitCode   int    `json:"exitCode,omitempty"`
+	HTTPStatus int    `json:"httpStatus,omitempty"`
+	Output     string `json:"output"`
+	Truncated  bool   `json:"truncated,omitempty"`
+	Error      string `json:"error,omitempty"`
+	StartedAt  int64  `json:"startedAt"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// postTurnHookName is how a --post-turn-hook is named in events: commands
+// by their command line, webhooks by their URL without credentials or
+// query, which may hold tokens.
+func postTurnHookName(spec string) string {
+	if _, ok := strings.CutPrefix(spec, "cmd:"); ok {
+		return spec
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		return "webhook"
+	}
+	u.User, u.RawQuery, u.Fragment = nil, "", ""
+	return u.String()
+}
+
+// startPostTurnHooks runs the --post-turn-hook pipeline for a completed
+// turn in the background, so the session's reader is never held up.
+// Interrupted turns are skipped: someone stopped them on purpose.
+func (s *Server) startPostTurnHooks(threadID string, params map[string]any) {
+	if len(s.cfg.PostTurnHooks) == 0 {
+		return
+	}
+	turn, _ := params["turn"].(map[string]any)
+	status, _ := turn["status"].(string)
+	turnID := eventTurnID(params)
+	if status == "interrupted" || turnID == "" {
+		return
+	}
+	summary := postTurnSummary{ThreadID: threadID, TurnID: turnID, Status: status, Error: turn["error"], Files: []string{}, CompletedAt: time.Now().UnixMilli()}
+	if message, ok := s.turnMessages.latest(threadID, turnID); ok {
+		summary.Message = message.Text
+	}
+	go func() {
+		defer s.recoverPanic("post-turn-hook")
+		s.runPostTurnHooks(summary)
+	}()
+}
+
+func (s *Server) runPostTurnHooks(summary postTurnSummary) {
+	meta, _ := s.threadIndex.Get(summary.ThreadID)
+	if meta.Cwd != "" {
+		if resolved, err := browserfs.ResolvePath(meta.Cwd); err == nil {
+			summary.Cwd = resolved
+		}
+	}
+	if review, ok, err := s.loadTurnReview(summary.ThreadID, summary.TurnID); err == nil && ok {
+		for _, file := range review.Files {
+			summary.Files = append(summary.Files, file.Path)
+		}
+	}
+	for _, spec := range s.cfg.PostTurnHooks {
+		result := postTurnResult{ThreadID: summary.ThreadID, TurnID: summary.TurnID, Hook: postTurnHookName(spec)}
+		started := time.Now()
+		result.StartedAt = started.UnixMilli()
+		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.PostTurnHookTimeout)
+		if command, ok := strings.CutPrefix(spec, "cmd:"); ok {
+			s.runPostTurnCommand(ctx, command, summary, &result)
+		} else {
+			s.postTurnWebhook(ctx, spec, summary, &result)
+		}
+		cancel()
+		result.DurationMs = time.Since(started).Milliseconds()
+		log.Printf("[post-turn-hook] %s on thread %s turn %s: %s in %s", result.Hook, summary.ThreadID, summary.TurnID, result.Status, time.Since(started).Round(time.Millisecond))
+		encoded, _ := json.Marshal(map[string]any{"method": "darkhold/turn/postHook", "params": result})
+		s.publishThreadEvent(summary.ThreadID, string(encoded))
+	}
+}
+
+// runPostTurnCommand runs a hook command in the thread's cwd, with the
+// summary on stdin and DARKHOLD_THREAD_ID / DARKHOLD_TURN_ID set, so plain
+// commands such as "go test ./..." work as hooks.
+func (s *Server) runPostTurnCommand(ctx context.Context, command string, summary postTurnSummary, result *postTurnResult) {
+	if summary.Cwd == "" {
+		result.Status, result.Error = postTurnError, "thread cwd is unknown or outside the base path"
+		return
+	}
+	args := strings.Fields(command)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = summary.Cwd
+	cmd.WaitDelay = execWaitDelay
+	cmd.Env = append(os.Environ(), "DARKHOLD_THREAD_ID="+summary.ThreadID, "DARKHOLD_TURN_ID="+summary.TurnID)
+	stdin, _ := json.Marshal(summary)
+	cmd.Stdin = bytes.NewReader(stdin)
+	output := &cappedBuffer{limit: postTurnOutputLimit}
+	cmd.Stdout, cmd.Stderr = output, output
+
+	err := cmd.Run()
+	result.Output, result.Truncated = output.buf.String(), output.truncated
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		result.Status, result.Error = postTurnError, fmt.Sprintf("timed out after %s", s.cfg.PostTurnHookTimeout)
+	case errors.As(err, &exitErr):
+		result.Status, result.ExitCode = postTurnFailed, exitErr.ExitCode()
+	case err != nil:
+		result.Status, result.Error = postTurnError, err.Error()
+	default:
+		result.Status = postTurnPassed
+	}
+}
+
+// postTurnWebhook POSTs the summary to a hook URL and keeps the start of
+// the response body as the output.
+func (s *Server) postTurnWebhook(ctx context.Context, target string, summary postTurnSummary, result *postTurnResult) {
+	body, _ := json.Marshal(summary)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		result.Status, result.Error = postTurnError, err.Error()
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		result.Status, result.Error = postTurnError, err.Error()
+		return
+	}
+	defer resp.Body.Close()
+	output := &cappedBuffer{limit: postTurnOutputLimit}
+	_, _ = io.Copy(output, io.LimitReader(resp.Body, postTurnOutputLimit+1))
+	result.Output, result.Truncated, result.HTTPStatus = output.buf.String(), output.truncated, resp.StatusCode
+	result.Status = postTurnFailed
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		result.Status = postTurnPassed
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestPostTurnHooksRunOnCompletedTurns(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the command hook is a shell script")
+	}
+	script := filepath.Join(t.TempDir(), "check.sh")
+	body := `cat > summary.json
+echo "checking $DARKHOLD_TURN_ID"
+exit 2
+`
+	if err := os.WriteFile(script, []byte(body), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	summaries := make(chan postTurnSummary, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var summary postTurnSummary
+		data, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(data, &summary)
+		summaries <- summary
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer hook.Close()
+
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.FakeApprovalRate = 0
+		cfg.PostTurnHooks = []string{"cmd:sh " + script, hook.URL + "/ci?token=secret"}
+		cfg.PostTurnHookTimeout = 10 * time.Second
+	})
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	turn := postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "hello"}}})
+	turnID := turn["turn"].(map[string]any)["id"].(string)
+
+	var results []map[string]any
+	waitForCondition(t, 5*time.Second, 20*time.Millisecond, func() bool {
+		events, _ := s.store.Read(threadID)
+		results = results[:0]
+		for _, event := range events {
+			if strings.Contains(event, "darkhold/turn/postHook") {
+				results = append(results, parseJSON(t, event)["params"].(map[string]any))
+			}
+		}
+		return len(results) == 2
+	})
+	command, webhook := results[0], results[1]
+	if command["status"] != postTurnFailed || command["exitCode"] != float64(2) || command["output"] != "checking "+turnID+"\n" {
+		t.Fatalf("unexpected command result: %v", command)
+	}
+	if webhook["status"] != postTurnPassed || webhook["httpStatus"] != float64(200) || webhook["output"] != "ok" || strings.Contains(webhook["hook"].(string), "secret") {
+		t.Fatalf("unexpected webhook result: %v", webhook)
+	}
+
+	summary := <-summaries
+	if summary.ThreadID != threadID || summary.TurnID != turnID || summary.Status != "completed" || summary.Cwd == "" || summary.Files == nil {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	written, err := os.ReadFile(filepath.Join(summary.Cwd, "summary.json"))
+	if err != nil || !strings.Contains(string(written), turnID) {
+		t.Fatalf("expected the command to get the summary on stdin in the cwd: %q, %v", written, err)
+	}
+}
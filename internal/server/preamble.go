@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"maps"
+	"strings"
+)
+
+// maxPreambleChars caps a workspace preamble.
+const maxPreambleChars = 8000
+
+// Markers around injected preamble text. They let clients tell the preamble
+// apart from what the user typed when the agent echoes the input back in
+// stored userMessage items.
+const (
+	preambleOpen  = "<darkhold-preamble>"
+	preambleClose = "</darkhold-preamble>"
+)
+
+// systemPreamble returns the preamble for a thread's turns: the global
+// --system-preamble, then the preamble of the workspace holding the thread's
+// cwd. sources names where each part came from.
+func (s *Server) systemPreamble(threadID string) (text string, sources []string) {
+	var parts []string
+	if s.cfg.SystemPreamble != "" {
+		parts, sources = append(parts, s.cfg.SystemPreamble), append(sources, "global")
+	}
+	meta, _ := s.threadIndex.Get(threadID)
+	if ws, ok := s.threadIndex.WorkspaceForCwd(meta.Cwd); ok && strings.TrimSpace(ws.Settings.Preamble) != "" {
+		parts, sources = append(parts, strings.TrimSpace(ws.Settings.Preamble)), append(sources, "workspace:"+ws.ID)
+	}
+	return strings.Join(parts, "\n\n"), sources
+}
+
+// withSystemPreamble puts the thread's preamble first in a turn/start input,
+// as one text item wrapped in the preamble markers.
+func (s *Server) withSystemPreamble(threadID string, params map[string]any) map[string]any {
+	text, _ := s.systemPreamble(threadID)
+	input, ok := params["input"].([]any)
+	if text == "" || !ok {
+		return params
+	}
+	out := make(map[string]any, len(params))
+	maps.Copy(out, params)
+	item := map[string]any{"type": "text", "text": preambleOpen + "\n" + text + "\n" + preambleClose}
+	out["input"] = append([]any{item}, input...)
+	return out
+}
+
+// recordSystemPreamble appends darkhold/turn/preamble to the thread log after
+// a turn/start that carried a preamble, so transcripts show which
+// instructions the agent was given beyond the user's prompt.
+func (s *Server) recordSystemPreamble(threadID string) {
+	text, sources := s.systemPreamble(threadID)
+	if text == "" {
+		return
+	}
+	notice, _ := json.Marshal(map[string]any{
+		"method": "darkhold/turn/preamble",
+		"params": map[string]any{"threadId": threadID, "sources": sources, "text": text},
+	})
+	s.publishThreadEvent(threadID, string(notice))
+}
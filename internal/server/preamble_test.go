@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestSystemPreambleLeadsEveryTurnAndIsLogged(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.SystemPreamble = "Never touch files under /infra."
+	})
+	defer s.close()
+
+	resp, _ := doJSON(t, http.MethodPost, s.http.URL+"/api/workspaces", map[string]any{
+		"name":     "Payments",
+		"cwd":      s.baseDir,
+		"settings": map[string]any{"preamble": "Run make lint before finishing.", "pinnedNotes": []string{"staging is down"}},
+	})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("unexpected workspace create: %d", resp.StatusCode)
+	}
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+
+	params := map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "fix the build"}}}
+	input := s.app.withSystemPreamble(threadID, s.app.withPinnedNotes(threadID, params))["input"].([]any)
+	first := input[0].(map[string]any)["text"].(string)
+	if len(input) != 3 || !strings.HasPrefix(first, preambleOpen) || !strings.HasSuffix(first, preambleClose) {
+		t.Fatalf("expected the marked preamble ahead of the pinned notes: %v", input)
+	}
+	if strings.Index(first, "/infra") > strings.Index(first, "make lint") {
+		t.Fatalf("expected the global preamble before the workspace one: %q", first)
+	}
+
+	postRPC[map[string]any](t, s.http.URL, "turn/start", params)
+	var logged string
+	waitForCondition(t, 3*time.Second, 20*time.Millisecond, func() bool {
+		events, _ := s.store.Read(threadID)
+		for _, event := range events {
+			if strings.Contains(event, "darkhold/turn/preamble") {
+				logged = event
+			}
+		}
+		return logged != ""
+	})
+	sources, _ := parseJSON(t, logged)["params"].(map[string]any)["sources"].([]any)
+	if len(sources) != 2 || sources[0] != "global" || sources[1] != "workspace:payments" {
+		t.Fatalf("unexpected preamble event: %s", logged)
+	}
+
+	resp, invalid := doJSON(t, http.MethodPatch, s.http.URL+"/api/workspaces", map[string]any{"id": "payments", "settings": map[string]any{"preamble": strings.Repeat("x", maxPreambleChars+1)}})
+	if resp.StatusCode != http.StatusBadRequest || invalid["code"] != errCodeInvalidRequest {
+		t.Fatalf("expected an oversized preamble to be rejected: %d %v", resp.StatusCode, invalid)
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// processStartTime returns when pid started, in clock ticks since boot, from
+// /proc/<pid>/stat. Zombies count as gone.
+func processStartTime(pid int) (string, error) {
+	fields, err := procStatFields(pid)
+	if err != nil {
+		return "", err
+	}
+	if fields[0] == "Z" || fields[0] == "X" {
+		return "", errProcessGone
+	}
+	return fields[19], nil
+}
+
+// procStatFields reads /proc/<pid>/stat from field 3 (state) on: the
+// command name before it is parenthesized and may contain spaces. Field n of
+// proc(5) is at index n-3.
+func procStatFields(pid int) ([]string, error) {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errProcessGone
+	}
+	if err != nil {
+		return nil, err
+	}
+	end := strings.LastIndexByte(string(data), ')')
+	if end < 0 {
+		return nil, errors.New("malformed /proc stat")
+	}
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 22 {
+		return nil, errors.New("malformed /proc stat")
+	}
+	return fields, nil
+}
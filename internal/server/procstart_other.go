@@ -0,0 +1,10 @@
+//go:build !linux && !windows
+
+package server
+
+// processStartTime is only implemented on Linux and Windows. Elsewhere
+// orphaned agents are reported but not terminated, since a recycled PID
+// cannot be ruled out.
+func processStartTime(pid int) (string, error) {
+	return "", errProcessStartUnknown
+}
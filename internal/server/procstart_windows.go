@@ -0,0 +1,39 @@
+package server
+
+import (
+	"errors"
+	"strconv"
+	"syscall"
+)
+
+const (
+	processQueryLimitedInformation = 0x1000
+	stillActive                    = 259
+	errorInvalidParameter          = syscall.Errno(87)
+)
+
+// processStartTime returns pid's creation time, in Unix nanoseconds, from
+// GetProcessTimes. Exited processes whose handles are still held elsewhere
+// count as gone.
+func processStartTime(pid int) (string, error) {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if errors.Is(err, errorInvalidParameter) {
+		return "", errProcessGone
+	}
+	if err != nil {
+		return "", err
+	}
+	defer syscall.CloseHandle(handle)
+	var code uint32
+	if err := syscall.GetExitCodeProcess(handle, &code); err != nil {
+		return "", err
+	}
+	if code != stillActive {
+		return "", errProcessGone
+	}
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(handle, &creation, &exit, &kernel, &user); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(creation.Nanoseconds(), 10), nil
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// clockTicksPerSecond is USER_HZ, which Linux fixes at 100 for /proc.
+const clockTicksPerSecond = 100
+
+// listProcesses returns the parent of every running process, from /proc.
+func listProcesses() (map[int]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	parents := make(map[int]int, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		fields, err := procStatFields(pid)
+		if err != nil {
+			continue
+		}
+		parents[pid], _ = strconv.Atoi(fields[1])
+	}
+	return parents, nil
+}
+
+// processUsage returns the CPU time (user and system) and resident memory of
+// one process.
+func processUsage(pid int) (time.Duration, int64, error) {
+	fields, err := procStatFields(pid)
+	if err != nil {
+		return 0, 0, err
+	}
+	utime, _ := strconv.ParseInt(fields[11], 10, 64)
+	stime, _ := strconv.ParseInt(fields[12], 10, 64)
+	rssPages, _ := strconv.ParseInt(fields[21], 10, 64)
+	cpu := time.Duration(utime+stime) * time.Second / clockTicksPerSecond
+	return cpu, rssPages * int64(os.Getpagesize()), nil
+}
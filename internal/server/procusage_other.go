@@ -0,0 +1,15 @@
+//go:build !linux && !windows
+
+package server
+
+import "time"
+
+// Resource sampling is only implemented on Linux and Windows; elsewhere
+// sessions report no usage and the limits never trigger.
+func listProcesses() (map[int]int, error) {
+	return nil, errProcessUsageUnsupported
+}
+
+func processUsage(pid int) (time.Duration, int64, error) {
+	return 0, 0, errProcessUsageUnsupported
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"errors"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const processVMRead = 0x0010
+
+var procK32GetProcessMemoryInfo = kernel32.NewProc("K32GetProcessMemoryInfo")
+
+type processMemoryCounters struct {
+	Cb                         uint32
+	PageFaultCount             uint32
+	PeakWorkingSetSize         uintptr
+	WorkingSetSize             uintptr
+	QuotaPeakPagedPoolUsage    uintptr
+	QuotaPagedPoolUsage        uintptr
+	QuotaPeakNonPagedPoolUsage uintptr
+	QuotaNonPagedPoolUsage     uintptr
+	PagefileUsage              uintptr
+	PeakPagefileUsage          uintptr
+}
+
+// listProcesses returns the parent of every running process, from a
+// Toolhelp snapshot.
+func listProcesses() (map[int]int, error) {
+	snapshot, err := syscall.CreateToolhelp32Snapshot(syscall.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.CloseHandle(snapshot)
+	parents := map[int]int{}
+	var entry syscall.ProcessEntry32
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	for err = syscall.Process32First(snapshot, &entry); err == nil; err = syscall.Process32Next(snapshot, &entry) {
+		parents[int(entry.ProcessID)] = int(entry.ParentProcessID)
+	}
+	if !errors.Is(err, syscall.ERROR_NO_MORE_FILES) {
+		return nil, err
+	}
+	return parents, nil
+}
+
+// processUsage returns the CPU time (user and kernel) and working set of one
+// process.
+func processUsage(pid int) (time.Duration, int64, error) {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation|processVMRead, false, uint32(pid))
+	if errors.Is(err, errorInvalidParameter) {
+		return 0, 0, errProcessGone
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer syscall.CloseHandle(handle)
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(handle, &creation, &exit, &kernel, &user); err != nil {
+		return 0, 0, err
+	}
+	var counters processMemoryCounters
+	counters.Cb = uint32(unsafe.Sizeof(counters))
+	if r, _, err := procK32GetProcessMemoryInfo.Call(uintptr(handle), uintptr(unsafe.Pointer(&counters)), uintptr(counters.Cb)); r == 0 {
+		return 0, 0, err
+	}
+	return filetimeDuration(kernel) + filetimeDuration(user), int64(counters.WorkingSetSize), nil
+}
+
+// filetimeDuration reads a FILETIME holding an interval of 100ns ticks.
+func filetimeDuration(ft syscall.Filetime) time.Duration {
+	return time.Duration(int64(ft.HighDateTime)<<32|int64(ft.LowDateTime)) * 100
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+type winsize struct {
+	Rows, Cols, X, Y uint16
+}
+
+// terminalSupported reports whether startTerminal can open a PTY here.
+const terminalSupported = true
+
+// startTerminal runs shell in cwd on a new pseudo-terminal. The shell leads
+// its own session with the PTY as controlling terminal, so job control and
+// Ctrl-C work as in a local terminal.
+func startTerminal(shell, cwd string, cols, rows int) (*terminal, error) {
+	ptmx, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	var unlock int32
+	var ptyNumber uint32
+	if err := ptyIoctl(ptmx, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		ptmx.Close()
+		return nil, err
+	}
+	if err := ptyIoctl(ptmx, syscall.TIOCGPTN, unsafe.Pointer(&ptyNumber)); err != nil {
+		ptmx.Close()
+		return nil, err
+	}
+	tty, err := os.OpenFile("/dev/pts/"+strconv.FormatUint(uint64(ptyNumber), 10), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		ptmx.Close()
+		return nil, err
+	}
+	defer tty.Close()
+
+	t := &terminal{pty: ptmx}
+	if err := t.resize(cols, rows); err != nil {
+		ptmx.Close()
+		return nil, err
+	}
+	cmd := exec.Command(shell)
+	cmd.Dir = cwd
+	cmd.Env = append(os.Environ(), "TERM=xterm-256color")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = tty, tty, tty
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+	if err := cmd.Start(); err != nil {
+		ptmx.Close()
+		return nil, err
+	}
+	t.cmd = cmd
+	return t, nil
+}
+
+func (t *terminal) resize(cols, rows int) error {
+	size := winsize{Rows: uint16(rows), Cols: uint16(cols)}
+	return ptyIoctl(t.pty, syscall.TIOCSWINSZ, unsafe.Pointer(&size))
+}
+
+// hangup sends SIGHUP to the shell's process group, as closing a terminal
+// window would.
+func (t *terminal) hangup() {
+	if t.cmd.Process != nil {
+		_ = syscall.Kill(-t.cmd.Process.Pid, syscall.SIGHUP)
+	}
+}
+
+func (t *terminal) kill() {
+	if t.cmd.Process != nil {
+		_ = syscall.Kill(-t.cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// ptyIoctl runs an ioctl through the file's raw conn, leaving the file in
+// non-blocking mode so a blocked Read returns when the PTY is closed.
+func ptyIoctl(f *os.File, request uintptr, arg unsafe.Pointer) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	if err := conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(arg))
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
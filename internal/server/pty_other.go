@@ -0,0 +1,19 @@
+//go:build !linux
+
+package server
+
+// terminalSupported reports whether startTerminal can open a PTY here.
+const terminalSupported = false
+
+// startTerminal is only implemented on Linux.
+func startTerminal(shell, cwd string, cols, rows int) (*terminal, error) {
+	return nil, errTerminalUnsupported
+}
+
+func (t *terminal) resize(cols, rows int) error {
+	return errTerminalUnsupported
+}
+
+func (t *terminal) hangup() {}
+
+func (t *terminal) kill() {}
@@ -0,0 +1,251 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	errCodeQuickLinkInvalid = "QUICK_LINK_INVALID"
+	errCodeQuickLinkExpired = "QUICK_LINK_EXPIRED"
+	errCodeQuickLinkUsed    = "QUICK_LINK_USED"
+
+	quickLinkPath = "/api/interaction/quick"
+)
+
+var (
+	errQuickLinkInvalid = errors.New("quick link is invalid")
+	errQuickLinkExpired = errors.New("quick link has expired")
+)
+
+// quickLinkDecisions maps the decision query parameter to the result sent
+// upstream, matching what the policy hook sends.
+var quickLinkDecisions = map[string]any{
+	"accept":  map[string]any{"decision": "accept"},
+	"decline": map[string]any{"decision": "decline"},
+}
+
+// quickLinkClaims is the signed part of a quick link token. One token covers
+// every decision link for an interaction and works once.
+type quickLinkClaims struct {
+	ThreadID  string `json:"t"`
+	RequestID string `json:"r"`
+	ExpiresAt int64  `json:"e"`
+	Nonce     string `json:"n"`
+}
+
+// quickLinks signs and redeems single-use interaction response tokens. The
+// signing key lives in <data-dir>/quick-link.key so links survive restarts;
+// redeemed nonces are only remembered in memory until they expire.
+type quickLinks struct {
+	signer tokenSigner
+	ttl    time.Duration
+
+	mu   sync.Mutex
+	used map[string]int64
+}
+
+func openQuickLinks(dataDir string, ttl time.Duration) *quickLinks {
+	q := &quickLinks{signer: openSigningKey(dataDir, "quick-link.key", "quick-link"), ttl: ttl, used: map[string]int64{}}
+	if q.ttl <= 0 {
+		q.ttl = 15 * time.Minute
+	}
+	return q
+}
+
+// mint returns a token for an interaction and when it expires.
+func (q *quickLinks) mint(threadID, requestID string) (string, time.Time) {
+	expiresAt := time.Now().Add(q.ttl)
+	nonce := make([]byte, 12)
+	_, _ = rand.Read(nonce)
+	return q.signer.seal(quickLinkClaims{
+		ThreadID:  threadID,
+		RequestID: requestID,
+		ExpiresAt: expiresAt.Unix(),
+		Nonce:     base64.RawURLEncoding.EncodeToString(nonce),
+	}), expiresAt
+}
+
+func (q *quickLinks) verify(token string) (quickLinkClaims, error) {
+	var claims quickLinkClaims
+	if !q.signer.open(token, &claims) || claims.ThreadID == "" || claims.RequestID == "" || claims.Nonce == "" {
+		return claims, errQuickLinkInvalid
+	}
+	if time.Now().Unix() > claims.ExpiresAt {
+		return claims, errQuickLinkExpired
+	}
+	return claims, nil
+}
+
+// redeem marks a verified token as used, reporting false if it already was.
+func (q *quickLinks) redeem(claims quickLinkClaims) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now().Unix()
+	for nonce, expiresAt := range q.used {
+		if expiresAt < now {
+			delete(q.used, nonce)
+		}
+	}
+	if _, used := q.used[claims.Nonce]; used {
+		return false
+	}
+	q.used[claims.Nonce] = claims.ExpiresAt
+	return true
+}
+
+// links builds one URL per decision under baseURL.
+func (q *quickLinks) links(baseURL, token string) map[string]string {
+	links := make(map[string]string, len(quickLinkDecisions))
+	for decision := range quickLinkDecisions {
+		links[decision] = strings.TrimRight(baseURL, "/") + quickLinkPath + "?" + url.Values{"token": {token}, "decision": {decision}}.Encode()
+	}
+	return links
+}
+
+// interactionQuickLinks mints links for a darkhold/interaction/request event
+// so webhook receivers can offer one-tap responses. It returns nil unless
+// --public-url is set.
+func (s *Server) interactionQuickLinks(payload string) map[string]string {
+	if s.cfg.PublicURL == "" {
+		return nil
+	}
+	var event struct {
+		Params struct {
+			ThreadID  string `json:"threadId"`
+			RequestID string `json:"requestId"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal([]byte(payload), &event); err != nil || event.Params.ThreadID == "" || event.Params.RequestID == "" {
+		return nil
+	}
+	token, _ := s.quickLinks.mint(event.Params.ThreadID, event.Params.RequestID)
+	return s.quickLinks.links(s.cfg.PublicURL, token)
+}
+
+func (s *Server) hasPendingInteraction(threadID, requestID string) bool {
+	s.sessionsMu.RLock()
+	defer s.sessionsMu.RUnlock()
+	_, ok := s.pendingResponses[threadID][requestID]
+	return ok
+}
+
+// handleQuickLinks mints links on demand, for notification channels that do
+// not go through webhooks (for example an email relay).
+func (s *Server) handleQuickLinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
+	var request struct {
+		ThreadID  string `json:"threadId"`
+		RequestID string `json:"requestId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+		return
+	}
+	threadID := strings.TrimSpace(request.ThreadID)
+	requestID := strings.TrimSpace(request.RequestID)
+	if threadID == "" || requestID == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId and requestId are required.")
+		return
+	}
+	if !s.hasPendingInteraction(threadID, requestID) && (s.cluster == nil || !s.interactionOpenInLog(threadID, requestID)) {
+		writeError(w, http.StatusConflict, errCodeInteractionResolved, errInteractionNotFound.Error()+".")
+		return
+	}
+	token, expiresAt := s.quickLinks.mint(threadID, requestID)
+	log.Printf("[quick-link] %s minted links for request %s on thread %s", clientIdentity(r.Context()), requestID, threadID)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"threadId":  threadID,
+		"requestId": requestID,
+		"expiresAt": expiresAt.UnixMilli(),
+		"links":     s.quickLinks.links(s.linkBaseURL(r), token),
+	})
+}
+
+// linkBaseURL is the base of links handed out to other people: --public-url,
+// or else the host the request came in on.
+func (s *Server) linkBaseURL(r *http.Request) string {
+	if s.cfg.PublicURL != "" {
+		return s.cfg.PublicURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// handleQuickInteraction resolves an interaction from a signed link. The
+// token is the credential, so this route skips API key auth; the client IP
+// allowlist still applies. HEAD only checks the token, so link preview
+// fetchers that use it do not burn the link.
+func (s *Server) handleQuickInteraction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeMethodNotAllowed(w)
+		return
+	}
+	query := r.URL.Query()
+	decision := query.Get("decision")
+	result, ok := quickLinkDecisions[decision]
+	if !ok {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "decision must be accept or decline.")
+		return
+	}
+	claims, err := s.quickLinks.verify(query.Get("token"))
+	switch {
+	case errors.Is(err, errQuickLinkExpired):
+		log.Printf("[quick-link] rejected expired link for request %s on thread %s from %s", claims.RequestID, claims.ThreadID, r.RemoteAddr)
+		writeError(w, http.StatusGone, errCodeQuickLinkExpired, err.Error()+".")
+		return
+	case err != nil:
+		log.Printf("[quick-link] rejected invalid link from %s", r.RemoteAddr)
+		writeError(w, http.StatusForbidden, errCodeQuickLinkInvalid, err.Error()+".")
+		return
+	}
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	// Wait for the thread before redeeming, so a busy thread does not burn
+	// the link.
+	unlock, err := s.lockThread(r.Context(), claims.ThreadID, "interaction/respond")
+	if err != nil {
+		writeDispatchError(w, err)
+		return
+	}
+	defer unlock()
+	if !s.quickLinks.redeem(claims) {
+		log.Printf("[quick-link] rejected reused link for request %s on thread %s from %s", claims.RequestID, claims.ThreadID, r.RemoteAddr)
+		writeError(w, http.StatusConflict, errCodeQuickLinkUsed, "quick link was already used.")
+		return
+	}
+
+	resolution := map[string]any{"source": "quick-link", "decision": decision}
+	forwarded, err := s.resolveInteractionAnywhere(r.Context(), claims.ThreadID, claims.RequestID, result, nil, resolution)
+	switch {
+	case errors.Is(err, errInteractionNotFound):
+		writeError(w, http.StatusConflict, errCodeInteractionResolved, "interaction request not found or already resolved.")
+		return
+	case err != nil:
+		writeError(w, http.StatusGone, errCodeSessionUnavailable, "app-server session is unavailable.")
+		return
+	}
+	log.Printf("[quick-link] %s request %s on thread %s from %s (%s)", decision, claims.RequestID, claims.ThreadID, r.RemoteAddr, r.UserAgent())
+	if forwarded {
+		writeInteractionForwarded(w, map[string]any{"threadId": claims.ThreadID, "requestId": claims.RequestID, "decision": decision})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "threadId": claims.ThreadID, "requestId": claims.RequestID, "decision": decision})
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+	"darkhold-go/internal/webhooks"
+)
+
+func TestQuickLinkResolvesInteractionOnce(t *testing.T) {
+	received := make(chan webhooks.Envelope, 16)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var envelope webhooks.Envelope
+		_ = json.Unmarshal(body, &envelope)
+		received <- envelope
+	}))
+	defer endpoint.Close()
+
+	s := startIntegrationServer(t)
+	defer s.close()
+	s.app.cfg.PublicURL = s.http.URL
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	resp, _ := doJSON(t, http.MethodPost, s.http.URL+"/api/webhooks", map[string]any{"url": endpoint.URL, "methods": []string{"darkhold/interaction/request"}})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("unexpected create status %d", resp.StatusCode)
+	}
+	_ = postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "hello"}}})
+
+	var envelope webhooks.Envelope
+	select {
+	case envelope = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no interaction webhook delivered")
+	}
+	accept := envelope.Links["accept"]
+	if !strings.HasPrefix(accept, s.http.URL+"/api/interaction/quick?") || envelope.Links["decline"] == "" {
+		t.Fatalf("unexpected links %v", envelope.Links)
+	}
+
+	head, err := http.Head(accept)
+	if err != nil || head.StatusCode != http.StatusOK {
+		t.Fatalf("HEAD should validate without redeeming: %v %v", head, err)
+	}
+	resp, payload := doJSON(t, http.MethodGet, accept, nil)
+	if resp.StatusCode != http.StatusOK || payload["decision"] != "accept" {
+		t.Fatalf("quick accept failed: %d %v", resp.StatusCode, payload)
+	}
+	resp, payload = doJSON(t, http.MethodGet, accept, nil)
+	if resp.StatusCode != http.StatusConflict || payload["code"] != errCodeQuickLinkUsed {
+		t.Fatalf("expected reused link to be rejected, got %d %v", resp.StatusCode, payload)
+	}
+
+	waitForCondition(t, 5*time.Second, 10*time.Millisecond, func() bool {
+		events, _ := s.store.Read(threadID)
+		joined := strings.Join(events, "\n")
+		return strings.Contains(joined, `"source":"quick-link"`) && strings.Contains(joined, "turn/completed")
+	})
+}
+
+func TestQuickLinkRejectsTamperedAndExpiredTokens(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.APIKeys = []config.APIKey{{Name: "phone", Token: "phone-token-0123456789"}}
+	})
+	defer s.close()
+
+	token, _ := s.app.quickLinks.mint("thread-a", "7001")
+	resp, payload := doJSON(t, http.MethodGet, s.http.URL+"/api/interaction/quick?decision=accept&token="+token+"x", nil)
+	if resp.StatusCode != http.StatusForbidden || payload["code"] != errCodeQuickLinkInvalid {
+		t.Fatalf("expected tampered token to be rejected without an API key, got %d %v", resp.StatusCode, payload)
+	}
+	resp, payload = doJSON(t, http.MethodGet, s.http.URL+"/api/interaction/quick?decision=maybe&token="+token, nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected unknown decision to be rejected, got %d %v", resp.StatusCode, payload)
+	}
+
+	s.app.quickLinks.ttl = -time.Minute
+	expired, _ := s.app.quickLinks.mint("thread-a", "7001")
+	resp, payload = doJSON(t, http.MethodGet, s.http.URL+"/api/interaction/quick?decision=accept&token="+expired, nil)
+	if resp.StatusCode != http.StatusGone || payload["code"] != errCodeQuickLinkExpired {
+		t.Fatalf("expected expired token to be rejected, got %d %v", resp.StatusCode, payload)
+	}
+}
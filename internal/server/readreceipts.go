@@ -0,0 +1,199 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/oklog/ulid/v2"
+
+	"darkhold-go/internal/events"
+	"darkhold-go/internal/receipts"
+)
+
+// maxReaderClientIDBytes bounds the clientId a reader picks for itself.
+const maxReaderClientIDBytes = 128
+
+func openReadReceipts(dataDir string) *receipts.Store {
+	path := ""
+	if dataDir != "" {
+		path = filepath.Join(dataDir, "read-receipts.json")
+	}
+	store, err := receipts.Open(path)
+	if err != nil {
+		log.Printf("[receipts] failed to load %s, using in-memory receipts: %v", path, err)
+		store, _ = receipts.Open("")
+	}
+	return store
+}
+
+// readerKey names whose receipts a request reads and moves: the caller's
+// identity (API key name, or anonymous), narrowed by the optional clientId
+// so devices sharing a key can keep separate receipts.
+func readerKey(r *http.Request, clientID string) string {
+	identity := clientIdentity(r.Context())
+	if clientID = strings.TrimSpace(clientID); clientID != "" {
+		return identity + "/" + clientID
+	}
+	return identity
+}
+
+// countsAsUnread reports whether a stored event is agent activity a reader
+// would want badged: a completed item other than the user's own message, or
+// an interaction waiting on an answer. Streaming deltas and lifecycle
+// notifications do not count.
+func countsAsUnread(payload string) bool {
+	var event struct {
+		Method string `json:"method"`
+		Params struct {
+			Item struct {
+				Type string `json:"type"`
+			} `json:"item"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return false
+	}
+	switch event.Method {
+	case "item/completed":
+		return event.Params.Item.Type != "userMessage"
+	case "darkhold/interaction/request":
+		return true
+	}
+	return false
+}
+
+// unreadState is a reader's position in a thread and what came after it.
+type unreadState struct {
+	LastReadEventID string `json:"lastReadEventId"`
+	// LatestEventID is the thread's newest stored event.
+	LatestEventID string `json:"latestEventId"`
+	Unread        int    `json:"unread"`
+}
+
+func (s *Server) unreadState(reader, threadID string) (unreadState, error) {
+	receipt, _ := s.readReceipts.Get(reader, threadID)
+	records, err := s.eventStore.ReadRange(threadID, receipt.EventID, 0)
+	if err != nil {
+		return unreadState{}, err
+	}
+	state := unreadState{LastReadEventID: receipt.EventID, LatestEventID: receipt.EventID}
+	for _, record := range records {
+		if countsAsUnread(record.Payload) {
+			state.Unread++
+		}
+	}
+	if len(records) > 0 {
+		state.LatestEventID = records[len(records)-1].ID
+	}
+	return state, nil
+}
+
+// unreadCache remembers unread counts per reader and thread until the log or
+// the receipt changes, so listing threads does not re-read every log.
+type unreadCache struct {
+	mu      sync.Mutex
+	entries map[[2]string]cachedUnread
+}
+
+type cachedUnread struct {
+	log   events.ThreadLog
+	state unreadState
+}
+
+// withUnread adds each entry's unread count for reader.
+func (s *Server) withUnread(reader string, entries []threadListEntry, logsByKey map[string]events.ThreadLog) {
+	s.unread.mu.Lock()
+	defer s.unread.mu.Unlock()
+	if s.unread.entries == nil {
+		s.unread.entries = map[[2]string]cachedUnread{}
+	}
+	for i := range entries {
+		threadID := entries[i].ThreadID
+		key := [2]string{reader, threadID}
+		threadLog := logsByKey[s.eventStore.Key(threadID)]
+		receipt, _ := s.readReceipts.Get(reader, threadID)
+		cached, ok := s.unread.entries[key]
+		if !ok || cached.log != threadLog || cached.state.LastReadEventID != receipt.EventID {
+			state, err := s.unreadState(reader, threadID)
+			if err != nil {
+				log.Printf("[receipts] failed to count unread events for thread %s: %v", threadID, err)
+				continue
+			}
+			cached = cachedUnread{log: threadLog, state: state}
+			s.unread.entries[key] = cached
+		}
+		entries[i].LastReadEventID, entries[i].Unread = cached.state.LastReadEventID, cached.state.Unread
+	}
+}
+
+// handleThreadRead reads and moves the caller's read receipt for a thread:
+//
+//	GET  ?threadId=[&clientId=]         {threadId, reader, lastReadEventId, latestEventId, unread}
+//	POST {threadId, eventId, clientId}  marks read up to eventId (default: the latest event)
+//
+// Receipts never move backwards.
+func (s *Server) handleThreadRead(w http.ResponseWriter, r *http.Request) {
+	var threadID, eventID, clientID string
+	switch r.Method {
+	case http.MethodGet:
+		threadID = strings.TrimSpace(r.URL.Query().Get("threadId"))
+		clientID = r.URL.Query().Get("clientId")
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
+		var request struct {
+			ThreadID string `json:"threadId"`
+			EventID  string `json:"eventId"`
+			ClientID string `json:"clientId"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+			return
+		}
+		threadID, eventID, clientID = strings.TrimSpace(request.ThreadID), strings.TrimSpace(request.EventID), request.ClientID
+	default:
+		writeMethodNotAllowed(w)
+		return
+	}
+	if threadID == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+		return
+	}
+	if len(clientID) > maxReaderClientIDBytes {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "clientId must be at most 128 bytes.")
+		return
+	}
+	if _, err := ulid.ParseStrict(eventID); eventID != "" && err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "eventId must be an event ID from the thread's log.")
+		return
+	}
+	reader := readerKey(r, clientID)
+	state, err := s.unreadState(reader, threadID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
+		return
+	}
+	if r.Method == http.MethodPost {
+		if eventID == "" {
+			eventID = state.LatestEventID
+		}
+		if eventID != "" {
+			if _, err := s.readReceipts.Mark(reader, threadID, eventID); err != nil {
+				writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
+				return
+			}
+		}
+		if state, err = s.unreadState(reader, threadID); err != nil {
+			writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, struct {
+		ThreadID string `json:"threadId"`
+		Reader   string `json:"reader"`
+		unreadState
+	}{threadID, reader, state})
+}
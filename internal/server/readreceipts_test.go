@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"darkhold-go/internal/threads"
+)
+
+func TestReadReceiptsDriveUnreadCountsPerReader(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	if _, err := s.app.threadIndex.Update("t-read", func(m *threads.Metadata) error {
+		m.ThreadID = "t-read"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	publish := func() {
+		s.app.publishThreadEvent("t-read", `{"method":"item/completed","params":{"threadId":"t-read","item":{"type":"userMessage","id":"u"}}}`)
+		s.app.publishThreadEvent("t-read", `{"method":"item/agentMessage/delta","params":{"threadId":"t-read","delta":"Do"}}`)
+		s.app.publishThreadEvent("t-read", `{"method":"item/completed","params":{"threadId":"t-read","item":{"type":"agentMessage","id":"a","text":"Done."}}}`)
+	}
+	unread := func(clientID string) float64 {
+		t.Helper()
+		_, body := doJSON(t, http.MethodGet, s.http.URL+"/api/threads?clientId="+clientID, nil)
+		entries, _ := body["threads"].([]any)
+		if len(entries) != 1 {
+			t.Fatalf("unexpected thread list: %v", body)
+		}
+		return entries[0].(map[string]any)["unread"].(float64)
+	}
+
+	publish()
+	if got := unread("phone"); got != 1 {
+		t.Fatalf("expected only the agent message to be unread, got %v", got)
+	}
+
+	resp, read := doJSON(t, http.MethodPost, s.http.URL+"/api/thread/read", map[string]any{"threadId": "t-read", "clientId": "phone"})
+	if resp.StatusCode != http.StatusOK || read["unread"] != float64(0) || read["lastReadEventId"] == "" || read["reader"] != "anonymous/phone" {
+		t.Fatalf("unexpected mark: %d %v", resp.StatusCode, read)
+	}
+	if got := unread("phone"); got != 0 {
+		t.Fatalf("expected the marked reader to have nothing unread, got %v", got)
+	}
+	if got := unread("desktop"); got != 1 {
+		t.Fatalf("expected another reader to keep its own count, got %v", got)
+	}
+
+	publish()
+	if got := unread("phone"); got != 1 {
+		t.Fatalf("expected new agent activity to be unread, got %v", got)
+	}
+
+	// Receipts never move backwards.
+	doJSON(t, http.MethodPost, s.http.URL+"/api/thread/read", map[string]any{"threadId": "t-read", "clientId": "phone", "eventId": "00000000000000000000000000"})
+	if got := unread("phone"); got != 1 {
+		t.Fatalf("expected an older event id to leave the receipt alone, got %v", got)
+	}
+
+	resp, invalid := doJSON(t, http.MethodPost, s.http.URL+"/api/thread/read", map[string]any{"threadId": "t-read", "eventId": "latest"})
+	if resp.StatusCode != http.StatusBadRequest || invalid["code"] != errCodeInvalidRequest {
+		t.Fatalf("expected an invalid event id to be rejected: %d %v", resp.StatusCode, invalid)
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// maxRenderBytes bounds markdown accepted by /api/render.
+const maxRenderBytes = 1 << 20
+
+// markdownRenderer renders GitHub-flavoured markdown. goldmark omits raw HTML
+// and drops javascript:/vbscript:/file: link targets unless told otherwise, so
+// the output is safe to embed without a separate sanitizer.
+var markdownRenderer = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+func renderMarkdown(source string) (string, error) {
+	var out bytes.Buffer
+	if err := markdownRenderer.Convert([]byte(source), &out); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func (s *Server) handleRender(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxRenderBytes+4096)
+	var request struct {
+		Markdown string `json:"markdown"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+		return
+	}
+	if len(request.Markdown) > maxRenderBytes {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "markdown is too large.")
+		return
+	}
+	rendered, err := renderMarkdown(request.Markdown)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"html": rendered})
+}
+
+// withRenderedAgentMessage adds an "html" field to agent message items in
+// item/started and item/completed events. Other payloads are returned as is.
+func withRenderedAgentMessage(payload string) string {
+	var event map[string]any
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		return payload
+	}
+	if method, _ := event["method"].(string); method != "item/completed" && method != "item/started" {
+		return payload
+	}
+	params, _ := event["params"].(map[string]any)
+	item, _ := params["item"].(map[string]any)
+	text, ok := item["text"].(string)
+	if item["type"] != "agentMessage" || !ok || text == "" {
+		return payload
+	}
+	rendered, err := renderMarkdown(text)
+	if err != nil {
+		return payload
+	}
+	item["html"] = rendered
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return payload
+	}
+	return string(encoded)
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdownSanitizes(t *testing.T) {
+	html, err := renderMarkdown("# Title\n\n<script>alert(1)</script>\n\n[click](javascript:alert(1)) and ~~gone~~\n\n| a | b |\n|---|---|\n| 1 | 2 |\n")
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if !strings.Contains(html, "<h1>Title</h1>") || !strings.Contains(html, "<del>gone</del>") || !strings.Contains(html, "<table>") {
+		t.Fatalf("expected GFM output, got %q", html)
+	}
+	if strings.Contains(html, "<script>") || strings.Contains(html, "javascript:") {
+		t.Fatalf("expected unsafe markup to be dropped, got %q", html)
+	}
+}
+
+func TestWithRenderedAgentMessage(t *testing.T) {
+	payload := `{"method":"item/completed","params":{"threadId":"t","item":{"type":"agentMessage","id":"m","text":"**done**"}}}`
+	var event map[string]any
+	if err := json.Unmarshal([]byte(withRenderedAgentMessage(payload)), &event); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	item := event["params"].(map[string]any)["item"].(map[string]any)
+	if item["html"] != "<p><strong>done</strong></p>\n" || item["text"] != "**done**" {
+		t.Fatalf("unexpected item: %v", item)
+	}
+
+	other := `{"method":"item/completed","params":{"item":{"type":"commandExecution","command":"ls"}}}`
+	if got := withRenderedAgentMessage(other); got != other {
+		t.Fatalf("expected non-message payload to be untouched, got %s", got)
+	}
+}
+
+func TestRenderEndpoint(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	resp, payload := doJSON(t, http.MethodPost, s.http.URL+"/api/render", map[string]any{"markdown": "- [x] ship it"})
+	if resp.StatusCode != http.StatusOK || !strings.Contains(payload["html"].(string), `type="checkbox"`) {
+		t.Fatalf("unexpected render response: %d %v", resp.StatusCode, payload)
+	}
+
+	resp, payload = doJSON(t, http.MethodPost, s.http.URL+"/api/render", map[string]any{"markdown": strings.Repeat("x", maxRenderBytes+1)})
+	if resp.StatusCode != http.StatusBadRequest || payload["code"] != errCodeInvalidRequest {
+		t.Fatalf("expected oversized markdown to be rejected: %d %v", resp.StatusCode, payload)
+	}
+}
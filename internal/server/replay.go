@@ -0,0 +1,461 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	browserfs "darkhold-go/internal/fs"
+	"darkhold-go/internal/threads"
+)
+
+const (
+	errCodeReplayNotFound = "REPLAY_NOT_FOUND"
+
+	replayRunning   = "running"
+	replayCompleted = "completed"
+	replayFailed    = "failed"
+	replayCanceled  = "canceled"
+
+	// maxThreadReplays is how many replays are remembered; the oldest
+	// finished ones are forgotten first.
+	maxThreadReplays = 64
+)
+
+// threadReplayView is the state of one replay, keyed by the thread it
+// replays into.
+type threadReplayView struct {
+	ThreadID       string           `json:"threadId"`
+	SourceThreadID string           `json:"sourceThreadId"`
+	Status         string           `json:"status" enum:"running,completed,failed,canceled"`
+	Settings       threads.Settings `json:"settings"`
+	Turns          []replayTurnView `json:"turns"`
+	Completed      int              `json:"completed"`
+	Error          string           `json:"error,omitempty"`
+	StartedAt      int64            `json:"startedAt"`
+	FinishedAt     int64            `json:"finishedAt,omitempty"`
+	StartedBy      string           `json:"startedBy,omitempty"`
+}
+
+type replayTurnView struct {
+	SourceTurnID string `json:"sourceTurnId"`
+	TurnID       string `json:"turnId,omitempty"`
+	Status       string `json:"status,omitempty"`
+}
+
+// threadReplay is a replay and what its run needs.
+type threadReplay struct {
+	view   threadReplayView
+	inputs [][]any
+	cancel context.CancelFunc
+	// ended receives the outcome of the turn being replayed.
+	ended chan replayTurnEnd
+}
+
+type replayTurnEnd struct {
+	turnID, status string
+}
+
+// threadReplayProgress is published as darkhold/thread/replay on the new
+// thread each time a replayed turn ends, and when the replay stops.
+type threadReplayProgress struct {
+	ThreadID       string `json:"threadId"`
+	SourceThreadID string `json:"sourceThreadId"`
+	Status         string `json:"status" enum:"running,completed,failed,canceled"`
+	Turn           int    `json:"turn"`
+	Turns          int    `json:"turns"`
+	SourceTurnID   string `json:"sourceTurnId,omitempty"`
+	TurnID         string `json:"turnId,omitempty"`
+	TurnStatus     string `json:"turnStatus,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// threadReplays holds the replays started since darkhold came up.
+type threadReplays struct {
+	mu       sync.Mutex
+	byThread map[string]*threadReplay
+	order    []string
+}
+
+func (r *threadReplays) add(replay *threadReplay) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.byThread == nil {
+		r.byThread = map[string]*threadReplay{}
+	}
+	r.byThread[replay.view.ThreadID] = replay
+	r.order = append(r.order, replay.view.ThreadID)
+	for i := 0; len(r.order) > maxThreadReplays && i < len(r.order); {
+		if id := r.order[i]; r.byThread[id].view.Status != replayRunning {
+			delete(r.byThread, id)
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			continue
+		}
+		i++
+	}
+}
+
+// get returns a copy of a replay's view.
+func (r *threadReplays) get(threadID string) (threadReplayView, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	replay, ok := r.byThread[threadID]
+	if !ok {
+		return threadReplayView{}, false
+	}
+	return replay.view.snapshot(), true
+}
+
+// cancel stops a running replay and reports whether it was running.
+func (r *threadReplays) cancel(threadID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	replay, ok := r.byThread[threadID]
+	if !ok || replay.view.Status != replayRunning {
+		return false
+	}
+	replay.cancel()
+	return true
+}
+
+// running returns the replay of threadID while it runs.
+func (r *threadReplays) running(threadID string) (*threadReplay, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	replay, ok := r.byThread[threadID]
+	return replay, ok && replay.view.Status == replayRunning
+}
+
+func (r *threadReplays) list() []threadReplayView {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]threadReplayView, 0, len(r.order))
+	for _, v := range slices.Backward(r.order) {
+		out = append(out, r.byThread[v].view.snapshot())
+	}
+	return out
+}
+
+func (r *threadReplays) update(threadID string, fn func(*threadReplayView)) threadReplayView {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	replay := r.byThread[threadID]
+	fn(&replay.view)
+	return replay.view.snapshot()
+}
+
+func (v *threadReplayView) snapshot() threadReplayView {
+	out := *v
+	out.Turns = append([]replayTurnView(nil), v.Turns...)
+	return out
+}
+
+// handleThreadReplay replays the user inputs of an existing thread, turn by
+// turn, into a new thread, so agent behavior can be reproduced and compared
+// after an upgrade or with different settings. POST starts a replay and
+// answers 202; progress is published on the new thread as
+// darkhold/thread/replay. GET reports one replay (?threadId= of the new
+// thread) or all of them, DELETE cancels one.
+func (s *Server) handleThreadReplay(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		threadID := strings.TrimSpace(r.URL.Query().Get("threadId"))
+		if threadID == "" {
+			writeJSON(w, http.StatusOK, map[string]any{"replays": s.replays.list()})
+			return
+		}
+		replay, ok := s.replays.get(threadID)
+		if !ok {
+			writeError(w, http.StatusNotFound, errCodeReplayNotFound, "replay not found.")
+			return
+		}
+		writeJSON(w, http.StatusOK, replay)
+	case http.MethodPost:
+		s.startThreadReplay(w, r)
+	case http.MethodDelete:
+		threadID := strings.TrimSpace(r.URL.Query().Get("threadId"))
+		replay, ok := s.replays.get(threadID)
+		if !ok {
+			writeError(w, http.StatusNotFound, errCodeReplayNotFound, "replay not found.")
+			return
+		}
+		// The replay stops asynchronously; its final status arrives as
+		// darkhold/thread/replay.
+		if s.replays.cancel(threadID) {
+			s.audit.record(r, "thread.replay.cancel", map[string]any{"threadId": threadID, "sourceThreadId": replay.SourceThreadID})
+		}
+		writeJSON(w, http.StatusOK, replay)
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+func (s *Server) startThreadReplay(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
+	var request struct {
+		ThreadID string `json:"threadId"`
+		// Cwd defaults to the source thread's.
+		Cwd string `json:"cwd"`
+		// Model, Effort and ApprovalPolicy override the source thread's
+		// settings for the replayed turns; "" clears one.
+		Model          *string `json:"model"`
+		Effort         *string `json:"effort"`
+		ApprovalPolicy *string `json:"approvalPolicy"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+		return
+	}
+	request.ThreadID = strings.TrimSpace(request.ThreadID)
+	if request.ThreadID == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+		return
+	}
+	source, _ := s.threadIndex.Get(request.ThreadID)
+	var settings threads.Settings
+	if source.Settings != nil {
+		settings = *source.Settings
+	}
+	for _, field := range []struct {
+		value  *string
+		target *string
+	}{
+		{request.Model, &settings.Model},
+		{request.Effort, &settings.Effort},
+		{request.ApprovalPolicy, &settings.ApprovalPolicy},
+	} {
+		if field.value != nil {
+			*field.target = strings.TrimSpace(*field.value)
+		}
+	}
+	if err := settings.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+
+	response, err := s.dispatchRPC(r.Context(), "thread/read", map[string]any{"threadId": request.ThreadID, "includeTurns": true})
+	if err != nil {
+		writeDispatchError(w, err)
+		return
+	}
+	if errObj, ok := response["error"].(map[string]any); ok {
+		writeUpstreamRPCError(w, errObj)
+		return
+	}
+	result, _ := response["result"].(map[string]any)
+	threadObj, _ := result["thread"].(map[string]any)
+	rawTurns, _ := threadObj["turns"].([]any)
+	var turns []replayTurnView
+	var inputs [][]any
+	for _, raw := range rawTurns {
+		turnObj, _ := raw.(map[string]any)
+		if input := replayTurnInput(turnObj); len(input) > 0 {
+			turnID, _ := turnObj["id"].(string)
+			turns = append(turns, replayTurnView{SourceTurnID: turnID})
+			inputs = append(inputs, input)
+		}
+	}
+	if len(inputs) == 0 {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "thread has no user turns to replay.")
+		return
+	}
+
+	cwd := strings.TrimSpace(request.Cwd)
+	if cwd == "" {
+		if cwd = source.Cwd; cwd == "" {
+			cwd, _ = threadObj["cwd"].(string)
+		}
+	}
+	if cwd != "" {
+		resolved, err := browserfs.ResolvePath(cwd)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidPath, err.Error())
+			return
+		}
+		cwd = resolved
+	}
+	started, err := s.dispatchForResult(r.Context(), "thread/start", map[string]any{"cwd": cwd})
+	if err != nil {
+		writeDispatchError(w, err)
+		return
+	}
+	startedThread, _ := started["thread"].(map[string]any)
+	threadID, _ := startedThread["id"].(string)
+	if threadID == "" {
+		writeError(w, http.StatusBadGateway, errCodeInternal, "thread/start returned no thread id.")
+		return
+	}
+	if !settings.IsZero() {
+		if _, err := s.threadIndex.Update(threadID, func(meta *threads.Metadata) error {
+			meta.Settings = &settings
+			meta.Version++
+			meta.UpdatedAt = time.Now().UnixMilli()
+			return nil
+		}); err != nil {
+			writeThreadUpdateError(w, err)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	replay := &threadReplay{
+		view: threadReplayView{
+			ThreadID:       threadID,
+			SourceThreadID: request.ThreadID,
+			Status:         replayRunning,
+			Settings:       settings,
+			Turns:          turns,
+			StartedAt:      time.Now().UnixMilli(),
+			StartedBy:      clientIdentity(r.Context()),
+		},
+		inputs: inputs,
+		cancel: cancel,
+		ended:  make(chan replayTurnEnd, 1),
+	}
+	s.replays.add(replay)
+	s.audit.record(r, "thread.replay", map[string]any{"threadId": threadID, "sourceThreadId": request.ThreadID, "turns": len(inputs), "settings": settings})
+	log.Printf("[replay] replaying %d turns of thread %s into %s", len(inputs), request.ThreadID, threadID)
+	view := replay.view.snapshot()
+	go func() {
+		defer s.recoverPanic("thread-replay")
+		defer cancel()
+		s.runThreadReplay(ctx, replay)
+	}()
+	writeJSON(w, http.StatusAccepted, view)
+}
+
+// replayTurnInput is what the user sent in a turn: the content of its
+// userMessage items without the text darkhold itself added (pinned notes,
+// preambles and git context), which the new thread adds again.
+func replayTurnInput(turn map[string]any) []any {
+	items, _ := turn["items"].([]any)
+	var input []any
+	for _, raw := range items {
+		item, _ := raw.(map[string]any)
+		if item["type"] != "userMessage" {
+			continue
+		}
+		content, _ := item["content"].([]any)
+		for _, raw := range content {
+			entry, ok := raw.(map[string]any)
+			if !ok || entry["type"] == nil {
+				continue
+			}
+			if text, _ := entry["text"].(string); entry["type"] == "text" && injectedTurnText(text) {
+				continue
+			}
+			input = append(input, entry)
+		}
+	}
+	return input
+}
+
+func injectedTurnText(text string) bool {
+	text = strings.TrimSpace(text)
+	return text == "" || strings.HasPrefix(text, preambleOpen) || strings.HasPrefix(text, contextOpen) || strings.HasPrefix(text, pinnedNotesHeader)
+}
+
+func (s *Server) runThreadReplay(ctx context.Context, replay *threadReplay) {
+	threadID := replay.view.ThreadID
+	finish := func(status, message string, progress threadReplayProgress) {
+		view := s.replays.update(threadID, func(v *threadReplayView) {
+			v.Status, v.Error, v.FinishedAt = status, message, time.Now().UnixMilli()
+		})
+		progress.Status, progress.Error = status, message
+		s.publishReplayProgress(view, progress)
+		log.Printf("[replay] replay of thread %s into %s %s after %d of %d turns", view.SourceThreadID, threadID, status, view.Completed, len(view.Turns))
+	}
+	for index, input := range replay.inputs {
+		progress := threadReplayProgress{Turn: index + 1, SourceTurnID: replay.view.Turns[index].SourceTurnID}
+		select {
+		case <-replay.ended:
+		default:
+		}
+		startCtx, cancel := context.WithTimeout(ctx, s.rpcTimeout)
+		result, err := s.dispatchForResult(startCtx, "turn/start", map[string]any{"threadId": threadID, "input": input})
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				finish(replayCanceled, "", progress)
+			} else {
+				finish(replayFailed, err.Error(), progress)
+			}
+			return
+		}
+		turnObj, _ := result["turn"].(map[string]any)
+		progress.TurnID, _ = turnObj["id"].(string)
+		s.replays.update(threadID, func(v *threadReplayView) {
+			v.Turns[index].TurnID, v.Turns[index].Status = progress.TurnID, replayRunning
+		})
+
+		var end replayTurnEnd
+		for waiting := true; waiting; {
+			select {
+			case <-ctx.Done():
+				interruptCtx, cancel := context.WithTimeout(context.Background(), s.rpcTimeout)
+				_, _ = s.dispatchForResult(interruptCtx, "turn/interrupt", map[string]any{"threadId": threadID, "turnId": progress.TurnID})
+				cancel()
+				s.replays.update(threadID, func(v *threadReplayView) { v.Turns[index].Status = "interrupted" })
+				progress.TurnStatus = "interrupted"
+				finish(replayCanceled, "", progress)
+				return
+			case end = <-replay.ended:
+				waiting = end.turnID != "" && progress.TurnID != "" && end.turnID != progress.TurnID
+			}
+		}
+		progress.TurnStatus = end.status
+		view := s.replays.update(threadID, func(v *threadReplayView) {
+			v.Turns[index].Status = end.status
+			if end.status == "completed" {
+				v.Completed++
+			}
+		})
+		if end.status != "completed" {
+			finish(replayFailed, "turn "+end.status, progress)
+			return
+		}
+		if index == len(replay.inputs)-1 {
+			finish(replayCompleted, "", progress)
+			return
+		}
+		progress.Status = replayRunning
+		s.publishReplayProgress(view, progress)
+	}
+}
+
+// observeReplayTurn hands the end of a turn on a replay's thread to the
+// replay waiting for it.
+func (s *Server) observeReplayTurn(threadID, method string, params map[string]any) {
+	replay, running := s.replays.running(threadID)
+	if !running {
+		return
+	}
+	end := replayTurnEnd{turnID: eventTurnID(params), status: "failed"}
+	switch method {
+	case "turn/completed":
+		turn, _ := params["turn"].(map[string]any)
+		if status, _ := turn["status"].(string); status != "" {
+			end.status = status
+		}
+		if end.status == "completed" && turn["error"] != nil {
+			end.status = "failed"
+		}
+	case "turn/aborted":
+		end.status = "interrupted"
+	}
+	select {
+	case replay.ended <- end:
+	default:
+	}
+}
+
+func (s *Server) publishReplayProgress(view threadReplayView, progress threadReplayProgress) {
+	progress.ThreadID, progress.SourceThreadID, progress.Turns = view.ThreadID, view.SourceThreadID, len(view.Turns)
+	encoded, _ := json.Marshal(map[string]any{"method": "darkhold/thread/replay", "params": progress})
+	s.publishThreadEvent(view.ThreadID, string(encoded))
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestThreadReplayRerunsUserTurnsInANewThread(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.FakeApprovalRate = 0
+	})
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	source := started["thread"].(map[string]any)["id"].(string)
+	resp, body := doJSON(t, http.MethodPost, s.http.URL+"/api/thread/replay", map[string]any{"threadId": source})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected a thread without turns refused: %d %v", resp.StatusCode, body)
+	}
+	for _, text := range []string{"first step", "second step"} {
+		turn := postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": source, "input": []any{map[string]any{"type": "text", "text": text}}})
+		turnID := turn["turn"].(map[string]any)["id"].(string)
+		waitForCondition(t, 5*time.Second, 20*time.Millisecond, func() bool {
+			_, ok := s.app.turnMessages.latest(source, turnID)
+			events, _ := s.store.Read(source)
+			return ok && strings.Contains(events[len(events)-1], `"turn/completed"`)
+		})
+	}
+
+	resp, body = doJSON(t, http.MethodPost, s.http.URL+"/api/thread/replay", map[string]any{"threadId": source, "effort": "high"})
+	replayed, _ := body["threadId"].(string)
+	if resp.StatusCode != http.StatusAccepted || replayed == "" || replayed == source || body["settings"].(map[string]any)["effort"] != "high" {
+		t.Fatalf("unexpected replay start: %d %v", resp.StatusCode, body)
+	}
+	waitForCondition(t, 10*time.Second, 20*time.Millisecond, func() bool {
+		_, body = doJSON(t, http.MethodGet, s.http.URL+"/api/thread/replay?threadId="+replayed, nil)
+		return body["status"] != replayRunning
+	})
+	if body["status"] != replayCompleted || body["completed"] != float64(2) {
+		t.Fatalf("unexpected replay: %v", body)
+	}
+	if meta, _ := s.app.threadIndex.Get(replayed); meta.Settings == nil || meta.Settings.Effort != "high" {
+		t.Fatalf("expected the override on the new thread, got %+v", meta.Settings)
+	}
+
+	read := postRPC[map[string]any](t, s.http.URL, "thread/read", map[string]any{"threadId": replayed, "includeTurns": true})
+	turns := read["thread"].(map[string]any)["turns"].([]any)
+	if len(turns) != 2 || replayTurnInput(turns[1].(map[string]any))[0].(map[string]any)["text"] != "second step" {
+		t.Fatalf("expected both inputs replayed in order: %v", turns)
+	}
+	events, _ := s.store.Read(replayed)
+	var progress []map[string]any
+	for _, event := range events {
+		if strings.Contains(event, "darkhold/thread/replay") {
+			progress = append(progress, parseJSON(t, event)["params"].(map[string]any))
+		}
+	}
+	if len(progress) != 2 || progress[0]["status"] != replayRunning || progress[1]["status"] != replayCompleted || progress[1]["sourceThreadId"] != source {
+		t.Fatalf("unexpected progress events: %v", progress)
+	}
+}
+
+func TestReplayTurnInputSkipsInjectedText(t *testing.T) {
+	turn := map[string]any{"items": []any{
+		map[string]any{"type": "userMessage", "content": []any{
+			map[string]any{"type": "text", "text": preambleOpen + "\nbe brief\n" + preambleClose},
+			map[string]any{"type": "text", "text": pinnedNotesHeader + "\n- use pnpm"},
+			map[string]any{"type": "text", "text": "fix the build"},
+			map[string]any{"type": "localImage", "path": "/tmp/shot.png"},
+			map[string]any{"type": "text", "text": contextOpen + "\nbranch: main\n" + contextClose},
+		}},
+		map[string]any{"type": "agentMessage", "text": "done"},
+	}}
+	input := replayTurnInput(turn)
+	if len(input) != 2 || input[0].(map[string]any)["text"] != "fix the build" || input[1].(map[string]any)["type"] != "localImage" {
+		t.Fatalf("unexpected input %v", input)
+	}
+}
@@ -0,0 +1,301 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	retryReasonNetwork     = "network"
+	retryReasonRateLimit   = "rate_limit"
+	retryReasonServer      = "server_error"
+	retryReasonAgentCrash  = "agent_crash"
+	retryReasonUnavailable = "agent_unavailable"
+	retryReasonPermanent   = "permanent"
+)
+
+// transientErrorInfos are codexErrorInfo values worth re-submitting the turn
+// for. Anything else (context window, usage limits, auth, bad requests) is
+// treated as permanent.
+var transientErrorInfos = map[string]string{
+	"httpConnectionFailed":           retryReasonNetwork,
+	"responseStreamConnectionFailed": retryReasonNetwork,
+	"responseStreamDisconnected":     retryReasonNetwork,
+	"responseTooManyFailedAttempts":  retryReasonNetwork,
+	"internalServerError":            retryReasonServer,
+	"serverOverloaded":               retryReasonServer,
+}
+
+// transientMessageHints classify failures that carry no codexErrorInfo.
+var transientMessageHints = []struct {
+	hint   string
+	reason string
+}{
+	{"rate limit", retryReasonRateLimit},
+	{"too many requests", retryReasonRateLimit},
+	{"429", retryReasonRateLimit},
+	{"overloaded", retryReasonServer},
+	{"502", retryReasonServer},
+	{"503", retryReasonServer},
+	{"504", retryReasonServer},
+	{"timed out", retryReasonNetwork},
+	{"timeout", retryReasonNetwork},
+	{"connection reset", retryReasonNetwork},
+	{"connection refused", retryReasonNetwork},
+	{"stream disconnected", retryReasonNetwork},
+	{"network", retryReasonNetwork},
+}
+
+type turnFailure struct {
+	Reason    string
+	Message   string
+	Transient bool
+}
+
+// turnRetryState remembers the latest user-submitted turn/start for a thread
+// so it can be re-submitted if the turn fails transiently.
+type turnRetryState struct {
+	params map[string]any
+	// identity submitted the turn; retries are checked against its budget.
+	identity string
+	attempt  int
+	running  bool
+	timer    *time.Timer
+}
+
+// classifyTurnFailure inspects turn/completed and turn/failed notifications.
+// ok is false when the turn did not fail.
+func classifyTurnFailure(method string, params map[string]any) (turnFailure, bool) {
+	turnObj, _ := params["turn"].(map[string]any)
+	errObj, _ := params["error"].(map[string]any)
+	switch method {
+	case "turn/completed":
+		if status, _ := turnObj["status"].(string); status != "failed" {
+			return turnFailure{}, false
+		}
+		if turnErr, ok := turnObj["error"].(map[string]any); ok {
+			errObj = turnErr
+		}
+	case "turn/failed":
+	default:
+		return turnFailure{}, false
+	}
+	message, _ := errObj["message"].(string)
+	failure := turnFailure{Reason: retryReasonPermanent, Message: message}
+
+	// codexErrorInfo is either a bare string or a single-key object such as
+	// {"httpConnectionFailed": {"httpStatusCode": 502}}.
+	info := ""
+	switch v := errObj["codexErrorInfo"].(type) {
+	case string:
+		info = v
+	case map[string]any:
+		for key := range v {
+			info = key
+		}
+	}
+	if info != "" {
+		if reason, ok := transientErrorInfos[info]; ok {
+			failure.Reason = reason
+			failure.Transient = true
+		}
+		return failure, true
+	}
+	lower := strings.ToLower(message)
+	for _, candidate := range transientMessageHints {
+		if strings.Contains(lower, candidate.hint) {
+			failure.Reason = candidate.reason
+			failure.Transient = true
+			break
+		}
+	}
+	return failure, true
+}
+
+// rememberTurnStart records a user-submitted turn/start while the thread is
+// locked, just before it is sent: the turn's failure can be read from the
+// session ahead of the turn/start response. The returned func settles it
+// once the response is in; a failed start drops the record and puts back
+// the thread's previous one, a successful one cancels any retry of the
+// previous turn still waiting.
+func (s *Server) rememberTurnStart(threadID, identity string, params map[string]any) func(started bool) {
+	state := &turnRetryState{params: params, identity: identity, running: true}
+	s.threadsMu.Lock()
+	previous := s.turnRetries[threadID]
+	s.turnRetries[threadID] = state
+	s.threadsMu.Unlock()
+	return func(started bool) {
+		s.threadsMu.Lock()
+		defer s.threadsMu.Unlock()
+		switch {
+		case started:
+			if previous != nil && previous.timer != nil {
+				previous.timer.Stop()
+			}
+		case s.turnRetries[threadID] != state:
+		case previous != nil:
+			s.turnRetries[threadID] = previous
+		default:
+			delete(s.turnRetries, threadID)
+		}
+	}
+}
+
+// observeTurnOutcome reacts to turn lifecycle notifications: failures go
+// through the retry policy, anything else ends the retry cycle.
+func (s *Server) observeTurnOutcome(threadID, method string, params map[string]any) {
+	if failure, failed := classifyTurnFailure(method, params); failed {
+		turnID, _ := params["turnId"].(string)
+		s.handleTurnFailure(threadID, turnID, failure)
+		return
+	}
+	s.threadsMu.Lock()
+	delete(s.turnRetries, threadID)
+	s.threadsMu.Unlock()
+}
+
+// handleSessionCrash treats running turns on an unexpectedly exited session as
+// transient failures.
+func (s *Server) handleSessionCrash(threadIDs []string) {
+	for _, threadID := range threadIDs {
+		s.handleTurnFailure(threadID, "", turnFailure{
+			Reason:    retryReasonAgentCrash,
+			Message:   "app-server exited while the turn was running",
+			Transient: true,
+		})
+	}
+}
+
+func (s *Server) handleTurnFailure(threadID, turnID string, failure turnFailure) {
+	s.threadsMu.Lock()
+	state := s.turnRetries[threadID]
+	if state == nil || !state.running || s.retriesStopped {
+		s.threadsMu.Unlock()
+		return
+	}
+	state.running = false
+	meta, _ := s.threadIndex.Get(threadID)
+	policy := meta.Retry
+	if !failure.Transient || policy == nil || policy.MaxAttempts == 0 || state.attempt >= policy.MaxAttempts {
+		delete(s.turnRetries, threadID)
+		attempts := state.attempt
+		s.threadsMu.Unlock()
+		if attempts > 0 {
+			stopReason := "exhausted"
+			if !failure.Transient {
+				stopReason = "permanent"
+			}
+			s.publishRetryEvent(threadID, "darkhold/turn/retryStopped", map[string]any{
+				"turnId":     turnID,
+				"attempts":   attempts,
+				"stopReason": stopReason,
+				"reason":     failure.Reason,
+				"error":      failure.Message,
+			})
+		}
+		return
+	}
+	state.attempt++
+	attempt := state.attempt
+	delay := policy.Delay(attempt)
+	state.timer = time.AfterFunc(delay, func() { s.retryTurn(threadID, state) })
+	s.threadsMu.Unlock()
+
+	s.publishRetryEvent(threadID, "darkhold/turn/retrying", map[string]any{
+		"turnId":      turnID,
+		"attempt":     attempt,
+		"maxAttempts": policy.MaxAttempts,
+		"delayMs":     delay.Milliseconds(),
+		"reason":      failure.Reason,
+		"error":       failure.Message,
+	})
+}
+
+// retryTurn re-submits the remembered turn/start. A thread whose session
+// died is resumed on a fresh session first.
+func (s *Server) retryTurn(threadID string, state *turnRetryState) {
+	s.threadsMu.Lock()
+	if s.turnRetries[threadID] != state || s.retriesStopped {
+		s.threadsMu.Unlock()
+		return
+	}
+	state.running = true
+	params, identity := state.params, state.identity
+	s.threadsMu.Unlock()
+
+	if err := s.checkTurnBudget(identity); err != nil {
+		log.Printf("[retry] not retrying turn/start for thread %s: %v", threadID, err)
+		s.handleTurnFailure(threadID, "", turnFailure{Reason: retryReasonPermanent, Message: err.Error()})
+		return
+	}
+	if err := s.submitRetriedTurn(threadID, params); err != nil {
+		log.Printf("[retry] turn/start retry for thread %s failed: %v", threadID, err)
+		failure := turnFailure{Reason: retryReasonUnavailable, Message: err.Error(), Transient: true}
+		var rpcErr upstreamRPCError
+		if errors.As(err, &rpcErr) {
+			failure = turnFailure{Reason: retryReasonPermanent, Message: rpcErr.message}
+		}
+		s.handleTurnFailure(threadID, "", failure)
+	}
+}
+
+type upstreamRPCError struct{ message string }
+
+func (e upstreamRPCError) Error() string { return e.message }
+
+func (s *Server) submitRetriedTurn(threadID string, params map[string]any) error {
+	s.sessionsMu.RLock()
+	_, bound := s.threadToSession[threadID]
+	s.sessionsMu.RUnlock()
+
+	sess, err := s.selectSession(threadID)
+	if err != nil {
+		return err
+	}
+	if err := s.ensureInitialized(sess); err != nil {
+		return err
+	}
+	call := func(method string, params any) error {
+		ctx, cancel := context.WithTimeout(context.Background(), s.rpcTimeout)
+		defer cancel()
+		response, err := s.callSessionRPC(ctx, sess, method, params)
+		if err != nil {
+			return err
+		}
+		if errObj, ok := response["error"].(map[string]any); ok {
+			message, _ := errObj["message"].(string)
+			return upstreamRPCError{message: method + ": " + message}
+		}
+		return nil
+	}
+	s.bindThreadToSession(threadID, sess)
+	if !bound {
+		if err := call("thread/resume", map[string]any{"threadId": threadID}); err != nil {
+			return err
+		}
+	}
+	return call("turn/start", s.withThreadSettings(threadID, s.withSystemPreamble(threadID, s.withPinnedNotes(threadID, params))))
+}
+
+func (s *Server) publishRetryEvent(threadID, method string, params map[string]any) {
+	params["threadId"] = threadID
+	encoded, _ := json.Marshal(map[string]any{"method": method, "params": params})
+	s.publishThreadEvent(threadID, string(encoded))
+}
+
+// stopTurnRetries cancels pending retries so none fire during shutdown.
+func (s *Server) stopTurnRetries() {
+	s.threadsMu.Lock()
+	defer s.threadsMu.Unlock()
+	s.retriesStopped = true
+	for threadID, state := range s.turnRetries {
+		if state.timer != nil {
+			state.timer.Stop()
+		}
+		delete(s.turnRetries, threadID)
+	}
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestTransientTurnFailureIsRetried(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	resp, meta := doJSON(t, http.MethodPatch, s.http.URL+"/api/thread/meta", map[string]any{"threadId": threadID, "retry": map[string]any{"maxAttempts": 2, "backoffMs": 10}})
+	if resp.StatusCode != http.StatusOK || meta["retry"] == nil {
+		t.Fatalf("unexpected retry policy update: %d %v", resp.StatusCode, meta)
+	}
+
+	stream := openSSE(t, s.http.URL, threadID, "")
+	defer stream.Body.Close()
+	_ = postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "FAIL_TRANSIENT run the suite"}}})
+
+	retrying := waitForSSEEvent(t, stream, func(event sseEvent) bool {
+		return parseJSON(t, event.Data)["method"] == "darkhold/turn/retrying"
+	}, 10*time.Second)
+	params := parseJSON(t, retrying.Data)["params"].(map[string]any)
+	if params["attempt"] != float64(1) || params["maxAttempts"] != float64(2) || params["reason"] != retryReasonNetwork {
+		t.Fatalf("unexpected retrying event: %v", params)
+	}
+
+	acceptNextApproval(t, s.http.URL, threadID, stream)
+	waitForSSEEvent(t, stream, func(event sseEvent) bool {
+		payload := parseJSON(t, event.Data)
+		if payload["method"] != "turn/completed" {
+			return false
+		}
+		turn, _ := payload["params"].(map[string]any)["turn"].(map[string]any)
+		return turn["status"] == "completed"
+	}, 10*time.Second)
+}
+
+func TestRetryStopsOnceBudgetIsUsedUp(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.GlobalBudget = config.Budget{TurnsPerDay: 1}
+	})
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	doJSON(t, http.MethodPatch, s.http.URL+"/api/thread/meta", map[string]any{"threadId": threadID, "retry": map[string]any{"maxAttempts": 2, "backoffMs": 10}})
+
+	stream := openSSE(t, s.http.URL, threadID, "")
+	defer stream.Body.Close()
+	_ = postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "FAIL_TRANSIENT run the suite"}}})
+
+	stopped := waitForSSEEvent(t, stream, func(event sseEvent) bool {
+		return parseJSON(t, event.Data)["method"] == "darkhold/turn/retryStopped"
+	}, 10*time.Second)
+	params := parseJSON(t, stopped.Data)["params"].(map[string]any)
+	if params["stopReason"] != "permanent" || !strings.Contains(params["error"].(string), "budget") {
+		t.Fatalf("expected the retry to stop on the budget, got %v", params)
+	}
+}
+
+func TestFailedTurnStartKeepsPreviousRetryState(t *testing.T) {
+	s := &Server{turnRetries: map[string]*turnRetryState{}}
+	failed := &turnRetryState{params: map[string]any{"input": "first"}}
+	s.turnRetries["t-1"] = failed
+
+	s.rememberTurnStart("t-1", "alice", map[string]any{"input": "refused"})(false)
+	if s.turnRetries["t-1"] != failed {
+		t.Fatalf("a refused turn/start must put back the previous retry state, got %+v", s.turnRetries["t-1"])
+	}
+	s.rememberTurnStart("t-2", "alice", map[string]any{"input": "refused"})(false)
+	if _, ok := s.turnRetries["t-2"]; ok {
+		t.Fatal("a refused turn/start must not be retried")
+	}
+	s.rememberTurnStart("t-1", "alice", map[string]any{"input": "second"})(true)
+	if state := s.turnRetries["t-1"]; state == failed || state.identity != "alice" || !state.running {
+		t.Fatalf("expected the started turn to replace the retry state, got %+v", state)
+	}
+}
+
+func TestClassifyTurnFailure(t *testing.T) {
+	cases := []struct {
+		name      string
+		method    string
+		params    map[string]any
+		failed    bool
+		transient bool
+		reason    string
+	}{
+		{"completed", "turn/completed", map[string]any{"turn": map[string]any{"status": "completed"}}, false, false, ""},
+		{"stream", "turn/completed", map[string]any{"turn": map[string]any{"status": "failed", "error": map[string]any{"codexErrorInfo": map[string]any{"httpConnectionFailed": map[string]any{"httpStatusCode": 502}}}}}, true, true, retryReasonNetwork},
+		{"context", "turn/completed", map[string]any{"turn": map[string]any{"status": "failed", "error": map[string]any{"message": "timeout", "codexErrorInfo": "contextWindowExceeded"}}}, true, false, retryReasonPermanent},
+		{"rate limit", "turn/failed", map[string]any{"error": map[string]any{"message": "429 Too Many Requests"}}, true, true, retryReasonRateLimit},
+		{"unknown", "turn/failed", map[string]any{"error": map[string]any{"message": "model refused"}}, true, false, retryReasonPermanent},
+	}
+	for _, tc := range cases {
+		failure, failed := classifyTurnFailure(tc.method, tc.params)
+		if failed != tc.failed || failure.Transient != tc.transient || (failed && failure.Reason != tc.reason) {
+			t.Fatalf("%s: got failed=%v %+v", tc.name, failed, failure)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"sort"
+)
+
+const errCodeInvalidInteractionResult = "INVALID_INTERACTION_RESULT"
+
+// Kinds of agent→client requests, by the result they expect.
+const (
+	reverseKindApproval    = "approval"
+	reverseKindUserInput   = "userInput"
+	reverseKindToolCall    = "toolCall"
+	reverseKindElicitation = "elicitation"
+	reverseKindGeneric     = "generic"
+)
+
+// reverseRequestHandler describes one agent→client request method: its kind,
+// published with darkhold/interaction/request, a check of the result a
+// client answers it with and the JSON Schema of that result, returned when a
+// response does not match. aliases rewrites approval decisions into the
+// vocabulary the method understands.
+type reverseRequestHandler struct {
+	kind     string
+	validate func(result any) error
+	schema   map[string]any
+	aliases  map[string]string
+}
+
+var (
+	// v2ApprovalHandler answers item/*/requestApproval.
+	v2ApprovalHandler = reverseRequestHandler{kind: reverseKindApproval, validate: validateApprovalResult, schema: approvalSchema, aliases: map[string]string{
+		"approved": "accept", "approved_for_session": "acceptForSession", "denied": "decline", "abort": "cancel",
+	}}
+	// legacyApprovalHandler answers execCommandApproval and applyPatchApproval.
+	legacyApprovalHandler = reverseRequestHandler{kind: reverseKindApproval, validate: validateApprovalResult, schema: approvalSchema, aliases: map[string]string{
+		"accept": "approved", "acceptForSession": "approved_for_session", "decline": "denied", "cancel": "abort",
+	}}
+)
+
+// reverseRequestHandlers are the agent→client requests darkhold knows the
+// result shape of. Anything else is generic and any JSON result is passed
+// through.
+var reverseRequestHandlers = map[string]reverseRequestHandler{
+	"item/commandExecution/requestApproval": v2ApprovalHandler,
+	"item/fileChange/requestApproval":       v2ApprovalHandler,
+	"execCommandApproval":                   legacyApprovalHandler,
+	"applyPatchApproval":                    legacyApprovalHandler,
+	"item/tool/requestUserInput":            {kind: reverseKindUserInput, validate: validateUserInputResult, schema: userInputSchema},
+	"item/tool/call":                        {kind: reverseKindToolCall, validate: validateToolCallResult, schema: toolCallSchema},
+	"mcpServer/elicitation/request":         {kind: reverseKindElicitation, validate: validateElicitationResult, schema: elicitationSchema},
+}
+
+var genericRequestHandler = reverseRequestHandler{kind: reverseKindGeneric, validate: func(any) error { return nil }}
+
+// check validates a response: rpcErr when one is sent instead of a result.
+func (h reverseRequestHandler) check(result, rpcErr any) error {
+	if rpcErr != nil {
+		return validateInteractionError(rpcErr)
+	}
+	return h.validate(result)
+}
+
+// responseSchema is the schema a response is checked against.
+func (h reverseRequestHandler) responseSchema(rpcErr any) map[string]any {
+	if rpcErr != nil {
+		return interactionErrorSchema
+	}
+	return h.schema
+}
+
+// normalize rewrites an approval decision from the other generation's
+// vocabulary, which the web UI, quick links and the deny-list send for every
+// approval, to the one the agent's method understands. Other results are
+// unchanged.
+func (h reverseRequestHandler) normalize(result any) any {
+	object, ok := result.(map[string]any)
+	if !ok {
+		return result
+	}
+	decision, _ := object["decision"].(string)
+	alias, ok := h.aliases[decision]
+	if !ok {
+		return result
+	}
+	normalized := make(map[string]any, len(object))
+	maps.Copy(normalized, object)
+	normalized["decision"] = alias
+	return normalized
+}
+
+func reverseRequestHandlerFor(method string) reverseRequestHandler {
+	if handler, ok := reverseRequestHandlers[method]; ok {
+		return handler
+	}
+	return genericRequestHandler
+}
+
+// resultShapeError is a result that does not match its request's kind.
+type resultShapeError struct {
+	Field  string
+	Reason string
+}
+
+func (e *resultShapeError) Error() string {
+	return e.Field + ": " + e.Reason
+}
+
+// approvalDecisions are the decisions of the v2 approval requests and of the
+// legacy execCommandApproval/applyPatchApproval ones. Either is accepted for
+// both and normalized before it is forwarded.
+var approvalDecisions = map[string]bool{
+	"accept": true, "acceptForSession": true, "decline": true, "cancel": true,
+	"approved": true, "approved_for_session": true, "denied": true, "abort": true,
+}
+
+// Result schemas (JSON Schema) matching the validators below.
+var (
+	approvalSchema = map[string]any{
+		"type":     "object",
+		"required": []string{"decision"},
+		"properties": map[string]any{
+			"decision": map[string]any{"enum": sortedKeyList(approvalDecisions)},
+		},
+	}
+	userInputSchema = map[string]any{
+		"type":     "object",
+		"required": []string{"answers"},
+		"properties": map[string]any{
+			"answers": map[string]any{
+				"type": "object",
+				"additionalProperties": map[string]any{
+					"type":     "object",
+					"required": []string{"answers"},
+					"properties": map[string]any{
+						"answers": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+					},
+				},
+			},
+		},
+	}
+	toolCallSchema = map[string]any{
+		"type":     "object",
+		"required": []string{"contentItems"},
+		"properties": map[string]any{
+			"contentItems": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type":       "object",
+					"required":   []string{"type"},
+					"properties": map[string]any{"type": map[string]any{"type": "string", "minLength": 1}},
+				},
+			},
+			"success": map[string]any{"type": "boolean"},
+		},
+	}
+	elicitationSchema = map[string]any{
+		"type":     "object",
+		"required": []string{"action"},
+		"properties": map[string]any{
+			"action":  map[string]any{"enum": sortedKeyList(elicitationActions)},
+			"content": map[string]any{"type": []string{"object", "null"}, "description": "only with action accept"},
+		},
+	}
+	interactionErrorSchema = map[string]any{
+		"type":     "object",
+		"required": []string{"message"},
+		"properties": map[string]any{
+			"message": map[string]any{"type": "string", "minLength": 1},
+			"code":    map[string]any{"type": "integer"},
+		},
+	}
+)
+
+func validateApprovalResult(result any) error {
+	object, ok := result.(map[string]any)
+	if !ok {
+		return &resultShapeError{Field: "result", Reason: "must be an object"}
+	}
+	decision, _ := object["decision"].(string)
+	if !approvalDecisions[decision] {
+		return &resultShapeError{Field: "result.decision", Reason: "must be one of " + sortedKeys(approvalDecisions)}
+	}
+	return nil
+}
+
+// validateUserInputResult checks {"answers": {"<questionId>": {"answers": ["..."]}}}.
+func validateUserInputResult(result any) error {
+	object, ok := result.(map[string]any)
+	if !ok {
+		return &resultShapeError{Field: "result", Reason: "must be an object"}
+	}
+	answers, ok := object["answers"].(map[string]any)
+	if !ok {
+		return &resultShapeError{Field: "result.answers", Reason: "must be an object keyed by question id"}
+	}
+	for id, answer := range answers {
+		field := "result.answers." + id + ".answers"
+		entry, _ := answer.(map[string]any)
+		values, ok := entry["answers"].([]any)
+		if !ok {
+			return &resultShapeError{Field: field, Reason: "must be an array of strings"}
+		}
+		for _, value := range values {
+			if _, ok := value.(string); !ok {
+				return &resultShapeError{Field: field, Reason: "must be an array of strings"}
+			}
+		}
+	}
+	return nil
+}
+
+// validateToolCallResult checks {"contentItems": [{"type": ...}], "success": bool}.
+func validateToolCallResult(result any) error {
+	object, ok := result.(map[string]any)
+	if !ok {
+		return &resultShapeError{Field: "result", Reason: "must be an object"}
+	}
+	items, ok := object["contentItems"].([]any)
+	if !ok {
+		return &resultShapeError{Field: "result.contentItems", Reason: "must be an array"}
+	}
+	for i, item := range items {
+		entry, _ := item.(map[string]any)
+		if kind, _ := entry["type"].(string); kind == "" {
+			return &resultShapeError{Field: fmt.Sprintf("result.contentItems[%d].type", i), Reason: "must be a non-empty string"}
+		}
+	}
+	if success, present := object["success"]; present {
+		if _, ok := success.(bool); !ok {
+			return &resultShapeError{Field: "result.success", Reason: "must be a boolean"}
+		}
+	}
+	return nil
+}
+
+var elicitationActions = map[string]bool{"accept": true, "decline": true, "cancel": true}
+
+// validateElicitationResult checks {"action": ..., "content": {...}}; content
+// only goes with accept.
+func validateElicitationResult(result any) error {
+	object, ok := result.(map[string]any)
+	if !ok {
+		return &resultShapeError{Field: "result", Reason: "must be an object"}
+	}
+	action, _ := object["action"].(string)
+	if !elicitationActions[action] {
+		return &resultShapeError{Field: "result.action", Reason: "must be one of " + sortedKeys(elicitationActions)}
+	}
+	content, present := object["content"]
+	if !present || content == nil {
+		return nil
+	}
+	if _, ok := content.(map[string]any); !ok {
+		return &resultShapeError{Field: "result.content", Reason: "must be an object"}
+	}
+	if action != "accept" {
+		return &resultShapeError{Field: "result.content", Reason: "is only allowed with action accept"}
+	}
+	return nil
+}
+
+// validateInteractionError checks a JSON-RPC error sent in place of a result:
+// an object with a message and, optionally, an integer code.
+func validateInteractionError(rpcErr any) error {
+	object, ok := rpcErr.(map[string]any)
+	if !ok {
+		return &resultShapeError{Field: "error", Reason: "must be an object"}
+	}
+	if message, _ := object["message"].(string); message == "" {
+		return &resultShapeError{Field: "error.message", Reason: "must be a non-empty string"}
+	}
+	if code, present := object["code"]; present {
+		if n, ok := code.(float64); !ok || n != float64(int64(n)) {
+			return &resultShapeError{Field: "error.code", Reason: "must be an integer"}
+		}
+	}
+	return nil
+}
+
+func sortedKeys(set map[string]bool) string {
+	return fmt.Sprint(sortedKeyList(set))
+}
+
+func sortedKeyList(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// openAutoResponses keeps the --auto-respond results that fit their method's
+// kind; the rest are logged and ignored.
+func openAutoResponses(configured map[string]any) map[string]any {
+	responses := map[string]any{}
+	for method, result := range configured {
+		if err := reverseRequestHandlerFor(method).validate(result); err != nil {
+			log.Printf("[auto-respond] ignoring result for %s: %v", method, err)
+			continue
+		}
+		responses[method] = result
+	}
+	return responses
+}
+
+// autoRespond answers an interaction with its configured --auto-respond
+// result. Like the policy service, it runs before anything is shown to
+// humans.
+func (s *Server) autoRespond(threadID, requestID, method string, result any) {
+	resolution := map[string]any{"source": "auto-respond", "kind": reverseRequestHandlerFor(method).kind}
+	if err := s.resolveInteraction(threadID, requestID, result, nil, resolution); err != nil {
+		log.Printf("[auto-respond] failed to answer %s request %s on thread %s: %v", method, requestID, threadID, err)
+	}
+}
+
+// pendingInteractionMethod returns the method of an interaction this replica
+// holds.
+func (s *Server) pendingInteractionMethod(threadID, requestID string) (string, bool) {
+	s.sessionsMu.RLock()
+	defer s.sessionsMu.RUnlock()
+	pending, ok := s.pendingResponses[threadID][requestID]
+	return pending.method, ok
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestReverseRequestResultValidation(t *testing.T) {
+	cases := []struct {
+		method string
+		result any
+		field  string
+	}{
+		{"item/commandExecution/requestApproval", map[string]any{"decision": "accept"}, ""},
+		{"execCommandApproval", map[string]any{"decision": "approved_for_session"}, ""},
+		{"item/fileChange/requestApproval", map[string]any{"decision": "maybe"}, "result.decision"},
+		{"item/tool/requestUserInput", map[string]any{"answers": map[string]any{"q1": map[string]any{"answers": []any{"yes"}}}}, ""},
+		{"item/tool/requestUserInput", map[string]any{"answers": map[string]any{"q1": "yes"}}, "result.answers.q1.answers"},
+		{"item/tool/call", map[string]any{"contentItems": []any{map[string]any{"type": "inputText", "text": "ok"}}, "success": true}, ""},
+		{"item/tool/call", map[string]any{"contentItems": []any{map[string]any{}}}, "result.contentItems[0].type"},
+		{"item/tool/call", map[string]any{"contentItems": []any{}, "success": "yes"}, "result.success"},
+		{"mcpServer/elicitation/request", map[string]any{"action": "accept", "content": map[string]any{"name": "x"}}, ""},
+		{"mcpServer/elicitation/request", map[string]any{"action": "decline", "content": map[string]any{"name": "x"}}, "result.content"},
+		{"vendor/anything", []any{1, "two"}, ""},
+	}
+	for _, tc := range cases {
+		err := reverseRequestHandlerFor(tc.method).validate(tc.result)
+		if tc.field == "" {
+			if err != nil {
+				t.Errorf("%s %v: unexpected error %v", tc.method, tc.result, err)
+			}
+			continue
+		}
+		shapeErr, ok := err.(*resultShapeError)
+		if !ok || shapeErr.Field != tc.field {
+			t.Errorf("%s %v: expected an error on %s, got %v", tc.method, tc.result, tc.field, err)
+		}
+	}
+	if validateInteractionError(map[string]any{"message": "no"}) != nil || validateInteractionError(map[string]any{"code": 1.5, "message": "no"}) == nil {
+		t.Fatal("unexpected error validation")
+	}
+}
+
+func TestInteractionRespondValidatesByKind(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	const threadID = "thread-reverse"
+	sess, stdin := injectSession(s.app, 911)
+	defer removeSession(s.app, sess)
+	line, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      12,
+		"method":  "item/tool/requestUserInput",
+		"params":  map[string]any{"threadId": threadID, "questions": []any{map[string]any{"id": "q1", "question": "Continue?"}}},
+	})
+	s.app.handleSessionLine(sess, string(line))
+	events, _ := s.store.Read(threadID)
+	if len(events) != 1 || !strings.Contains(events[0], `"kind":"userInput"`) {
+		t.Fatalf("expected a userInput interaction request, got %v", events)
+	}
+
+	respond := func(body map[string]any) (*http.Response, map[string]any) {
+		body["threadId"], body["requestId"] = threadID, "12"
+		return doJSON(t, http.MethodPost, s.http.URL+"/api/thread/interaction/respond", body)
+	}
+	resp, payload := respond(map[string]any{"result": map[string]any{"decision": "accept"}})
+	details, _ := payload["details"].(map[string]any)
+	schema, _ := details["schema"].(map[string]any)
+	if resp.StatusCode != http.StatusUnprocessableEntity || payload["code"] != errCodeInvalidInteractionResult || details["field"] != "result.answers" || schema["required"] == nil {
+		t.Fatalf("expected an approval result to be rejected with the userInput schema, got %d %v", resp.StatusCode, payload)
+	}
+	resp, payload = respond(map[string]any{"error": map[string]any{"code": "bad"}})
+	details, _ = payload["details"].(map[string]any)
+	schema, _ = details["schema"].(map[string]any)
+	if resp.StatusCode != http.StatusUnprocessableEntity || payload["code"] != errCodeInvalidInteractionResult || schema["properties"].(map[string]any)["message"] == nil {
+		t.Fatalf("expected a malformed error to be rejected with the error schema, got %d %v", resp.StatusCode, payload)
+	}
+	if strings.Contains(stdin.String(), `"id":12`) {
+		t.Fatal("rejected responses must not reach the agent")
+	}
+	resp, payload = respond(map[string]any{"result": map[string]any{"answers": map[string]any{"q1": map[string]any{"answers": []any{"yes"}}}}})
+	if resp.StatusCode != http.StatusOK || payload["kind"] != reverseKindUserInput || !strings.Contains(stdin.String(), `"id":12`) {
+		t.Fatalf("expected the answer to be forwarded, got %d %v (%q)", resp.StatusCode, payload, stdin.String())
+	}
+}
+
+func TestInteractionRespondNormalizesApprovalDecisions(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	const threadID = "thread-decisions"
+	sess, stdin := injectSession(s.app, 913)
+	defer removeSession(s.app, sess)
+	for id, method := range map[int]string{21: "execCommandApproval", 22: "item/commandExecution/requestApproval"} {
+		line, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": map[string]any{"threadId": threadID, "command": []any{"ls"}}})
+		s.app.handleSessionLine(sess, string(line))
+	}
+	respond := func(requestID string, result any) (*http.Response, map[string]any) {
+		return doJSON(t, http.MethodPost, s.http.URL+"/api/thread/interaction/respond", map[string]any{"threadId": threadID, "requestId": requestID, "result": result})
+	}
+	resp, payload := respond("21", map[string]any{"decision": "yes"})
+	details, _ := payload["details"].(map[string]any)
+	if resp.StatusCode != http.StatusUnprocessableEntity || details["kind"] != reverseKindApproval || details["method"] != "execCommandApproval" {
+		t.Fatalf("expected an unknown decision to be rejected, got %d %v", resp.StatusCode, payload)
+	}
+	if resp, payload := respond("21", map[string]any{"decision": "accept"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the legacy approval to be answered, got %d %v", resp.StatusCode, payload)
+	}
+	if resp, payload := respond("22", map[string]any{"decision": "denied"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the v2 approval to be answered, got %d %v", resp.StatusCode, payload)
+	}
+	sent := stdin.String()
+	if !strings.Contains(sent, `{"id":21,"jsonrpc":"2.0","result":{"decision":"approved"}}`) || !strings.Contains(sent, `{"id":22,"jsonrpc":"2.0","result":{"decision":"decline"}}`) {
+		t.Fatalf("expected decisions in each method's vocabulary, got %q", sent)
+	}
+}
+
+func TestAutoRespondAnswersWithoutPublishing(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.AutoRespond = map[string]any{
+			"item/tool/call":             map[string]any{"contentItems": []any{}, "success": false},
+			"item/tool/requestUserInput": map[string]any{"answers": "nope"},
+		}
+	})
+	defer s.close()
+	if _, ok := s.app.autoResponses["item/tool/requestUserInput"]; ok {
+		t.Fatal("an auto-response that does not fit its kind should be ignored")
+	}
+
+	const threadID = "thread-auto"
+	sess, stdin := injectSession(s.app, 912)
+	defer removeSession(s.app, sess)
+	line, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 5, "method": "item/tool/call", "params": map[string]any{"threadId": threadID, "tool": "lookup"}})
+	s.app.handleSessionLine(sess, string(line))
+
+	var joined string
+	waitForCondition(t, 5*time.Second, 10*time.Millisecond, func() bool {
+		events, _ := s.store.Read(threadID)
+		joined = strings.Join(events, "\n")
+		return strings.Contains(stdin.String(), `"success":false`) && strings.Contains(joined, "darkhold/interaction/resolved")
+	})
+	if strings.Contains(joined, "darkhold/interaction/request") || !strings.Contains(joined, `"source":"auto-respond"`) {
+		t.Fatalf("expected only an auto-respond resolution, got %s", joined)
+	}
+}
@@ -0,0 +1,326 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	browserfs "darkhold-go/internal/fs"
+)
+
+const (
+	errCodeReviewNotFound = "REVIEW_NOT_FOUND"
+	errCodeReviewConflict = "REVIEW_CONFLICT"
+)
+
+// Review states of a changed file.
+const (
+	reviewPending  = "pending"
+	reviewAccepted = "accepted"
+	reviewReverted = "reverted"
+)
+
+// reviewFile is one file a turn changed, with where its review stands.
+type reviewFile struct {
+	Path string `json:"path"`
+	Kind string `json:"kind,omitempty"`
+	// MovePath is where an update moved the file to.
+	MovePath string `json:"movePath,omitempty"`
+	Diff     string `json:"diff"`
+	// Revertible is false when the agent recorded no diff darkhold can undo.
+	Revertible bool   `json:"revertible"`
+	State      string `json:"state"`
+	ReviewedBy string `json:"reviewedBy,omitempty"`
+	ReviewedAt int64  `json:"reviewedAt,omitempty"`
+}
+
+type turnReview struct {
+	ThreadID  string       `json:"threadId"`
+	TurnID    string       `json:"turnId"`
+	Completed bool         `json:"completed"`
+	Files     []reviewFile `json:"files"`
+}
+
+// loadTurnReview rebuilds a turn's review from the thread log: file changes
+// come from completed fileChange items, states from darkhold/review/file
+// events. An empty turnID picks the latest turn that changed files.
+func (s *Server) loadTurnReview(threadID, turnID string) (turnReview, bool, error) {
+	records, err := s.eventStore.ReadRange(threadID, "", 0)
+	if err != nil {
+		return turnReview{}, false, err
+	}
+	type turnFiles struct {
+		files     []reviewFile
+		byPath    map[string]int
+		completed bool
+	}
+	turns := map[string]*turnFiles{}
+	latest := ""
+	for _, record := range records {
+		var event struct {
+			Method string `json:"method"`
+			Params struct {
+				TurnID string         `json:"turnId"`
+				Item   map[string]any `json:"item"`
+				Turn   struct {
+					ID string `json:"id"`
+				} `json:"turn"`
+				Path   string `json:"path"`
+				Action string `json:"action"`
+				By     string `json:"by"`
+				At     int64  `json:"at"`
+			} `json:"params"`
+		}
+		if json.Unmarshal([]byte(record.Payload), &event) != nil {
+			continue
+		}
+		id := event.Params.TurnID
+		if id == "" {
+			id = event.Params.Turn.ID
+		}
+		turn := turns[id]
+		switch {
+		case event.Method == "item/completed" && event.Params.Item["type"] == "fileChange" && id != "":
+			if turn == nil {
+				turn = &turnFiles{byPath: map[string]int{}}
+				turns[id] = turn
+			}
+			latest = id
+			changes, _ := event.Params.Item["changes"].([]any)
+			for i, raw := range changes {
+				change, _ := raw.(map[string]any)
+				file := reviewFile{State: reviewPending}
+				normalized := normalizeFileChange(change, i)
+				file.Path, file.Kind, file.Diff = normalized.Path, normalized.Kind, normalized.Diff
+				file.Revertible = revertible(change, file)
+				if kind, ok := change["kind"].(map[string]any); ok {
+					file.MovePath, _ = kind["movePath"].(string)
+					if file.MovePath == "" {
+						file.MovePath, _ = kind["move_path"].(string)
+					}
+				}
+				if index, seen := turn.byPath[file.Path]; seen {
+					turn.files[index] = file
+					continue
+				}
+				turn.byPath[file.Path] = len(turn.files)
+				turn.files = append(turn.files, file)
+			}
+		case event.Method == "turn/completed" && turn != nil:
+			turn.completed = true
+		case event.Method == "darkhold/review/file" && turn != nil:
+			if index, ok := turn.byPath[event.Params.Path]; ok {
+				file := &turn.files[index]
+				file.State, file.ReviewedBy, file.ReviewedAt = reviewState(event.Params.Action), event.Params.By, event.Params.At
+			}
+		}
+	}
+	if turnID == "" {
+		turnID = latest
+	}
+	turn, ok := turns[turnID]
+	if !ok {
+		return turnReview{}, false, nil
+	}
+	return turnReview{ThreadID: threadID, TurnID: turnID, Completed: turn.completed, Files: turn.files}, true, nil
+}
+
+func reviewState(action string) string {
+	if action == "revert" {
+		return reviewReverted
+	}
+	return reviewAccepted
+}
+
+// revertible reports whether a change carries text darkhold can undo it
+// with: the whole file for adds and deletes, a unified diff otherwise.
+func revertible(change map[string]any, file reviewFile) bool {
+	hasText := false
+	for _, key := range []string{"diff", "patch", "unifiedDiff", "unified_diff", "content"} {
+		if _, ok := change[key].(string); ok {
+			hasText = true
+		}
+	}
+	switch {
+	case !hasText:
+		return false
+	case file.Kind == "add" || file.Kind == "delete":
+		return true
+	}
+	_, err := parseUnifiedDiff(file.Diff)
+	return err == nil
+}
+
+// revertFileChange undoes one file change on disk. Paths are taken relative
+// to the thread's cwd and must stay under the browser root.
+func revertFileChange(cwd string, file reviewFile) error {
+	resolve := func(path string) (string, error) {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(cwd, path)
+		}
+		path = filepath.Clean(path)
+		if !browserfs.IsWithinRoot(path) {
+			return "", errors.New("path is outside the base path")
+		}
+		return path, nil
+	}
+	path, err := resolve(file.Path)
+	if err != nil {
+		return err
+	}
+	current := path
+	if file.MovePath != "" {
+		if current, err = resolve(file.MovePath); err != nil {
+			return err
+		}
+	}
+	switch file.Kind {
+	case "add":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return errPatchConflict
+		}
+		if string(data) != file.Diff {
+			return errPatchConflict
+		}
+		return os.Remove(path)
+	case "delete":
+		if _, err := os.Stat(path); err == nil {
+			return errPatchConflict
+		}
+		return os.WriteFile(path, []byte(file.Diff), 0o644)
+	}
+	data, err := os.ReadFile(current)
+	if err != nil {
+		return errPatchConflict
+	}
+	reverted, err := reversePatch(string(data), file.Diff)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(current)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(reverted), info.Mode().Perm()); err != nil {
+		return err
+	}
+	if current != path {
+		return os.Remove(current)
+	}
+	return nil
+}
+
+// handleThreadReview lists a turn's file changes and takes per-file review
+// actions:
+//
+//	GET  ?threadId=[&turnId=]                   {threadId, turnId, completed, files}
+//	POST {threadId, turnId, path, action}       action is accept or revert
+//
+// Reverting undoes the turn's recorded diff for that file, so the agent is
+// not asked to undo its own work. Each action is appended to the thread log
+// as darkhold/review/file.
+func (s *Server) handleThreadReview(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		threadID := strings.TrimSpace(r.URL.Query().Get("threadId"))
+		if threadID == "" {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+			return
+		}
+		review, ok, err := s.loadTurnReview(threadID, strings.TrimSpace(r.URL.Query().Get("turnId")))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
+			return
+		}
+		if !ok {
+			writeError(w, http.StatusNotFound, errCodeReviewNotFound, "no file changes recorded for the turn.")
+			return
+		}
+		writeJSON(w, http.StatusOK, review)
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
+		var request struct {
+			ThreadID string `json:"threadId"`
+			TurnID   string `json:"turnId"`
+			Path     string `json:"path"`
+			Action   string `json:"action"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+			return
+		}
+		request.ThreadID, request.TurnID = strings.TrimSpace(request.ThreadID), strings.TrimSpace(request.TurnID)
+		if request.ThreadID == "" || request.TurnID == "" || request.Path == "" {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId, turnId and path are required.")
+			return
+		}
+		if request.Action != "accept" && request.Action != "revert" {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "action must be accept or revert.")
+			return
+		}
+		s.reviewMu.Lock()
+		defer s.reviewMu.Unlock()
+		review, ok, err := s.loadTurnReview(request.ThreadID, request.TurnID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
+			return
+		}
+		var file *reviewFile
+		for i := range review.Files {
+			if review.Files[i].Path == request.Path {
+				file = &review.Files[i]
+			}
+		}
+		if !ok || file == nil {
+			writeError(w, http.StatusNotFound, errCodeReviewNotFound, "the turn did not change that file.")
+			return
+		}
+		if file.State == reviewReverted {
+			writeErrorDetails(w, http.StatusConflict, errCodeReviewConflict, "the change was already reverted.", map[string]any{"path": file.Path, "state": file.State})
+			return
+		}
+		if request.Action == "revert" {
+			if !file.Revertible {
+				writeErrorDetails(w, http.StatusConflict, errCodeReviewConflict, "the change has no diff to revert.", map[string]any{"path": file.Path, "reason": "noDiff"})
+				return
+			}
+			if _, active := s.threadLiveState(); active[request.ThreadID] {
+				writeErrorDetails(w, http.StatusConflict, errCodeReviewConflict, "a turn is running on the thread.", map[string]any{"path": file.Path, "reason": "activeTurn"})
+				return
+			}
+			meta, _ := s.threadIndex.Get(request.ThreadID)
+			if err := revertFileChange(meta.Cwd, *file); err != nil {
+				if errors.Is(err, errPatchConflict) {
+					writeErrorDetails(w, http.StatusConflict, errCodeReviewConflict, err.Error(), map[string]any{"path": file.Path, "reason": "fileChanged"})
+					return
+				}
+				writeErrorDetails(w, http.StatusBadRequest, errCodeInvalidPath, err.Error(), map[string]any{"path": file.Path})
+				return
+			}
+		}
+		file.State, file.ReviewedBy, file.ReviewedAt = reviewState(request.Action), clientIdentity(r.Context()), time.Now().UnixMilli()
+		notice, _ := json.Marshal(map[string]any{
+			"method": "darkhold/review/file",
+			"params": map[string]any{
+				"threadId": request.ThreadID,
+				"turnId":   request.TurnID,
+				"path":     file.Path,
+				"action":   request.Action,
+				"by":       file.ReviewedBy,
+				"at":       file.ReviewedAt,
+			},
+		})
+		s.publishThreadEvent(request.ThreadID, string(notice))
+		if request.Action == "revert" {
+			s.audit.record(r, "review.revert", map[string]any{"threadId": request.ThreadID, "turnId": request.TurnID, "path": file.Path})
+		}
+		writeJSON(w, http.StatusOK, file)
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
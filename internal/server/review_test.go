@@ -0,0 +1,132 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"darkhold-go/internal/threads"
+)
+
+func TestReversePatchUndoesHunksAndDetectsConflicts(t *testing.T) {
+	diff := "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n@@ -6,2 +6,3 @@\n f\n+inserted\n g\n"
+	after := "a\nB\nc\nd\ne\nf\ninserted\ng\n"
+	got, err := reversePatch(after, diff)
+	if err != nil || got != "a\nb\nc\nd\ne\nf\ng\n" {
+		t.Fatalf("unexpected revert: %q %v", got, err)
+	}
+
+	// Lines added above the hunks since the turn shift them; the revert
+	// still finds them.
+	if got, err := reversePatch("new top\n"+after, diff); err != nil || got != "new top\na\nb\nc\nd\ne\nf\ng\n" {
+		t.Fatalf("unexpected shifted revert: %q %v", got, err)
+	}
+	if _, err := reversePatch("a\nX\nc\n", diff); err != errPatchConflict {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+}
+
+func TestUnifiedDiffRevertsToBefore(t *testing.T) {
+	if got, want := unifiedDiff("a\nb\nc\n", "a\nB\nc\n"), "--- before\n+++ after\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"; got != want {
+		t.Fatalf("unifiedDiff = %q, want %q", got, want)
+	}
+	if got := unifiedDiff("same\n", "same\n"); got != "" {
+		t.Fatalf("expected no diff for equal text, got %q", got)
+	}
+	long := strings.Repeat("line\n", 20)
+	for _, tc := range []struct{ before, after string }{
+		{"", "new file\nwith two lines\n"},
+		{"going away\n", ""},
+		{"1\n2\n3\n" + long + "4\n5\n", "1\nTWO\n3\n" + long + "4\nfive\nsix\n"},
+		{"a\nb\nc\nd\ne\nf\ng\nh\n", "a\nc\nd\ne\nf\ng\nH\n"},
+	} {
+		diff := unifiedDiff(tc.before, tc.after)
+		if !strings.HasPrefix(diff, "--- before\n+++ after\n@@ ") {
+			t.Fatalf("not a unified diff: %q", diff)
+		}
+		if got, err := reversePatch(tc.after, diff); err != nil || got != tc.before {
+			t.Fatalf("reverting %q gave %q %v, want %q", diff, got, err, tc.before)
+		}
+	}
+	if hunks, _ := parseUnifiedDiff(unifiedDiff("1\n2\n3\n"+long+"4\n5\n", "1\nTWO\n3\n"+long+"4\nfive\n")); len(hunks) != 2 {
+		t.Fatalf("expected distant changes in separate hunks, got %d", len(hunks))
+	}
+}
+
+func TestThreadReviewRevertsOneFileAndLogsActions(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	if _, err := s.app.threadIndex.Update("t-review", func(m *threads.Metadata) error {
+		m.ThreadID, m.Cwd = "t-review", s.baseDir
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	kept := filepath.Join(s.baseDir, "kept.go")
+	rejected := filepath.Join(s.baseDir, "rejected.go")
+	added := filepath.Join(s.baseDir, "added.txt")
+	for path, content := range map[string]string{kept: "package a\n\nvar x = 2\n", rejected: "one\nTWO\nthree\n", added: "hello\n"} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	item, _ := json.Marshal(map[string]any{
+		"method": "item/completed",
+		"params": map[string]any{"threadId": "t-review", "turnId": "turn-1", "item": map[string]any{
+			"type": "fileChange",
+			"id":   "fc-1",
+			"changes": []any{
+				map[string]any{"path": kept, "kind": map[string]any{"type": "update"}, "diff": "@@ -3 +3 @@\n-var x = 1\n+var x = 2\n"},
+				map[string]any{"path": "rejected.go", "kind": map[string]any{"type": "update"}, "diff": "@@ -1,3 +1,3 @@\n one\n-two\n+TWO\n three\n"},
+				map[string]any{"path": added, "kind": map[string]any{"type": "add"}, "diff": "hello\n"},
+			},
+		}},
+	})
+	s.app.publishThreadEvent("t-review", string(item))
+	s.app.publishThreadEvent("t-review", `{"method":"turn/completed","params":{"threadId":"t-review","turn":{"id":"turn-1","status":"completed"}}}`)
+
+	resp, review := doJSON(t, http.MethodGet, s.http.URL+"/api/thread/review?threadId=t-review", nil)
+	files, _ := review["files"].([]any)
+	if resp.StatusCode != http.StatusOK || review["turnId"] != "turn-1" || review["completed"] != true || len(files) != 3 {
+		t.Fatalf("unexpected review: %d %v", resp.StatusCode, review)
+	}
+
+	resp, file := doJSON(t, http.MethodPost, s.http.URL+"/api/thread/review", map[string]any{"threadId": "t-review", "turnId": "turn-1", "path": "rejected.go", "action": "revert"})
+	if resp.StatusCode != http.StatusOK || file["state"] != reviewReverted {
+		t.Fatalf("unexpected revert: %d %v", resp.StatusCode, file)
+	}
+	if data, _ := os.ReadFile(rejected); string(data) != "one\ntwo\nthree\n" {
+		t.Fatalf("expected rejected.go to be reverted, got %q", data)
+	}
+	doJSON(t, http.MethodPost, s.http.URL+"/api/thread/review", map[string]any{"threadId": "t-review", "turnId": "turn-1", "path": added, "action": "revert"})
+	if _, err := os.Stat(added); !os.IsNotExist(err) {
+		t.Fatalf("expected the added file to be removed: %v", err)
+	}
+	doJSON(t, http.MethodPost, s.http.URL+"/api/thread/review", map[string]any{"threadId": "t-review", "turnId": "turn-1", "path": kept, "action": "accept"})
+	if data, _ := os.ReadFile(kept); string(data) != "package a\n\nvar x = 2\n" {
+		t.Fatalf("expected kept.go to be untouched, got %q", data)
+	}
+
+	_, review = doJSON(t, http.MethodGet, s.http.URL+"/api/thread/review?threadId=t-review&turnId=turn-1", nil)
+	states := map[string]any{}
+	for _, raw := range review["files"].([]any) {
+		f := raw.(map[string]any)
+		states[filepath.Base(f["path"].(string))] = f["state"]
+	}
+	if states["kept.go"] != reviewAccepted || states["rejected.go"] != reviewReverted || states["added.txt"] != reviewReverted {
+		t.Fatalf("unexpected states: %v", states)
+	}
+
+	resp, again := doJSON(t, http.MethodPost, s.http.URL+"/api/thread/review", map[string]any{"threadId": "t-review", "turnId": "turn-1", "path": "rejected.go", "action": "revert"})
+	if resp.StatusCode != http.StatusConflict || again["code"] != errCodeReviewConflict {
+		t.Fatalf("expected REVIEW_CONFLICT for a second revert: %d %v", resp.StatusCode, again)
+	}
+	events, _ := s.store.Read("t-review")
+	if logged := strings.Count(strings.Join(events, "\n"), "darkhold/review/file"); logged != 3 {
+		t.Fatalf("expected three review events, got %d", logged)
+	}
+}
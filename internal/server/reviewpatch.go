@@ -0,0 +1,279 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// diffHunk is one "@@ -a,b +c,d @@" section of a unified diff.
+type diffHunk struct {
+	newStart, newCount int
+	lines              []string // with their ' ', '-' or '+' prefix
+}
+
+// parseUnifiedDiff reads the hunks of a unified diff. File headers and
+// "\ No newline at end of file" markers are skipped.
+func parseUnifiedDiff(diff string) ([]diffHunk, error) {
+	var hunks []diffHunk
+	for line := range strings.SplitSeq(strings.TrimSuffix(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			hunk, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, err
+			}
+			hunks = append(hunks, hunk)
+		case len(hunks) == 0, strings.HasPrefix(line, `\`):
+		case line == "":
+			// Some generators drop the space of an empty context line.
+			hunks[len(hunks)-1].lines = append(hunks[len(hunks)-1].lines, " ")
+		case line[0] == ' ', line[0] == '-', line[0] == '+':
+			hunks[len(hunks)-1].lines = append(hunks[len(hunks)-1].lines, line)
+		default:
+			return nil, fmt.Errorf("unexpected diff line %q", line)
+		}
+	}
+	if len(hunks) == 0 {
+		return nil, errors.New("diff has no hunks")
+	}
+	return hunks, nil
+}
+
+func parseHunkHeader(line string) (diffHunk, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+		return diffHunk{}, fmt.Errorf("malformed hunk header %q", line)
+	}
+	start, count, ok := strings.Cut(fields[2][1:], ",")
+	hunk := diffHunk{newCount: 1}
+	var err error
+	if hunk.newStart, err = strconv.Atoi(start); err != nil {
+		return diffHunk{}, fmt.Errorf("malformed hunk header %q", line)
+	}
+	if ok {
+		if hunk.newCount, err = strconv.Atoi(count); err != nil {
+			return diffHunk{}, fmt.Errorf("malformed hunk header %q", line)
+		}
+	}
+	return hunk, nil
+}
+
+// errPatchConflict means the file no longer holds what the diff produced.
+var errPatchConflict = errors.New("file has changed since the turn")
+
+// reversePatch undoes diff on content, the file as the diff left it. Each
+// hunk is looked for at its recorded line first and then anywhere after the
+// previous hunk, so edits elsewhere in the file do not block the revert.
+func reversePatch(content, diff string) (string, error) {
+	hunks, err := parseUnifiedDiff(diff)
+	if err != nil {
+		return "", err
+	}
+	trailingNewline := content == "" || strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+	out := make([]string, 0, len(lines))
+	cursor := 0
+	for _, hunk := range hunks {
+		var after, before []string
+		for _, line := range hunk.lines {
+			text := line[1:]
+			switch line[0] {
+			case ' ':
+				after, before = append(after, text), append(before, text)
+			case '+':
+				after = append(after, text)
+			case '-':
+				before = append(before, text)
+			}
+		}
+		at := hunk.newStart - 1
+		if hunk.newCount == 0 {
+			at = hunk.newStart
+		}
+		if !linesMatch(lines, at, after) || at < cursor {
+			at = -1
+			for i := cursor; i+len(after) <= len(lines); i++ {
+				if linesMatch(lines, i, after) {
+					at = i
+					break
+				}
+			}
+			if at < 0 {
+				return "", errPatchConflict
+			}
+		}
+		out = append(out, lines[cursor:at]...)
+		out = append(out, before...)
+		cursor = at + len(after)
+	}
+	out = append(out, lines[cursor:]...)
+	result := strings.Join(out, "\n")
+	if trailingNewline && len(out) > 0 {
+		result += "\n"
+	}
+	return result, nil
+}
+
+func linesMatch(lines []string, at int, want []string) bool {
+	if at < 0 || at+len(want) > len(lines) {
+		return false
+	}
+	for i, line := range want {
+		if lines[at+i] != line {
+			return false
+		}
+	}
+	return true
+}
+
+const (
+	// diffContext is how many unchanged lines unifiedDiff keeps around a
+	// change.
+	diffContext = 3
+	// maxDiffCells bounds unifiedDiff's line-matching table; a larger
+	// change is shown as its differing lines removed and re-added.
+	maxDiffCells = 1 << 20
+)
+
+// diffLine is one line of an edit script, op being ' ', '-' or '+'.
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// unifiedDiff returns the unified diff, headed "--- before" / "+++ after",
+// that turns before into after. It is empty when both hold the same lines.
+func unifiedDiff(before, after string) string {
+	a, b := splitDiffLines(before), splitDiffLines(after)
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	script := make([]diffLine, 0, len(a)+len(b)-prefix-suffix)
+	for _, line := range a[:prefix] {
+		script = append(script, diffLine{' ', line})
+	}
+	script = append(script, editScript(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		script = append(script, diffLine{' ', line})
+	}
+
+	var out strings.Builder
+	for start := 0; start < len(script); {
+		first := start
+		for first < len(script) && script[first].op == ' ' {
+			first++
+		}
+		if first == len(script) {
+			break
+		}
+		// Extend the hunk over changes whose context would touch.
+		last := first
+		for i := first; i < len(script) && i <= last+2*diffContext+1; i++ {
+			if script[i].op != ' ' {
+				last = i
+			}
+		}
+		from, to := max(first-diffContext, start), min(last+1+diffContext, len(script))
+		if out.Len() == 0 {
+			out.WriteString("--- before\n+++ after\n")
+		}
+		oldStart, newStart := 1, 1
+		for _, line := range script[:from] {
+			if line.op != '+' {
+				oldStart++
+			}
+			if line.op != '-' {
+				newStart++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, line := range script[from:to] {
+			if line.op != '+' {
+				oldCount++
+			}
+			if line.op != '-' {
+				newCount++
+			}
+		}
+		// An empty range is numbered by the line before it.
+		if oldCount == 0 {
+			oldStart--
+		}
+		if newCount == 0 {
+			newStart--
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, line := range script[from:to] {
+			out.WriteByte(line.op)
+			out.WriteString(line.text)
+			out.WriteByte('\n')
+		}
+		start = to
+	}
+	return out.String()
+}
+
+func splitDiffLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// editScript turns a into b along a longest common subsequence of lines.
+func editScript(a, b []string) []diffLine {
+	script := make([]diffLine, 0, len(a)+len(b))
+	if len(a)*len(b) > maxDiffCells {
+		for _, line := range a {
+			script = append(script, diffLine{'-', line})
+		}
+		for _, line := range b {
+			script = append(script, diffLine{'+', line})
+		}
+		return script
+	}
+	// common[i*(len(b)+1)+j] is the LCS length of a[i:] and b[j:].
+	width := len(b) + 1
+	common := make([]int32, (len(a)+1)*width)
+	for i, lineA := range slices.Backward(a) {
+		for j, lineB := range slices.Backward(b) {
+			if lineA == lineB {
+				common[i*width+j] = common[(i+1)*width+j+1] + 1
+			} else {
+				common[i*width+j] = max(common[(i+1)*width+j], common[i*width+j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			script = append(script, diffLine{' ', a[i]})
+			i, j = i+1, j+1
+		case common[(i+1)*width+j] >= common[i*width+j+1]:
+			script = append(script, diffLine{'-', a[i]})
+			i++
+		default:
+			script = append(script, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		script = append(script, diffLine{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		script = append(script, diffLine{'+', b[j]})
+	}
+	return script
+}
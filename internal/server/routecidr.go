@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"darkhold-go/internal/config"
+)
+
+// allowClient checks the client IP against the CIDRs for r's route, before
+// authentication, so a refused client never opens an event stream or
+// reaches a handler.
+func (s *Server) allowClient(r *http.Request) bool {
+	ip := net.ParseIP(remoteHost(r))
+	return config.IsAllowedClient(ip, s.routeAllowCIDRs(r))
+}
+
+// routeAllowCIDRs picks the allow-list for r: the longest --route-cidr path
+// prefix it matches, else the rule for the scope an API route needs, else
+// --allow-cidr.
+func (s *Server) routeAllowCIDRs(r *http.Request) []string {
+	if len(s.cfg.RouteCIDRs) == 0 {
+		return s.cfg.AllowCIDRs
+	}
+	path := r.URL.Path
+	best := ""
+	for route := range s.cfg.RouteCIDRs {
+		if strings.HasPrefix(route, "/") && strings.HasPrefix(path, route) && len(route) > len(best) {
+			best = route
+		}
+	}
+	if best != "" {
+		return s.cfg.RouteCIDRs[best]
+	}
+	if strings.HasPrefix(path, "/api/") {
+		if cidrs, ok := s.cfg.RouteCIDRs["scope:"+requiredScope(r)]; ok {
+			return cidrs
+		}
+	}
+	return s.cfg.AllowCIDRs
+}
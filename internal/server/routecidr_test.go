@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"darkhold-go/internal/config"
+)
+
+func TestRouteCIDRs(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.AllowCIDRs = []string{"10.0.0.0/8"}
+		cfg.RouteCIDRs = map[string][]string{
+			"/api/health":   {"0.0.0.0/0"},
+			"/api/rpc":      {"10.1.0.0/16"},
+			"/api/fs/":      {"10.2.0.0/16"},
+			"/api/fs/list":  {"10.3.0.0/16"},
+			"scope:approve": {"10.4.0.0/16"},
+		}
+	})
+	defer s.close()
+	handler := s.app.Handler()
+	status := func(method, path, remote string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = remote + ":40000"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	forbidden := []struct{ method, path, remote string }{
+		{http.MethodPost, "/api/rpc", "10.9.0.1"},
+		{http.MethodGet, "/api/fs/recent", "10.3.0.1"},
+		{http.MethodGet, "/api/fs/list", "10.2.0.1"},
+		{http.MethodPost, "/api/thread/interaction/respond", "10.9.0.1"},
+		{http.MethodGet, "/api/thread/events/stream?threadId=a", "192.168.1.1"},
+		{http.MethodGet, "/", "8.8.8.8"},
+	}
+	for _, c := range forbidden {
+		if got := status(c.method, c.path, c.remote); got != http.StatusForbidden {
+			t.Fatalf("%s %s from %s: expected 403, got %d", c.method, c.path, c.remote, got)
+		}
+	}
+	if got := status(http.MethodGet, "/api/health", "8.8.8.8"); got != http.StatusOK {
+		t.Fatalf("health should be open to everyone, got %d", got)
+	}
+	if got := status(http.MethodGet, "/api/fs/list", "127.0.0.1"); got == http.StatusForbidden {
+		t.Fatal("localhost should always be allowed")
+	}
+	if got := status(http.MethodGet, "/api/fs/list", "10.3.0.1"); got == http.StatusForbidden {
+		t.Fatal("the longest prefix rule should apply")
+	}
+	if got := status(http.MethodGet, "/api/thread/events?threadId=a", "10.9.0.1"); got == http.StatusForbidden {
+		t.Fatal("routes without a rule should fall back to --allow-cidr")
+	}
+	if got := s.app.sseStreams.stats().Total; got != 0 {
+		t.Fatalf("refused clients should not hold streams, got %d", got)
+	}
+}
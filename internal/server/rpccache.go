@@ -0,0 +1,155 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// rpcCacheLifecycleMethods are the notifications that can change a thread's
+// entry in thread/list: its existence, title, status or latest turn. Item
+// events and deltas only touch the thread's own entries.
+var rpcCacheLifecycleMethods = map[string]bool{
+	"thread/started":        true,
+	"thread/archived":       true,
+	"thread/unarchived":     true,
+	"thread/name/updated":   true,
+	"thread/status/changed": true,
+	"turn/started":          true,
+	"turn/completed":        true,
+	"turn/failed":           true,
+	"turn/aborted":          true,
+}
+
+// rpcCacheLifecycleRPCs are the calls that change thread/list; any other
+// uncached call only drops the entries of the thread it names.
+var rpcCacheLifecycleRPCs = map[string]bool{
+	"thread/start":     true,
+	"thread/archive":   true,
+	"thread/unarchive": true,
+	"thread/name/set":  true,
+	"turn/start":       true,
+}
+
+// cacheableRPCMethods are read-only passthrough calls whose results can be
+// reused briefly, mostly to absorb UI polling.
+var cacheableRPCMethods = map[string]bool{
+	"thread/read": true,
+	"thread/list": true,
+}
+
+// rpcCache keeps short-lived results of read-only RPCs keyed by method and
+// params. Each scope (a thread, or "" for thread-independent entries) has a
+// generation that every invalidation of it bumps, so in-flight reads that
+// started before a mutation are never stored.
+type rpcCache struct {
+	ttl time.Duration
+
+	mu          sync.Mutex
+	generations map[string]uint64
+	entries     map[string]rpcCacheEntry
+	hits        int64
+	misses      int64
+}
+
+type rpcCacheEntry struct {
+	threadID string
+	encoded  []byte
+	expires  time.Time
+}
+
+func newRPCCache(ttl time.Duration) *rpcCache {
+	return &rpcCache{ttl: ttl, generations: map[string]uint64{}, entries: map[string]rpcCacheEntry{}}
+}
+
+func (c *rpcCache) enabled(method string) bool {
+	return c.ttl > 0 && cacheableRPCMethods[method]
+}
+
+func rpcCacheKey(method string, params any) string {
+	// encoding/json sorts map keys, so equal params produce equal keys.
+	encoded, _ := json.Marshal(params)
+	return method + "\x00" + string(encoded)
+}
+
+// get returns a decoded copy of a live entry and the generation of threadID's
+// scope to pass to put on a miss.
+func (c *rpcCache) get(key, threadID string) (map[string]any, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		c.misses++
+		return nil, c.generations[threadID], false
+	}
+	var response map[string]any
+	if err := json.Unmarshal(entry.encoded, &response); err != nil {
+		delete(c.entries, key)
+		c.misses++
+		return nil, c.generations[threadID], false
+	}
+	c.hits++
+	return response, c.generations[threadID], true
+}
+
+func (c *rpcCache) put(key, threadID string, generation uint64, response map[string]any) {
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generations[threadID] {
+		return
+	}
+	c.entries[key] = rpcCacheEntry{threadID: threadID, encoded: encoded, expires: time.Now().Add(c.ttl)}
+}
+
+// invalidateThread drops entries for threadID. A lifecycle change also drops
+// every thread-independent entry (such as thread/list), which includes the
+// thread's summary; per-item traffic leaves those alone so the list stays
+// cached while turns run.
+func (c *rpcCache) invalidateThread(threadID string, lifecycle bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generations[threadID]++
+	if lifecycle {
+		c.generations[""]++
+	}
+	for key, entry := range c.entries {
+		if entry.threadID == threadID || (lifecycle && entry.threadID == "") {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// invalidateThreadEvent invalidates threadID for an event payload published
+// on it, treating it as a lifecycle change when its method is one.
+func (s *Server) invalidateThreadEvent(threadID, payload string) {
+	var event struct {
+		Method string `json:"method"`
+	}
+	_ = json.Unmarshal([]byte(payload), &event)
+	s.rpcCache.invalidateThread(threadID, rpcCacheLifecycleMethods[event.Method])
+}
+
+func (c *rpcCache) stats() map[string]any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return map[string]any{
+		"ttlMs":   c.ttl.Milliseconds(),
+		"entries": len(c.entries),
+		"hits":    c.hits,
+		"misses":  c.misses,
+	}
+}
+
+func (s *Server) handleAdminCache(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.rpcCache.stats())
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestRPCCacheGenerationRejectsStaleReads(t *testing.T) {
+	cache := newRPCCache(time.Minute)
+	key := rpcCacheKey("thread/read", map[string]any{"threadId": "t1"})
+	_, generation, ok := cache.get(key, "t1")
+	if ok {
+		t.Fatal("unexpected hit on empty cache")
+	}
+	cache.invalidateThread("t1", false)
+	cache.put(key, "t1", generation, map[string]any{"result": "stale"})
+	if _, _, ok := cache.get(key, "t1"); ok {
+		t.Fatal("a read that raced with a mutation must not be cached")
+	}
+
+	_, generation, _ = cache.get(key, "t1")
+	cache.put(key, "t1", generation, map[string]any{"result": "fresh"})
+	listKey := rpcCacheKey("thread/list", map[string]any{})
+	_, generation, _ = cache.get(listKey, "")
+	cache.put(listKey, "", generation, map[string]any{"result": "list"})
+	cache.invalidateThread("t2", true)
+	if _, _, ok := cache.get(key, "t1"); !ok {
+		t.Fatal("mutations on other threads should keep t1 entries")
+	}
+	if _, _, ok := cache.get(listKey, ""); ok {
+		t.Fatal("thread/list should be invalidated by a lifecycle change on any thread")
+	}
+}
+
+func TestRPCCacheKeepsThreadListThroughItemEvents(t *testing.T) {
+	cache := newRPCCache(time.Minute)
+	listKey := rpcCacheKey("thread/list", map[string]any{})
+	_, generation, _ := cache.get(listKey, "")
+	// A delta arriving while thread/list is in flight must not discard it.
+	cache.invalidateThread("t1", rpcCacheLifecycleMethods["item/agentMessage/delta"])
+	cache.put(listKey, "", generation, map[string]any{"result": "list"})
+	if _, _, ok := cache.get(listKey, ""); !ok {
+		t.Fatal("item events should leave thread/list cached")
+	}
+	cache.invalidateThread("t1", rpcCacheLifecycleMethods["turn/completed"])
+	if _, _, ok := cache.get(listKey, ""); ok {
+		t.Fatal("turn/completed should drop thread/list")
+	}
+}
+
+func TestThreadListStaysCachedThroughNonLifecycleWrites(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) { cfg.RPCCacheTTL = time.Minute })
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	_ = postRPC[map[string]any](t, s.http.URL, "thread/list", map[string]any{})
+	_ = postRPC[map[string]any](t, s.http.URL, "model/list", map[string]any{})
+	_ = postRPC[map[string]any](t, s.http.URL, "config/read", map[string]any{})
+	_ = postRPC[map[string]any](t, s.http.URL, "thread/list", map[string]any{})
+	if _, stats := doJSON(t, http.MethodGet, s.http.URL+"/api/admin/cache", nil); stats["hits"] != float64(1) {
+		t.Fatalf("expected thread/list to survive model/list and config/read: %v", stats)
+	}
+
+	_ = postRPC[map[string]any](t, s.http.URL, "thread/name/set", map[string]any{"threadId": threadID, "name": "renamed"})
+	_ = postRPC[map[string]any](t, s.http.URL, "thread/list", map[string]any{})
+	if _, stats := doJSON(t, http.MethodGet, s.http.URL+"/api/admin/cache", nil); stats["hits"] != float64(1) {
+		t.Fatalf("expected thread/name/set to drop thread/list: %v", stats)
+	}
+}
+
+func TestThreadReadServedFromCacheUntilThreadChanges(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) { cfg.RPCCacheTTL = time.Minute })
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	read := map[string]any{"threadId": threadID}
+	_ = postRPC[map[string]any](t, s.http.URL, "thread/read", read)
+	_ = postRPC[map[string]any](t, s.http.URL, "thread/read", read)
+
+	_, stats := doJSON(t, http.MethodGet, s.http.URL+"/api/admin/cache", nil)
+	if stats["hits"] != float64(1) || stats["entries"] != float64(1) {
+		t.Fatalf("expected second read to hit the cache: %v", stats)
+	}
+
+	stream := openSSE(t, s.http.URL, threadID, "")
+	defer stream.Body.Close()
+	_ = postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "go"}}})
+	acceptNextApproval(t, s.http.URL, threadID, stream)
+	waitForSSEEvent(t, stream, func(event sseEvent) bool {
+		return parseJSON(t, event.Data)["method"] == "turn/completed"
+	}, 10*time.Second)
+
+	after := postRPC[map[string]any](t, s.http.URL, "thread/read", read)
+	turns, _ := after["thread"].(map[string]any)["turns"].([]any)
+	if len(turns) != 1 {
+		t.Fatalf("expected fresh thread/read after the turn, got %v", after)
+	}
+}
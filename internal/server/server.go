@@ -10,11 +10,10 @@ import (
 	"io"
 	"io/fs"
 	"log"
+	"maps"
 	"mime"
-	"net"
 	"net/http"
 	"os"
-	"os/exec"
 	"path"
 	"strconv"
 	"strings"
@@ -25,6 +24,14 @@ import (
 	"darkhold-go/internal/config"
 	"darkhold-go/internal/events"
 	browserfs "darkhold-go/internal/fs"
+	"darkhold-go/internal/indexdb"
+	"darkhold-go/internal/mqtt"
+	"darkhold-go/internal/places"
+	"darkhold-go/internal/receipts"
+	"darkhold-go/internal/snapshots"
+	"darkhold-go/internal/threads"
+	"darkhold-go/internal/usage"
+	"darkhold-go/internal/webhooks"
 	sse "github.com/tmaxmax/go-sse"
 )
 
@@ -39,10 +46,13 @@ var embeddedWebRoot = func() fs.FS {
 	return root
 }()
 
+// eventSchemaHeader carries events.SchemaVersion on thread event streams.
+const eventSchemaHeader = "X-Darkhold-Event-Schema"
+
 type session struct {
 	id int
 
-	cmd   *exec.Cmd
+	proc  agentProcess
 	stdin io.WriteCloser
 
 	initOnce      sync.Once
@@ -51,13 +61,56 @@ type session struct {
 
 	writeMu sync.Mutex // guards stdin writes only; never hold mu during IO
 
+	startedAt time.Time
+	stderr    *lineRing
+	// recorder is the session's transcript under --record-sessions, or nil.
+	recorder *sessionRecorder
+
 	mu             sync.Mutex
 	pending        map[int64]chan map[string]any
 	knownThreadIDs map[string]struct{}
-	activeTurnIDs  map[string]struct{}
+	activeTurnIDs  map[string]string // turn ID -> thread ID
+	rpcLatency     time.Duration     // moving average RPC round trip
+	peakPending    int               // most RPCs outstanding at once
+	rejectedRPCs   int64             // calls refused by --max-session-rpcs
+	routed         int64             // unbound calls routed here
 	lastActivityAt time.Time
 	closed         bool
 	stopRequested  bool
+	exitedAt       time.Time
+	exitCode       int
+
+	// unhealthy is set when the session stopped answering health pings; see
+	// sessionhealth.go.
+	unhealthy     bool
+	pinging       bool
+	pingRequestID int64
+	pingMisses    int
+	lastPingAt    time.Time
+
+	// keepalive* track --session-keepalive-interval; see sessionkeepalive.go.
+	keepaliveRequestID int64
+	keepaliveSending   bool
+	keepalives         int64
+	lastKeepaliveAt    time.Time
+
+	// usage is the latest --agent-sample-interval sample, usageCPU and
+	// usageAt its raw CPU time and time; cpuOverSince is when CPU use went
+	// over --max-agent-cpu, and killedFor the limit the agent was killed
+	// for. See agentusage.go.
+	usage        *agentUsage
+	usageCPU     time.Duration
+	usageAt      time.Time
+	cpuOverSince time.Time
+	killedFor    string
+
+	// state is the lifecycle state last published; see sessionevents.go.
+	state string
+
+	// abandoned holds calls whose caller gave up before the response came,
+	// abandonedRPCs how many there have been; see abandonedrpc.go.
+	abandoned     map[int64]abandonedRPC
+	abandonedRPCs int64
 }
 
 type pendingInteraction struct {
@@ -65,30 +118,66 @@ type pendingInteraction struct {
 	requestID int64
 	method    string
 	params    any
+	// fingerprint matches re-sent copies of the request; duplicates are
+	// copies still waiting in a live session (see interactiondedup.go).
+	fingerprint string
+	duplicates  []upstreamRequest
+	// receivedAt is when the request arrived, for --alert-approval-pending.
+	receivedAt time.Time
 }
 
-type threadSummary struct {
-	ID        string `json:"id"`
-	Cwd       string `json:"cwd"`
-	UpdatedAt int64  `json:"updatedAt"`
+// eventStamp is the darkhold-assigned identity of a stored event.
+type eventStamp struct {
+	ID   string `json:"id"`
+	Seq  int64  `json:"seq"`
+	Time int64  `json:"ts"`
 }
 
 type Server struct {
 	cfg config.Config
 
-	eventStore *events.Store
-	shutdownMu sync.Once
-	reaperStop chan struct{}
-
-	sessionsMu       sync.RWMutex
-	sessions         map[int]*session
-	threadToSession  map[string]int
+	eventStore events.Storage
+	// cluster is set when eventStore is shared with other replicas.
+	cluster     clusterStore
+	clusterStop context.CancelFunc
+	shutdownMu  sync.Once
+	reaperStop  chan struct{}
+	// shutdownRequests carries /api/admin/shutdown and restart to the
+	// process owner (see shutdown.go).
+	shutdownRequests chan ShutdownRequest
+
+	sessionsMu      sync.RWMutex
+	sessions        map[int]*session
+	exitedSessions  []*session
+	threadToSession map[string]int
+	// threadEpochs counts how often each thread changed sessions; see
+	// fencing.go.
+	threadEpochs     map[string]uint64
 	nextSessionID    int
 	pendingResponses map[string]map[string]pendingInteraction
-	threadsMu        sync.RWMutex
-	knownThreads     map[string]threadSummary
+	// interactionFingerprints maps threadId -> fingerprint -> requestId of
+	// unresolved interactions, guarded by sessionsMu.
+	interactionFingerprints map[string]map[string]string
+	threadsMu               sync.RWMutex
+	// indexDB, when set, persists threadIndex and usage.
+	indexDB        *indexdb.DB
+	threadIndex    *threads.Index
+	usage          *usage.Tracker
+	firstPrompts   map[string]string
+	turnRetries    map[string]*turnRetryState
+	turnOwners     map[string]string
+	turnBudgets    map[string]*usage.Reservation
+	retriesStopped bool
+
+	broadcastsMu     sync.Mutex
+	broadcasts       map[string]*broadcast
+	broadcastOrder   []string
+	threadBroadcasts map[string]string
 
 	sseProvider sse.Provider
+	// fanout is set when SSE events are shared with other instances over
+	// Redis; sseProvider is then the fanout provider.
+	fanout *fanoutProvider
 
 	publishMu sync.Mutex
 
@@ -98,6 +187,103 @@ type Server struct {
 	rpcTimeout          time.Duration
 
 	maxRequestBodySize int64
+
+	policyClient *http.Client
+	// autoResponses are the --auto-respond results by request method.
+	autoResponses map[string]any
+	rpcCache      *rpcCache
+	webhooks      *webhooks.Manager
+	mqtt          *mqtt.Publisher
+
+	// sessionEvents is the darkhold/session/* history (see
+	// sessionevents.go).
+	sessionEvents *sessionEvents
+
+	sessionBridgesMu sync.Mutex
+	sessionBridges   map[string]map[*sessionBridge]struct{}
+
+	archiver   *archiver
+	quickLinks *quickLinks
+	shareLinks *shareLinks
+	// oidc is nil unless --oidc-issuer is set (see oidcauth.go).
+	oidc *oidcAuth
+
+	storageMu      sync.Mutex // held for a whole eviction pass
+	evictedThreads int64
+	evictedBytes   int64
+
+	autoArchive autoArchiveState
+
+	mcpServers mcpServerCache
+
+	drafts draftStore
+
+	// replays are the /api/thread/replay runs (see replay.go).
+	replays threadReplays
+
+	quarantine quarantine
+
+	readReceipts *receipts.Store
+	unread       unreadCache
+
+	places *places.Store
+
+	cwdWatchMu sync.Mutex
+	cwdWatches map[string]*cwdWatch // thread ID -> watcher for the running turn
+
+	reviewMu sync.Mutex // serializes review actions and rollbacks
+
+	// snapshots is nil unless --turn-snapshots is on.
+	snapshots        *snapshots.Manager
+	snapshotsMu      sync.Mutex
+	pendingSnapshots map[string]*snapshots.Snapshot // threadId -> snapshot awaiting its turn id
+
+	coldMu        sync.Mutex
+	coldBackfills map[string]*coldBackfill
+
+	sseStreams *sseAccounting
+
+	// agentPIDs tracks spawned agent processes so orphans left by a crash
+	// are cleaned up on the next start.
+	agentPIDs *agentPIDs
+	// alertTurns and alertedInteractions track what --alert-turn-duration
+	// and --alert-approval-pending watch (see alerts.go), guarded by
+	// alertsMu; alertsMu is taken before sessionsMu.
+	alertsMu            sync.Mutex
+	alertTurns          map[string]*alertTurn
+	alertedInteractions map[alertInteractionKey]bool
+	// limitedTurns are the running turns --max-turn-duration and
+	// --max-turn-cpu watch (see turnlimits.go), guarded by turnLimitsMu;
+	// turnLimitsMu is taken before sessionsMu.
+	turnLimitsMu sync.Mutex
+	limitedTurns map[string]*limitedTurn
+
+	// turnMessages assembles each thread's latest agent message for
+	// /api/thread/turn/message (see turnmessages.go).
+	turnMessages turnMessages
+
+	// stats backs /api/stats/overview (see stats.go).
+	stats *serverStats
+	// agentKills counts agents killed for their resource use (see
+	// agentusage.go).
+	agentKills agentResourceKills
+	// supervisor counts recovered panics and restarts (see supervisor.go).
+	supervisor supervisorStats
+	// transcriptRedactor scrubs session transcripts (see sessionrecord.go).
+	transcriptRedactor *transcriptRedactor
+
+	audit *auditLog
+
+	// eventSchema normalizes agent messages to events.SchemaVersion before
+	// they are stored or forwarded.
+	eventSchema *events.Translator
+
+	routingMu sync.Mutex
+	routing   []routingDecision
+
+	threadLocksMu  sync.Mutex
+	threadLocks    map[string]*threadLock
+	threadLockWait time.Duration
 }
 
 type channelMessageWriter struct {
@@ -117,27 +303,99 @@ func (w *channelMessageWriter) Flush() error {
 	return nil
 }
 
-func New(cfg config.Config, eventStore *events.Store) *Server {
+func New(cfg config.Config, eventStore events.Storage) *Server {
 	replayer, err := sse.NewValidReplayer(24*time.Hour, false)
 	if err != nil {
 		panic(err)
 	}
 	provider := &sse.Joe{Replayer: replayer}
+	indexDB := openIndexDB(cfg.DataDir)
 	s := &Server{
-		cfg:                 cfg,
-		eventStore:          eventStore,
-		reaperStop:          make(chan struct{}),
-		sessions:            map[int]*session{},
-		threadToSession:     map[string]int{},
-		pendingResponses:    map[string]map[string]pendingInteraction{},
-		knownThreads:        map[string]threadSummary{},
-		sseProvider:         provider,
-		sessionIdleTTL:      5 * time.Minute,
-		sessionReapInterval: 5 * time.Second,
-		rpcTimeout:          60 * time.Second,
-		maxRequestBodySize:  10 << 20, // 10 MB
-	}
-	go s.sessionIdleReaper()
+		cfg:                     cfg,
+		eventStore:              eventStore,
+		indexDB:                 indexDB,
+		threadIndex:             openThreadIndex(indexDB),
+		usage:                   openUsageTracker(indexDB),
+		reaperStop:              make(chan struct{}),
+		shutdownRequests:        make(chan ShutdownRequest, 1),
+		sessions:                map[int]*session{},
+		threadToSession:         map[string]int{},
+		threadEpochs:            map[string]uint64{},
+		pendingResponses:        map[string]map[string]pendingInteraction{},
+		interactionFingerprints: map[string]map[string]string{},
+		firstPrompts:            map[string]string{},
+		turnRetries:             map[string]*turnRetryState{},
+		turnOwners:              map[string]string{},
+		turnBudgets:             map[string]*usage.Reservation{},
+		broadcasts:              map[string]*broadcast{},
+		threadBroadcasts:        map[string]string{},
+		sessionBridges:          map[string]map[*sessionBridge]struct{}{},
+		coldBackfills:           map[string]*coldBackfill{},
+		threadLocks:             map[string]*threadLock{},
+		pendingSnapshots:        map[string]*snapshots.Snapshot{},
+		threadLockWait:          threadLockWait,
+		sseStreams:              newSSEAccounting(cfg.MaxSSEPerIP, cfg.MaxSSETotal),
+		sseProvider:             provider,
+		sessionIdleTTL:          5 * time.Minute,
+		sessionReapInterval:     5 * time.Second,
+		rpcTimeout:              60 * time.Second,
+		maxRequestBodySize:      10 << 20, // 10 MB
+		policyClient:            &http.Client{Timeout: cfg.PolicyTimeout},
+		autoResponses:           openAutoResponses(cfg.AutoRespond),
+		rpcCache:                newRPCCache(cfg.RPCCacheTTL),
+		webhooks:                openWebhooks(cfg.DataDir),
+		mqtt:                    openMQTT(cfg),
+		archiver:                openArchiver(cfg),
+		quickLinks:              openQuickLinks(cfg.DataDir, cfg.QuickLinkTTL),
+		shareLinks:              openShareLinks(cfg.DataDir, cfg.ShareLinkTTL),
+		oidc:                    openOIDC(cfg),
+		agentPIDs:               openAgentPIDs(cfg.DataDir),
+		transcriptRedactor:      configuredSecretsRedactor(cfg),
+		stats:                   newServerStats(),
+		audit:                   openAuditLog(cfg.DataDir),
+		sessionEvents:           openSessionEvents(cfg.DataDir),
+		readReceipts:            openReadReceipts(cfg.DataDir),
+		places:                  openPlaces(cfg.DataDir),
+		snapshots:               openTurnSnapshots(cfg),
+		eventSchema:             events.NewTranslator(events.DefaultShims),
+	}
+	// Session IDs carry on from the persisted history, so its events stay
+	// unambiguous across restarts.
+	s.nextSessionID = s.sessionEvents.lastSessionID()
+	if fanout := openFanout(cfg, provider, s.invalidateRemoteTopics); fanout != nil {
+		s.fanout = fanout
+		s.sseProvider = fanout
+	}
+	if cluster, ok := eventStore.(clusterStore); ok {
+		s.threadIndex = openClusterThreadIndex(cluster)
+		s.startCluster(cluster)
+	}
+	go s.agentPIDs.recoverOrphans()
+	go s.supervise("reaper", s.sessionIdleReaper)
+	if cfg.AlertTurnDuration > 0 || cfg.AlertApprovalPending > 0 {
+		go s.supervise("alerts", s.alertWatcher)
+	}
+	if cfg.MaxTurnDuration > 0 || cfg.MaxTurnCPU > 0 {
+		go s.supervise("turn-limits", s.turnLimitWatcher)
+	}
+	if cfg.SessionPingInterval > 0 {
+		go s.supervise("session-health", s.sessionHealthMonitor)
+	}
+	if cfg.AgentSampleInterval > 0 {
+		go s.supervise("agent-usage", s.agentUsageSampler)
+	}
+	if cfg.MaxEventStoreBytes > 0 {
+		go s.supervise("storage-guard", s.storageGuard)
+	}
+	if cfg.AutoArchiveAfter > 0 {
+		go s.supervise("auto-archive", s.autoArchiver)
+	}
+	if s.archiver != nil && cfg.ArchiveInterval > 0 {
+		go s.supervise("archive", s.archiveScheduler)
+	}
+	if cfg.ImportCodexHistory {
+		go s.importCodexHistoryOnce()
+	}
 	return s
 }
 
@@ -145,49 +403,128 @@ func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/health", s.handleHealth)
 	mux.HandleFunc("/api/fs/list", s.handleFSList)
+	mux.HandleFunc("/api/fs/thumbnail", s.handleFSThumbnail)
+	mux.HandleFunc("/api/fs/recent", s.handleFSRecent)
+	mux.HandleFunc("/api/fs/favorites", s.handleFSFavorites)
+	mux.HandleFunc("/api/fs/complete", s.handleFSComplete)
 	mux.HandleFunc("/api/thread/events", s.handleThreadEvents)
 	mux.HandleFunc("/api/thread/events/stream", s.handleThreadEventsStream)
+	mux.HandleFunc("/api/thread/events.ndjson", s.handleThreadEventsNDJSON)
+	mux.HandleFunc("/api/thread/events/poll", s.handleThreadEventsPoll)
+	mux.HandleFunc("/api/events/schema", s.handleEventSchema)
 	mux.HandleFunc("/api/rpc", s.handleRPC)
+	mux.HandleFunc("/api/session/ws", s.handleSessionWS)
+	mux.HandleFunc("/api/sessions/events", s.handleSessionEvents)
+	mux.HandleFunc("/api/sessions/events/stream", s.handleSessionEventsStream)
+	mux.HandleFunc("/api/terminal/ws", s.handleTerminalWS)
+	mux.HandleFunc("/api/exec", s.handleExec)
+	mux.HandleFunc("/api/mcp/servers", s.handleMCPServers)
+	mux.HandleFunc("/api/mcp/servers/test", s.handleMCPServerTest)
+	mux.HandleFunc("/api/interactions/pending", s.handlePendingInteractions)
 	mux.HandleFunc("/api/thread/interaction/respond", s.handleInteractionRespond)
+	mux.HandleFunc("/api/thread/interaction/quick-links", s.handleQuickLinks)
+	mux.HandleFunc(quickLinkPath, s.handleQuickInteraction)
+	mux.HandleFunc("/api/thread/shares", s.handleThreadShares)
+	mux.HandleFunc("/api/turn/estimate", s.handleTurnEstimate)
+	mux.HandleFunc("/api/thread/notes", s.handleThreadNotes)
+	mux.HandleFunc("/api/thread/meta", s.handleThreadMeta)
+	mux.HandleFunc("/api/thread/settings", s.handleThreadSettings)
+	mux.HandleFunc("/api/thread/chain", s.handleThreadChain)
+	mux.HandleFunc("/api/thread/draft", s.handleThreadDraft)
+	mux.HandleFunc("/api/thread/read", s.handleThreadRead)
+	mux.HandleFunc("/api/thread/export", s.handleThreadExport)
+	mux.HandleFunc("/api/thread/review", s.handleThreadReview)
+	mux.HandleFunc("/api/thread/turn/snapshots", s.handleTurnSnapshots)
+	mux.HandleFunc("/api/thread/turn/rollback", s.handleTurnRollback)
+	mux.HandleFunc("/api/thread/turn/message", s.handleTurnMessage)
+	mux.HandleFunc("/api/threads", s.handleThreads)
+	mux.HandleFunc("/api/thread/compare", s.handleThreadCompare)
+	mux.HandleFunc("/api/thread/replay", s.handleThreadReplay)
+	mux.HandleFunc("/api/workspaces", s.handleWorkspaces)
+	mux.HandleFunc("/api/usage", s.handleUsage)
+	mux.HandleFunc("/api/stats/overview", s.handleStatsOverview)
+	mux.HandleFunc("/api/metrics", s.handleMetrics)
+	mux.HandleFunc("/api/render", s.handleRender)
+	mux.HandleFunc("/api/webhooks", s.handleWebhooks)
+	mux.HandleFunc("/api/archive", s.handleArchive)
+	mux.HandleFunc("/api/archive/upload", s.handleArchiveUpload)
+	mux.HandleFunc("/api/archive/restore", s.handleArchiveRestore)
+	mux.HandleFunc("/api/webhooks/deliveries", s.handleWebhookDeliveries)
+	mux.HandleFunc("/api/broadcast", s.handleBroadcast)
+	mux.HandleFunc("/api/broadcast/stream", s.handleBroadcastStream)
+	mux.HandleFunc("/api/broadcast/turn/start", s.handleBroadcastTurnStart)
+	mux.HandleFunc(oidcLoginPath, s.handleOIDCLogin)
+	mux.HandleFunc(oidcCallbackPath, s.handleOIDCCallback)
+	mux.HandleFunc("/api/auth/logout", s.handleAuthLogout)
+	mux.HandleFunc("/api/auth/me", s.handleAuthMe)
+	mux.HandleFunc("/api/admin/cache", s.handleAdminCache)
+	mux.HandleFunc("/api/admin/sessions", s.handleAdminSessions)
+	mux.HandleFunc("/api/admin/sse", s.handleAdminSSE)
+	mux.HandleFunc("/api/admin/orphans", s.handleAdminOrphans)
+	mux.HandleFunc("/api/admin/quarantine", s.handleAdminQuarantine)
+	mux.HandleFunc("/api/admin/auto-archive", s.handleAdminAutoArchive)
+	mux.HandleFunc("/api/admin/fsck", s.handleAdminFsck)
+	mux.HandleFunc("/api/admin/event-chain", s.handleAdminEventChain)
+	mux.HandleFunc("/api/admin/shutdown", s.handleAdminShutdown)
+	mux.HandleFunc("/api/admin/restart", s.handleAdminRestart)
+	mux.HandleFunc("/api/admin/sessions/{id}/stderr", s.handleAdminSessionStderr)
+	mux.HandleFunc("/api/admin/sessions/{id}/stderr/stream", s.handleAdminSessionStderrStream)
+	mux.HandleFunc("/api/admin/transcripts", s.handleAdminTranscripts)
+	mux.HandleFunc("/api/admin/transcripts/{name}", s.handleAdminTranscript)
+	mux.HandleFunc(embedThreadPathPrefix+"{id}", s.handleEmbedThread)
 	mux.HandleFunc("/", s.handleWeb)
 
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.negotiateLocale(w, r)
 		if !s.allowClient(r) {
-			writeJSON(w, http.StatusForbidden, map[string]any{"error": "Forbidden for client IP."})
+			writeError(w, http.StatusForbidden, errCodeForbidden, "Forbidden for client IP.")
+			return
+		}
+		r, ok := s.authenticate(w, r)
+		if !ok || !s.authorize(w, r) {
 			return
 		}
 		mux.ServeHTTP(w, r)
 	})
-}
-
-func (s *Server) allowClient(r *http.Request) bool {
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		host = r.RemoteAddr
-	}
-	ip := net.ParseIP(host)
-	return config.IsAllowedClient(ip, s.cfg.AllowCIDRs)
+	mux.HandleFunc("/api/mcp", s.mcpEndpoint(handler))
+	return s.recoverHandlerPanics(handler)
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		writeMethodNotAllowed(w)
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]any{
+	payload := map[string]any{
 		"ok":       true,
 		"basePath": browserfs.GetHomeRoot(),
-	})
+	}
+	if stats, err := s.storageStats(); err == nil {
+		payload["storage"] = stats
+	}
+	payload["eventSchema"] = map[string]any{"version": events.SchemaVersion, "shims": s.eventSchema.Shims()}
+	disk := s.diskChecks()
+	payload["disk"] = disk
+	var warnings []string
+	for _, check := range disk {
+		if check.Low != "" {
+			warnings = append(warnings, (&diskLowError{diskCheck: check, MinFreeBytes: s.cfg.MinFreeDiskBytes, MinFreeInodes: s.cfg.MinFreeInodes}).Error())
+		}
+	}
+	if len(warnings) > 0 {
+		payload["warnings"] = warnings
+	}
+	writeJSON(w, http.StatusOK, payload)
 }
 
 func (s *Server) handleFSList(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		writeMethodNotAllowed(w)
 		return
 	}
 	listing, err := browserfs.ListFolder(r.URL.Query().Get("path"))
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		writeError(w, http.StatusBadRequest, errCodeInvalidPath, err.Error())
 		return
 	}
 	writeJSON(w, http.StatusOK, listing)
@@ -195,46 +532,81 @@ func (s *Server) handleFSList(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleThreadEvents(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		writeMethodNotAllowed(w)
 		return
 	}
 	threadID := strings.TrimSpace(r.URL.Query().Get("threadId"))
 	if threadID == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "threadId is required."})
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
 		return
 	}
-	events, err := s.eventStore.Read(threadID)
+	records, err := s.eventStore.ReadRange(threadID, "", 0)
+	if err == nil && len(records) == 0 && s.backfillColdThread(r.Context(), threadID) {
+		records, err = s.eventStore.ReadRange(threadID, "", 0)
+	}
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]any{"threadId": threadID, "events": events})
+	render := r.URL.Query().Get("render") == "html"
+	payloads := make([]string, 0, len(records))
+	for _, record := range records {
+		if render {
+			record.Payload = withRenderedAgentMessage(record.Payload)
+		}
+		payloads = append(payloads, record.Payload)
+	}
+	response := map[string]any{"threadId": threadID, "schemaVersion": events.SchemaVersion, "events": payloads}
+	if r.URL.Query().Get("records") == "true" {
+		// records[i] carries the stamps for events[i].
+		stamps := make([]eventStamp, 0, len(records))
+		for _, record := range records {
+			stamps = append(stamps, eventStamp{ID: record.ID, Seq: record.Seq, Time: record.Time})
+		}
+		response["records"] = stamps
+	}
+	writeJSON(w, http.StatusOK, response)
 }
 
 func (s *Server) handleThreadEventsStream(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		writeMethodNotAllowed(w)
 		return
 	}
 	threadID := strings.TrimSpace(r.URL.Query().Get("threadId"))
 	if threadID == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "threadId is required."})
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
 		return
 	}
+	unnamed, err := sseEventNamesOff(r.URL.Query().Get("eventNames"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "eventNames must be true or false.")
+		return
+	}
+	release, limitCode := s.sseStreams.acquire(remoteHost(r), threadID)
+	if limitCode != "" {
+		writeSSELimit(w, limitCode)
+		return
+	}
+	defer release()
 
 	lastEventIDRaw := strings.TrimSpace(r.Header.Get("Last-Event-ID"))
 	if lastEventIDRaw == "" {
 		lastEventIDRaw = strings.TrimSpace(r.URL.Query().Get("lastEventId"))
 	}
-	history, err := s.eventStore.ReadRecords(threadID)
+	if all, err := s.eventStore.ReadRange(threadID, "", 1); err == nil && len(all) == 0 {
+		s.backfillColdThread(r.Context(), threadID)
+	}
+	history, err := s.eventStore.ReadRange(threadID, lastEventIDRaw, 0)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
 		return
 	}
 
+	w.Header().Set(eventSchemaHeader, strconv.Itoa(events.SchemaVersion))
 	sess, err := sse.Upgrade(w, r)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		writeError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
 		return
 	}
 	ready := &sse.Message{}
@@ -243,21 +615,23 @@ func (s *Server) handleThreadEventsStream(w http.ResponseWriter, r *http.Request
 		return
 	}
 	_ = sess.Flush()
+	send := func(msg *sse.Message) error {
+		if unnamed {
+			msg = unnamedSSEMessage(msg)
+		}
+		return sess.Send(msg)
+	}
 
 	for _, record := range history {
-		if lastEventIDRaw != "" && record.ID <= lastEventIDRaw {
-			continue
-		}
-		if err := sendSSEMessage(sess, record.ID, record.Payload); err != nil {
+		if err := send(newThreadEventMessage(record.ID, record.Payload)); err != nil {
 			return
 		}
 	}
 	_ = sess.Flush()
+	// History is in ID order, so its last record is the newest replayed.
 	replayCursor := lastEventIDRaw
-	for _, record := range history {
-		if replayCursor == "" || record.ID > replayCursor {
-			replayCursor = record.ID
-		}
+	if len(history) > 0 {
+		replayCursor = history[len(history)-1].ID
 	}
 	writer := &channelMessageWriter{ch: make(chan *sse.Message, 128)}
 	sub := sse.Subscription{
@@ -271,6 +645,17 @@ func (s *Server) handleThreadEventsStream(w http.ResponseWriter, r *http.Request
 	go func() {
 		subscribeErr <- s.sseProvider.Subscribe(r.Context(), sub)
 	}()
+	// Drafts are not stored, so they carry no id and leave the client's
+	// Last-Event-ID alone; the current entries are sent after the history.
+	go func() {
+		_ = s.drafts.events.Subscribe(r.Context(), sse.Subscription{Client: writer, Topics: []string{threadID}})
+	}()
+	for _, message := range s.drafts.snapshot(threadID) {
+		if err := send(message); err != nil {
+			return
+		}
+	}
+	_ = sess.Flush()
 	for {
 		select {
 		case <-r.Context().Done():
@@ -281,7 +666,7 @@ func (s *Server) handleThreadEventsStream(w http.ResponseWriter, r *http.Request
 			}
 			return
 		case message := <-writer.ch:
-			if err := sess.Send(message); err != nil {
+			if err := send(message); err != nil {
 				return
 			}
 			_ = sess.Flush()
@@ -291,7 +676,7 @@ func (s *Server) handleThreadEventsStream(w http.ResponseWriter, r *http.Request
 
 func (s *Server) handleRPC(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		writeMethodNotAllowed(w)
 		return
 	}
 	r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
@@ -300,73 +685,256 @@ func (s *Server) handleRPC(w http.ResponseWriter, r *http.Request) {
 		Params any    `json:"params"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "Invalid JSON body."})
+		writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
 		return
 	}
 	request.Method = strings.TrimSpace(request.Method)
 	if request.Method == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "method is required."})
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "method is required.")
+		return
+	}
+
+	response, err := s.dispatchRPC(r.Context(), request.Method, request.Params)
+	if err != nil {
+		writeDispatchError(w, err)
+		return
+	}
+
+	if errObj, ok := response["error"].(map[string]any); ok {
+		writeUpstreamRPCError(w, errObj)
 		return
 	}
 
+	writeJSON(w, http.StatusOK, response["result"])
+}
+
+// dispatchRPC forwards one JSON-RPC call to the session owning the thread in
+// params (spawning one if needed), applying darkhold's request rewrites and
+// post-response bookkeeping. Upstream errors are returned in the response.
+func (s *Server) dispatchRPC(ctx context.Context, method string, params any) (map[string]any, error) {
 	threadIDHint := ""
-	if paramsMap, ok := request.Params.(map[string]any); ok {
-		if tid, ok := paramsMap["threadId"].(string); ok {
-			threadIDHint = tid
+	paramsMap, _ := params.(map[string]any)
+	if tid, ok := paramsMap["threadId"].(string); ok {
+		threadIDHint = tid
+	}
+
+	identity := clientIdentity(ctx)
+	// started is set once turn/start has succeeded; deferred cleanups of a
+	// failed start check it.
+	started := false
+	var hookRuns []turnHookRun
+	var budget *usage.Reservation
+	if method == "turn/start" {
+		normalized, err := s.normalizeTurnStart(paramsMap)
+		if err != nil {
+			return nil, err
+		}
+		paramsMap, params = normalized, normalized
+		if budget, err = s.reserveTurnBudget(identity, paramsMap); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if !started {
+				s.releaseTurnBudget(threadIDHint, budget)
+			}
+		}()
+		if err := s.checkDiskSpace(); err != nil {
+			return nil, err
+		}
+		transformed, runs, err := s.runTurnHooks(ctx, threadIDHint, paramsMap)
+		if err != nil {
+			return nil, err
+		}
+		paramsMap, params, hookRuns = transformed, transformed, runs
+	}
+
+	var estimate *turnEstimate
+	switch {
+	case method == "turn/start" && threadIDHint != "":
+		withNotes := s.withPinnedNotes(threadIDHint, paramsMap)
+		withPreamble := s.withSystemPreamble(threadIDHint, withNotes)
+		sized := s.estimateTurn(paramsMap, withNotes, withPreamble)
+		if err := s.checkTurnEstimate(sized); err != nil {
+			return nil, err
+		}
+		estimate = &sized
+		if s.rememberFirstPrompt(threadIDHint, paramsMap) {
+			defer func() {
+				if !started {
+					s.forgetFirstPrompt(threadIDHint)
+				}
+			}()
+		}
+		params = s.withThreadSettings(threadIDHint, withPreamble)
+	case (method == "thread/start" || method == "thread/resume") && paramsMap != nil:
+		params = s.withWorkspaceDefaults(threadIDHint, paramsMap)
+	}
+
+	if threadLockedMethods[method] && threadIDHint != "" {
+		unlock, err := s.lockThread(ctx, threadIDHint, method)
+		if err != nil {
+			return nil, err
 		}
+		defer unlock()
+	}
+
+	var snap *snapshots.Snapshot
+	if method == "turn/start" {
+		snap = s.takeTurnSnapshot(ctx, threadIDHint, paramsMap)
+	}
+
+	cacheKey := ""
+	var cacheGeneration uint64
+	if s.rpcCache.enabled(method) {
+		cacheKey = rpcCacheKey(method, params)
+		cached, generation, ok := s.rpcCache.get(cacheKey, threadIDHint)
+		if ok {
+			return cached, nil
+		}
+		cacheGeneration = generation
+	} else if rpcCacheLifecycleRPCs[method] {
+		s.rpcCache.invalidateThread(threadIDHint, true)
+	} else if threadIDHint != "" {
+		// Calls naming no thread (config/*, model/list) touch nothing
+		// cached; the rest may change the thread's own entries.
+		s.rpcCache.invalidateThread(threadIDHint, false)
 	}
 
 	sess, err := s.selectSession(threadIDHint)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
-		return
+		s.discardTurnSnapshot(threadIDHint, snap)
+		return nil, &rpcDispatchError{code: errCodeSessionSpawnFailed, err: err}
 	}
+	// Claim the thread before the call, so the fence already admits what
+	// the session sends for it ahead of the response.
+	s.bindThreadToSession(threadIDHint, sess)
 
-	if request.Method != "initialize" {
+	if method != "initialize" {
 		if err := s.ensureInitialized(sess); err != nil {
-			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
-			return
+			s.discardTurnSnapshot(threadIDHint, snap)
+			return nil, &rpcDispatchError{code: errCodeSessionInitFailed, err: err}
 		}
 	}
 
-	response, err := s.callSessionRPC(r.Context(), sess, request.Method, request.Params)
+	if method == "turn/start" && threadIDHint != "" {
+		settleRetry := s.rememberTurnStart(threadIDHint, identity, paramsMap)
+		defer func() { settleRetry(started) }()
+		s.holdTurnBudget(threadIDHint, budget)
+	}
+	response, err := s.callSessionRPC(ctx, sess, method, params)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
-		return
+		s.discardTurnSnapshot(threadIDHint, snap)
+		code := errCodeInternal
+		if errors.Is(err, errSessionSaturated) {
+			code = errCodeSessionSaturated
+		}
+		return nil, &rpcDispatchError{code: code, err: err}
 	}
 
-	if errObj, ok := response["error"].(map[string]any); ok {
-		message, _ := errObj["message"].(string)
-		if message == "" {
-			message = "RPC error"
-		}
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": message})
-		return
+	if _, ok := response["error"].(map[string]any); ok {
+		s.discardTurnSnapshot(threadIDHint, snap)
+		return response, nil
 	}
 
 	if threadIDHint != "" {
 		s.bindThreadToSession(threadIDHint, sess)
 	}
+	if method == "turn/start" {
+		started = true
+		s.recordTurnStarted(threadIDHint, identity, budget)
+		s.clearThreadDraft(threadIDHint, "turnStarted")
+		s.recordSystemPreamble(threadIDHint)
+		result, _ := response["result"].(map[string]any)
+		s.recordTurnHooks(threadIDHint, result, hookRuns)
+		if result != nil {
+			s.turnStartedSnapshot(threadIDHint, result)
+			if estimate != nil {
+				result["darkholdEstimate"] = estimate
+			}
+		}
+	}
+	s.recordThreadArchived(threadIDHint, method)
+	if cacheKey != "" {
+		s.rpcCache.put(cacheKey, threadIDHint, cacheGeneration, response)
+	}
 
-	if request.Method == "thread/start" || request.Method == "thread/read" || request.Method == "thread/resume" {
+	if method == "thread/start" || method == "thread/read" || method == "thread/resume" {
 		if result, ok := response["result"].(map[string]any); ok {
 			if threadObj, ok := result["thread"].(map[string]any); ok {
 				if threadID, ok := threadObj["id"].(string); ok && threadID != "" {
 					s.bindThreadToSession(threadID, sess)
-					if request.Method == "thread/read" || request.Method == "thread/resume" {
-						_ = s.eventStore.RehydrateFromThreadRead(threadID, result)
+					s.recordThreadSeen(threadID, threadObj)
+					if method != "thread/read" {
+						s.recordRecentCwd(threadObj)
+					}
+					if (method == "thread/read" || method == "thread/resume") && !skipRehydrate(ctx) {
+						if err := s.eventStore.Rehydrate(threadID, result); err != nil {
+							log.Printf("[events] failed to reconcile thread %s with %s: %v", threadID, method, err)
+						}
 					}
 				}
 			}
 		}
 	}
 
-	writeJSON(w, http.StatusOK, response["result"])
+	return response, nil
+}
+
+// rpcDispatchError carries the fallback error code for a failed dispatch step.
+type rpcDispatchError struct {
+	code string
+	err  error
+}
+
+func (e *rpcDispatchError) Error() string { return e.err.Error() }
+func (e *rpcDispatchError) Unwrap() error { return e.err }
+
+func writeDispatchError(w http.ResponseWriter, err error) {
+	var budgetErr *budgetExceededError
+	if errors.As(err, &budgetErr) {
+		writeBudgetExceeded(w, budgetErr)
+		return
+	}
+	var diskErr *diskLowError
+	if errors.As(err, &diskErr) {
+		writeDiskLow(w, diskErr)
+		return
+	}
+	var inputErr *turnInputError
+	if errors.As(err, &inputErr) {
+		writeInvalidTurnInput(w, inputErr)
+		return
+	}
+	var busyErr *threadBusyError
+	if errors.As(err, &busyErr) {
+		writeThreadBusy(w, busyErr)
+		return
+	}
+	var sizeErr *turnTooLargeError
+	if errors.As(err, &sizeErr) {
+		writeTurnTooLarge(w, sizeErr)
+		return
+	}
+	var rejectedErr *turnRejectedError
+	if errors.As(err, &rejectedErr) {
+		writeTurnRejected(w, rejectedErr)
+		return
+	}
+	var dispatchErr *rpcDispatchError
+	if !errors.As(err, &dispatchErr) {
+		writeSessionError(w, err, errCodeInternal)
+		return
+	}
+	if dispatchErr.code == errCodeSessionSpawnFailed {
+		writeError(w, http.StatusServiceUnavailable, errCodeSessionSpawnFailed, err.Error())
+		return
+	}
+	writeSessionError(w, err, dispatchErr.code)
 }
 
 func (s *Server) handleInteractionRespond(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]any{"error": "method not allowed"})
+		writeMethodNotAllowed(w)
 		return
 	}
 	r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
@@ -377,60 +945,141 @@ func (s *Server) handleInteractionRespond(w http.ResponseWriter, r *http.Request
 		Error     any    `json:"error"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "Invalid JSON body."})
+		writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
 		return
 	}
 	request.ThreadID = strings.TrimSpace(request.ThreadID)
 	request.RequestID = strings.TrimSpace(request.RequestID)
 	if request.ThreadID == "" || request.RequestID == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "threadId and requestId are required."})
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId and requestId are required.")
+		return
+	}
+	if request.Result != nil && request.Error != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "send either result or error, not both.")
 		return
 	}
+	// Only interactions held by this replica can be checked here; the
+	// replica holding a forwarded one checks it again before answering.
+	kind := ""
+	if method, ok := s.pendingInteractionMethod(request.ThreadID, request.RequestID); ok {
+		handler := reverseRequestHandlerFor(method)
+		kind = handler.kind
+		var invalid *resultShapeError
+		if errors.As(handler.check(request.Result, request.Error), &invalid) {
+			writeInvalidInteractionResult(w, method, handler, request.Error, invalid)
+			return
+		}
+	}
 
-	s.sessionsMu.Lock()
-	threadPending := s.pendingResponses[request.ThreadID]
-	if threadPending == nil {
-		s.sessionsMu.Unlock()
-		writeJSON(w, http.StatusConflict, map[string]any{"error": "interaction request not found or already resolved."})
+	unlock, err := s.lockThread(r.Context(), request.ThreadID, "interaction/respond")
+	if err != nil {
+		writeDispatchError(w, err)
+		return
+	}
+	defer unlock()
+	forwarded, err := s.resolveInteractionAnywhere(r.Context(), request.ThreadID, request.RequestID, request.Result, request.Error, map[string]any{"source": "http", "by": clientIdentity(r.Context())})
+	var invalid *resultShapeError
+	switch {
+	case errors.Is(err, errInteractionNotFound):
+		writeError(w, http.StatusConflict, errCodeInteractionResolved, "interaction request not found or already resolved.")
+		return
+	case errors.As(err, &invalid):
+		method, _ := s.pendingInteractionMethod(request.ThreadID, request.RequestID)
+		writeInvalidInteractionResult(w, method, reverseRequestHandlerFor(method), request.Error, invalid)
+		return
+	case err != nil:
+		writeError(w, http.StatusGone, errCodeSessionUnavailable, "app-server session is unavailable.")
+		return
+	case forwarded:
+		writeInteractionForwarded(w, nil)
 		return
 	}
-	pending, ok := threadPending[request.RequestID]
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "kind": kind})
+}
+
+// writeInvalidInteractionResult answers 422 with the schema the response was
+// checked against. The interaction stays pending, so the client can retry.
+func writeInvalidInteractionResult(w http.ResponseWriter, method string, handler reverseRequestHandler, rpcErr any, invalid *resultShapeError) {
+	writeErrorDetails(w, http.StatusUnprocessableEntity, errCodeInvalidInteractionResult, "response does not match the "+handler.kind+" request: "+invalid.Error(), map[string]any{
+		"method": method,
+		"kind":   handler.kind,
+		"field":  invalid.Field,
+		"reason": invalid.Reason,
+		"schema": handler.responseSchema(rpcErr),
+	})
+}
+
+// resolveInteraction claims a pending interaction (first write wins), forwards
+// the response upstream and publishes darkhold/interaction/resolved with the
+// given resolution fields merged into its params. A response that does not
+// fit the request's method is refused with a *resultShapeError and leaves the
+// interaction pending; approval decisions are normalized to the method's
+// vocabulary.
+func (s *Server) resolveInteraction(threadID, requestID string, result, rpcErr any, resolution map[string]any) error {
+	s.sessionsMu.Lock()
+	threadPending := s.pendingResponses[threadID]
+	pending, ok := threadPending[requestID]
 	if !ok {
 		s.sessionsMu.Unlock()
-		writeJSON(w, http.StatusConflict, map[string]any{"error": "interaction request not found or already resolved."})
-		return
+		return errInteractionNotFound
 	}
-	delete(threadPending, request.RequestID)
+	handler := reverseRequestHandlerFor(pending.method)
+	if err := handler.check(result, rpcErr); err != nil {
+		s.sessionsMu.Unlock()
+		return err
+	}
+	if rpcErr == nil {
+		result = handler.normalize(result)
+	}
+	delete(threadPending, requestID)
 	if len(threadPending) == 0 {
-		delete(s.pendingResponses, request.ThreadID)
+		delete(s.pendingResponses, threadID)
 	}
+	s.forgetInteractionFingerprint(threadID, requestID, pending)
 	sess := s.sessions[pending.sessionID]
+	duplicates := make(map[*session]int64, len(pending.duplicates))
+	for _, duplicate := range pending.duplicates {
+		if dupSess := s.sessions[duplicate.sessionID]; dupSess != nil {
+			duplicates[dupSess] = duplicate.requestID
+		}
+	}
 	s.sessionsMu.Unlock()
 
 	if sess == nil {
-		writeJSON(w, http.StatusGone, map[string]any{"error": "app-server session is unavailable."})
-		return
+		return errSessionUnavailable
 	}
 
-	payload := map[string]any{"jsonrpc": "2.0", "id": pending.requestID}
-	if request.Error != nil {
-		payload["error"] = request.Error
-	} else {
-		payload["result"] = request.Result
+	respond := func(sess *session, id int64) error {
+		payload := map[string]any{"jsonrpc": "2.0", "id": id}
+		if rpcErr != nil {
+			payload["error"] = rpcErr
+		} else {
+			payload["result"] = result
+		}
+		line, _ := json.Marshal(payload)
+		return s.writeSessionLine(sess, string(line))
 	}
-	line, _ := json.Marshal(payload)
-	if err := s.writeSessionLine(sess, string(line)); err != nil {
-		writeJSON(w, http.StatusGone, map[string]any{"error": "app-server session is unavailable."})
-		return
+	if err := respond(sess, pending.requestID); err != nil {
+		return err
+	}
+	if handler.kind == reverseKindApproval && rpcErr == nil {
+		s.stats.approvalResolved(result)
+	}
+	for dupSess, id := range duplicates {
+		if err := respond(dupSess, id); err != nil {
+			log.Printf("[session=%d] failed to answer duplicate request %d on thread %s: %v", dupSess.id, id, threadID, err)
+		}
 	}
 
-	resolvedPayload := map[string]any{
+	params := map[string]any{"threadId": threadID, "requestId": requestID}
+	maps.Copy(params, resolution)
+	resolvedLine, _ := json.Marshal(map[string]any{
 		"method": "darkhold/interaction/resolved",
-		"params": map[string]any{"threadId": request.ThreadID, "requestId": request.RequestID, "source": "http"},
-	}
-	resolvedLine, _ := json.Marshal(resolvedPayload)
-	s.publishThreadEvent(request.ThreadID, string(resolvedLine))
-	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+		"params": params,
+	})
+	s.publishThreadEvent(threadID, string(resolvedLine))
+	s.observeBroadcastEvent(threadID, "darkhold/interaction/resolved", params)
+	return nil
 }
 
 func (s *Server) handleWeb(w http.ResponseWriter, r *http.Request) {
@@ -445,7 +1094,7 @@ func (s *Server) handleWeb(w http.ResponseWriter, r *http.Request) {
 
 	requestPath := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
 	if requestPath == "api" || strings.HasPrefix(requestPath, "api/") {
-		http.NotFound(w, r)
+		writeError(w, http.StatusNotFound, errCodeNotFound, "no API route matches "+r.URL.Path)
 		return
 	}
 	if requestPath == "" || requestPath == "." {
@@ -471,6 +1120,7 @@ func (s *Server) handleWeb(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("Cache-Control", "no-store")
+	s.setWebSecurityHeaders(w)
 	w.WriteHeader(http.StatusOK)
 	if r.Method != http.MethodHead {
 		_, _ = w.Write(data)
@@ -481,16 +1131,42 @@ func (s *Server) publishThreadEvent(threadID, payload string) {
 	s.publishMu.Lock()
 	defer s.publishMu.Unlock()
 
-	eventID, err := s.eventStore.Append(threadID, payload)
+	record, err := s.eventStore.Append(threadID, payload)
 	if err != nil {
 		log.Printf("[publish] failed to append event for thread %s: %v", threadID, err)
 		return
 	}
-	msg := &sse.Message{ID: sse.ID(eventID)}
-	msg.AppendData(payload)
+	s.stats.eventStored()
+	msg := newThreadEventMessage(record.ID, payload)
 	if err := s.sseProvider.Publish(msg, []string{threadID}); err != nil {
 		log.Printf("[publish] failed to broadcast event for thread %s: %v", threadID, err)
 	}
+	s.exportThreadEvent(threadID, record)
+}
+
+// exportThreadEvent hands a stored thread event to the outbound integrations
+// (webhooks, MQTT). Both queue internally, so this never blocks on the network.
+func (s *Server) exportThreadEvent(threadID string, record events.Record) {
+	var event struct {
+		Method string `json:"method"`
+	}
+	if err := json.Unmarshal([]byte(record.Payload), &event); err != nil || event.Method == "" {
+		return
+	}
+	ev := webhooks.Event{
+		ThreadID: threadID,
+		EventID:  record.ID,
+		Seq:      record.Seq,
+		Time:     record.Time,
+		Method:   event.Method,
+		Payload:  record.Payload,
+	}
+	if event.Method == "darkhold/interaction/request" {
+		ev.Links = s.interactionQuickLinks(record.Payload)
+	}
+	ev.Message = s.notificationMessage(record.Payload)
+	s.webhooks.Publish(ev)
+	s.publishMQTTEvent(threadID, event.Method, record.Payload)
 }
 
 func sendSSEMessage(sess *sse.Session, id, payload string) error {
@@ -508,6 +1184,9 @@ func (s *Server) bindThreadToSession(threadID string, sess *session) {
 	sess.mu.Unlock()
 
 	s.sessionsMu.Lock()
+	if owner, bound := s.threadToSession[threadID]; !bound || owner != sess.id {
+		s.threadEpochs[threadID]++
+	}
 	s.threadToSession[threadID] = sess.id
 	s.sessionsMu.Unlock()
 }
@@ -518,7 +1197,7 @@ func (s *Server) selectSession(threadIDHint string) (*session, error) {
 		if sessionID, ok := s.threadToSession[threadIDHint]; ok {
 			if sess, ok := s.sessions[sessionID]; ok {
 				sess.mu.Lock()
-				alive := !sess.closed && !sess.stopRequested
+				alive := !sess.closed && !sess.stopRequested && !sess.unhealthy
 				sess.mu.Unlock()
 				if alive {
 					s.sessionsMu.RUnlock()
@@ -527,55 +1206,50 @@ func (s *Server) selectSession(threadIDHint string) (*session, error) {
 			}
 		}
 	}
-	for _, sess := range s.sessions {
-		sess.mu.Lock()
-		alive := !sess.closed && !sess.stopRequested
-		sess.mu.Unlock()
-		if alive {
-			s.sessionsMu.RUnlock()
-			return sess, nil
-		}
+	if sess := s.leastLoadedSession(); sess != nil {
+		s.sessionsMu.RUnlock()
+		return sess, nil
 	}
 	s.sessionsMu.RUnlock()
 
-	return s.spawnSession()
+	sess, err := s.spawnSession()
+	if err == nil {
+		s.recordRouting(routingDecision{SessionID: sess.id, Reason: "spawned"})
+	}
+	return sess, err
 }
 
 func (s *Server) spawnSession() (*session, error) {
-	cmd := exec.Command("codex", "app-server")
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return nil, err
-	}
-	stdout, err := cmd.StdoutPipe()
+	proc, pipes, err := startAgent(s.cfg)
 	if err != nil {
 		return nil, err
 	}
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return nil, err
-	}
-	if err := cmd.Start(); err != nil {
-		return nil, err
-	}
 
 	s.sessionsMu.Lock()
 	s.nextSessionID++
+	id := s.nextSessionID
+	s.sessionsMu.Unlock()
 	now := time.Now()
 	sess := &session{
-		id:             s.nextSessionID,
-		cmd:            cmd,
-		stdin:          stdin,
+		id:             id,
+		proc:           proc,
+		stdin:          pipes.stdin,
+		startedAt:      now,
+		stderr:         newLineRing(stderrRingSize),
 		pending:        map[int64]chan map[string]any{},
 		knownThreadIDs: map[string]struct{}{},
-		activeTurnIDs:  map[string]struct{}{},
+		activeTurnIDs:  map[string]string{},
 		lastActivityAt: now,
 	}
+	sess.recorder = s.startSessionRecording(sess)
+	s.sessionsMu.Lock()
 	s.sessions[sess.id] = sess
 	s.sessionsMu.Unlock()
+	s.agentPIDs.record(sess.id, proc.Pid(), s.cfg.AgentCmd)
+	s.setSessionState(sess, sessionStateSpawned, nil)
 
-	go s.readSessionStdout(sess, stdout)
-	go s.readSessionStderr(sess, stderr)
+	go s.readSessionStdout(sess, pipes.stdout)
+	go s.readSessionStderr(sess, pipes.stderr)
 	go s.waitSessionExit(sess)
 	return sess, nil
 }
@@ -588,40 +1262,58 @@ func (s *Server) readSessionStdout(sess *session, reader io.Reader) {
 		if line == "" {
 			continue
 		}
-		s.handleSessionLine(sess, line)
+		sess.recorder.record("recv", line)
+		s.handleSessionLineRecovered(sess, line)
 	}
 	if err := scanner.Err(); err != nil {
 		log.Printf("[session=%d] stdout scanner error: %v", sess.id, err)
 	}
 }
 
+// handleSessionLineRecovered handles one line from the agent, dropping it
+// if handling it panics, so one unexpected message does not end the session
+// (or the process).
+func (s *Server) handleSessionLineRecovered(sess *session, line string) {
+	defer s.recoverPanic("session")
+	s.handleSessionLine(sess, line)
+}
+
 func (s *Server) readSessionStderr(sess *session, reader io.Reader) {
 	scanner := bufio.NewScanner(reader)
 	for scanner.Scan() {
+		sess.stderr.append(scanner.Text())
 		_, _ = fmt.Fprintf(os.Stderr, "[app-server session=%d] %s\n", sess.id, scanner.Text())
 	}
 }
 
 func (s *Server) waitSessionExit(sess *session) {
-	_ = sess.cmd.Wait()
+	defer s.recoverPanic("session")
+	_ = sess.proc.Wait()
+	s.agentPIDs.forget(sess.proc.Pid())
+	sess.recorder.close()
+
+	sess.mu.Lock()
+	sess.exitedAt = time.Now()
+	sess.exitCode = sess.proc.ExitCode()
+	crashed := !sess.stopRequested
+	threadIDs := make([]string, 0, len(sess.knownThreadIDs))
+	for threadID := range sess.knownThreadIDs {
+		threadIDs = append(threadIDs, threadID)
+	}
+	sess.mu.Unlock()
 
 	s.sessionsMu.Lock()
 	delete(s.sessions, sess.id)
+	s.exitedSessions = append(s.exitedSessions, sess)
+	if len(s.exitedSessions) > exitedSessionHistory {
+		s.exitedSessions = s.exitedSessions[len(s.exitedSessions)-exitedSessionHistory:]
+	}
 	for threadID, id := range s.threadToSession {
 		if id == sess.id {
 			delete(s.threadToSession, threadID)
 		}
 	}
-	for threadID, pending := range s.pendingResponses {
-		for requestID, entry := range pending {
-			if entry.sessionID == sess.id {
-				delete(pending, requestID)
-			}
-		}
-		if len(pending) == 0 {
-			delete(s.pendingResponses, threadID)
-		}
-	}
+	s.dropSessionInteractions(sess.id)
 	s.sessionsMu.Unlock()
 
 	sess.mu.Lock()
@@ -631,6 +1323,21 @@ func (s *Server) waitSessionExit(sess *session) {
 		close(ch)
 	}
 	sess.mu.Unlock()
+
+	for _, threadID := range threadIDs {
+		s.stopCwdWatch(threadID)
+		s.forgetAlertTurn(threadID)
+		s.forgetLimitedTurn(threadID)
+	}
+	s.stats.sessionExited(threadIDs)
+	exited := map[string]any{"exitCode": sess.exitCode, "crashed": crashed, "uptimeMs": sess.exitedAt.Sub(sess.startedAt).Milliseconds()}
+	if sess.killedFor != "" {
+		exited["killedFor"] = sess.killedFor
+	}
+	s.setSessionState(sess, sessionStateExited, exited)
+	if crashed {
+		s.handleSessionCrash(threadIDs)
+	}
 }
 
 func (s *Server) handleSessionLine(sess *session, line string) {
@@ -639,7 +1346,6 @@ func (s *Server) handleSessionLine(sess *session, line string) {
 		log.Printf("[session=%d] malformed JSON from upstream: %v", sess.id, err)
 		return
 	}
-	s.markSessionActivity(sess)
 
 	if idFloat, ok := parsed["id"].(float64); ok {
 		if _, hasResult := parsed["result"]; hasResult || parsed["error"] != nil {
@@ -647,18 +1353,30 @@ func (s *Server) handleSessionLine(sess *session, line string) {
 			sess.mu.Lock()
 			ch := sess.pending[requestID]
 			delete(sess.pending, requestID)
+			abandoned, wasAbandoned := sess.abandoned[requestID]
+			delete(sess.abandoned, requestID)
+			// Ping and keepalive answers are not activity, or they would
+			// keep idle sessions from being reaped.
+			if requestID != sess.pingRequestID && requestID != sess.keepaliveRequestID {
+				sess.lastActivityAt = time.Now()
+			}
 			sess.mu.Unlock()
 			if ch != nil {
 				ch <- parsed
+			} else if wasAbandoned {
+				s.handleAbandonedResponse(sess, abandoned, parsed)
 			}
 			return
 		}
 	}
 
+	s.markSessionActivity(sess)
 	method, _ := parsed["method"].(string)
 	if method == "" {
 		return
 	}
+	line = s.eventSchema.NormalizeLine(line, parsed)
+	method, _ = parsed["method"].(string)
 
 	params, _ := parsed["params"].(map[string]any)
 	s.trackSessionTurnState(sess, method, params)
@@ -668,51 +1386,133 @@ func (s *Server) handleSessionLine(sess *session, line string) {
 			threadID = inferred
 		}
 	}
+	if s.annotateFileChanges(threadID, method, params) {
+		if encoded, err := json.Marshal(parsed); err == nil {
+			line = string(encoded)
+		}
+	}
 
 	if idFloat, ok := parsed["id"].(float64); ok {
 		if threadID == "" {
 			log.Printf("[session=%d] dropping upstream request %s (id=%.0f): cannot infer threadId", sess.id, method, idFloat)
 			return
 		}
-		s.bindThreadToSession(threadID, sess)
-		requestID := strconv.FormatInt(int64(idFloat), 10)
+		if ok, owner, epoch := s.admitThreadEvent(threadID, sess); !ok {
+			s.quarantineThreadEvent(threadID, sess, owner, epoch, method, line)
+			return
+		}
 
 		s.sessionsMu.Lock()
-		threadPending := s.pendingResponses[threadID]
-		if threadPending == nil {
-			threadPending = map[string]pendingInteraction{}
-			s.pendingResponses[threadID] = threadPending
-		}
-		threadPending[requestID] = pendingInteraction{
-			sessionID: sess.id,
-			requestID: int64(idFloat),
-			method:    method,
-			params:    params,
-		}
+		requestID, coalesced := s.registerInteraction(threadID, pendingInteraction{
+			sessionID:   sess.id,
+			requestID:   int64(idFloat),
+			method:      method,
+			params:      params,
+			fingerprint: interactionFingerprint(method, params),
+			receivedAt:  time.Now(),
+		})
 		s.sessionsMu.Unlock()
+		if coalesced {
+			log.Printf("[session=%d] coalesced duplicate %s (id=%.0f) into request %s on thread %s", sess.id, method, idFloat, requestID, threadID)
+			return
+		}
 
-		payload := map[string]any{
-			"method": "darkhold/interaction/request",
-			"params": map[string]any{
-				"threadId":  threadID,
-				"requestId": requestID,
-				"method":    method,
-				"params":    params,
-			},
+		if pattern := s.deniedCommand(params); pattern != nil {
+			go s.declineDeniedCommand(threadID, requestID, method, params, pattern)
+			return
 		}
-		encoded, _ := json.Marshal(payload)
-		s.publishThreadEvent(threadID, string(encoded))
+		if s.cfg.RejectOutsideRoot {
+			if check := s.checkConfinement(threadID, params); check != nil && check.Level == confinementWarning {
+				go s.declineForViolation(threadID, requestID, method, "outside-root", map[string]any{"root": check.Root, "outside": check.Outside})
+				return
+			}
+		}
+		if result, ok := s.autoResponses[method]; ok {
+			go s.autoRespond(threadID, requestID, method, result)
+			return
+		}
+		if s.cfg.PolicyURL != "" {
+			go s.consultPolicy(threadID, requestID, method, params)
+			return
+		}
+		s.publishInteractionRequest(threadID, requestID, method, params)
 		return
 	}
 
 	if threadID != "" {
-		s.bindThreadToSession(threadID, sess)
+		if ok, owner, epoch := s.admitThreadEvent(threadID, sess); !ok {
+			s.quarantineThreadEvent(threadID, sess, owner, epoch, method, line)
+			return
+		}
+		s.rpcCache.invalidateThread(threadID, rpcCacheLifecycleMethods[method])
 		s.publishThreadEvent(threadID, line)
+		s.forwardToSessionBridges(threadID, []byte(line))
+		s.observeThreadEvent(threadID, method, params)
 	} else {
 		log.Printf("[session=%d] dropping notification %s: cannot infer threadId", sess.id, method)
 	}
 }
 
+func (s *Server) publishInteractionRequest(threadID, requestID, method string, params map[string]any) {
+	wrapper := map[string]any{
+		"threadId":  threadID,
+		"requestId": requestID,
+		"method":    method,
+		"kind":      reverseRequestHandlerFor(method).kind,
+		"params":    params,
+	}
+	if check := s.checkConfinement(threadID, params); check != nil {
+		wrapper["confinement"] = check
+	}
+	payload := map[string]any{
+		"method": "darkhold/interaction/request",
+		"params": wrapper,
+	}
+	encoded, _ := json.Marshal(payload)
+	s.publishThreadEvent(threadID, string(encoded))
+	s.forwardInteractionToSessionBridges(threadID, requestID, method, params)
+	s.observeBroadcastEvent(threadID, "darkhold/interaction/request", params)
+}
+
+// observeThreadEvent runs server-side bookkeeping for upstream notifications
+// after they have been stored and broadcast.
+func (s *Server) observeThreadEvent(threadID, method string, params map[string]any) {
+	s.turnMessages.observe(threadID, method, params)
+	switch method {
+	case "turn/started":
+		s.stats.turnStarted(threadID)
+		s.trackAlertTurn(threadID, params)
+		s.trackLimitedTurn(threadID, params)
+		s.turnStartedSnapshot(threadID, params)
+		s.startCwdWatch(threadID, params)
+	case "turn/completed":
+		s.stats.turnFinished(threadID, method, params)
+		s.forgetAlertTurn(threadID)
+		s.forgetLimitedTurn(threadID)
+		s.stopCwdWatch(threadID)
+		s.applyAutoTitle(threadID, method, params)
+		s.settleTurnBudget(threadID)
+		s.observeTurnOutcome(threadID, method, params)
+		s.forgetOrphanedInteractions(threadID)
+		s.startPostTurnHooks(threadID, params)
+		s.fireThreadChains(threadID, params)
+		s.observeReplayTurn(threadID, method, params)
+	case "turn/failed", "turn/aborted":
+		s.stats.turnFinished(threadID, method, params)
+		s.forgetAlertTurn(threadID)
+		s.forgetLimitedTurn(threadID)
+		s.stopCwdWatch(threadID)
+		s.applyAutoTitle(threadID, method, params)
+		s.settleTurnBudget(threadID)
+		s.observeTurnOutcome(threadID, method, params)
+		s.forgetOrphanedInteractions(threadID)
+		s.observeReplayTurn(threadID, method, params)
+	case "thread/tokenUsage/updated":
+		s.recordTokenUsage(threadID, params)
+	}
+	s.observeBroadcastEvent(threadID, method, params)
+}
+
 func (s *Server) inferThreadID(sess *session) string {
 	sess.mu.Lock()
 	defer sess.mu.Unlock()
@@ -727,39 +1527,95 @@ func (s *Server) inferThreadID(sess *session) string {
 
 func (s *Server) ensureInitialized(sess *session) error {
 	sess.initOnce.Do(func() {
-		response, err := s.callSessionRPC(context.Background(), sess, "initialize", map[string]any{
-			"clientInfo":   map[string]any{"name": "darkhold-go", "title": "Darkhold Go", "version": "0.1.0"},
-			"capabilities": map[string]any{"experimentalApi": true},
-		})
+		started := time.Now()
+		response, err := s.callSessionRPC(context.Background(), sess, "initialize", s.initializeParams())
+		if err == nil {
+			if errObj, ok := response["error"].(map[string]any); ok {
+				message, _ := errObj["message"].(string)
+				if !strings.Contains(strings.ToLower(message), "already initialized") {
+					err = errors.New(message)
+				}
+			}
+		}
+		sess.initErr = err
 		if err != nil {
-			sess.initErr = err
+			s.setSessionState(sess, sessionStateUnhealthy, map[string]any{"reason": "initFailed", "error": err.Error()})
 			return
 		}
-		if errObj, ok := response["error"].(map[string]any); ok {
-			message, _ := errObj["message"].(string)
-			if !strings.Contains(strings.ToLower(message), "already initialized") {
-				sess.initErr = errors.New(message)
-			}
-		}
+		s.setSessionState(sess, sessionStateInitialized, map[string]any{"durationMs": time.Since(started).Milliseconds()})
 	})
 	return sess.initErr
 }
 
+// initializeParams builds the agent initialize params: clientInfo and
+// capabilities from the --agent-client-* and --agent-capability flags over
+// darkhold's defaults, then --agent-init-params merged over both.
+func (s *Server) initializeParams() map[string]any {
+	clientInfo := map[string]any{"name": "darkhold-go", "title": "Darkhold Go", "version": "0.1.0"}
+	for key, value := range map[string]string{"name": s.cfg.AgentClientName, "title": s.cfg.AgentClientTitle, "version": s.cfg.AgentClientVersion} {
+		if value != "" {
+			clientInfo[key] = value
+		}
+	}
+	capabilities := map[string]any{"experimentalApi": true}
+	maps.Copy(capabilities, s.cfg.AgentCapabilities)
+	params := map[string]any{"clientInfo": clientInfo, "capabilities": capabilities}
+	mergeJSONObject(params, s.cfg.AgentInitParams)
+	return params
+}
+
+// mergeJSONObject copies src into dst, merging nested objects key by key
+// rather than replacing them.
+func mergeJSONObject(dst, src map[string]any) {
+	for key, value := range src {
+		nested, ok := value.(map[string]any)
+		existing, isObject := dst[key].(map[string]any)
+		if ok && isObject {
+			mergeJSONObject(existing, nested)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
 func (s *Server) callSessionRPC(ctx context.Context, sess *session, method string, params any) (map[string]any, error) {
 	requestID := atomic.AddInt64(&sess.nextRequestID, 1_000_000)
 	responseCh := make(chan map[string]any, 1)
 
+	// A caller that is already gone gets nothing started on its behalf.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	keepalive := isKeepaliveRPC(ctx)
+	ping := method == sessionPingMethod && !keepalive
 	sess.mu.Lock()
 	if sess.closed {
 		sess.mu.Unlock()
-		return nil, errors.New("app-server session is unavailable")
+		return nil, errSessionUnavailable
+	}
+	// Pings bypass the cap: a saturated session is the one most worth
+	// checking.
+	if limit := s.cfg.MaxSessionRPCs; limit > 0 && !ping && len(sess.pending) >= limit {
+		sess.rejectedRPCs++
+		sess.mu.Unlock()
+		return nil, fmt.Errorf("%w (%d): %s", errSessionSaturated, limit, method)
 	}
 	sess.pending[requestID] = responseCh
+	sess.peakPending = max(sess.peakPending, len(sess.pending))
+	if ping {
+		sess.pingRequestID = requestID
+	}
+	if keepalive {
+		sess.keepaliveRequestID = requestID
+	}
 	sess.mu.Unlock()
 
 	payload := map[string]any{"jsonrpc": "2.0", "id": requestID, "method": method, "params": params}
 	encoded, _ := json.Marshal(payload)
-	s.markSessionActivity(sess)
+	if !ping && !keepalive {
+		s.markSessionActivity(sess)
+	}
+	sent := time.Now()
 	if err := s.writeSessionLine(sess, string(encoded)); err != nil {
 		sess.mu.Lock()
 		delete(sess.pending, requestID)
@@ -769,19 +1625,27 @@ func (s *Server) callSessionRPC(ctx context.Context, sess *session, method strin
 
 	select {
 	case <-ctx.Done():
-		sess.mu.Lock()
-		delete(sess.pending, requestID)
-		sess.mu.Unlock()
+		reason := abandonedReasonCanceled
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			reason = abandonedReasonTimeout
+		}
+		s.abandonRPC(sess, requestID, method, params, reason, sent)
 		return nil, ctx.Err()
 	case <-time.After(s.rpcTimeout):
-		sess.mu.Lock()
-		delete(sess.pending, requestID)
-		sess.mu.Unlock()
-		return nil, fmt.Errorf("RPC request timed out after %s: %s", s.rpcTimeout, method)
+		s.abandonRPC(sess, requestID, method, params, abandonedReasonTimeout, sent)
+		sess.recordRPCLatency(s.rpcTimeout)
+		return nil, fmt.Errorf("%w after %s: %s", errRPCTimeout, s.rpcTimeout, method)
 	case response, ok := <-responseCh:
 		if !ok {
-			return nil, errors.New("app-server session closed")
+			sess.mu.Lock()
+			unhealthy := sess.unhealthy
+			sess.mu.Unlock()
+			if unhealthy {
+				return nil, errSessionUnhealthy
+			}
+			return nil, errSessionClosed
 		}
+		sess.recordRPCLatency(time.Since(sent))
 		return response, nil
 	}
 }
@@ -790,20 +1654,32 @@ func (s *Server) writeSessionLine(sess *session, line string) error {
 	sess.mu.Lock()
 	if sess.closed {
 		sess.mu.Unlock()
-		return errors.New("app-server session is unavailable")
+		return errSessionUnavailable
 	}
 	sess.mu.Unlock()
 
 	sess.writeMu.Lock()
 	defer sess.writeMu.Unlock()
 	_, err := io.WriteString(sess.stdin, line+"\n")
+	if err == nil {
+		sess.recorder.record("send", line)
+	}
 	return err
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.shutdownMu.Do(func() {
 		close(s.reaperStop)
+		if s.clusterStop != nil {
+			s.clusterStop()
+		}
 	})
+	s.stopTurnRetries()
+	s.stopCwdWatches()
+	s.webhooks.Close()
+	if s.mqtt != nil {
+		s.mqtt.Close()
+	}
 
 	s.sessionsMu.RLock()
 	sessions := make([]*session, 0, len(s.sessions))
@@ -813,15 +1689,13 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	s.sessionsMu.RUnlock()
 
 	for _, sess := range sessions {
-		if sess.cmd.Process != nil {
-			_ = sess.cmd.Process.Signal(os.Interrupt)
-		}
+		_ = sess.proc.Interrupt()
 	}
 
 	done := make(chan struct{})
 	go func() {
 		for _, sess := range sessions {
-			_ = sess.cmd.Wait()
+			_ = sess.proc.Wait()
 		}
 		close(done)
 	}()
@@ -830,13 +1704,15 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	case <-done:
 	case <-ctx.Done():
 		for _, sess := range sessions {
-			if sess.cmd.Process != nil {
-				_ = sess.cmd.Process.Kill()
-			}
+			_ = sess.proc.Kill()
 		}
 	}
 
 	_ = s.sseProvider.Shutdown(ctx)
+	_ = s.drafts.events.Shutdown(ctx)
+	if s.indexDB != nil {
+		_ = s.indexDB.Close()
+	}
 	return nil
 }
 
@@ -855,7 +1731,9 @@ func (s *Server) sessionIdleReaper() {
 		}
 		s.sessionsMu.RUnlock()
 		for _, sess := range sessions {
-			s.tryReapSession(sess, now)
+			if !s.tryReapSession(sess, now) {
+				s.keepSessionAlive(sess, now)
+			}
 		}
 	}
 }
@@ -869,7 +1747,7 @@ func (s *Server) tryReapSession(sess *session, now time.Time) bool {
 		sess.mu.Unlock()
 		return false
 	}
-	if len(sess.activeTurnIDs) > 0 {
+	if sess.pinnedLocked() {
 		sess.mu.Unlock()
 		return false
 	}
@@ -878,11 +1756,11 @@ func (s *Server) tryReapSession(sess *session, now time.Time) bool {
 		return false
 	}
 	sess.stopRequested = true
+	idle := now.Sub(sess.lastActivityAt)
 	sess.mu.Unlock()
 
-	if sess.cmd.Process != nil {
-		_ = sess.cmd.Process.Signal(os.Interrupt)
-	}
+	s.setSessionState(sess, sessionStateReaped, map[string]any{"idleMs": idle.Milliseconds()})
+	_ = sess.proc.Interrupt()
 	return true
 }
 
@@ -912,8 +1790,9 @@ func (s *Server) markSessionActivity(sess *session) {
 }
 
 func (s *Server) trackSessionTurnState(sess *session, method string, params map[string]any) {
-	turnID := ""
+	turnID, threadID := "", ""
 	if params != nil {
+		threadID, _ = params["threadId"].(string)
 		if v, ok := params["turnId"].(string); ok {
 			turnID = v
 		}
@@ -930,7 +1809,7 @@ func (s *Server) trackSessionTurnState(sess *session, method string, params map[
 	switch method {
 	case "turn/started":
 		if turnID != "" {
-			sess.activeTurnIDs[turnID] = struct{}{}
+			sess.activeTurnIDs[turnID] = threadID
 		}
 	case "turn/completed", "turn/aborted", "turn/failed":
 		if turnID != "" {
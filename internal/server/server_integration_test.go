@@ -6,17 +6,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"darkhold-go/internal/config"
 	"darkhold-go/internal/events"
+	"darkhold-go/internal/fakeagent"
 	browserfs "darkhold-go/internal/fs"
 )
 
@@ -38,113 +41,42 @@ func canUseLoopbackSockets() bool {
 	return true
 }
 
-func startIntegrationServer(t *testing.T) *integrationServer {
+// fakeAgentProcessEnv makes the test binary serve the built-in fake agent on
+// its stdio instead of running tests.
+const fakeAgentProcessEnv = "DARKHOLD_TEST_FAKE_AGENT_PROCESS"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fakeAgentProcessEnv) != "" {
+		os.Exit(serveFakeAgentProcess())
+	}
+	os.Exit(m.Run())
+}
+
+func serveFakeAgentProcess() int {
+	p := fakeagent.Start(fakeagent.Options{ApprovalRate: 1})
+	go func() {
+		_, _ = io.Copy(p.Stdin, os.Stdin)
+		_ = p.Stdin.Close()
+	}()
+	go func() { _, _ = io.Copy(os.Stderr, p.Stderr) }()
+	_, _ = io.Copy(os.Stdout, p.Stdout)
+	_ = p.Wait()
+	return max(p.ExitCode(), 0)
+}
+
+// withFakeAgentProcess runs the fake agent as a child process, for tests
+// that need a real process behind the session.
+func withFakeAgentProcess(t *testing.T) func(*config.Config) {
+	t.Setenv(fakeAgentProcessEnv, "1")
+	return func(cfg *config.Config) { cfg.AgentCmd = os.Args[0] }
+}
+
+func startIntegrationServer(t *testing.T, configure ...func(*config.Config)) *integrationServer {
 	t.Helper()
 	if !canUseLoopbackSockets() {
 		t.Skip("loopback sockets are not available in this environment")
 	}
 	baseDir := t.TempDir()
-	fakeBinDir := filepath.Join(baseDir, "bin")
-	if err := os.MkdirAll(fakeBinDir, 0o755); err != nil {
-		t.Fatal(err)
-	}
-	codexPath := filepath.Join(fakeBinDir, "codex")
-	codexScript := `#!/usr/bin/env node
-const readline = require('node:readline');
-let threadId = null;
-let cwd = '/tmp';
-let updatedAt = Math.floor(Date.now() / 1000);
-const turns = [];
-let turnCounter = 0;
-let initialized = false;
-let pendingApprovalRequestId = null;
-let pendingApprovalThreadId = null;
-let pendingApprovalTurnId = null;
-function send(obj) { process.stdout.write(JSON.stringify(obj) + '\n'); }
-if (process.argv[2] !== 'app-server') { process.exit(2); }
-const rl = readline.createInterface({ input: process.stdin, crlfDelay: Infinity });
-rl.on('line', (line) => {
-  let msg;
-  try { msg = JSON.parse(line); } catch { return; }
-  if (typeof msg.id === 'number' && typeof msg.method !== 'string') {
-    if (pendingApprovalRequestId !== null && msg.id === pendingApprovalRequestId) {
-      const approvalThreadId = pendingApprovalThreadId || threadId || ('thread-' + process.pid);
-      const approvalTurnId = pendingApprovalTurnId || 'turn-' + (turnCounter || 1);
-      send({ method: 'item/agentMessage/delta', params: { threadId: approvalThreadId, turnId: approvalTurnId, delta: 'delta-from-' + process.pid } });
-      turns.push({
-        status: 'completed',
-        error: null,
-        items: [
-          { type: 'userMessage', content: [{ type: 'text', text: 'prompt' }] },
-          { type: 'agentMessage', text: 'response-' + process.pid },
-        ],
-      });
-      updatedAt = Math.floor(Date.now() / 1000);
-      send({ method: 'turn/completed', params: { threadId: approvalThreadId, turnId: approvalTurnId, turn: { id: approvalTurnId, status: 'completed', error: null } } });
-      pendingApprovalRequestId = null;
-      pendingApprovalThreadId = null;
-      pendingApprovalTurnId = null;
-    }
-    return;
-  }
-  if (typeof msg.method !== 'string') { return; }
-  const id = msg.id;
-  const p = msg.params || {};
-  if (msg.method === 'initialize') {
-    if (initialized) {
-      send({ id, error: { message: 'Already initialized' } });
-      return;
-    }
-    initialized = true;
-    send({ id, result: {} });
-    return;
-  }
-  if (msg.method === 'thread/start') {
-    threadId = threadId || ('thread-' + process.pid);
-    cwd = typeof p.cwd === 'string' ? p.cwd : cwd;
-    updatedAt = Math.floor(Date.now() / 1000);
-    send({ id, result: { thread: { id: threadId, cwd, updatedAt } } });
-    return;
-  }
-  if (msg.method === 'thread/list') {
-    const data = threadId ? [{ id: threadId, cwd, updatedAt }] : [];
-    send({ id, result: { data } });
-    return;
-  }
-  if (msg.method === 'thread/read' || msg.method === 'thread/resume') {
-    const requestedId = typeof p.threadId === 'string' ? p.threadId : threadId;
-    send({ id, result: { thread: { id: requestedId || ('thread-' + process.pid), cwd, updatedAt, turns } } });
-    return;
-  }
-  if (msg.method === 'turn/start') {
-    turnCounter += 1;
-    const activeThreadId = typeof p.threadId === 'string' ? p.threadId : (threadId || ('thread-' + process.pid));
-    threadId = activeThreadId;
-    const turnId = 'turn-' + turnCounter;
-    send({ id, result: { ok: true } });
-    send({ method: 'turn/started', params: { threadId: activeThreadId, turnId, turn: { id: turnId, status: 'inProgress' } } });
-    pendingApprovalRequestId = 7000 + turnCounter;
-    pendingApprovalThreadId = activeThreadId;
-    pendingApprovalTurnId = turnId;
-    setTimeout(() => {
-      if (pendingApprovalRequestId !== null) {
-        send({
-          id: pendingApprovalRequestId,
-          method: 'execCommandApproval',
-          params: { threadId: activeThreadId, command: 'echo from-fake-codex' },
-        });
-      }
-    }, 20);
-    return;
-  }
-  send({ id, result: {} });
-});
-`
-	if err := os.WriteFile(codexPath, []byte(codexScript), 0o755); err != nil {
-		t.Fatal(err)
-	}
-
-	t.Setenv("PATH", fakeBinDir+":"+os.Getenv("PATH"))
 	if _, err := browserfs.SetBrowserRoot(baseDir); err != nil {
 		t.Fatal(err)
 	}
@@ -154,7 +86,13 @@ rl.on('line', (line) => {
 		t.Fatal(err)
 	}
 	store := events.NewStore(eventRoot)
-	app := New(config.Config{Bind: "127.0.0.1", Port: 0}, store)
+	// The built-in fake agent asks for a command approval on every turn
+	// unless a test turns FakeApprovalRate down.
+	cfg := config.Config{Bind: "127.0.0.1", Port: 0, AgentCmd: config.FakeAgentCmd, FakeApprovalRate: 1}
+	for _, fn := range configure {
+		fn(&cfg)
+	}
+	app := New(cfg, store)
 	httpSrv := httptest.NewServer(app.Handler())
 
 	return &integrationServer{t: t, baseDir: baseDir, store: store, app: app, http: httpSrv}
@@ -188,6 +126,7 @@ func postRPC[T any](t *testing.T, baseURL, method string, params any) T {
 
 type sseEvent struct {
 	ID   string
+	Type string
 	Data string
 }
 
@@ -213,12 +152,17 @@ func openSSE(t *testing.T, baseURL, threadID, lastEventID string) *http.Response
 	return resp
 }
 
+// sseScanners keeps one scanner per stream, so events a scanner has already
+// buffered are not lost to the next waitForSSEEvent on the same stream.
+var sseScanners sync.Map
+
 func waitForSSEEvent(t *testing.T, resp *http.Response, predicate func(sseEvent) bool, timeout time.Duration) sseEvent {
 	t.Helper()
-	scanner := bufio.NewScanner(resp.Body)
+	stored, _ := sseScanners.LoadOrStore(resp, bufio.NewScanner(resp.Body))
+	scanner := stored.(*bufio.Scanner)
 	deadline := time.Now().Add(timeout)
 	for time.Now().Before(deadline) {
-		id := ""
+		id, eventType := "", ""
 		dataLines := []string{}
 		for scanner.Scan() {
 			line := scanner.Text()
@@ -228,17 +172,20 @@ func waitForSSEEvent(t *testing.T, resp *http.Response, predicate func(sseEvent)
 			if strings.HasPrefix(line, ":") {
 				continue
 			}
-			if strings.HasPrefix(line, "id:") {
-				id = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+			if after, ok := strings.CutPrefix(line, "id:"); ok {
+				id = strings.TrimSpace(after)
 			}
-			if strings.HasPrefix(line, "data:") {
-				dataLines = append(dataLines, strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+			if after, ok := strings.CutPrefix(line, "event:"); ok {
+				eventType = strings.TrimSpace(after)
+			}
+			if after, ok := strings.CutPrefix(line, "data:"); ok {
+				dataLines = append(dataLines, strings.TrimSpace(after))
 			}
 		}
 		if len(dataLines) == 0 {
 			continue
 		}
-		event := sseEvent{ID: id, Data: strings.Join(dataLines, "\n")}
+		event := sseEvent{ID: id, Type: eventType, Data: strings.Join(dataLines, "\n")}
 		if predicate(event) {
 			return event
 		}
@@ -330,6 +277,11 @@ func TestRehydrateThreadEventCacheFromThreadRead(t *testing.T) {
 	if len(eventsAny) == 0 {
 		t.Fatal("expected events")
 	}
+	for _, event := range eventsAny {
+		if raw, _ := event.(string); strings.Contains(raw, `"synthesized":true`) {
+			t.Fatalf("turn already streamed live should not be backfilled: %v", event)
+		}
+	}
 }
 
 func TestBroadcastsThreadEventsToMultipleSSEClientsAndReconnect(t *testing.T) {
@@ -351,10 +303,10 @@ func TestBroadcastsThreadEventsToMultipleSSEClientsAndReconnect(t *testing.T) {
 	delta2 := waitForSSEEvent(t, sse2, func(event sseEvent) bool {
 		return parseJSON(t, event.Data)["method"] == "item/agentMessage/delta"
 	}, 10*time.Second)
-	if !strings.Contains(parseJSON(t, delta1.Data)["params"].(map[string]any)["delta"].(string), "delta-from-") {
+	if !strings.Contains(parseJSON(t, delta1.Data)["params"].(map[string]any)["delta"].(string), "fake") {
 		t.Fatal("missing delta in client 1")
 	}
-	if !strings.Contains(parseJSON(t, delta2.Data)["params"].(map[string]any)["delta"].(string), "delta-from-") {
+	if !strings.Contains(parseJSON(t, delta2.Data)["params"].(map[string]any)["delta"].(string), "fake") {
 		t.Fatal("missing delta in client 2")
 	}
 
@@ -377,15 +329,15 @@ func TestAllowsTurnStartFromSeparateHTTPCallersOnSameThread(t *testing.T) {
 	defer sse.Body.Close()
 
 	first := postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "first"}}})
-	if ok, _ := first["ok"].(bool); !ok {
-		t.Fatal("first turn/start did not return ok")
+	if first["turn"] == nil {
+		t.Fatalf("first turn/start did not start a turn: %v", first)
 	}
 	acceptNextApproval(t, s.http.URL, threadID, sse)
 	_ = waitForSSEEvent(t, sse, func(event sseEvent) bool { return parseJSON(t, event.Data)["method"] == "turn/completed" }, 10*time.Second)
 
 	second := postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "second"}}})
-	if ok, _ := second["ok"].(bool); !ok {
-		t.Fatal("second turn/start did not return ok")
+	if second["turn"] == nil {
+		t.Fatalf("second turn/start did not start a turn: %v", second)
 	}
 	acceptNextApproval(t, s.http.URL, threadID, sse)
 	_ = waitForSSEEvent(t, sse, func(event sseEvent) bool { return parseJSON(t, event.Data)["method"] == "turn/completed" }, 10*time.Second)
@@ -421,8 +373,8 @@ func TestReapsIdleSessionAfterTurnCompletion(t *testing.T) {
 		"threadId": threadID,
 		"input":    []any{map[string]any{"type": "text", "text": "after reap"}},
 	})
-	if ok, _ := afterReap["ok"].(bool); !ok {
-		t.Fatal("turn/start after reap did not return ok")
+	if afterReap["turn"] == nil {
+		t.Fatalf("turn/start after reap did not start a turn: %v", afterReap)
 	}
 }
 
@@ -495,6 +447,51 @@ func TestSSEResumeWithLastEventID(t *testing.T) {
 	}, 10*time.Second)
 }
 
+func TestSSEResumeAcrossEventIDMigration(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	// A log with plain integer IDs, where "10" sorts before "9", and a bare
+	// payload line from before records had IDs.
+	legacy := `{"id":"9","payload":"{\"method\":\"nine\"}"}` + "\n" +
+		`{"id":"10","payload":"{\"method\":\"ten\"}"}` + "\n" +
+		`{"method":"bare"}` + "\n"
+	if err := os.WriteFile(filepath.Join(s.store.RootDir, "thread-legacy.jsonl"), []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if migrated, err := s.store.MigrateIDs(); err != nil || migrated != 1 {
+		t.Fatalf("unexpected migration: %d %v", migrated, err)
+	}
+
+	methodOf := func(event sseEvent) string {
+		method, _ := parseJSON(t, event.Data)["method"].(string)
+		return method
+	}
+	for cursor, want := range map[string]string{"9": "ten", "10": "bare"} {
+		stream := openSSE(t, s.http.URL, "thread-legacy", cursor)
+		first := waitForSSEEvent(t, stream, func(sseEvent) bool { return true }, 5*time.Second)
+		stream.Body.Close()
+		if methodOf(first) != want {
+			t.Fatalf("expected resuming after %q to start at %s, got %s", cursor, want, first.Data)
+		}
+	}
+
+	// A client caught up to the migrated log resumes with new IDs.
+	stream := openSSE(t, s.http.URL, "thread-legacy", "10")
+	defer stream.Body.Close()
+	bare := waitForSSEEvent(t, stream, func(sseEvent) bool { return true }, 5*time.Second)
+	s.app.publishThreadEvent("thread-legacy", `{"method":"live"}`)
+	live := waitForSSEEvent(t, stream, func(event sseEvent) bool { return methodOf(event) == "live" }, 5*time.Second)
+	if live.ID <= bare.ID {
+		t.Fatalf("expected live IDs after migrated ones, got %s then %s", bare.ID, live.ID)
+	}
+	resumed := openSSE(t, s.http.URL, "thread-legacy", bare.ID)
+	defer resumed.Body.Close()
+	if next := waitForSSEEvent(t, resumed, func(sseEvent) bool { return true }, 5*time.Second); next.ID != live.ID {
+		t.Fatalf("expected to resume at the live event, got %s", next.Data)
+	}
+}
+
 func TestHTTPRPCValidation(t *testing.T) {
 	s := startIntegrationServer(t)
 	defer s.close()
@@ -827,3 +824,115 @@ func TestWebIndexRoute(t *testing.T) {
 	_, _ = resp.Body.Read(buf)
 	_ = fmt.Sprintf("%s", string(buf))
 }
+
+func TestErrorResponsesIncludeStableCodes(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	cases := []struct {
+		name   string
+		do     func() (*http.Response, error)
+		status int
+		code   string
+	}{
+		{"unknown api route", func() (*http.Response, error) { return http.Get(s.http.URL + "/api/missing") }, http.StatusNotFound, errCodeNotFound},
+		{"invalid json", func() (*http.Response, error) {
+			return http.Post(s.http.URL+"/api/rpc", "application/json", strings.NewReader(`{`))
+		}, http.StatusBadRequest, errCodeInvalidJSON},
+		{"resolved interaction", func() (*http.Response, error) {
+			return http.Post(s.http.URL+"/api/thread/interaction/respond", "application/json", strings.NewReader(`{"threadId":"a","requestId":"b","result":{}}`))
+		}, http.StatusConflict, errCodeInteractionResolved},
+		{"method not allowed", func() (*http.Response, error) {
+			return http.Post(s.http.URL+"/api/health", "application/json", nil)
+		}, http.StatusMethodNotAllowed, errCodeMethodNotAllowed},
+	}
+	for _, tc := range cases {
+		resp, err := tc.do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var payload map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&payload)
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Fatalf("%s: expected %d, got %d", tc.name, tc.status, resp.StatusCode)
+		}
+		if payload["code"] != tc.code {
+			t.Fatalf("%s: expected code %s, got %v", tc.name, tc.code, payload["code"])
+		}
+		if message, _ := payload["error"].(string); message == "" {
+			t.Fatalf("%s: expected error message", tc.name)
+		}
+	}
+}
+
+func TestThreadEventsIncludeRecordStamps(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	s.app.publishThreadEvent("thread-stamps", `{"method":"darkhold/test","params":{}}`)
+	s.app.publishThreadEvent("thread-stamps", `{"method":"darkhold/test","params":{}}`)
+
+	resp, payload := doJSON(t, http.MethodGet, s.http.URL+"/api/thread/events?threadId=thread-stamps&records=true", nil)
+	records, _ := payload["records"].([]any)
+	if resp.StatusCode != http.StatusOK || len(records) != 2 {
+		t.Fatalf("unexpected response: %d %v", resp.StatusCode, payload)
+	}
+	first, second := records[0].(map[string]any), records[1].(map[string]any)
+	if second["seq"].(float64) != first["seq"].(float64)+1 || first["ts"].(float64) == 0 || first["id"] == "" {
+		t.Fatalf("unexpected stamps: %v", records)
+	}
+
+	_, payload = doJSON(t, http.MethodGet, s.http.URL+"/api/thread/events?threadId=thread-stamps", nil)
+	if _, ok := payload["records"]; ok {
+		t.Fatalf("records should be opt-in: %v", payload)
+	}
+}
+
+func TestAgentMessagesAreNormalizedToEventSchema(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+	s.app.eventSchema = events.NewTranslator([]events.Shim{{Name: "renamed-delta", Method: "item/agentMessage/chunk", Rename: "item/agentMessage/delta"}})
+
+	sess, _ := injectSession(s.app, 42)
+	defer removeSession(s.app, sess)
+	s.app.handleSessionLine(sess, `{"method":"item/agentMessage/chunk","params":{"threadId":"thread-shim","itemId":"m1","delta":"hi"}}`)
+
+	stored, _ := s.store.Read("thread-shim")
+	if len(stored) != 1 || !strings.Contains(stored[0], `"method":"item/agentMessage/delta"`) || !strings.Contains(stored[0], `"upstreamMethod":"item/agentMessage/chunk"`) {
+		t.Fatalf("expected a normalized event, got %v", stored)
+	}
+
+	_, health := doJSON(t, http.MethodGet, s.http.URL+"/api/health", nil)
+	schema, _ := health["eventSchema"].(map[string]any)
+	if schema["version"] != float64(events.SchemaVersion) || len(schema["shims"].([]any)) != 1 {
+		t.Fatalf("unexpected eventSchema in health: %v", health)
+	}
+	stream := openSSE(t, s.http.URL, "thread-shim", "")
+	defer stream.Body.Close()
+	if stream.Header.Get(eventSchemaHeader) != "1" {
+		t.Fatalf("expected %s on the event stream, got %q", eventSchemaHeader, stream.Header.Get(eventSchemaHeader))
+	}
+}
+
+func TestInitializeParamsApplyConfiguredOverrides(t *testing.T) {
+	s := &Server{cfg: config.Config{
+		AgentClientName:   "acme-darkhold",
+		AgentCapabilities: map[string]any{"experimentalApi": false, "optOutNotificationMethods": []any{"item/reasoning/textDelta"}},
+		AgentInitParams:   map[string]any{"clientInfo": map[string]any{"version": "9.9.9"}, "profile": "ci"},
+	}}
+	params := s.initializeParams()
+	clientInfo := params["clientInfo"].(map[string]any)
+	capabilities := params["capabilities"].(map[string]any)
+	if clientInfo["name"] != "acme-darkhold" || clientInfo["title"] != "Darkhold Go" || clientInfo["version"] != "9.9.9" {
+		t.Fatalf("unexpected clientInfo %v", clientInfo)
+	}
+	if capabilities["experimentalApi"] != false || capabilities["optOutNotificationMethods"] == nil || params["profile"] != "ci" {
+		t.Fatalf("unexpected initialize params %v", params)
+	}
+
+	defaults := (&Server{}).initializeParams()
+	if defaults["capabilities"].(map[string]any)["experimentalApi"] != true {
+		t.Fatalf("expected experimentalApi by default, got %v", defaults)
+	}
+}
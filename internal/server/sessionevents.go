@@ -0,0 +1,255 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Session lifecycle states. A session moves forward only:
+//
+//	spawned -> initialized -> unhealthy | reaped -> exited
+//
+// unhealthy and reaped may also follow spawned directly, and any state may
+// go straight to exited when the agent dies on its own.
+const (
+	sessionStateSpawned     = "spawned"
+	sessionStateInitialized = "initialized"
+	sessionStateUnhealthy   = "unhealthy"
+	sessionStateReaped      = "reaped"
+	sessionStateExited      = "exited"
+
+	// sessionEventHistory is how many lifecycle events are kept, in memory
+	// and in <data-dir>/session-events.jsonl.
+	sessionEventHistory = 500
+)
+
+var sessionStateOrder = map[string]int{
+	sessionStateSpawned:     0,
+	sessionStateInitialized: 1,
+	sessionStateUnhealthy:   2,
+	sessionStateReaped:      2,
+	sessionStateExited:      3,
+}
+
+// sessionEvents keeps the recent darkhold/session/* events in a lineRing,
+// one {method, params} payload per line, and mirrors them to a JSONL file
+// so the history survives restarts. Without a data dir nothing is written.
+type sessionEvents struct {
+	ring *lineRing
+
+	mu      sync.Mutex
+	path    string
+	written int // lines in the file; it is rewritten at twice the history
+}
+
+func openSessionEvents(dataDir string) *sessionEvents {
+	e := &sessionEvents{ring: newLineRing(sessionEventHistory)}
+	if dataDir == "" {
+		return e
+	}
+	e.path = filepath.Join(dataDir, "session-events.jsonl")
+	f, err := os.Open(e.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[session-events] failed to read history: %v", err)
+		}
+		return e
+	}
+	defer f.Close()
+	var lines []logLine
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var line logLine
+		if json.Unmarshal(scanner.Bytes(), &line) == nil && line.Seq > 0 {
+			lines = append(lines, line)
+		}
+	}
+	e.written = len(lines)
+	e.ring.restore(lines)
+	return e
+}
+
+// lastSessionID is the highest session ID in the history.
+func (e *sessionEvents) lastSessionID() int {
+	last := 0
+	for _, line := range e.ring.snapshot(0) {
+		var event struct {
+			Params struct {
+				SessionID int `json:"sessionId"`
+			} `json:"params"`
+		}
+		if json.Unmarshal([]byte(line.Text), &event) == nil {
+			last = max(last, event.Params.SessionID)
+		}
+	}
+	return last
+}
+
+// append stores one event and hands it to live subscribers.
+func (e *sessionEvents) append(payload string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	line := e.ring.append(payload)
+	if e.path == "" {
+		return
+	}
+	if e.written >= 2*sessionEventHistory {
+		e.rewrite()
+		return
+	}
+	encoded, _ := json.Marshal(line)
+	f, err := os.OpenFile(e.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err == nil {
+		_, err = f.Write(append(encoded, '\n'))
+		f.Close()
+	}
+	if err != nil {
+		log.Printf("[session-events] failed to record event: %v", err)
+		return
+	}
+	e.written++
+}
+
+// rewrite replaces the file with the events still in the ring.
+func (e *sessionEvents) rewrite() {
+	lines := e.ring.snapshot(0)
+	var buf strings.Builder
+	for _, line := range lines {
+		encoded, _ := json.Marshal(line)
+		buf.Write(encoded)
+		buf.WriteByte('\n')
+	}
+	tmp := e.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(buf.String()), 0o600); err != nil {
+		log.Printf("[session-events] failed to compact history: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, e.path); err != nil {
+		log.Printf("[session-events] failed to compact history: %v", err)
+		return
+	}
+	e.written = len(lines)
+}
+
+// setSessionState moves sess to state and publishes darkhold/session/<state>
+// with params plus sessionId, pid and the thread IDs bound to it. Moves
+// backwards or to the current state are ignored, so racing observers (a
+// failover and the exit it causes) publish each state once.
+func (s *Server) setSessionState(sess *session, state string, params map[string]any) {
+	sess.mu.Lock()
+	if sess.state != "" && sessionStateOrder[state] <= sessionStateOrder[sess.state] {
+		sess.mu.Unlock()
+		return
+	}
+	previous := sess.state
+	sess.state = state
+	threadIDs := make([]string, 0, len(sess.knownThreadIDs))
+	for threadID := range sess.knownThreadIDs {
+		threadIDs = append(threadIDs, threadID)
+	}
+	sess.mu.Unlock()
+	sort.Strings(threadIDs)
+
+	event := map[string]any{"sessionId": sess.id, "state": state, "threadIds": threadIDs}
+	if previous != "" {
+		event["previousState"] = previous
+	}
+	if sess.proc != nil {
+		event["pid"] = sess.proc.Pid()
+	}
+	maps.Copy(event, params)
+	method := "darkhold/session/" + state
+	payload, _ := json.Marshal(map[string]any{"method": method, "params": event})
+	s.sessionEvents.append(string(payload))
+	s.publishServerEvent(method, event)
+}
+
+// sessionEventView is one entry of GET /api/sessions/events.
+type sessionEventView struct {
+	Seq    int64          `json:"seq"`
+	Time   int64          `json:"ts"`
+	Method string         `json:"method"`
+	Params map[string]any `json:"params"`
+}
+
+// handleSessionEvents lists the recent lifecycle events, oldest first:
+//
+//	GET ?sessionId=&threadId=&after=
+//
+// threadId keeps the events of sessions the thread was bound to, which is
+// what a client needs to explain a SESSION_UNAVAILABLE error.
+func (s *Server) handleSessionEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	query := r.URL.Query()
+	sessionID := 0
+	if raw := strings.TrimSpace(query.Get("sessionId")); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil || id <= 0 {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "sessionId must be a positive integer.")
+			return
+		}
+		sessionID = id
+	}
+	after, _ := strconv.ParseInt(query.Get("after"), 10, 64)
+	threadID := strings.TrimSpace(query.Get("threadId"))
+
+	lines := s.sessionEvents.ring.snapshot(after)
+	var views []sessionEventView
+	threadSessions := map[float64]bool{}
+	for _, line := range lines {
+		var event struct {
+			Method string         `json:"method"`
+			Params map[string]any `json:"params"`
+		}
+		if json.Unmarshal([]byte(line.Text), &event) != nil {
+			continue
+		}
+		id, _ := event.Params["sessionId"].(float64)
+		if threadID != "" {
+			threadIDs, _ := event.Params["threadIds"].([]any)
+			for _, bound := range threadIDs {
+				if bound == threadID {
+					threadSessions[id] = true
+				}
+			}
+		}
+		views = append(views, sessionEventView{Seq: line.Seq, Time: line.Time, Method: event.Method, Params: event.Params})
+	}
+	out := make([]sessionEventView, 0, len(views))
+	for _, view := range views {
+		id, _ := view.Params["sessionId"].(float64)
+		if sessionID > 0 && int(id) != sessionID {
+			continue
+		}
+		// A thread binds after spawn, so the events of any session it
+		// was bound to at some point all count.
+		if threadID != "" && !threadSessions[id] {
+			continue
+		}
+		out = append(out, view)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"events": out})
+}
+
+// handleSessionEventsStream replays the lifecycle events after Last-Event-ID
+// (or ?after=) and streams new ones.
+func (s *Server) handleSessionEventsStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	streamLineRing(w, r, s.sessionEvents.ring, func(line logLine) string { return line.Text })
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestSessionLifecycleEvents(t *testing.T) {
+	dataDir := t.TempDir()
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.FakeApprovalRate = 0
+		cfg.DataDir = dataDir
+	})
+	defer s.close()
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	sess := s.app.lookupSession(1)
+	s.app.failoverSession(sess, "unresponsive")
+	waitForCondition(t, 5*time.Second, 10*time.Millisecond, func() bool {
+		_, body := doJSON(t, http.MethodGet, s.http.URL+"/api/sessions/events?sessionId=1", nil)
+		events, _ := body["events"].([]any)
+		return len(events) == 4
+	})
+
+	_, body := doJSON(t, http.MethodGet, s.http.URL+"/api/sessions/events?threadId="+threadID, nil)
+	var methods []string
+	for _, raw := range body["events"].([]any) {
+		event := raw.(map[string]any)
+		methods = append(methods, event["method"].(string))
+		params := event["params"].(map[string]any)
+		switch event["method"] {
+		case "darkhold/session/unhealthy":
+			if params["reason"] != "unresponsive" || params["previousState"] != sessionStateInitialized {
+				t.Fatalf("unexpected unhealthy event: %v", params)
+			}
+		case "darkhold/session/exited":
+			if params["crashed"] != true || params["threadIds"].([]any)[0] != threadID {
+				t.Fatalf("unexpected exit event: %v", params)
+			}
+		}
+	}
+	want := []string{"darkhold/session/spawned", "darkhold/session/initialized", "darkhold/session/unhealthy", "darkhold/session/exited"}
+	if len(methods) != len(want) {
+		t.Fatalf("expected %v for the thread's session, got %v", want, methods)
+	}
+	for i := range want {
+		if methods[i] != want[i] {
+			t.Fatalf("expected %v for the thread's session, got %v", want, methods)
+		}
+	}
+	if _, body := doJSON(t, http.MethodGet, s.http.URL+"/api/sessions/events?threadId=other", nil); len(body["events"].([]any)) != 0 {
+		t.Fatalf("expected no events for an unknown thread, got %v", body)
+	}
+	if resp, _ := doJSON(t, http.MethodGet, s.http.URL+"/api/sessions/events?sessionId=x", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad sessionId, got %d", resp.StatusCode)
+	}
+
+	// The history is reloaded on restart and session IDs carry on from it.
+	reopened := openSessionEvents(dataDir)
+	if lines := reopened.ring.snapshot(0); len(lines) < 4 || lines[0].Seq != 1 {
+		t.Fatalf("expected the persisted history, got %v", lines)
+	}
+	if last := reopened.lastSessionID(); last < 1 {
+		t.Fatalf("expected the last session ID, got %d", last)
+	}
+}
+
+func TestSessionEventsCompactHistory(t *testing.T) {
+	dataDir := t.TempDir()
+	e := openSessionEvents(dataDir)
+	for i := range 2*sessionEventHistory + 10 {
+		e.append(`{"method":"darkhold/session/spawned","params":{"sessionId":` + strconv.Itoa(i+1) + `}}`)
+	}
+	reopened := openSessionEvents(dataDir)
+	lines := reopened.ring.snapshot(0)
+	if len(lines) != sessionEventHistory || lines[len(lines)-1].Seq != 2*sessionEventHistory+10 {
+		t.Fatalf("expected the newest %d events, got %d ending at %d", sessionEventHistory, len(lines), lines[len(lines)-1].Seq)
+	}
+	if reopened.written > 2*sessionEventHistory || reopened.lastSessionID() != 2*sessionEventHistory+10 {
+		t.Fatalf("expected a compacted file, got %d lines, last session %d", reopened.written, reopened.lastSessionID())
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dataDir, "*.tmp")); len(matches) != 0 {
+		t.Fatalf("expected no temporary files, got %v", matches)
+	}
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	// sessionPingMethod is sent to check that a session still answers. The
+	// agent has no ping method of its own; any response, including a
+	// method-not-found error, shows its request loop is alive.
+	sessionPingMethod = "darkhold/ping"
+	// sessionPingMisses is how many pings in a row a session may miss
+	// before it is declared hung.
+	sessionPingMisses = 2
+)
+
+// sessionHealthMonitor pings every live session each --session-ping-interval
+// and fails over the ones that stopped answering while their process kept
+// running, which would otherwise only show up as RPC timeouts.
+func (s *Server) sessionHealthMonitor() {
+	for {
+		select {
+		case <-s.reaperStop:
+			return
+		case <-time.After(s.cfg.SessionPingInterval):
+		}
+		s.sessionsMu.RLock()
+		sessions := make([]*session, 0, len(s.sessions))
+		for _, sess := range s.sessions {
+			sessions = append(sessions, sess)
+		}
+		s.sessionsMu.RUnlock()
+		// Concurrently, so a hung session doesn't delay the others' pings.
+		for _, sess := range sessions {
+			go s.pingSession(sess)
+		}
+	}
+}
+
+// pingSession sends one ping unless one is already outstanding, and fails
+// the session over once it has missed sessionPingMisses in a row.
+func (s *Server) pingSession(sess *session) {
+	sess.mu.Lock()
+	if sess.closed || sess.stopRequested || sess.unhealthy || sess.pinging {
+		sess.mu.Unlock()
+		return
+	}
+	sess.pinging = true
+	sess.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.SessionPingTimeout)
+	_, err := s.callSessionRPC(ctx, sess, sessionPingMethod, map[string]any{})
+	cancel()
+
+	sess.mu.Lock()
+	sess.pinging = false
+	switch {
+	case err == nil:
+		sess.pingMisses = 0
+		sess.lastPingAt = time.Now()
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, errRPCTimeout):
+		sess.pingMisses++
+	}
+	hung := sess.pingMisses >= sessionPingMisses
+	misses := sess.pingMisses
+	sess.mu.Unlock()
+	if hung {
+		log.Printf("[session=%d] missed %d health pings; failing over", sess.id, misses)
+		s.failoverSession(sess, "unresponsive")
+	}
+}
+
+// hasLiveSession reports whether any session can take new work.
+func (s *Server) hasLiveSession() bool {
+	s.sessionsMu.RLock()
+	defer s.sessionsMu.RUnlock()
+	for _, sess := range s.sessions {
+		if _, alive := sess.load(); alive {
+			return true
+		}
+	}
+	return false
+}
+
+// failoverSession takes a hung session out of routing, fails its pending
+// RPCs with errSessionUnhealthy instead of letting them time out, and kills
+// it. The exit is handled as a crash, so running turns go through their
+// retry policy. A replacement is started when the session had threads bound
+// to it and no other session is alive.
+func (s *Server) failoverSession(sess *session, reason string) {
+	sess.mu.Lock()
+	if sess.closed || sess.unhealthy {
+		sess.mu.Unlock()
+		return
+	}
+	sess.unhealthy = true
+	pending := sess.pending
+	sess.pending = map[int64]chan map[string]any{}
+	hadThreads := len(sess.knownThreadIDs) > 0
+	sess.mu.Unlock()
+	s.setSessionState(sess, sessionStateUnhealthy, map[string]any{"reason": reason})
+
+	for _, ch := range pending {
+		close(ch)
+	}
+	if sess.proc != nil {
+		if err := sess.proc.Kill(); err != nil {
+			log.Printf("[session=%d] failed to kill hung session: %v", sess.id, err)
+		}
+	}
+
+	if !hadThreads || s.hasLiveSession() {
+		return
+	}
+	replacement, err := s.spawnSession()
+	if err != nil {
+		log.Printf("[session=%d] failed to start a replacement: %v", sess.id, err)
+		return
+	}
+	s.recordRouting(routingDecision{SessionID: replacement.id, Reason: "failover"})
+	if err := s.ensureInitialized(replacement); err != nil {
+		log.Printf("[session=%d] replacement for session %d failed to initialize: %v", replacement.id, sess.id, err)
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+	"darkhold-go/internal/fakeagent"
+)
+
+func TestHungSessionIsFailedOver(t *testing.T) {
+	hang := filepath.Join(t.TempDir(), "hang")
+	t.Setenv(fakeagent.HangFileEnv, hang)
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.SessionPingInterval = 50 * time.Millisecond
+		cfg.SessionPingTimeout = 100 * time.Millisecond
+	})
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	s.app.sessionsMu.RLock()
+	sess := s.app.sessions[s.app.threadToSession[threadID]]
+	s.app.sessionsMu.RUnlock()
+	active := sess.info().LastActivityAt
+	waitForCondition(t, 5*time.Second, 10*time.Millisecond, func() bool { return sess.info().LastPingAt > 0 })
+	if sess.info().LastActivityAt != active {
+		t.Fatal("pings must not count as session activity, or idle sessions are never reaped")
+	}
+
+	if err := os.WriteFile(hang, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stuck := make(chan error, 1)
+	go func() {
+		_, err := s.app.callSessionRPC(context.Background(), sess, sessionPingMethod, map[string]any{})
+		stuck <- err
+	}()
+	select {
+	case err := <-stuck:
+		if !errors.Is(err, errSessionUnhealthy) {
+			t.Fatalf("expected the pending RPC to fail as unhealthy, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the pending RPC to fail fast once the session was declared hung")
+	}
+	_ = os.Remove(hang)
+
+	waitForCondition(t, 5*time.Second, 10*time.Millisecond, func() bool {
+		s.app.sessionsMu.RLock()
+		defer s.app.sessionsMu.RUnlock()
+		_, stillThere := s.app.sessions[sess.id]
+		return !stillThere && len(s.app.sessions) == 1
+	})
+	if info := sess.info(); !info.Unhealthy || info.Alive {
+		t.Fatalf("expected the hung session to be marked unhealthy and gone, got %+v", info)
+	}
+	routing := s.app.recentRouting()
+	if len(routing) == 0 || routing[0].Reason != "failover" {
+		t.Fatalf("expected a failover replacement, got %+v", routing)
+	}
+	listed := postRPC[map[string]any](t, s.http.URL, "thread/resume", map[string]any{"threadId": threadID})
+	if listed["thread"] == nil {
+		t.Fatalf("expected the thread to resume on the replacement, got %v", listed)
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+type keepaliveRPCKey struct{}
+
+// isKeepaliveRPC reports whether callSessionRPC is sending a keepalive,
+// which, like a health ping, is not session activity.
+func isKeepaliveRPC(ctx context.Context) bool {
+	keepalive, _ := ctx.Value(keepaliveRPCKey{}).(bool)
+	return keepalive
+}
+
+// pinnedLocked reports whether the idle reaper keeps the session however long
+// it has been quiet: a turn is running or an RPC is outstanding. Callers hold
+// sess.mu.
+func (sess *session) pinnedLocked() bool {
+	return len(sess.activeTurnIDs) > 0 || len(sess.pending) > 0
+}
+
+// keepSessionAlive sends --session-keepalive-method to a session the idle
+// reaper is keeping once nothing has gone to or come from it for
+// --session-keepalive-interval, so agent builds that exit without traffic
+// survive a long approval wait or a quiet turn. It runs from the reaper's
+// pass, after the reaper has decided to keep the session; keepalives are not
+// activity, so they never hold off reaping an unpinned session.
+func (s *Server) keepSessionAlive(sess *session, now time.Time) {
+	interval := s.cfg.SessionKeepaliveInterval
+	if interval <= 0 {
+		return
+	}
+	sess.mu.Lock()
+	quietSince := sess.lastActivityAt
+	if sess.lastKeepaliveAt.After(quietSince) {
+		quietSince = sess.lastKeepaliveAt
+	}
+	if sess.closed || sess.stopRequested || sess.unhealthy || sess.keepaliveSending || !sess.pinnedLocked() || now.Sub(quietSince) < interval {
+		sess.mu.Unlock()
+		return
+	}
+	sess.keepaliveSending = true
+	sess.lastKeepaliveAt = now
+	sess.mu.Unlock()
+
+	go func() {
+		defer s.recoverPanic("session-keepalive")
+		ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), keepaliveRPCKey{}, true), s.rpcTimeout)
+		_, err := s.callSessionRPC(ctx, sess, s.cfg.SessionKeepaliveMethod, map[string]any{})
+		cancel()
+		sess.mu.Lock()
+		sess.keepaliveSending = false
+		sess.keepalives++
+		sess.mu.Unlock()
+		// Any answer, even an error, is traffic; health pings deal with
+		// sessions that give none.
+		if err != nil && !errors.Is(err, errSessionUnavailable) {
+			log.Printf("[session=%d] keepalive %s: %v", sess.id, s.cfg.SessionKeepaliveMethod, err)
+		}
+	}()
+}
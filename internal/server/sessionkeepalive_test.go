@@ -0,0 +1,51 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestKeepalivesGoOnlyToQuietPinnedSessions(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.FakeApprovalRate = 0
+		cfg.SessionPingInterval = 0
+		cfg.SessionKeepaliveInterval = time.Minute
+		cfg.SessionKeepaliveMethod = "darkhold/keepalive"
+	})
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	s.app.sessionsMu.RLock()
+	sess := s.app.sessions[s.app.threadToSession[threadID]]
+	s.app.sessionsMu.RUnlock()
+	active := sess.info().LastActivityAt
+	later := time.Now().Add(2 * time.Minute)
+
+	s.app.keepSessionAlive(sess, later)
+	if info := sess.info(); info.KeepaliveAt != 0 {
+		t.Fatalf("expected no keepalive for a session the reaper may reap, got %+v", info)
+	}
+
+	sess.mu.Lock()
+	sess.activeTurnIDs["turn-quiet"] = threadID
+	sess.mu.Unlock()
+	defer func() {
+		sess.mu.Lock()
+		delete(sess.activeTurnIDs, "turn-quiet")
+		sess.mu.Unlock()
+	}()
+	s.app.keepSessionAlive(sess, time.Now())
+	if info := sess.info(); info.KeepaliveAt != 0 {
+		t.Fatalf("expected no keepalive before the session has been quiet long enough, got %+v", info)
+	}
+
+	s.app.keepSessionAlive(sess, later)
+	waitForCondition(t, 5*time.Second, 10*time.Millisecond, func() bool { return sess.info().Keepalives == 1 })
+	s.app.keepSessionAlive(sess, later.Add(time.Second))
+	if info := sess.info(); info.Keepalives != 1 || info.LastActivityAt != active {
+		t.Fatalf("expected one keepalive that is not activity, got %+v", info)
+	}
+}
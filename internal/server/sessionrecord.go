@@ -0,0 +1,293 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+const (
+	errCodeTranscriptNotFound = "TRANSCRIPT_NOT_FOUND"
+
+	// transcriptKeep is how many session transcripts stay on disk; older
+	// ones are removed as new sessions start.
+	transcriptKeep = 50
+	redactedValue  = "[REDACTED]"
+)
+
+var (
+	transcriptNamePattern = regexp.MustCompile(`^session-(\d+)-(\d+)\.jsonl$`)
+	// secretKeyPattern matches object keys whose string values are redacted.
+	// Only strings are touched, so token counts and usage objects survive.
+	secretKeyPattern = regexp.MustCompile(`(?i)(token|secret|password|passwd|api[_-]?key|authorization|cookie|credential|private[_-]?key)`)
+	// secretValuePattern matches credentials that show up anywhere in a
+	// string: bearer tokens and well-known key formats.
+	secretValuePattern = regexp.MustCompile(`(?i)\bbearer\s+[a-z0-9._~+/=-]{8,}|\b(sk-[A-Za-z0-9_-]{16,}|gh[pousr]_[A-Za-z0-9]{20,}|AKIA[0-9A-Z]{16}|xox[abprs]-[A-Za-z0-9-]{10,})`)
+)
+
+// sessionRecorder appends every line exchanged with one agent process to
+// <data-dir>/transcripts/session-<startedAtMs>-<id>.jsonl, one record per
+// line: {"ts", "dir": "send"|"recv", "message"} with the JSON message as
+// sent, or "line" when it was not valid JSON. The first record describes the
+// session; the last is {"ts", "truncated": true} once maxBytes is reached.
+type sessionRecorder struct {
+	name     string
+	redactor *transcriptRedactor
+
+	mu        sync.Mutex
+	file      *os.File
+	written   int64
+	maxBytes  int64
+	truncated bool
+}
+
+// startSessionRecording opens the transcript for a new session, or returns
+// nil when --record-sessions is off or the file cannot be created.
+func (s *Server) startSessionRecording(sess *session) *sessionRecorder {
+	if !s.cfg.RecordSessions || s.cfg.DataDir == "" {
+		return nil
+	}
+	dir := filepath.Join(s.cfg.DataDir, "transcripts")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		log.Printf("[transcripts] session %d not recorded: %v", sess.id, err)
+		return nil
+	}
+	pruneTranscripts(dir, transcriptKeep-1)
+	name := "session-" + strconv.FormatInt(sess.startedAt.UnixMilli(), 10) + "-" + strconv.Itoa(sess.id) + ".jsonl"
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		log.Printf("[transcripts] session %d not recorded: %v", sess.id, err)
+		return nil
+	}
+	rec := &sessionRecorder{name: name, redactor: s.transcriptRedactor, file: file, maxBytes: s.cfg.RecordSessionMaxBytes}
+	header := map[string]any{"session": sess.id, "command": rec.redactor.redactString(s.cfg.AgentCmd)}
+	if sess.proc != nil {
+		header["pid"] = sess.proc.Pid()
+	}
+	rec.write(header)
+	return rec
+}
+
+// record appends one line sent to ("send") or received from ("recv") the
+// agent. It is safe on a nil recorder.
+func (rec *sessionRecorder) record(dir, line string) {
+	if rec == nil {
+		return
+	}
+	entry := map[string]any{"dir": dir}
+	var message any
+	if err := json.Unmarshal([]byte(line), &message); err == nil {
+		entry["message"] = rec.redactor.redact(message)
+	} else {
+		entry["line"] = rec.redactor.redactString(line)
+	}
+	rec.write(entry)
+}
+
+func (rec *sessionRecorder) write(entry map[string]any) {
+	entry["ts"] = time.Now().UnixMilli()
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	encoded = append(encoded, '\n')
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.file == nil || rec.truncated {
+		return
+	}
+	if rec.maxBytes > 0 && rec.written+int64(len(encoded)) > rec.maxBytes {
+		rec.truncated = true
+		marker, _ := json.Marshal(map[string]any{"ts": entry["ts"], "truncated": true})
+		_, _ = rec.file.Write(append(marker, '\n'))
+		return
+	}
+	n, err := rec.file.Write(encoded)
+	rec.written += int64(n)
+	if err != nil {
+		log.Printf("[transcripts] %s: %v; recording stopped", rec.name, err)
+		rec.truncated = true
+	}
+}
+
+// close ends the transcript when the session exits. Safe on nil.
+func (rec *sessionRecorder) close() {
+	if rec == nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.file != nil {
+		_ = rec.file.Close()
+		rec.file = nil
+	}
+}
+
+// transcriptRedactor strips credentials from recorded messages: string
+// values under secret-looking keys, known credential formats, and the
+// secrets darkhold itself was configured with.
+type transcriptRedactor struct {
+	known []string
+}
+
+func newTranscriptRedactor(known ...string) *transcriptRedactor {
+	r := &transcriptRedactor{}
+	for _, secret := range known {
+		// Short values would redact unrelated text.
+		if len(secret) >= 6 {
+			r.known = append(r.known, secret)
+		}
+	}
+	return r
+}
+
+// configuredSecretsRedactor redacts the credentials darkhold was started
+// with, in case an agent echoes them back.
+func configuredSecretsRedactor(cfg config.Config) *transcriptRedactor {
+	known := []string{cfg.S3AccessKey, cfg.S3SecretKey, cfg.MQTTPassword}
+	for _, key := range cfg.APIKeys {
+		known = append(known, key.Token)
+	}
+	return newTranscriptRedactor(known...)
+}
+
+func (r *transcriptRedactor) redact(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if _, isString := item.(string); isString && secretKeyPattern.MatchString(key) {
+				v[key] = redactedValue
+				continue
+			}
+			v[key] = r.redact(item)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = r.redact(item)
+		}
+		return v
+	case string:
+		return r.redactString(v)
+	}
+	return value
+}
+
+func (r *transcriptRedactor) redactString(value string) string {
+	for _, secret := range r.known {
+		value = strings.ReplaceAll(value, secret, redactedValue)
+	}
+	return secretValuePattern.ReplaceAllString(value, redactedValue)
+}
+
+// pruneTranscripts removes the oldest transcripts beyond keep.
+func pruneTranscripts(dir string, keep int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if transcriptNamePattern.MatchString(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	if len(names) <= keep {
+		return
+	}
+	sortTranscriptNames(names)
+	for _, name := range names[keep:] {
+		_ = os.Remove(filepath.Join(dir, name))
+	}
+}
+
+// sortTranscriptNames orders transcripts newest first.
+func sortTranscriptNames(names []string) {
+	started := func(name string) int64 {
+		match := transcriptNamePattern.FindStringSubmatch(name)
+		ms, _ := strconv.ParseInt(match[1], 10, 64)
+		return ms
+	}
+	sort.Slice(names, func(i, j int) bool { return started(names[i]) > started(names[j]) })
+}
+
+type transcriptInfo struct {
+	Name      string `json:"name"`
+	SessionID int    `json:"sessionId"`
+	StartedAt int64  `json:"startedAt"`
+	Size      int64  `json:"size"`
+	Live      bool   `json:"live"`
+}
+
+// handleAdminTranscripts lists recorded session transcripts, newest first.
+func (s *Server) handleAdminTranscripts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	live := map[string]bool{}
+	s.sessionsMu.RLock()
+	for _, sess := range s.sessions {
+		if sess.recorder != nil {
+			live[sess.recorder.name] = true
+		}
+	}
+	s.sessionsMu.RUnlock()
+
+	dir := filepath.Join(s.cfg.DataDir, "transcripts")
+	entries, _ := os.ReadDir(dir)
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if transcriptNamePattern.MatchString(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	sortTranscriptNames(names)
+	transcripts := make([]transcriptInfo, 0, len(names))
+	for _, name := range names {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		match := transcriptNamePattern.FindStringSubmatch(name)
+		startedAt, _ := strconv.ParseInt(match[1], 10, 64)
+		sessionID, _ := strconv.Atoi(match[2])
+		transcripts = append(transcripts, transcriptInfo{Name: name, SessionID: sessionID, StartedAt: startedAt, Size: info.Size(), Live: live[name]})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"recording": s.cfg.RecordSessions, "transcripts": transcripts})
+}
+
+// handleAdminTranscript serves one transcript as NDJSON.
+func (s *Server) handleAdminTranscript(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	name := r.PathValue("name")
+	if !transcriptNamePattern.MatchString(name) {
+		writeError(w, http.StatusNotFound, errCodeTranscriptNotFound, "transcript not found.")
+		return
+	}
+	file, err := os.Open(filepath.Join(s.cfg.DataDir, "transcripts", name))
+	if err != nil {
+		writeError(w, http.StatusNotFound, errCodeTranscriptNotFound, "transcript not found.")
+		return
+	}
+	defer file.Close()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, file)
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"darkhold-go/internal/config"
+)
+
+func TestRecordSessionsTranscript(t *testing.T) {
+	dataDir := t.TempDir()
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.DataDir = dataDir
+		cfg.RecordSessions = true
+	})
+	defer s.close()
+
+	_ = postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir, "apiKey": "not-for-the-log"})
+
+	resp, listing := doJSON(t, http.MethodGet, s.http.URL+"/api/admin/transcripts", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected transcripts listing, got %d", resp.StatusCode)
+	}
+	transcripts, _ := listing["transcripts"].([]any)
+	if len(transcripts) != 1 {
+		t.Fatalf("expected one transcript, got %v", listing)
+	}
+	entry := transcripts[0].(map[string]any)
+	if entry["live"] != true || entry["sessionId"] != float64(1) {
+		t.Fatalf("expected the live session's transcript, got %v", entry)
+	}
+
+	resp, err := http.Get(s.http.URL + "/api/admin/transcripts/" + entry["name"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if strings.Contains(scanner.Text(), "not-for-the-log") {
+			t.Fatalf("expected the secret to be redacted: %s", scanner.Text())
+		}
+		var record struct {
+			Dir     string `json:"dir"`
+			Message struct {
+				Method string `json:"method"`
+				Params struct {
+					APIKey string `json:"apiKey"`
+				} `json:"params"`
+			} `json:"message"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &record) != nil {
+			t.Fatalf("invalid record: %s", scanner.Text())
+		}
+		seen[record.Dir+" "+record.Message.Method] = true
+		if record.Message.Method == "thread/start" && record.Message.Params.APIKey != redactedValue {
+			t.Fatalf("expected apiKey to be redacted, got %q", record.Message.Params.APIKey)
+		}
+	}
+	if !seen["send initialize"] || !seen["send thread/start"] || !seen["recv "] {
+		t.Fatalf("expected initialize, thread/start and a reply to be recorded, got %v", seen)
+	}
+
+	if resp, _ := doJSON(t, http.MethodGet, s.http.URL+"/api/admin/transcripts/..%2Fthreads.json", nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected an invalid name to be refused, got %d", resp.StatusCode)
+	}
+}
+
+func TestTranscriptRedactor(t *testing.T) {
+	r := newTranscriptRedactor("configured-token", "short")
+	message := map[string]any{
+		"params": map[string]any{
+			"env":        []any{"OPENAI_API_KEY=sk-abcdefghijklmnopqrstuv"},
+			"header":     "Authorization: Bearer abc.def.ghi123",
+			"note":       "uses configured-token and short",
+			"password":   "hunter2",
+			"tokenUsage": map[string]any{"inputTokens": float64(12)},
+		},
+	}
+	params := r.redact(message).(map[string]any)["params"].(map[string]any)
+	if params["env"].([]any)[0] != "OPENAI_API_KEY="+redactedValue {
+		t.Fatalf("expected the sk- key to be redacted, got %v", params["env"])
+	}
+	if params["header"] != "Authorization: "+redactedValue || params["password"] != redactedValue {
+		t.Fatalf("expected bearer token and password to be redacted, got %v", params)
+	}
+	if params["note"] != "uses "+redactedValue+" and short" {
+		t.Fatalf("expected only the long configured secret to be redacted, got %v", params["note"])
+	}
+	if usage := params["tokenUsage"].(map[string]any); usage["inputTokens"] != float64(12) {
+		t.Fatalf("expected token counts to survive, got %v", usage)
+	}
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+const (
+	// rpcLatencyWeight is the weight of the newest sample in a session's
+	// moving average RPC latency.
+	rpcLatencyWeight = 0.2
+	// routingHistory is how many routing decisions the admin listing keeps.
+	routingHistory = 32
+)
+
+// sessionLoad is a live session's load as seen by routing.
+type sessionLoad struct {
+	SessionID    int     `json:"sessionId"`
+	ActiveTurns  int     `json:"activeTurns"`
+	PendingRPCs  int     `json:"pendingRpcs"`
+	RPCLatencyMs float64 `json:"rpcLatencyMs"`
+}
+
+// busy is the work currently in flight on the session.
+func (l sessionLoad) busy() int {
+	return l.ActiveTurns + l.PendingRPCs
+}
+
+// lighter orders sessions for routing: least work in flight, then lowest
+// recent RPC latency, then the oldest session.
+func (l sessionLoad) lighter(other sessionLoad) bool {
+	if l.busy() != other.busy() {
+		return l.busy() < other.busy()
+	}
+	if l.RPCLatencyMs != other.RPCLatencyMs {
+		return l.RPCLatencyMs < other.RPCLatencyMs
+	}
+	return l.SessionID < other.SessionID
+}
+
+// routingDecision records where a call without a bound thread was sent.
+// Reason is "least-loaded" when several sessions were candidates and
+// "spawned" when none was alive.
+type routingDecision struct {
+	At         int64         `json:"at"`
+	SessionID  int           `json:"sessionId"`
+	Reason     string        `json:"reason"`
+	Candidates []sessionLoad `json:"candidates,omitempty"`
+}
+
+// load reports the session's load and whether it can take new work.
+func (sess *session) load() (sessionLoad, bool) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sessionLoad{
+		SessionID:    sess.id,
+		ActiveTurns:  len(sess.activeTurnIDs),
+		PendingRPCs:  len(sess.pending),
+		RPCLatencyMs: float64(sess.rpcLatency.Microseconds()) / 1000,
+	}, !sess.closed && !sess.stopRequested && !sess.unhealthy
+}
+
+// recordRPCLatency folds one RPC round trip into the session's moving
+// average.
+func (sess *session) recordRPCLatency(d time.Duration) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.rpcLatency == 0 {
+		sess.rpcLatency = d
+		return
+	}
+	sess.rpcLatency = time.Duration(rpcLatencyWeight*float64(d) + (1-rpcLatencyWeight)*float64(sess.rpcLatency))
+}
+
+// leastLoadedSession picks the lightest live session. Callers hold
+// sessionsMu. Decisions between several sessions are recorded.
+func (s *Server) leastLoadedSession() *session {
+	var best *session
+	var bestLoad sessionLoad
+	candidates := make([]sessionLoad, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		load, alive := sess.load()
+		if !alive {
+			continue
+		}
+		candidates = append(candidates, load)
+		if best == nil || load.lighter(bestLoad) {
+			best, bestLoad = sess, load
+		}
+	}
+	if len(candidates) > 1 {
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].SessionID < candidates[j].SessionID })
+		s.recordRouting(routingDecision{SessionID: best.id, Reason: "least-loaded", Candidates: candidates})
+	}
+	if best != nil {
+		best.mu.Lock()
+		best.routed++
+		best.mu.Unlock()
+	}
+	return best
+}
+
+func (s *Server) recordRouting(decision routingDecision) {
+	decision.At = time.Now().UnixMilli()
+	s.routingMu.Lock()
+	defer s.routingMu.Unlock()
+	s.routing = append(s.routing, decision)
+	if len(s.routing) > routingHistory {
+		s.routing = s.routing[len(s.routing)-routingHistory:]
+	}
+}
+
+// recentRouting returns routing decisions, newest first.
+func (s *Server) recentRouting() []routingDecision {
+	s.routingMu.Lock()
+	defer s.routingMu.Unlock()
+	out := make([]routingDecision, 0, len(s.routing))
+	for _, v := range slices.Backward(s.routing) {
+		out = append(out, v)
+	}
+	return out
+}
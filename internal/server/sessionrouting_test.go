@@ -0,0 +1,79 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestUnboundCallsGoToLeastLoadedSession(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	busy, _ := injectSession(s.app, 1)
+	defer removeSession(s.app, busy)
+	slow, _ := injectSession(s.app, 2)
+	defer removeSession(s.app, slow)
+	fast, _ := injectSession(s.app, 3)
+	defer removeSession(s.app, fast)
+	busy.activeTurnIDs["turn-1"] = "thread-busy"
+	slow.recordRPCLatency(300 * time.Millisecond)
+	fast.recordRPCLatency(20 * time.Millisecond)
+	fast.recordRPCLatency(120 * time.Millisecond)
+
+	if load, _ := fast.load(); load.RPCLatencyMs != 40 {
+		t.Fatalf("expected a moving average of 40ms, got %v", load.RPCLatencyMs)
+	}
+	sess, err := s.app.selectSession("")
+	if err != nil || sess != fast {
+		t.Fatalf("expected the idle low-latency session, got %v %v", sess, err)
+	}
+
+	// Threads stay on the session they are bound to regardless of load.
+	s.app.bindThreadToSession("thread-busy", busy)
+	if sess, _ := s.app.selectSession("thread-busy"); sess != busy {
+		t.Fatalf("expected the bound session, got %d", sess.id)
+	}
+
+	resp, body := doJSON(t, http.MethodGet, s.http.URL+"/api/admin/sessions", nil)
+	routing, _ := body["routing"].([]any)
+	if resp.StatusCode != http.StatusOK || len(routing) == 0 {
+		t.Fatalf("expected routing decisions, got %d %v", resp.StatusCode, body)
+	}
+	latest := routing[0].(map[string]any)
+	if latest["sessionId"] != float64(3) || latest["reason"] != "least-loaded" || len(latest["candidates"].([]any)) != 3 {
+		t.Fatalf("unexpected routing decision %v", latest)
+	}
+}
+
+func TestSessionRPCCapRefusesCallsBeyondIt(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) { cfg.MaxSessionRPCs = 2 })
+	defer s.close()
+
+	const threadID = "thread-saturated"
+	sess, _ := injectSession(s.app, 921)
+	defer removeSession(s.app, sess)
+	sess.initOnce.Do(func() {})
+	s.app.bindThreadToSession(threadID, sess)
+
+	// The injected session never answers, so these stay outstanding.
+	ctx := t.Context()
+	for range 2 {
+		go func() { _, _ = s.app.callSessionRPC(ctx, sess, "thread/read", map[string]any{"threadId": threadID}) }()
+	}
+	waitForCondition(t, 5*time.Second, 10*time.Millisecond, func() bool { return sess.info().PendingRPCs == 2 })
+
+	resp, payload := doJSON(t, http.MethodPost, s.http.URL+"/api/rpc", map[string]any{"method": "thread/read", "params": map[string]any{"threadId": threadID}})
+	if resp.StatusCode != http.StatusServiceUnavailable || payload["code"] != errCodeSessionSaturated || resp.Header.Get("Retry-After") != "1" {
+		t.Fatalf("expected 503 SESSION_SATURATED, got %d %v", resp.StatusCode, payload)
+	}
+	if info := sess.info(); info.RejectedRPCs != 1 || info.PeakPending != 2 {
+		t.Fatalf("expected the rejection to be counted, got %+v", info)
+	}
+	_, listed := doJSON(t, http.MethodGet, s.http.URL+"/api/admin/sessions", nil)
+	if listed["maxSessionRpcs"] != float64(2) {
+		t.Fatalf("expected the cap in the session listing, got %v", listed)
+	}
+}
@@ -0,0 +1,240 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/coder/websocket"
+)
+
+// sessionBridgeBuffer is how many upstream frames may queue for one WebSocket
+// client before it is disconnected as too slow.
+const sessionBridgeBuffer = 256
+
+// sessionBridge is one /api/session/ws client. Upstream notifications and
+// interaction requests for its thread are queued on out; closing done drops
+// the client.
+type sessionBridge struct {
+	threadID string
+	out      chan []byte
+	done     chan struct{}
+	once     sync.Once
+}
+
+func (b *sessionBridge) close() {
+	b.once.Do(func() { close(b.done) })
+}
+
+func (b *sessionBridge) send(frame []byte) {
+	select {
+	case <-b.done:
+	case b.out <- frame:
+	default:
+		log.Printf("[session-ws] dropping backpressured client for thread %s", b.threadID)
+		b.close()
+	}
+}
+
+func (s *Server) addSessionBridge(b *sessionBridge) {
+	s.sessionBridgesMu.Lock()
+	defer s.sessionBridgesMu.Unlock()
+	if s.sessionBridges[b.threadID] == nil {
+		s.sessionBridges[b.threadID] = map[*sessionBridge]struct{}{}
+	}
+	s.sessionBridges[b.threadID][b] = struct{}{}
+}
+
+func (s *Server) removeSessionBridge(b *sessionBridge) {
+	s.sessionBridgesMu.Lock()
+	defer s.sessionBridgesMu.Unlock()
+	delete(s.sessionBridges[b.threadID], b)
+	if len(s.sessionBridges[b.threadID]) == 0 {
+		delete(s.sessionBridges, b.threadID)
+	}
+}
+
+// forwardToSessionBridges sends a raw upstream JSON-RPC line to every
+// WebSocket client attached to the thread.
+func (s *Server) forwardToSessionBridges(threadID string, frame []byte) {
+	s.sessionBridgesMu.Lock()
+	bridges := make([]*sessionBridge, 0, len(s.sessionBridges[threadID]))
+	for b := range s.sessionBridges[threadID] {
+		bridges = append(bridges, b)
+	}
+	s.sessionBridgesMu.Unlock()
+	for _, b := range bridges {
+		b.send(frame)
+	}
+}
+
+// forwardInteractionToSessionBridges re-creates the upstream server request so
+// native clients can answer it with a plain JSON-RPC response.
+func (s *Server) forwardInteractionToSessionBridges(threadID, requestID, method string, params map[string]any) {
+	id, err := strconv.ParseInt(requestID, 10, 64)
+	if err != nil {
+		return
+	}
+	frame, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+	s.forwardToSessionBridges(threadID, frame)
+}
+
+// jsonRPCMessage is any frame a WebSocket client may send: a request (id and
+// method), a notification (method only) or a response to a server request
+// (id with result or error).
+type jsonRPCMessage struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params any             `json:"params,omitempty"`
+	Result any             `json:"result,omitempty"`
+	Error  any             `json:"error,omitempty"`
+}
+
+// handleSessionWS bridges a WebSocket to the app-server session owning a
+// thread using the native JSON-RPC protocol. Requests still go through
+// dispatchRPC, so darkhold keeps recording events, binding threads and
+// applying budgets; request IDs are rewritten on the way up and restored on
+// the way back.
+func (s *Server) handleSessionWS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	threadID := strings.TrimSpace(r.URL.Query().Get("threadId"))
+	if threadID == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+		return
+	}
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	conn.SetReadLimit(s.maxRequestBodySize)
+	defer conn.CloseNow()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	bridge := &sessionBridge{threadID: threadID, out: make(chan []byte, sessionBridgeBuffer), done: make(chan struct{})}
+	s.addSessionBridge(bridge)
+	defer s.removeSessionBridge(bridge)
+
+	go func() {
+		defer cancel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-bridge.done:
+				_ = conn.Close(websocket.StatusPolicyViolation, "client is too slow")
+				return
+			case frame := <-bridge.out:
+				if err := conn.Write(ctx, websocket.MessageText, frame); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	var inflight sync.WaitGroup
+	defer inflight.Wait()
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return
+		}
+		var msg jsonRPCMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			bridge.send(jsonRPCErrorFrame(nil, -32700, "parse error", ""))
+			continue
+		}
+		switch {
+		case msg.Method != "" && len(msg.ID) > 0:
+			inflight.Go(func() {
+				bridge.send(s.bridgeRequest(ctx, msg))
+			})
+		case msg.Method != "":
+			// darkhold already completed the initialize handshake for the
+			// session; other client notifications have no upstream meaning.
+		case len(msg.ID) > 0:
+			var requestID json.Number
+			if err := json.Unmarshal(msg.ID, &requestID); err != nil {
+				continue
+			}
+			// The WebSocket itself only needs write; answering the agent's
+			// requests over it needs approve, as on the REST route.
+			if !slices.Contains(clientScopes(ctx), "approve") {
+				bridge.send(jsonRPCErrorFrame(msg.ID, -32000, "your account lacks the approve scope.", errCodeForbidden))
+				log.Printf("[session-ws] refused response to request %s on thread %s from %s: no approve scope", requestID, threadID, clientIdentity(ctx))
+				continue
+			}
+			if err := s.resolveInteraction(threadID, requestID.String(), msg.Result, msg.Error, map[string]any{"source": "websocket", "by": clientIdentity(ctx)}); err != nil {
+				log.Printf("[session-ws] cannot resolve request %s on thread %s: %v", requestID, threadID, err)
+			}
+		}
+	}
+}
+
+// bridgeRequest runs one client request and returns the response frame with
+// the client's request ID.
+func (s *Server) bridgeRequest(ctx context.Context, msg jsonRPCMessage) []byte {
+	if msg.Method == "initialize" {
+		frame, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": msg.ID, "result": map[string]any{"userAgent": "darkhold-go"}})
+		return frame
+	}
+	response, err := s.dispatchRPC(ctx, msg.Method, msg.Params)
+	if err != nil {
+		code := errCodeInternal
+		var dispatchErr *rpcDispatchError
+		var budgetErr *budgetExceededError
+		var diskErr *diskLowError
+		var inputErr *turnInputError
+		var busyErr *threadBusyError
+		var sizeErr *turnTooLargeError
+		var rejectedErr *turnRejectedError
+		switch {
+		case errors.As(err, &budgetErr):
+			code = errCodeBudgetExceeded
+		case errors.As(err, &diskErr):
+			code = errCodeInsufficientStorage
+		case errors.As(err, &inputErr):
+			return jsonRPCErrorFrame(msg.ID, -32602, err.Error(), errCodeInvalidTurnInput)
+		case errors.As(err, &busyErr):
+			code = errCodeThreadBusy
+		case errors.As(err, &sizeErr):
+			code = errCodeTurnTooLarge
+		case errors.As(err, &rejectedErr):
+			code = errCodeTurnRejected
+		case errors.As(err, &dispatchErr):
+			code = dispatchErr.code
+		}
+		return jsonRPCErrorFrame(msg.ID, -32000, err.Error(), code)
+	}
+	reply := map[string]any{"jsonrpc": "2.0", "id": msg.ID}
+	if errObj, ok := response["error"]; ok && errObj != nil {
+		reply["error"] = errObj
+	} else {
+		reply["result"] = response["result"]
+	}
+	frame, _ := json.Marshal(reply)
+	return frame
+}
+
+func jsonRPCErrorFrame(id json.RawMessage, code int, message, darkholdCode string) []byte {
+	errObj := map[string]any{"code": code, "message": message}
+	if darkholdCode != "" {
+		errObj["data"] = map[string]any{"code": darkholdCode}
+	}
+	var rawID any
+	if len(id) > 0 {
+		rawID = id
+	}
+	frame, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": rawID, "error": errObj})
+	return frame
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+func readWSFrame(t *testing.T, ctx context.Context, conn *websocket.Conn, predicate func(map[string]any) bool) map[string]any {
+	t.Helper()
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			t.Fatalf("websocket read failed: %v", err)
+		}
+		var frame map[string]any
+		if err := json.Unmarshal(data, &frame); err != nil {
+			t.Fatalf("invalid frame %s: %v", data, err)
+		}
+		if predicate(frame) {
+			return frame
+		}
+	}
+}
+
+func TestSessionWebSocketBridgesNativeProtocol(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	wsURL := "ws" + strings.TrimPrefix(s.http.URL, "http") + "/api/session/ws?threadId=" + threadID
+	conn, _, err := websocket.Dial(ctx, wsURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.CloseNow()
+
+	write := func(frame map[string]any) {
+		data, _ := json.Marshal(frame)
+		if err := conn.Write(ctx, websocket.MessageText, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(map[string]any{"jsonrpc": "2.0", "id": "init", "method": "initialize", "params": map[string]any{}})
+	if frame := readWSFrame(t, ctx, conn, func(f map[string]any) bool { return f["id"] == "init" }); frame["result"] == nil {
+		t.Fatalf("expected initialize to be answered locally: %v", frame)
+	}
+
+	write(map[string]any{"jsonrpc": "2.0", "id": 41, "method": "turn/start", "params": map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "hi LEGACY_APPROVAL"}}}})
+	if frame := readWSFrame(t, ctx, conn, func(f map[string]any) bool { return f["id"] == float64(41) }); frame["result"].(map[string]any)["turn"] == nil {
+		t.Fatalf("unexpected turn/start reply: %v", frame)
+	}
+
+	approval := readWSFrame(t, ctx, conn, func(f map[string]any) bool { return f["method"] == "execCommandApproval" })
+	write(map[string]any{"jsonrpc": "2.0", "id": approval["id"], "result": map[string]any{"decision": "accept"}})
+	readWSFrame(t, ctx, conn, func(f map[string]any) bool { return f["method"] == "turn/completed" })
+
+	stored, err := s.store.Read(threadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined := strings.Join(stored, "\n")
+	if !strings.Contains(joined, `"source":"websocket"`) || !strings.Contains(joined, "turn/completed") {
+		t.Fatalf("expected bridged traffic to be recorded, got %s", joined)
+	}
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"darkhold-go/internal/threads"
+)
+
+const (
+	errCodeShareNotFound    = "SHARE_NOT_FOUND"
+	errCodeShareLinkInvalid = "SHARE_LINK_INVALID"
+	errCodeShareLinkExpired = "SHARE_LINK_EXPIRED"
+
+	// shareLinkParam carries a share token on the event routes it unlocks.
+	shareLinkParam      = "share"
+	shareIdentityPrefix = "share:"
+)
+
+// shareLinkPaths are the routes a share token grants, all read-only views of
+// one thread's events, besides the thread's /embed/thread/{id} widget.
+var shareLinkPaths = map[string]bool{
+	"/api/thread/events":        true,
+	"/api/thread/events/stream": true,
+	"/api/thread/events/poll":   true,
+}
+
+// shareLinkClaims is the signed part of a share token.
+type shareLinkClaims struct {
+	ThreadID  string `json:"t"`
+	ShareID   string `json:"s"`
+	ExpiresAt int64  `json:"e"`
+}
+
+// shareLinks signs thread share tokens with a key kept in
+// <data-dir>/share-link.key, separate from the quick link key so neither kind
+// of token can pass for the other. Whether a share is still live is recorded
+// in the thread's metadata.
+type shareLinks struct {
+	signer tokenSigner
+	ttl    time.Duration
+}
+
+func openShareLinks(dataDir string, ttl time.Duration) *shareLinks {
+	l := &shareLinks{signer: openSigningKey(dataDir, "share-link.key", "share"), ttl: ttl}
+	if l.ttl <= 0 {
+		l.ttl = 24 * time.Hour
+	}
+	return l
+}
+
+func (l *shareLinks) mint(threadID string, share threads.Share) string {
+	return l.signer.seal(shareLinkClaims{ThreadID: threadID, ShareID: share.ID, ExpiresAt: share.ExpiresAt})
+}
+
+func (l *shareLinks) verify(token string) (shareLinkClaims, bool) {
+	var claims shareLinkClaims
+	if !l.signer.open(token, &claims) || claims.ThreadID == "" || claims.ShareID == "" {
+		return claims, false
+	}
+	return claims, true
+}
+
+// links builds the share's event URLs under baseURL.
+func (l *shareLinks) links(baseURL, threadID, token string) map[string]string {
+	query := url.Values{"threadId": {threadID}, shareLinkParam: {token}}.Encode()
+	base := strings.TrimRight(baseURL, "/")
+	return map[string]string{
+		"events": base + "/api/thread/events?" + query,
+		"stream": base + "/api/thread/events/stream?" + query,
+		"poll":   base + "/api/thread/events/poll?" + query,
+		"embed":  base + embedThreadPathPrefix + url.PathEscape(threadID) + "?" + url.Values{shareLinkParam: {token}}.Encode(),
+	}
+}
+
+// shareLinkIdentity authenticates a request carrying ?share=. The token only
+// opens the event routes of its own thread, and only while the share exists
+// and has not expired; anything else is refused here rather than falling
+// back to other credentials.
+func (s *Server) shareLinkIdentity(w http.ResponseWriter, r *http.Request) (string, bool) {
+	claims, ok := s.shareLinks.verify(r.URL.Query().Get(shareLinkParam))
+	if !ok {
+		log.Printf("[share] rejected invalid link from %s", r.RemoteAddr)
+		writeError(w, http.StatusForbidden, errCodeShareLinkInvalid, "share link is invalid.")
+		return "", false
+	}
+	threadID := strings.TrimSpace(r.URL.Query().Get("threadId"))
+	if embedded, ok := strings.CutPrefix(r.URL.Path, embedThreadPathPrefix); ok {
+		threadID = embedded
+	} else if !shareLinkPaths[r.URL.Path] {
+		threadID = ""
+	}
+	if threadID != claims.ThreadID || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		writeError(w, http.StatusForbidden, errCodeShareLinkInvalid, "share links only grant read access to their thread's events.")
+		return "", false
+	}
+	meta, _ := s.threadIndex.Get(claims.ThreadID)
+	if _, ok := meta.ActiveShare(claims.ShareID, time.Now()); !ok || time.Now().UnixMilli() > claims.ExpiresAt {
+		log.Printf("[share] rejected expired or revoked link %s on thread %s from %s", claims.ShareID, claims.ThreadID, r.RemoteAddr)
+		writeError(w, http.StatusGone, errCodeShareLinkExpired, "share link has expired or was revoked.")
+		return "", false
+	}
+	return shareIdentityPrefix + claims.ShareID, true
+}
+
+// handleThreadShares lists, creates and revokes a thread's share links:
+//
+//	GET ?threadId=                          {threadId, shares}
+//	POST {threadId, label?, expiresInMs?}   {share, links}
+//	DELETE ?threadId=&shareId=              {ok}
+//
+// expiresInMs defaults to, and may not exceed, --share-link-ttl. Tokens are
+// only returned when a share is created.
+func (s *Server) handleThreadShares(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		threadID := strings.TrimSpace(r.URL.Query().Get("threadId"))
+		if threadID == "" {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+			return
+		}
+		meta, _ := s.threadIndex.Get(threadID)
+		writeJSON(w, http.StatusOK, map[string]any{"threadId": threadID, "shares": meta.ActiveShares(time.Now())})
+	case http.MethodPost:
+		s.createThreadShare(w, r)
+	case http.MethodDelete:
+		threadID := strings.TrimSpace(r.URL.Query().Get("threadId"))
+		shareID := strings.TrimSpace(r.URL.Query().Get("shareId"))
+		if threadID == "" || shareID == "" {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId and shareId are required.")
+			return
+		}
+		_, err := s.threadIndex.Update(threadID, func(meta *threads.Metadata) error {
+			return meta.DeleteShare(shareID)
+		})
+		switch {
+		case errors.Is(err, threads.ErrShareNotFound):
+			writeError(w, http.StatusNotFound, errCodeShareNotFound, "share link not found.")
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
+		default:
+			s.audit.record(r, "share.revoke", map[string]any{"threadId": threadID, "shareId": shareID})
+			log.Printf("[share] %s revoked link %s on thread %s", clientIdentity(r.Context()), shareID, threadID)
+			writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+		}
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+func (s *Server) createThreadShare(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
+	var request struct {
+		ThreadID    string `json:"threadId"`
+		Label       string `json:"label"`
+		ExpiresInMs int64  `json:"expiresInMs"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+		return
+	}
+	threadID := strings.TrimSpace(request.ThreadID)
+	if threadID == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+		return
+	}
+	ttl := s.shareLinks.ttl
+	if request.ExpiresInMs < 0 || time.Duration(request.ExpiresInMs)*time.Millisecond > ttl {
+		writeErrorDetails(w, http.StatusBadRequest, errCodeInvalidRequest, "expiresInMs must be between 1 and --share-link-ttl.", map[string]any{"maxExpiresInMs": ttl.Milliseconds()})
+		return
+	}
+	if request.ExpiresInMs > 0 {
+		ttl = time.Duration(request.ExpiresInMs) * time.Millisecond
+	}
+	if _, ok := s.threadIndex.Get(threadID); !ok {
+		writeError(w, http.StatusNotFound, errCodeThreadNotFound, "thread not found.")
+		return
+	}
+	var share threads.Share
+	_, err := s.threadIndex.Update(threadID, func(meta *threads.Metadata) error {
+		var err error
+		share, err = meta.AddShare(request.Label, clientIdentity(r.Context()), time.Now().Add(ttl))
+		return err
+	})
+	switch {
+	case errors.Is(err, threads.ErrShareLabelTooLong):
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error()+".")
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
+		return
+	}
+	s.audit.record(r, "share.create", map[string]any{"threadId": threadID, "shareId": share.ID, "expiresAt": share.ExpiresAt})
+	log.Printf("[share] %s shared thread %s as %s until %s", clientIdentity(r.Context()), threadID, share.ID, time.UnixMilli(share.ExpiresAt).UTC().Format(time.RFC3339))
+	token := s.shareLinks.mint(threadID, share)
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"threadId": threadID,
+		"share":    share,
+		"links":    s.shareLinks.links(s.linkBaseURL(r), threadID, token),
+	})
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	errCodeNotSupervised = "NOT_SUPERVISED"
+
+	// RestartExitCode is the exit status after /api/admin/restart:
+	// EX_TEMPFAIL, which systemd's Restart=on-failure and similar service
+	// manager policies restart.
+	RestartExitCode = 75
+)
+
+// ShutdownRequest is a shutdown or restart asked for over the admin API.
+type ShutdownRequest struct {
+	Restart  bool
+	Identity string
+	Reason   string
+}
+
+// ShutdownRequests delivers the admin API's shutdown and restart requests.
+// The caller drains the server as it does for SIGTERM.
+func (s *Server) ShutdownRequests() <-chan ShutdownRequest {
+	return s.shutdownRequests
+}
+
+func (s *Server) handleAdminShutdown(w http.ResponseWriter, r *http.Request) {
+	s.requestShutdown(w, r, false)
+}
+
+// handleAdminRestart only works under a service manager: darkhold exits with
+// RestartExitCode and relies on being started again.
+func (s *Server) handleAdminRestart(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost && !s.cfg.Supervised {
+		writeError(w, http.StatusConflict, errCodeNotSupervised, "darkhold is not running under a service manager; start it with --supervised.")
+		return
+	}
+	s.requestShutdown(w, r, true)
+}
+
+// requestShutdown answers 202 and hands the request to ShutdownRequests. A
+// drain already under way is left alone.
+func (s *Server) requestShutdown(w http.ResponseWriter, r *http.Request, restart bool) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+		return
+	}
+	req := ShutdownRequest{Restart: restart, Identity: clientIdentity(r.Context()), Reason: strings.TrimSpace(body.Reason)}
+	action := "admin.shutdown"
+	if restart {
+		action = "admin.restart"
+	}
+	var details map[string]any
+	if req.Reason != "" {
+		details = map[string]any{"reason": req.Reason}
+	}
+	s.audit.record(r, action, details)
+	select {
+	case s.shutdownRequests <- req:
+	default:
+	}
+	writeJSON(w, http.StatusAccepted, map[string]any{"status": "draining", "restart": restart})
+}
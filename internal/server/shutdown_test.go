@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"darkhold-go/internal/config"
+)
+
+func TestAdminShutdownAndRestart(t *testing.T) {
+	dataDir := t.TempDir()
+	s := startIntegrationServer(t, func(cfg *config.Config) { cfg.DataDir = dataDir })
+	defer s.close()
+
+	if resp, body := doJSON(t, http.MethodPost, s.http.URL+"/api/admin/restart", nil); resp.StatusCode != http.StatusConflict || body["code"] != errCodeNotSupervised {
+		t.Fatalf("expected restart to need a supervisor, got %d %v", resp.StatusCode, body)
+	}
+	if resp, _ := doJSON(t, http.MethodGet, s.http.URL+"/api/admin/shutdown", nil); resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected GET to be refused, got %d", resp.StatusCode)
+	}
+	select {
+	case req := <-s.app.ShutdownRequests():
+		t.Fatalf("unexpected request %+v", req)
+	default:
+	}
+
+	resp, body := doJSON(t, http.MethodPost, s.http.URL+"/api/admin/shutdown", map[string]any{"reason": "kernel update"})
+	if resp.StatusCode != http.StatusAccepted || body["status"] != "draining" || body["restart"] != false {
+		t.Fatalf("unexpected response %d %v", resp.StatusCode, body)
+	}
+	// A second request while the first is pending is accepted but not queued.
+	_, _ = doJSON(t, http.MethodPost, s.http.URL+"/api/admin/shutdown", nil)
+	if req := <-s.app.ShutdownRequests(); req.Restart || req.Identity != anonymousIdentity || req.Reason != "kernel update" {
+		t.Fatalf("unexpected request %+v", req)
+	}
+
+	s.app.cfg.Supervised = true
+	if resp, _ := doJSON(t, http.MethodPost, s.http.URL+"/api/admin/restart", nil); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected the restart to be accepted, got %d", resp.StatusCode)
+	}
+	if req := <-s.app.ShutdownRequests(); !req.Restart {
+		t.Fatalf("expected a restart, got %+v", req)
+	}
+
+	audit, _ := os.ReadFile(filepath.Join(dataDir, "audit.jsonl"))
+	if !strings.Contains(string(audit), `"action":"admin.shutdown","identity":"anonymous"`) || !strings.Contains(string(audit), `"details":{"reason":"kernel update"}`) ||
+		!strings.Contains(string(audit), `"action":"admin.restart"`) {
+		t.Fatalf("expected shutdowns to be audited, got %s", audit)
+	}
+}
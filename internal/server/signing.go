@@ -0,0 +1,75 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tokenSigner seals values as base64url(JSON).base64url(HMAC-SHA256), the
+// format of quick links, share links and OIDC cookies. Each kind of token has
+// its own key so none can pass for another.
+type tokenSigner struct {
+	key []byte
+}
+
+// openSigningKey reads an HMAC key from <data-dir>/<name>, creating a random
+// one (mode 0600) when it is missing or short.
+func openSigningKey(dataDir, name, tag string) tokenSigner {
+	path := ""
+	if dataDir != "" {
+		path = filepath.Join(dataDir, name)
+		if key, err := os.ReadFile(path); err == nil && len(key) >= 32 {
+			return tokenSigner{key: key}
+		}
+	}
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	if path != "" {
+		err := os.MkdirAll(dataDir, 0o755)
+		if err == nil {
+			err = os.WriteFile(path, key, 0o600)
+		}
+		if err != nil {
+			log.Printf("[%s] failed to persist %s, tokens signed with it will not survive a restart: %v", tag, name, err)
+		}
+	}
+	return tokenSigner{key: key}
+}
+
+func (s tokenSigner) sign(data []byte) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// seal signs the JSON encoding of value.
+func (s tokenSigner) seal(value any) string {
+	data, _ := json.Marshal(value)
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(data) + "." + enc.EncodeToString(s.sign(data))
+}
+
+// open verifies a sealed token and decodes it into out, reporting false when
+// it is malformed or was not signed with this key.
+func (s tokenSigner) open(token string, out any) bool {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.sign(data)) {
+		return false
+	}
+	return json.Unmarshal(data, out) == nil
+}
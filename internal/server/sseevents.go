@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	sse "github.com/tmaxmax/go-sse"
+)
+
+// sseEventName is the SSE "event:" field a thread event is sent with, so
+// browser EventSource clients can addEventListener per kind instead of
+// parsing every message. Methods outside these families are sent unnamed and
+// arrive as ordinary "message" events.
+func sseEventName(method string) string {
+	switch {
+	case strings.HasPrefix(method, "darkhold/interaction/"):
+		return "interaction"
+	case strings.HasPrefix(method, "darkhold/draft/"):
+		return "draft"
+	case strings.HasPrefix(method, "darkhold/"):
+		return "darkhold"
+	case strings.HasPrefix(method, "item/") && (strings.HasSuffix(method, "/delta") || strings.HasSuffix(method, "Delta")):
+		return "delta"
+	case strings.HasPrefix(method, "item/"):
+		return "item"
+	case strings.HasPrefix(method, "turn/"):
+		return "turn"
+	case strings.HasPrefix(method, "thread/"):
+		return "thread"
+	}
+	return ""
+}
+
+// newThreadEventMessage builds the SSE message for a thread event payload,
+// named by its method. An empty id leaves the client's Last-Event-ID alone.
+func newThreadEventMessage(id, payload string) *sse.Message {
+	msg := &sse.Message{}
+	if id != "" {
+		msg.ID = sse.ID(id)
+	}
+	var event struct {
+		Method string `json:"method"`
+	}
+	if json.Unmarshal([]byte(payload), &event) == nil {
+		if name := sseEventName(event.Method); name != "" {
+			msg.Type = sse.Type(name)
+		}
+	}
+	msg.AppendData(payload)
+	return msg
+}
+
+// sseEventNamesOff reports whether a stream asked for unnamed events with
+// ?eventNames=false, for clients that only listen to onmessage.
+func sseEventNamesOff(raw string) (bool, error) {
+	if raw == "" {
+		return false, nil
+	}
+	named, err := strconv.ParseBool(raw)
+	return !named, err
+}
+
+// unnamedSSEMessage strips the event name from msg for a client that asked
+// for unnamed events. Published messages are shared between streams, so a
+// named one is copied rather than changed.
+func unnamedSSEMessage(msg *sse.Message) *sse.Message {
+	if !msg.Type.IsSet() {
+		return msg
+	}
+	msg = msg.Clone()
+	msg.Type = sse.EventType{}
+	return msg
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSSEEventName(t *testing.T) {
+	for method, want := range map[string]string{
+		"darkhold/interaction/request":      "interaction",
+		"darkhold/interaction/resolved":     "interaction",
+		"darkhold/draft/changed":            "draft",
+		"darkhold/alert":                    "darkhold",
+		"item/agentMessage/delta":           "delta",
+		"item/commandExecution/outputDelta": "delta",
+		"item/started":                      "item",
+		"turn/completed":                    "turn",
+		"thread/tokenUsage/updated":         "thread",
+		"account/rateLimits/updated":        "",
+		"":                                  "",
+	} {
+		if got := sseEventName(method); got != want {
+			t.Errorf("sseEventName(%q) = %q, want %q", method, got, want)
+		}
+	}
+}
+
+func TestThreadEventStreamNamesEvents(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	s.app.publishThreadEvent("t-named", `{"method":"turn/started","params":{"threadId":"t-named"}}`)
+	s.app.publishThreadEvent("t-named", `{"method":"item/agentMessage/delta","params":{"threadId":"t-named","delta":"hi"}}`)
+
+	named := openSSE(t, s.http.URL, "t-named", "")
+	defer named.Body.Close()
+	unnamed := openSSE(t, s.http.URL, "t-named&eventNames=false", "")
+	defer unnamed.Body.Close()
+	go func() {
+		// Let both streams subscribe before the live event goes out.
+		time.Sleep(300 * time.Millisecond)
+		s.app.publishThreadEvent("t-named", `{"method":"darkhold/interaction/request","params":{"threadId":"t-named"}}`)
+	}()
+
+	for _, stream := range []struct {
+		resp *http.Response
+		want []string
+	}{
+		{named, []string{"turn", "delta", "interaction"}},
+		{unnamed, []string{"", "", ""}},
+	} {
+		var types []string
+		waitForSSEEvent(t, stream.resp, func(event sseEvent) bool {
+			types = append(types, event.Type)
+			return len(types) == len(stream.want)
+		}, 5*time.Second)
+		if !slices.Equal(types, stream.want) {
+			t.Fatalf("expected event names %q, got %q", stream.want, types)
+		}
+	}
+
+	if resp, _ := doJSON(t, http.MethodGet, s.http.URL+"/api/thread/events/stream?threadId=t-named&eventNames=maybe", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected an invalid eventNames to be refused, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"maps"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+const (
+	errCodeSSEClientLimit = "SSE_CLIENT_LIMIT"
+	errCodeSSECapacity    = "SSE_CAPACITY"
+)
+
+// sseRetryAfter is the Retry-After hint, in seconds, on rejected streams.
+const sseRetryAfter = 5
+
+// sseAccounting counts open thread event streams per thread and per client
+// IP, and enforces --max-sse-per-ip and --max-sse-total. Each stream holds a
+// goroutine, a channel and a provider subscription, so the caps bound memory
+// as well as file descriptors.
+type sseAccounting struct {
+	maxPerIP int
+	maxTotal int
+
+	mu              sync.Mutex
+	total           int
+	byIP            map[string]int
+	byThread        map[string]int
+	rejectedPerIP   int64
+	rejectedByTotal int64
+}
+
+type sseStats struct {
+	Total           int            `json:"total"`
+	MaxTotal        int            `json:"maxTotal"`
+	MaxPerIP        int            `json:"maxPerIp"`
+	ByThread        map[string]int `json:"byThread"`
+	ByIP            map[string]int `json:"byIp"`
+	RejectedPerIP   int64          `json:"rejectedPerIp"`
+	RejectedByTotal int64          `json:"rejectedByTotal"`
+}
+
+func newSSEAccounting(maxPerIP, maxTotal int) *sseAccounting {
+	return &sseAccounting{maxPerIP: maxPerIP, maxTotal: maxTotal, byIP: map[string]int{}, byThread: map[string]int{}}
+}
+
+// acquire reserves a stream slot for ip on threadID. It returns the release
+// function, or the error code to reject the stream with.
+func (a *sseAccounting) acquire(ip, threadID string) (func(), string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.maxPerIP > 0 && a.byIP[ip] >= a.maxPerIP {
+		a.rejectedPerIP++
+		return nil, errCodeSSEClientLimit
+	}
+	if a.maxTotal > 0 && a.total >= a.maxTotal {
+		a.rejectedByTotal++
+		return nil, errCodeSSECapacity
+	}
+	a.total++
+	a.byIP[ip]++
+	a.byThread[threadID]++
+	var once sync.Once
+	return func() { once.Do(func() { a.release(ip, threadID) }) }, ""
+}
+
+func (a *sseAccounting) release(ip, threadID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.total--
+	if a.byIP[ip]--; a.byIP[ip] <= 0 {
+		delete(a.byIP, ip)
+	}
+	if a.byThread[threadID]--; a.byThread[threadID] <= 0 {
+		delete(a.byThread, threadID)
+	}
+}
+
+func (a *sseAccounting) stats() sseStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stats := sseStats{
+		Total:           a.total,
+		MaxTotal:        a.maxTotal,
+		MaxPerIP:        a.maxPerIP,
+		ByThread:        make(map[string]int, len(a.byThread)),
+		ByIP:            make(map[string]int, len(a.byIP)),
+		RejectedPerIP:   a.rejectedPerIP,
+		RejectedByTotal: a.rejectedByTotal,
+	}
+	maps.Copy(stats.ByThread, a.byThread)
+	maps.Copy(stats.ByIP, a.byIP)
+	return stats
+}
+
+// writeSSELimit rejects a stream: 429 when the client is over its own cap,
+// 503 when the server is full.
+func writeSSELimit(w http.ResponseWriter, code string) {
+	w.Header().Set("Retry-After", strconv.Itoa(sseRetryAfter))
+	if code == errCodeSSEClientLimit {
+		writeError(w, http.StatusTooManyRequests, code, "too many event streams open from this client.")
+		return
+	}
+	writeError(w, http.StatusServiceUnavailable, code, "server has no free event stream slots.")
+}
+
+// remoteHost returns the IP part of r.RemoteAddr.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (s *Server) handleAdminSSE(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.sseStreams.stats())
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestSSEAccountingCaps(t *testing.T) {
+	a := newSSEAccounting(2, 3)
+	releaseA1, _ := a.acquire("10.0.0.1", "t1")
+	_, _ = a.acquire("10.0.0.1", "t2")
+	if _, code := a.acquire("10.0.0.1", "t1"); code != errCodeSSEClientLimit {
+		t.Fatalf("expected per-IP rejection, got %q", code)
+	}
+	_, _ = a.acquire("10.0.0.2", "t1")
+	if _, code := a.acquire("10.0.0.3", "t1"); code != errCodeSSECapacity {
+		t.Fatalf("expected capacity rejection, got %q", code)
+	}
+	releaseA1()
+	releaseA1()
+	stats := a.stats()
+	if stats.Total != 2 || stats.ByIP["10.0.0.1"] != 1 || stats.ByThread["t1"] != 1 || stats.RejectedPerIP != 1 || stats.RejectedByTotal != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestThreadStreamsArePerIPLimited(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.FakeApprovalRate = 0
+		cfg.MaxSSEPerIP = 1
+	})
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	first := openSSE(t, s.http.URL, threadID, "")
+
+	resp, err := http.Get(s.http.URL + "/api/thread/events/stream?threadId=" + threadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After, got %d", resp.StatusCode)
+	}
+	_, stats := doJSON(t, http.MethodGet, s.http.URL+"/api/admin/sse", nil)
+	byThread, _ := stats["byThread"].(map[string]any)
+	if stats["total"] != float64(1) || byThread[threadID] != float64(1) || stats["rejectedPerIp"] != float64(1) {
+		t.Fatalf("unexpected stats %v", stats)
+	}
+
+	first.Body.Close()
+	waitForCondition(t, 5*time.Second, 10*time.Millisecond, func() bool {
+		return s.app.sseStreams.stats().Total == 0
+	})
+	second := openSSE(t, s.http.URL, threadID, "")
+	second.Body.Close()
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"darkhold-go/internal/usage"
+)
+
+// statsCounters are anonymous totals: no client, key or thread breakdown,
+// just the numbers a team dashboard charts.
+type statsCounters struct {
+	TurnsRun         int64 `json:"turnsRun"`
+	TurnsCompleted   int64 `json:"turnsCompleted"`
+	TurnsFailed      int64 `json:"turnsFailed"`
+	TurnsInterrupted int64 `json:"turnsInterrupted"`
+	// AvgTurnDurationMs averages the turns whose start this server saw.
+	AvgTurnDurationMs int64 `json:"avgTurnDurationMs"`
+	ApprovalsGranted  int64 `json:"approvalsGranted"`
+	ApprovalsDenied   int64 `json:"approvalsDenied"`
+	EventsStored      int64 `json:"eventsStored"`
+
+	timedTurns int64
+	turnTime   time.Duration
+}
+
+func (c *statsCounters) view() statsCounters {
+	out := *c
+	if c.timedTurns > 0 {
+		out.AvgTurnDurationMs = (c.turnTime / time.Duration(c.timedTurns)).Milliseconds()
+	}
+	return out
+}
+
+// serverStats accumulates the counters behind /api/stats/overview for the
+// current UTC day and since the server started. They are kept in memory, so
+// a restart starts both from zero.
+type serverStats struct {
+	now       func() time.Time
+	startedAt time.Time
+
+	mu         sync.Mutex
+	day        string
+	today      statsCounters
+	sinceStart statsCounters
+	// turnStarts is when each thread's running turn started.
+	turnStarts map[string]time.Time
+}
+
+func newServerStats() *serverStats {
+	now := time.Now()
+	return &serverStats{now: time.Now, startedAt: now, day: usage.Day(now), turnStarts: map[string]time.Time{}}
+}
+
+// rollLocked starts a new day's counters once the date has changed.
+func (st *serverStats) rollLocked() {
+	if day := usage.Day(st.now()); day != st.day {
+		st.day, st.today = day, statsCounters{}
+	}
+}
+
+// add applies fn to both sets of counters.
+func (st *serverStats) add(fn func(*statsCounters)) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.rollLocked()
+	fn(&st.today)
+	fn(&st.sinceStart)
+}
+
+func (st *serverStats) turnStarted(threadID string) {
+	st.mu.Lock()
+	st.turnStarts[threadID] = st.now()
+	st.mu.Unlock()
+}
+
+// turnFinished counts a turn that ended with a turn/completed, turn/failed
+// or turn/aborted notification.
+func (st *serverStats) turnFinished(threadID, method string, params map[string]any) {
+	st.mu.Lock()
+	started, timed := st.turnStarts[threadID]
+	delete(st.turnStarts, threadID)
+	elapsed := st.now().Sub(started)
+	st.mu.Unlock()
+
+	status := ""
+	if turn, ok := params["turn"].(map[string]any); ok {
+		status, _ = turn["status"].(string)
+	}
+	st.add(func(c *statsCounters) {
+		c.TurnsRun++
+		switch {
+		case method == "turn/failed" || status == "failed":
+			c.TurnsFailed++
+		case method == "turn/aborted" || status == "interrupted":
+			c.TurnsInterrupted++
+		default:
+			c.TurnsCompleted++
+		}
+		if timed {
+			c.timedTurns++
+			c.turnTime += elapsed
+		}
+	})
+}
+
+// sessionExited counts the turns still running on an exited session's
+// threads as failed.
+func (st *serverStats) sessionExited(threadIDs []string) {
+	for _, threadID := range threadIDs {
+		st.mu.Lock()
+		_, running := st.turnStarts[threadID]
+		st.mu.Unlock()
+		if running {
+			st.turnFinished(threadID, "turn/failed", nil)
+		}
+	}
+}
+
+// approvalResolved counts an answered approval request by its decision.
+func (st *serverStats) approvalResolved(result any) {
+	object, _ := result.(map[string]any)
+	decision, _ := object["decision"].(string)
+	granted := false
+	switch decision {
+	case "accept", "acceptForSession", "approved", "approved_for_session":
+		granted = true
+	case "":
+		return
+	}
+	st.add(func(c *statsCounters) {
+		if granted {
+			c.ApprovalsGranted++
+		} else {
+			c.ApprovalsDenied++
+		}
+	})
+}
+
+func (st *serverStats) eventStored() {
+	st.add(func(c *statsCounters) { c.EventsStored++ })
+}
+
+type statsOverview struct {
+	Day          string        `json:"day"`
+	StartedAt    int64         `json:"startedAt"`
+	Threads      threadCounts  `json:"threads"`
+	TurnsRunning int           `json:"turnsRunning"`
+	Today        statsCounters `json:"today"`
+	SinceStart   statsCounters `json:"sinceStart"`
+	Storage      *storageStats `json:"storage,omitempty"`
+	SSEClients   int           `json:"sseClients"`
+}
+
+type threadCounts struct {
+	Total        int `json:"total"`
+	CreatedToday int `json:"createdToday"`
+}
+
+// handleStatsOverview reports totals for dashboards: threads created today,
+// turns run and their average duration, approval decisions, events stored
+// and open SSE streams.
+func (s *Server) handleStatsOverview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	st := s.stats
+	st.mu.Lock()
+	st.rollLocked()
+	overview := statsOverview{
+		Day:          st.day,
+		StartedAt:    st.startedAt.UnixMilli(),
+		TurnsRunning: len(st.turnStarts),
+		Today:        st.today.view(),
+		SinceStart:   st.sinceStart.view(),
+	}
+	st.mu.Unlock()
+
+	midnight, _ := time.Parse(time.DateOnly, overview.Day)
+	for _, meta := range s.threadIndex.List() {
+		overview.Threads.Total++
+		if meta.ImportedAt == 0 && meta.CreatedAt >= midnight.UnixMilli() {
+			overview.Threads.CreatedToday++
+		}
+	}
+	if stats, err := s.storageStats(); err == nil {
+		overview.Storage = &stats
+	}
+	overview.SSEClients = s.sseStreams.stats().Total
+	writeJSON(w, http.StatusOK, overview)
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStatsOverviewCountsTurnsAndApprovals(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	stream := openSSE(t, s.http.URL, threadID, "")
+	defer stream.Body.Close()
+
+	_ = postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "hello stats"}}})
+	acceptNextApproval(t, s.http.URL, threadID, stream)
+	waitForSSEEvent(t, stream, func(event sseEvent) bool {
+		return parseJSON(t, event.Data)["method"] == "turn/completed"
+	}, 10*time.Second)
+
+	resp, overview := doJSON(t, http.MethodGet, s.http.URL+"/api/stats/overview", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected stats, got %d", resp.StatusCode)
+	}
+	today := overview["today"].(map[string]any)
+	if today["turnsRun"] != float64(1) || today["turnsCompleted"] != float64(1) || today["approvalsGranted"] != float64(1) || today["approvalsDenied"] != float64(0) {
+		t.Fatalf("expected one completed turn and one granted approval, got %v", today)
+	}
+	if today["eventsStored"].(float64) < 3 {
+		t.Fatalf("expected stored events to be counted, got %v", today)
+	}
+	if threads := overview["threads"].(map[string]any); threads["createdToday"] != float64(1) || threads["total"] != float64(1) {
+		t.Fatalf("expected one thread created today, got %v", threads)
+	}
+	if overview["sseClients"] != float64(1) || overview["turnsRunning"] != float64(0) {
+		t.Fatalf("expected one open stream and no running turns, got %v", overview)
+	}
+}
+
+func TestServerStatsRollsOverAndTimesTurns(t *testing.T) {
+	now := time.Date(2026, 3, 1, 23, 59, 0, 0, time.UTC)
+	st := newServerStats()
+	st.now = func() time.Time { return now }
+	st.day = "2026-03-01"
+
+	st.turnStarted("t-1")
+	now = now.Add(2 * time.Second)
+	st.turnFinished("t-1", "turn/completed", map[string]any{"turn": map[string]any{"status": "interrupted"}})
+	st.approvalResolved(map[string]any{"decision": "denied"})
+	if st.today.TurnsInterrupted != 1 || st.today.view().AvgTurnDurationMs != 2000 || st.today.ApprovalsDenied != 1 {
+		t.Fatalf("unexpected counters: %+v", st.today)
+	}
+
+	st.turnStarted("t-2")
+	now = now.Add(time.Minute)
+	st.sessionExited([]string{"t-2", "t-3"})
+	if st.day != "2026-03-02" || st.today.TurnsFailed != 1 || st.today.TurnsRun != 1 || st.sinceStart.TurnsRun != 2 {
+		t.Fatalf("expected a new day with the crashed turn failed, got %s %+v %+v", st.day, st.today, st.sinceStart)
+	}
+	if len(st.turnStarts) != 0 {
+		t.Fatalf("expected no running turns, got %v", st.turnStarts)
+	}
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	"darkhold-go/internal/events"
+	"darkhold-go/internal/threads"
+)
+
+const (
+	// storageCheckInterval is how often the event store size is checked
+	// against --max-event-store-size.
+	storageCheckInterval = time.Minute
+	// storageLowWater is the fraction of the cap eviction shrinks the store
+	// to, so a store hovering at the limit is not trimmed on every check.
+	storageLowWater = 0.9
+)
+
+// storageStats is the event store section of /api/health.
+type storageStats struct {
+	EventBytes     int64 `json:"eventBytes"`
+	ThreadLogs     int   `json:"threadLogs"`
+	MaxBytes       int64 `json:"maxBytes,omitempty"`
+	EvictedThreads int64 `json:"evictedThreads"`
+	EvictedBytes   int64 `json:"evictedBytes"`
+}
+
+func (s *Server) storageStats() (storageStats, error) {
+	logs, err := s.eventStore.Logs()
+	if err != nil {
+		return storageStats{}, err
+	}
+	stats := storageStats{ThreadLogs: len(logs), MaxBytes: s.cfg.MaxEventStoreBytes}
+	for _, l := range logs {
+		stats.EventBytes += l.Size
+	}
+	s.storageMu.Lock()
+	stats.EvictedThreads = s.evictedThreads
+	stats.EvictedBytes = s.evictedBytes
+	s.storageMu.Unlock()
+	return stats, nil
+}
+
+// recordThreadArchived tracks archive state from successful thread/archive
+// and thread/unarchive calls so eviction can prefer archived threads, and
+// uploads newly archived threads when S3 archiving is configured.
+func (s *Server) recordThreadArchived(threadID, method string) {
+	if threadID == "" || (method != "thread/archive" && method != "thread/unarchive") {
+		return
+	}
+	_, err := s.threadIndex.Update(threadID, func(m *threads.Metadata) error {
+		if method == "thread/archive" {
+			m.ArchivedAt = time.Now().UnixMilli()
+		} else {
+			m.ArchivedAt = 0
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[threads] failed to record %s for %s: %v", method, threadID, err)
+	}
+	if method == "thread/archive" && s.archiver != nil {
+		go func() {
+			if _, err := s.uploadArchive(context.Background(), threadID); err != nil {
+				log.Printf("[archive] failed to upload archived thread %s: %v", threadID, err)
+			}
+		}()
+	}
+}
+
+// metadataByLogKey maps event log keys back to known thread metadata. Logs
+// with no metadata belong to a thread whose ID is the key itself.
+func (s *Server) metadataByLogKey() map[string]threads.Metadata {
+	byKey := map[string]threads.Metadata{}
+	for _, meta := range s.threadIndex.List() {
+		byKey[s.eventStore.Key(meta.ThreadID)] = meta
+	}
+	return byKey
+}
+
+// liveThreadKeys returns the log keys of threads bound to an open session.
+func (s *Server) liveThreadKeys() map[string]bool {
+	live := map[string]bool{}
+	s.sessionsMu.RLock()
+	defer s.sessionsMu.RUnlock()
+	for threadID, sessionID := range s.threadToSession {
+		if sess, ok := s.sessions[sessionID]; ok {
+			sess.mu.Lock()
+			if !sess.closed {
+				live[s.eventStore.Key(threadID)] = true
+			}
+			sess.mu.Unlock()
+		}
+	}
+	return live
+}
+
+func (s *Server) storageGuard() {
+	for {
+		s.enforceStorageQuota()
+		select {
+		case <-s.reaperStop:
+			return
+		case <-time.After(storageCheckInterval):
+		}
+	}
+}
+
+type evictionCandidate struct {
+	threads.Metadata
+	log events.ThreadLog
+}
+
+// enforceStorageQuota evicts thread logs while the store is over
+// --max-event-store-size: archived threads first, then the least recently
+// written. Threads bound to a live session are never evicted. Each evicted
+// thread gets a darkhold/storage/evicted event so clients know why its
+// history is gone.
+func (s *Server) enforceStorageQuota() {
+	limit := s.cfg.MaxEventStoreBytes
+	if limit <= 0 {
+		return
+	}
+	s.storageMu.Lock()
+	defer s.storageMu.Unlock()
+	logs, err := s.eventStore.Logs()
+	if err != nil {
+		log.Printf("[storage] failed to list event logs: %v", err)
+		return
+	}
+	var total int64
+	for _, l := range logs {
+		total += l.Size
+	}
+	if total <= limit {
+		return
+	}
+
+	byKey := s.metadataByLogKey()
+	live := s.liveThreadKeys()
+
+	candidates := make([]evictionCandidate, 0, len(logs))
+	for _, l := range logs {
+		if live[l.Key] {
+			continue
+		}
+		meta, ok := byKey[l.Key]
+		if !ok {
+			meta = threads.Metadata{ThreadID: l.Key}
+		}
+		candidates = append(candidates, evictionCandidate{Metadata: meta, log: l})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		ai, aj := candidates[i].ArchivedAt != 0, candidates[j].ArchivedAt != 0
+		if ai != aj {
+			return ai
+		}
+		return candidates[i].log.ModTime.Before(candidates[j].log.ModTime)
+	})
+
+	target := int64(float64(limit) * storageLowWater)
+	for _, c := range candidates {
+		if total <= target {
+			break
+		}
+		if err := s.eventStore.Delete(c.ThreadID); err != nil {
+			log.Printf("[storage] failed to evict thread %s: %v", c.ThreadID, err)
+			continue
+		}
+		total -= c.log.Size
+		s.evictedThreads++
+		s.evictedBytes += c.log.Size
+		log.Printf("[storage] evicted thread %s (%d bytes) to stay under %d bytes", c.ThreadID, c.log.Size, limit)
+
+		reason := "lru"
+		if c.ArchivedAt != 0 {
+			reason = "archived"
+		}
+		notice, _ := json.Marshal(map[string]any{
+			"method": "darkhold/storage/evicted",
+			"params": map[string]any{"threadId": c.ThreadID, "bytes": c.log.Size, "reason": reason, "maxBytes": limit},
+		})
+		s.publishThreadEvent(c.ThreadID, string(notice))
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/threads"
+)
+
+func TestStorageQuotaEvictsArchivedThenOldest(t *testing.T) {
+	// Start uncapped so the background guard stays off; the cap is set below
+	// once the log sizes are known.
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	padding := strings.Repeat("x", 2000)
+	base := time.Now().Add(-time.Hour)
+	for i, threadID := range []string{"thread-old", "thread-archived", "thread-recent"} {
+		s.app.publishThreadEvent(threadID, `{"method":"darkhold/test","params":{"text":"`+padding+`"}}`)
+		modTime := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(filepath.Join(s.store.RootDir, threadID+".jsonl"), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.app.threadIndex.Update("thread-archived", func(m *threads.Metadata) error {
+		m.ArchivedAt = time.Now().UnixMilli()
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := s.app.storageStats()
+	if err != nil || stats.ThreadLogs != 3 {
+		t.Fatalf("unexpected stats: %+v %v", stats, err)
+	}
+	// Leave room for two logs: the archived thread goes first even though
+	// thread-old was written earlier.
+	s.app.cfg.MaxEventStoreBytes = stats.EventBytes - 100
+	s.app.enforceStorageQuota()
+
+	archived, _ := s.store.Read("thread-archived")
+	if len(archived) != 1 || !strings.Contains(archived[0], "darkhold/storage/evicted") || !strings.Contains(archived[0], `"reason":"archived"`) {
+		t.Fatalf("expected archived thread to be evicted, got %v", archived)
+	}
+	if old, _ := s.store.Read("thread-old"); len(old) != 1 || strings.Contains(old[0], "evicted") {
+		t.Fatalf("expected oldest active thread to survive, got %v", old)
+	}
+
+	resp, payload := doJSON(t, http.MethodGet, s.http.URL+"/api/health", nil)
+	storage, _ := payload["storage"].(map[string]any)
+	if resp.StatusCode != http.StatusOK || storage["evictedThreads"] != float64(1) || storage["maxBytes"] == nil {
+		t.Fatalf("unexpected health: %d %v", resp.StatusCode, payload)
+	}
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// A supervised component that panics or fails is restarted after
+	// supervisorBackoffMin, doubling up to supervisorBackoffMax while it
+	// keeps failing. A run longer than supervisorStableAfter resets the
+	// backoff.
+	supervisorBackoffMin  = time.Second
+	supervisorBackoffMax  = time.Minute
+	supervisorStableAfter = 5 * time.Minute
+)
+
+// supervisorStats counts, per component, the panics recovered and the
+// restarts that followed them, for /metrics.
+type supervisorStats struct {
+	mu       sync.Mutex
+	panics   map[string]int64
+	restarts map[string]int64
+}
+
+func (st *supervisorStats) count(counts *map[string]int64, component string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if *counts == nil {
+		*counts = map[string]int64{}
+	}
+	(*counts)[component]++
+}
+
+// snapshot returns the component names with a count, sorted, and copies of
+// both counters.
+func (st *supervisorStats) snapshot() ([]string, map[string]int64, map[string]int64) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	panics := make(map[string]int64, len(st.panics))
+	restarts := make(map[string]int64, len(st.restarts))
+	seen := map[string]bool{}
+	for component, n := range st.panics {
+		panics[component] = n
+		seen[component] = true
+	}
+	for component, n := range st.restarts {
+		restarts[component] = n
+		seen[component] = true
+	}
+	components := make([]string, 0, len(seen))
+	for component := range seen {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+	return components, panics, restarts
+}
+
+// recordPanic logs a recovered panic with the stack of the goroutine that
+// raised it and counts it against component.
+func (s *Server) recordPanic(component string, value any, stack []byte) {
+	log.Printf("[supervisor] %s panicked: %v\n%s", component, value, stack)
+	s.supervisor.count(&s.supervisor.panics, component)
+}
+
+// recoverPanic, deferred, stops a panic in the calling goroutine from taking
+// the process down, recording it against component.
+func (s *Server) recoverPanic(component string) {
+	if value := recover(); value != nil {
+		s.recordPanic(component, value, debug.Stack())
+	}
+}
+
+// supervise runs fn, a background loop that returns on shutdown, and
+// restarts it with exponential backoff whenever it panics.
+func (s *Server) supervise(component string, fn func()) {
+	backoff := supervisorBackoffMin
+	for {
+		started := time.Now()
+		if !s.runRecovered(component, fn) {
+			return
+		}
+		if time.Since(started) >= supervisorStableAfter {
+			backoff = supervisorBackoffMin
+		}
+		log.Printf("[supervisor] restarting %s in %s", component, backoff)
+		select {
+		case <-s.reaperStop:
+			return
+		case <-time.After(backoff):
+		}
+		s.supervisor.count(&s.supervisor.restarts, component)
+		backoff = min(2*backoff, supervisorBackoffMax)
+	}
+}
+
+// runRecovered calls fn and reports whether it panicked.
+func (s *Server) runRecovered(component string, fn func()) (panicked bool) {
+	defer func() {
+		if value := recover(); value != nil {
+			s.recordPanic(component, value, debug.Stack())
+			panicked = true
+		}
+	}()
+	fn()
+	return false
+}
+
+// ServeSupervised runs serve, such as an http.Server's ListenAndServe,
+// until it returns http.ErrServerClosed. When it panics, or fails after it
+// has been serving for a second, it is restarted with exponential backoff;
+// an immediate failure, such as a port already in use, is returned.
+func (s *Server) ServeSupervised(component string, serve func() error) error {
+	backoff := supervisorBackoffMin
+	for attempt := 0; ; attempt++ {
+		started := time.Now()
+		var err error
+		panicked := s.runRecovered(component, func() { err = serve() })
+		if errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		if !panicked && attempt == 0 && time.Since(started) < time.Second {
+			return err
+		}
+		if time.Since(started) >= supervisorStableAfter {
+			backoff = supervisorBackoffMin
+		}
+		if err != nil {
+			log.Printf("[supervisor] %s failed: %v", component, err)
+		}
+		log.Printf("[supervisor] restarting %s in %s", component, backoff)
+		select {
+		case <-s.reaperStop:
+			return http.ErrServerClosed
+		case <-time.After(backoff):
+		}
+		s.supervisor.count(&s.supervisor.restarts, component)
+		backoff = min(2*backoff, supervisorBackoffMax)
+	}
+}
+
+// panicResponseWriter notes whether a response was started, so a handler
+// that panics before writing anything still gets an error response.
+type panicResponseWriter struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (w *panicResponseWriter) WriteHeader(status int) {
+	w.wrote = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *panicResponseWriter) Write(data []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(data)
+}
+
+// Unwrap lets http.ResponseController, SSE and WebSocket upgrades reach the
+// connection's own writer.
+func (w *panicResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// recoverHandlerPanics answers a request whose handler panicked with a 500
+// INTERNAL error, or aborts it when part of the response was already sent,
+// after logging the panic and its stack. http.ErrAbortHandler passes through
+// unlogged, as handlers use it to cut a response short on purpose.
+func (s *Server) recoverHandlerPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pw := &panicResponseWriter{ResponseWriter: w}
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+			if value == http.ErrAbortHandler {
+				panic(value)
+			}
+			s.recordPanic("http", value, debug.Stack())
+			if pw.wrote {
+				panic(http.ErrAbortHandler)
+			}
+			writeError(w, http.StatusInternalServerError, errCodeInternal, "Internal server error.")
+		}()
+		next.ServeHTTP(pw, r)
+	})
+}
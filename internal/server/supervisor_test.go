@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSuperviseRestartsAfterPanic(t *testing.T) {
+	s := &Server{reaperStop: make(chan struct{})}
+	var runs atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		s.supervise("test-loop", func() {
+			if runs.Add(1) == 1 {
+				panic("boom")
+			}
+		})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the loop to be restarted and then return")
+	}
+	if runs.Load() != 2 {
+		t.Fatalf("expected 2 runs, got %d", runs.Load())
+	}
+	_, panics, restarts := s.supervisor.snapshot()
+	if panics["test-loop"] != 1 || restarts["test-loop"] != 1 {
+		t.Fatalf("expected one panic and one restart, got %v %v", panics, restarts)
+	}
+}
+
+func TestServeSupervisedReturnsImmediateFailures(t *testing.T) {
+	s := &Server{reaperStop: make(chan struct{})}
+	bind := errors.New("address already in use")
+	if err := s.ServeSupervised("test-listener", func() error { return bind }); err != bind {
+		t.Fatalf("expected the first failure to be returned, got %v", err)
+	}
+	closed := 0
+	err := s.ServeSupervised("test-listener", func() error {
+		closed++
+		if closed == 1 {
+			panic("listener exploded")
+		}
+		return http.ErrServerClosed
+	})
+	if !errors.Is(err, http.ErrServerClosed) || closed != 2 {
+		t.Fatalf("expected a restart after the panic, got %v after %d runs", err, closed)
+	}
+}
+
+func TestRecoverHandlerPanics(t *testing.T) {
+	s := &Server{}
+	handler := s.recoverHandlerPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params map[string]any
+		_ = params["missing"].(string)
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/thing", nil))
+	var body apiError
+	if rec.Code != http.StatusInternalServerError || json.Unmarshal(rec.Body.Bytes(), &body) != nil || body.Code != errCodeInternal {
+		t.Fatalf("expected a 500 INTERNAL error, got %d %s", rec.Code, rec.Body.String())
+	}
+	if _, panics, _ := s.supervisor.snapshot(); panics["http"] != 1 {
+		t.Fatalf("expected the panic to be counted, got %v", panics)
+	}
+
+	aborting := s.recoverHandlerPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("partial"))
+		panic(http.ErrAbortHandler)
+	}))
+	func() {
+		defer func() {
+			if value := recover(); value != http.ErrAbortHandler {
+				t.Fatalf("expected ErrAbortHandler to pass through, got %v", value)
+			}
+		}()
+		aborting.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/thing", nil))
+	}()
+	if _, panics, _ := s.supervisor.snapshot(); panics["http"] != 1 {
+		t.Fatalf("expected ErrAbortHandler not to be counted, got %v", panics)
+	}
+}
+
+func TestMetricsCountRecoveredPanics(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	s.app.recordPanic("session", "boom", nil)
+	resp, err := http.Get(s.http.URL + "/api/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	metrics, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(metrics), `darkhold_panics_recovered_total{component="session"} 1`) {
+		t.Fatalf("unexpected metrics:\n%s", metrics)
+	}
+}
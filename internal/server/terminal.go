@@ -0,0 +1,279 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/oklog/ulid/v2"
+
+	browserfs "darkhold-go/internal/fs"
+)
+
+const (
+	errCodeTerminalDisabled    = "TERMINAL_DISABLED"
+	errCodeTerminalUnsupported = "TERMINAL_UNSUPPORTED"
+
+	terminalDefaultCols = 80
+	terminalDefaultRows = 24
+
+	// terminalDrainWait is how long output is still forwarded after the
+	// shell exits.
+	terminalDrainWait = 200 * time.Millisecond
+	// terminalHangupWait is how long a shell gets to exit after SIGHUP.
+	terminalHangupWait = 2 * time.Second
+)
+
+var errTerminalUnsupported = errors.New("terminals are not supported on this platform")
+
+// terminal is a shell running on a pseudo-terminal; pty is the master side.
+type terminal struct {
+	pty *os.File
+	cmd *exec.Cmd
+}
+
+// terminalControl is a text frame from the client. Keystrokes travel as
+// binary frames.
+type terminalControl struct {
+	Type string `json:"type"`
+	Cols int    `json:"cols"`
+	Rows int    `json:"rows"`
+}
+
+// terminalRecording writes a session in asciicast v2 format: a header line,
+// then [seconds, code, data] events for output ("o"), input ("i") and
+// resizes ("r").
+type terminalRecording struct {
+	mu      sync.Mutex
+	f       *os.File
+	started time.Time
+}
+
+func openTerminalRecording(path string, cols, rows int, cwd, shell string) (*terminalRecording, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	started := time.Now()
+	header, _ := json.Marshal(map[string]any{
+		"version":   2,
+		"width":     cols,
+		"height":    rows,
+		"timestamp": started.Unix(),
+		"title":     cwd,
+		"env":       map[string]string{"SHELL": shell, "TERM": "xterm-256color"},
+	})
+	if _, err := f.Write(append(header, '\n')); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &terminalRecording{f: f, started: started}, nil
+}
+
+func (rec *terminalRecording) event(code string, data []byte) {
+	if rec == nil {
+		return
+	}
+	line, _ := json.Marshal([]any{time.Since(rec.started).Seconds(), code, string(data)})
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	_, _ = rec.f.Write(append(line, '\n'))
+}
+
+func (rec *terminalRecording) close() {
+	if rec != nil {
+		rec.f.Close()
+	}
+}
+
+// terminalShell is --terminal-shell, else $SHELL, else /bin/sh.
+func (s *Server) terminalShell() string {
+	if s.cfg.TerminalShell != "" {
+		return s.cfg.TerminalShell
+	}
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	return "/bin/sh"
+}
+
+// handleTerminalWS runs an interactive shell in a directory under the
+// browser root and bridges its PTY to a WebSocket. Only the starting
+// directory is checked: the shell runs with darkhold's own privileges. Every
+// session is recorded in <data-dir>/terminals and logged to the audit log.
+func (s *Server) handleTerminalWS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if !s.cfg.TerminalEnabled {
+		writeError(w, http.StatusForbidden, errCodeTerminalDisabled, "the terminal is disabled; start darkhold with --enable-terminal.")
+		return
+	}
+	query := r.URL.Query()
+	cwd, err := browserfs.ResolvePath(query.Get("cwd"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidPath, err.Error())
+		return
+	}
+	if info, err := os.Stat(cwd); err != nil || !info.IsDir() {
+		writeError(w, http.StatusBadRequest, errCodeInvalidPath, "cwd must be a directory.")
+		return
+	}
+	cols := terminalDimension(query.Get("cols"), terminalDefaultCols)
+	rows := terminalDimension(query.Get("rows"), terminalDefaultRows)
+
+	if !terminalSupported {
+		writeError(w, http.StatusNotImplemented, errCodeTerminalUnsupported, errTerminalUnsupported.Error())
+		return
+	}
+
+	// Upgrade before spawning, so a plain GET or a failed handshake never
+	// starts a shell, a recording or audit entries.
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.CloseNow()
+
+	shell := s.terminalShell()
+	term, err := startTerminal(shell, cwd, cols, rows)
+	if err != nil {
+		log.Printf("[terminal] failed to start %s in %s: %v", shell, cwd, err)
+		_ = conn.Close(websocket.StatusInternalError, "cannot start the terminal")
+		return
+	}
+	defer term.pty.Close()
+
+	id := ulid.Make().String()
+	var rec *terminalRecording
+	recordingPath := ""
+	if s.cfg.DataDir != "" {
+		recordingPath = filepath.Join(s.cfg.DataDir, "terminals", id+".cast")
+		if rec, err = openTerminalRecording(recordingPath, cols, rows, cwd, shell); err != nil {
+			log.Printf("[terminal] failed to open recording %s: %v", recordingPath, err)
+			recordingPath = ""
+		}
+	}
+	defer rec.close()
+	s.audit.record(r, "terminal.open", map[string]any{"id": id, "cwd": cwd, "shell": shell, "pid": term.cmd.Process.Pid, "recording": recordingPath})
+
+	exited := make(chan int, 1)
+	go func() {
+		_ = term.cmd.Wait()
+		exited <- term.cmd.ProcessState.ExitCode()
+	}()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// Shell output until the PTY reports EOF, which on Linux happens once
+	// every process holding the terminal has exited.
+	outputDone := make(chan struct{})
+	go func() {
+		defer close(outputDone)
+		buf := make([]byte, 32<<10)
+		for {
+			n, err := term.pty.Read(buf)
+			if n > 0 {
+				rec.event("o", buf[:n])
+				if err := conn.Write(ctx, websocket.MessageBinary, buf[:n]); err != nil {
+					cancel()
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer cancel()
+		for {
+			kind, data, err := conn.Read(ctx)
+			if err != nil {
+				return
+			}
+			if kind == websocket.MessageBinary {
+				rec.event("i", data)
+				if _, err := term.pty.Write(data); err != nil {
+					return
+				}
+				continue
+			}
+			var control terminalControl
+			if err := json.Unmarshal(data, &control); err != nil || control.Type != "resize" {
+				continue
+			}
+			cols, rows := terminalDimension(strconv.Itoa(control.Cols), cols), terminalDimension(strconv.Itoa(control.Rows), rows)
+			if err := term.resize(cols, rows); err == nil {
+				rec.event("r", []byte(strconv.Itoa(cols)+"x"+strconv.Itoa(rows)))
+			}
+		}
+	}()
+
+	reason := "shell exited"
+	var code int
+	select {
+	case <-outputDone:
+		if ctx.Err() != nil {
+			reason = "client disconnected"
+			code = hangUp(term, exited)
+		} else {
+			code = <-exited
+		}
+	case code = <-exited:
+		// Background jobs may still hold the terminal open; flush what the
+		// shell wrote before it exited but do not wait for them.
+		select {
+		case <-outputDone:
+		case <-time.After(terminalDrainWait):
+		}
+	case <-ctx.Done():
+		reason = "client disconnected"
+		code = hangUp(term, exited)
+	}
+	s.audit.record(r, "terminal.close", map[string]any{"id": id, "exitCode": code, "reason": reason})
+	if reason == "shell exited" {
+		frame, _ := json.Marshal(map[string]any{"type": "exit", "code": code})
+		_ = conn.Write(ctx, websocket.MessageText, frame)
+		_ = conn.Close(websocket.StatusNormalClosure, "shell exited")
+	}
+}
+
+// hangUp ends a terminal whose client went away and returns the shell's
+// exit code. Shells that ignore SIGHUP are killed after terminalHangupWait.
+func hangUp(term *terminal, exited <-chan int) int {
+	term.hangup()
+	select {
+	case code := <-exited:
+		return code
+	case <-time.After(terminalHangupWait):
+		term.kill()
+		return <-exited
+	}
+}
+
+// terminalDimension parses a column or row count, falling back to def for
+// missing or implausible values.
+func terminalDimension(raw string, def int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || n < 1 || n > 1000 {
+		return def
+	}
+	return n
+}
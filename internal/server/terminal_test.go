@@ -0,0 +1,146 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+
+	"darkhold-go/internal/config"
+)
+
+func TestTerminalRequiresOptIn(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	resp, body := doJSON(t, http.MethodGet, s.http.URL+"/api/terminal/ws?cwd="+s.baseDir, nil)
+	if resp.StatusCode != http.StatusForbidden || body["code"] != errCodeTerminalDisabled {
+		t.Fatalf("expected TERMINAL_DISABLED, got %d %v", resp.StatusCode, body)
+	}
+}
+
+func TestTerminalRejectsCwdOutsideRoot(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) { cfg.TerminalEnabled = true })
+	defer s.close()
+
+	resp, body := doJSON(t, http.MethodGet, s.http.URL+"/api/terminal/ws?cwd="+t.TempDir(), nil)
+	if resp.StatusCode != http.StatusBadRequest || body["code"] != errCodeInvalidPath {
+		t.Fatalf("expected INVALID_PATH, got %d %v", resp.StatusCode, body)
+	}
+}
+
+func TestTerminalStartsNoShellWithoutAWebSocket(t *testing.T) {
+	dataDir := t.TempDir()
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.TerminalEnabled = true
+		cfg.DataDir = dataDir
+	})
+	defer s.close()
+
+	resp, err := http.Get(s.http.URL + "/api/terminal/ws?cwd=" + s.baseDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 400 {
+		t.Fatalf("expected a plain GET to be refused, got %d", resp.StatusCode)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "audit.jsonl")); !os.IsNotExist(err) {
+		t.Fatalf("expected no audit entries for a refused handshake, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "terminals")); !os.IsNotExist(err) {
+		t.Fatalf("expected no recording for a refused handshake, got %v", err)
+	}
+}
+
+func TestTerminalRunsShellAndRecordsSession(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("terminals are only supported on linux")
+	}
+	dataDir := t.TempDir()
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.TerminalEnabled = true
+		cfg.TerminalShell = "/bin/sh"
+		cfg.DataDir = dataDir
+	})
+	defer s.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	wsURL := "ws" + strings.TrimPrefix(s.http.URL, "http") + "/api/terminal/ws?cols=100&rows=30&cwd=" + s.baseDir
+	conn, _, err := websocket.Dial(ctx, wsURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.CloseNow()
+
+	if err := conn.Write(ctx, websocket.MessageText, []byte(`{"type":"resize","cols":120,"rows":40}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Write(ctx, websocket.MessageBinary, []byte("pwd; echo marker-$((40+2)); exit 3\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	var output strings.Builder
+	var exit map[string]any
+	for exit == nil {
+		kind, data, err := conn.Read(ctx)
+		if websocket.CloseStatus(err) == websocket.StatusInternalError {
+			t.Skipf("cannot open a pseudo-terminal here: %v", err)
+		}
+		if err != nil {
+			t.Fatalf("terminal closed before exit frame: %v (output %q)", err, output.String())
+		}
+		if kind == websocket.MessageBinary {
+			output.Write(data)
+			continue
+		}
+		_ = json.Unmarshal(data, &exit)
+	}
+	if exit["type"] != "exit" || exit["code"] != float64(3) {
+		t.Fatalf("unexpected exit frame %v", exit)
+	}
+	if !strings.Contains(output.String(), "marker-42") || !strings.Contains(output.String(), filepath.Base(s.baseDir)) {
+		t.Fatalf("unexpected output %q", output.String())
+	}
+
+	var actions []string
+	var recording string
+	waitForCondition(t, 5*time.Second, 10*time.Millisecond, func() bool {
+		actions, recording = nil, ""
+		f, err := os.Open(filepath.Join(dataDir, "audit.jsonl"))
+		if err != nil {
+			return false
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var entry auditEntry
+			if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+				actions = append(actions, entry.Action)
+				if path, ok := entry.Details["recording"].(string); ok {
+					recording = path
+				}
+			}
+		}
+		return len(actions) == 2
+	})
+	if actions[0] != "terminal.open" || actions[1] != "terminal.close" || recording == "" {
+		t.Fatalf("unexpected audit log %v (recording %q)", actions, recording)
+	}
+	cast, err := os.ReadFile(recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(string(cast), "\n"); !strings.Contains(lines[0], `"version":2`) || !strings.Contains(string(cast), `"r","120x40"`) || !strings.Contains(string(cast), "marker-") {
+		t.Fatalf("unexpected recording %s", cast)
+	}
+}
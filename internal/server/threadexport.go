@@ -0,0 +1,242 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"darkhold-go/internal/archive"
+	"darkhold-go/internal/events"
+	browserfs "darkhold-go/internal/fs"
+)
+
+var (
+	// identityKeyPattern matches object keys whose string values name a
+	// person: "by", "createdBy", "resolvedBy", "identity", "email", ...
+	identityKeyPattern = regexp.MustCompile(`^(by|[a-z]+By|identity|user|username|userName|email|caller|author)$`)
+	emailPattern       = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	// homeUserPattern matches the user name in home directory paths of any
+	// account, not just the one darkhold runs as.
+	homeUserPattern = regexp.MustCompile(`(/home/|/Users/|[A-Za-z]:\\Users\\)([^/\\\s"'` + "`" + `]+)`)
+)
+
+// threadAnonymizer rewrites exported events so they can be shared publicly:
+// paths under the browser root become relative to it, the home directory
+// becomes ~, the host name and the user names of home directories are
+// replaced, and secrets are redacted as in session transcripts. Each
+// distinct identity or email gets a stable pseudonym (user-1, user-2, ...)
+// within one export, so a reader can still tell people apart.
+type threadAnonymizer struct {
+	root      *regexp.Regexp
+	home      string
+	host      string
+	secrets   *transcriptRedactor
+	pseudonym map[string]string
+	// names holds the identities also replaced in free text, longest first
+	// so a name is replaced before any shorter name inside it. Only
+	// prefixed identities (oidc:..., cert:...) qualify: a bare API key name
+	// such as "owner" is too likely to be an ordinary word.
+	names []string
+}
+
+func (s *Server) newThreadAnonymizer() *threadAnonymizer {
+	a := &threadAnonymizer{secrets: configuredSecretsRedactor(s.cfg), pseudonym: map[string]string{}}
+	if root := strings.TrimRight(browserfs.GetHomeRoot(), `/\`); root != "" {
+		a.root = regexp.MustCompile(regexp.QuoteMeta(root) + `([/\\]|\b)`)
+	}
+	if home, err := os.UserHomeDir(); err == nil && len(strings.TrimRight(home, `/\`)) > 1 {
+		a.home = strings.TrimRight(home, `/\`)
+	}
+	if host, err := os.Hostname(); err == nil && len(host) >= 3 {
+		a.host = host
+	}
+	return a
+}
+
+func (a *threadAnonymizer) pseudonymFor(name string) string {
+	if alias, ok := a.pseudonym[name]; ok {
+		return alias
+	}
+	alias := "user-" + strconv.Itoa(len(a.pseudonym)+1)
+	a.pseudonym[name] = alias
+	if strings.ContainsAny(name, ":@") {
+		i, _ := slices.BinarySearchFunc(a.names, name, func(have, want string) int { return len(want) - len(have) })
+		a.names = slices.Insert(a.names, i, name)
+	}
+	return alias
+}
+
+// collect gives a pseudonym to every identity field in value, so the names
+// are also replaced where they appear in free text.
+func (a *threadAnonymizer) collect(value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if text, ok := item.(string); ok && text != "" && identityKeyPattern.MatchString(key) {
+				a.pseudonymFor(text)
+				continue
+			}
+			a.collect(item)
+		}
+	case []any:
+		for _, item := range v {
+			a.collect(item)
+		}
+	}
+}
+
+func (a *threadAnonymizer) rewrite(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			if text, ok := item.(string); ok && text != "" {
+				switch {
+				case secretKeyPattern.MatchString(key):
+					out[key] = redactedValue
+					continue
+				case identityKeyPattern.MatchString(key):
+					out[key] = a.pseudonymFor(text)
+					continue
+				}
+			}
+			// Keys can be paths too, as in file change maps.
+			out[a.rewriteString(key)] = a.rewrite(item)
+		}
+		return out
+	case []any:
+		for i, item := range v {
+			v[i] = a.rewrite(item)
+		}
+		return v
+	case string:
+		return a.secrets.redactString(a.rewriteString(v))
+	}
+	return value
+}
+
+func (a *threadAnonymizer) rewriteString(text string) string {
+	if a.root != nil {
+		text = a.root.ReplaceAllStringFunc(text, func(match string) string {
+			if strings.HasSuffix(match, "/") || strings.HasSuffix(match, `\`) {
+				return ""
+			}
+			return "."
+		})
+	}
+	if a.home != "" {
+		text = strings.ReplaceAll(text, a.home, "~")
+	}
+	text = homeUserPattern.ReplaceAllString(text, "${1}user")
+	for _, name := range a.names {
+		text = strings.ReplaceAll(text, name, a.pseudonym[name])
+	}
+	text = emailPattern.ReplaceAllStringFunc(text, a.pseudonymFor)
+	if a.host != "" {
+		text = strings.ReplaceAll(text, a.host, "host")
+	}
+	return text
+}
+
+// payload anonymizes one stored event. Payloads that are not JSON objects
+// are redacted as text.
+func (a *threadAnonymizer) payload(payload string) string {
+	var value any
+	if err := json.Unmarshal([]byte(payload), &value); err != nil {
+		return a.secrets.redactString(a.rewriteString(payload))
+	}
+	data, err := json.Marshal(a.rewrite(value))
+	if err != nil {
+		return payload
+	}
+	return string(data)
+}
+
+// handleThreadExport serves a thread's events as a download:
+//
+//	GET ?threadId=&format=jsonl|markdown[&anonymize=true]
+//
+// jsonl (the default) is the stored log, importable with ImportLog; markdown
+// is the transcript the S3 archive writes. anonymize=true rewrites both for
+// sharing as a public bug report (see threadAnonymizer). The records keep
+// their IDs and stamps but lose chain hashes, which no longer match.
+func (s *Server) handleThreadExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	query := r.URL.Query()
+	threadID := strings.TrimSpace(query.Get("threadId"))
+	if threadID == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+		return
+	}
+	format := query.Get("format")
+	if format == "" {
+		format = "jsonl"
+	}
+	if format != "jsonl" && format != "markdown" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "format must be jsonl or markdown.")
+		return
+	}
+	anonymize := query.Get("anonymize") == "true"
+
+	records, err := s.eventStore.ReadRange(threadID, "", 0)
+	if err == nil && len(records) == 0 && s.backfillColdThread(r.Context(), threadID) {
+		records, err = s.eventStore.ReadRange(threadID, "", 0)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
+		return
+	}
+	if len(records) == 0 {
+		writeError(w, http.StatusNotFound, errCodeThreadNotFound, "thread not found.")
+		return
+	}
+	meta, _ := s.threadIndex.Get(threadID)
+	title := meta.Title
+	if anonymize {
+		a := s.newThreadAnonymizer()
+		for _, record := range records {
+			var value any
+			if json.Unmarshal([]byte(record.Payload), &value) == nil {
+				a.collect(value)
+			}
+		}
+		for i := range records {
+			records[i] = events.Record{ID: records[i].ID, Seq: records[i].Seq, Time: records[i].Time, Payload: a.payload(records[i].Payload)}
+		}
+		title = a.secrets.redactString(a.rewriteString(title))
+	}
+
+	var body []byte
+	name := "thread-" + s.eventStore.Key(threadID)
+	if anonymize {
+		name += "-anonymized"
+	}
+	switch format {
+	case "markdown":
+		body = []byte(archive.Transcript(title, events.Payloads(records)))
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		name += ".md"
+	default:
+		var buf bytes.Buffer
+		for _, record := range records {
+			line, _ := json.Marshal(record)
+			buf.Write(append(line, '\n'))
+		}
+		body = buf.Bytes()
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		name += ".jsonl"
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
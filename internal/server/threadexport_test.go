@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	browserfs "darkhold-go/internal/fs"
+)
+
+func TestThreadExportAnonymizes(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+	root := browserfs.GetHomeRoot()
+	home, _ := os.UserHomeDir()
+	event := map[string]any{
+		"method": "item/completed",
+		"params": map[string]any{
+			"item": map[string]any{
+				"type":    "commandExecution",
+				"command": "cat " + root + "/src/main.go > /Users/bob/notes.txt",
+				"cwd":     root,
+				"aggregatedOutput": "mail ada@example.com, key sk-abcdefghijklmnopqrstu, config at " + home + "/.codex\n" +
+					"resolved by oidc:ada@corp.example",
+				"changes": map[string]any{root + "/README.md": map[string]any{"kind": "update"}},
+			},
+		},
+	}
+	payload, _ := json.Marshal(event)
+	s.app.publishThreadEvent("thread-public", string(payload))
+	s.app.publishThreadEvent("thread-public", `{"method":"darkhold/interaction/resolved","params":{"requestId":7,"source":"http","by":"oidc:ada@corp.example","apiToken":"abc123secret"}}`)
+
+	get := func(query string) (*http.Response, string) {
+		t.Helper()
+		resp, err := http.Get(s.http.URL + "/api/thread/export?threadId=thread-public" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+	resp, raw := get("")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(raw, root) || !strings.Contains(resp.Header.Get("Content-Disposition"), "thread-thread-public.jsonl") {
+		t.Fatalf("expected the plain export to be the stored log: %d %s", resp.StatusCode, raw)
+	}
+
+	resp, anonymized := get("&anonymize=true")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("Content-Disposition"), "-anonymized.jsonl") {
+		t.Fatalf("unexpected response %d %v", resp.StatusCode, resp.Header)
+	}
+	for _, leaked := range []string{root, home, "bob", "ada@example.com", "sk-abcdefghijklmnopqrstu", "oidc:ada", "abc123secret"} {
+		if strings.Contains(anonymized, leaked) {
+			t.Fatalf("anonymized export still contains %q:\n%s", leaked, anonymized)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(anonymized), "\n")
+	var first, second struct {
+		Payload string `json:"payload"`
+	}
+	_ = json.Unmarshal([]byte(lines[0]), &first)
+	_ = json.Unmarshal([]byte(lines[1]), &second)
+	for _, want := range []string{`cat src/main.go \u003e /Users/user/notes.txt`, `"cwd":"."`, `"README.md":`, `~/.codex`, `resolved by user-1`, `[REDACTED]`} {
+		if !strings.Contains(first.Payload, want) {
+			t.Fatalf("expected %q in %s", want, first.Payload)
+		}
+	}
+	if !strings.Contains(second.Payload, `"by":"user-1"`) || !strings.Contains(second.Payload, `"apiToken":"[REDACTED]"`) {
+		t.Fatalf("unexpected identity rewrite %s", second.Payload)
+	}
+
+	if resp, _ := get("&format=markdown&anonymize=true"); resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/markdown") {
+		t.Fatalf("unexpected markdown export %d %v", resp.StatusCode, resp.Header)
+	}
+	if resp, _ := get("&format=pdf"); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected an unknown format to be rejected, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,286 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"darkhold-go/internal/events"
+	browserfs "darkhold-go/internal/fs"
+	"darkhold-go/internal/threads"
+)
+
+// threadListMaxLimit caps one page of GET /api/threads.
+const threadListMaxLimit = 500
+
+// threadListSorts maps accepted sort names to their default descending order.
+var threadListSorts = map[string]bool{
+	"threadId":  false,
+	"updatedAt": true,
+	"createdAt": true,
+	"title":     false,
+	"cwd":       false,
+}
+
+// threadListEntry is one GET /api/threads result: the thread's metadata plus
+// its live state. LastActivityAt is the later of the last event written to
+// its log and the last metadata change.
+type threadListEntry struct {
+	threads.Metadata
+	LastActivityAt      int64 `json:"lastActivityAt,omitempty"`
+	PendingInteractions int   `json:"pendingInteractions"`
+	ActiveTurn          bool  `json:"activeTurn"`
+	// Unread counts agent activity after the caller's read receipt.
+	Unread          int    `json:"unread"`
+	LastReadEventID string `json:"lastReadEventId,omitempty"`
+}
+
+// threadListCursor is the position after the last entry of a page, encoded
+// as URL-safe base64 JSON. Positions are keys rather than offsets, so threads
+// created or changed between pages do not shift later pages.
+type threadListCursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d"`
+	Num  int64  `json:"n,omitempty"`
+	Str  string `json:"k,omitempty"`
+	ID   string `json:"id"`
+}
+
+// threadSortKey is the value an entry is ordered by, with the thread ID as
+// tie-breaker.
+type threadSortKey struct {
+	num int64
+	str string
+	id  string
+}
+
+func threadListKey(sortBy string, e threadListEntry) threadSortKey {
+	key := threadSortKey{id: e.ThreadID}
+	switch sortBy {
+	case "updatedAt":
+		key.num = e.LastActivityAt
+	case "createdAt":
+		key.num = e.CreatedAt
+	case "title":
+		key.str = strings.ToLower(e.Title)
+	case "cwd":
+		key.str = e.Cwd
+	}
+	return key
+}
+
+func (a threadSortKey) compare(b threadSortKey) int {
+	switch {
+	case a.num != b.num:
+		if a.num < b.num {
+			return -1
+		}
+		return 1
+	case a.str != b.str:
+		return strings.Compare(a.str, b.str)
+	default:
+		return strings.Compare(a.id, b.id)
+	}
+}
+
+// threadLastActivity is the later of a thread's last log write and its last
+// metadata change.
+func threadLastActivity(meta threads.Metadata, log events.ThreadLog) time.Time {
+	last := log.ModTime
+	if updated := time.UnixMilli(meta.UpdatedAt); meta.UpdatedAt != 0 && updated.After(last) {
+		last = updated
+	}
+	return last
+}
+
+// threadLiveState counts unresolved interactions per thread and reports
+// threads with a turn in progress on an open session.
+func (s *Server) threadLiveState() (pending map[string]int, active map[string]bool) {
+	pending, active = map[string]int{}, map[string]bool{}
+	s.sessionsMu.RLock()
+	defer s.sessionsMu.RUnlock()
+	for threadID, requests := range s.pendingResponses {
+		pending[threadID] = len(requests)
+	}
+	for _, sess := range s.sessions {
+		sess.mu.Lock()
+		if !sess.closed {
+			for _, threadID := range sess.activeTurnIDs {
+				active[threadID] = true
+			}
+		}
+		sess.mu.Unlock()
+	}
+	return pending, active
+}
+
+// handleThreads lists darkhold's thread metadata. Query parameters:
+//
+//	sort        threadId (default), updatedAt, createdAt, title or cwd
+//	order       asc or desc; timestamps default to desc, the rest to asc
+//	cwdPrefix   only threads whose cwd is this directory or below it
+//	tag         only threads with this tag; repeat to require several
+//	pendingApproval, activeTurn
+//	            true or false to filter on live state
+//	limit       page size (1-500); without it every match is returned
+//	cursor      nextCursor from the previous page
+func (s *Server) handleThreads(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	query := r.URL.Query()
+	sortBy := query.Get("sort")
+	if sortBy == "" {
+		sortBy = "threadId"
+	} else if sortBy == "created" {
+		sortBy = "createdAt"
+	}
+	desc, ok := threadListSorts[sortBy]
+	if !ok {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "sort must be one of threadId, updatedAt, createdAt, title or cwd.")
+		return
+	}
+	switch query.Get("order") {
+	case "":
+	case "asc":
+		desc = false
+	case "desc":
+		desc = true
+	default:
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "order must be asc or desc.")
+		return
+	}
+	pendingFilter, err := optionalBoolParam(query.Get("pendingApproval"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "pendingApproval must be true or false.")
+		return
+	}
+	activeFilter, err := optionalBoolParam(query.Get("activeTurn"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "activeTurn must be true or false.")
+		return
+	}
+	limit := 0
+	if raw := query.Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > threadListMaxLimit {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "limit must be between 1 and 500.")
+			return
+		}
+	}
+	var after *threadSortKey
+	if raw := query.Get("cursor"); raw != "" {
+		cursor, err := decodeThreadListCursor(raw)
+		if err != nil || cursor.Sort != sortBy || cursor.Desc != desc {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "cursor is invalid or was issued for a different sort.")
+			return
+		}
+		after = &threadSortKey{num: cursor.Num, str: cursor.Str, id: cursor.ID}
+	}
+	cwdPrefix := ""
+	if raw := strings.TrimSpace(query.Get("cwdPrefix")); raw != "" {
+		cwdPrefix = filepath.Clean(raw)
+	}
+	tags := query["tag"]
+
+	logs, err := s.eventStore.Logs()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
+		return
+	}
+	logsByKey := make(map[string]events.ThreadLog, len(logs))
+	for _, l := range logs {
+		logsByKey[l.Key] = l
+	}
+	pending, active := s.threadLiveState()
+
+	entries := []threadListEntry{}
+	for _, meta := range s.threadIndex.List() {
+		entry := threadListEntry{Metadata: meta, PendingInteractions: pending[meta.ThreadID], ActiveTurn: active[meta.ThreadID]}
+		if last := threadLastActivity(meta, logsByKey[s.eventStore.Key(meta.ThreadID)]); !last.IsZero() {
+			entry.LastActivityAt = last.UnixMilli()
+		}
+		if cwdPrefix != "" && (meta.Cwd == "" || !browserfs.PathWithin(filepath.Clean(meta.Cwd), cwdPrefix)) {
+			continue
+		}
+		if !hasAllTags(meta, tags) {
+			continue
+		}
+		if pendingFilter != nil && *pendingFilter != (entry.PendingInteractions > 0) {
+			continue
+		}
+		if activeFilter != nil && *activeFilter != entry.ActiveTurn {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	ordered := func(a, b threadSortKey) int {
+		if desc {
+			return b.compare(a)
+		}
+		return a.compare(b)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return ordered(threadListKey(sortBy, entries[i]), threadListKey(sortBy, entries[j])) < 0
+	})
+	if after != nil {
+		start := sort.Search(len(entries), func(i int) bool {
+			return ordered(*after, threadListKey(sortBy, entries[i])) < 0
+		})
+		entries = entries[start:]
+	}
+
+	response := map[string]any{"threads": entries}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+		last := threadListKey(sortBy, entries[limit-1])
+		response["threads"] = entries
+		response["nextCursor"] = encodeThreadListCursor(threadListCursor{Sort: sortBy, Desc: desc, Num: last.num, Str: last.str, ID: last.id})
+	}
+	s.withUnread(readerKey(r, query.Get("clientId")), entries, logsByKey)
+	writeJSON(w, http.StatusOK, response)
+}
+
+func hasAllTags(meta threads.Metadata, tags []string) bool {
+	for _, tag := range tags {
+		if !meta.HasTag(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// optionalBoolParam parses an optional true/false query parameter; nil means
+// it was not given.
+func optionalBoolParam(raw string) (*bool, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func encodeThreadListCursor(cursor threadListCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeThreadListCursor(raw string) (threadListCursor, error) {
+	var cursor threadListCursor
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(data, &cursor)
+	return cursor, err
+}
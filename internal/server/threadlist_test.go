@@ -0,0 +1,106 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"darkhold-go/internal/threads"
+)
+
+func threadListIDs(t *testing.T, body map[string]any) []string {
+	t.Helper()
+	entries, _ := body["threads"].([]any)
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.(map[string]any)["threadId"].(string))
+	}
+	return ids
+}
+
+func TestThreadListSortsFiltersAndPaginates(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	repo := filepath.Join(s.baseDir, "repo")
+	for i, thread := range []threads.Metadata{
+		{ThreadID: "t-a", Title: "Bravo", Cwd: repo, CreatedAt: 300, Tags: []string{"bug"}},
+		{ThreadID: "t-b", Title: "alpha", Cwd: filepath.Join(repo, "sub"), CreatedAt: 100, Tags: []string{"bug", "ui"}},
+		{ThreadID: "t-c", Title: "Charlie", Cwd: filepath.Join(s.baseDir, "repository"), CreatedAt: 200},
+	} {
+		if i == 0 {
+			// Only t-a has logged activity.
+			s.app.publishThreadEvent(thread.ThreadID, `{"method":"turn/started","params":{"threadId":"t-a","turnId":"x"}}`)
+		}
+		if _, err := s.app.threadIndex.Update(thread.ThreadID, func(m *threads.Metadata) error {
+			*m = thread
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sess, _ := injectSession(s.app, 99)
+	defer removeSession(s.app, sess)
+	sess.activeTurnIDs["turn-1"] = "t-c"
+	s.app.sessionsMu.Lock()
+	s.app.pendingResponses["t-b"] = map[string]pendingInteraction{"7": {sessionID: 99, requestID: 7}}
+	s.app.sessionsMu.Unlock()
+
+	list := func(query url.Values) (int, map[string]any) {
+		resp, body := doJSON(t, http.MethodGet, s.http.URL+"/api/threads?"+query.Encode(), nil)
+		return resp.StatusCode, body
+	}
+	cases := []struct {
+		query url.Values
+		want  []string
+	}{
+		{url.Values{}, []string{"t-a", "t-b", "t-c"}},
+		{url.Values{"sort": {"created"}}, []string{"t-a", "t-c", "t-b"}},
+		{url.Values{"sort": {"updatedAt"}}, []string{"t-a", "t-c", "t-b"}},
+		{url.Values{"sort": {"updatedAt"}, "order": {"asc"}}, []string{"t-b", "t-c", "t-a"}},
+		{url.Values{"sort": {"title"}}, []string{"t-b", "t-a", "t-c"}},
+		{url.Values{"sort": {"cwd"}, "order": {"desc"}}, []string{"t-c", "t-b", "t-a"}},
+		{url.Values{"cwdPrefix": {repo}}, []string{"t-a", "t-b"}},
+		{url.Values{"tag": {"BUG", "ui"}}, []string{"t-b"}},
+		{url.Values{"pendingApproval": {"true"}}, []string{"t-b"}},
+		{url.Values{"activeTurn": {"false"}}, []string{"t-a", "t-b"}},
+	}
+	for _, c := range cases {
+		status, body := list(c.query)
+		if got := threadListIDs(t, body); status != http.StatusOK || len(got) != len(c.want) || (len(got) > 0 && got[0] != c.want[0]) || got[len(got)-1] != c.want[len(c.want)-1] {
+			t.Fatalf("%v: expected %v, got %d %v", c.query, c.want, status, got)
+		}
+	}
+
+	status, first := list(url.Values{"sort": {"createdAt"}, "limit": {"2"}})
+	cursor, _ := first["nextCursor"].(string)
+	if status != http.StatusOK || cursor == "" || len(threadListIDs(t, first)) != 2 {
+		t.Fatalf("unexpected first page %d %v", status, first)
+	}
+	status, second := list(url.Values{"sort": {"createdAt"}, "limit": {"2"}, "cursor": {cursor}})
+	if ids := threadListIDs(t, second); status != http.StatusOK || len(ids) != 1 || ids[0] != "t-b" || second["nextCursor"] != nil {
+		t.Fatalf("unexpected second page %d %v", status, second)
+	}
+	if status, body := list(url.Values{"sort": {"title"}, "cursor": {cursor}}); status != http.StatusBadRequest || body["code"] != errCodeInvalidRequest {
+		t.Fatalf("expected a cursor for another sort to be rejected, got %d %v", status, body)
+	}
+	if status, _ := list(url.Values{"sort": {"size"}}); status != http.StatusBadRequest {
+		t.Fatalf("expected an unknown sort to be rejected, got %d", status)
+	}
+}
+
+func TestThreadMetaTagsAreNormalized(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	resp, meta := doJSON(t, http.MethodPatch, s.http.URL+"/api/thread/meta", map[string]any{"threadId": "t-1", "tags": []string{" UI ", "bug", "ui", ""}})
+	tags, _ := meta["tags"].([]any)
+	if resp.StatusCode != http.StatusOK || len(tags) != 2 || tags[0] != "bug" || tags[1] != "ui" {
+		t.Fatalf("unexpected tags %d %v", resp.StatusCode, meta)
+	}
+	resp, meta = doJSON(t, http.MethodPatch, s.http.URL+"/api/thread/meta", map[string]any{"threadId": "t-1", "tags": []string{}})
+	if resp.StatusCode != http.StatusOK || meta["tags"] != nil {
+		t.Fatalf("expected tags to be cleared, got %d %v", resp.StatusCode, meta)
+	}
+}
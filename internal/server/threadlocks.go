@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const errCodeThreadBusy = "THREAD_BUSY"
+
+// threadLockWait is how long a conflicting call waits for a thread's lock
+// before it is turned away with THREAD_BUSY.
+const threadLockWait = 5 * time.Second
+
+// threadLockedMethods are the RPCs the agent rejects or misorders when they
+// overlap on one thread, so they run one at a time per thread.
+var threadLockedMethods = map[string]bool{
+	"thread/resume": true,
+	"turn/start":    true,
+}
+
+// threadLock serializes conflicting calls on one thread. held is a
+// one-slot semaphore; refs counts holders and waiters so idle locks can be
+// dropped from the map.
+type threadLock struct {
+	held   chan struct{}
+	refs   int
+	holder string
+	since  time.Time
+}
+
+// threadBusyError rejects a call that waited threadLockWait for another
+// conflicting call on the same thread to finish.
+type threadBusyError struct {
+	ThreadID   string `json:"threadId"`
+	HeldBy     string `json:"heldBy"`
+	HeldForMs  int64  `json:"heldForMs"`
+	RetryAfter int    `json:"retryAfter"`
+}
+
+func (e *threadBusyError) Error() string {
+	return fmt.Sprintf("thread %s is busy with %s; retry in %ds", e.ThreadID, e.HeldBy, e.RetryAfter)
+}
+
+func writeThreadBusy(w http.ResponseWriter, err *threadBusyError) {
+	w.Header().Set("Retry-After", strconv.Itoa(err.RetryAfter))
+	writeErrorDetails(w, http.StatusConflict, errCodeThreadBusy, err.Error(), err)
+}
+
+// lockThread waits up to threadLockWait for threadID's lock and returns the
+// function releasing it. what names the caller in THREAD_BUSY errors.
+func (s *Server) lockThread(ctx context.Context, threadID, what string) (func(), error) {
+	s.threadLocksMu.Lock()
+	lock := s.threadLocks[threadID]
+	if lock == nil {
+		lock = &threadLock{held: make(chan struct{}, 1)}
+		s.threadLocks[threadID] = lock
+	}
+	lock.refs++
+	s.threadLocksMu.Unlock()
+
+	release := func() {
+		s.threadLocksMu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(s.threadLocks, threadID)
+		}
+		s.threadLocksMu.Unlock()
+	}
+
+	timer := time.NewTimer(s.threadLockWait)
+	defer timer.Stop()
+	select {
+	case lock.held <- struct{}{}:
+	case <-ctx.Done():
+		release()
+		return nil, ctx.Err()
+	case <-timer.C:
+		s.threadLocksMu.Lock()
+		busy := &threadBusyError{ThreadID: threadID, HeldBy: lock.holder, HeldForMs: time.Since(lock.since).Milliseconds(), RetryAfter: 1}
+		s.threadLocksMu.Unlock()
+		release()
+		return nil, busy
+	}
+	s.threadLocksMu.Lock()
+	lock.holder = what
+	lock.since = time.Now()
+	s.threadLocksMu.Unlock()
+	return func() {
+		<-lock.held
+		release()
+	}, nil
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestThreadLockSerializesCallers(t *testing.T) {
+	s := &Server{threadLocks: map[string]*threadLock{}, threadLockWait: time.Second}
+	unlock, err := s.lockThread(t.Context(), "thread-1", "turn/start")
+	if err != nil {
+		t.Fatal(err)
+	}
+	acquired := make(chan func())
+	go func() {
+		next, err := s.lockThread(t.Context(), "thread-1", "thread/resume")
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- next
+	}()
+	otherUnlock, err := s.lockThread(t.Context(), "thread-2", "turn/start")
+	if err != nil {
+		t.Fatalf("other threads should not wait: %v", err)
+	}
+	otherUnlock()
+	select {
+	case <-acquired:
+		t.Fatal("second caller acquired a held lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	(<-acquired)()
+	if len(s.threadLocks) != 0 {
+		t.Fatalf("idle locks should be dropped, got %v", s.threadLocks)
+	}
+}
+
+func TestConflictingTurnStartGetsRetryAfter(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) { cfg.FakeApprovalRate = 0 })
+	defer s.close()
+	s.app.threadLockWait = 50 * time.Millisecond
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	turn := map[string]any{"method": "turn/start", "params": map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "hello"}}}}
+
+	unlock, err := s.app.lockThread(t.Context(), threadID, "thread/resume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, payload := doJSON(t, http.MethodPost, s.http.URL+"/api/rpc", turn)
+	details, _ := payload["details"].(map[string]any)
+	if resp.StatusCode != http.StatusConflict || payload["code"] != errCodeThreadBusy || resp.Header.Get("Retry-After") != "1" || details["heldBy"] != "thread/resume" {
+		t.Fatalf("expected THREAD_BUSY, got %d %v", resp.StatusCode, payload)
+	}
+	resp, payload = doJSON(t, http.MethodPost, s.http.URL+"/api/thread/interaction/respond", map[string]any{"threadId": threadID, "requestId": "9001", "result": map[string]any{"decision": "accept"}})
+	if resp.StatusCode != http.StatusConflict || payload["code"] != errCodeThreadBusy {
+		t.Fatalf("expected interaction response to wait for the lock, got %d %v", resp.StatusCode, payload)
+	}
+	unlock()
+
+	if resp, payload := doJSON(t, http.MethodPost, s.http.URL+"/api/rpc", turn); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected turn/start after release to succeed, got %d %v", resp.StatusCode, payload)
+	}
+}
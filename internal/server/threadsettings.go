@@ -0,0 +1,182 @@
+package server
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"strings"
+	"time"
+
+	"darkhold-go/internal/threads"
+)
+
+// threadSettingsView is the body of GET and PATCH /api/thread/settings.
+type threadSettingsView struct {
+	ThreadID string           `json:"threadId"`
+	Settings threads.Settings `json:"settings"`
+	// Version is the thread metadata version, also sent as the ETag.
+	Version int64 `json:"version"`
+	// Changed lists the fields a PATCH changed; empty when it was a no-op.
+	Changed []string `json:"changed,omitempty"`
+}
+
+// handleThreadSettings reads and changes a thread's model, reasoning effort
+// and approval policy overrides. They take effect from the next turn/start:
+// darkhold adds them to the turn's params unless the caller sets them. A
+// change is appended to the thread log as darkhold/thread/settingsChanged so
+// transcripts show where it happened.
+func (s *Server) handleThreadSettings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		threadID := strings.TrimSpace(r.URL.Query().Get("threadId"))
+		if threadID == "" {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+			return
+		}
+		meta, ok := s.threadIndex.Get(threadID)
+		if !ok {
+			writeError(w, http.StatusNotFound, errCodeThreadNotFound, "thread not found.")
+			return
+		}
+		view := threadSettingsView{ThreadID: threadID, Version: meta.Version}
+		if meta.Settings != nil {
+			view.Settings = *meta.Settings
+		}
+		writeThreadVersion(w, meta.Version)
+		writeJSON(w, http.StatusOK, view)
+	case http.MethodPatch:
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
+		var request struct {
+			ThreadID       string  `json:"threadId"`
+			Model          *string `json:"model"`
+			Effort         *string `json:"effort"`
+			ApprovalPolicy *string `json:"approvalPolicy"`
+			Version        *int64  `json:"version"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+			return
+		}
+		request.ThreadID = strings.TrimSpace(request.ThreadID)
+		if request.ThreadID == "" {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+			return
+		}
+		expected, checked, err := threadVersionPrecondition(r, request.Version)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+			return
+		}
+		meta, ok := s.threadIndex.Get(request.ThreadID)
+		if !ok {
+			writeError(w, http.StatusNotFound, errCodeThreadNotFound, "thread not found.")
+			return
+		}
+		if err := checkThreadVersion(meta.Version, expected, checked); err != nil {
+			writeThreadUpdateError(w, err)
+			return
+		}
+		var previous threads.Settings
+		if meta.Settings != nil {
+			previous = *meta.Settings
+		}
+		next := previous
+		for _, field := range []struct {
+			value  *string
+			target *string
+		}{
+			{request.Model, &next.Model},
+			{request.Effort, &next.Effort},
+			{request.ApprovalPolicy, &next.ApprovalPolicy},
+		} {
+			if field.value != nil {
+				*field.target = strings.TrimSpace(*field.value)
+			}
+		}
+		if err := next.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+			return
+		}
+		changed := changedSettings(previous, next)
+		view := threadSettingsView{ThreadID: request.ThreadID, Settings: next, Version: meta.Version, Changed: changed}
+		if len(changed) == 0 {
+			writeThreadVersion(w, view.Version)
+			writeJSON(w, http.StatusOK, view)
+			return
+		}
+		meta, err = s.threadIndex.Update(request.ThreadID, func(meta *threads.Metadata) error {
+			// Checked again under the index lock, since the edit was
+			// computed from an unlocked read.
+			if err := checkThreadVersion(meta.Version, expected, checked); err != nil {
+				return err
+			}
+			meta.Settings = nil
+			if !next.IsZero() {
+				settings := next
+				meta.Settings = &settings
+			}
+			meta.Version++
+			meta.UpdatedAt = time.Now().UnixMilli()
+			return nil
+		})
+		if err != nil {
+			writeThreadUpdateError(w, err)
+			return
+		}
+		view.Version = meta.Version
+		notice, _ := json.Marshal(map[string]any{
+			"method": "darkhold/thread/settingsChanged",
+			"params": map[string]any{
+				"threadId": request.ThreadID,
+				"settings": next,
+				"previous": previous,
+				"changed":  changed,
+				"by":       clientIdentity(r.Context()),
+				"at":       time.Now().UnixMilli(),
+			},
+		})
+		s.publishThreadEvent(request.ThreadID, string(notice))
+		s.audit.record(r, "thread.settings", map[string]any{"threadId": request.ThreadID, "settings": next, "changed": changed})
+		writeThreadVersion(w, view.Version)
+		writeJSON(w, http.StatusOK, view)
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+// changedSettings names the fields that differ between a and b, using their
+// JSON spelling.
+func changedSettings(a, b threads.Settings) []string {
+	changed := []string{}
+	if a.Model != b.Model {
+		changed = append(changed, "model")
+	}
+	if a.Effort != b.Effort {
+		changed = append(changed, "effort")
+	}
+	if a.ApprovalPolicy != b.ApprovalPolicy {
+		changed = append(changed, "approvalPolicy")
+	}
+	return changed
+}
+
+// withThreadSettings fills model, effort and approvalPolicy on turn/start
+// from the thread's settings, leaving any values the caller set explicitly.
+func (s *Server) withThreadSettings(threadID string, params map[string]any) map[string]any {
+	meta, _ := s.threadIndex.Get(threadID)
+	if meta.Settings == nil || meta.Settings.IsZero() || params == nil {
+		return params
+	}
+	out := make(map[string]any, len(params)+3)
+	maps.Copy(out, params)
+	for key, value := range map[string]string{
+		"model":          meta.Settings.Model,
+		"effort":         meta.Settings.Effort,
+		"approvalPolicy": meta.Settings.ApprovalPolicy,
+	} {
+		if _, set := out[key]; !set && value != "" {
+			out[key] = value
+		}
+	}
+	return out
+}
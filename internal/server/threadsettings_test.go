@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestThreadSettingsApplyToLaterTurnsAndAreLogged(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+
+	resp, view := doJSON(t, http.MethodPatch, s.http.URL+"/api/thread/settings", map[string]any{
+		"threadId": threadID,
+		"model":    "gpt-5-codex",
+		"effort":   "high",
+	})
+	changed, _ := view["changed"].([]any)
+	if resp.StatusCode != http.StatusOK || len(changed) != 2 {
+		t.Fatalf("unexpected patch: %d %v", resp.StatusCode, view)
+	}
+
+	params := s.app.withThreadSettings(threadID, map[string]any{"threadId": threadID, "effort": "low"})
+	if params["model"] != "gpt-5-codex" || params["effort"] != "low" {
+		t.Fatalf("expected settings to fill only unset turn/start params: %v", params)
+	}
+	if _, ok := params["approvalPolicy"]; ok {
+		t.Fatalf("expected unset approvalPolicy to stay unset: %v", params)
+	}
+
+	events, _ := s.store.Read(threadID)
+	logged := 0
+	for _, line := range events {
+		if strings.Contains(line, "darkhold/thread/settingsChanged") {
+			logged++
+		}
+	}
+	if logged != 1 {
+		t.Fatalf("expected one settingsChanged event, got %d in %v", logged, events)
+	}
+
+	resp, view = doJSON(t, http.MethodPatch, s.http.URL+"/api/thread/settings", map[string]any{"threadId": threadID, "model": "gpt-5-codex"})
+	if resp.StatusCode != http.StatusOK || view["changed"] != nil {
+		t.Fatalf("expected a no-op patch: %d %v", resp.StatusCode, view)
+	}
+
+	resp, view = doJSON(t, http.MethodPatch, s.http.URL+"/api/thread/settings", map[string]any{"threadId": threadID, "model": "", "effort": ""})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("clear failed: %d %v", resp.StatusCode, view)
+	}
+	if meta, _ := s.app.threadIndex.Get(threadID); meta.Settings != nil {
+		t.Fatalf("expected cleared settings to be dropped: %+v", meta.Settings)
+	}
+
+	resp, view = doJSON(t, http.MethodPatch, s.http.URL+"/api/thread/settings", map[string]any{"threadId": threadID, "effort": "extreme"})
+	if resp.StatusCode != http.StatusBadRequest || view["code"] != errCodeInvalidRequest {
+		t.Fatalf("expected invalid effort to be rejected: %d %v", resp.StatusCode, view)
+	}
+
+	resp, view = doJSON(t, http.MethodGet, s.http.URL+"/api/thread/settings?threadId=missing", nil)
+	if resp.StatusCode != http.StatusNotFound || view["code"] != errCodeThreadNotFound {
+		t.Fatalf("expected THREAD_NOT_FOUND: %d %v", resp.StatusCode, view)
+	}
+}
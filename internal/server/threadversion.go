@@ -0,0 +1,68 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const errCodeThreadVersionConflict = "THREAD_VERSION_CONFLICT"
+
+// threadVersionConflict is returned from a threadIndex.Update callback when
+// the thread changed since the version the client edited.
+type threadVersionConflict struct {
+	current int64
+}
+
+func (e *threadVersionConflict) Error() string {
+	return "thread metadata version " + strconv.FormatInt(e.current, 10) + " does not match"
+}
+
+// threadVersionPrecondition is the metadata version a PATCH was made
+// against: the If-Match header ("3", W/"3" or 3) or else the body's version
+// field. ok is false when the client sent neither, or If-Match: *, and the
+// edit applies whatever the current version is.
+func threadVersionPrecondition(r *http.Request, body *int64) (version int64, ok bool, err error) {
+	if raw := strings.TrimSpace(r.Header.Get("If-Match")); raw != "" {
+		if raw == "*" {
+			return 0, false, nil
+		}
+		raw = strings.Trim(strings.TrimPrefix(raw, "W/"), `"`)
+		version, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || version < 0 {
+			return 0, false, errors.New("If-Match must be a thread version")
+		}
+		return version, true, nil
+	}
+	if body != nil {
+		return *body, true, nil
+	}
+	return 0, false, nil
+}
+
+// checkThreadVersion fails with a threadVersionConflict when a precondition
+// was given and the thread is at another version.
+func checkThreadVersion(current, expected int64, ok bool) error {
+	if ok && current != expected {
+		return &threadVersionConflict{current: current}
+	}
+	return nil
+}
+
+// writeThreadVersion sets the ETag clients send back as If-Match.
+func writeThreadVersion(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", `"`+strconv.FormatInt(version, 10)+`"`)
+}
+
+// writeThreadUpdateError reports a failed metadata update: 409 with the
+// current version for a conflict, a storage error otherwise.
+func writeThreadUpdateError(w http.ResponseWriter, err error) {
+	var conflict *threadVersionConflict
+	if errors.As(err, &conflict) {
+		writeThreadVersion(w, conflict.current)
+		writeErrorDetails(w, http.StatusConflict, errCodeThreadVersionConflict, "the thread was changed by another client; reload it and retry.", map[string]any{"version": conflict.current})
+		return
+	}
+	writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
+}
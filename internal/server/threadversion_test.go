@@ -0,0 +1,78 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestThreadMetaEditsConflictOnStaleVersion(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	patch := func(path, ifMatch string, body map[string]any) (*http.Response, map[string]any) {
+		t.Helper()
+		encoded, _ := json.Marshal(body)
+		req, err := http.NewRequest(http.MethodPatch, s.http.URL+path, bytes.NewReader(encoded))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var payload map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&payload)
+		return resp, payload
+	}
+
+	resp, meta := doJSON(t, http.MethodGet, s.http.URL+"/api/thread/meta?threadId=t-edit", nil)
+	if resp.Header.Get("ETag") != `"0"` || meta["version"] != float64(0) {
+		t.Fatalf("expected a new thread at version 0, got %q %v", resp.Header.Get("ETag"), meta)
+	}
+
+	// Two devices edit from version 0; the second is refused.
+	resp, meta = patch("/api/thread/meta", `"0"`, map[string]any{"threadId": "t-edit", "title": "Phone"})
+	if resp.StatusCode != http.StatusOK || meta["version"] != float64(1) || resp.Header.Get("ETag") != `"1"` {
+		t.Fatalf("expected the first edit to apply, got %d %v", resp.StatusCode, meta)
+	}
+	resp, body := patch("/api/thread/meta", `"0"`, map[string]any{"threadId": "t-edit", "title": "Laptop"})
+	if resp.StatusCode != http.StatusConflict || body["code"] != errCodeThreadVersionConflict || resp.Header.Get("ETag") != `"1"` {
+		t.Fatalf("expected a stale If-Match to conflict, got %d %v", resp.StatusCode, body)
+	}
+	if details, _ := body["details"].(map[string]any); details["version"] != float64(1) {
+		t.Fatalf("expected the conflict to report the current version, got %v", body)
+	}
+	if current, _ := s.app.threadIndex.Get("t-edit"); current.Title != "Phone" {
+		t.Fatalf("expected the conflicting edit to be dropped, got %q", current.Title)
+	}
+
+	// The body's version works like If-Match, and no precondition still
+	// applies whatever the version.
+	if resp, body := patch("/api/thread/meta", "", map[string]any{"threadId": "t-edit", "tags": []string{"x"}, "version": 0}); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected a stale body version to conflict, got %d %v", resp.StatusCode, body)
+	}
+	if resp, body := patch("/api/thread/meta", "", map[string]any{"threadId": "t-edit", "tags": []string{"x"}}); resp.StatusCode != http.StatusOK || body["version"] != float64(2) {
+		t.Fatalf("expected an unconditional edit to apply, got %d %v", resp.StatusCode, body)
+	}
+	if resp, body := patch("/api/thread/meta", "soon", map[string]any{"threadId": "t-edit", "title": "x"}); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected an invalid If-Match to be refused, got %d %v", resp.StatusCode, body)
+	}
+
+	// Settings share the version.
+	resp, body = patch("/api/thread/settings", `W/"2"`, map[string]any{"threadId": "t-edit", "model": "gpt-5-codex"})
+	if resp.StatusCode != http.StatusOK || body["version"] != float64(3) {
+		t.Fatalf("expected the settings edit to apply, got %d %v", resp.StatusCode, body)
+	}
+	if resp, body := patch("/api/thread/settings", `"2"`, map[string]any{"threadId": "t-edit", "model": "gpt-5"}); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected a stale settings edit to conflict, got %d %v", resp.StatusCode, body)
+	}
+	if resp, body := patch("/api/thread/meta", "*", map[string]any{"threadId": "t-edit", "title": "Any"}); resp.StatusCode != http.StatusOK || body["version"] != float64(4) {
+		t.Fatalf("expected If-Match: * to apply, got %d %v", resp.StatusCode, body)
+	}
+}
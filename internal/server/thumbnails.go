@@ -0,0 +1,176 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	browserfs "darkhold-go/internal/fs"
+)
+
+const (
+	errCodeNotImage      = "NOT_AN_IMAGE"
+	errCodeImageTooLarge = "IMAGE_TOO_LARGE"
+
+	// thumbnailDir holds generated thumbnails under the data dir.
+	thumbnailDir = "thumbnails"
+	// defaultThumbnailSize is the w and h used when either is omitted.
+	defaultThumbnailSize = 256
+	maxThumbnailSize     = 1024
+	// thumbnailCacheEntries is how many cached thumbnails are kept; the
+	// oldest are removed first.
+	thumbnailCacheEntries = 2000
+)
+
+// handleFSThumbnail serves a resized preview of an image under the browser
+// root: GET /api/fs/thumbnail?path=&w=&h=. The image is scaled to fit within
+// w x h (default 256, at most 1024) and cached under the data dir, keyed by
+// path, size, modification time and dimensions, so edits are picked up.
+func (s *Server) handleFSThumbnail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeMethodNotAllowed(w)
+		return
+	}
+	query := r.URL.Query()
+	width, errW := parseThumbnailSize(query.Get("w"))
+	height, errH := parseThumbnailSize(query.Get("h"))
+	if errW != nil || errH != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, fmt.Sprintf("w and h must be between 1 and %d.", maxThumbnailSize))
+		return
+	}
+	resolved, err := browserfs.ResolvePath(query.Get("path"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidPath, err.Error())
+		return
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidPath, err.Error())
+		return
+	}
+	if info.IsDir() || !browserfs.IsImage(resolved) {
+		writeError(w, http.StatusUnsupportedMediaType, errCodeNotImage, "thumbnails are available for PNG, JPEG, GIF, WebP and BMP files.")
+		return
+	}
+
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s\x00%d\x00%d\x00%dx%d", resolved, info.Size(), info.ModTime().UnixNano(), width, height))
+	key := hex.EncodeToString(sum[:16])
+	etag := `"` + key + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	data, contentType, err := s.thumbnail(resolved, key, width, height)
+	switch {
+	case errors.Is(err, browserfs.ErrNotImage):
+		writeError(w, http.StatusUnsupportedMediaType, errCodeNotImage, err.Error())
+		return
+	case errors.Is(err, browserfs.ErrImageTooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, errCodeImageTooLarge, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(data)
+	}
+}
+
+func parseThumbnailSize(raw string) (int, error) {
+	if raw == "" {
+		return defaultThumbnailSize, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 || n > maxThumbnailSize {
+		return 0, errors.New("invalid thumbnail size")
+	}
+	return n, nil
+}
+
+// thumbnail returns the cached thumbnail for key or generates and caches
+// it. Without a data dir nothing is cached.
+func (s *Server) thumbnail(path, key string, width, height int) ([]byte, string, error) {
+	ext, contentType := ".png", "image/png"
+	if lower := strings.ToLower(filepath.Ext(path)); lower == ".jpg" || lower == ".jpeg" {
+		ext, contentType = ".jpg", "image/jpeg"
+	}
+	dir := ""
+	if s.cfg.DataDir != "" {
+		dir = filepath.Join(s.cfg.DataDir, thumbnailDir)
+		cached := filepath.Join(dir, key+ext)
+		if data, err := os.ReadFile(cached); err == nil {
+			// Touched so trimming drops the least recently used first.
+			now := time.Now()
+			_ = os.Chtimes(cached, now, now)
+			return data, contentType, nil
+		}
+	}
+	data, contentType, err := browserfs.Thumbnail(path, width, height)
+	if err != nil || dir == "" {
+		return data, contentType, err
+	}
+	if err := writeThumbnail(dir, key+ext, data); err != nil {
+		log.Printf("[thumbnails] failed to cache %s: %v", path, err)
+	}
+	return data, contentType, nil
+}
+
+// writeThumbnail stores one thumbnail and trims the cache to
+// thumbnailCacheEntries.
+func writeThumbnail(dir, name string, data []byte) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(dir, name))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) <= thumbnailCacheEntries {
+		return err
+	}
+	type cached struct {
+		name    string
+		modTime int64
+	}
+	files := make([]cached, 0, len(entries))
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && !strings.HasPrefix(entry.Name(), ".") {
+			files = append(files, cached{entry.Name(), info.ModTime().UnixNano()})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime < files[j].modTime })
+	for _, file := range files[:max(0, len(files)-thumbnailCacheEntries)] {
+		_ = os.Remove(filepath.Join(dir, file.name))
+	}
+	return nil
+}
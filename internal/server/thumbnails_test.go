@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"darkhold-go/internal/config"
+)
+
+func TestFSThumbnail(t *testing.T) {
+	dataDir := t.TempDir()
+	s := startIntegrationServer(t, func(cfg *config.Config) { cfg.DataDir = dataDir })
+	defer s.close()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 640, 480))); err != nil {
+		t.Fatal(err)
+	}
+	shot := filepath.Join(s.baseDir, "shot.png")
+	if err := os.WriteFile(shot, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	thumbURL := s.http.URL + "/api/fs/thumbnail?" + url.Values{"path": {shot}, "w": {"64"}, "h": {"64"}}.Encode()
+
+	resp, err := http.Get(thumbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/png" {
+		t.Fatalf("expected a PNG thumbnail, got %d %s", resp.StatusCode, body)
+	}
+	if cfg, err := png.DecodeConfig(bytes.NewReader(body)); err != nil || cfg.Width != 64 || cfg.Height != 48 {
+		t.Fatalf("expected 64x48, got %dx%d (%v)", cfg.Width, cfg.Height, err)
+	}
+	if cached, _ := filepath.Glob(filepath.Join(dataDir, thumbnailDir, "*.png")); len(cached) != 1 {
+		t.Fatalf("expected one cached thumbnail, got %v", cached)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, thumbURL, nil)
+	req.Header.Set("If-None-Match", resp.Header.Get("ETag"))
+	revalidated, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revalidated.Body.Close()
+	if revalidated.StatusCode != http.StatusNotModified {
+		t.Fatalf("expected 304 for a matching ETag, got %d", revalidated.StatusCode)
+	}
+
+	notes := filepath.Join(s.baseDir, "notes.txt")
+	if err := os.WriteFile(notes, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		query  url.Values
+		status int
+		code   string
+	}{
+		{url.Values{"path": {notes}}, http.StatusUnsupportedMediaType, errCodeNotImage},
+		{url.Values{"path": {shot}, "w": {"5000"}}, http.StatusBadRequest, errCodeInvalidRequest},
+		{url.Values{"path": {"/etc/hosts.png"}}, http.StatusBadRequest, errCodeInvalidPath},
+	} {
+		resp, payload := doJSON(t, http.MethodGet, s.http.URL+"/api/fs/thumbnail?"+tc.query.Encode(), nil)
+		if resp.StatusCode != tc.status || payload["code"] != tc.code {
+			t.Errorf("%v: expected %d %s, got %d %v", tc.query, tc.status, tc.code, resp.StatusCode, payload)
+		}
+	}
+
+	_, listing := doJSON(t, http.MethodGet, s.http.URL+"/api/fs/list?path="+url.QueryEscape(s.baseDir), nil)
+	entries, _ := listing["entries"].([]any)
+	images := 0
+	for _, raw := range entries {
+		if entry, _ := raw.(map[string]any); entry["image"] == true {
+			images++
+		}
+	}
+	if images != 1 {
+		t.Fatalf("expected shot.png to be flagged as an image, got %v", entries)
+	}
+}
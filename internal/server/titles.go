@@ -0,0 +1,208 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"darkhold-go/internal/threads"
+)
+
+// rememberFirstPrompt keeps the text of a thread's first turn/start input
+// (before pinned notes are prepended) until that turn completes and can be
+// used for an automatic title. It reports whether this call stored it, so a
+// start that then fails can forget it without dropping another turn's.
+func (s *Server) rememberFirstPrompt(threadID string, params map[string]any) bool {
+	if meta, _ := s.threadIndex.Get(threadID); meta.Title != "" {
+		return false
+	}
+	text := turnInputText(params)
+	if text == "" {
+		return false
+	}
+	s.threadsMu.Lock()
+	defer s.threadsMu.Unlock()
+	if _, exists := s.firstPrompts[threadID]; exists {
+		return false
+	}
+	s.firstPrompts[threadID] = text
+	return true
+}
+
+func (s *Server) forgetFirstPrompt(threadID string) {
+	s.threadsMu.Lock()
+	delete(s.firstPrompts, threadID)
+	s.threadsMu.Unlock()
+}
+
+// applyAutoTitle derives a title from the remembered first prompt once the
+// first turn completes. A turn that fails or is interrupted only forgets
+// the prompt, so the next turn's prompt titles the thread instead. Titles
+// set by users are never overwritten.
+func (s *Server) applyAutoTitle(threadID, method string, params map[string]any) {
+	s.threadsMu.Lock()
+	prompt, ok := s.firstPrompts[threadID]
+	delete(s.firstPrompts, threadID)
+	s.threadsMu.Unlock()
+	if !ok || method != "turn/completed" {
+		return
+	}
+	turn, _ := params["turn"].(map[string]any)
+	if status, _ := turn["status"].(string); status == "failed" || status == "interrupted" {
+		return
+	}
+	title := threads.DeriveTitle(prompt)
+	if title == "" {
+		return
+	}
+	_, err := s.threadIndex.Update(threadID, func(meta *threads.Metadata) error {
+		if meta.Title == "" {
+			meta.Title = title
+			meta.TitleSource = threads.TitleSourceAuto
+			meta.Version++
+		}
+		meta.UpdatedAt = time.Now().UnixMilli()
+		return nil
+	})
+	if err != nil {
+		log.Printf("[threads] failed to store title for thread %s: %v", threadID, err)
+	}
+}
+
+// recordThreadSeen stores the cwd and first-seen time of threads returned by
+// thread/start, thread/read and thread/resume.
+func (s *Server) recordThreadSeen(threadID string, threadObj map[string]any) {
+	cwd, _ := threadObj["cwd"].(string)
+	if meta, ok := s.threadIndex.Get(threadID); ok && meta.Cwd == cwd {
+		return
+	}
+	_, err := s.threadIndex.Update(threadID, func(meta *threads.Metadata) error {
+		if cwd != "" {
+			meta.Cwd = cwd
+		}
+		if meta.CreatedAt == 0 {
+			meta.CreatedAt = time.Now().UnixMilli()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[threads] failed to record thread %s: %v", threadID, err)
+	}
+}
+
+func turnInputText(params map[string]any) string {
+	input, _ := params["input"].([]any)
+	parts := make([]string, 0, len(input))
+	for _, item := range input {
+		entry, _ := item.(map[string]any)
+		if entry["type"] != "text" {
+			continue
+		}
+		if text, _ := entry["text"].(string); strings.TrimSpace(text) != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func (s *Server) handleThreadMeta(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		threadID := strings.TrimSpace(r.URL.Query().Get("threadId"))
+		if threadID == "" {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+			return
+		}
+		meta, _ := s.threadIndex.Get(threadID)
+		writeThreadVersion(w, meta.Version)
+		writeJSON(w, http.StatusOK, meta)
+	case http.MethodPatch:
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
+		var request struct {
+			ThreadID string          `json:"threadId"`
+			Title    *string         `json:"title"`
+			Tags     *[]string       `json:"tags"`
+			Retry    json.RawMessage `json:"retry"`
+			// WatchFiles takes effect from the next turn; turning it off
+			// also stops a running watcher.
+			WatchFiles *bool `json:"watchFiles"`
+			// Version, like If-Match, refuses the edit with a 409 when the
+			// thread has changed since.
+			Version *int64 `json:"version"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+			return
+		}
+		request.ThreadID = strings.TrimSpace(request.ThreadID)
+		if request.ThreadID == "" {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+			return
+		}
+		expected, checked, err := threadVersionPrecondition(r, request.Version)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+			return
+		}
+		var tags []string
+		if request.Tags != nil {
+			var err error
+			if tags, err = threads.NormalizeTags(*request.Tags); err != nil {
+				writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+				return
+			}
+		}
+		var retry *threads.RetryPolicy
+		if len(request.Retry) > 0 && string(request.Retry) != "null" {
+			retry = &threads.RetryPolicy{}
+			if err := json.Unmarshal(request.Retry, retry); err != nil {
+				writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "retry must be an object.")
+				return
+			}
+			if err := retry.Validate(); err != nil {
+				writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+				return
+			}
+			if retry.MaxAttempts == 0 {
+				retry = nil
+			}
+		}
+		meta, err := s.threadIndex.Update(request.ThreadID, func(meta *threads.Metadata) error {
+			if err := checkThreadVersion(meta.Version, expected, checked); err != nil {
+				return err
+			}
+			if len(request.Retry) > 0 {
+				meta.Retry = retry
+			}
+			if request.Tags != nil {
+				meta.Tags = tags
+			}
+			if request.WatchFiles != nil {
+				meta.WatchFiles = *request.WatchFiles
+			}
+			if request.Title != nil {
+				meta.Title = strings.TrimSpace(*request.Title)
+				meta.TitleSource = threads.TitleSourceUser
+				if meta.Title == "" {
+					meta.TitleSource = ""
+				}
+			}
+			meta.Version++
+			meta.UpdatedAt = time.Now().UnixMilli()
+			return nil
+		})
+		if err != nil {
+			writeThreadUpdateError(w, err)
+			return
+		}
+		if !meta.WatchFiles {
+			s.stopCwdWatch(request.ThreadID)
+		}
+		writeThreadVersion(w, meta.Version)
+		writeJSON(w, http.StatusOK, meta)
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestThreadTitledFromFirstPromptAfterTurnCompletes(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	stream := openSSE(t, s.http.URL, threadID, "")
+	defer stream.Body.Close()
+
+	_ = postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "Fix the login redirect loop\nIt happens after SSO."}}})
+	acceptNextApproval(t, s.http.URL, threadID, stream)
+	waitForSSEEvent(t, stream, func(event sseEvent) bool {
+		return parseJSON(t, event.Data)["method"] == "turn/completed"
+	}, 10*time.Second)
+
+	var meta map[string]any
+	waitForCondition(t, 5*time.Second, 20*time.Millisecond, func() bool {
+		_, meta = doJSON(t, http.MethodGet, s.http.URL+"/api/thread/meta?threadId="+threadID, nil)
+		return meta["title"] != nil
+	})
+	if meta["title"] != "Fix the login redirect loop" || meta["titleSource"] != "auto" || meta["cwd"] != s.baseDir {
+		t.Fatalf("unexpected metadata: %v", meta)
+	}
+
+	resp, renamed := doJSON(t, http.MethodPatch, s.http.URL+"/api/thread/meta", map[string]any{"threadId": threadID, "title": "SSO redirect"})
+	if resp.StatusCode != http.StatusOK || renamed["titleSource"] != "user" {
+		t.Fatalf("unexpected rename: %d %v", resp.StatusCode, renamed)
+	}
+	_, listed := doJSON(t, http.MethodGet, s.http.URL+"/api/threads", nil)
+	entries, _ := listed["threads"].([]any)
+	if len(entries) != 1 || entries[0].(map[string]any)["title"] != "SSO redirect" {
+		t.Fatalf("unexpected thread list: %v", listed)
+	}
+}
+
+func TestFailedFirstTurnDoesNotTitleThread(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	stream := openSSE(t, s.http.URL, threadID, "")
+	defer stream.Body.Close()
+
+	_ = postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "FAIL_TRANSIENT flaky prompt"}}})
+	waitForSSEEvent(t, stream, func(event sseEvent) bool {
+		return parseJSON(t, event.Data)["method"] == "turn/completed"
+	}, 10*time.Second)
+	// The event reaches subscribers before the server observes it.
+	waitForCondition(t, 5*time.Second, 10*time.Millisecond, func() bool {
+		s.app.threadsMu.Lock()
+		defer s.app.threadsMu.Unlock()
+		_, remembered := s.app.firstPrompts[threadID]
+		return !remembered
+	})
+
+	_ = postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "Fix the login redirect loop"}}})
+	acceptNextApproval(t, s.http.URL, threadID, stream)
+	var meta map[string]any
+	waitForCondition(t, 5*time.Second, 20*time.Millisecond, func() bool {
+		_, meta = doJSON(t, http.MethodGet, s.http.URL+"/api/thread/meta?threadId="+threadID, nil)
+		return meta["title"] != nil
+	})
+	if meta["title"] != "Fix the login redirect loop" {
+		t.Fatalf("expected the first successful turn to title the thread, got %v", meta)
+	}
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+)
+
+const (
+	errCodeTurnTooLarge = "TURN_TOO_LARGE"
+
+	// estimateCharsPerToken is the usual rule of thumb for English text and
+	// code. It is only meant to catch prompts that are far too large, not to
+	// predict the bill.
+	estimateCharsPerToken = 4
+)
+
+// turnEstimate is the pre-flight size of a turn/start: its input text plus
+// what darkhold adds in front of it. Non-text items such as images are
+// counted but not sized.
+type turnEstimate struct {
+	InputChars      int    `json:"inputChars"`
+	PinnedNoteChars int    `json:"pinnedNoteChars"`
+	PreambleChars   int    `json:"preambleChars"`
+	Chars           int    `json:"chars"`
+	Tokens          int    `json:"tokens"`
+	Attachments     int    `json:"attachments"`
+	WarnTokens      int    `json:"warnTokens,omitempty"`
+	MaxTokens       int    `json:"maxTokens,omitempty"`
+	Warning         string `json:"warning,omitempty"`
+}
+
+// turnTooLargeError refuses a turn/start whose estimate is over
+// --turn-max-tokens.
+type turnTooLargeError struct {
+	estimate turnEstimate
+}
+
+func (e *turnTooLargeError) Error() string {
+	return fmt.Sprintf("turn is an estimated %d tokens, over the %d token limit", e.estimate.Tokens, e.estimate.MaxTokens)
+}
+
+func writeTurnTooLarge(w http.ResponseWriter, err *turnTooLargeError) {
+	writeErrorDetails(w, http.StatusRequestEntityTooLarge, errCodeTurnTooLarge, err.Error()+".", err.estimate)
+}
+
+// inputTextChars counts the text characters and the other items of a
+// turn/start input.
+func inputTextChars(params map[string]any) (chars, attachments int) {
+	input, _ := params["input"].([]any)
+	for _, raw := range input {
+		item, _ := raw.(map[string]any)
+		if text, ok := item["text"].(string); ok && item["type"] == "text" {
+			chars += utf8.RuneCountInString(text)
+		} else {
+			attachments++
+		}
+	}
+	return chars, attachments
+}
+
+// estimateTurn sizes a normalized turn/start and the params it becomes with
+// pinned notes and then the preamble prepended.
+func (s *Server) estimateTurn(params, withNotes, withPreamble map[string]any) turnEstimate {
+	inputChars, attachments := inputTextChars(params)
+	notesChars, _ := inputTextChars(withNotes)
+	totalChars, _ := inputTextChars(withPreamble)
+	estimate := turnEstimate{
+		InputChars:      inputChars,
+		PinnedNoteChars: notesChars - inputChars,
+		PreambleChars:   totalChars - notesChars,
+		Chars:           totalChars,
+		Tokens:          (totalChars + estimateCharsPerToken - 1) / estimateCharsPerToken,
+		Attachments:     attachments,
+		WarnTokens:      s.cfg.TurnWarnTokens,
+		MaxTokens:       s.cfg.TurnMaxTokens,
+	}
+	if estimate.WarnTokens > 0 && estimate.Tokens > estimate.WarnTokens {
+		estimate.Warning = fmt.Sprintf("turn is an estimated %d tokens, over the %d token warning threshold", estimate.Tokens, estimate.WarnTokens)
+	}
+	return estimate
+}
+
+// checkTurnEstimate refuses turns over --turn-max-tokens.
+func (s *Server) checkTurnEstimate(estimate turnEstimate) error {
+	if estimate.MaxTokens > 0 && estimate.Tokens > estimate.MaxTokens {
+		return &turnTooLargeError{estimate: estimate}
+	}
+	return nil
+}
+
+// handleTurnEstimate sizes a turn/start without running it, so a client can
+// show the estimate and ask for confirmation first. The body is the
+// turn/start params; input errors are reported as turn/start would.
+func (s *Server) handleTurnEstimate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
+	var params map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+		return
+	}
+	normalized, err := s.normalizeTurnStart(params)
+	if err != nil {
+		writeDispatchError(w, err)
+		return
+	}
+	threadID, _ := normalized["threadId"].(string)
+	withNotes := s.withPinnedNotes(threadID, normalized)
+	estimate := s.estimateTurn(normalized, withNotes, s.withSystemPreamble(threadID, withNotes))
+	writeJSON(w, http.StatusOK, map[string]any{
+		"threadId": threadID,
+		"estimate": estimate,
+		"allowed":  s.checkTurnEstimate(estimate) == nil,
+	})
+}
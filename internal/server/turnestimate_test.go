@@ -0,0 +1,45 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"darkhold-go/internal/config"
+	"darkhold-go/internal/threads"
+)
+
+func TestTurnEstimateWarnsAndBlocks(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.TurnWarnTokens = 10
+		cfg.TurnMaxTokens = 60
+	})
+	defer s.close()
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	if _, err := s.app.threadIndex.Update(threadID, func(meta *threads.Metadata) error {
+		_, err := meta.AddNote("Keep answers short.", true)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	text := func(value string) []any { return []any{map[string]any{"type": "text", "text": value}} }
+
+	resp, payload := doJSON(t, http.MethodPost, s.http.URL+"/api/turn/estimate", map[string]any{"threadId": threadID, "input": text("Summarize the repo layout please.")})
+	estimate, _ := payload["estimate"].(map[string]any)
+	if resp.StatusCode != http.StatusOK || payload["allowed"] != true || estimate["inputChars"] != float64(33) || estimate["pinnedNoteChars"].(float64) <= 0 || estimate["warning"] == nil {
+		t.Fatalf("unexpected estimate %d %v", resp.StatusCode, payload)
+	}
+
+	resp, payload = doJSON(t, http.MethodPost, s.http.URL+"/api/rpc", map[string]any{"method": "turn/start", "params": map[string]any{"threadId": threadID, "input": text(strings.Repeat("word ", 60))}})
+	details, _ := payload["details"].(map[string]any)
+	if resp.StatusCode != http.StatusRequestEntityTooLarge || payload["code"] != errCodeTurnTooLarge || details["maxTokens"] != float64(60) {
+		t.Fatalf("expected the oversized turn to be refused, got %d %v", resp.StatusCode, payload)
+	}
+
+	result := postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": text("Summarize the repo layout please.")})
+	attached, _ := result["darkholdEstimate"].(map[string]any)
+	if attached == nil || attached["tokens"] != estimate["tokens"] || attached["warning"] == nil {
+		t.Fatalf("expected the estimate on the turn/start result, got %v", result)
+	}
+}
@@ -0,0 +1,327 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	browserfs "darkhold-go/internal/fs"
+)
+
+const (
+	errCodeTurnRejected = "TURN_REJECTED"
+
+	// turnHookOutputLimit caps what an external hook may print; a hook
+	// that prints more fails.
+	turnHookOutputLimit = 8 << 20
+	// turnHookStderrLimit caps the stderr quoted in a failed hook's error.
+	turnHookStderrLimit = 4 << 10
+	// gitContextMaxStatus caps the git status lines the git-context hook
+	// attaches.
+	gitContextMaxStatus = 50
+)
+
+// Markers around the repository context the git-context hook attaches, so
+// clients can tell it apart from what the user typed.
+const (
+	contextOpen  = "<darkhold-context>"
+	contextClose = "</darkhold-context>"
+)
+
+// snippetPattern matches a {{snippet:name}} reference in turn text.
+var snippetPattern = regexp.MustCompile(`\{\{snippet:([A-Za-z0-9_-]+)\}\}`)
+
+// turnHookInput is what a hook is given: the thread, its cwd (empty when
+// unknown or outside the base path) and the turn/start params so far,
+// including what earlier hooks did. An external hook reads it as JSON on
+// stdin.
+type turnHookInput struct {
+	ThreadID string         `json:"threadId"`
+	Cwd      string         `json:"cwd"`
+	Params   map[string]any `json:"params"`
+}
+
+// turnHookOutput is a hook's answer, printed as JSON by an external hook;
+// empty output changes nothing. Params replaces the turn/start params,
+// Annotations are recorded with the run, and Reject refuses the turn with
+// the given reason.
+type turnHookOutput struct {
+	Params      map[string]any `json:"params,omitempty"`
+	Annotations map[string]any `json:"annotations,omitempty"`
+	Reject      string         `json:"reject,omitempty"`
+}
+
+// turnHookRun records one hook that changed, annotated or failed on a turn,
+// for darkhold/turn/transformed.
+type turnHookRun struct {
+	Hook        string         `json:"hook"`
+	Changed     bool           `json:"changed"`
+	Annotations map[string]any `json:"annotations,omitempty"`
+	Error       string         `json:"error,omitempty"`
+	DurationMs  int64          `json:"durationMs"`
+}
+
+// builtinTurnHooks are the compiled-in hooks, by their --turn-hook name.
+var builtinTurnHooks = map[string]func(*Server, context.Context, turnHookInput) (turnHookOutput, error){
+	"snippets":    (*Server).expandSnippets,
+	"git-context": (*Server).attachGitContext,
+}
+
+// turnRejectedError refuses a turn/start a hook rejected.
+type turnRejectedError struct {
+	Hook   string        `json:"hook"`
+	Reason string        `json:"reason"`
+	Runs   []turnHookRun `json:"runs,omitempty"`
+}
+
+func (e *turnRejectedError) Error() string {
+	return fmt.Sprintf("turn rejected by %s: %s", e.Hook, e.Reason)
+}
+
+func writeTurnRejected(w http.ResponseWriter, err *turnRejectedError) {
+	writeErrorDetails(w, http.StatusUnprocessableEntity, errCodeTurnRejected, err.Error(), err)
+}
+
+// turnHookName is how a --turn-hook is named in events and logs: external
+// hooks by their program alone, so arguments such as tokens stay out of
+// the thread log.
+func turnHookName(spec string) string {
+	command, ok := strings.CutPrefix(spec, "cmd:")
+	if !ok {
+		return spec
+	}
+	return "cmd:" + filepath.Base(strings.Fields(command)[0])
+}
+
+// runTurnHooks passes a normalized turn/start through the --turn-hook
+// pipeline in order and returns the params to send, with a run for every
+// hook that did something. A hook that fails or returns unusable params is
+// skipped, so the turn goes out as the earlier hooks left it; only an
+// explicit reject stops it.
+func (s *Server) runTurnHooks(ctx context.Context, threadID string, params map[string]any) (map[string]any, []turnHookRun, error) {
+	if len(s.cfg.TurnHooks) == 0 {
+		return params, nil, nil
+	}
+	meta, _ := s.threadIndex.Get(threadID)
+	cwd := ""
+	if meta.Cwd != "" {
+		if resolved, err := browserfs.ResolvePath(meta.Cwd); err == nil {
+			cwd = resolved
+		}
+	}
+	var runs []turnHookRun
+	for _, spec := range s.cfg.TurnHooks {
+		name := turnHookName(spec)
+		started := time.Now()
+		hookCtx, cancel := context.WithTimeout(ctx, s.cfg.TurnHookTimeout)
+		out, err := s.runTurnHook(hookCtx, spec, turnHookInput{ThreadID: threadID, Cwd: cwd, Params: params})
+		cancel()
+		run := turnHookRun{Hook: name, Annotations: out.Annotations, DurationMs: time.Since(started).Milliseconds()}
+		if err == nil && out.Reject != "" {
+			return nil, runs, &turnRejectedError{Hook: name, Reason: out.Reject, Runs: runs}
+		}
+		if err == nil && out.Params != nil {
+			var next map[string]any
+			next, err = s.normalizeTurnStart(out.Params)
+			if err == nil && next["threadId"] != threadID {
+				err = errors.New("hook changed threadId")
+			}
+			if err == nil {
+				before, _ := json.Marshal(params)
+				after, _ := json.Marshal(next)
+				run.Changed = !bytes.Equal(before, after)
+				params = next
+			}
+		}
+		if err != nil {
+			log.Printf("[turn-hook] %s failed on thread %s, skipping it: %v", name, threadID, err)
+			run.Error = err.Error()
+		}
+		if run.Changed || len(run.Annotations) > 0 || run.Error != "" {
+			runs = append(runs, run)
+		}
+	}
+	return params, runs, nil
+}
+
+func (s *Server) runTurnHook(ctx context.Context, spec string, in turnHookInput) (turnHookOutput, error) {
+	if command, ok := strings.CutPrefix(spec, "cmd:"); ok {
+		return runTurnHookCommand(ctx, command, in)
+	}
+	hook, ok := builtinTurnHooks[spec]
+	if !ok {
+		return turnHookOutput{}, fmt.Errorf("unknown hook %q", spec)
+	}
+	return hook(s, ctx, in)
+}
+
+// runTurnHookCommand runs an external hook in the thread's cwd with the
+// input on stdin, and reads its answer from stdout. A non-zero exit is a
+// failure, reported with the start of stderr.
+func runTurnHookCommand(ctx context.Context, command string, in turnHookInput) (turnHookOutput, error) {
+	args := strings.Fields(command)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = in.Cwd
+	cmd.WaitDelay = execWaitDelay
+	stdin, _ := json.Marshal(in)
+	cmd.Stdin = bytes.NewReader(stdin)
+	stdout := &cappedBuffer{limit: turnHookOutputLimit}
+	stderr := &cappedBuffer{limit: turnHookStderrLimit}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	var out turnHookOutput
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return out, fmt.Errorf("timed out: %w", ctx.Err())
+		}
+		if detail := strings.TrimSpace(stderr.buf.String()); detail != "" {
+			return out, fmt.Errorf("%w: %s", err, detail)
+		}
+		return out, err
+	}
+	if stdout.truncated {
+		return out, fmt.Errorf("output is over %d bytes", turnHookOutputLimit)
+	}
+	if len(bytes.TrimSpace(stdout.buf.Bytes())) == 0 {
+		return out, nil
+	}
+	if err := json.Unmarshal(stdout.buf.Bytes(), &out); err != nil {
+		return turnHookOutput{}, fmt.Errorf("invalid output: %w", err)
+	}
+	return out, nil
+}
+
+// expandSnippets replaces each {{snippet:name}} in the input's text items
+// with <--turn-snippets-dir>/<name>.md. Unknown names are left in place and
+// reported as missing.
+func (s *Server) expandSnippets(_ context.Context, in turnHookInput) (turnHookOutput, error) {
+	input, _ := in.Params["input"].([]any)
+	var expanded, missing []string
+	seen := map[string]bool{}
+	next := make([]any, len(input))
+	changed := false
+	for i, raw := range input {
+		next[i] = raw
+		item, _ := raw.(map[string]any)
+		text, _ := item["text"].(string)
+		if item["type"] != "text" || !strings.Contains(text, "{{snippet:") {
+			continue
+		}
+		replaced := snippetPattern.ReplaceAllStringFunc(text, func(ref string) string {
+			name := snippetPattern.FindStringSubmatch(ref)[1]
+			data, err := os.ReadFile(filepath.Join(s.cfg.TurnSnippetsDir, name+".md"))
+			if !seen[name] {
+				seen[name] = true
+				if err != nil {
+					missing = append(missing, name)
+				} else {
+					expanded = append(expanded, name)
+				}
+			}
+			if err != nil {
+				return ref
+			}
+			return strings.TrimSpace(string(data))
+		})
+		if replaced != text {
+			copied := make(map[string]any, len(item))
+			maps.Copy(copied, item)
+			copied["text"] = replaced
+			next[i], changed = copied, true
+		}
+	}
+	var out turnHookOutput
+	if len(expanded) > 0 || len(missing) > 0 {
+		out.Annotations = map[string]any{}
+		if len(expanded) > 0 {
+			out.Annotations["expanded"] = expanded
+		}
+		if len(missing) > 0 {
+			out.Annotations["missing"] = missing
+		}
+	}
+	if changed {
+		out.Params = withTurnInput(in.Params, next)
+	}
+	return out, nil
+}
+
+// attachGitContext appends a text item naming the branch and the
+// uncommitted changes of the git repository holding the thread's cwd. A cwd
+// outside git is left alone.
+func (s *Server) attachGitContext(ctx context.Context, in turnHookInput) (turnHookOutput, error) {
+	if in.Cwd == "" {
+		return turnHookOutput{}, nil
+	}
+	branch, err := exec.CommandContext(ctx, "git", "-C", in.Cwd, "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		return turnHookOutput{}, nil
+	}
+	status, err := exec.CommandContext(ctx, "git", "-C", in.Cwd, "status", "--porcelain").Output()
+	if err != nil {
+		return turnHookOutput{}, err
+	}
+	var lines []string
+	for line := range strings.SplitSeq(strings.TrimRight(string(status), "\n"), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	changes := len(lines)
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s\nbranch: %s\n", contextOpen, strings.TrimSpace(string(branch)))
+	if changes == 0 {
+		text.WriteString("working tree clean\n")
+	} else {
+		text.WriteString("uncommitted changes:\n")
+		for _, line := range lines[:min(changes, gitContextMaxStatus)] {
+			text.WriteString(line + "\n")
+		}
+		if changes > gitContextMaxStatus {
+			fmt.Fprintf(&text, "... and %d more\n", changes-gitContextMaxStatus)
+		}
+	}
+	text.WriteString(contextClose)
+
+	input, _ := in.Params["input"].([]any)
+	next := append(append([]any(nil), input...), map[string]any{"type": "text", "text": text.String()})
+	return turnHookOutput{
+		Params:      withTurnInput(in.Params, next),
+		Annotations: map[string]any{"branch": strings.TrimSpace(string(branch)), "changedFiles": changes},
+	}, nil
+}
+
+// withTurnInput returns a copy of params with input replaced.
+func withTurnInput(params map[string]any, input []any) map[string]any {
+	out := make(map[string]any, len(params))
+	maps.Copy(out, params)
+	out["input"] = input
+	return out
+}
+
+// recordTurnHooks appends darkhold/turn/transformed to the thread log after
+// a turn/start the hooks changed, annotated or failed on, so transcripts
+// show how the input differs from what was typed.
+func (s *Server) recordTurnHooks(threadID string, result map[string]any, runs []turnHookRun) {
+	if len(runs) == 0 {
+		return
+	}
+	params := map[string]any{"threadId": threadID, "hooks": runs}
+	turn, _ := result["turn"].(map[string]any)
+	if turnID, _ := turn["id"].(string); turnID != "" {
+		params["turnId"] = turnID
+	}
+	notice, _ := json.Marshal(map[string]any{"method": "darkhold/turn/transformed", "params": params})
+	s.publishThreadEvent(threadID, string(notice))
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestTurnHooksTransformRejectAndAreLogged(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the command hook is a shell script")
+	}
+	snippets := t.TempDir()
+	if err := os.WriteFile(filepath.Join(snippets, "review.md"), []byte("Check every error is handled.\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(t.TempDir(), "guard.sh")
+	body := `input=$(cat)
+case "$input" in
+*forbidden*) echo '{"reject":"no forbidden words"}' ;;
+*crash*) echo boom >&2; exit 3 ;;
+*) echo '{"annotations":{"checked":true}}' ;;
+esac
+`
+	if err := os.WriteFile(script, []byte(body), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.TurnHooks = []string{"snippets", "cmd:sh " + script}
+		cfg.TurnSnippetsDir = snippets
+		cfg.TurnHookTimeout = 5 * time.Second
+	})
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	textTurn := func(text string) map[string]any {
+		return map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": text}}}
+	}
+
+	params := textTurn("{{snippet:review}} then {{snippet:nope}}")
+	out, runs, err := s.app.runTurnHooks(context.Background(), threadID, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := out["input"].([]any)[0].(map[string]any)["text"].(string)
+	if text != "Check every error is handled. then {{snippet:nope}}" {
+		t.Fatalf("unexpected expansion %q", text)
+	}
+	if params["input"].([]any)[0].(map[string]any)["text"] == text {
+		t.Fatal("expected the caller's params to be left alone")
+	}
+	if len(runs) != 2 || runs[0].Hook != "snippets" || !runs[0].Changed || runs[1].Hook != "cmd:sh" || runs[1].Changed || runs[1].Annotations["checked"] != true {
+		t.Fatalf("unexpected runs %+v", runs)
+	}
+	if missing, _ := runs[0].Annotations["missing"].([]string); len(missing) != 1 || missing[0] != "nope" {
+		t.Fatalf("expected nope reported missing, got %v", runs[0].Annotations)
+	}
+
+	// A failing hook is skipped and reported.
+	_, runs, err = s.app.runTurnHooks(context.Background(), threadID, textTurn("crash please"))
+	if err != nil || len(runs) != 1 || !strings.Contains(runs[0].Error, "boom") {
+		t.Fatalf("expected the failure recorded, got %+v, %v", runs, err)
+	}
+
+	resp, rejected := doJSON(t, http.MethodPost, s.http.URL+"/api/rpc", map[string]any{"method": "turn/start", "params": textTurn("say a forbidden word")})
+	details, _ := rejected["details"].(map[string]any)
+	if resp.StatusCode != http.StatusUnprocessableEntity || rejected["code"] != errCodeTurnRejected || details["hook"] != "cmd:sh" || details["reason"] != "no forbidden words" {
+		t.Fatalf("expected the turn rejected, got %d %v", resp.StatusCode, rejected)
+	}
+
+	postRPC[map[string]any](t, s.http.URL, "turn/start", textTurn("{{snippet:review}}"))
+	var logged string
+	waitForCondition(t, 3*time.Second, 20*time.Millisecond, func() bool {
+		events, _ := s.store.Read(threadID)
+		for _, event := range events {
+			if strings.Contains(event, "darkhold/turn/transformed") {
+				logged = event
+			}
+		}
+		return logged != ""
+	})
+	hooks, _ := parseJSON(t, logged)["params"].(map[string]any)["hooks"].([]any)
+	if len(hooks) != 2 || hooks[0].(map[string]any)["hook"] != "snippets" || strings.Contains(logged, script) {
+		t.Fatalf("unexpected transformed event: %s", logged)
+	}
+}
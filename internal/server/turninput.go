@@ -0,0 +1,117 @@
+package server
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const errCodeInvalidTurnInput = "INVALID_TURN_INPUT"
+
+// turnInputFields lists the input item types the app-server accepts on
+// turn/start and the string fields each one requires.
+var turnInputFields = map[string][]string{
+	"text":       {"text"},
+	"image":      {"url"},
+	"localImage": {"path"},
+	"skill":      {"name", "path"},
+	"mention":    {"name", "path"},
+}
+
+// turnInputError rejects a turn/start before it reaches the agent. Index is
+// the offending input item, or -1 when the problem is not tied to one item.
+type turnInputError struct {
+	Index  int    `json:"index"`
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+func (e *turnInputError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("invalid turn/start %s: %s", e.Field, e.Reason)
+	}
+	return fmt.Sprintf("invalid turn/start input[%d].%s: %s", e.Index, e.Field, e.Reason)
+}
+
+func writeInvalidTurnInput(w http.ResponseWriter, err *turnInputError) {
+	writeErrorDetails(w, http.StatusBadRequest, errCodeInvalidTurnInput, err.Error(), err)
+}
+
+// normalizeTurnInput validates a turn/start input array and returns a copy
+// with text normalized: CRLF line endings become LF and surrounding
+// whitespace is trimmed. Control characters other than tab and newline are
+// rejected, as is text longer than maxChars in total (zero disables the
+// limit). Unknown fields on items are passed through untouched.
+func normalizeTurnInput(raw any, maxChars int) ([]any, error) {
+	input, ok := raw.([]any)
+	if !ok || len(input) == 0 {
+		return nil, &turnInputError{Index: -1, Field: "input", Reason: "must be a non-empty array"}
+	}
+	out := make([]any, len(input))
+	total := 0
+	for i, rawItem := range input {
+		item, ok := rawItem.(map[string]any)
+		if !ok {
+			return nil, &turnInputError{Index: i, Field: "type", Reason: "item must be an object"}
+		}
+		itemType, _ := item["type"].(string)
+		fields, known := turnInputFields[itemType]
+		if !known {
+			return nil, &turnInputError{Index: i, Field: "type", Reason: fmt.Sprintf("unknown item type %q", itemType)}
+		}
+		normalized := make(map[string]any, len(item))
+		maps.Copy(normalized, item)
+		for _, field := range fields {
+			value, _ := item[field].(string)
+			if field == "text" {
+				value = strings.TrimSpace(strings.ReplaceAll(value, "\r\n", "\n"))
+				total += utf8.RuneCountInString(value)
+			} else {
+				value = strings.TrimSpace(value)
+			}
+			if value == "" {
+				return nil, &turnInputError{Index: i, Field: field, Reason: "is required"}
+			}
+			if reason := invalidTextReason(value); reason != "" {
+				return nil, &turnInputError{Index: i, Field: field, Reason: reason}
+			}
+			normalized[field] = value
+		}
+		out[i] = normalized
+	}
+	if maxChars > 0 && total > maxChars {
+		return nil, &turnInputError{Index: -1, Field: "input", Reason: fmt.Sprintf("text is %d characters, over the %d character limit", total, maxChars)}
+	}
+	return out, nil
+}
+
+func invalidTextReason(value string) string {
+	if !utf8.ValidString(value) {
+		return "is not valid UTF-8"
+	}
+	for _, r := range value {
+		if r != '\n' && r != '\t' && unicode.IsControl(r) {
+			return fmt.Sprintf("contains control character %U", r)
+		}
+	}
+	return ""
+}
+
+// normalizeTurnStart validates turn/start params and returns a copy carrying
+// the normalized input.
+func (s *Server) normalizeTurnStart(params map[string]any) (map[string]any, error) {
+	if threadID, _ := params["threadId"].(string); strings.TrimSpace(threadID) == "" {
+		return nil, &turnInputError{Index: -1, Field: "threadId", Reason: "is required"}
+	}
+	input, err := normalizeTurnInput(params["input"], s.cfg.MaxTurnInputChars)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]any, len(params))
+	maps.Copy(out, params)
+	out["input"] = input
+	return out, nil
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"darkhold-go/internal/config"
+)
+
+func TestNormalizeTurnInput(t *testing.T) {
+	input, err := normalizeTurnInput([]any{
+		map[string]any{"type": "text", "text": "  line one\r\nline two\t\n", "text_elements": []any{}},
+		map[string]any{"type": "localImage", "path": " /tmp/shot.png "},
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := input[0].(map[string]any)
+	if text["text"] != "line one\nline two" || text["text_elements"] == nil {
+		t.Fatalf("unexpected text item %v", text)
+	}
+	if input[1].(map[string]any)["path"] != "/tmp/shot.png" {
+		t.Fatalf("unexpected image item %v", input[1])
+	}
+
+	cases := []struct {
+		name     string
+		input    any
+		maxChars int
+		index    int
+		field    string
+	}{
+		{"missing", nil, 0, -1, "input"},
+		{"empty", []any{}, 0, -1, "input"},
+		{"not an object", []any{"hello"}, 0, 0, "type"},
+		{"unknown type", []any{map[string]any{"type": "audio"}}, 0, 0, "type"},
+		{"blank text", []any{map[string]any{"type": "text", "text": " \n "}}, 0, 0, "text"},
+		{"control character", []any{map[string]any{"type": "text", "text": "a\x1b[2Jb"}}, 0, 0, "text"},
+		{"missing path", []any{map[string]any{"type": "text", "text": "ok"}, map[string]any{"type": "skill", "name": "lint"}}, 0, 1, "path"},
+		{"too long", []any{map[string]any{"type": "text", "text": "héllo"}, map[string]any{"type": "text", "text": "wörld"}}, 9, -1, "input"},
+	}
+	for _, tc := range cases {
+		_, err := normalizeTurnInput(tc.input, tc.maxChars)
+		var inputErr *turnInputError
+		if !errors.As(err, &inputErr) || inputErr.Index != tc.index || inputErr.Field != tc.field {
+			t.Fatalf("%s: unexpected error %v", tc.name, err)
+		}
+	}
+}
+
+func TestTurnStartRejectsInvalidInput(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) { cfg.FakeApprovalRate = 0 })
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	resp, payload := doJSON(t, http.MethodPost, s.http.URL+"/api/rpc", map[string]any{
+		"method": "turn/start",
+		"params": map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "txt", "text": "hello"}}},
+	})
+	details, _ := payload["details"].(map[string]any)
+	if resp.StatusCode != http.StatusBadRequest || payload["code"] != errCodeInvalidTurnInput || details["field"] != "type" {
+		t.Fatalf("expected structured rejection, got %d %v", resp.StatusCode, payload)
+	}
+	if events, _ := s.store.Read(threadID); len(events) != 0 {
+		t.Fatalf("rejected input should not reach the agent, got %v", events)
+	}
+
+	resp, payload = doJSON(t, http.MethodPost, s.http.URL+"/api/rpc", map[string]any{
+		"method": "turn/start",
+		"params": map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "  hello  "}}},
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected valid input to be accepted, got %d %v", resp.StatusCode, payload)
+	}
+}
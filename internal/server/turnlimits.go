@@ -0,0 +1,196 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+const (
+	turnLimitDuration = "duration"
+	turnLimitCPU      = "cpu"
+
+	// turnLimitGrace is how long an interrupted turn may keep running
+	// before its agent is sent SIGINT.
+	turnLimitGrace = 30 * time.Second
+)
+
+// limitedTurn is a running turn watched for --max-turn-duration and
+// --max-turn-cpu. cpuBase is the agent's CPU time at the first sample taken
+// since the turn started; interruptedAt is when darkhold asked the agent to
+// interrupt the turn, and signalled whether it then fell back to SIGINT.
+type limitedTurn struct {
+	turnID        string
+	startedAt     time.Time
+	cpuBase       time.Duration
+	cpuBaseSet    bool
+	interruptedAt time.Time
+	signalled     bool
+}
+
+// turnLimitAction is an interrupt or a SIGINT checkTurnLimits decided on,
+// carried out after its locks are released.
+type turnLimitAction struct {
+	threadID string
+	turnID   string
+	sess     *session
+	// event is the darkhold/turn/limitExceeded params; nil for the SIGINT
+	// that follows an interrupt the agent ignored.
+	event map[string]any
+}
+
+// turnLimitWatcher checks the turn limits until shutdown.
+func (s *Server) turnLimitWatcher() {
+	interval := alertCheckInterval(s.cfg.MaxTurnDuration, s.cfg.MaxTurnCPU)
+	if s.cfg.MaxTurnCPU > 0 {
+		interval = min(interval, max(s.cfg.AgentSampleInterval, time.Second))
+	}
+	for {
+		select {
+		case <-s.reaperStop:
+			return
+		case <-time.After(interval):
+		}
+		s.checkTurnLimits(time.Now())
+	}
+}
+
+// trackLimitedTurn starts timing a turn when turn/started arrives.
+func (s *Server) trackLimitedTurn(threadID string, params map[string]any) {
+	if s.cfg.MaxTurnDuration <= 0 && s.cfg.MaxTurnCPU <= 0 {
+		return
+	}
+	turnID, _ := params["turnId"].(string)
+	if turn, ok := params["turn"].(map[string]any); ok && turnID == "" {
+		turnID, _ = turn["id"].(string)
+	}
+	s.turnLimitsMu.Lock()
+	defer s.turnLimitsMu.Unlock()
+	if s.limitedTurns == nil {
+		s.limitedTurns = map[string]*limitedTurn{}
+	}
+	s.limitedTurns[threadID] = &limitedTurn{turnID: turnID, startedAt: time.Now()}
+}
+
+// forgetLimitedTurn stops timing the thread's turn once it ends.
+func (s *Server) forgetLimitedTurn(threadID string) {
+	s.turnLimitsMu.Lock()
+	delete(s.limitedTurns, threadID)
+	s.turnLimitsMu.Unlock()
+}
+
+// checkTurnLimits interrupts, once each, the turns that have run longer
+// than --max-turn-duration or whose agent has used more than --max-turn-cpu
+// of CPU time since they started, and sends SIGINT to agents still running
+// an interrupted turn turnLimitGrace later.
+func (s *Server) checkTurnLimits(now time.Time) {
+	var actions []turnLimitAction
+
+	s.turnLimitsMu.Lock()
+	s.sessionsMu.RLock()
+	for threadID, turn := range s.limitedTurns {
+		var sess *session
+		if sessionID, ok := s.threadToSession[threadID]; ok {
+			sess = s.sessions[sessionID]
+		}
+		if !turn.interruptedAt.IsZero() {
+			if !turn.signalled && sess != nil && now.Sub(turn.interruptedAt) >= turnLimitGrace {
+				turn.signalled = true
+				actions = append(actions, turnLimitAction{threadID: threadID, turnID: turn.turnID, sess: sess})
+			}
+			continue
+		}
+		elapsed := now.Sub(turn.startedAt)
+		event := map[string]any{"threadId": threadID, "startedAt": turn.startedAt.UnixMilli(), "elapsedMs": elapsed.Milliseconds()}
+		switch {
+		case s.cfg.MaxTurnDuration > 0 && elapsed >= s.cfg.MaxTurnDuration:
+			event["limit"] = turnLimitDuration
+			event["thresholdMs"] = s.cfg.MaxTurnDuration.Milliseconds()
+		case s.cfg.MaxTurnCPU > 0 && sess != nil:
+			used, ok := turn.cpuUsed(sess)
+			if !ok || used < s.cfg.MaxTurnCPU {
+				continue
+			}
+			event["limit"] = turnLimitCPU
+			event["cpuMs"] = used.Milliseconds()
+			event["thresholdMs"] = s.cfg.MaxTurnCPU.Milliseconds()
+		default:
+			continue
+		}
+		if turn.turnID != "" {
+			event["turnId"] = turn.turnID
+		}
+		turn.interruptedAt = now
+		actions = append(actions, turnLimitAction{threadID: threadID, turnID: turn.turnID, sess: sess, event: event})
+	}
+	s.sessionsMu.RUnlock()
+	s.turnLimitsMu.Unlock()
+
+	for _, action := range actions {
+		if action.event == nil {
+			log.Printf("[turn-limit] thread %s: turn %s ignored the interrupt for %s; sending SIGINT", action.threadID, action.turnID, turnLimitGrace)
+			s.signalTurnAgent(action)
+			continue
+		}
+		s.interruptLimitedTurn(action)
+	}
+}
+
+// cpuUsed returns the CPU time sess's agent has used since the turn
+// started, once a sample taken after the start exists. The agent's other
+// threads count too: CPU time is only known per process tree.
+func (t *limitedTurn) cpuUsed(sess *session) (time.Duration, bool) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.usageAt.IsZero() || sess.usageAt.Before(t.startedAt) {
+		return 0, false
+	}
+	if !t.cpuBaseSet {
+		t.cpuBase, t.cpuBaseSet = sess.usageCPU, true
+		return 0, false
+	}
+	return sess.usageCPU - t.cpuBase, true
+}
+
+// interruptLimitedTurn publishes darkhold/turn/limitExceeded to the thread
+// and interrupts the turn with turn/interrupt, or with SIGINT when the agent
+// cannot be asked (no turn ID) or the call fails.
+func (s *Server) interruptLimitedTurn(action turnLimitAction) {
+	limit, _ := action.event["limit"].(string)
+	elapsed := time.Duration(action.event["elapsedMs"].(int64)) * time.Millisecond
+	log.Printf("[turn-limit] thread %s: turn %s exceeded --max-turn-%s after %s; interrupting", action.threadID, action.turnID, limit, elapsed.Round(time.Second))
+	encoded, _ := json.Marshal(map[string]any{"method": "darkhold/turn/limitExceeded", "params": action.event})
+	s.publishThreadEvent(action.threadID, string(encoded))
+	if action.sess == nil {
+		return
+	}
+	go func() {
+		if action.turnID != "" {
+			ctx, cancel := context.WithTimeout(context.Background(), s.rpcTimeout)
+			_, err := s.callSessionRPC(ctx, action.sess, "turn/interrupt", map[string]any{"threadId": action.threadID, "turnId": action.turnID})
+			cancel()
+			if err == nil {
+				return
+			}
+			log.Printf("[turn-limit] thread %s: turn/interrupt failed: %v; sending SIGINT", action.threadID, err)
+		}
+		s.signalTurnAgent(action)
+	}()
+}
+
+// signalTurnAgent sends SIGINT (CTRL_BREAK on Windows) to the agent running
+// the turn. The agent exits, so its other threads' turns are lost too.
+func (s *Server) signalTurnAgent(action turnLimitAction) {
+	s.turnLimitsMu.Lock()
+	if turn := s.limitedTurns[action.threadID]; turn != nil && turn.turnID == action.turnID {
+		turn.signalled = true
+	}
+	s.turnLimitsMu.Unlock()
+	if action.sess.proc == nil {
+		return
+	}
+	if err := action.sess.proc.Interrupt(); err != nil {
+		log.Printf("[session=%d] failed to interrupt agent: %v", action.sess.id, err)
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func limitExceededEvents(t *testing.T, s *integrationServer, threadID string) []map[string]any {
+	t.Helper()
+	records, err := s.app.eventStore.ReadRange(threadID, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exceeded []map[string]any
+	for _, record := range records {
+		var event struct {
+			Method string         `json:"method"`
+			Params map[string]any `json:"params"`
+		}
+		if json.Unmarshal([]byte(record.Payload), &event) == nil && event.Method == "darkhold/turn/limitExceeded" {
+			exceeded = append(exceeded, event.Params)
+		}
+	}
+	return exceeded
+}
+
+func TestTurnDurationLimitInterruptsTheTurn(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.MaxTurnDuration = time.Minute
+	})
+	defer s.close()
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	_ = postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "run forever"}}})
+	waitForCondition(t, 5*time.Second, 10*time.Millisecond, func() bool {
+		s.app.turnLimitsMu.Lock()
+		defer s.app.turnLimitsMu.Unlock()
+		return s.app.limitedTurns[threadID] != nil
+	})
+
+	s.app.checkTurnLimits(time.Now().Add(30 * time.Second))
+	if exceeded := limitExceededEvents(t, s, threadID); len(exceeded) != 0 {
+		t.Fatalf("expected no limit event before the limit, got %v", exceeded)
+	}
+	s.app.checkTurnLimits(time.Now().Add(2 * time.Minute))
+	s.app.checkTurnLimits(time.Now().Add(2 * time.Minute))
+	exceeded := limitExceededEvents(t, s, threadID)
+	if len(exceeded) != 1 || exceeded[0]["limit"] != turnLimitDuration || exceeded[0]["turnId"] != "fake-turn-1" || exceeded[0]["thresholdMs"] != float64(60000) {
+		t.Fatalf("expected one duration limit event, got %v", exceeded)
+	}
+	waitForCondition(t, 5*time.Second, 10*time.Millisecond, func() bool {
+		events, _ := s.store.Read(threadID)
+		return len(events) > 0 && strings.Contains(events[len(events)-1], "interrupted")
+	})
+	s.app.turnLimitsMu.Lock()
+	remaining := len(s.app.limitedTurns)
+	s.app.turnLimitsMu.Unlock()
+	if remaining != 0 {
+		t.Fatalf("expected the interrupted turn to be forgotten, got %d", remaining)
+	}
+}
+
+func TestTurnLimitSignalsAnAgentIgnoringTheInterrupt(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.FakeApprovalRate = 0
+		cfg.MaxTurnDuration = time.Minute
+	})
+	defer s.close()
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+
+	// A turn the agent already interrupted but that never finished.
+	s.app.observeThreadEvent(threadID, "turn/started", map[string]any{"threadId": threadID, "turn": map[string]any{"id": "stuck"}})
+	s.app.turnLimitsMu.Lock()
+	s.app.limitedTurns[threadID].interruptedAt = time.Now()
+	s.app.turnLimitsMu.Unlock()
+	s.app.checkTurnLimits(time.Now().Add(turnLimitGrace / 2))
+	if s.app.limitedTurns[threadID].signalled {
+		t.Fatal("expected no SIGINT within the grace period")
+	}
+	s.app.checkTurnLimits(time.Now().Add(2 * turnLimitGrace))
+	waitForCondition(t, 5*time.Second, 20*time.Millisecond, func() bool {
+		_, listed := doJSON(t, http.MethodGet, s.http.URL+"/api/admin/sessions", nil)
+		for _, raw := range listed["sessions"].([]any) {
+			if info := raw.(map[string]any); info["alive"] == true {
+				return false
+			}
+		}
+		return true
+	})
+	s.app.turnLimitsMu.Lock()
+	remaining := len(s.app.limitedTurns)
+	s.app.turnLimitsMu.Unlock()
+	if remaining != 0 {
+		t.Fatalf("expected the exited session's turn to be forgotten, got %d", remaining)
+	}
+}
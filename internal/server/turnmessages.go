@@ -0,0 +1,206 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const errCodeTurnMessageNotFound = "TURN_MESSAGE_NOT_FOUND"
+
+// turnMessageItem is one agent message of a turn, as assembled so far.
+type turnMessageItem struct {
+	ItemID    string `json:"itemId"`
+	Text      string `json:"text"`
+	Completed bool   `json:"completed"`
+}
+
+// turnMessage is the agent's reply for a turn: its agentMessage items with
+// their deltas concatenated, or their final text once the item completed.
+type turnMessage struct {
+	ThreadID  string            `json:"threadId"`
+	TurnID    string            `json:"turnId"`
+	Completed bool              `json:"completed"`
+	Text      string            `json:"text"`
+	Items     []turnMessageItem `json:"items"`
+	UpdatedAt int64             `json:"updatedAt"`
+
+	byItem map[string]int
+}
+
+// apply folds one thread event into the message, ignoring events of other
+// turns.
+func (m *turnMessage) apply(method string, params map[string]any, at time.Time) {
+	if eventTurnID(params) != m.TurnID {
+		return
+	}
+	switch method {
+	case "item/agentMessage/delta":
+		itemID, _ := params["itemId"].(string)
+		delta, _ := params["delta"].(string)
+		item := m.item(itemID)
+		if !item.Completed {
+			item.Text += delta
+		}
+	case "item/started", "item/completed":
+		raw, _ := params["item"].(map[string]any)
+		if raw["type"] != "agentMessage" {
+			return
+		}
+		itemID, _ := raw["id"].(string)
+		text, _ := raw["text"].(string)
+		item := m.item(itemID)
+		if method == "item/completed" {
+			item.Text, item.Completed = text, true
+		} else if item.Text == "" {
+			item.Text = text
+		}
+	case "turn/completed", "turn/failed", "turn/aborted":
+		m.Completed = true
+	default:
+		return
+	}
+	texts := make([]string, 0, len(m.Items))
+	for _, item := range m.Items {
+		if item.Text != "" {
+			texts = append(texts, item.Text)
+		}
+	}
+	m.Text = strings.Join(texts, "\n\n")
+	m.UpdatedAt = at.UnixMilli()
+}
+
+// eventTurnID is the turn a notification belongs to: params.turnId, or
+// params.turn.id on turn lifecycle events.
+func eventTurnID(params map[string]any) string {
+	turnID, _ := params["turnId"].(string)
+	if turn, ok := params["turn"].(map[string]any); ok && turnID == "" {
+		turnID, _ = turn["id"].(string)
+	}
+	return turnID
+}
+
+func (m *turnMessage) item(itemID string) *turnMessageItem {
+	if m.byItem == nil {
+		m.byItem = map[string]int{}
+	}
+	index, ok := m.byItem[itemID]
+	if !ok {
+		index = len(m.Items)
+		m.byItem[itemID] = index
+		m.Items = append(m.Items, turnMessageItem{ItemID: itemID})
+	}
+	return &m.Items[index]
+}
+
+// view copies the message for a response, since the live one keeps
+// changing.
+func (m *turnMessage) view() turnMessage {
+	out := *m
+	out.Items = append([]turnMessageItem{}, m.Items...)
+	out.byItem = nil
+	return out
+}
+
+// turnMessages keeps the assembled message of each thread's latest turn, so
+// a client reconnecting mid-turn can render what has been said so far instead
+// of replaying every delta. Older turns are rebuilt from the thread log.
+type turnMessages struct {
+	mu       sync.Mutex
+	byThread map[string]*turnMessage
+}
+
+// observe updates the thread's latest turn from a live thread event.
+func (tm *turnMessages) observe(threadID, method string, params map[string]any) {
+	if !strings.HasPrefix(method, "item/") && !strings.HasPrefix(method, "turn/") {
+		return
+	}
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if method == "turn/started" {
+		if tm.byThread == nil {
+			tm.byThread = map[string]*turnMessage{}
+		}
+		tm.byThread[threadID] = &turnMessage{ThreadID: threadID, TurnID: eventTurnID(params), Items: []turnMessageItem{}, UpdatedAt: time.Now().UnixMilli()}
+		return
+	}
+	if message := tm.byThread[threadID]; message != nil {
+		message.apply(method, params, time.Now())
+	}
+}
+
+// latest returns the thread's latest turn when it is turnID, or any turn
+// when turnID is empty.
+func (tm *turnMessages) latest(threadID, turnID string) (turnMessage, bool) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	message := tm.byThread[threadID]
+	if message == nil || (turnID != "" && message.TurnID != turnID) {
+		return turnMessage{}, false
+	}
+	return message.view(), true
+}
+
+// loadTurnMessage assembles a turn's message from the thread log. An empty
+// turnID picks the latest turn that started.
+func (s *Server) loadTurnMessage(threadID, turnID string) (turnMessage, bool, error) {
+	records, err := s.eventStore.ReadRange(threadID, "", 0)
+	if err != nil {
+		return turnMessage{}, false, err
+	}
+	var message *turnMessage
+	for _, record := range records {
+		var event struct {
+			Method string         `json:"method"`
+			Params map[string]any `json:"params"`
+		}
+		if json.Unmarshal([]byte(record.Payload), &event) != nil {
+			continue
+		}
+		if event.Method == "turn/started" {
+			id := eventTurnID(event.Params)
+			if turnID == "" || id == turnID {
+				message = &turnMessage{ThreadID: threadID, TurnID: id, Items: []turnMessageItem{}, UpdatedAt: record.Time}
+			}
+			continue
+		}
+		if message != nil {
+			message.apply(event.Method, event.Params, time.UnixMilli(record.Time))
+		}
+	}
+	if message == nil {
+		return turnMessage{}, false, nil
+	}
+	return message.view(), true, nil
+}
+
+// handleTurnMessage serves the agent's message for a turn as assembled so
+// far. turnId defaults to the thread's latest turn.
+func (s *Server) handleTurnMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	threadID := strings.TrimSpace(r.URL.Query().Get("threadId"))
+	if threadID == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+		return
+	}
+	turnID := strings.TrimSpace(r.URL.Query().Get("turnId"))
+	if message, ok := s.turnMessages.latest(threadID, turnID); ok {
+		writeJSON(w, http.StatusOK, message)
+		return
+	}
+	message, ok, err := s.loadTurnMessage(threadID, turnID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, errCodeTurnMessageNotFound, "no such turn on the thread.")
+		return
+	}
+	writeJSON(w, http.StatusOK, message)
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestTurnMessageAssemblesDeltas(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	publish := func(method string, params map[string]any) {
+		params["threadId"] = "t-msg"
+		encoded, _ := json.Marshal(map[string]any{"method": method, "params": params})
+		s.app.publishThreadEvent("t-msg", string(encoded))
+		s.app.observeThreadEvent("t-msg", method, params)
+	}
+	get := func(query string) (int, turnMessage) {
+		t.Helper()
+		resp, body := doJSON(t, http.MethodGet, s.http.URL+"/api/thread/turn/message?threadId=t-msg"+query, nil)
+		var message turnMessage
+		encoded, _ := json.Marshal(body)
+		_ = json.Unmarshal(encoded, &message)
+		return resp.StatusCode, message
+	}
+
+	publish("turn/started", map[string]any{"turn": map[string]any{"id": "turn-1"}})
+	publish("item/started", map[string]any{"turnId": "turn-1", "item": map[string]any{"type": "agentMessage", "id": "msg-1", "text": ""}})
+	publish("item/agentMessage/delta", map[string]any{"turnId": "turn-1", "itemId": "msg-1", "delta": "Hello"})
+	publish("item/agentMessage/delta", map[string]any{"turnId": "turn-1", "itemId": "msg-1", "delta": " there"})
+	publish("item/started", map[string]any{"turnId": "turn-1", "item": map[string]any{"type": "reasoning", "id": "r-1"}})
+
+	status, message := get("")
+	if status != http.StatusOK || message.TurnID != "turn-1" || message.Completed || message.Text != "Hello there" || len(message.Items) != 1 {
+		t.Fatalf("expected the partial message, got %d %+v", status, message)
+	}
+
+	publish("item/completed", map[string]any{"turnId": "turn-1", "item": map[string]any{"type": "agentMessage", "id": "msg-1", "text": "Hello there."}})
+	publish("item/agentMessage/delta", map[string]any{"turnId": "turn-1", "itemId": "msg-2", "delta": "Done"})
+	publish("turn/completed", map[string]any{"turn": map[string]any{"id": "turn-1", "status": "completed"}})
+	status, message = get("&turnId=turn-1")
+	if status != http.StatusOK || !message.Completed || message.Text != "Hello there.\n\nDone" || !message.Items[0].Completed {
+		t.Fatalf("expected the completed message, got %d %+v", status, message)
+	}
+
+	// A newer turn replaces the live view; the older one is rebuilt from the
+	// log.
+	publish("turn/started", map[string]any{"turn": map[string]any{"id": "turn-2"}})
+	if status, replayed := get("&turnId=turn-1"); status != http.StatusOK || replayed.Text != message.Text || !replayed.Completed {
+		t.Fatalf("expected turn-1 from the log, got %d %+v", status, replayed)
+	}
+	if status, latest := get(""); status != http.StatusOK || latest.TurnID != "turn-2" || latest.Text != "" {
+		t.Fatalf("expected the latest turn by default, got %d %+v", status, latest)
+	}
+	if status, _ := get("&turnId=missing"); status != http.StatusNotFound {
+		t.Fatalf("expected an unknown turn to be 404, got %d", status)
+	}
+}
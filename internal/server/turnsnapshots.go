@@ -0,0 +1,301 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"darkhold-go/internal/config"
+	browserfs "darkhold-go/internal/fs"
+	"darkhold-go/internal/snapshots"
+)
+
+const (
+	errCodeSnapshotNotFound = "SNAPSHOT_NOT_FOUND"
+	errCodeSnapshotExpired  = "SNAPSHOT_EXPIRED"
+	errCodeRollbackConflict = "ROLLBACK_CONFLICT"
+)
+
+// openTurnSnapshots returns nil when --turn-snapshots is off. Copies live
+// under <data-dir>/snapshots.
+func openTurnSnapshots(cfg config.Config) *snapshots.Manager {
+	if cfg.TurnSnapshots == "" || cfg.TurnSnapshots == "off" {
+		return nil
+	}
+	root := filepath.Join(os.TempDir(), "darkhold-snapshots")
+	if cfg.DataDir != "" {
+		root = filepath.Join(cfg.DataDir, "snapshots")
+	}
+	return &snapshots.Manager{Mode: cfg.TurnSnapshots, Root: root, MaxBytes: cfg.TurnSnapshotMaxBytes}
+}
+
+// turnSnapshot is a snapshot as recorded in the thread log.
+type turnSnapshot struct {
+	TurnID   string             `json:"turnId"`
+	Snapshot snapshots.Snapshot `json:"snapshot"`
+	// Available is false once the snapshot was pruned or deleted.
+	Available  bool  `json:"available"`
+	RolledBack bool  `json:"rolledBack"`
+	RolledAt   int64 `json:"rolledBackAt,omitempty"`
+}
+
+// takeTurnSnapshot snapshots the cwd a turn/start will run in and holds it
+// for the thread until the turn's id is known. Failures are logged and the
+// turn goes ahead without a snapshot.
+func (s *Server) takeTurnSnapshot(ctx context.Context, threadID string, params map[string]any) *snapshots.Snapshot {
+	if s.snapshots == nil || threadID == "" {
+		return nil
+	}
+	cwd, _ := params["cwd"].(string)
+	if cwd == "" {
+		meta, _ := s.threadIndex.Get(threadID)
+		cwd = meta.Cwd
+	}
+	if cwd == "" {
+		return nil
+	}
+	cwd = filepath.Clean(cwd)
+	if !browserfs.IsWithinRoot(cwd) {
+		log.Printf("[snapshots] not snapshotting %s for thread %s: outside the base path", cwd, threadID)
+		return nil
+	}
+	snap, err := s.snapshots.Take(ctx, cwd)
+	if err != nil {
+		log.Printf("[snapshots] failed to snapshot %s for thread %s: %v", cwd, threadID, err)
+		return nil
+	}
+	s.snapshotsMu.Lock()
+	stale := s.pendingSnapshots[threadID]
+	s.pendingSnapshots[threadID] = &snap
+	s.snapshotsMu.Unlock()
+	// A turn/start whose turn never started leaves its snapshot behind.
+	s.dropTurnSnapshot(stale)
+	return &snap
+}
+
+// claimTurnSnapshot takes the thread's pending snapshot; a non-nil snap
+// only claims that snapshot.
+func (s *Server) claimTurnSnapshot(threadID string, snap *snapshots.Snapshot) *snapshots.Snapshot {
+	s.snapshotsMu.Lock()
+	defer s.snapshotsMu.Unlock()
+	pending := s.pendingSnapshots[threadID]
+	if pending == nil || (snap != nil && pending != snap) {
+		return nil
+	}
+	delete(s.pendingSnapshots, threadID)
+	return pending
+}
+
+// discardTurnSnapshot drops the snapshot of a turn/start that failed.
+func (s *Server) discardTurnSnapshot(threadID string, snap *snapshots.Snapshot) {
+	if snap != nil {
+		s.dropTurnSnapshot(s.claimTurnSnapshot(threadID, snap))
+	}
+}
+
+// turnStartedSnapshot ties the pending snapshot to the turn that
+// turn/start started, using the turn id from the RPC result or, for agents
+// that leave it out, from the turn/started notification.
+func (s *Server) turnStartedSnapshot(threadID string, params map[string]any) {
+	if s.snapshots == nil {
+		return
+	}
+	turnID, _ := params["turnId"].(string)
+	if turn, ok := params["turn"].(map[string]any); ok && turnID == "" {
+		turnID, _ = turn["id"].(string)
+	}
+	if turnID == "" {
+		return
+	}
+	if snap := s.claimTurnSnapshot(threadID, nil); snap != nil {
+		s.recordTurnSnapshot(threadID, turnID, snap)
+	}
+}
+
+// recordTurnSnapshot logs a snapshot as darkhold/turn/snapshot and prunes
+// the thread's oldest snapshots beyond --turn-snapshot-keep.
+func (s *Server) recordTurnSnapshot(threadID, turnID string, snap *snapshots.Snapshot) {
+	notice, _ := json.Marshal(map[string]any{
+		"method": "darkhold/turn/snapshot",
+		"params": map[string]any{"threadId": threadID, "turnId": turnID, "snapshot": snap},
+	})
+	s.publishThreadEvent(threadID, string(notice))
+
+	recorded, err := s.loadTurnSnapshots(threadID)
+	if err != nil {
+		return
+	}
+	// Older snapshots were pruned in order, so stop at the first one gone.
+	for i := len(recorded) - s.cfg.TurnSnapshotKeep - 1; i >= 0; i-- {
+		if !s.snapshots.Exists(context.Background(), recorded[i].Snapshot) {
+			break
+		}
+		s.dropTurnSnapshot(&recorded[i].Snapshot)
+	}
+}
+
+func (s *Server) dropTurnSnapshot(snap *snapshots.Snapshot) {
+	if snap == nil {
+		return
+	}
+	if err := s.snapshots.Drop(context.Background(), *snap); err != nil {
+		log.Printf("[snapshots] failed to drop snapshot %s: %v", snap.ID, err)
+	}
+}
+
+// loadTurnSnapshots lists a thread's snapshots, oldest first, from the
+// darkhold/turn/snapshot and darkhold/turn/rolledBack events in its log.
+func (s *Server) loadTurnSnapshots(threadID string) ([]turnSnapshot, error) {
+	records, err := s.eventStore.ReadRange(threadID, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	var out []turnSnapshot
+	for _, record := range records {
+		if !strings.Contains(record.Payload, "darkhold/turn/") {
+			continue
+		}
+		var event struct {
+			Method string `json:"method"`
+			Params struct {
+				TurnID     string             `json:"turnId"`
+				Snapshot   snapshots.Snapshot `json:"snapshot"`
+				SnapshotID string             `json:"snapshotId"`
+				At         int64              `json:"at"`
+			} `json:"params"`
+		}
+		if json.Unmarshal([]byte(record.Payload), &event) != nil {
+			continue
+		}
+		switch event.Method {
+		case "darkhold/turn/snapshot":
+			out = append(out, turnSnapshot{TurnID: event.Params.TurnID, Snapshot: event.Params.Snapshot})
+		case "darkhold/turn/rolledBack":
+			for i := range out {
+				if out[i].Snapshot.ID == event.Params.SnapshotID {
+					out[i].RolledBack, out[i].RolledAt = true, event.Params.At
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// handleTurnSnapshots lists a thread's turn snapshots:
+//
+//	GET ?threadId=    {threadId, enabled, snapshots}
+func (s *Server) handleTurnSnapshots(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	threadID := strings.TrimSpace(r.URL.Query().Get("threadId"))
+	if threadID == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId is required.")
+		return
+	}
+	recorded, err := s.loadTurnSnapshots(threadID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
+		return
+	}
+	list := make([]turnSnapshot, 0, len(recorded))
+	for _, entry := range recorded {
+		entry.Available = s.snapshots != nil && s.snapshots.Exists(r.Context(), entry.Snapshot)
+		list = append(list, entry)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"threadId": threadID, "enabled": s.snapshots != nil, "snapshots": list})
+}
+
+// handleTurnRollback puts a thread's cwd back to how it was before a turn:
+//
+//	POST {threadId, turnId}    {threadId, turnId, snapshotId, restored, removed}
+//
+// Every change made since the snapshot is undone, including those of later
+// turns and edits made outside the agent. The rollback is appended to the
+// thread log as darkhold/turn/rolledBack; the snapshot is kept, so a turn
+// can be rolled back again.
+func (s *Server) handleTurnRollback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
+	var request struct {
+		ThreadID string `json:"threadId"`
+		TurnID   string `json:"turnId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+		return
+	}
+	request.ThreadID, request.TurnID = strings.TrimSpace(request.ThreadID), strings.TrimSpace(request.TurnID)
+	if request.ThreadID == "" || request.TurnID == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "threadId and turnId are required.")
+		return
+	}
+	if s.snapshots == nil {
+		writeError(w, http.StatusNotFound, errCodeSnapshotNotFound, "turn snapshots are disabled.")
+		return
+	}
+	s.reviewMu.Lock()
+	defer s.reviewMu.Unlock()
+	recorded, err := s.loadTurnSnapshots(request.ThreadID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
+		return
+	}
+	var snap *snapshots.Snapshot
+	for i := range recorded {
+		if recorded[i].TurnID == request.TurnID {
+			snap = &recorded[i].Snapshot
+		}
+	}
+	if snap == nil {
+		writeError(w, http.StatusNotFound, errCodeSnapshotNotFound, "no snapshot was taken before the turn.")
+		return
+	}
+	if _, active := s.threadLiveState(); active[request.ThreadID] {
+		writeErrorDetails(w, http.StatusConflict, errCodeRollbackConflict, "a turn is running on the thread.", map[string]any{"reason": "activeTurn"})
+		return
+	}
+	result, err := s.snapshots.Restore(r.Context(), *snap)
+	if errors.Is(err, snapshots.ErrGone) {
+		writeErrorDetails(w, http.StatusGone, errCodeSnapshotExpired, "the snapshot was pruned.", map[string]any{"snapshotId": snap.ID})
+		return
+	}
+	if err != nil {
+		log.Printf("[snapshots] rollback of thread %s turn %s failed: %v", request.ThreadID, request.TurnID, err)
+		writeError(w, http.StatusInternalServerError, errCodeInternal, err.Error())
+		return
+	}
+	by, at := clientIdentity(r.Context()), time.Now().UnixMilli()
+	notice, _ := json.Marshal(map[string]any{
+		"method": "darkhold/turn/rolledBack",
+		"params": map[string]any{
+			"threadId":   request.ThreadID,
+			"turnId":     request.TurnID,
+			"snapshotId": snap.ID,
+			"mode":       snap.Mode,
+			"restored":   result.Restored,
+			"removed":    result.Removed,
+			"by":         by,
+			"at":         at,
+		},
+	})
+	s.publishThreadEvent(request.ThreadID, string(notice))
+	s.audit.record(r, "turn.rollback", map[string]any{"threadId": request.ThreadID, "turnId": request.TurnID, "snapshotId": snap.ID})
+	writeJSON(w, http.StatusOK, map[string]any{
+		"threadId":   request.ThreadID,
+		"turnId":     request.TurnID,
+		"snapshotId": snap.ID,
+		"restored":   result.Restored,
+		"removed":    result.Removed,
+	})
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/config"
+)
+
+func TestTurnRollbackRestoresTheCwd(t *testing.T) {
+	s := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.TurnSnapshots = "copy"
+		cfg.TurnSnapshotKeep = 1
+	})
+	defer s.close()
+
+	project := filepath.Join(s.baseDir, "project")
+	main := filepath.Join(project, "main.go")
+	if err := os.MkdirAll(project, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(main, []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": project})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+	stream := openSSE(t, s.http.URL, threadID, "")
+	defer stream.Body.Close()
+
+	snapshotsFor := func() []any {
+		t.Helper()
+		_, body := doJSON(t, http.MethodGet, s.http.URL+"/api/thread/turn/snapshots?threadId="+threadID, nil)
+		list, _ := body["snapshots"].([]any)
+		return list
+	}
+	startTurn := func(want int) string {
+		t.Helper()
+		postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "edit main.go"}}})
+		var list []any
+		waitForCondition(t, 3*time.Second, 20*time.Millisecond, func() bool {
+			list = snapshotsFor()
+			return len(list) == want
+		})
+		return list[want-1].(map[string]any)["turnId"].(string)
+	}
+	finishTurn := func() {
+		t.Helper()
+		acceptNextApproval(t, s.http.URL, threadID, stream)
+		waitForSSEEvent(t, stream, func(event sseEvent) bool {
+			return parseJSON(t, event.Data)["method"] == "turn/completed"
+		}, 10*time.Second)
+	}
+
+	firstTurn := startTurn(1)
+	if err := os.WriteFile(main, []byte("package broken\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(project, "scratch.txt"), []byte("junk\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	resp, busy := doJSON(t, http.MethodPost, s.http.URL+"/api/thread/turn/rollback", map[string]any{"threadId": threadID, "turnId": firstTurn})
+	if resp.StatusCode != http.StatusConflict || busy["code"] != errCodeRollbackConflict {
+		t.Fatalf("expected ROLLBACK_CONFLICT during the turn: %d %v", resp.StatusCode, busy)
+	}
+	finishTurn()
+
+	resp, rolled := doJSON(t, http.MethodPost, s.http.URL+"/api/thread/turn/rollback", map[string]any{"threadId": threadID, "turnId": firstTurn})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected rollback: %d %v", resp.StatusCode, rolled)
+	}
+	if data, _ := os.ReadFile(main); string(data) != "package main\n" {
+		t.Fatalf("expected main.go to be restored, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(project, "scratch.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected the file created by the turn to be removed: %v", err)
+	}
+	if events, _ := s.store.Read(threadID); !strings.Contains(strings.Join(events, "\n"), "darkhold/turn/rolledBack") {
+		t.Fatalf("expected the rollback in the thread log: %v", events)
+	}
+
+	// With one snapshot kept, the next turn prunes the first.
+	startTurn(2)
+	var first map[string]any
+	waitForCondition(t, 3*time.Second, 20*time.Millisecond, func() bool {
+		first = snapshotsFor()[0].(map[string]any)
+		return first["available"] == false
+	})
+	if first["rolledBack"] != true {
+		t.Fatalf("expected the first snapshot to be pruned: %v", first)
+	}
+	finishTurn()
+	resp, expired := doJSON(t, http.MethodPost, s.http.URL+"/api/thread/turn/rollback", map[string]any{"threadId": threadID, "turnId": firstTurn})
+	if resp.StatusCode != http.StatusGone || expired["code"] != errCodeSnapshotExpired {
+		t.Fatalf("expected SNAPSHOT_EXPIRED: %d %v", resp.StatusCode, expired)
+	}
+	resp, missing := doJSON(t, http.MethodPost, s.http.URL+"/api/thread/turn/rollback", map[string]any{"threadId": threadID, "turnId": "turn-404"})
+	if resp.StatusCode != http.StatusNotFound || missing["code"] != errCodeSnapshotNotFound {
+		t.Fatalf("expected SNAPSHOT_NOT_FOUND: %d %v", resp.StatusCode, missing)
+	}
+}
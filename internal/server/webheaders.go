@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// webContentSecurityPolicy is the default policy for the web UI, with the
+// frame-ancestors sources left to fill in. Scripts only come from the
+// embedded bundle; styles allow inline attributes for React, and images and
+// fonts allow data: URIs for Bootstrap's icons. Agent output is rendered as
+// markdown, so this caps what injected markup could load or run.
+const webContentSecurityPolicy = "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data: blob:; font-src 'self' data:; connect-src 'self'; object-src 'none'; " +
+	"base-uri 'self'; form-action 'self'; frame-ancestors "
+
+// setWebSecurityHeaders adds the hardening headers to a web UI response.
+// --csp replaces the policy ("off" drops it) and --frame-ancestors sets who
+// may frame the UI, such as a dev proxy on another origin.
+func (s *Server) setWebSecurityHeaders(w http.ResponseWriter) {
+	header := w.Header()
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Referrer-Policy", "no-referrer")
+	switch s.cfg.ContentSecurityPolicy {
+	case "":
+		header.Set("Content-Security-Policy", webContentSecurityPolicy+s.frameAncestors())
+	case "off":
+	default:
+		header.Set("Content-Security-Policy", s.cfg.ContentSecurityPolicy)
+	}
+	// X-Frame-Options is for browsers without frame-ancestors; it can only
+	// say none or same-origin.
+	switch s.frameAncestors() {
+	case "'none'":
+		header.Set("X-Frame-Options", "DENY")
+	case "'self'":
+		header.Set("X-Frame-Options", "SAMEORIGIN")
+	}
+}
+
+func (s *Server) frameAncestors() string {
+	if sources := strings.TrimSpace(s.cfg.FrameAncestors); sources != "" {
+		return sources
+	}
+	return "'none'"
+}
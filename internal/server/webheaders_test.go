@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"darkhold-go/internal/config"
+)
+
+func TestWebSecurityHeaders(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+	resp, err := http.Get(s.http.URL + "/threads/abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	csp := resp.Header.Get("Content-Security-Policy")
+	if !strings.Contains(csp, "script-src 'self';") || !strings.HasSuffix(csp, "frame-ancestors 'none'") || strings.Contains(csp, "unsafe-eval") {
+		t.Fatalf("unexpected policy %q", csp)
+	}
+	for name, want := range map[string]string{"X-Content-Type-Options": "nosniff", "Referrer-Policy": "no-referrer", "X-Frame-Options": "DENY"} {
+		if got := resp.Header.Get(name); got != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+
+	// A dev proxy on another origin may frame the UI; --csp off drops the
+	// policy but keeps the other headers.
+	proxied := startIntegrationServer(t, func(cfg *config.Config) {
+		cfg.ContentSecurityPolicy = "off"
+		cfg.FrameAncestors = "'self' http://127.0.0.1:5173"
+	})
+	defer proxied.close()
+	resp, err = http.Get(proxied.http.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Content-Security-Policy") != "" || resp.Header.Get("X-Frame-Options") != "" || resp.Header.Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatalf("unexpected headers %v", resp.Header)
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"darkhold-go/internal/webhooks"
+)
+
+const errCodeWebhookNotFound = "WEBHOOK_NOT_FOUND"
+
+func openWebhooks(dataDir string) *webhooks.Manager {
+	path := ""
+	if dataDir != "" {
+		path = filepath.Join(dataDir, "webhooks.json")
+	}
+	manager, err := webhooks.Open(path, nil)
+	if err != nil {
+		log.Printf("[webhooks] failed to load %s, using in-memory subscriptions: %v", path, err)
+		manager, _ = webhooks.Open("", nil)
+	}
+	return manager
+}
+
+func (s *Server) handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		id := strings.TrimSpace(r.URL.Query().Get("id"))
+		if id == "" {
+			subs := s.webhooks.List()
+			views := make([]webhooks.Subscription, 0, len(subs))
+			for _, sub := range subs {
+				views = append(views, sub.Redacted())
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"webhooks": views})
+			return
+		}
+		sub, ok := s.webhooks.Get(id)
+		if !ok {
+			writeError(w, http.StatusNotFound, errCodeWebhookNotFound, webhooks.ErrNotFound.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, sub.Redacted())
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
+		var request struct {
+			URL       string   `json:"url"`
+			Secret    string   `json:"secret"`
+			Methods   []string `json:"methods"`
+			ThreadIDs []string `json:"threadIds"`
+			Locale    string   `json:"locale"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+			return
+		}
+		sub, err := s.webhooks.Create(webhooks.Subscription{
+			URL:       request.URL,
+			Secret:    request.Secret,
+			Methods:   request.Methods,
+			ThreadIDs: request.ThreadIDs,
+			Locale:    request.Locale,
+		})
+		if errors.Is(err, webhooks.ErrInvalid) || errors.Is(err, webhooks.ErrInvalidLocale) {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
+			return
+		}
+		// The secret is only returned here, so callers can verify signatures.
+		writeJSON(w, http.StatusCreated, sub)
+	case http.MethodDelete:
+		id := strings.TrimSpace(r.URL.Query().Get("id"))
+		if id == "" {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "id is required.")
+			return
+		}
+		err := s.webhooks.Delete(id)
+		if errors.Is(err, webhooks.ErrNotFound) {
+			writeError(w, http.StatusNotFound, errCodeWebhookNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+func (s *Server) handleWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+	id := strings.TrimSpace(r.URL.Query().Get("id"))
+	if id == "" {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "id is required.")
+		return
+	}
+	deliveries, err := s.webhooks.Deliveries(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, errCodeWebhookNotFound, err.Error())
+		return
+	}
+	if status := strings.TrimSpace(r.URL.Query().Get("status")); status != "" {
+		filtered := deliveries[:0]
+		for _, d := range deliveries {
+			if d.Status == status {
+				filtered = append(filtered, d)
+			}
+		}
+		deliveries = filtered
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"webhookId": id, "deliveries": deliveries})
+}
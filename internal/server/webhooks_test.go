@@ -0,0 +1,66 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"darkhold-go/internal/webhooks"
+)
+
+func TestWebhookDeliversThreadEvents(t *testing.T) {
+	received := make(chan webhooks.Envelope, 16)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var envelope webhooks.Envelope
+		_ = json.Unmarshal(body, &envelope)
+		received <- envelope
+	}))
+	defer endpoint.Close()
+
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+
+	resp, created := doJSON(t, http.MethodPost, s.http.URL+"/api/webhooks", map[string]any{"url": endpoint.URL, "methods": []string{"turn/started"}, "threadIds": []string{threadID}})
+	if resp.StatusCode != http.StatusCreated || created["secret"] == "" {
+		t.Fatalf("unexpected create response: %d %v", resp.StatusCode, created)
+	}
+	webhookID := created["id"].(string)
+
+	resp, listed := doJSON(t, http.MethodGet, s.http.URL+"/api/webhooks", nil)
+	hooks := listed["webhooks"].([]any)
+	if resp.StatusCode != http.StatusOK || len(hooks) != 1 || hooks[0].(map[string]any)["secret"] != nil {
+		t.Fatalf("expected one redacted webhook: %d %v", resp.StatusCode, listed)
+	}
+
+	_ = postRPC[map[string]any](t, s.http.URL, "turn/start", map[string]any{"threadId": threadID, "input": []any{map[string]any{"type": "text", "text": "hello"}}})
+	select {
+	case envelope := <-received:
+		if envelope.ThreadID != threadID || envelope.Method != "turn/started" {
+			t.Fatalf("unexpected envelope: %+v", envelope)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+
+	waitForCondition(t, 3*time.Second, 10*time.Millisecond, func() bool {
+		_, payload := doJSON(t, http.MethodGet, s.http.URL+"/api/webhooks/deliveries?id="+webhookID+"&status=delivered", nil)
+		deliveries, _ := payload["deliveries"].([]any)
+		return len(deliveries) == 1
+	})
+
+	resp, _ = doJSON(t, http.MethodDelete, s.http.URL+"/api/webhooks?id="+webhookID, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected delete status %d", resp.StatusCode)
+	}
+	resp, payload := doJSON(t, http.MethodGet, s.http.URL+"/api/webhooks/deliveries?id="+webhookID, nil)
+	if resp.StatusCode != http.StatusNotFound || payload["code"] != errCodeWebhookNotFound {
+		t.Fatalf("expected deleted webhook to be gone: %d %v", resp.StatusCode, payload)
+	}
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	browserfs "darkhold-go/internal/fs"
+	"darkhold-go/internal/threads"
+)
+
+const (
+	errCodeWorkspaceNotFound = "WORKSPACE_NOT_FOUND"
+	errCodeWorkspaceExists   = "WORKSPACE_EXISTS"
+)
+
+// workspaceView is a workspace plus the threads currently grouped under it.
+// Implicit workspaces are synthesized for thread cwds not covered by any
+// explicit workspace; they have no ID or settings.
+type workspaceView struct {
+	threads.Workspace
+	Implicit  bool     `json:"implicit,omitempty"`
+	ThreadIDs []string `json:"threadIds"`
+}
+
+func (s *Server) workspaceViews() []workspaceView {
+	explicit := s.threadIndex.ListWorkspaces()
+	views := make([]workspaceView, 0, len(explicit))
+	byID := map[string]int{}
+	for _, ws := range explicit {
+		byID[ws.ID] = len(views)
+		views = append(views, workspaceView{Workspace: ws, ThreadIDs: []string{}})
+	}
+	implicitByCwd := map[string]int{}
+	for _, meta := range s.threadIndex.List() {
+		cwd := browserfs.NormalizePath(meta.Cwd)
+		if cwd == "" {
+			continue
+		}
+		if ws, ok := s.threadIndex.WorkspaceForCwd(cwd); ok {
+			idx := byID[ws.ID]
+			views[idx].ThreadIDs = append(views[idx].ThreadIDs, meta.ThreadID)
+			continue
+		}
+		idx, ok := implicitByCwd[cwd]
+		if !ok {
+			idx = len(views)
+			implicitByCwd[cwd] = idx
+			views = append(views, workspaceView{
+				Workspace: threads.Workspace{Name: cwd, Cwd: cwd},
+				Implicit:  true,
+				ThreadIDs: []string{},
+			})
+		}
+		views[idx].ThreadIDs = append(views[idx].ThreadIDs, meta.ThreadID)
+	}
+	sort.SliceStable(views, func(i, j int) bool {
+		if views[i].Implicit != views[j].Implicit {
+			return !views[i].Implicit
+		}
+		return views[i].Cwd < views[j].Cwd
+	})
+	return views
+}
+
+func (s *Server) handleWorkspaces(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		id := strings.TrimSpace(r.URL.Query().Get("id"))
+		views := s.workspaceViews()
+		if id == "" {
+			writeJSON(w, http.StatusOK, map[string]any{"workspaces": views})
+			return
+		}
+		for _, view := range views {
+			if !view.Implicit && view.ID == id {
+				writeJSON(w, http.StatusOK, view)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, errCodeWorkspaceNotFound, "workspace not found.")
+	case http.MethodPost, http.MethodPatch:
+		s.handleWorkspaceWrite(w, r)
+	case http.MethodDelete:
+		err := s.threadIndex.DeleteWorkspace(strings.TrimSpace(r.URL.Query().Get("id")))
+		if errors.Is(err, threads.ErrWorkspaceNotFound) {
+			writeError(w, http.StatusNotFound, errCodeWorkspaceNotFound, "workspace not found.")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	default:
+		writeMethodNotAllowed(w)
+	}
+}
+
+func (s *Server) handleWorkspaceWrite(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxRequestBodySize)
+	var request struct {
+		ID       string                     `json:"id"`
+		Name     *string                    `json:"name"`
+		Cwd      *string                    `json:"cwd"`
+		Settings *threads.WorkspaceSettings `json:"settings"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, errCodeInvalidJSON, "Invalid JSON body.")
+		return
+	}
+	if request.Settings != nil && utf8.RuneCountInString(request.Settings.Preamble) > maxPreambleChars {
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, fmt.Sprintf("settings.preamble is limited to %d characters.", maxPreambleChars))
+		return
+	}
+	if request.Cwd != nil {
+		resolved, err := browserfs.ResolvePath(browserfs.NormalizePath(*request.Cwd))
+		if err != nil || strings.TrimSpace(*request.Cwd) == "" {
+			writeError(w, http.StatusBadRequest, errCodeInvalidPath, "cwd must be an existing directory inside the configured base path.")
+			return
+		}
+		request.Cwd = &resolved
+	}
+
+	var (
+		ws     threads.Workspace
+		err    error
+		status = http.StatusOK
+	)
+	if r.Method == http.MethodPost {
+		if request.Cwd == nil {
+			writeError(w, http.StatusBadRequest, errCodeInvalidRequest, "cwd is required.")
+			return
+		}
+		draft := threads.Workspace{Cwd: *request.Cwd}
+		if request.Name != nil {
+			draft.Name = *request.Name
+		}
+		if request.Settings != nil {
+			draft.Settings = *request.Settings
+		}
+		ws, err = s.threadIndex.CreateWorkspace(draft)
+		status = http.StatusCreated
+	} else {
+		ws, err = s.threadIndex.UpdateWorkspace(strings.TrimSpace(request.ID), func(ws *threads.Workspace) error {
+			if request.Name != nil && strings.TrimSpace(*request.Name) != "" {
+				ws.Name = strings.TrimSpace(*request.Name)
+			}
+			if request.Cwd != nil {
+				ws.Cwd = *request.Cwd
+			}
+			if request.Settings != nil {
+				ws.Settings = *request.Settings
+			}
+			return nil
+		})
+	}
+	switch {
+	case errors.Is(err, threads.ErrWorkspaceNotFound):
+		writeError(w, http.StatusNotFound, errCodeWorkspaceNotFound, "workspace not found.")
+	case errors.Is(err, threads.ErrWorkspaceExists):
+		writeError(w, http.StatusConflict, errCodeWorkspaceExists, "a workspace with this name already exists.")
+	case errors.Is(err, threads.ErrWorkspaceInvalid):
+		writeError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error()+".")
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, errCodeStorage, err.Error())
+	default:
+		writeJSON(w, status, ws)
+	}
+}
+
+// withWorkspaceDefaults fills model and approvalPolicy on thread/start and
+// thread/resume from the workspace that owns the thread's cwd, leaving any
+// values the caller set explicitly.
+func (s *Server) withWorkspaceDefaults(threadID string, params map[string]any) map[string]any {
+	cwd, _ := params["cwd"].(string)
+	if cwd == "" && threadID != "" {
+		meta, _ := s.threadIndex.Get(threadID)
+		cwd = meta.Cwd
+	}
+	ws, ok := s.threadIndex.WorkspaceForCwd(cwd)
+	if !ok {
+		return params
+	}
+	out := make(map[string]any, len(params)+2)
+	maps.Copy(out, params)
+	if _, set := out["model"]; !set && ws.Settings.Model != "" {
+		out["model"] = ws.Settings.Model
+	}
+	if _, set := out["approvalPolicy"]; !set && ws.Settings.ApprovalPolicy != "" {
+		out["approvalPolicy"] = ws.Settings.ApprovalPolicy
+	}
+	return out
+}
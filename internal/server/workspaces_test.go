@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestWorkspaceGroupsThreadsAndCarriesSettings(t *testing.T) {
+	s := startIntegrationServer(t)
+	defer s.close()
+
+	resp, created := doJSON(t, http.MethodPost, s.http.URL+"/api/workspaces", map[string]any{
+		"name":     "Payments",
+		"cwd":      s.baseDir,
+		"settings": map[string]any{"model": "gpt-5-codex", "pinnedNotes": []string{"run make lint before finishing"}},
+	})
+	if resp.StatusCode != http.StatusCreated || created["id"] != "payments" {
+		t.Fatalf("unexpected create: %d %v", resp.StatusCode, created)
+	}
+
+	started := postRPC[map[string]any](t, s.http.URL, "thread/start", map[string]any{"cwd": s.baseDir})
+	threadID := started["thread"].(map[string]any)["id"].(string)
+
+	resp, view := doJSON(t, http.MethodGet, s.http.URL+"/api/workspaces?id=payments", nil)
+	ids, _ := view["threadIds"].([]any)
+	if resp.StatusCode != http.StatusOK || len(ids) != 1 || ids[0] != threadID {
+		t.Fatalf("expected thread grouped under workspace: %d %v", resp.StatusCode, view)
+	}
+
+	defaults := s.app.withWorkspaceDefaults("", map[string]any{"cwd": s.baseDir, "approvalPolicy": "never"})
+	if defaults["model"] != "gpt-5-codex" || defaults["approvalPolicy"] != "never" {
+		t.Fatalf("unexpected thread/start defaults: %v", defaults)
+	}
+	for _, cwd := range []string{"~", s.baseDir + "/", s.baseDir + "/sub/.."} {
+		if defaults := s.app.withWorkspaceDefaults("", map[string]any{"cwd": cwd}); defaults["model"] != "gpt-5-codex" {
+			t.Fatalf("expected cwd %q to match the workspace, got %v", cwd, defaults)
+		}
+	}
+	withNotes := s.app.withPinnedNotes(threadID, map[string]any{"input": []any{map[string]any{"type": "text", "text": "go"}}})
+	if len(withNotes["input"].([]any)) != 2 {
+		t.Fatalf("expected workspace pinned notes to be prepended: %v", withNotes)
+	}
+
+	resp, missing := doJSON(t, http.MethodPatch, s.http.URL+"/api/workspaces", map[string]any{"id": "nope", "name": "x"})
+	if resp.StatusCode != http.StatusNotFound || missing["code"] != errCodeWorkspaceNotFound {
+		t.Fatalf("expected WORKSPACE_NOT_FOUND: %d %v", resp.StatusCode, missing)
+	}
+}
@@ -0,0 +1,410 @@
+// Package snapshots captures a working directory before an agent turn and
+// puts it back on request. Inside a git work tree a snapshot is a commit of
+// every non-ignored file under the directory, built with a private index so
+// the user's index, stash and branches are left alone. Elsewhere it is a
+// plain copy of the directory.
+package snapshots
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// Modes a Manager can snapshot with.
+const (
+	ModeGit  = "git"
+	ModeCopy = "copy"
+	// ModeAuto uses git inside a work tree and a copy elsewhere.
+	ModeAuto = "auto"
+)
+
+// refPrefix keeps git snapshots reachable, so gc does not collect them.
+const refPrefix = "refs/darkhold/snapshots/"
+
+var (
+	// ErrTooLarge means a copy snapshot would exceed the size limit.
+	ErrTooLarge = errors.New("directory is larger than the snapshot size limit")
+	// ErrNotGit means git mode was asked for outside a git work tree.
+	ErrNotGit = errors.New("directory is not inside a git work tree")
+	// ErrGone means the snapshot was pruned or deleted.
+	ErrGone = errors.New("snapshot no longer exists")
+)
+
+// Snapshot identifies one captured state of Dir.
+type Snapshot struct {
+	ID   string `json:"id"`
+	Mode string `json:"mode"`
+	Dir  string `json:"dir"`
+	// Commit is the snapshot commit in git mode.
+	Commit string `json:"commit,omitempty"`
+	// Files and Bytes describe the copy in copy mode.
+	Files     int   `json:"files,omitempty"`
+	Bytes     int64 `json:"bytes,omitempty"`
+	CreatedAt int64 `json:"createdAt"`
+}
+
+// Result is what a restore changed.
+type Result struct {
+	// Restored are files written back; Removed are files created after the
+	// snapshot. Both are relative to the snapshot's Dir.
+	Restored []string `json:"restored"`
+	Removed  []string `json:"removed"`
+}
+
+// Manager takes and restores snapshots.
+type Manager struct {
+	Mode string
+	// Root holds copy snapshots, one directory each.
+	Root string
+	// MaxBytes bounds a copy snapshot; zero means no bound.
+	MaxBytes int64
+}
+
+// Take snapshots dir.
+func (m *Manager) Take(ctx context.Context, dir string) (Snapshot, error) {
+	snap := Snapshot{ID: ulid.Make().String(), Dir: dir, CreatedAt: time.Now().UnixMilli()}
+	top, gitErr := gitTopLevel(ctx, dir)
+	switch {
+	case m.Mode == ModeGit && gitErr != nil:
+		return Snapshot{}, ErrNotGit
+	case m.Mode == ModeGit, m.Mode == ModeAuto && gitErr == nil:
+		snap.Mode = ModeGit
+		commit, err := gitSnapshot(ctx, dir, top, snap.ID)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		snap.Commit = commit
+		return snap, nil
+	}
+	snap.Mode = ModeCopy
+	files, size, err := m.copySnapshot(dir, snap.ID)
+	if err != nil {
+		_ = os.RemoveAll(m.copyDir(snap.ID))
+		return Snapshot{}, err
+	}
+	snap.Files, snap.Bytes = files, size
+	return snap, nil
+}
+
+// Restore puts snap's directory back as it was: changed and deleted files
+// are written back and files created since are removed. Files git ignores
+// are left alone in git mode, as are .git directories in copy mode.
+func (m *Manager) Restore(ctx context.Context, snap Snapshot) (Result, error) {
+	if !m.Exists(ctx, snap) {
+		return Result{}, ErrGone
+	}
+	if snap.Mode == ModeGit {
+		return gitRestore(ctx, snap)
+	}
+	return m.copyRestore(snap)
+}
+
+// Exists reports whether snap can still be restored.
+func (m *Manager) Exists(ctx context.Context, snap Snapshot) bool {
+	if snap.Mode == ModeGit {
+		_, err := git(ctx, snap.Dir, nil, "rev-parse", "--verify", "--quiet", refPrefix+snap.ID)
+		return err == nil
+	}
+	info, err := os.Stat(m.copyDir(snap.ID))
+	return err == nil && info.IsDir()
+}
+
+// Drop deletes snap. Dropping a snapshot that is already gone is not an
+// error.
+func (m *Manager) Drop(ctx context.Context, snap Snapshot) error {
+	if snap.Mode == ModeGit {
+		if !m.Exists(ctx, snap) {
+			return nil
+		}
+		_, err := git(ctx, snap.Dir, nil, "update-ref", "-d", refPrefix+snap.ID)
+		return err
+	}
+	return os.RemoveAll(m.copyDir(snap.ID))
+}
+
+func git(ctx context.Context, dir string, env []string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+func gitTopLevel(ctx context.Context, dir string) (string, error) {
+	out, err := git(ctx, dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return filepath.FromSlash(strings.TrimSpace(out)), nil
+}
+
+// gitTree writes every non-ignored file under dir into a fresh index and
+// returns its tree.
+func gitTree(ctx context.Context, dir string) (string, error) {
+	indexDir, err := os.MkdirTemp("", "darkhold-snapshot-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(indexDir)
+	env := []string{"GIT_INDEX_FILE=" + filepath.Join(indexDir, "index")}
+	if _, err := git(ctx, dir, env, "add", "-A", "--", "."); err != nil {
+		return "", err
+	}
+	tree, err := git(ctx, dir, env, "write-tree")
+	return strings.TrimSpace(tree), err
+}
+
+var gitIdentity = []string{
+	"GIT_AUTHOR_NAME=darkhold", "GIT_AUTHOR_EMAIL=darkhold@localhost",
+	"GIT_COMMITTER_NAME=darkhold", "GIT_COMMITTER_EMAIL=darkhold@localhost",
+}
+
+func gitSnapshot(ctx context.Context, dir, top, id string) (string, error) {
+	tree, err := gitTree(ctx, dir)
+	if err != nil {
+		return "", err
+	}
+	out, err := git(ctx, top, gitIdentity, "commit-tree", tree, "-m", "darkhold turn snapshot "+id)
+	if err != nil {
+		return "", err
+	}
+	commit := strings.TrimSpace(out)
+	if _, err := git(ctx, top, nil, "update-ref", refPrefix+id, commit); err != nil {
+		return "", err
+	}
+	return commit, nil
+}
+
+func gitRestore(ctx context.Context, snap Snapshot) (Result, error) {
+	top, err := gitTopLevel(ctx, snap.Dir)
+	if err != nil {
+		return Result{}, err
+	}
+	current, err := gitTree(ctx, snap.Dir)
+	if err != nil {
+		return Result{}, err
+	}
+	out, err := git(ctx, top, nil, "diff-tree", "-r", "-z", "--no-renames", "--name-status", snap.Commit, current)
+	if err != nil {
+		return Result{}, err
+	}
+	prefix, err := filepath.Rel(top, snap.Dir)
+	if err != nil {
+		return Result{}, err
+	}
+	relative := func(path string) string {
+		rel, err := filepath.Rel(prefix, filepath.FromSlash(path))
+		if err != nil {
+			return path
+		}
+		return filepath.ToSlash(rel)
+	}
+	result := Result{Restored: []string{}, Removed: []string{}}
+	var checkout []string
+	fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		status, path := fields[i], fields[i+1]
+		if status == "A" {
+			if err := os.Remove(filepath.Join(top, filepath.FromSlash(path))); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return result, err
+			}
+			removeEmptyParents(filepath.Join(top, filepath.FromSlash(path)), snap.Dir)
+			result.Removed = append(result.Removed, relative(path))
+			continue
+		}
+		checkout = append(checkout, path)
+		result.Restored = append(result.Restored, relative(path))
+	}
+	if len(checkout) == 0 {
+		return result, nil
+	}
+	indexDir, err := os.MkdirTemp("", "darkhold-restore-")
+	if err != nil {
+		return result, err
+	}
+	defer os.RemoveAll(indexDir)
+	env := []string{"GIT_INDEX_FILE=" + filepath.Join(indexDir, "index")}
+	if _, err := git(ctx, top, env, "read-tree", snap.Commit); err != nil {
+		return result, err
+	}
+	if _, err := git(ctx, top, env, append([]string{"checkout-index", "-f", "--"}, checkout...)...); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func (m *Manager) copyDir(id string) string {
+	return filepath.Join(m.Root, id)
+}
+
+// walkFiles calls fn for every file and symlink under dir, skipping .git.
+func walkFiles(dir string, fn func(rel string, entry fs.DirEntry) error) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".git" && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		return fn(rel, entry)
+	})
+}
+
+func (m *Manager) copySnapshot(dir, id string) (int, int64, error) {
+	var files int
+	var size int64
+	err := walkFiles(dir, func(_ string, entry fs.DirEntry) error {
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		files++
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		if m.MaxBytes > 0 && size > m.MaxBytes {
+			return ErrTooLarge
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	target := m.copyDir(id)
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return 0, 0, err
+	}
+	err = walkFiles(dir, func(rel string, _ fs.DirEntry) error {
+		return copyEntry(filepath.Join(dir, rel), filepath.Join(target, rel))
+	})
+	return files, size, err
+}
+
+func (m *Manager) copyRestore(snap Snapshot) (Result, error) {
+	source := m.copyDir(snap.ID)
+	result := Result{Restored: []string{}, Removed: []string{}}
+	kept := map[string]bool{}
+	err := walkFiles(source, func(rel string, _ fs.DirEntry) error {
+		kept[rel] = true
+		from, to := filepath.Join(source, rel), filepath.Join(snap.Dir, rel)
+		if sameEntry(from, to) {
+			return nil
+		}
+		if err := os.RemoveAll(to); err != nil {
+			return err
+		}
+		if err := copyEntry(from, to); err != nil {
+			return err
+		}
+		result.Restored = append(result.Restored, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	var created []string
+	err = walkFiles(snap.Dir, func(rel string, _ fs.DirEntry) error {
+		if !kept[rel] {
+			created = append(created, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	for _, rel := range created {
+		path := filepath.Join(snap.Dir, rel)
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return result, err
+		}
+		removeEmptyParents(path, snap.Dir)
+		result.Removed = append(result.Removed, filepath.ToSlash(rel))
+	}
+	return result, nil
+}
+
+// copyEntry copies a file or symlink, creating parent directories.
+func copyEntry(from, to string) error {
+	info, err := os.Lstat(from)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		link, err := os.Readlink(from)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(link, to)
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	in, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// sameEntry reports whether two files or symlinks have the same content.
+func sameEntry(a, b string) bool {
+	infoA, errA := os.Lstat(a)
+	infoB, errB := os.Lstat(b)
+	if errA != nil || errB != nil || infoA.Mode().Type() != infoB.Mode().Type() {
+		return false
+	}
+	if infoA.Mode()&fs.ModeSymlink != 0 {
+		linkA, errA := os.Readlink(a)
+		linkB, errB := os.Readlink(b)
+		return errA == nil && errB == nil && linkA == linkB
+	}
+	if infoA.Size() != infoB.Size() || infoA.Mode().Perm() != infoB.Mode().Perm() {
+		return false
+	}
+	dataA, errA := os.ReadFile(a)
+	dataB, errB := os.ReadFile(b)
+	return errA == nil && errB == nil && bytes.Equal(dataA, dataB)
+}
+
+// removeEmptyParents removes the directories above path that a removal left
+// empty, stopping at root.
+func removeEmptyParents(path, root string) {
+	for dir := filepath.Dir(path); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
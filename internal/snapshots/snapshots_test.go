@@ -0,0 +1,126 @@
+package snapshots
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// changeAndRestore takes a snapshot of dir, edits, deletes and creates
+// files, and checks Restore undoes all of it.
+func changeAndRestore(t *testing.T, m *Manager, dir string) Snapshot {
+	t.Helper()
+	ctx := context.Background()
+	writeFiles(t, dir, map[string]string{"main.go": "package main\n", "docs/notes.md": "notes\n"})
+	snap, err := m.Take(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, dir, map[string]string{"main.go": "package broken\n", "new/generated.txt": "junk\n"})
+	if err := os.Remove(filepath.Join(dir, "docs", "notes.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := m.Restore(ctx, snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(result.Restored)
+	if !slices.Equal(result.Restored, []string{"docs/notes.md", "main.go"}) || !slices.Equal(result.Removed, []string{"new/generated.txt"}) {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if readFile(t, filepath.Join(dir, "main.go")) != "package main\n" || readFile(t, filepath.Join(dir, "docs", "notes.md")) != "notes\n" {
+		t.Fatal("expected the files to be restored")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new")); !os.IsNotExist(err) {
+		t.Fatalf("expected the created directory to be removed: %v", err)
+	}
+	return snap
+}
+
+func TestCopySnapshotRestoresAndDrops(t *testing.T) {
+	dir := t.TempDir()
+	m := &Manager{Mode: ModeAuto, Root: t.TempDir()}
+	snap := changeAndRestore(t, m, dir)
+	if snap.Mode != ModeCopy || snap.Files != 2 {
+		t.Fatalf("expected a copy snapshot outside git: %+v", snap)
+	}
+	if err := m.Drop(context.Background(), snap); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Restore(context.Background(), snap); !errors.Is(err, ErrGone) {
+		t.Fatalf("expected a dropped snapshot to be gone, got %v", err)
+	}
+
+	small := &Manager{Mode: ModeCopy, Root: t.TempDir(), MaxBytes: 4}
+	if _, err := small.Take(context.Background(), dir); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected the size limit to apply, got %v", err)
+	}
+}
+
+func TestGitSnapshotLeavesTheIndexAlone(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repo := t.TempDir()
+	ctx := context.Background()
+	run := func(args ...string) string {
+		t.Helper()
+		out, err := git(ctx, repo, gitIdentity, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	run("init", "-q")
+	writeFiles(t, repo, map[string]string{"README.md": "outside the cwd\n", ".gitignore": "*.log\n"})
+	run("add", ".")
+	run("commit", "-q", "-m", "initial")
+	dir := filepath.Join(repo, "app")
+	writeFiles(t, repo, map[string]string{"app/debug.log": "ignored\n"})
+
+	m := &Manager{Mode: ModeGit}
+	snap := changeAndRestore(t, m, dir)
+	if snap.Mode != ModeGit || snap.Commit == "" {
+		t.Fatalf("expected a git snapshot: %+v", snap)
+	}
+	if status := run("status", "--porcelain"); status != "?? app/\n" {
+		t.Fatalf("expected the user's index to be untouched, got %q", status)
+	}
+	if readFile(t, filepath.Join(dir, "debug.log")) != "ignored\n" {
+		t.Fatal("expected ignored files to be left alone")
+	}
+	if err := m.Drop(ctx, snap); err != nil || m.Exists(ctx, snap) {
+		t.Fatalf("expected the snapshot ref to be deleted: %v", err)
+	}
+
+	if _, err := (&Manager{Mode: ModeGit}).Take(ctx, t.TempDir()); !errors.Is(err, ErrNotGit) {
+		t.Fatalf("expected git mode to need a work tree, got %v", err)
+	}
+}
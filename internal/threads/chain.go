@@ -0,0 +1,61 @@
+package threads
+
+import (
+	"errors"
+	"strings"
+)
+
+// MaxChainPromptChars caps a chain's prompt.
+const MaxChainPromptChars = 8000
+
+var ErrChainInvalid = errors.New("chain after must name another thread and prompt must be at most 8000 characters")
+
+// Chain starts a thread's next turn when a turn of the thread it follows
+// completes successfully, with that turn's reply as input.
+type Chain struct {
+	// After is the thread whose turns the chain waits for.
+	After string `json:"after"`
+	// Prompt leads the chained turn's input, ahead of the reply.
+	Prompt string `json:"prompt,omitempty"`
+	// Repeat keeps the chain after it fires; without it the chain fires
+	// once and is removed.
+	Repeat    bool   `json:"repeat,omitempty"`
+	CreatedAt int64  `json:"createdAt"`
+	CreatedBy string `json:"createdBy,omitempty"`
+}
+
+// Validate reports whether threadID may follow the chain's thread.
+func (c Chain) Validate(threadID string) error {
+	if strings.TrimSpace(c.After) == "" || c.After == threadID || len([]rune(c.Prompt)) > MaxChainPromptChars {
+		return ErrChainInvalid
+	}
+	return nil
+}
+
+// ChainCycle returns the loop that making threadID follow after would
+// close, starting and ending at threadID, or nil when there is none. metas
+// are the threads as they are now.
+func ChainCycle(metas []Metadata, threadID, after string) []string {
+	follows := make(map[string]string, len(metas))
+	for _, meta := range metas {
+		if meta.Chain != nil {
+			follows[meta.ThreadID] = meta.Chain.After
+		}
+	}
+	follows[threadID] = after
+	path := []string{threadID}
+	seen := map[string]bool{threadID: true}
+	for next := after; next != ""; next = follows[next] {
+		path = append(path, next)
+		if next == threadID {
+			return path
+		}
+		if seen[next] {
+			// A loop that does not pass through threadID already
+			// existed; it is not this change's.
+			return nil
+		}
+		seen[next] = true
+	}
+	return nil
+}
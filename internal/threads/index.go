@@ -0,0 +1,303 @@
+package threads
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Note is a human-authored annotation attached to a thread. Pinned notes are
+// prepended to every turn/start input for the thread.
+type Note struct {
+	ID        string `json:"id"`
+	Text      string `json:"text"`
+	Pinned    bool   `json:"pinned"`
+	CreatedAt int64  `json:"createdAt"`
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+// Metadata is darkhold-owned state about a thread, kept separately from the
+// agent's own thread records.
+type Metadata struct {
+	ThreadID string `json:"threadId"`
+	// Title is a short display name. TitleSource is "auto" when darkhold
+	// derived it from the first exchange and "user" when set explicitly.
+	Title       string `json:"title,omitempty"`
+	TitleSource string `json:"titleSource,omitempty"`
+	Cwd         string `json:"cwd,omitempty"`
+	CreatedAt   int64  `json:"createdAt,omitempty"`
+	UpdatedAt   int64  `json:"updatedAt,omitempty"`
+	Notes       []Note `json:"notes"`
+	// Tags are free-form labels, normalized by NormalizeTags.
+	Tags []string `json:"tags,omitempty"`
+	// Retry, when set, re-submits turns that fail transiently.
+	Retry *RetryPolicy `json:"retry,omitempty"`
+	// Settings, when set, override the model, reasoning effort or approval
+	// policy of later turns.
+	Settings *Settings `json:"settings,omitempty"`
+	// Chain, when set, starts the thread's next turn after a turn of
+	// another thread completes.
+	Chain *Chain `json:"chain,omitempty"`
+	// WatchFiles publishes darkhold/fs/changed events for the cwd while a
+	// turn is running.
+	WatchFiles bool `json:"watchFiles,omitempty"`
+	// Version counts edits to the title, tags, retry policy, file watching,
+	// settings and chain, so concurrent editors can detect each other's
+	// changes.
+	Version int64 `json:"version"`
+	// ArchivedAt is when the thread was last archived through darkhold; zero
+	// while the thread is active.
+	ArchivedAt int64 `json:"archivedAt,omitempty"`
+	// ImportedAt is set when the thread's history was imported from the
+	// agent by --import-codex-history rather than recorded live.
+	ImportedAt int64 `json:"importedAt,omitempty"`
+	// Shares are the thread's read-only share links.
+	Shares []Share `json:"shares,omitempty"`
+}
+
+// Backend stores the index as one JSON document. Load returns nil data when
+// nothing has been saved yet.
+type Backend interface {
+	Load() ([]byte, error)
+	Save(data []byte) error
+}
+
+// fileBackend keeps the document in a file rewritten atomically on each save.
+type fileBackend struct {
+	path string
+}
+
+func (b fileBackend) Load() ([]byte, error) {
+	data, err := os.ReadFile(b.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (b fileBackend) Save(data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(b.path), 0o755); err != nil {
+		return err
+	}
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.path)
+}
+
+// Store persists the index one record at a time, each change in its own
+// transaction, instead of rewriting a whole document.
+type Store interface {
+	Load() ([]*Metadata, []*Workspace, error)
+	PutThread(meta *Metadata) error
+	PutWorkspace(ws *Workspace) error
+	DeleteWorkspace(id string) error
+}
+
+// Index holds thread metadata in memory and, when a backend is configured,
+// persists it as a single JSON document rewritten on each update, or record
+// by record to a Store.
+type Index struct {
+	backend Backend
+	store   Store
+
+	mu         sync.RWMutex
+	threads    map[string]*Metadata
+	workspaces map[string]*Workspace
+}
+
+type indexFile struct {
+	Threads    []*Metadata  `json:"threads"`
+	Workspaces []*Workspace `json:"workspaces,omitempty"`
+}
+
+// Open loads the index stored at path. An empty path yields an in-memory
+// index; a missing file yields an empty one.
+func Open(path string) (*Index, error) {
+	if path == "" {
+		return OpenBackend(nil)
+	}
+	return OpenBackend(fileBackend{path: path})
+}
+
+// OpenBackend loads the index from backend; a nil backend keeps it in memory.
+func OpenBackend(backend Backend) (*Index, error) {
+	ix := &Index{backend: backend, threads: map[string]*Metadata{}, workspaces: map[string]*Workspace{}}
+	if err := ix.Reload(); err != nil {
+		return nil, err
+	}
+	return ix, nil
+}
+
+// OpenStore loads the index from store.
+func OpenStore(store Store) (*Index, error) {
+	ix := &Index{store: store, threads: map[string]*Metadata{}, workspaces: map[string]*Workspace{}}
+	if err := ix.Reload(); err != nil {
+		return nil, err
+	}
+	return ix, nil
+}
+
+// Reload replaces the in-memory index with the backend's document, for
+// backends that other processes write to.
+func (ix *Index) Reload() error {
+	var file indexFile
+	switch {
+	case ix.store != nil:
+		var err error
+		if file.Threads, file.Workspaces, err = ix.store.Load(); err != nil {
+			return err
+		}
+	case ix.backend != nil:
+		data, err := ix.backend.Load()
+		if err != nil || data == nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &file); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+	threads := map[string]*Metadata{}
+	for _, meta := range file.Threads {
+		if meta != nil && meta.ThreadID != "" {
+			threads[meta.ThreadID] = meta
+		}
+	}
+	workspaces := map[string]*Workspace{}
+	for _, ws := range file.Workspaces {
+		if ws != nil && ws.ID != "" {
+			workspaces[ws.ID] = ws
+		}
+	}
+	ix.mu.Lock()
+	ix.threads = threads
+	ix.workspaces = workspaces
+	ix.mu.Unlock()
+	return nil
+}
+
+// Get returns a copy of the metadata for threadID.
+func (ix *Index) Get(threadID string) (Metadata, bool) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	meta, ok := ix.threads[threadID]
+	if !ok {
+		return Metadata{ThreadID: threadID}, false
+	}
+	return meta.clone(), true
+}
+
+// List returns copies of all known thread metadata ordered by thread ID.
+func (ix *Index) List() []Metadata {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	out := make([]Metadata, 0, len(ix.threads))
+	for _, meta := range ix.threads {
+		out = append(out, meta.clone())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ThreadID < out[j].ThreadID })
+	return out
+}
+
+// Update applies fn to the thread's metadata, creating it if needed, and
+// persists the result. If fn returns an error nothing is changed.
+func (ix *Index) Update(threadID string, fn func(*Metadata) error) (Metadata, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	next := Metadata{ThreadID: threadID}
+	if current, ok := ix.threads[threadID]; ok {
+		next = current.clone()
+	}
+	if err := fn(&next); err != nil {
+		return Metadata{}, err
+	}
+	previous, existed := ix.threads[threadID]
+	ix.threads[threadID] = &next
+	if err := ix.saveThreadLocked(threadID); err != nil {
+		if existed {
+			ix.threads[threadID] = previous
+		} else {
+			delete(ix.threads, threadID)
+		}
+		return Metadata{}, err
+	}
+	return next.clone(), nil
+}
+
+// saveThreadLocked persists the thread's current record.
+func (ix *Index) saveThreadLocked(threadID string) error {
+	if ix.store != nil {
+		return ix.store.PutThread(ix.threads[threadID])
+	}
+	return ix.saveLocked()
+}
+
+// saveWorkspaceLocked persists the workspace's current record, or its
+// removal.
+func (ix *Index) saveWorkspaceLocked(id string) error {
+	if ix.store == nil {
+		return ix.saveLocked()
+	}
+	if ws, ok := ix.workspaces[id]; ok {
+		return ix.store.PutWorkspace(ws)
+	}
+	return ix.store.DeleteWorkspace(id)
+}
+
+func (ix *Index) saveLocked() error {
+	if ix.backend == nil {
+		return nil
+	}
+	file := indexFile{Threads: make([]*Metadata, 0, len(ix.threads))}
+	for _, meta := range ix.threads {
+		file.Threads = append(file.Threads, meta)
+	}
+	sort.Slice(file.Threads, func(i, j int) bool { return file.Threads[i].ThreadID < file.Threads[j].ThreadID })
+	for _, ws := range ix.workspaces {
+		file.Workspaces = append(file.Workspaces, ws)
+	}
+	sort.Slice(file.Workspaces, func(i, j int) bool { return file.Workspaces[i].ID < file.Workspaces[j].ID })
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ix.backend.Save(data)
+}
+
+func (m Metadata) clone() Metadata {
+	out := m
+	out.Notes = append([]Note(nil), m.Notes...)
+	out.Tags = append([]string(nil), m.Tags...)
+	out.Shares = append([]Share(nil), m.Shares...)
+	if m.Retry != nil {
+		retry := *m.Retry
+		out.Retry = &retry
+	}
+	if m.Settings != nil {
+		settings := *m.Settings
+		out.Settings = &settings
+	}
+	if m.Chain != nil {
+		chain := *m.Chain
+		out.Chain = &chain
+	}
+	return out
+}
+
+// PinnedNotes returns the thread's pinned notes in creation order.
+func (m Metadata) PinnedNotes() []Note {
+	pinned := make([]Note, 0, len(m.Notes))
+	for _, note := range m.Notes {
+		if note.Pinned {
+			pinned = append(pinned, note)
+		}
+	}
+	return pinned
+}
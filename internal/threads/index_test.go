@@ -0,0 +1,99 @@
+package threads
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIndexPersistsNotesAcrossOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "threads.json")
+	ix, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var noteID string
+	if _, err := ix.Update("thread-1", func(m *Metadata) error {
+		note, err := m.AddNote("  use pnpm, not npm  ", true)
+		noteID = note.ID
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ix.Update("thread-1", func(m *Metadata) error {
+		_, err := m.AddNote("scratch", false)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, ok := reopened.Get("thread-1")
+	if !ok || len(meta.Notes) != 2 {
+		t.Fatalf("expected 2 persisted notes, got %+v", meta)
+	}
+	pinned := meta.PinnedNotes()
+	if len(pinned) != 1 || pinned[0].ID != noteID || pinned[0].Text != "use pnpm, not npm" {
+		t.Fatalf("unexpected pinned notes: %+v", pinned)
+	}
+}
+
+func TestIndexUpdateErrorLeavesMetadataUnchanged(t *testing.T) {
+	ix, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ix.Update("thread-2", func(m *Metadata) error {
+		return m.DeleteNote("missing")
+	})
+	if !errors.Is(err, ErrNoteNotFound) {
+		t.Fatalf("expected ErrNoteNotFound, got %v", err)
+	}
+	if _, ok := ix.Get("thread-2"); ok {
+		t.Fatal("failed update should not create metadata")
+	}
+	if _, err := ix.Update("thread-2", func(m *Metadata) error {
+		_, err := m.AddNote("   ", false)
+		return err
+	}); !errors.Is(err, ErrNoteEmpty) {
+		t.Fatalf("expected ErrNoteEmpty, got %v", err)
+	}
+}
+
+func TestDeriveTitle(t *testing.T) {
+	cases := map[string]string{
+		"\n\n  Fix   the flaky\tlogin test \nand more detail":                                      "Fix the flaky login test",
+		"Refactor the payment reconciliation job so it streams rows instead of loading everything": "Refactor the payment reconciliation job so it streams rows…",
+		"   ": "",
+		// The last space is past half the limit in bytes but not in runes.
+		strings.Repeat("é", 28) + " " + strings.Repeat("b", 40): strings.Repeat("é", 28) + " " + strings.Repeat("b", 31) + "…",
+	}
+	for prompt, want := range cases {
+		if got := DeriveTitle(prompt); got != want {
+			t.Fatalf("DeriveTitle(%q) = %q, want %q", prompt, got, want)
+		}
+	}
+}
+
+func TestChainCycle(t *testing.T) {
+	metas := []Metadata{
+		{ThreadID: "b", Chain: &Chain{After: "a"}},
+		{ThreadID: "c", Chain: &Chain{After: "b"}},
+		{ThreadID: "x", Chain: &Chain{After: "y"}},
+		{ThreadID: "y", Chain: &Chain{After: "x"}},
+	}
+	if path := ChainCycle(metas, "a", "c"); strings.Join(path, ",") != "a,c,b,a" {
+		t.Fatalf("expected a,c,b,a, got %v", path)
+	}
+	if path := ChainCycle(metas, "d", "c"); path != nil {
+		t.Fatalf("expected no loop, got %v", path)
+	}
+	if path := ChainCycle(metas, "d", "x"); path != nil {
+		t.Fatalf("expected a loop that does not pass through d ignored, got %v", path)
+	}
+}
@@ -0,0 +1,76 @@
+package threads
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// MaxNoteLength bounds a single note so pinned context cannot balloon every
+// turn input.
+const MaxNoteLength = 20000
+
+var (
+	ErrNoteNotFound = errors.New("note not found")
+	ErrNoteEmpty    = errors.New("note text is required")
+	ErrNoteTooLong  = errors.New("note text is too long")
+)
+
+func normalizeNoteText(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", ErrNoteEmpty
+	}
+	if len(text) > MaxNoteLength {
+		return "", ErrNoteTooLong
+	}
+	return text, nil
+}
+
+// AddNote appends a new note and returns it.
+func (m *Metadata) AddNote(text string, pinned bool) (Note, error) {
+	text, err := normalizeNoteText(text)
+	if err != nil {
+		return Note{}, err
+	}
+	now := time.Now().UnixMilli()
+	note := Note{ID: ulid.Make().String(), Text: text, Pinned: pinned, CreatedAt: now, UpdatedAt: now}
+	m.Notes = append(m.Notes, note)
+	return note, nil
+}
+
+// EditNote replaces the text and/or pinned flag of an existing note. Nil
+// arguments leave the field unchanged.
+func (m *Metadata) EditNote(noteID string, text *string, pinned *bool) (Note, error) {
+	for i := range m.Notes {
+		if m.Notes[i].ID != noteID {
+			continue
+		}
+		if text != nil {
+			normalized, err := normalizeNoteText(*text)
+			if err != nil {
+				return Note{}, err
+			}
+			m.Notes[i].Text = normalized
+		}
+		if pinned != nil {
+			m.Notes[i].Pinned = *pinned
+		}
+		m.Notes[i].UpdatedAt = time.Now().UnixMilli()
+		return m.Notes[i], nil
+	}
+	return Note{}, ErrNoteNotFound
+}
+
+// DeleteNote removes a note by ID.
+func (m *Metadata) DeleteNote(noteID string) error {
+	for i := range m.Notes {
+		if m.Notes[i].ID == noteID {
+			m.Notes = append(m.Notes[:i], m.Notes[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNoteNotFound
+}
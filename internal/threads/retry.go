@@ -0,0 +1,49 @@
+package threads
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	DefaultRetryBackoffMs = 2000
+	DefaultRetryMaxDelay  = 5 * time.Minute
+	MaxRetryAttempts      = 20
+)
+
+var ErrRetryInvalid = errors.New("retry maxAttempts must be between 0 and 20 and backoffMs must not be negative")
+
+// RetryPolicy controls automatic re-submission of turns that fail with a
+// transient error. A nil policy or zero MaxAttempts disables retries.
+type RetryPolicy struct {
+	MaxAttempts int `json:"maxAttempts"`
+	// BackoffMs is the delay before the first retry; each further attempt
+	// doubles it, capped at MaxBackoffMs (or DefaultRetryMaxDelay).
+	BackoffMs    int64 `json:"backoffMs,omitempty"`
+	MaxBackoffMs int64 `json:"maxBackoffMs,omitempty"`
+}
+
+// Validate reports whether the policy values are usable.
+func (p RetryPolicy) Validate() error {
+	if p.MaxAttempts < 0 || p.MaxAttempts > MaxRetryAttempts || p.BackoffMs < 0 || p.MaxBackoffMs < 0 {
+		return ErrRetryInvalid
+	}
+	return nil
+}
+
+// Delay returns the wait before retry number attempt (starting at 1).
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	base := time.Duration(p.BackoffMs) * time.Millisecond
+	if p.BackoffMs == 0 {
+		base = DefaultRetryBackoffMs * time.Millisecond
+	}
+	limit := DefaultRetryMaxDelay
+	if p.MaxBackoffMs > 0 {
+		limit = time.Duration(p.MaxBackoffMs) * time.Millisecond
+	}
+	delay := base
+	for i := 1; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
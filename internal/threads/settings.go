@@ -0,0 +1,43 @@
+package threads
+
+import (
+	"errors"
+	"slices"
+	"unicode/utf8"
+)
+
+// ReasoningEfforts and ApprovalPolicies are the values the agent accepts for
+// turn/start's effort and approvalPolicy.
+var (
+	ReasoningEfforts = []string{"none", "minimal", "low", "medium", "high", "xhigh"}
+	ApprovalPolicies = []string{"untrusted", "on-failure", "on-request", "never"}
+)
+
+const maxModelRunes = 128
+
+// Settings override the agent's defaults for every later turn of a thread.
+// Empty fields leave the agent's (or workspace's) choice alone.
+type Settings struct {
+	Model          string `json:"model,omitempty"`
+	Effort         string `json:"effort,omitempty"`
+	ApprovalPolicy string `json:"approvalPolicy,omitempty"`
+}
+
+// IsZero reports whether no setting is overridden.
+func (s Settings) IsZero() bool {
+	return s == Settings{}
+}
+
+// Validate reports whether the values are ones the agent accepts.
+func (s Settings) Validate() error {
+	if utf8.RuneCountInString(s.Model) > maxModelRunes {
+		return errors.New("model must be at most 128 characters")
+	}
+	if s.Effort != "" && !slices.Contains(ReasoningEfforts, s.Effort) {
+		return errors.New("effort must be one of none, minimal, low, medium, high, xhigh")
+	}
+	if s.ApprovalPolicy != "" && !slices.Contains(ApprovalPolicies, s.ApprovalPolicy) {
+		return errors.New("approvalPolicy must be one of untrusted, on-failure, on-request, never")
+	}
+	return nil
+}
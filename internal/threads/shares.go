@@ -0,0 +1,74 @@
+package threads
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// MaxShareLabelLength bounds the free-form label on a share link.
+const MaxShareLabelLength = 200
+
+var (
+	ErrShareNotFound     = errors.New("share link not found")
+	ErrShareLabelTooLong = errors.New("share label is too long")
+)
+
+// Share is a read-only link to a thread's transcript and live events. The
+// link itself is a token signed by the server; only its ID is kept here, so
+// deleting the share revokes the link.
+type Share struct {
+	ID        string `json:"id"`
+	Label     string `json:"label,omitempty"`
+	CreatedBy string `json:"createdBy,omitempty"`
+	CreatedAt int64  `json:"createdAt"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// AddShare records a new share link valid until expiresAt and drops expired
+// ones.
+func (m *Metadata) AddShare(label, createdBy string, expiresAt time.Time) (Share, error) {
+	label = strings.TrimSpace(label)
+	if len(label) > MaxShareLabelLength {
+		return Share{}, ErrShareLabelTooLong
+	}
+	now := time.Now()
+	share := Share{ID: ulid.Make().String(), Label: label, CreatedBy: createdBy, CreatedAt: now.UnixMilli(), ExpiresAt: expiresAt.UnixMilli()}
+	m.Shares = append(m.ActiveShares(now), share)
+	return share, nil
+}
+
+// DeleteShare revokes a share link by ID.
+func (m *Metadata) DeleteShare(shareID string) error {
+	for i := range m.Shares {
+		if m.Shares[i].ID == shareID {
+			m.Shares = append(m.Shares[:i], m.Shares[i+1:]...)
+			return nil
+		}
+	}
+	return ErrShareNotFound
+}
+
+// ActiveShares returns the share links that have not expired by now.
+func (m Metadata) ActiveShares(now time.Time) []Share {
+	active := make([]Share, 0, len(m.Shares))
+	for _, share := range m.Shares {
+		if share.ExpiresAt > now.UnixMilli() {
+			active = append(active, share)
+		}
+	}
+	return active
+}
+
+// ActiveShare reports whether the share link shareID exists and has not
+// expired by now.
+func (m Metadata) ActiveShare(shareID string, now time.Time) (Share, bool) {
+	for _, share := range m.ActiveShares(now) {
+		if share.ID == shareID {
+			return share, true
+		}
+	}
+	return Share{}, false
+}
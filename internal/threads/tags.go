@@ -0,0 +1,44 @@
+package threads
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxTags     = 32
+	maxTagRunes = 64
+)
+
+// NormalizeTags trims, lowercases, de-duplicates and sorts tags, dropping
+// empty ones. It rejects more than maxTags tags or tags longer than
+// maxTagRunes.
+func NormalizeTags(tags []string) ([]string, error) {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxTagRunes {
+			return nil, errors.New("tags must be at most 64 characters")
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	if len(out) > maxTags {
+		return nil, errors.New("a thread can have at most 32 tags")
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// HasTag reports whether the thread carries tag, compared case-insensitively.
+func (m Metadata) HasTag(tag string) bool {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	return slices.Contains(m.Tags, tag)
+}
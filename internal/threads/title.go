@@ -0,0 +1,35 @@
+package threads
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	TitleSourceAuto = "auto"
+	TitleSourceUser = "user"
+
+	maxTitleRunes = 60
+)
+
+// DeriveTitle builds a short display title from a user prompt: the first
+// non-empty line, whitespace-collapsed and cut at a word boundary.
+func DeriveTitle(prompt string) string {
+	line := ""
+	for candidate := range strings.SplitSeq(prompt, "\n") {
+		if strings.TrimSpace(candidate) != "" {
+			line = candidate
+			break
+		}
+	}
+	line = strings.Join(strings.Fields(line), " ")
+	if utf8.RuneCountInString(line) <= maxTitleRunes {
+		return line
+	}
+	runes := []rune(line)
+	cut := string(runes[:maxTitleRunes])
+	if space := strings.LastIndexByte(cut, ' '); space >= 0 && utf8.RuneCountInString(cut[:space]) > maxTitleRunes/2 {
+		cut = cut[:space]
+	}
+	return strings.TrimRight(cut, " .,;:-") + "…"
+}
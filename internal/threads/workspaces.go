@@ -0,0 +1,194 @@
+package threads
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	browserfs "darkhold-go/internal/fs"
+)
+
+var (
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+	ErrWorkspaceExists   = errors.New("workspace already exists")
+	ErrWorkspaceInvalid  = errors.New("workspace requires a name or cwd")
+)
+
+// WorkspaceSettings are defaults shared by every thread in a workspace.
+type WorkspaceSettings struct {
+	ApprovalPolicy string   `json:"approvalPolicy,omitempty"`
+	Model          string   `json:"model,omitempty"`
+	PinnedNotes    []string `json:"pinnedNotes,omitempty"`
+	// Preamble is injected into every turn of the workspace's threads, after
+	// the global --system-preamble.
+	Preamble string `json:"preamble,omitempty"`
+}
+
+// Workspace groups threads by project directory. Threads whose cwd is the
+// workspace cwd or anything beneath it belong to the workspace.
+type Workspace struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Cwd       string            `json:"cwd"`
+	Settings  WorkspaceSettings `json:"settings"`
+	CreatedAt int64             `json:"createdAt"`
+	UpdatedAt int64             `json:"updatedAt"`
+}
+
+// WorkspaceID derives a stable slug from a name, falling back to the cwd's
+// base name.
+func WorkspaceID(name, cwd string) string {
+	source := strings.TrimSpace(name)
+	if source == "" && cwd != "" {
+		source = filepath.Base(cwd)
+	}
+	var b strings.Builder
+	lastDash := false
+	for _, r := range strings.ToLower(source) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			lastDash = false
+		case !lastDash && b.Len() > 0:
+			b.WriteByte('-')
+			lastDash = true
+		}
+	}
+	return strings.TrimRight(b.String(), "-")
+}
+
+// CreateWorkspace stores a new workspace.
+func (ix *Index) CreateWorkspace(ws Workspace) (Workspace, error) {
+	ws.Cwd = cleanCwd(ws.Cwd)
+	ws.Name = strings.TrimSpace(ws.Name)
+	if ws.Name == "" {
+		ws.Name = filepath.Base(ws.Cwd)
+	}
+	ws.ID = WorkspaceID(ws.Name, ws.Cwd)
+	if ws.ID == "" || ws.Cwd == "" {
+		return Workspace{}, ErrWorkspaceInvalid
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if _, exists := ix.workspaces[ws.ID]; exists {
+		return Workspace{}, ErrWorkspaceExists
+	}
+	now := time.Now().UnixMilli()
+	ws.CreatedAt, ws.UpdatedAt = now, now
+	ws.Settings = ws.Settings.clone()
+	ix.workspaces[ws.ID] = &ws
+	if err := ix.saveWorkspaceLocked(ws.ID); err != nil {
+		delete(ix.workspaces, ws.ID)
+		return Workspace{}, err
+	}
+	return ws, nil
+}
+
+// UpdateWorkspace applies fn to an existing workspace and persists it.
+func (ix *Index) UpdateWorkspace(id string, fn func(*Workspace) error) (Workspace, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	current, ok := ix.workspaces[id]
+	if !ok {
+		return Workspace{}, ErrWorkspaceNotFound
+	}
+	next := current.clone()
+	if err := fn(&next); err != nil {
+		return Workspace{}, err
+	}
+	next.ID = id
+	next.Cwd = cleanCwd(next.Cwd)
+	next.UpdatedAt = time.Now().UnixMilli()
+	ix.workspaces[id] = &next
+	if err := ix.saveWorkspaceLocked(id); err != nil {
+		ix.workspaces[id] = current
+		return Workspace{}, err
+	}
+	return next.clone(), nil
+}
+
+// DeleteWorkspace removes a workspace. Its threads are left untouched.
+func (ix *Index) DeleteWorkspace(id string) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	current, ok := ix.workspaces[id]
+	if !ok {
+		return ErrWorkspaceNotFound
+	}
+	delete(ix.workspaces, id)
+	if err := ix.saveWorkspaceLocked(id); err != nil {
+		ix.workspaces[id] = current
+		return err
+	}
+	return nil
+}
+
+// GetWorkspace returns a workspace by ID.
+func (ix *Index) GetWorkspace(id string) (Workspace, bool) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	ws, ok := ix.workspaces[id]
+	if !ok {
+		return Workspace{}, false
+	}
+	return ws.clone(), true
+}
+
+// ListWorkspaces returns all workspaces ordered by ID.
+func (ix *Index) ListWorkspaces() []Workspace {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	out := make([]Workspace, 0, len(ix.workspaces))
+	for _, ws := range ix.workspaces {
+		out = append(out, ws.clone())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// WorkspaceForCwd returns the workspace with the longest cwd that contains
+// cwd.
+func (ix *Index) WorkspaceForCwd(cwd string) (Workspace, bool) {
+	cwd = cleanCwd(cwd)
+	if cwd == "" {
+		return Workspace{}, false
+	}
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	var best *Workspace
+	for _, ws := range ix.workspaces {
+		if !cwdWithin(cwd, ws.Cwd) {
+			continue
+		}
+		if best == nil || len(ws.Cwd) > len(best.Cwd) {
+			best = ws
+		}
+	}
+	if best == nil {
+		return Workspace{}, false
+	}
+	return best.clone(), true
+}
+
+func cleanCwd(cwd string) string {
+	return browserfs.NormalizePath(cwd)
+}
+
+func cwdWithin(cwd, root string) bool {
+	return browserfs.PathWithin(cwd, root)
+}
+
+func (w Workspace) clone() Workspace {
+	out := w
+	out.Settings = w.Settings.clone()
+	return out
+}
+
+func (s WorkspaceSettings) clone() WorkspaceSettings {
+	out := s
+	out.PinnedNotes = append([]string(nil), s.PinnedNotes...)
+	return out
+}
@@ -0,0 +1,33 @@
+package threads
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWorkspaceForCwdPrefersLongestMatch(t *testing.T) {
+	ix, _ := Open("")
+	if _, err := ix.CreateWorkspace(Workspace{Name: "Org Monorepo", Cwd: "/home/me/work/org"}); err != nil {
+		t.Fatal(err)
+	}
+	svc, err := ix.CreateWorkspace(Workspace{Cwd: "/home/me/work/org/services/billing/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc.ID != "billing" || svc.Cwd != "/home/me/work/org/services/billing" {
+		t.Fatalf("unexpected workspace: %+v", svc)
+	}
+	if _, err := ix.CreateWorkspace(Workspace{Name: "org monorepo", Cwd: "/elsewhere"}); !errors.Is(err, ErrWorkspaceExists) {
+		t.Fatalf("expected ErrWorkspaceExists, got %v", err)
+	}
+
+	if ws, ok := ix.WorkspaceForCwd("/home/me/work/org/services/billing/api"); !ok || ws.ID != "billing" {
+		t.Fatalf("expected billing workspace, got %+v", ws)
+	}
+	if ws, ok := ix.WorkspaceForCwd("/home/me/work/org/web"); !ok || ws.ID != "org-monorepo" {
+		t.Fatalf("expected org-monorepo workspace, got %+v", ws)
+	}
+	if _, ok := ix.WorkspaceForCwd("/home/me/work/organic"); ok {
+		t.Fatal("sibling directory with shared prefix should not match")
+	}
+}
@@ -0,0 +1,285 @@
+// Package usage counts turns and tokens per client identity for the current
+// UTC day.
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Counts is one identity's usage for a day.
+type Counts struct {
+	Turns  int64 `json:"turns"`
+	Tokens int64 `json:"tokens"`
+}
+
+// Store persists one identity's counters at a time. It keeps a single day:
+// saving counts for a later day drops the earlier day's.
+type Store interface {
+	LoadDay(day string) (map[string]Counts, error)
+	SaveCounts(day, key string, counts Counts) error
+}
+
+// Tracker holds the current day's counters and, when a path or store is
+// configured, persists them so restarts do not reset budgets.
+type Tracker struct {
+	path  string
+	store Store
+	now   func() time.Time
+
+	mu   sync.Mutex
+	day  string
+	keys map[string]*Counts
+	// held is usage reserved for admitted turns that has not been used yet.
+	// It is never persisted and is dropped when the day rolls over.
+	held map[string]*Counts
+}
+
+// Reservation is usage held for key by Reserve until it is used or released.
+type Reservation struct {
+	t    *Tracker
+	key  string
+	day  string
+	left Counts
+}
+
+type trackerFile struct {
+	Day  string            `json:"day"`
+	Keys map[string]Counts `json:"keys"`
+}
+
+// Day returns the UTC calendar day that t falls in.
+func Day(t time.Time) string {
+	return t.UTC().Format(time.DateOnly)
+}
+
+// Open loads counters stored at path. An empty path keeps counters in memory;
+// a missing file starts empty.
+func Open(path string) (*Tracker, error) {
+	t := &Tracker{path: path, now: time.Now, keys: map[string]*Counts{}, held: map[string]*Counts{}}
+	t.day = Day(t.now())
+	if path == "" {
+		return t, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file trackerFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Day == t.day {
+		for key, counts := range file.Keys {
+			c := counts
+			t.keys[key] = &c
+		}
+	}
+	return t, nil
+}
+
+// OpenStore loads today's counters from store.
+func OpenStore(store Store) (*Tracker, error) {
+	t := &Tracker{store: store, now: time.Now, keys: map[string]*Counts{}, held: map[string]*Counts{}}
+	t.day = Day(t.now())
+	saved, err := store.LoadDay(t.day)
+	if err != nil {
+		return nil, err
+	}
+	for key, counts := range saved {
+		c := counts
+		t.keys[key] = &c
+	}
+	return t, nil
+}
+
+// ResetsAt returns when the current day's counters roll over.
+func (t *Tracker) ResetsAt() time.Time {
+	now := t.now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+}
+
+// AddTurn counts one started turn for key.
+func (t *Tracker) AddTurn(key string) error {
+	return t.add(key, Counts{Turns: 1})
+}
+
+// AddTokens counts tokens consumed on behalf of key.
+func (t *Tracker) AddTokens(key string, tokens int64) error {
+	if tokens <= 0 {
+		return nil
+	}
+	return t.add(key, Counts{Tokens: tokens})
+}
+
+func (t *Tracker) add(key string, delta Counts) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollLocked()
+	return t.addLocked(key, delta)
+}
+
+func (t *Tracker) addLocked(key string, delta Counts) error {
+	counts := t.keys[key]
+	if counts == nil {
+		counts = &Counts{}
+		t.keys[key] = counts
+	}
+	counts.Turns += delta.Turns
+	counts.Tokens += delta.Tokens
+	if t.store != nil {
+		return t.store.SaveCounts(t.day, key, *counts)
+	}
+	return t.saveLocked()
+}
+
+// Reserve holds delta for key if check allows it. check is given key's and
+// the whole day's usage with every outstanding reservation counted as used;
+// checking and holding happen under one lock, so concurrent callers cannot
+// both take the last of a budget. A zero delta only checks.
+func (t *Tracker) Reserve(key string, delta Counts, check func(used, total Counts) error) (*Reservation, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollLocked()
+	used := Counts{}
+	if counts := t.keys[key]; counts != nil {
+		used = *counts
+	}
+	if held := t.held[key]; held != nil {
+		used.Turns += held.Turns
+		used.Tokens += held.Tokens
+	}
+	var total Counts
+	for _, counts := range t.keys {
+		total.Turns += counts.Turns
+		total.Tokens += counts.Tokens
+	}
+	for _, held := range t.held {
+		total.Turns += held.Turns
+		total.Tokens += held.Tokens
+	}
+	if err := check(used, total); err != nil {
+		return nil, err
+	}
+	if delta == (Counts{}) {
+		return &Reservation{t: t, key: key, day: t.day}, nil
+	}
+	held := t.held[key]
+	if held == nil {
+		held = &Counts{}
+		t.held[key] = held
+	}
+	held.Turns += delta.Turns
+	held.Tokens += delta.Tokens
+	return &Reservation{t: t, key: key, day: t.day, left: delta}, nil
+}
+
+// Use counts delta as used and takes as much of it as is still held out of
+// the reservation.
+func (r *Reservation) Use(delta Counts) error {
+	t := r.t
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollLocked()
+	released := Counts{Turns: min(delta.Turns, r.left.Turns), Tokens: min(delta.Tokens, r.left.Tokens)}
+	r.releaseLocked(released)
+	return t.addLocked(r.key, delta)
+}
+
+// Release drops whatever the reservation still holds. It may be called more
+// than once.
+func (r *Reservation) Release() {
+	t := r.t
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollLocked()
+	r.releaseLocked(r.left)
+}
+
+func (r *Reservation) releaseLocked(counts Counts) {
+	r.left.Turns -= counts.Turns
+	r.left.Tokens -= counts.Tokens
+	// Holds from an earlier day went with its counters.
+	held := r.t.held[r.key]
+	if held == nil || r.day != r.t.day {
+		return
+	}
+	held.Turns -= counts.Turns
+	held.Tokens -= counts.Tokens
+	if *held == (Counts{}) {
+		delete(r.t.held, r.key)
+	}
+}
+
+// Get returns today's counts for key.
+func (t *Tracker) Get(key string) Counts {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollLocked()
+	if counts := t.keys[key]; counts != nil {
+		return *counts
+	}
+	return Counts{}
+}
+
+// Total returns today's counts summed across all keys.
+func (t *Tracker) Total() Counts {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollLocked()
+	var total Counts
+	for _, counts := range t.keys {
+		total.Turns += counts.Turns
+		total.Tokens += counts.Tokens
+	}
+	return total
+}
+
+// Snapshot returns the current day and a copy of every key's counts.
+func (t *Tracker) Snapshot() (string, map[string]Counts) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollLocked()
+	out := make(map[string]Counts, len(t.keys))
+	for key, counts := range t.keys {
+		out[key] = *counts
+	}
+	return t.day, out
+}
+
+func (t *Tracker) rollLocked() {
+	if day := Day(t.now()); day != t.day {
+		t.day = day
+		t.keys = map[string]*Counts{}
+		t.held = map[string]*Counts{}
+	}
+}
+
+func (t *Tracker) saveLocked() error {
+	if t.path == "" {
+		return nil
+	}
+	file := trackerFile{Day: t.day, Keys: make(map[string]Counts, len(t.keys))}
+	for key, counts := range t.keys {
+		file.Keys[key] = *counts
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.path)
+}
@@ -0,0 +1,87 @@
+package usage
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTrackerPersistsAndRollsOverDaily(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	tracker, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.AddTurn("intern"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.AddTokens("intern", 1200); err != nil {
+		t.Fatal(err)
+	}
+	_ = tracker.AddTurn("lead")
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.Get("intern"); got != (Counts{Turns: 1, Tokens: 1200}) {
+		t.Fatalf("unexpected persisted counts: %+v", got)
+	}
+	if got := reopened.Total(); got.Turns != 2 {
+		t.Fatalf("unexpected total: %+v", got)
+	}
+
+	tomorrow := time.Now().Add(24 * time.Hour)
+	reopened.now = func() time.Time { return tomorrow }
+	if got := reopened.Get("intern"); got != (Counts{}) {
+		t.Fatalf("expected counters to reset on a new day, got %+v", got)
+	}
+}
+
+func TestReserveHoldsUsageUntilSettled(t *testing.T) {
+	tracker, _ := Open("")
+	allowOneTurn := func(used, total Counts) error {
+		if used.Turns >= 1 {
+			return errors.New("budget exhausted")
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	var admitted atomic.Int32
+	var reservation atomic.Pointer[Reservation]
+	for range 20 {
+		wg.Go(func() {
+			if r, err := tracker.Reserve("intern", Counts{Turns: 1, Tokens: 100}, allowOneTurn); err == nil {
+				admitted.Add(1)
+				reservation.Store(r)
+			}
+		})
+	}
+	wg.Wait()
+	if admitted.Load() != 1 {
+		t.Fatalf("expected exactly one concurrent reservation to pass, got %d", admitted.Load())
+	}
+	r := reservation.Load()
+	if err := r.Use(Counts{Turns: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Use(Counts{Tokens: 250}); err != nil {
+		t.Fatal(err)
+	}
+	r.Release()
+	r.Release()
+	if got := tracker.Get("intern"); got != (Counts{Turns: 1, Tokens: 250}) {
+		t.Fatalf("unexpected used counts: %+v", got)
+	}
+	var seen Counts
+	if _, err := tracker.Reserve("lead", Counts{}, func(used, total Counts) error { seen = total; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if seen != (Counts{Turns: 1, Tokens: 250}) {
+		t.Fatalf("a released reservation must no longer count, saw %+v", seen)
+	}
+}
@@ -0,0 +1,495 @@
+// Package webhooks delivers thread events to registered HTTP endpoints with
+// HMAC signatures and retries.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"darkhold-go/internal/i18n"
+	"github.com/oklog/ulid/v2"
+)
+
+var (
+	ErrNotFound = errors.New("webhook not found")
+	ErrInvalid  = errors.New("webhook url must be an absolute http or https URL")
+	// ErrInvalidLocale is returned by Create for a locale without a catalog.
+	ErrInvalidLocale = errors.New("webhook locale has no message catalog")
+)
+
+// Delivery statuses.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+	StatusDropped   = "dropped"
+)
+
+const (
+	// DefaultMaxAttempts is how many times an event is POSTed before the
+	// delivery is marked failed.
+	DefaultMaxAttempts = 5
+	// DefaultBackoff is the delay before the first retry; it doubles per
+	// attempt.
+	DefaultBackoff = time.Second
+
+	deliveryHistory = 100
+	queueSize       = 256
+)
+
+// Subscription is a registered endpoint. Methods are exact event methods or
+// prefixes ending in "*" ("item/*"); ThreadIDs scopes delivery to specific
+// threads. Empty filters match everything. Locale picks the language of the
+// envelope's message; empty means the server's default.
+type Subscription struct {
+	ID        string   `json:"id"`
+	URL       string   `json:"url"`
+	Secret    string   `json:"secret,omitempty"`
+	Methods   []string `json:"methods,omitempty"`
+	ThreadIDs []string `json:"threadIds,omitempty"`
+	Locale    string   `json:"locale,omitempty"`
+	CreatedAt int64    `json:"createdAt"`
+}
+
+// Matches reports whether an event on threadID with method should be sent to
+// the subscription.
+func (sub Subscription) Matches(threadID, method string) bool {
+	if len(sub.ThreadIDs) > 0 && !slices.Contains(sub.ThreadIDs, threadID) {
+		return false
+	}
+	if len(sub.Methods) == 0 {
+		return true
+	}
+	for _, pattern := range sub.Methods {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(method, prefix) {
+				return true
+			}
+		} else if pattern == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Redacted returns the subscription without its signing secret.
+func (sub Subscription) Redacted() Subscription {
+	sub.Secret = ""
+	return sub
+}
+
+// Delivery is the outcome of sending one event to one subscription.
+type Delivery struct {
+	ID             string `json:"id"`
+	SubscriptionID string `json:"subscriptionId"`
+	ThreadID       string `json:"threadId"`
+	EventID        string `json:"eventId"`
+	Method         string `json:"method"`
+	Status         string `json:"status"`
+	Attempts       int    `json:"attempts"`
+	LastStatusCode int    `json:"lastStatusCode,omitempty"`
+	LastError      string `json:"lastError,omitempty"`
+	CreatedAt      int64  `json:"createdAt"`
+	UpdatedAt      int64  `json:"updatedAt"`
+}
+
+// Event is a stored thread event offered to subscriptions.
+type Event struct {
+	ThreadID string
+	EventID  string
+	Seq      int64
+	Time     int64
+	Method   string
+	Payload  string
+	// Links are extra URLs for the receiver, such as one-tap interaction
+	// responses for darkhold/interaction/request events.
+	Links map[string]string
+	// Message, when set, returns a human-readable summary of the event in a
+	// subscription's locale ("" for the server default).
+	Message func(locale string) string
+}
+
+// Envelope is the JSON body POSTed to subscribers. Seq and Ts are the event
+// store's global sequence and append time; Event is the stored payload,
+// unchanged. Message is a summary in the subscription's locale, for events
+// a person acts on.
+type Envelope struct {
+	DeliveryID     string            `json:"deliveryId"`
+	SubscriptionID string            `json:"subscriptionId"`
+	ThreadID       string            `json:"threadId"`
+	EventID        string            `json:"eventId"`
+	Seq            int64             `json:"seq,omitempty"`
+	Ts             int64             `json:"ts,omitempty"`
+	Method         string            `json:"method"`
+	Event          json.RawMessage   `json:"event"`
+	Links          map[string]string `json:"links,omitempty"`
+	Message        string            `json:"message,omitempty"`
+}
+
+// Sign returns the X-Darkhold-Signature value for a body sent at timestamp:
+// "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body)).
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type job struct {
+	delivery *Delivery
+	body     []byte
+}
+
+type subscriber struct {
+	sub        Subscription
+	queue      chan job
+	deliveries []*Delivery
+}
+
+// Manager stores subscriptions and runs one delivery worker per
+// subscription, so each endpoint sees events in order.
+type Manager struct {
+	path   string
+	client *http.Client
+
+	// MaxAttempts and Backoff control retries; set before the first Create.
+	MaxAttempts int
+	Backoff     time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	subs map[string]*subscriber
+}
+
+type managerFile struct {
+	Webhooks []Subscription `json:"webhooks"`
+}
+
+// Open loads subscriptions stored at path and starts their workers. An empty
+// path keeps subscriptions in memory; a missing file starts empty.
+func Open(path string, client *http.Client) (*Manager, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Manager{
+		path:        path,
+		client:      client,
+		MaxAttempts: DefaultMaxAttempts,
+		Backoff:     DefaultBackoff,
+		ctx:         ctx,
+		cancel:      cancel,
+		subs:        map[string]*subscriber{},
+	}
+	if path == "" {
+		return m, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	var file managerFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		cancel()
+		return nil, err
+	}
+	for _, sub := range file.Webhooks {
+		m.startLocked(sub)
+	}
+	return m, nil
+}
+
+// Create validates and stores a subscription, generating its ID and, when
+// none is given, its secret.
+func (m *Manager) Create(sub Subscription) (Subscription, error) {
+	u, err := url.Parse(strings.TrimSpace(sub.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Subscription{}, ErrInvalid
+	}
+	sub.URL = u.String()
+	sub.ID = ulid.Make().String()
+	sub.Methods = compact(sub.Methods)
+	sub.ThreadIDs = compact(sub.ThreadIDs)
+	if sub.Locale = strings.TrimSpace(sub.Locale); sub.Locale != "" {
+		locale, ok := i18n.Supported(sub.Locale)
+		if !ok {
+			return Subscription{}, ErrInvalidLocale
+		}
+		sub.Locale = locale
+	}
+	if sub.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return Subscription{}, err
+		}
+		sub.Secret = hex.EncodeToString(secret)
+	}
+	sub.CreatedAt = time.Now().UnixMilli()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.startLocked(sub)
+	if err := m.saveLocked(); err != nil {
+		m.stopLocked(sub.ID)
+		return Subscription{}, err
+	}
+	return sub, nil
+}
+
+// Delete removes a subscription and abandons its queued deliveries. If the
+// file cannot be saved the subscription stays, still delivering.
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.subs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.subs, id)
+	if err := m.saveLocked(); err != nil {
+		m.subs[id] = s
+		return err
+	}
+	close(s.queue)
+	return nil
+}
+
+// List returns every subscription, oldest first.
+func (m *Manager) List() []Subscription {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Subscription, 0, len(m.subs))
+	for _, s := range m.subs {
+		out = append(out, s.sub)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Get returns one subscription.
+func (m *Manager) Get(id string) (Subscription, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.subs[id]
+	if !ok {
+		return Subscription{}, false
+	}
+	return s.sub, true
+}
+
+// Deliveries returns the most recent deliveries for a subscription, newest
+// first.
+func (m *Manager) Deliveries(id string) ([]Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.subs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	out := make([]Delivery, 0, len(s.deliveries))
+	for _, v := range slices.Backward(s.deliveries) {
+		out = append(out, *v)
+	}
+	return out, nil
+}
+
+// Publish queues a stored thread event for every matching subscription. It
+// never blocks: if a subscriber's queue is full the delivery is recorded as
+// dropped.
+func (m *Manager) Publish(ev Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UnixMilli()
+	for _, s := range m.subs {
+		if !s.sub.Matches(ev.ThreadID, ev.Method) {
+			continue
+		}
+		d := &Delivery{
+			ID:             ulid.Make().String(),
+			SubscriptionID: s.sub.ID,
+			ThreadID:       ev.ThreadID,
+			EventID:        ev.EventID,
+			Method:         ev.Method,
+			Status:         StatusPending,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		envelope := Envelope{
+			DeliveryID:     d.ID,
+			SubscriptionID: s.sub.ID,
+			ThreadID:       ev.ThreadID,
+			EventID:        ev.EventID,
+			Seq:            ev.Seq,
+			Ts:             ev.Time,
+			Method:         ev.Method,
+			Event:          json.RawMessage(ev.Payload),
+			Links:          ev.Links,
+		}
+		if ev.Message != nil {
+			envelope.Message = ev.Message(s.sub.Locale)
+		}
+		body, _ := json.Marshal(envelope)
+		s.deliveries = append(s.deliveries, d)
+		if len(s.deliveries) > deliveryHistory {
+			s.deliveries = s.deliveries[len(s.deliveries)-deliveryHistory:]
+		}
+		select {
+		case s.queue <- job{delivery: d, body: body}:
+		default:
+			d.Status = StatusDropped
+			d.LastError = "delivery queue full"
+		}
+	}
+}
+
+// Close stops every worker. Queued and in-flight deliveries are abandoned.
+func (m *Manager) Close() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+func (m *Manager) startLocked(sub Subscription) {
+	s := &subscriber{sub: sub, queue: make(chan job, queueSize)}
+	m.subs[sub.ID] = s
+	m.wg.Add(1)
+	go m.work(s)
+}
+
+func (m *Manager) stopLocked(id string) {
+	if s, ok := m.subs[id]; ok {
+		close(s.queue)
+		delete(m.subs, id)
+	}
+}
+
+func (m *Manager) work(s *subscriber) {
+	defer m.wg.Done()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case j, ok := <-s.queue:
+			if !ok {
+				return
+			}
+			m.deliver(s.sub, j)
+		}
+	}
+}
+
+func (m *Manager) deliver(sub Subscription, j job) {
+	maxAttempts := max(m.MaxAttempts, 1)
+	backoff := m.Backoff
+	for attempt := 1; ; attempt++ {
+		code, err := m.post(sub, j)
+		retryable := err != nil || code == http.StatusTooManyRequests || code >= 500
+		m.mu.Lock()
+		j.delivery.Attempts = attempt
+		j.delivery.LastStatusCode = code
+		j.delivery.UpdatedAt = time.Now().UnixMilli()
+		switch {
+		case err == nil && code >= 200 && code < 300:
+			j.delivery.Status = StatusDelivered
+			j.delivery.LastError = ""
+		case err != nil:
+			j.delivery.LastError = err.Error()
+		default:
+			j.delivery.LastError = fmt.Sprintf("endpoint returned HTTP %d", code)
+		}
+		done := j.delivery.Status == StatusDelivered || !retryable || attempt >= maxAttempts
+		if done && j.delivery.Status != StatusDelivered {
+			j.delivery.Status = StatusFailed
+		}
+		m.mu.Unlock()
+		if done {
+			return
+		}
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (m *Manager) post(sub Subscription, j job) (int, error) {
+	req, err := http.NewRequestWithContext(m.ctx, http.MethodPost, sub.URL, bytes.NewReader(j.body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "darkhold-webhooks")
+	req.Header.Set("X-Darkhold-Delivery", j.delivery.ID)
+	req.Header.Set("X-Darkhold-Event", j.delivery.Method)
+	req.Header.Set("X-Darkhold-Timestamp", timestamp)
+	req.Header.Set("X-Darkhold-Signature", Sign(sub.Secret, timestamp, j.body))
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func (m *Manager) saveLocked() error {
+	if m.path == "" {
+		return nil
+	}
+	file := managerFile{Webhooks: make([]Subscription, 0, len(m.subs))}
+	for _, s := range m.subs {
+		file.Webhooks = append(file.Webhooks, s.sub)
+	}
+	sort.Slice(file.Webhooks, func(i, j int) bool { return file.Webhooks[i].ID < file.Webhooks[j].ID })
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0o755); err != nil {
+		return err
+	}
+	// The file holds signing secrets, so keep it private to the user.
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+func compact(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" && !slices.Contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,185 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func waitForDelivery(t *testing.T, m *Manager, id string, done func(Delivery) bool) Delivery {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		deliveries, err := m.Deliveries(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deliveries) > 0 && done(deliveries[0]) {
+			return deliveries[0]
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("delivery for %s did not settle", id)
+	return Delivery{}
+}
+
+func TestSubscriptionMatches(t *testing.T) {
+	sub := Subscription{Methods: []string{"turn/completed", "item/*"}, ThreadIDs: []string{"t1"}}
+	cases := []struct {
+		thread, method string
+		want           bool
+	}{
+		{"t1", "turn/completed", true},
+		{"t1", "item/completed", true},
+		{"t1", "turn/started", false},
+		{"t2", "turn/completed", false},
+	}
+	for _, tc := range cases {
+		if got := sub.Matches(tc.thread, tc.method); got != tc.want {
+			t.Errorf("Matches(%q, %q) = %v, want %v", tc.thread, tc.method, got, tc.want)
+		}
+	}
+	if !(Subscription{}).Matches("any", "anything") {
+		t.Fatal("expected empty filters to match everything")
+	}
+}
+
+func TestDeliverySignsAndRetries(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	var body []byte
+	var header http.Header
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header.Clone()
+	}))
+	defer endpoint.Close()
+
+	m, err := Open("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	m.Backoff = time.Millisecond
+	sub, err := m.Create(Subscription{URL: endpoint.URL, Secret: "s3cret", Methods: []string{"turn/completed"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.Publish(Event{ThreadID: "t1", EventID: "e1", Seq: 1, Method: "turn/started", Payload: `{"method":"turn/started"}`})
+	m.Publish(Event{ThreadID: "t1", EventID: "e2", Seq: 2, Method: "turn/completed", Payload: `{"method":"turn/completed","params":{"threadId":"t1"}}`})
+	d := waitForDelivery(t, m, sub.ID, func(d Delivery) bool { return d.Status != StatusPending })
+	if d.Status != StatusDelivered || d.Attempts != 2 || d.EventID != "e2" {
+		t.Fatalf("unexpected delivery: %+v", d)
+	}
+	if all, _ := m.Deliveries(sub.ID); len(all) != 1 {
+		t.Fatalf("expected filtered events to be skipped, got %+v", all)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := Sign("s3cret", header.Get("X-Darkhold-Timestamp"), body); header.Get("X-Darkhold-Signature") != want {
+		t.Fatalf("signature mismatch: %q vs %q", header.Get("X-Darkhold-Signature"), want)
+	}
+	var envelope Envelope
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.ThreadID != "t1" || envelope.Method != "turn/completed" || envelope.Seq != 2 || header.Get("X-Darkhold-Delivery") != d.ID {
+		t.Fatalf("unexpected envelope %s (%v)", body, err)
+	}
+}
+
+func TestDeliveryGivesUpOnClientErrors(t *testing.T) {
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer endpoint.Close()
+
+	m, _ := Open("", nil)
+	defer m.Close()
+	m.Backoff = time.Millisecond
+	sub, _ := m.Create(Subscription{URL: endpoint.URL})
+	m.Publish(Event{ThreadID: "t1", EventID: "e1", Method: "turn/completed", Payload: `{}`})
+	d := waitForDelivery(t, m, sub.ID, func(d Delivery) bool { return d.Status != StatusPending })
+	if d.Status != StatusFailed || d.Attempts != 1 || d.LastStatusCode != http.StatusGone {
+		t.Fatalf("expected a single failed attempt, got %+v", d)
+	}
+}
+
+func TestSubscriptionsPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	m, err := Open(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Create(Subscription{URL: "ftp://example.com"}); err != ErrInvalid {
+		t.Fatalf("expected invalid URL to be rejected, got %v", err)
+	}
+	sub, err := m.Create(Subscription{URL: "https://example.com/hook", ThreadIDs: []string{"t1", " ", "t1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+
+	reopened, err := Open(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	got, ok := reopened.Get(sub.ID)
+	if !ok || got.Secret != sub.Secret || len(got.ThreadIDs) != 1 {
+		t.Fatalf("unexpected reloaded subscription: %+v", got)
+	}
+	if err := reopened.Delete(sub.ID); err != nil || len(reopened.List()) != 0 {
+		t.Fatalf("expected delete to succeed: %v", err)
+	}
+}
+
+func TestDeleteKeepsSubscriptionWhenSaveFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	m, err := Open(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	sub, err := m.Create(Subscription{URL: "https://example.com/hook"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A directory where the temporary file goes makes the save fail.
+	if err := os.Mkdir(path+".tmp", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Delete(sub.ID); err == nil {
+		t.Fatal("expected delete to fail when the file cannot be saved")
+	}
+	if _, ok := m.Get(sub.ID); !ok {
+		t.Fatal("a failed delete must keep the subscription")
+	}
+
+	if err := os.Remove(path + ".tmp"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Delete(sub.ID); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := Open(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if len(reopened.List()) != 0 {
+		t.Fatalf("expected the delete to be saved, got %+v", reopened.List())
+	}
+}